@@ -0,0 +1,185 @@
+package main
+
+import (
+    "context"
+    "crypto/rand"
+    "flag"
+    "fmt"
+    "os"
+    "time"
+
+    "auth-service/internal/anonymize"
+    "auth-service/internal/config"
+    "auth-service/internal/database"
+    "auth-service/internal/keybundle"
+
+    "golang.org/x/crypto/bcrypt"
+)
+
+// Environment variable holding the key bundle passphrase. It is never read
+// from flags so it doesn't end up in shell history or process listings.
+const bundlePassphraseEnv = "BUNDLE_PASSPHRASE"
+
+// Environment variables for anonymize-users: the seed that keys the fake
+// values (random when unset) and the password every anonymized account gets
+// (none when unset).
+const (
+    anonymizeSeedEnv     = "ANONYMIZE_SEED"
+    anonymizePasswordEnv = "ANONYMIZE_PASSWORD"
+)
+
+// runCommand runs the maintenance subcommand named by args[0].
+func runCommand(args []string) error {
+    switch args[0] {
+    case "export-bundle":
+        return exportBundle(args[1:])
+    case "import-bundle":
+        return importBundle(args[1:])
+    case "anonymize-users":
+        return anonymizeUsers(args[1:])
+    default:
+        return fmt.Errorf("unknown command %q (expected export-bundle, import-bundle or anonymize-users)", args[0])
+    }
+}
+
+// exportBundle seals the current key material and critical settings into an
+// encrypted bundle.
+func exportBundle(args []string) error {
+    fs := flag.NewFlagSet("export-bundle", flag.ContinueOnError)
+    out := fs.String("out", "", "path of the bundle to create")
+    if err := fs.Parse(args); err != nil {
+        return err
+    }
+    if *out == "" {
+        return fmt.Errorf("-out is required")
+    }
+
+    passphrase := os.Getenv(bundlePassphraseEnv)
+    if passphrase == "" {
+        return fmt.Errorf("%s must be set", bundlePassphraseEnv)
+    }
+
+    cfg, err := config.Load()
+    if err != nil {
+        return fmt.Errorf("load config: %w", err)
+    }
+
+    settings, err := config.BundledSettings()
+    if err != nil {
+        return err
+    }
+
+    data, err := keybundle.Seal(&keybundle.Snapshot{
+        Environment: cfg.Environment,
+        CreatedAt:   time.Now().UTC(),
+        Settings:    settings,
+    }, passphrase)
+    if err != nil {
+        return err
+    }
+
+    // O_EXCL so an existing bundle is never silently replaced
+    f, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+    if err != nil {
+        return fmt.Errorf("create bundle: %w", err)
+    }
+    defer f.Close()
+
+    if _, err := f.Write(data); err != nil {
+        return fmt.Errorf("write bundle: %w", err)
+    }
+
+    fmt.Printf("Exported %s key bundle to %s\n", cfg.Environment, *out)
+    return nil
+}
+
+// importBundle decrypts a bundle into a config file the service can load.
+func importBundle(args []string) error {
+    fs := flag.NewFlagSet("import-bundle", flag.ContinueOnError)
+    in := fs.String("in", "", "path of the bundle to import")
+    out := fs.String("out", "config/config.yaml", "config file to write")
+    force := fs.Bool("force", false, "replace an existing config file")
+    if err := fs.Parse(args); err != nil {
+        return err
+    }
+    if *in == "" {
+        return fmt.Errorf("-in is required")
+    }
+
+    passphrase := os.Getenv(bundlePassphraseEnv)
+    if passphrase == "" {
+        return fmt.Errorf("%s must be set", bundlePassphraseEnv)
+    }
+
+    data, err := os.ReadFile(*in)
+    if err != nil {
+        return fmt.Errorf("read bundle: %w", err)
+    }
+
+    snapshot, err := keybundle.Open(data, passphrase)
+    if err != nil {
+        return err
+    }
+
+    if err := config.WriteSettings(*out, snapshot.Settings, *force); err != nil {
+        return err
+    }
+
+    fmt.Printf("Imported %s key bundle from %s (created %s) to %s\n",
+        snapshot.Environment, *in, snapshot.CreatedAt.Format(time.RFC3339), *out)
+    return nil
+}
+
+// anonymizeUsers replaces the personal data in the configured database with
+// fakes. It is meant for a copy of production restored into a lower
+// environment and refuses to run against production itself.
+func anonymizeUsers(args []string) error {
+    fs := flag.NewFlagSet("anonymize-users", flag.ContinueOnError)
+    yes := fs.Bool("yes", false, "confirm that the configured database may be rewritten")
+    if err := fs.Parse(args); err != nil {
+        return err
+    }
+
+    cfg, err := config.Load()
+    if err != nil {
+        return fmt.Errorf("load config: %w", err)
+    }
+    if cfg.Profile.Name == "production" {
+        return fmt.Errorf("refusing to anonymize a production database")
+    }
+    if !*yes {
+        return fmt.Errorf("this rewrites every user in the %s database; pass -yes to continue", cfg.Environment)
+    }
+
+    seed := []byte(os.Getenv(anonymizeSeedEnv))
+    if len(seed) == 0 {
+        seed = make([]byte, 32)
+        if _, err := rand.Read(seed); err != nil {
+            return fmt.Errorf("generate seed: %w", err)
+        }
+    }
+
+    opts := anonymize.Options{}
+    if password := os.Getenv(anonymizePasswordEnv); password != "" {
+        hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+        if err != nil {
+            return fmt.Errorf("hash password: %w", err)
+        }
+        opts.PasswordHash = string(hash)
+    }
+
+    db, err := database.New(cfg.DatabaseURL)
+    if err != nil {
+        return fmt.Errorf("connect to database: %w", err)
+    }
+    defer db.Close()
+
+    report, err := anonymize.Rewrite(context.Background(), db, anonymize.NewFaker(seed), opts)
+    if err != nil {
+        return err
+    }
+
+    fmt.Printf("Anonymized %d users and %d IP addresses, cleared %d pending rows\n",
+        report.Users, report.IPs, report.RowsCleared)
+    return nil
+}
@@ -14,6 +14,8 @@ import (
 	"auth-service/internal/middleware"
 	"auth-service/internal/models"
 	"auth-service/internal/services"
+	"auth-service/internal/store"
+	"auth-service/internal/validation"
 	"auth-service/test"
 
 	"github.com/gin-gonic/gin"
@@ -25,24 +27,32 @@ import (
 
 type IntegrationTestSuite struct {
 	suite.Suite
-	app    *gin.Engine
-	suite_ *test.TestSuite
+	app             *gin.Engine
+	suite_          *test.TestSuite
+	accountDeletion *services.AccountDeletionService
 }
 
 func (s *IntegrationTestSuite) SetupSuite() {
 	s.suite_ = test.NewTestSuite(s.T())
 
 	// Initialize services
-	authService := services.NewAuthService(s.suite_.DB.DB, s.suite_.Redis.Client, s.suite_.Config, s.suite_.Logger)
-	userService := services.NewUserService(s.suite_.DB.DB, s.suite_.Logger)
-	tokenService := services.NewTokenService(s.suite_.Config.JWTSecret, s.suite_.Config.JWTExpiry, s.suite_.Redis.Client, s.suite_.Logger)
+	authService := services.NewAuthService(s.suite_.DB.DB, s.suite_.Redis.Client, s.suite_.Config, s.suite_.Logger, s.suite_.Events)
+	userService := services.NewUserService(s.suite_.DB.DB, s.suite_.Redis.Client, s.suite_.Logger)
+	tokenService := services.NewTokenService(test.Keyring(s.T(), s.suite_.Config.JWTSecret), s.suite_.Config.JWTExpiry, store.NewRedis(s.suite_.Redis.Client), s.suite_.Logger)
 
 	// Initialize handlers
+	onboarding := services.NewOnboardingService(s.suite_.DB.DB, s.suite_.Events, s.suite_.Logger)
 	authHandler := handlers.NewAuthHandler(authService, userService, tokenService, s.suite_.Logger)
-	userHandler := handlers.NewUserHandler(userService, s.suite_.Logger)
+	authHandler.SetHandles(services.NewHandleService(s.suite_.DB.DB, s.suite_.Logger))
+	authHandler.SetFunnel(services.NewFunnelService(s.suite_.DB.DB, s.suite_.Logger))
+	authHandler.SetOnboarding(onboarding)
+	authHandler.SetLoginGuard(services.NewLoginGuard(s.suite_.Redis.Client, s.suite_.Logger))
+	authHandler.SetRefreshGuard(services.NewRefreshGuard(s.suite_.Redis.Client, services.NewIPBanService(s.suite_.Redis.Client, s.suite_.Logger), s.suite_.Logger))
+	s.accountDeletion = services.NewAccountDeletionService(s.suite_.DB.DB, userService, services.NewWebhookService(s.suite_.DB.DB, s.suite_.Logger), s.suite_.Events, s.suite_.Logger)
+	userHandler := handlers.NewUserHandler(userService, s.accountDeletion, onboarding, s.suite_.Logger)
 
 	// Setup router
-	s.app = s.setupIntegrationRouter(s.suite_.Config, authHandler, userHandler, tokenService, s.suite_.Logger)
+	s.app = s.setupIntegrationRouter(s.suite_.Config, authHandler, userHandler, tokenService, authService, s.suite_.Logger)
 }
 
 func (s *IntegrationTestSuite) TearDownSuite() {
@@ -58,15 +68,19 @@ func (s *IntegrationTestSuite) setupIntegrationRouter(
 	authHandler *handlers.AuthHandler,
 	userHandler *handlers.UserHandler,
 	tokenService *services.TokenService,
+	authService *services.AuthService,
 	logger *zap.SugaredLogger,
 ) *gin.Engine {
 	gin.SetMode(gin.TestMode)
+	if err := validation.Setup(validation.DefaultLimits); err != nil {
+		panic(err)
+	}
 
 	router := gin.New()
 	router.Use(gin.Recovery())
-	router.Use(middleware.Logger(logger))
-	router.Use(middleware.CORS(cfg.AllowedOrigins))
-	router.Use(middleware.RateLimit(cfg.RateLimit))
+	router.Use(middleware.Logger(logger, config.RequestLogAll))
+	router.Use(middleware.CORS(cfg.CORSPolicies[config.CORSGroupPublic]))
+	router.Use(middleware.RateLimit(cfg.RateLimit, nil))
 
 	// Health check
 	router.GET("/health", func(c *gin.Context) {
@@ -81,7 +95,8 @@ func (s *IntegrationTestSuite) setupIntegrationRouter(
 			auth.POST("/register", authHandler.Register)
 			auth.POST("/login", authHandler.Login)
 			auth.POST("/refresh", authHandler.RefreshToken)
-			auth.POST("/logout", middleware.Auth(tokenService), authHandler.Logout)
+			auth.POST("/logout", middleware.Auth(tokenService, nil), authHandler.Logout)
+			auth.POST("/sudo", middleware.Auth(tokenService, nil), authHandler.Sudo)
 			auth.POST("/verify-email", authHandler.VerifyEmail)
 			auth.POST("/forgot-password", authHandler.ForgotPassword)
 			auth.POST("/reset-password", authHandler.ResetPassword)
@@ -89,12 +104,13 @@ func (s *IntegrationTestSuite) setupIntegrationRouter(
 
 		// Protected routes
 		users := v1.Group("/users")
-		users.Use(middleware.Auth(tokenService))
+		users.Use(middleware.Auth(tokenService, nil))
 		{
 			users.GET("/me", userHandler.GetCurrentUser)
 			users.PUT("/me", userHandler.UpdateProfile)
 			users.PUT("/me/password", userHandler.ChangePassword)
-			users.DELETE("/me", userHandler.DeleteAccount)
+			users.DELETE("/me", middleware.RequireSudo(authService), userHandler.DeleteAccount)
+			users.GET("/me/deletion-status", userHandler.DeletionStatus)
 		}
 	}
 
@@ -148,7 +164,7 @@ func (s *IntegrationTestSuite) TestUserRegistrationAndLogin() {
 	w := s.makeRequest("POST", "/api/v1/auth/register", registerReq, nil)
 	assert.Equal(s.T(), http.StatusCreated, w.Code)
 
-	var user models.User
+	var user models.PrivateUser
 	err := json.Unmarshal(w.Body.Bytes(), &user)
 	require.NoError(s.T(), err)
 	assert.Equal(s.T(), registerReq.Email, user.Email)
@@ -208,7 +224,7 @@ func (s *IntegrationTestSuite) TestUserProfileOperations() {
 	w = s.makeRequest("GET", "/api/v1/users/me", nil, authHeaders)
 	assert.Equal(s.T(), http.StatusOK, w.Code)
 
-	var user models.User
+	var user models.PrivateUser
 	err = json.Unmarshal(w.Body.Bytes(), &user)
 	require.NoError(s.T(), err)
 	assert.Equal(s.T(), registerReq.Email, user.Email)
@@ -414,17 +430,37 @@ func (s *IntegrationTestSuite) TestAccountDeletion() {
 		"Authorization": "Bearer " + tokenResponse.AccessToken,
 	}
 
+	// Deleting needs the session elevated first
+	w = s.makeRequest("DELETE", "/api/v1/users/me", nil, authHeaders)
+	assert.Equal(s.T(), http.StatusForbidden, w.Code)
+
+	w = s.makeRequest("POST", "/api/v1/auth/sudo", models.SudoRequest{Password: "wrong"}, authHeaders)
+	assert.Equal(s.T(), http.StatusUnauthorized, w.Code)
+
+	w = s.makeRequest("POST", "/api/v1/auth/sudo", models.SudoRequest{Password: registerReq.Password}, authHeaders)
+	require.Equal(s.T(), http.StatusOK, w.Code)
+
 	// Delete account
 	w = s.makeRequest("DELETE", "/api/v1/users/me", nil, authHeaders)
-	assert.Equal(s.T(), http.StatusOK, w.Code)
+	assert.Equal(s.T(), http.StatusAccepted, w.Code)
+
+	// Login stops working as soon as the deletion is queued
+	w = s.makeRequest("POST", "/api/v1/auth/login", loginReq, nil)
+	assert.Equal(s.T(), http.StatusUnauthorized, w.Code)
+
+	// Run the deletion worker
+	_, err = s.accountDeletion.ProcessPending(context.Background(), 10)
+	require.NoError(s.T(), err)
+
+	var deletion models.AccountDeletion
+	w = s.makeRequest("GET", "/api/v1/users/me/deletion-status", nil, authHeaders)
+	require.Equal(s.T(), http.StatusOK, w.Code)
+	require.NoError(s.T(), json.Unmarshal(w.Body.Bytes(), &deletion))
+	assert.Equal(s.T(), models.DeletionStatusCompleted, deletion.Status)
 
 	// Verify account is deleted - getting user info should fail
 	w = s.makeRequest("GET", "/api/v1/users/me", nil, authHeaders)
 	assert.Equal(s.T(), http.StatusInternalServerError, w.Code)
-
-	// Verify login no longer works
-	w = s.makeRequest("POST", "/api/v1/auth/login", loginReq, nil)
-	assert.Equal(s.T(), http.StatusUnauthorized, w.Code)
 }
 
 func (s *IntegrationTestSuite) TestRateLimiting() {
@@ -0,0 +1,140 @@
+// Package anonymize rewrites the personal data in a copy of the production
+// database with fake values of the same shape, so staging and other lower
+// environments can run on production-sized data without exposing real users.
+package anonymize
+
+import (
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/binary"
+    "encoding/hex"
+    "net"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Fake email domains end in the reserved .invalid TLD so nothing is ever
+// delivered to them.
+const fakeEmailTLD = "invalid"
+
+const (
+    lowerLetters = "abcdefghijklmnopqrstuvwxyz"
+    upperLetters = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+    digits       = "0123456789"
+)
+
+// Faker maps real values to fake ones. The mapping is keyed by the seed, so
+// the same value always gets the same fake within and across runs using the
+// seed, and can't be reversed by anyone who doesn't know it.
+type Faker struct {
+    key []byte
+}
+
+func NewFaker(seed []byte) *Faker {
+    return &Faker{key: seed}
+}
+
+// Email fakes the local part character by character and every domain label
+// but the TLD, which becomes .invalid. Users on the same domain keep sharing
+// a (fake) domain. attempt picks another fake for the same email when the
+// first one is taken.
+func (f *Faker) Email(email string, attempt int) string {
+    at := strings.LastIndex(email, "@")
+    if at < 0 {
+        return f.mask("email", email, attempt)
+    }
+
+    local := f.mask("email", email, attempt)[:at]
+    labels := strings.Split(email[at+1:], ".")
+    if len(labels) > 1 {
+        labels = labels[:len(labels)-1]
+    }
+    for i, label := range labels {
+        labels[i] = f.mask("domain", strings.ToLower(label), 0)
+    }
+    return local + "@" + strings.Join(append(labels, fakeEmailTLD), ".")
+}
+
+// Username fakes each character with one of the same class, so the result
+// passes the same validation as the original.
+func (f *Faker) Username(username string, attempt int) string {
+    return f.mask("username", username, attempt)
+}
+
+// Text fakes free text such as display names, keeping its length, case and
+// punctuation.
+func (f *Faker) Text(text string) string {
+    return f.mask("text", text, 0)
+}
+
+// URL replaces a URL with one on example.com that is stable per input.
+func (f *Faker) URL(url string) string {
+    return "https://example.com/" + hex.EncodeToString(f.sum("url", url, 0)[:8])
+}
+
+// Date moves a date to another day of the same year, so ages stay roughly
+// right.
+func (f *Faker) Date(date time.Time) time.Time {
+    start := time.Date(date.Year(), time.January, 1, 0, 0, 0, 0, date.Location())
+    days := start.AddDate(1, 0, 0).Sub(start).Hours() / 24
+    offset := binary.BigEndian.Uint32(f.sum("date", date.Format("2006-01-02"), 0)) % uint32(days)
+    return start.AddDate(0, 0, int(offset))
+}
+
+// IP fakes an address of the same family. Addresses that shared a network
+// (/24 for IPv4, /64 for IPv6) still share one afterwards, so per-network
+// rate limits and bans behave as they would on the real data. Values that
+// aren't IP addresses are masked as text.
+func (f *Faker) IP(ip string) string {
+    parsed := net.ParseIP(ip)
+    if parsed == nil {
+        return f.mask("text", ip, 0)
+    }
+
+    if v4 := parsed.To4(); v4 != nil {
+        network := f.sum("net4", net.IP(v4[:3]).String(), 0)
+        host := f.sum("ip4", ip, 0)
+        // Host byte stays within 1-254 so it is never a network or broadcast address
+        return net.IPv4(network[0], network[1], network[2], 1+host[0]%254).String()
+    }
+
+    fake := make(net.IP, net.IPv6len)
+    copy(fake[:8], f.sum("net6", hex.EncodeToString(parsed[:8]), 0))
+    copy(fake[8:], f.sum("ip6", ip, 0))
+    return fake.String()
+}
+
+// mask replaces every letter and digit in value with a pseudo-random one of
+// the same class and case, leaving other characters where they are.
+func (f *Faker) mask(kind, value string, attempt int) string {
+    sum := f.sum(kind, value, attempt)
+    var b strings.Builder
+    b.Grow(len(value))
+    for i := 0; i < len(value); i++ {
+        // Stretch the digest for values longer than it
+        if i > 0 && i%len(sum) == 0 {
+            sum = f.sum(kind, value+"#"+strconv.Itoa(i), attempt)
+        }
+        n := int(sum[i%len(sum)])
+
+        c := value[i]
+        switch {
+        case c >= 'a' && c <= 'z':
+            b.WriteByte(lowerLetters[n%len(lowerLetters)])
+        case c >= 'A' && c <= 'Z':
+            b.WriteByte(upperLetters[n%len(upperLetters)])
+        case c >= '0' && c <= '9':
+            b.WriteByte(digits[n%len(digits)])
+        default:
+            b.WriteByte(c)
+        }
+    }
+    return b.String()
+}
+
+func (f *Faker) sum(kind, value string, attempt int) []byte {
+    mac := hmac.New(sha256.New, f.key)
+    mac.Write([]byte(kind + ":" + strconv.Itoa(attempt) + ":" + value))
+    return mac.Sum(nil)
+}
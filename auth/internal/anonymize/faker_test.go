@@ -0,0 +1,87 @@
+package anonymize
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFaker_Email(t *testing.T) {
+	f := NewFaker([]byte("seed"))
+
+	fake := f.Email("Jane.Doe42@mail.example.org", 0)
+	assert.NotEqual(t, "Jane.Doe42@mail.example.org", fake)
+	assert.Len(t, strings.Split(fake, "@")[0], len("Jane.Doe42"))
+	assert.True(t, strings.HasSuffix(fake, ".invalid"))
+	assert.Regexp(t, `^[A-Z][a-z]{3}\.[A-Z][a-z]{2}[0-9]{2}@[a-z]{4}\.[a-z]{7}\.invalid$`, fake)
+
+	// Deterministic per seed, different per attempt and seed
+	assert.Equal(t, fake, f.Email("Jane.Doe42@mail.example.org", 0))
+	assert.NotEqual(t, fake, f.Email("Jane.Doe42@mail.example.org", 1))
+	assert.NotEqual(t, fake, NewFaker([]byte("other")).Email("Jane.Doe42@mail.example.org", 0))
+
+	// Users on the same domain share a fake domain
+	a := strings.Split(f.Email("a@gmail.com", 0), "@")[1]
+	b := strings.Split(f.Email("b@GMAIL.com", 0), "@")[1]
+	assert.Equal(t, a, b)
+}
+
+func TestFaker_Username(t *testing.T) {
+	f := NewFaker([]byte("seed"))
+
+	fake := f.Username("cool_user_99", 0)
+	assert.Regexp(t, `^[a-z]{4}_[a-z]{4}_[0-9]{2}$`, fake)
+	assert.NotEqual(t, "cool_user_99", fake)
+
+	// Long values are masked all the way through
+	long := strings.Repeat("a", 50)
+	assert.Regexp(t, `^[a-z]{50}$`, f.Username(long, 0))
+	assert.NotEqual(t, long, f.Username(long, 0))
+}
+
+func TestFaker_IP(t *testing.T) {
+	f := NewFaker([]byte("seed"))
+
+	a := net.ParseIP(f.IP("203.0.113.7")).To4()
+	b := net.ParseIP(f.IP("203.0.113.200")).To4()
+	c := net.ParseIP(f.IP("198.51.100.7")).To4()
+	assert.NotNil(t, a)
+	assert.Equal(t, a[:3], b[:3], "same /24 stays together")
+	assert.NotEqual(t, a[:3], c[:3])
+	assert.NotEqual(t, "203.0.113.7", a.String())
+
+	v6 := net.ParseIP(f.IP("2001:db8::1"))
+	assert.NotNil(t, v6)
+	assert.Nil(t, v6.To4())
+	assert.Equal(t, v6[:8], net.ParseIP(f.IP("2001:db8::2"))[:8], "same /64 stays together")
+
+	assert.Equal(t, f.IP("203.0.113.7"), f.IP("203.0.113.7"))
+}
+
+func TestFaker_Date(t *testing.T) {
+	f := NewFaker([]byte("seed"))
+
+	dob := time.Date(1990, time.June, 15, 0, 0, 0, 0, time.UTC)
+	fake := f.Date(dob)
+	assert.Equal(t, 1990, fake.Year())
+	assert.Equal(t, fake, f.Date(dob))
+}
+
+func TestUniqueFake(t *testing.T) {
+	f := NewFaker([]byte("seed"))
+	taken := map[string]bool{}
+
+	// Single-letter usernames run out of fakes quickly, so some fall back to
+	// a numbered suffix; none may repeat
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		v := uniqueFake(taken, "x", f.Username)
+		assert.False(t, seen[v], v)
+		seen[v] = true
+	}
+
+	assert.Equal(t, "abc7@x.invalid", numbered("abc@x.invalid", 7))
+}
@@ -0,0 +1,345 @@
+package anonymize
+
+import (
+    "context"
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+
+    "auth-service/internal/database"
+
+    "github.com/google/uuid"
+    "github.com/jackc/pgx/v5"
+)
+
+// How many fakes to try for an email or username before falling back to a
+// numbered suffix. Only very short values run out.
+const maxFakeAttempts = 8
+
+// Options controls what Rewrite does besides faking personal data.
+type Options struct {
+    // PasswordHash replaces every user's password hash. Empty leaves
+    // accounts without a usable password.
+    PasswordHash string
+}
+
+// Report counts what Rewrite changed.
+type Report struct {
+    Users       int
+    IPs         int
+    RowsCleared int64
+}
+
+// Tables whose rows are deleted outright: pending emails and events that
+// would reach real people or subscribers, sandboxed emails and tokens and
+// codes bound to real addresses, webhook endpoints owned by real users, and copies of real
+// usernames, display names and emails kept by moderation and impersonation
+// review.
+var clearedTables = []string{
+    "email_outbox",
+    "sent_emails",
+    "event_outbox",
+    "event_journal",
+    "email_verification_tokens",
+    "login_challenges",
+    "login_confirmation_tokens",
+    "user_webhooks",
+    "moderation_checks",
+    "moderation_denials",
+    "impersonation_reviews",
+}
+
+type fakeUser struct {
+    id            uuid.UUID
+    email         string
+    username      string
+    recoveryEmail *string
+    displayName   *string
+    avatarURL     *string
+    dateOfBirth   *time.Time
+}
+
+// Rewrite replaces personal data throughout the database in a single
+// transaction. It must only ever be pointed at a copy of production.
+func Rewrite(ctx context.Context, db *database.DB, faker *Faker, opts Options) (*Report, error) {
+    tx, err := db.Pool().Begin(ctx)
+    if err != nil {
+        return nil, fmt.Errorf("begin transaction: %w", err)
+    }
+    defer tx.Rollback(ctx)
+
+    report := &Report{}
+
+    users, err := loadUsers(ctx, tx)
+    if err != nil {
+        return nil, err
+    }
+    if err := rewriteUsers(ctx, tx, faker, users, opts); err != nil {
+        return nil, err
+    }
+    report.Users = len(users)
+
+    ips, err := rewriteIPs(ctx, tx, faker)
+    if err != nil {
+        return nil, err
+    }
+    report.IPs = ips
+
+    if err := rewriteRecoveryRequests(ctx, tx, faker); err != nil {
+        return nil, err
+    }
+
+    // Note bodies are admin free text about real people; the notes and
+    // their flags stay so support tooling has something to show
+    if _, err := tx.Exec(ctx, `UPDATE account_notes SET body = '' WHERE body <> ''`); err != nil {
+        return nil, fmt.Errorf("clear account notes: %w", err)
+    }
+
+    for _, table := range clearedTables {
+        result, err := tx.Exec(ctx, "DELETE FROM "+table)
+        if err != nil {
+            return nil, fmt.Errorf("clear %s: %w", table, err)
+        }
+        report.RowsCleared += result.RowsAffected()
+    }
+
+    if err := tx.Commit(ctx); err != nil {
+        return nil, fmt.Errorf("commit transaction: %w", err)
+    }
+    return report, nil
+}
+
+func loadUsers(ctx context.Context, tx pgx.Tx) ([]*fakeUser, error) {
+    rows, err := tx.Query(ctx,
+        `SELECT id, email, username, recovery_email, display_name, avatar_url, date_of_birth
+         FROM users ORDER BY created_at, id`,
+    )
+    if err != nil {
+        return nil, fmt.Errorf("load users: %w", err)
+    }
+    defer rows.Close()
+
+    users := []*fakeUser{}
+    for rows.Next() {
+        u := &fakeUser{}
+        if err := rows.Scan(&u.id, &u.email, &u.username, &u.recoveryEmail, &u.displayName, &u.avatarURL, &u.dateOfBirth); err != nil {
+            return nil, fmt.Errorf("scan user: %w", err)
+        }
+        users = append(users, u)
+    }
+    return users, rows.Err()
+}
+
+// rewriteUsers fakes every user's identifying columns and drops their
+// secrets. Emails and usernames are first parked on values derived from the
+// ID, so a fake can never collide with a real value that hasn't been
+// rewritten yet.
+func rewriteUsers(ctx context.Context, tx pgx.Tx, faker *Faker, users []*fakeUser, opts Options) error {
+    _, err := tx.Exec(ctx,
+        `UPDATE users SET
+             email = id::text || '@anonymize.invalid',
+             username = 'anon_' || replace(id::text, '-', '')`,
+    )
+    if err != nil {
+        return fmt.Errorf("park users: %w", err)
+    }
+
+    emails := make(map[string]bool, len(users))
+    usernames := make(map[string]bool, len(users))
+    rows := make([][]interface{}, 0, len(users))
+    for _, u := range users {
+        email := uniqueFake(emails, u.email, faker.Email)
+        username := uniqueFake(usernames, u.username, faker.Username)
+
+        var recoveryEmail, displayName, avatarURL *string
+        var dateOfBirth *time.Time
+        if u.recoveryEmail != nil {
+            v := faker.Email(*u.recoveryEmail, 0)
+            recoveryEmail = &v
+        }
+        if u.displayName != nil {
+            v := faker.Text(*u.displayName)
+            displayName = &v
+        }
+        if u.avatarURL != nil {
+            v := faker.URL(*u.avatarURL)
+            avatarURL = &v
+        }
+        if u.dateOfBirth != nil {
+            v := faker.Date(*u.dateOfBirth)
+            dateOfBirth = &v
+        }
+
+        rows = append(rows, []interface{}{u.id, email, username, recoveryEmail, displayName, avatarURL, dateOfBirth})
+    }
+
+    _, err = tx.Exec(ctx,
+        `CREATE TEMP TABLE anonymized_users (
+             id UUID PRIMARY KEY,
+             email VARCHAR(255),
+             username VARCHAR(50),
+             recovery_email VARCHAR(255),
+             display_name VARCHAR(100),
+             avatar_url VARCHAR(2048),
+             date_of_birth DATE
+         ) ON COMMIT DROP`,
+    )
+    if err != nil {
+        return fmt.Errorf("create user map: %w", err)
+    }
+
+    _, err = tx.CopyFrom(ctx,
+        pgx.Identifier{"anonymized_users"},
+        []string{"id", "email", "username", "recovery_email", "display_name", "avatar_url", "date_of_birth"},
+        pgx.CopyFromRows(rows),
+    )
+    if err != nil {
+        return fmt.Errorf("copy user map: %w", err)
+    }
+
+    _, err = tx.Exec(ctx,
+        `UPDATE users u SET
+             email = a.email, username = a.username, recovery_email = a.recovery_email,
+             display_name = a.display_name, avatar_url = a.avatar_url, date_of_birth = a.date_of_birth,
+             password_hash = $1, reset_token = NULL, reset_expiry = NULL,
+             totp_secret = NULL, totp_pending_secret = NULL, totp_enabled_at = NULL, totp_last_step = NULL,
+             travel_mode_until = NULL, travel_mode_user_agent = NULL
+         FROM anonymized_users a
+         WHERE u.id = a.id`,
+        opts.PasswordHash,
+    )
+    if err != nil {
+        return fmt.Errorf("rewrite users: %w", err)
+    }
+
+    _, err = tx.Exec(ctx,
+        `UPDATE account_deletions d SET username = a.username
+         FROM anonymized_users a
+         WHERE d.user_id = a.id`,
+    )
+    if err != nil {
+        return fmt.Errorf("rewrite account deletions: %w", err)
+    }
+    return nil
+}
+
+// uniqueFake fakes value, retrying until it gets one not in taken, and
+// records the result. Comparison ignores case since lookups do.
+func uniqueFake(taken map[string]bool, value string, fake func(string, int) string) string {
+    for attempt := 0; attempt < maxFakeAttempts; attempt++ {
+        v := fake(value, attempt)
+        if !taken[strings.ToLower(v)] {
+            taken[strings.ToLower(v)] = true
+            return v
+        }
+    }
+
+    base := fake(value, 0)
+    for n := 1; ; n++ {
+        v := numbered(base, n)
+        if !taken[strings.ToLower(v)] {
+            taken[strings.ToLower(v)] = true
+            return v
+        }
+    }
+}
+
+// numbered appends n to the local part of an email, or to a username.
+func numbered(value string, n int) string {
+    suffix := strconv.Itoa(n)
+    if at := strings.LastIndex(value, "@"); at >= 0 {
+        return value[:at] + suffix + value[at:]
+    }
+    return value + suffix
+}
+
+// rewriteIPs fakes every client IP recorded in sessions, token lineage, login
+// failures and the admin audit log, mapping each distinct address once so they stay
+// consistent across tables.
+func rewriteIPs(ctx context.Context, tx pgx.Tx, faker *Faker) (int, error) {
+    rows, err := tx.Query(ctx,
+        `SELECT ip FROM sessions WHERE ip IS NOT NULL
+         UNION SELECT ip FROM refresh_token_lineage WHERE ip IS NOT NULL
+         UNION SELECT reused_ip FROM refresh_token_lineage WHERE reused_ip IS NOT NULL
+         UNION SELECT ip FROM login_failures WHERE ip IS NOT NULL
+         UNION SELECT ip FROM admin_audit_log WHERE ip IS NOT NULL`,
+    )
+    if err != nil {
+        return 0, fmt.Errorf("load ips: %w", err)
+    }
+    pairs := [][]interface{}{}
+    for rows.Next() {
+        var ip string
+        if err := rows.Scan(&ip); err != nil {
+            rows.Close()
+            return 0, fmt.Errorf("scan ip: %w", err)
+        }
+        pairs = append(pairs, []interface{}{ip, faker.IP(ip)})
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil {
+        return 0, fmt.Errorf("load ips: %w", err)
+    }
+
+    if _, err := tx.Exec(ctx, `CREATE TEMP TABLE anonymized_ips (old VARCHAR(45) PRIMARY KEY, new VARCHAR(45)) ON COMMIT DROP`); err != nil {
+        return 0, fmt.Errorf("create ip map: %w", err)
+    }
+    if _, err := tx.CopyFrom(ctx, pgx.Identifier{"anonymized_ips"}, []string{"old", "new"}, pgx.CopyFromRows(pairs)); err != nil {
+        return 0, fmt.Errorf("copy ip map: %w", err)
+    }
+
+    // The audit log is append-only; the guard is lifted for this
+    // transaction only. Its before/after snapshots can hold emails and
+    // usernames, so they are dropped rather than rewritten.
+    statements := []string{
+        `UPDATE sessions s SET ip = m.new FROM anonymized_ips m WHERE s.ip = m.old`,
+        `UPDATE refresh_token_lineage l SET ip = m.new FROM anonymized_ips m WHERE l.ip = m.old`,
+        `UPDATE refresh_token_lineage l SET reused_ip = m.new FROM anonymized_ips m WHERE l.reused_ip = m.old`,
+        `UPDATE login_failures f SET ip = m.new FROM anonymized_ips m WHERE f.ip = m.old`,
+        `ALTER TABLE admin_audit_log DISABLE TRIGGER admin_audit_log_no_update_delete`,
+        `UPDATE admin_audit_log a SET ip = m.new FROM anonymized_ips m WHERE a.ip = m.old`,
+        `UPDATE admin_audit_log SET before_state = NULL, after_state = NULL`,
+        `ALTER TABLE admin_audit_log ENABLE TRIGGER admin_audit_log_no_update_delete`,
+    }
+    for _, stmt := range statements {
+        if _, err := tx.Exec(ctx, stmt); err != nil {
+            return 0, fmt.Errorf("rewrite ips: %w", err)
+        }
+    }
+    return len(pairs), nil
+}
+
+// rewriteRecoveryRequests fakes the contact addresses left on recovery
+// requests and drops the free-text details users wrote about themselves.
+func rewriteRecoveryRequests(ctx context.Context, tx pgx.Tx, faker *Faker) error {
+    rows, err := tx.Query(ctx, `SELECT id, contact_email FROM recovery_requests WHERE contact_email IS NOT NULL`)
+    if err != nil {
+        return fmt.Errorf("load recovery requests: %w", err)
+    }
+    batch := &pgx.Batch{}
+    for rows.Next() {
+        var id uuid.UUID
+        var email string
+        if err := rows.Scan(&id, &email); err != nil {
+            rows.Close()
+            return fmt.Errorf("scan recovery request: %w", err)
+        }
+        batch.Queue(`UPDATE recovery_requests SET contact_email = $2 WHERE id = $1`, id, faker.Email(email, 0))
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil {
+        return fmt.Errorf("load recovery requests: %w", err)
+    }
+
+    if batch.Len() > 0 {
+        if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+            return fmt.Errorf("rewrite recovery requests: %w", err)
+        }
+    }
+
+    if _, err := tx.Exec(ctx, `UPDATE recovery_requests SET details = NULL`); err != nil {
+        return fmt.Errorf("rewrite recovery requests: %w", err)
+    }
+    return nil
+}
@@ -0,0 +1,78 @@
+package anonymize
+
+import (
+	"context"
+	"testing"
+
+	"auth-service/test"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRewrite(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	ctx := context.Background()
+	pool := suite.DB.DB.Pool()
+
+	var userID uuid.UUID
+	err := pool.QueryRow(ctx,
+		`INSERT INTO users (email, username, password_hash, recovery_email, display_name)
+		 VALUES ('jane@example.com', 'jane_doe', 'hash', 'jane.backup@example.com', 'Jane Doe')
+		 RETURNING id`,
+	).Scan(&userID)
+	require.NoError(t, err)
+
+	_, err = pool.Exec(ctx,
+		`INSERT INTO sessions (user_id, refresh_token, ip, expires_at) VALUES ($1, 'h', '203.0.113.7', NOW() + INTERVAL '1 hour')`,
+		userID,
+	)
+	require.NoError(t, err)
+
+	_, err = pool.Exec(ctx,
+		`INSERT INTO impersonation_reviews (user_id, field, content, protected_handle)
+		 VALUES ($1, 'display_name', 'Jane Doe', 'tapin')`,
+		userID,
+	)
+	require.NoError(t, err)
+
+	_, err = pool.Exec(ctx,
+		`INSERT INTO account_notes (user_id, flag, body) VALUES ($1, 'vip', 'Jane is the CEO''s sister')`,
+		userID,
+	)
+	require.NoError(t, err)
+
+	faker := NewFaker([]byte("seed"))
+	report, err := Rewrite(ctx, suite.DB.DB, faker, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Users)
+	assert.Equal(t, 1, report.IPs)
+
+	var email, username, passwordHash, recoveryEmail, displayName string
+	err = pool.QueryRow(ctx,
+		`SELECT email, username, password_hash, recovery_email, display_name FROM users WHERE id = $1`,
+		userID,
+	).Scan(&email, &username, &passwordHash, &recoveryEmail, &displayName)
+	require.NoError(t, err)
+	assert.Equal(t, faker.Email("jane@example.com", 0), email)
+	assert.Equal(t, faker.Username("jane_doe", 0), username)
+	assert.Equal(t, faker.Email("jane.backup@example.com", 0), recoveryEmail)
+	assert.Equal(t, faker.Text("Jane Doe"), displayName)
+	assert.Empty(t, passwordHash)
+
+	var ip string
+	require.NoError(t, pool.QueryRow(ctx, `SELECT ip FROM sessions WHERE user_id = $1`, userID).Scan(&ip))
+	assert.Equal(t, faker.IP("203.0.113.7"), ip)
+
+	var reviews int
+	require.NoError(t, pool.QueryRow(ctx, `SELECT COUNT(*) FROM impersonation_reviews`).Scan(&reviews))
+	assert.Zero(t, reviews)
+
+	var flag, body string
+	require.NoError(t, pool.QueryRow(ctx, `SELECT flag, body FROM account_notes WHERE user_id = $1`, userID).Scan(&flag, &body))
+	assert.Equal(t, "vip", flag)
+	assert.Empty(t, body)
+}
@@ -0,0 +1,119 @@
+// Package apperr defines the errors services return for conditions a client
+// can act on. Each carries a code, which maps to an HTTP status, and a
+// message safe to show the client; handlers turn them into responses in one
+// place instead of comparing against every sentinel they might see.
+package apperr
+
+import (
+    "errors"
+    "net/http"
+    "time"
+)
+
+// Code classifies an error. It is sent to clients as the "code" detail and
+// decides the response status.
+type Code string
+
+const (
+    Invalid         Code = "INVALID"
+    Unauthenticated Code = "UNAUTHENTICATED"
+    Forbidden       Code = "FORBIDDEN"
+    NotFound        Code = "NOT_FOUND"
+    Conflict        Code = "CONFLICT"
+    RateLimited     Code = "RATE_LIMITED"
+    Unavailable     Code = "UNAVAILABLE"
+    Internal        Code = "INTERNAL"
+)
+
+// statuses maps each code to its HTTP status. Codes missing here answer 500.
+var statuses = map[Code]int{
+    Invalid:         http.StatusBadRequest,
+    Unauthenticated: http.StatusUnauthorized,
+    Forbidden:       http.StatusForbidden,
+    NotFound:        http.StatusNotFound,
+    Conflict:        http.StatusConflict,
+    RateLimited:     http.StatusTooManyRequests,
+    Unavailable:     http.StatusServiceUnavailable,
+    Internal:        http.StatusInternalServerError,
+}
+
+// Error is an error with a code and a client-facing message. Sentinels are
+// declared with New and a nil cause; errors.Is matches any *Error with the
+// same code and message, so one built around a cause still matches its
+// sentinel.
+type Error struct {
+    Code    Code
+    Message string
+    Cause   error
+    // Details are sent to the client next to the message
+    Details map[string]interface{}
+    // RetryAfter, when set, is sent as the Retry-After header
+    RetryAfter time.Duration
+}
+
+func New(code Code, message string, cause error) *Error {
+    return &Error{Code: code, Message: message, Cause: cause}
+}
+
+func (e *Error) Error() string {
+    if e.Cause != nil {
+        return e.Message + ": " + e.Cause.Error()
+    }
+    return e.Message
+}
+
+func (e *Error) Unwrap() error {
+    return e.Cause
+}
+
+func (e *Error) Is(target error) bool {
+    t, ok := target.(*Error)
+    return ok && t.Code == e.Code && t.Message == e.Message
+}
+
+// Status returns the HTTP status for the error's code.
+func (e *Error) Status() int {
+    if status, ok := statuses[e.Code]; ok {
+        return status
+    }
+    return http.StatusInternalServerError
+}
+
+// Wrap returns a copy of e caused by cause, which still matches e.
+func (e *Error) Wrap(cause error) *Error {
+    wrapped := *e
+    wrapped.Cause = cause
+    return &wrapped
+}
+
+// WithDetails returns a copy of e sending details to the client.
+func (e *Error) WithDetails(details map[string]interface{}) *Error {
+    detailed := *e
+    detailed.Details = details
+    return &detailed
+}
+
+// WithRetryAfter returns a copy of e telling the client to retry after d.
+func (e *Error) WithRetryAfter(d time.Duration) *Error {
+    retry := *e
+    retry.RetryAfter = d
+    return &retry
+}
+
+// As returns the first *Error in err's chain.
+func As(err error) (*Error, bool) {
+    var appErr *Error
+    if errors.As(err, &appErr) {
+        return appErr, true
+    }
+    return nil, false
+}
+
+// StatusOf returns the HTTP status for err: that of the first *Error in its
+// chain, or 500.
+func StatusOf(err error) int {
+    if appErr, ok := As(err); ok {
+        return appErr.Status()
+    }
+    return http.StatusInternalServerError
+}
@@ -0,0 +1,54 @@
+package apperr
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var errMissing = New(NotFound, "Thing not found", nil)
+
+func TestError_MatchesSentinel(t *testing.T) {
+	cause := errors.New("no rows")
+	wrapped := fmt.Errorf("get thing: %w", errMissing.Wrap(cause))
+
+	assert.ErrorIs(t, wrapped, errMissing)
+	assert.ErrorIs(t, wrapped, cause)
+	assert.ErrorIs(t, errMissing.WithRetryAfter(time.Second), errMissing)
+	assert.NotErrorIs(t, New(NotFound, "Other not found", nil), errMissing)
+	assert.NotErrorIs(t, New(Conflict, "Thing not found", nil), errMissing)
+
+	assert.Equal(t, "Thing not found: no rows", errMissing.Wrap(cause).Error())
+	assert.Nil(t, errMissing.Cause, "Wrap must not change the sentinel")
+}
+
+func TestError_OverridesMessage(t *testing.T) {
+	err := New(Unauthenticated, "Invalid password", errMissing)
+
+	appErr, ok := As(err)
+	assert.True(t, ok)
+	assert.Equal(t, "Invalid password", appErr.Message)
+	assert.ErrorIs(t, err, errMissing)
+}
+
+func TestStatusOf(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{New(Invalid, "bad", nil), http.StatusBadRequest},
+		{New(Unauthenticated, "who", nil), http.StatusUnauthorized},
+		{fmt.Errorf("wrapped: %w", errMissing), http.StatusNotFound},
+		{New(RateLimited, "slow down", nil), http.StatusTooManyRequests},
+		{New(Code("UNKNOWN"), "odd", nil), http.StatusInternalServerError},
+		{errors.New("plain"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, StatusOf(tt.err), "%v", tt.err)
+	}
+}
@@ -0,0 +1,83 @@
+// Package chaos carries the faults a request should run into down to its
+// Redis and Postgres calls, for resilience testing.
+package chaos
+
+import (
+    "context"
+    "math/rand"
+    "time"
+
+    "auth-service/internal/metrics"
+)
+
+// Fault describes how the dependency calls of one request misbehave. Rates
+// are the chance of each call failing, between 0 and 1.
+type Fault struct {
+    DropRedis float64
+    DropDB    float64
+    SlowRedis time.Duration
+    SlowDB    time.Duration
+}
+
+type faultKey struct{}
+
+// WithFault returns ctx carrying fault.
+func WithFault(ctx context.Context, fault *Fault) context.Context {
+    return context.WithValue(ctx, faultKey{}, fault)
+}
+
+// FromContext returns the fault carried by ctx, or nil.
+func FromContext(ctx context.Context) *Fault {
+    fault, _ := ctx.Value(faultKey{}).(*Fault)
+    return fault
+}
+
+// Redis delays a Redis call made with ctx as its fault asks, and reports
+// whether the call should fail.
+func Redis(ctx context.Context) bool {
+    fault := FromContext(ctx)
+    if fault == nil {
+        return false
+    }
+    return inject(ctx, "redis", fault.SlowRedis, fault.DropRedis)
+}
+
+// DB delays a Postgres call made with ctx as its fault asks, and reports
+// whether the call should fail.
+func DB(ctx context.Context) bool {
+    fault := FromContext(ctx)
+    if fault == nil {
+        return false
+    }
+    return inject(ctx, "db", fault.SlowDB, fault.DropDB)
+}
+
+func inject(ctx context.Context, dependency string, delay time.Duration, rate float64) bool {
+    if delay > 0 && !Sleep(ctx, delay) {
+        return false
+    }
+    if Roll(rate) {
+        metrics.FaultsInjected.WithLabelValues(dependency).Inc()
+        return true
+    }
+    return false
+}
+
+// Roll reports true with the chance rate.
+func Roll(rate float64) bool {
+    return rate > 0 && rand.Float64() < rate
+}
+
+// Sleep waits for d, or until ctx is done. It reports whether it waited the
+// whole time.
+func Sleep(ctx context.Context, d time.Duration) bool {
+    timer := time.NewTimer(d)
+    defer timer.Stop()
+
+    select {
+    case <-timer.C:
+        return true
+    case <-ctx.Done():
+        return false
+    }
+}
@@ -0,0 +1,34 @@
+package chaos
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFaults(t *testing.T) {
+	ctx := context.Background()
+
+	// Calls outside a faulty request are left alone
+	assert.False(t, Redis(ctx))
+	assert.False(t, DB(ctx))
+
+	ctx = WithFault(ctx, &Fault{DropRedis: 1, SlowDB: 20 * time.Millisecond})
+	assert.True(t, Redis(ctx))
+
+	start := time.Now()
+	assert.False(t, DB(ctx))
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+
+	// A cancelled request isn't kept waiting
+	cancelled, cancel := context.WithCancel(WithFault(context.Background(), &Fault{SlowDB: time.Hour}))
+	cancel()
+	assert.False(t, DB(cancelled))
+}
+
+func TestRoll(t *testing.T) {
+	assert.False(t, Roll(0))
+	assert.True(t, Roll(1))
+}
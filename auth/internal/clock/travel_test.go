@@ -0,0 +1,29 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTravel(t *testing.T) {
+	system := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	travel := NewTravel()
+	travel.now = func() time.Time { return system }
+
+	assert.Equal(t, system, travel.Now())
+	assert.Zero(t, travel.Offset())
+
+	assert.Equal(t, system.Add(time.Hour), travel.Advance(time.Hour))
+	assert.Equal(t, system.Add(25*time.Hour), travel.Advance(24*time.Hour))
+	assert.Equal(t, 25*time.Hour, travel.Offset())
+
+	// The offset holds as the system clock moves on
+	system = system.Add(time.Minute)
+	assert.Equal(t, system.Add(25*time.Hour), travel.Now())
+
+	travel.Reset()
+	assert.Equal(t, system, travel.Now())
+	assert.Zero(t, travel.Offset())
+}
@@ -0,0 +1,129 @@
+package config
+
+import (
+    "fmt"
+    "net/url"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Ops alerts posted to the security team. Each is high severity on its own
+// or past a threshold.
+const (
+    // Content resembling an admin's or other protected handle was queued
+    // for impersonation review
+    AlertImpersonation = "impersonation"
+    // Failed logins across all accounts reached a threshold within a window
+    AlertFailedLoginSpike = "failed_login_spike"
+    // Logins from devices their accounts never used before reached a
+    // threshold within a window, e.g. after a credential stuffing run
+    AlertNewDeviceLoginSpike = "new_device_login_spike"
+)
+
+// Alert target kinds. Slack targets are incoming webhook URLs and get the
+// rendered message as text; webhook targets get the whole alert as JSON.
+const (
+    AlertTargetSlack   = "slack"
+    AlertTargetWebhook = "webhook"
+)
+
+// AlertingConfig selects which ops alerts are sent where. Alerting is off
+// without targets.
+type AlertingConfig struct {
+    Targets    []AlertTarget
+    Alerts     []string
+    Thresholds map[string]AlertThreshold
+    // Minimum time between two alerts of the same kind; alerts in between
+    // are counted and reported with the next one
+    Cooldown   time.Duration
+    // text/template for the message, executed with the alert
+    Template   string
+}
+
+type AlertTarget struct {
+    Kind string
+    URL  string
+}
+
+// AlertThreshold raises a spike alert once Count events happen within
+// Window.
+type AlertThreshold struct {
+    Count  int64
+    Window time.Duration
+}
+
+var alertNames = []string{AlertImpersonation, AlertFailedLoginSpike, AlertNewDeviceLoginSpike}
+
+// parseAlertTargets parses a comma-separated list of kind=url targets, e.g.
+// "slack=https://hooks.slack.com/services/T/B/X,webhook=https://ops.example/alerts".
+func parseAlertTargets(raw string) ([]AlertTarget, error) {
+    var targets []AlertTarget
+    for _, entry := range strings.Split(raw, ",") {
+        entry = strings.TrimSpace(entry)
+        if entry == "" {
+            continue
+        }
+
+        kind, target, ok := strings.Cut(entry, "=")
+        kind = strings.ToLower(strings.TrimSpace(kind))
+        if !ok || (kind != AlertTargetSlack && kind != AlertTargetWebhook) {
+            return nil, fmt.Errorf("invalid ops_alert_targets entry %q (expected slack=URL or webhook=URL)", entry)
+        }
+        parsed, err := url.Parse(strings.TrimSpace(target))
+        if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+            return nil, fmt.Errorf("invalid ops_alert_targets URL in %q", entry)
+        }
+        targets = append(targets, AlertTarget{Kind: kind, URL: parsed.String()})
+    }
+    return targets, nil
+}
+
+// parseAlerts checks a list of alert names; an empty list enables them all.
+func parseAlerts(names []string) ([]string, error) {
+    if len(names) == 0 {
+        return alertNames, nil
+    }
+    known := make(map[string]bool, len(alertNames))
+    for _, name := range alertNames {
+        known[name] = true
+    }
+    for _, name := range names {
+        if !known[name] {
+            return nil, fmt.Errorf("unknown ops alert %q (expected one of %s)", name, strings.Join(alertNames, ", "))
+        }
+    }
+    return names, nil
+}
+
+// parseAlertThresholds parses spike thresholds as name=count/window, e.g.
+// "failed_login_spike=200/5m". Missing entries keep their defaults.
+func parseAlertThresholds(raw string) (map[string]AlertThreshold, error) {
+    thresholds := map[string]AlertThreshold{
+        AlertFailedLoginSpike:    {Count: 200, Window: 5 * time.Minute},
+        AlertNewDeviceLoginSpike: {Count: 100, Window: 5 * time.Minute},
+    }
+    for _, entry := range strings.Split(raw, ",") {
+        entry = strings.TrimSpace(entry)
+        if entry == "" {
+            continue
+        }
+
+        name, spec, ok := strings.Cut(entry, "=")
+        name = strings.TrimSpace(name)
+        if _, known := thresholds[name]; !ok || !known {
+            return nil, fmt.Errorf("invalid ops_alert_thresholds entry %q", entry)
+        }
+        rawCount, rawWindow, ok := strings.Cut(strings.TrimSpace(spec), "/")
+        count, err := strconv.ParseInt(rawCount, 10, 64)
+        if !ok || err != nil || count <= 0 {
+            return nil, fmt.Errorf("invalid ops_alert_thresholds count in %q", entry)
+        }
+        window, err := time.ParseDuration(rawWindow)
+        if err != nil || window < time.Second {
+            return nil, fmt.Errorf("invalid ops_alert_thresholds window in %q", entry)
+        }
+        thresholds[name] = AlertThreshold{Count: count, Window: window}
+    }
+    return thresholds, nil
+}
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAlertTargets(t *testing.T) {
+	targets, err := parseAlertTargets("")
+	require.NoError(t, err)
+	assert.Empty(t, targets)
+
+	targets, err = parseAlertTargets("Slack=https://hooks.slack.com/services/T/B/X, webhook=http://ops.internal/alerts")
+	require.NoError(t, err)
+	assert.Equal(t, []AlertTarget{
+		{Kind: AlertTargetSlack, URL: "https://hooks.slack.com/services/T/B/X"},
+		{Kind: AlertTargetWebhook, URL: "http://ops.internal/alerts"},
+	}, targets)
+
+	for _, raw := range []string{"pagerduty=https://example.com", "slack=ftp://example.com", "slack", "webhook=/alerts"} {
+		_, err := parseAlertTargets(raw)
+		assert.Error(t, err, raw)
+	}
+}
+
+func TestParseAlerts(t *testing.T) {
+	alerts, err := parseAlerts(nil)
+	require.NoError(t, err)
+	assert.Equal(t, alertNames, alerts)
+
+	alerts, err = parseAlerts([]string{AlertImpersonation})
+	require.NoError(t, err)
+	assert.Equal(t, []string{AlertImpersonation}, alerts)
+
+	_, err = parseAlerts([]string{"admin_login"})
+	assert.Error(t, err)
+}
+
+func TestParseAlertThresholds(t *testing.T) {
+	thresholds, err := parseAlertThresholds("failed_login_spike=50/1m")
+	require.NoError(t, err)
+	assert.Equal(t, AlertThreshold{Count: 50, Window: time.Minute}, thresholds[AlertFailedLoginSpike])
+	assert.Equal(t, AlertThreshold{Count: 100, Window: 5 * time.Minute}, thresholds[AlertNewDeviceLoginSpike])
+
+	for _, raw := range []string{"impersonation=1/1m", "failed_login_spike=0/1m", "failed_login_spike=10", "failed_login_spike=10/10ms"} {
+		_, err := parseAlertThresholds(raw)
+		assert.Error(t, err, raw)
+	}
+}
@@ -0,0 +1,96 @@
+package config
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// ChaosRouteAll is the rule applying to routes without one of their own.
+const ChaosRouteAll = "*"
+
+// ChaosRule injects faults into requests to one route, for resilience
+// testing.
+type ChaosRule struct {
+    // Method and route as registered, e.g. "POST /api/v1/auth/login"
+    Route     string
+    // Added before the request is handled
+    Latency   time.Duration
+    // Chance of answering 503 instead of handling the request
+    ErrorRate float64
+    // Chances of each Redis or Postgres call made for the request failing,
+    // and delays added to each one
+    DropRedis float64
+    DropDB    float64
+    SlowRedis time.Duration
+    SlowDB    time.Duration
+}
+
+// parseChaosRules parses a semicolon-separated list of route=faults rules,
+// where faults are comma-separated name:value pairs, e.g.
+// "POST /api/v1/auth/login=latency:200ms,redis:0.5;*=db:0.01". Faults are
+// latency, error, redis, db, redis_latency and db_latency.
+func parseChaosRules(raw string) (map[string]ChaosRule, error) {
+    rules := map[string]ChaosRule{}
+    for _, entry := range strings.Split(raw, ";") {
+        entry = strings.TrimSpace(entry)
+        if entry == "" {
+            continue
+        }
+
+        route, spec, ok := strings.Cut(entry, "=")
+        route = strings.Join(strings.Fields(route), " ")
+        if !ok || route == "" {
+            return nil, fmt.Errorf("invalid chaos_rules entry %q", entry)
+        }
+
+        rule := ChaosRule{Route: route}
+        for _, fault := range strings.Split(spec, ",") {
+            name, value, ok := strings.Cut(strings.TrimSpace(fault), ":")
+            if !ok {
+                return nil, fmt.Errorf("invalid chaos_rules fault %q for %s", fault, route)
+            }
+            value = strings.TrimSpace(value)
+
+            var err error
+            switch strings.TrimSpace(name) {
+            case "latency":
+                rule.Latency, err = parseChaosDelay(value)
+            case "redis_latency":
+                rule.SlowRedis, err = parseChaosDelay(value)
+            case "db_latency":
+                rule.SlowDB, err = parseChaosDelay(value)
+            case "error":
+                rule.ErrorRate, err = parseChaosRate(value)
+            case "redis":
+                rule.DropRedis, err = parseChaosRate(value)
+            case "db":
+                rule.DropDB, err = parseChaosRate(value)
+            default:
+                err = fmt.Errorf("unknown fault")
+            }
+            if err != nil {
+                return nil, fmt.Errorf("invalid chaos_rules fault %q for %s", fault, route)
+            }
+        }
+        rules[route] = rule
+    }
+    return rules, nil
+}
+
+func parseChaosDelay(value string) (time.Duration, error) {
+    d, err := time.ParseDuration(value)
+    if err != nil || d < 0 {
+        return 0, fmt.Errorf("invalid delay %q", value)
+    }
+    return d, nil
+}
+
+func parseChaosRate(value string) (float64, error) {
+    rate, err := strconv.ParseFloat(value, 64)
+    if err != nil || rate < 0 || rate > 1 {
+        return 0, fmt.Errorf("invalid rate %q", value)
+    }
+    return rate, nil
+}
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseChaosRules(t *testing.T) {
+	rules, err := parseChaosRules("")
+	require.NoError(t, err)
+	assert.Empty(t, rules)
+
+	rules, err = parseChaosRules("POST  /api/v1/auth/login=latency:200ms, error:0.1, redis:1;*=db:0.05,db_latency:2s,redis_latency:50ms")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]ChaosRule{
+		"POST /api/v1/auth/login": {
+			Route:     "POST /api/v1/auth/login",
+			Latency:   200 * time.Millisecond,
+			ErrorRate: 0.1,
+			DropRedis: 1,
+		},
+		ChaosRouteAll: {
+			Route:     ChaosRouteAll,
+			DropDB:    0.05,
+			SlowDB:    2 * time.Second,
+			SlowRedis: 50 * time.Millisecond,
+		},
+	}, rules)
+
+	for _, raw := range []string{"*=", "=error:0.5", "*=error:1.5", "*=redis:-0.1", "*=latency:-1s", "*=latency:soon", "*=cpu:0.5", "*=error"} {
+		_, err := parseChaosRules(raw)
+		assert.Error(t, err, raw)
+	}
+}
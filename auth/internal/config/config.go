@@ -1,26 +1,107 @@
 package config
 
 import (
+    "fmt"
+    "strings"
     "time"
+
+    "auth-service/internal/validation"
+
+    "github.com/google/uuid"
     "github.com/spf13/viper"
 )
 
 type Config struct {
-    Port           int
-    Environment    string
-    DatabaseURL    string
-    RedisURL       string
-    RabbitMQURL    string
-    JWTSecret      string
-    JWTExpiry      time.Duration
-    RefreshExpiry  time.Duration
-    AllowedOrigins []string
-    RateLimit      int
-    EmailFrom      string
-    SMTPHost       string
-    SMTPPort       int
-    SMTPUser       string
-    SMTPPass       string
+    Port                    int
+    AdminHost               string
+    AdminPort               int
+    // PEM certificate and key for serving the public port over TLS, re-read
+    // on SIGHUP. Both or neither.
+    TLSCertFile             string
+    TLSKeyFile              string
+    Environment             string
+    Profile                 Profile
+    DatabaseURL             string
+    Region                  string
+    // UUID version of new user and session IDs: 4 (random) or 7
+    // (time-ordered). Existing IDs are never rewritten.
+    IDVersion               int
+    RedisURL                string
+    RedisReplicaURL         string
+    TokenStore              string
+    RabbitMQURL             string
+    // Where user events are published: rabbitmq, or none to only journal
+    // them, e.g. for local development
+    EventBroker             string
+    // JWT signing secret given inline. main moves it into a keyring and
+    // clears it; JWTSecretFile takes precedence.
+    JWTSecret               string
+    // File holding the JWT signing secret, re-read on SIGHUP
+    JWTSecretFile           string
+    JWTExpiry               time.Duration
+    // Status for a valid token whose user no longer exists: 401, 403 or 410
+    GoneUserStatus          int
+    RefreshExpiry           time.Duration
+    TrustedRefreshExpiry    time.Duration
+    // Live sessions per user; logins beyond it close the oldest. 0 for no
+    // limit.
+    MaxSessions             int
+    ViewOnlyGrace           time.Duration
+    RecoveryTokenExpiry     time.Duration
+    SudoTTL                 time.Duration
+    EmailVerificationExpiry time.Duration
+    EmailReverifyMonths     int
+    EmailCodeNewDevice      bool
+    RegistrationEmailCode   bool
+    TOSVersion              string
+    TOTPIssuer              string
+    CaptchaVerifyURL        string
+    CaptchaSecret           string
+    CaptchaAfterFailures    int
+    Moderation              ModerationConfig
+    Alerting                AlertingConfig
+    // Length bounds of usernames, passwords and display names
+    ValidationLimits        validation.Limits
+    AllowedOrigins          []string
+    CORSPolicies            map[string]CORSPolicy
+    SigningKeys             map[string]string
+    ProfileRequiredFields   []string
+    RateLimit               int
+    RateLimitRules          map[string]RateLimitRule
+    V1Sunset                time.Time
+    SchemaValidation        bool
+    SchemaValidateResponses bool
+    LoadShedding            LoadShedding
+    FunnelAggregation       time.Duration
+    DeletionInterval        time.Duration
+    DeletionBatchSize       int
+    // How often the data integrity checks run; 0 turns them off
+    IntegrityCheckInterval  time.Duration
+    IntegrityAutoRepair     bool
+    EventBufferSize         int
+    EventJournalRetention   time.Duration
+    BcryptConcurrency       int
+    BcryptQueueTimeout      time.Duration
+    SessionPolicy           SessionPolicy
+    GeoBlockedCountries     []string
+    GeoIPHeader             string
+    GeoIPDatabase           string
+    EmailFrom               string
+    SMTPHost                string
+    SMTPPort                int
+    SMTPUser                string
+    SMTPPass                string
+    // Store emails in sent_emails instead of sending them
+    EmailSandbox            bool
+    // Run on a clock end-to-end tests can move forward through the admin
+    // listener
+    TestMode                bool
+    // Admins who may read and write restricted account notes and flags
+    RestrictedNotesAdmins   []uuid.UUID
+    DataResidency           DataResidency
+    // Faults injected per route for resilience testing, keyed by method and
+    // route or ChaosRouteAll
+    ChaosRules              map[string]ChaosRule
 }
 
 func Load() (*Config, error) {
@@ -33,10 +114,40 @@ func Load() (*Config, error) {
 
     // Set defaults
     viper.SetDefault("port", 8080)
+    viper.SetDefault("admin_host", "127.0.0.1")
+    viper.SetDefault("admin_port", 9090)
     viper.SetDefault("environment", "development")
     viper.SetDefault("jwt_expiry", "15m")
+    viper.SetDefault("gone_user_status", 401)
+    viper.SetDefault("id_version", 4)
     viper.SetDefault("refresh_expiry", "168h") // 7 days
+    viper.SetDefault("trusted_refresh_expiry", "720h") // 30 days
+    viper.SetDefault("view_only_grace", "72h")
     viper.SetDefault("rate_limit", 60)
+    viper.SetDefault("recovery_token_expiry", "30m")
+    viper.SetDefault("sudo_ttl", "10m")
+    viper.SetDefault("email_verification_expiry", "24h")
+    viper.SetDefault("funnel_aggregation_interval", "1h")
+    viper.SetDefault("deletion_worker_interval", "30s")
+    viper.SetDefault("deletion_batch_size", 10)
+    viper.SetDefault("integrity_check_interval", "1h")
+    viper.SetDefault("event_buffer_size", 1000)
+    viper.SetDefault("event_journal_retention", "2160h") // 90 days
+    viper.SetDefault("totp_issuer", "TapIn")
+    viper.SetDefault("moderation_protected_handles", "tapin,support,moderator")
+    viper.SetDefault("ops_alert_cooldown", "15m")
+    viper.SetDefault("ops_alert_template", "[{{.Severity}}] {{.Title}}: {{.Message}}")
+    viper.SetDefault("captcha_after_failures", 3)
+    viper.SetDefault("smtp_port", 587)
+    viper.SetDefault("bcrypt_queue_timeout", "2s")
+    viper.SetDefault("session_policy", SessionPolicyRelaxed)
+    viper.SetDefault("default_data_region", "global")
+    viper.SetDefault("token_store", "redis")
+    viper.SetDefault("event_broker", "rabbitmq")
+    viper.SetDefault("username_min_length", validation.DefaultLimits.UsernameMin)
+    viper.SetDefault("username_max_length", validation.DefaultLimits.UsernameMax)
+    viper.SetDefault("password_min_length", validation.DefaultLimits.PasswordMin)
+    viper.SetDefault("display_name_max_length", validation.DefaultLimits.DisplayNameMax)
 
     if err := viper.ReadInConfig(); err != nil {
         if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
@@ -44,6 +155,161 @@ func Load() (*Config, error) {
         }
     }
 
+    profile, err := ProfileFor(viper.GetString("environment"))
+    if err != nil {
+        return nil, err
+    }
+    profile, err = profile.applyOverrides(
+        viper.GetString("log_encoding"),
+        viper.GetString("log_level"),
+        viper.GetString("gin_mode"),
+        viper.GetString("request_log"),
+    )
+    if err != nil {
+        return nil, err
+    }
+
+    sessionPolicy, err := parseSessionPolicy(viper.GetString("session_policy"), viper.GetString("session_policy_clients"))
+    if err != nil {
+        return nil, err
+    }
+
+    dataResidency, err := parseDataResidency(viper.GetString("default_data_region"),
+        viper.GetString("data_region_countries"), viper.GetString("data_region_databases"))
+    if err != nil {
+        return nil, err
+    }
+
+    rateLimitRules, err := parseRateLimitRules(viper.GetString("rate_limit_rules"))
+    if err != nil {
+        return nil, err
+    }
+
+    allowedOrigins := viper.GetStringSlice("allowed_origins")
+    corsPolicies, err := parseCORSPolicies(viper.GetString("cors_policies"), allowedOrigins)
+    if err != nil {
+        return nil, err
+    }
+
+    signingKeys, err := parseSigningKeys(viper.GetString("internal_signing_keys"))
+    if err != nil {
+        return nil, err
+    }
+
+    moderationProvider, err := parseModerationProvider(viper.GetString("moderation_provider"),
+        viper.GetString("moderation_url"), viper.GetString("moderation_api_key"))
+    if err != nil {
+        return nil, err
+    }
+
+    loadShedding, err := parseLoadShedding(viper.GetString("load_shedding"))
+    if err != nil {
+        return nil, err
+    }
+
+    alertTargets, err := parseAlertTargets(viper.GetString("ops_alert_targets"))
+    if err != nil {
+        return nil, err
+    }
+    alerts, err := parseAlerts(splitList(viper.GetStringSlice("ops_alerts")))
+    if err != nil {
+        return nil, err
+    }
+    alertThresholds, err := parseAlertThresholds(viper.GetString("ops_alert_thresholds"))
+    if err != nil {
+        return nil, err
+    }
+
+    moderationFlag, moderationDeny, err := parseModerationThresholds(viper.GetString("moderation_thresholds"))
+    if err != nil {
+        return nil, err
+    }
+
+    profileRequiredFields, err := parseProfileRequiredFields(viper.GetString("profile_required_fields"))
+    if err != nil {
+        return nil, err
+    }
+
+    restrictedNotesAdmins, err := parseUserIDs("restricted_notes_admins", splitList(viper.GetStringSlice("restricted_notes_admins")))
+    if err != nil {
+        return nil, err
+    }
+
+    // Backend for blacklisted tokens and single-use nonces
+    tokenStore := viper.GetString("token_store")
+    switch tokenStore {
+    case "redis", "postgres", "memory":
+    default:
+        return nil, fmt.Errorf("token_store must be redis, postgres or memory, got %q", tokenStore)
+    }
+
+    validationLimits := validation.Limits{
+        UsernameMin:    viper.GetInt("username_min_length"),
+        UsernameMax:    viper.GetInt("username_max_length"),
+        PasswordMin:    viper.GetInt("password_min_length"),
+        DisplayNameMax: viper.GetInt("display_name_max_length"),
+    }
+    if err := validationLimits.Check(); err != nil {
+        return nil, err
+    }
+
+    // Without a broker other services never see user events
+    eventBroker := viper.GetString("event_broker")
+    switch eventBroker {
+    case "rabbitmq":
+    case "none":
+        if profile.Name == "production" {
+            return nil, fmt.Errorf("event_broker cannot be none in production")
+        }
+    default:
+        return nil, fmt.Errorf("event_broker must be rabbitmq or none, got %q", eventBroker)
+    }
+
+    // The admin listener must never share the public port, or admin routes
+    // would be reachable through the public ingress
+    if viper.GetInt("admin_port") == viper.GetInt("port") {
+        return nil, fmt.Errorf("admin_port must differ from port (%d)", viper.GetInt("port"))
+    }
+
+    // Sandboxed emails are never delivered, which would lock real users out
+    // of verification and password resets
+    if viper.GetBool("email_sandbox") && profile.Name == "production" {
+        return nil, fmt.Errorf("email_sandbox cannot be enabled in production")
+    }
+
+    // A travelling clock lets anyone with admin access extend tokens and
+    // sessions past their expiry
+    if viper.GetBool("test_mode") && profile.Name == "production" {
+        return nil, fmt.Errorf("test_mode cannot be enabled in production")
+    }
+
+    chaosRules, err := parseChaosRules(viper.GetString("chaos_rules"))
+    if err != nil {
+        return nil, err
+    }
+    if len(chaosRules) > 0 && profile.Name == "production" {
+        return nil, fmt.Errorf("chaos_rules cannot be set in production")
+    }
+
+    if (viper.GetString("tls_cert_file") == "") != (viper.GetString("tls_key_file") == "") {
+        return nil, fmt.Errorf("tls_cert_file and tls_key_file must be set together")
+    }
+
+    if viper.GetString("jwt_secret") == "" && viper.GetString("jwt_secret_file") == "" {
+        return nil, fmt.Errorf("jwt_secret or jwt_secret_file must be set")
+    }
+
+    if idVersion := viper.GetInt("id_version"); idVersion != 4 && idVersion != 7 {
+        return nil, fmt.Errorf("id_version must be 4 or 7, got %d", idVersion)
+    }
+
+    goneUserStatus := viper.GetInt("gone_user_status")
+    switch goneUserStatus {
+    case 401, 403, 410:
+    default:
+        return nil, fmt.Errorf("gone_user_status must be 401, 403 or 410, got %d", goneUserStatus)
+    }
+
     jwtExpiry, err := time.ParseDuration(viper.GetString("jwt_expiry"))
     if err != nil {
         jwtExpiry = 15 * time.Minute
@@ -54,21 +320,196 @@ func Load() (*Config, error) {
         refreshExpiry = 168 * time.Hour
     }
 
+    trustedRefreshExpiry, err := time.ParseDuration(viper.GetString("trusted_refresh_expiry"))
+    if err != nil {
+        trustedRefreshExpiry = 720 * time.Hour
+    }
+
+    maxSessions := viper.GetInt("max_sessions")
+    if maxSessions < 0 {
+        maxSessions = 0
+    }
+
+    // A view_only_grace of 0 disables view-only tokens
+    viewOnlyGrace, err := time.ParseDuration(viper.GetString("view_only_grace"))
+    if err != nil || viewOnlyGrace < 0 {
+        viewOnlyGrace = 72 * time.Hour
+    }
+
+    recoveryTokenExpiry, err := time.ParseDuration(viper.GetString("recovery_token_expiry"))
+    if err != nil {
+        recoveryTokenExpiry = 30 * time.Minute
+    }
+
+    sudoTTL, err := time.ParseDuration(viper.GetString("sudo_ttl"))
+    if err != nil || sudoTTL <= 0 {
+        sudoTTL = 10 * time.Minute
+    }
+
+    emailVerificationExpiry, err := time.ParseDuration(viper.GetString("email_verification_expiry"))
+    if err != nil {
+        emailVerificationExpiry = 24 * time.Hour
+    }
+
+    funnelAggregation, err := time.ParseDuration(viper.GetString("funnel_aggregation_interval"))
+    if err != nil || funnelAggregation <= 0 {
+        funnelAggregation = time.Hour
+    }
+
+    deletionInterval, err := time.ParseDuration(viper.GetString("deletion_worker_interval"))
+    if err != nil || deletionInterval <= 0 {
+        deletionInterval = 30 * time.Second
+    }
+
+    integrityCheckInterval, err := time.ParseDuration(viper.GetString("integrity_check_interval"))
+    if err != nil || integrityCheckInterval < 0 {
+        integrityCheckInterval = time.Hour
+    }
+
+    deletionBatchSize := viper.GetInt("deletion_batch_size")
+    if deletionBatchSize <= 0 {
+        deletionBatchSize = 10
+    }
+
+    eventBufferSize := viper.GetInt("event_buffer_size")
+    if eventBufferSize <= 0 {
+        eventBufferSize = 1000
+    }
+
+    eventJournalRetention, err := time.ParseDuration(viper.GetString("event_journal_retention"))
+    if err != nil || eventJournalRetention <= 0 {
+        eventJournalRetention = 2160 * time.Hour
+    }
+
+    // bcrypt_concurrency of 0 sizes the bcrypt pool to the CPU count
+    bcryptQueueTimeout, err := time.ParseDuration(viper.GetString("bcrypt_queue_timeout"))
+    if err != nil || bcryptQueueTimeout <= 0 {
+        bcryptQueueTimeout = 2 * time.Second
+    }
+
+    // Optional date (YYYY-MM-DD) advertised in the Sunset header on /api/v1
+    var v1Sunset time.Time
+    if raw := viper.GetString("api_v1_sunset"); raw != "" {
+        v1Sunset, err = time.Parse("2006-01-02", raw)
+        if err != nil {
+            return nil, fmt.Errorf("parse api_v1_sunset: %w", err)
+        }
+    }
+
     return &Config{
-        Port:           viper.GetInt("port"),
-        Environment:    viper.GetString("environment"),
-        DatabaseURL:    viper.GetString("database_url"),
-        RedisURL:       viper.GetString("redis_url"),
-        RabbitMQURL:    viper.GetString("rabbitmq_url"),
-        JWTSecret:      viper.GetString("jwt_secret"),
-        JWTExpiry:      jwtExpiry,
-        RefreshExpiry:  refreshExpiry,
-        AllowedOrigins: viper.GetStringSlice("allowed_origins"),
-        RateLimit:      viper.GetInt("rate_limit"),
-        EmailFrom:      viper.GetString("email_from"),
-        SMTPHost:       viper.GetString("smtp_host"),
-        SMTPPort:       viper.GetInt("smtp_port"),
-        SMTPUser:       viper.GetString("smtp_user"),
-        SMTPPass:       viper.GetString("smtp_pass"),
+        Port:                    viper.GetInt("port"),
+        AdminHost:               viper.GetString("admin_host"),
+        AdminPort:               viper.GetInt("admin_port"),
+        TLSCertFile:             viper.GetString("tls_cert_file"),
+        TLSKeyFile:              viper.GetString("tls_key_file"),
+        Environment:             viper.GetString("environment"),
+        Profile:                 profile,
+        DatabaseURL:             viper.GetString("database_url"),
+        Region:                  viper.GetString("region"),
+        IDVersion:               viper.GetInt("id_version"),
+        RedisURL:                viper.GetString("redis_url"),
+        RedisReplicaURL:         viper.GetString("redis_replica_url"),
+        TokenStore:              tokenStore,
+        RabbitMQURL:             viper.GetString("rabbitmq_url"),
+        EventBroker:             eventBroker,
+        JWTSecret:               viper.GetString("jwt_secret"),
+        JWTSecretFile:           viper.GetString("jwt_secret_file"),
+        JWTExpiry:               jwtExpiry,
+        GoneUserStatus:          goneUserStatus,
+        RefreshExpiry:           refreshExpiry,
+        TrustedRefreshExpiry:    trustedRefreshExpiry,
+        MaxSessions:             maxSessions,
+        ViewOnlyGrace:           viewOnlyGrace,
+        RecoveryTokenExpiry:     recoveryTokenExpiry,
+        SudoTTL:                 sudoTTL,
+        EmailVerificationExpiry: emailVerificationExpiry,
+        EmailReverifyMonths:     viper.GetInt("email_reverify_months"),
+        EmailCodeNewDevice:      viper.GetBool("email_code_new_device"),
+        RegistrationEmailCode:   viper.GetBool("registration_email_code"),
+        TOSVersion:              viper.GetString("tos_version"),
+        TOTPIssuer:              viper.GetString("totp_issuer"),
+        CaptchaVerifyURL:        viper.GetString("captcha_verify_url"),
+        CaptchaSecret:           viper.GetString("captcha_secret"),
+        CaptchaAfterFailures:    viper.GetInt("captcha_after_failures"),
+        Moderation: ModerationConfig{
+            Provider:         moderationProvider,
+            DenyWords:        splitList(viper.GetStringSlice("moderation_deny_words")),
+            FlagWords:        splitList(viper.GetStringSlice("moderation_flag_words")),
+            URL:              viper.GetString("moderation_url"),
+            APIKey:           viper.GetString("moderation_api_key"),
+            FlagThreshold:    moderationFlag,
+            DenyThreshold:    moderationDeny,
+            ProtectedHandles: splitList(viper.GetStringSlice("moderation_protected_handles")),
+        },
+        Alerting: AlertingConfig{
+            Targets:    alertTargets,
+            Alerts:     alerts,
+            Thresholds: alertThresholds,
+            Cooldown:   viper.GetDuration("ops_alert_cooldown"),
+            Template:   viper.GetString("ops_alert_template"),
+        },
+        ValidationLimits:        validationLimits,
+        AllowedOrigins:          allowedOrigins,
+        CORSPolicies:            corsPolicies,
+        SigningKeys:             signingKeys,
+        ProfileRequiredFields:   profileRequiredFields,
+        RateLimit:               viper.GetInt("rate_limit"),
+        RateLimitRules:          rateLimitRules,
+        V1Sunset:                v1Sunset,
+        SchemaValidation:        viper.GetBool("schema_validation"),
+        // Checking responses costs a copy of every body, so it is never
+        // done in production
+        SchemaValidateResponses: viper.GetBool("schema_validate_responses") && profile.Name != "production",
+        LoadShedding:            loadShedding,
+        FunnelAggregation:       funnelAggregation,
+        DeletionInterval:        deletionInterval,
+        DeletionBatchSize:       deletionBatchSize,
+        IntegrityCheckInterval:  integrityCheckInterval,
+        IntegrityAutoRepair:     viper.GetBool("integrity_auto_repair"),
+        EventBufferSize:         eventBufferSize,
+        EventJournalRetention:   eventJournalRetention,
+        BcryptConcurrency:       viper.GetInt("bcrypt_concurrency"),
+        BcryptQueueTimeout:      bcryptQueueTimeout,
+        SessionPolicy:           sessionPolicy,
+        GeoBlockedCountries:     splitList(viper.GetStringSlice("geo_blocked_countries")),
+        GeoIPHeader:             viper.GetString("geoip_header"),
+        GeoIPDatabase:           viper.GetString("geoip_database"),
+        EmailFrom:               viper.GetString("email_from"),
+        SMTPHost:                viper.GetString("smtp_host"),
+        SMTPPort:                viper.GetInt("smtp_port"),
+        SMTPUser:                viper.GetString("smtp_user"),
+        SMTPPass:                viper.GetString("smtp_pass"),
+        EmailSandbox:            viper.GetBool("email_sandbox"),
+        TestMode:                viper.GetBool("test_mode"),
+        RestrictedNotesAdmins:   restrictedNotesAdmins,
+        DataResidency:           dataResidency,
+        ChaosRules:              chaosRules,
     }, nil
-}
\ No newline at end of file
+}
+
+// parseUserIDs parses the user IDs listed in the named setting.
+func parseUserIDs(name string, values []string) ([]uuid.UUID, error) {
+    ids := make([]uuid.UUID, 0, len(values))
+    for _, value := range values {
+        id, err := uuid.Parse(value)
+        if err != nil {
+            return nil, fmt.Errorf("invalid %s entry %q: %w", name, value, err)
+        }
+        ids = append(ids, id)
+    }
+    return ids, nil
+}
+
+// splitList flattens list settings that may come from YAML lists or from
+// comma-separated environment variables.
+func splitList(values []string) []string {
+    var out []string
+    for _, value := range values {
+        for _, item := range strings.Split(value, ",") {
+            if item = strings.TrimSpace(item); item != "" {
+                out = append(out, item)
+            }
+        }
+    }
+    return out
+}
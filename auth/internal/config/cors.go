@@ -0,0 +1,77 @@
+package config
+
+import (
+    "fmt"
+    "strings"
+)
+
+// CORS policy groups. The public group covers the public listener; the admin
+// and internal groups cover the admin API and the service-to-service routes
+// on the admin listener.
+const (
+    CORSGroupPublic   = "public"
+    CORSGroupAdmin    = "admin"
+    CORSGroupInternal = "internal"
+)
+
+// CORSPolicy says which browser origins may call a group of routes, and with
+// which methods and headers. A policy without origins allows none, so
+// cross-origin requests are refused.
+type CORSPolicy struct {
+    Origins []string
+    Methods []string
+    Headers []string
+}
+
+// DefaultCORSPolicies returns the policies used when cors_policies sets no
+// origins: the public group allows allowedOrigins, the others none.
+func DefaultCORSPolicies(allowedOrigins []string) map[string]CORSPolicy {
+    methods := []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+    return map[string]CORSPolicy{
+        // Clients send X-Client-Type so logins and sessions are attributed
+        // to them, and X-Data-Region to pick a new account's data region
+        CORSGroupPublic: {
+            Origins: allowedOrigins,
+            Methods: methods,
+            Headers: []string{"Content-Type", "Authorization", "Accept-Language", "X-Client-Type", "X-Data-Region"},
+        },
+        CORSGroupAdmin: {
+            Methods: methods,
+            Headers: []string{"Content-Type", "Authorization"},
+        },
+        CORSGroupInternal: {
+            Methods: methods,
+            Headers: []string{"Content-Type", "X-API-Key"},
+        },
+    }
+}
+
+// parseCORSPolicies overrides the allowed origins of the default policies
+// with a semicolon-separated list of group=origins entries, origins being
+// comma-separated, e.g. "admin=https://backoffice.tapin.app;internal=". The
+// public group defaults to allowedOrigins; the others allow no origins.
+func parseCORSPolicies(raw string, allowedOrigins []string) (map[string]CORSPolicy, error) {
+    policies := DefaultCORSPolicies(allowedOrigins)
+    for _, entry := range strings.Split(raw, ";") {
+        entry = strings.TrimSpace(entry)
+        if entry == "" {
+            continue
+        }
+
+        group, origins, ok := strings.Cut(entry, "=")
+        group = strings.TrimSpace(group)
+        policy, known := policies[group]
+        if !ok || !known {
+            return nil, fmt.Errorf("invalid cors_policies entry %q", entry)
+        }
+
+        policy.Origins = nil
+        for _, origin := range strings.Split(origins, ",") {
+            if origin = strings.TrimSpace(origin); origin != "" {
+                policy.Origins = append(policy.Origins, origin)
+            }
+        }
+        policies[group] = policy
+    }
+    return policies, nil
+}
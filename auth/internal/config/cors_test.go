@@ -0,0 +1,28 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCORSPolicies(t *testing.T) {
+	policies, err := parseCORSPolicies("", []string{"http://localhost:3000"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"http://localhost:3000"}, policies[CORSGroupPublic].Origins)
+	assert.Empty(t, policies[CORSGroupAdmin].Origins)
+	assert.Empty(t, policies[CORSGroupInternal].Origins)
+	assert.Contains(t, policies[CORSGroupInternal].Headers, "X-API-Key")
+
+	policies, err = parseCORSPolicies(" admin=https://backoffice.tapin.app, https://ops.tapin.app ; public=", []string{"*"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"https://backoffice.tapin.app", "https://ops.tapin.app"}, policies[CORSGroupAdmin].Origins)
+	assert.Empty(t, policies[CORSGroupPublic].Origins)
+	assert.Contains(t, policies[CORSGroupAdmin].Headers, "Authorization")
+
+	for _, raw := range []string{"admin", "backoffice=https://backoffice.tapin.app", "=https://backoffice.tapin.app"} {
+		_, err := parseCORSPolicies(raw, nil)
+		assert.Error(t, err, raw)
+	}
+}
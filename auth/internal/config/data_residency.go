@@ -0,0 +1,102 @@
+package config
+
+import (
+    "fmt"
+    "regexp"
+    "sort"
+    "strings"
+)
+
+var dataRegionPattern = regexp.MustCompile(`^[a-z][a-z0-9-]{0,15}$`)
+
+// DataResidency decides which data region a new user's data belongs to.
+// A client hint naming a known region wins; otherwise the region is looked
+// up from the client's country, falling back to Default.
+type DataResidency struct {
+    Default string
+    // ISO 3166-1 alpha-2 country code to region
+    Countries map[string]string
+    // Region to the URL of its own database. Regions without one are kept
+    // in DATABASE_URL.
+    Databases map[string]string
+}
+
+// parseDataResidency builds a DataResidency from the default region, a
+// comma-separated list of country=region pairs, e.g. "DE=eu,FR=eu", and a
+// semicolon-separated list of region=database URL pairs.
+func parseDataResidency(defaultRegion, countries, databases string) (DataResidency, error) {
+    residency := DataResidency{
+        Default:   defaultRegion,
+        Countries: map[string]string{},
+        Databases: map[string]string{},
+    }
+    if !dataRegionPattern.MatchString(defaultRegion) {
+        return residency, fmt.Errorf("invalid default_data_region %q", defaultRegion)
+    }
+
+    for _, pair := range strings.Split(countries, ",") {
+        pair = strings.TrimSpace(pair)
+        if pair == "" {
+            continue
+        }
+        country, region, ok := strings.Cut(pair, "=")
+        country = strings.ToUpper(strings.TrimSpace(country))
+        region = strings.TrimSpace(region)
+        if !ok || len(country) != 2 || !dataRegionPattern.MatchString(region) {
+            return residency, fmt.Errorf("invalid data_region_countries entry %q", pair)
+        }
+        residency.Countries[country] = region
+    }
+
+    for _, pair := range strings.Split(databases, ";") {
+        pair = strings.TrimSpace(pair)
+        if pair == "" {
+            continue
+        }
+        region, url, ok := strings.Cut(pair, "=")
+        region = strings.TrimSpace(region)
+        url = strings.TrimSpace(url)
+        if !ok || !dataRegionPattern.MatchString(region) || url == "" {
+            return residency, fmt.Errorf("invalid data_region_databases entry for region %q", region)
+        }
+        residency.Databases[region] = url
+    }
+
+    return residency, nil
+}
+
+// Regions lists every region the configuration mentions, sorted.
+func (d DataResidency) Regions() []string {
+    seen := map[string]bool{d.Default: true}
+    for _, region := range d.Countries {
+        seen[region] = true
+    }
+    for region := range d.Databases {
+        seen[region] = true
+    }
+
+    regions := make([]string, 0, len(seen))
+    for region := range seen {
+        regions = append(regions, region)
+    }
+    sort.Strings(regions)
+    return regions
+}
+
+// Region returns the data region for a client sending hint (possibly "")
+// from country (possibly "" if unknown). Hints naming unknown regions are
+// ignored.
+func (d DataResidency) Region(hint, country string) string {
+    hint = strings.ToLower(strings.TrimSpace(hint))
+    if hint != "" {
+        for _, region := range d.Regions() {
+            if region == hint {
+                return region
+            }
+        }
+    }
+    if region, ok := d.Countries[strings.ToUpper(country)]; ok {
+        return region
+    }
+    return d.Default
+}
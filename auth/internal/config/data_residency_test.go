@@ -0,0 +1,30 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDataResidency(t *testing.T) {
+	residency, err := parseDataResidency("global", "DE=eu, fr=eu,US=us", "eu=postgres://eu-db/auth?sslmode=require")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"eu", "global", "us"}, residency.Regions())
+	assert.Equal(t, "postgres://eu-db/auth?sslmode=require", residency.Databases["eu"])
+
+	assert.Equal(t, "eu", residency.Region("", "FR"))
+	assert.Equal(t, "global", residency.Region("", "JP"))
+	assert.Equal(t, "global", residency.Region("", ""))
+	// A hint for a known region wins over the country
+	assert.Equal(t, "us", residency.Region("US", "DE"))
+	assert.Equal(t, "eu", residency.Region("mars", "DE"))
+
+	_, err = parseDataResidency("", "", "")
+	assert.Error(t, err)
+	_, err = parseDataResidency("global", "Germany=eu", "")
+	assert.Error(t, err)
+	_, err = parseDataResidency("global", "", "eu")
+	assert.Error(t, err)
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// LoadShedding sets when low-priority requests are turned away. A zero
+// threshold is not checked, so the zero value never sheds.
+type LoadShedding struct {
+    // Requests being served at once, across both listeners
+    MaxInFlight int
+    // Smoothed latency of any dependency (Postgres, Redis)
+    MaxLatency time.Duration
+    // Retry-After sent with shed requests
+    RetryAfter time.Duration
+}
+
+// Enabled reports whether any threshold is set.
+func (l LoadShedding) Enabled() bool {
+    return l.MaxInFlight > 0 || l.MaxLatency > 0
+}
+
+// parseLoadShedding reads "inflight=200,latency=250ms,retry_after=5s". Every
+// entry is optional; retry_after defaults to 5s.
+func parseLoadShedding(raw string) (LoadShedding, error) {
+    shedding := LoadShedding{RetryAfter: 5 * time.Second}
+    for _, entry := range strings.Split(raw, ",") {
+        entry = strings.TrimSpace(entry)
+        if entry == "" {
+            continue
+        }
+
+        name, value, ok := strings.Cut(entry, "=")
+        if !ok {
+            return LoadShedding{}, fmt.Errorf("invalid load_shedding entry %q", entry)
+        }
+        value = strings.TrimSpace(value)
+        switch strings.TrimSpace(name) {
+        case "inflight":
+            n, err := strconv.Atoi(value)
+            if err != nil || n <= 0 {
+                return LoadShedding{}, fmt.Errorf("invalid load_shedding entry %q", entry)
+            }
+            shedding.MaxInFlight = n
+        case "latency", "retry_after":
+            d, err := time.ParseDuration(value)
+            if err != nil || d <= 0 {
+                return LoadShedding{}, fmt.Errorf("invalid load_shedding entry %q", entry)
+            }
+            if name == "latency" {
+                shedding.MaxLatency = d
+            } else {
+                shedding.RetryAfter = d
+            }
+        default:
+            return LoadShedding{}, fmt.Errorf("invalid load_shedding entry %q", entry)
+        }
+    }
+    return shedding, nil
+}
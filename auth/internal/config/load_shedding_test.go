@@ -0,0 +1,26 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLoadShedding(t *testing.T) {
+	shedding, err := parseLoadShedding("")
+	require.NoError(t, err)
+	assert.False(t, shedding.Enabled())
+	assert.Equal(t, 5*time.Second, shedding.RetryAfter)
+
+	shedding, err = parseLoadShedding("inflight=200, latency=250ms, retry_after=10s")
+	require.NoError(t, err)
+	assert.True(t, shedding.Enabled())
+	assert.Equal(t, LoadShedding{MaxInFlight: 200, MaxLatency: 250 * time.Millisecond, RetryAfter: 10 * time.Second}, shedding)
+
+	for _, raw := range []string{"inflight=0", "latency=fast", "retry_after=-1s", "queue=10", "inflight"} {
+		_, err := parseLoadShedding(raw)
+		assert.Error(t, err, raw)
+	}
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+)
+
+// Content moderation providers. An empty provider turns moderation off.
+const (
+    ModerationProviderWordlist    = "wordlist"
+    ModerationProviderPerspective = "perspective"
+    ModerationProviderHTTP        = "http"
+)
+
+// ModerationConfig selects and configures the moderator that screens
+// usernames and display names.
+type ModerationConfig struct {
+    Provider string
+    // Words that deny or flag content for the wordlist provider
+    DenyWords []string
+    FlagWords []string
+    // Endpoint for the http provider; overrides the Perspective API URL
+    URL string
+    // Perspective API key, or bearer token sent to the http provider
+    APIKey string
+    // Perspective scores at or above which content is flagged or denied
+    FlagThreshold float64
+    DenyThreshold float64
+    // Handles guarded against impersonation on top of those of admins and
+    // venue accounts. Checked whatever the provider.
+    ProtectedHandles []string
+}
+
+// parseModerationProvider checks the provider name and the settings it
+// needs.
+func parseModerationProvider(provider, url, apiKey string) (string, error) {
+    provider = strings.ToLower(strings.TrimSpace(provider))
+    switch provider {
+    case "", "none":
+        return "", nil
+    case ModerationProviderWordlist:
+    case ModerationProviderPerspective:
+        if apiKey == "" {
+            return "", fmt.Errorf("moderation_api_key is required for the perspective moderation provider")
+        }
+    case ModerationProviderHTTP:
+        if url == "" {
+            return "", fmt.Errorf("moderation_url is required for the http moderation provider")
+        }
+    default:
+        return "", fmt.Errorf("unknown moderation_provider %q (expected wordlist, perspective or http)", provider)
+    }
+    return provider, nil
+}
+
+// parseModerationThresholds parses Perspective score thresholds, e.g.
+// "flag=0.7,deny=0.9". Missing entries keep their defaults.
+func parseModerationThresholds(raw string) (flag, deny float64, err error) {
+    flag, deny = 0.7, 0.9
+    for _, entry := range strings.Split(raw, ",") {
+        entry = strings.TrimSpace(entry)
+        if entry == "" {
+            continue
+        }
+
+        name, value, ok := strings.Cut(entry, "=")
+        score, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+        if !ok || err != nil || score <= 0 || score > 1 {
+            return 0, 0, fmt.Errorf("invalid moderation_thresholds entry %q", entry)
+        }
+        switch strings.TrimSpace(name) {
+        case "flag":
+            flag = score
+        case "deny":
+            deny = score
+        default:
+            return 0, 0, fmt.Errorf("invalid moderation_thresholds entry %q", entry)
+        }
+    }
+    if flag > deny {
+        return 0, 0, fmt.Errorf("moderation_thresholds flag must not exceed deny")
+    }
+    return flag, deny, nil
+}
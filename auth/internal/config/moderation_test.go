@@ -0,0 +1,44 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseModerationProvider(t *testing.T) {
+	provider, err := parseModerationProvider(" Wordlist", "", "")
+	require.NoError(t, err)
+	assert.Equal(t, ModerationProviderWordlist, provider)
+
+	provider, err = parseModerationProvider("none", "", "")
+	require.NoError(t, err)
+	assert.Empty(t, provider)
+
+	_, err = parseModerationProvider("perspective", "", "")
+	assert.Error(t, err)
+
+	_, err = parseModerationProvider("http", "", "token")
+	assert.Error(t, err)
+
+	_, err = parseModerationProvider("openai", "", "")
+	assert.Error(t, err)
+}
+
+func TestParseModerationThresholds(t *testing.T) {
+	flag, deny, err := parseModerationThresholds("")
+	require.NoError(t, err)
+	assert.Equal(t, 0.7, flag)
+	assert.Equal(t, 0.9, deny)
+
+	flag, deny, err = parseModerationThresholds("flag=0.5, deny=0.8")
+	require.NoError(t, err)
+	assert.Equal(t, 0.5, flag)
+	assert.Equal(t, 0.8, deny)
+
+	for _, raw := range []string{"flag=0.9,deny=0.5", "flag=2", "warn=0.5", "flag"} {
+		_, _, err := parseModerationThresholds(raw)
+		assert.Error(t, err, raw)
+	}
+}
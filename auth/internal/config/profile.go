@@ -0,0 +1,102 @@
+package config
+
+import (
+    "fmt"
+    "strings"
+)
+
+// Request log verbosity levels
+const (
+    RequestLogAll    = "all"
+    RequestLogErrors = "errors"
+    RequestLogNone   = "none"
+)
+
+// Profile bundles the logging and Gin settings for an environment. The
+// profile is picked by Environment and individual fields can be overridden
+// with log_encoding, log_level, gin_mode and request_log.
+type Profile struct {
+    Name        string
+    LogEncoding string // "json" or "console"
+    LogLevel    string // any zap level: debug, info, warn, error
+    GinMode     string // "debug", "release" or "test"
+    RequestLog  string // RequestLogAll, RequestLogErrors or RequestLogNone
+}
+
+var profiles = map[string]Profile{
+    "development": {
+        Name:        "development",
+        LogEncoding: "console",
+        LogLevel:    "debug",
+        GinMode:     "debug",
+        RequestLog:  RequestLogAll,
+    },
+    "staging": {
+        Name:        "staging",
+        LogEncoding: "json",
+        LogLevel:    "info",
+        GinMode:     "release",
+        RequestLog:  RequestLogAll,
+    },
+    "production": {
+        Name:        "production",
+        LogEncoding: "json",
+        LogLevel:    "info",
+        GinMode:     "release",
+        RequestLog:  RequestLogErrors,
+    },
+}
+
+var profileAliases = map[string]string{
+    "dev":  "development",
+    "prod": "production",
+}
+
+// ProfileFor returns the named profile for an environment.
+func ProfileFor(environment string) (Profile, error) {
+    name := strings.ToLower(strings.TrimSpace(environment))
+    if alias, ok := profileAliases[name]; ok {
+        name = alias
+    }
+
+    profile, ok := profiles[name]
+    if !ok {
+        return Profile{}, fmt.Errorf("unknown environment %q (expected development, staging or production)", environment)
+    }
+    return profile, nil
+}
+
+// applyOverrides replaces profile fields with any explicitly configured
+// values and checks the result.
+func (p Profile) applyOverrides(logEncoding, logLevel, ginMode, requestLog string) (Profile, error) {
+    if logEncoding != "" {
+        p.LogEncoding = logEncoding
+    }
+    if logLevel != "" {
+        p.LogLevel = logLevel
+    }
+    if ginMode != "" {
+        p.GinMode = ginMode
+    }
+    if requestLog != "" {
+        p.RequestLog = requestLog
+    }
+
+    switch p.LogEncoding {
+    case "json", "console":
+    default:
+        return p, fmt.Errorf("invalid log_encoding %q", p.LogEncoding)
+    }
+    switch p.GinMode {
+    case "debug", "release", "test":
+    default:
+        return p, fmt.Errorf("invalid gin_mode %q", p.GinMode)
+    }
+    switch p.RequestLog {
+    case RequestLogAll, RequestLogErrors, RequestLogNone:
+    default:
+        return p, fmt.Errorf("invalid request_log %q", p.RequestLog)
+    }
+
+    return p, nil
+}
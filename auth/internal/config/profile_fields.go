@@ -0,0 +1,34 @@
+package config
+
+import (
+    "fmt"
+    "strings"
+)
+
+// Profile fields that progressive profiling can require
+const (
+    ProfileFieldDisplayName = "display_name"
+    ProfileFieldAvatarURL   = "avatar_url"
+    ProfileFieldDateOfBirth = "date_of_birth"
+)
+
+// parseProfileRequiredFields parses the comma-separated profile fields users
+// are asked to fill in after signing up, e.g. "display_name,date_of_birth".
+func parseProfileRequiredFields(raw string) ([]string, error) {
+    fields := []string{}
+    seen := map[string]bool{}
+    for _, field := range strings.Split(raw, ",") {
+        field = strings.TrimSpace(field)
+        if field == "" || seen[field] {
+            continue
+        }
+        switch field {
+        case ProfileFieldDisplayName, ProfileFieldAvatarURL, ProfileFieldDateOfBirth:
+        default:
+            return nil, fmt.Errorf("invalid profile_required_fields entry %q", field)
+        }
+        seen[field] = true
+        fields = append(fields, field)
+    }
+    return fields, nil
+}
@@ -0,0 +1,21 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseProfileRequiredFields(t *testing.T) {
+	fields, err := parseProfileRequiredFields(" display_name,date_of_birth,display_name")
+	require.NoError(t, err)
+	assert.Equal(t, []string{ProfileFieldDisplayName, ProfileFieldDateOfBirth}, fields)
+
+	fields, err = parseProfileRequiredFields("")
+	require.NoError(t, err)
+	assert.Empty(t, fields)
+
+	_, err = parseProfileRequiredFields("display_name,bio")
+	assert.Error(t, err)
+}
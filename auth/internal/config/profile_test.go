@@ -0,0 +1,44 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfileFor(t *testing.T) {
+	for env, want := range map[string]string{
+		"development": "development",
+		"dev":         "development",
+		"Staging":     "staging",
+		"prod":        "production",
+		"production":  "production",
+	} {
+		profile, err := ProfileFor(env)
+		require.NoError(t, err, env)
+		assert.Equal(t, want, profile.Name, env)
+	}
+
+	_, err := ProfileFor("qa")
+	assert.Error(t, err)
+}
+
+func TestProfile_ApplyOverrides(t *testing.T) {
+	prod, err := ProfileFor("production")
+	require.NoError(t, err)
+
+	profile, err := prod.applyOverrides("console", "debug", "", RequestLogAll)
+	require.NoError(t, err)
+	assert.Equal(t, "console", profile.LogEncoding)
+	assert.Equal(t, "debug", profile.LogLevel)
+	assert.Equal(t, "release", profile.GinMode)
+	assert.Equal(t, RequestLogAll, profile.RequestLog)
+
+	_, err = prod.applyOverrides("xml", "", "", "")
+	assert.Error(t, err)
+	_, err = prod.applyOverrides("", "", "verbose", "")
+	assert.Error(t, err)
+	_, err = prod.applyOverrides("", "", "", "some")
+	assert.Error(t, err)
+}
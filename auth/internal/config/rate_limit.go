@@ -0,0 +1,50 @@
+package config
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+)
+
+// RateLimitRule is a per-IP limit on one group of endpoints. A shadow rule
+// only logs and counts the requests it would block, so a new limit can be
+// tuned against real traffic before it is enforced.
+type RateLimitRule struct {
+    Name      string
+    PerMinute int
+    Shadow    bool
+}
+
+// parseRateLimitRules parses a comma-separated list of name=perMinute rules,
+// each optionally suffixed with ":shadow", e.g. "login=10,register=5:shadow".
+func parseRateLimitRules(raw string) (map[string]RateLimitRule, error) {
+    rules := map[string]RateLimitRule{}
+    for _, entry := range strings.Split(raw, ",") {
+        entry = strings.TrimSpace(entry)
+        if entry == "" {
+            continue
+        }
+
+        name, spec, ok := strings.Cut(entry, "=")
+        name = strings.TrimSpace(name)
+        if !ok || name == "" {
+            return nil, fmt.Errorf("invalid rate_limit_rules entry %q", entry)
+        }
+
+        limit, mode, _ := strings.Cut(strings.TrimSpace(spec), ":")
+        perMinute, err := strconv.Atoi(limit)
+        if err != nil || perMinute <= 0 {
+            return nil, fmt.Errorf("invalid rate_limit_rules limit in %q", entry)
+        }
+        if mode != "" && mode != "shadow" && mode != "enforce" {
+            return nil, fmt.Errorf("invalid rate_limit_rules mode in %q", entry)
+        }
+
+        rules[name] = RateLimitRule{
+            Name:      name,
+            PerMinute: perMinute,
+            Shadow:    mode == "shadow",
+        }
+    }
+    return rules, nil
+}
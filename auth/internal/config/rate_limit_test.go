@@ -0,0 +1,25 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRateLimitRules(t *testing.T) {
+	rules, err := parseRateLimitRules("login=10, register=5:shadow,refresh=30:enforce")
+	require.NoError(t, err)
+	assert.Equal(t, RateLimitRule{Name: "login", PerMinute: 10}, rules["login"])
+	assert.Equal(t, RateLimitRule{Name: "register", PerMinute: 5, Shadow: true}, rules["register"])
+	assert.False(t, rules["refresh"].Shadow)
+
+	rules, err = parseRateLimitRules("")
+	require.NoError(t, err)
+	assert.Empty(t, rules)
+
+	for _, raw := range []string{"login", "login=0", "login=ten", "login=10:dry", "=10"} {
+		_, err := parseRateLimitRules(raw)
+		assert.Error(t, err, raw)
+	}
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+    "fmt"
+    "strings"
+)
+
+// Refresh binding policies. Strict rejects a refresh from outside the
+// session's original network or user agent family; relaxed allows it but
+// warns and notifies the user; off skips the check.
+const (
+    SessionPolicyStrict  = "strict"
+    SessionPolicyRelaxed = "relaxed"
+    SessionPolicyOff     = "off"
+)
+
+// SessionPolicy selects the refresh binding policy per client type
+// (as reported by X-Client-Type when the session was opened).
+type SessionPolicy struct {
+    Default  string
+    ByClient map[string]string
+}
+
+// parseSessionPolicy builds a SessionPolicy from the default policy and a
+// comma-separated list of client=policy overrides, e.g. "web=strict,ios=relaxed".
+func parseSessionPolicy(defaultPolicy, overrides string) (SessionPolicy, error) {
+    policy := SessionPolicy{
+        Default:  defaultPolicy,
+        ByClient: map[string]string{},
+    }
+    if !validSessionPolicy(defaultPolicy) {
+        return policy, fmt.Errorf("invalid session_policy %q", defaultPolicy)
+    }
+
+    for _, pair := range strings.Split(overrides, ",") {
+        pair = strings.TrimSpace(pair)
+        if pair == "" {
+            continue
+        }
+        client, mode, ok := strings.Cut(pair, "=")
+        client = strings.ToLower(strings.TrimSpace(client))
+        mode = strings.TrimSpace(mode)
+        if !ok || client == "" || !validSessionPolicy(mode) {
+            return policy, fmt.Errorf("invalid session_policy_clients entry %q", pair)
+        }
+        policy.ByClient[client] = mode
+    }
+
+    return policy, nil
+}
+
+func validSessionPolicy(mode string) bool {
+    switch mode {
+    case SessionPolicyStrict, SessionPolicyRelaxed, SessionPolicyOff:
+        return true
+    }
+    return false
+}
+
+// For returns the policy for a client type.
+func (p SessionPolicy) For(clientType string) string {
+    if mode, ok := p.ByClient[clientType]; ok {
+        return mode
+    }
+    if p.Default == "" {
+        return SessionPolicyOff
+    }
+    return p.Default
+}
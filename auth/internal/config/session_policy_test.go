@@ -0,0 +1,25 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSessionPolicy(t *testing.T) {
+	policy, err := parseSessionPolicy(SessionPolicyRelaxed, "web=strict, IOS=off")
+	require.NoError(t, err)
+	assert.Equal(t, SessionPolicyStrict, policy.For("web"))
+	assert.Equal(t, SessionPolicyOff, policy.For("ios"))
+	assert.Equal(t, SessionPolicyRelaxed, policy.For("android"))
+
+	assert.Equal(t, SessionPolicyOff, SessionPolicy{}.For("web"))
+
+	_, err = parseSessionPolicy("lenient", "")
+	assert.Error(t, err)
+	_, err = parseSessionPolicy(SessionPolicyStrict, "web")
+	assert.Error(t, err)
+	_, err = parseSessionPolicy(SessionPolicyStrict, "web=maybe")
+	assert.Error(t, err)
+}
@@ -0,0 +1,36 @@
+package config
+
+import (
+    "fmt"
+    "strings"
+)
+
+// Shortest accepted request signing secret
+const minSigningSecretLength = 32
+
+// parseSigningKeys parses a comma-separated list of keyID=secret pairs used
+// to verify signed internal requests, e.g. "chat=<secret>,user=<secret>".
+func parseSigningKeys(raw string) (map[string]string, error) {
+    keys := map[string]string{}
+    for _, entry := range strings.Split(raw, ",") {
+        entry = strings.TrimSpace(entry)
+        if entry == "" {
+            continue
+        }
+
+        keyID, secret, ok := strings.Cut(entry, "=")
+        keyID = strings.TrimSpace(keyID)
+        if !ok || keyID == "" {
+            return nil, fmt.Errorf("invalid internal_signing_keys entry for key %q", keyID)
+        }
+        if _, dup := keys[keyID]; dup {
+            return nil, fmt.Errorf("duplicate internal_signing_keys key %q", keyID)
+        }
+        // The secret itself is never echoed in errors
+        if len(strings.TrimSpace(secret)) < minSigningSecretLength {
+            return nil, fmt.Errorf("internal_signing_keys secret for %q must be at least %d characters", keyID, minSigningSecretLength)
+        }
+        keys[keyID] = strings.TrimSpace(secret)
+    }
+    return keys, nil
+}
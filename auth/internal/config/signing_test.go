@@ -0,0 +1,29 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSigningKeys(t *testing.T) {
+	secret := strings.Repeat("s", 32)
+
+	keys, err := parseSigningKeys("chat=" + secret + ", user = " + secret + "x")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"chat": secret, "user": secret + "x"}, keys)
+
+	keys, err = parseSigningKeys("")
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+
+	for _, raw := range []string{"chat", "chat=short", "=" + secret, "chat=" + secret + ",chat=" + secret} {
+		_, err := parseSigningKeys(raw)
+		assert.Error(t, err, raw)
+		if err != nil {
+			assert.NotContains(t, err.Error(), secret)
+		}
+	}
+}
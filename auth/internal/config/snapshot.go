@@ -0,0 +1,76 @@
+package config
+
+import (
+    "fmt"
+    "os"
+    "strings"
+
+    "github.com/spf13/viper"
+)
+
+// bundledSettings are the key material and policy settings carried in key
+// bundles. Connection URLs and the region are left out so a cloned
+// environment never points at the source environment's infrastructure.
+var bundledSettings = []string{
+    "jwt_secret",
+    "jwt_expiry",
+    "refresh_expiry",
+    "trusted_refresh_expiry",
+    "view_only_grace",
+    "recovery_token_expiry",
+    "email_verification_expiry",
+    "email_reverify_months",
+    "session_policy",
+    "session_policy_clients",
+    "rate_limit",
+    "rate_limit_rules",
+    "token_store",
+    "api_v1_sunset",
+    "email_from",
+    "smtp_host",
+    "smtp_port",
+    "smtp_user",
+    "smtp_pass",
+}
+
+// BundledSettings returns the loaded values of the settings carried in key
+// bundles, skipping unset ones. A JWT secret kept in jwt_secret_file is
+// bundled by value as jwt_secret, so the restored environment doesn't need
+// the file. Call it after Load.
+func BundledSettings() (map[string]string, error) {
+    settings := make(map[string]string)
+    for _, key := range bundledSettings {
+        if value := viper.GetString(key); value != "" {
+            settings[key] = value
+        }
+    }
+
+    if path := viper.GetString("jwt_secret_file"); path != "" {
+        secret, err := os.ReadFile(path)
+        if err != nil {
+            return nil, fmt.Errorf("read jwt_secret_file: %w", err)
+        }
+        settings["jwt_secret"] = strings.TrimRight(string(secret), "\r\n")
+    }
+    return settings, nil
+}
+
+// WriteSettings writes settings to path as a YAML config file that Load can
+// read. The file is readable by its owner only. An existing file is only
+// replaced when overwrite is set.
+func WriteSettings(path string, settings map[string]string, overwrite bool) error {
+    v := viper.New()
+    v.SetConfigPermissions(0o600)
+    for key, value := range settings {
+        v.Set(key, value)
+    }
+
+    write := v.SafeWriteConfigAs
+    if overwrite {
+        write = v.WriteConfigAs
+    }
+    if err := write(path); err != nil {
+        return fmt.Errorf("write settings: %w", err)
+    }
+    return nil
+}
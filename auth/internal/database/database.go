@@ -5,6 +5,7 @@ import (
     "embed"
     "fmt"
 
+    "github.com/jackc/pgx/v5"
     "github.com/jackc/pgx/v5/pgxpool"
     "github.com/jackc/pgx/v5/stdlib"
     "github.com/pressly/goose/v3"
@@ -18,6 +19,13 @@ type DB struct {
 }
 
 func New(databaseURL string) (*DB, error) {
+    return NewWithFaults(databaseURL, nil)
+}
+
+// NewWithFaults is New with fault run before every query: it may delay the
+// query, and returning true fails it as if Postgres had timed out. It is
+// meant for resilience testing.
+func NewWithFaults(databaseURL string, fault func(ctx context.Context) bool) (*DB, error) {
     config, err := pgxpool.ParseConfig(databaseURL)
     if err != nil {
         return nil, fmt.Errorf("parse config: %w", err)
@@ -25,6 +33,9 @@ func New(databaseURL string) (*DB, error) {
 
     config.MaxConns = 25
     config.MinConns = 5
+    if fault != nil {
+        config.ConnConfig.Tracer = faultTracer(fault)
+    }
 
     pool, err := pgxpool.NewWithConfig(context.Background(), config)
     if err != nil {
@@ -62,4 +73,36 @@ func (db *DB) Migrate() error {
     }
 
     return nil
-}
\ No newline at end of file
+}
+
+// faultTracer fails queries by handing pgx a context that has already timed
+// out, so they return before anything is sent.
+type faultTracer func(ctx context.Context) bool
+
+func (f faultTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, _ pgx.TraceQueryStartData) context.Context {
+    if f(ctx) {
+        return timedOut{ctx}
+    }
+    return ctx
+}
+
+func (f faultTracer) TraceQueryEnd(context.Context, *pgx.Conn, pgx.TraceQueryEndData) {}
+
+var closedDone = func() chan struct{} {
+    done := make(chan struct{})
+    close(done)
+    return done
+}()
+
+// timedOut is a context whose deadline has passed.
+type timedOut struct {
+    context.Context
+}
+
+func (timedOut) Done() <-chan struct{} {
+    return closedDone
+}
+
+func (timedOut) Err() error {
+    return context.DeadlineExceeded
+}
@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE api_keys (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(100) NOT NULL,
+    key_prefix VARCHAR(16) NOT NULL,
+    key_hash VARCHAR(64) UNIQUE NOT NULL,
+    daily_quota INTEGER NOT NULL DEFAULT 0,
+    monthly_quota INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    revoked_at TIMESTAMP
+);
+
+CREATE INDEX idx_api_keys_key_hash ON api_keys(key_hash);
+
+-- +goose Down
+DROP TABLE IF EXISTS api_keys;
@@ -0,0 +1,34 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN recovery_email VARCHAR(255);
+
+CREATE TABLE recovery_codes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash VARCHAR(64) NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_recovery_codes_user_id ON recovery_codes(user_id);
+
+CREATE TABLE recovery_requests (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    method VARCHAR(20) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    contact_email VARCHAR(255),
+    details TEXT,
+    token_hash VARCHAR(64) UNIQUE,
+    token_expires_at TIMESTAMP,
+    reviewed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    reviewed_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_recovery_requests_user_id ON recovery_requests(user_id);
+CREATE INDEX idx_recovery_requests_status ON recovery_requests(status);
+
+-- +goose Down
+DROP TABLE IF EXISTS recovery_requests;
+DROP TABLE IF EXISTS recovery_codes;
+ALTER TABLE users DROP COLUMN IF EXISTS recovery_email;
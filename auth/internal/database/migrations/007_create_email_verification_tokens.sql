@@ -0,0 +1,25 @@
+-- +goose Up
+CREATE TABLE email_verification_tokens (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_email_verification_tokens_user_id ON email_verification_tokens(user_id);
+
+-- Carry over outstanding tokens, hashed, with a fresh expiry
+INSERT INTO email_verification_tokens (user_id, email, token_hash, expires_at)
+SELECT id, email, encode(sha256(convert_to(email_token, 'UTF8')), 'hex'), NOW() + INTERVAL '24 hours'
+FROM users
+WHERE email_token IS NOT NULL AND email_verified = false;
+
+ALTER TABLE users DROP COLUMN email_token;
+
+-- +goose Down
+ALTER TABLE users ADD COLUMN email_token VARCHAR(255);
+CREATE INDEX idx_users_email_token ON users(email_token) WHERE email_token IS NOT NULL;
+DROP TABLE IF EXISTS email_verification_tokens;
@@ -0,0 +1,35 @@
+-- +goose Up
+-- admin_id and target_user_id deliberately have no foreign keys: entries must
+-- outlive the accounts they mention.
+CREATE TABLE admin_audit_log (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    admin_id UUID NOT NULL,
+    action VARCHAR(50) NOT NULL,
+    target_type VARCHAR(50) NOT NULL,
+    target_id VARCHAR(255) NOT NULL,
+    target_user_id UUID,
+    before_state JSONB,
+    after_state JSONB,
+    ip VARCHAR(45),
+    user_agent TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_admin_audit_log_admin_id ON admin_audit_log(admin_id, created_at DESC);
+CREATE INDEX idx_admin_audit_log_target_user_id ON admin_audit_log(target_user_id, created_at DESC) WHERE target_user_id IS NOT NULL;
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION admin_audit_log_immutable() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'admin_audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER admin_audit_log_no_update_delete
+    BEFORE UPDATE OR DELETE ON admin_audit_log
+    FOR EACH ROW EXECUTE FUNCTION admin_audit_log_immutable();
+
+-- +goose Down
+DROP TABLE IF EXISTS admin_audit_log;
+DROP FUNCTION IF EXISTS admin_audit_log_immutable();
@@ -0,0 +1,23 @@
+-- +goose Up
+CREATE TABLE funnel_events (
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    step VARCHAR(30) NOT NULL,
+    client_type VARCHAR(20) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_funnel_events_created_at ON funnel_events(created_at);
+
+CREATE TABLE funnel_daily_stats (
+    day DATE NOT NULL,
+    step VARCHAR(30) NOT NULL,
+    client_type VARCHAR(20) NOT NULL,
+    count INTEGER NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (day, step, client_type)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS funnel_daily_stats;
+DROP TABLE IF EXISTS funnel_events;
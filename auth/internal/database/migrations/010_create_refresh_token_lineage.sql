@@ -0,0 +1,24 @@
+-- +goose Up
+-- One row per refresh token ever issued. family_id is the session the token
+-- belongs to; it has no foreign key so the lineage survives logout and
+-- revocation for later investigation.
+CREATE TABLE refresh_token_lineage (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    family_id UUID NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    parent_id UUID REFERENCES refresh_token_lineage(id),
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    ip VARCHAR(45),
+    user_agent TEXT,
+    issued_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    rotated_at TIMESTAMP,
+    reused_at TIMESTAMP,
+    reused_ip VARCHAR(45),
+    reused_user_agent TEXT
+);
+
+CREATE INDEX idx_refresh_token_lineage_family_id ON refresh_token_lineage(family_id, issued_at);
+CREATE INDEX idx_refresh_token_lineage_user_id ON refresh_token_lineage(user_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS refresh_token_lineage;
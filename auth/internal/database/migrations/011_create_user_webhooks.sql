@@ -0,0 +1,18 @@
+-- +goose Up
+-- Webhooks registered by users for their own account events. The secret is
+-- kept in plaintext because it is needed to sign every delivery.
+CREATE TABLE user_webhooks (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    secret VARCHAR(64) NOT NULL,
+    events TEXT[] NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_delivery_at TIMESTAMP,
+    last_status_code INTEGER
+);
+
+CREATE INDEX idx_user_webhooks_user_id ON user_webhooks(user_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS user_webhooks;
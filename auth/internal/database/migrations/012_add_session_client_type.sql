@@ -0,0 +1,7 @@
+-- +goose Up
+-- The client type (web, ios, ...) a session was opened from selects the
+-- refresh binding policy for the session's lifetime.
+ALTER TABLE sessions ADD COLUMN client_type VARCHAR(20) NOT NULL DEFAULT 'unknown';
+
+-- +goose Down
+ALTER TABLE sessions DROP COLUMN IF EXISTS client_type;
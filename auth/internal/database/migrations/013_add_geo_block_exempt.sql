@@ -0,0 +1,6 @@
+-- +goose Up
+-- Lets an admin allow an individual account to log in from a blocked country.
+ALTER TABLE users ADD COLUMN geo_block_exempt BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN IF EXISTS geo_block_exempt;
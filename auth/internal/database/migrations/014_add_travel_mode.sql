@@ -0,0 +1,21 @@
+-- +goose Up
+-- Travel mode pins new logins to one device until travel_mode_until; logins
+-- from any other device must be confirmed through an emailed token.
+ALTER TABLE users ADD COLUMN travel_mode_until TIMESTAMP;
+ALTER TABLE users ADD COLUMN travel_mode_user_agent TEXT;
+
+CREATE TABLE login_confirmation_tokens (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_login_confirmation_tokens_user_id ON login_confirmation_tokens(user_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS login_confirmation_tokens;
+ALTER TABLE users DROP COLUMN IF EXISTS travel_mode_user_agent;
+ALTER TABLE users DROP COLUMN IF EXISTS travel_mode_until;
@@ -0,0 +1,9 @@
+-- +goose Up
+-- User-chosen session names and trusted-device marking. Trusted sessions get
+-- a longer refresh lifetime and skip travel mode login confirmation.
+ALTER TABLE sessions ADD COLUMN label VARCHAR(100);
+ALTER TABLE sessions ADD COLUMN trusted BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+ALTER TABLE sessions DROP COLUMN IF EXISTS trusted;
+ALTER TABLE sessions DROP COLUMN IF EXISTS label;
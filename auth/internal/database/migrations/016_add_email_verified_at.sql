@@ -0,0 +1,12 @@
+-- +goose Up
+-- Tracks when the primary email was last proven, and the last bounce reported
+-- for it, so accounts can be asked to re-verify.
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN email_bounced_at TIMESTAMP;
+
+-- Best available estimate for accounts verified before this column existed
+UPDATE users SET email_verified_at = updated_at WHERE email_verified = true;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN IF EXISTS email_bounced_at;
+ALTER TABLE users DROP COLUMN IF EXISTS email_verified_at;
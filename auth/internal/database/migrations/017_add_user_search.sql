@@ -0,0 +1,25 @@
+-- +goose Up
+-- Public profile fields and privacy controls for user search.
+ALTER TABLE users ADD COLUMN display_name VARCHAR(100);
+ALTER TABLE users ADD COLUMN avatar_url VARCHAR(2048);
+ALTER TABLE users ADD COLUMN discoverable BOOLEAN NOT NULL DEFAULT TRUE;
+
+CREATE INDEX idx_users_username_prefix ON users (lower(username) text_pattern_ops);
+CREATE INDEX idx_users_display_name_prefix ON users (lower(display_name) text_pattern_ops);
+
+CREATE TABLE user_blocks (
+    blocker_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    blocked_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (blocker_id, blocked_id)
+);
+
+CREATE INDEX idx_user_blocks_blocked_id ON user_blocks(blocked_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS user_blocks;
+DROP INDEX IF EXISTS idx_users_display_name_prefix;
+DROP INDEX IF EXISTS idx_users_username_prefix;
+ALTER TABLE users DROP COLUMN IF EXISTS discoverable;
+ALTER TABLE users DROP COLUMN IF EXISTS avatar_url;
+ALTER TABLE users DROP COLUMN IF EXISTS display_name;
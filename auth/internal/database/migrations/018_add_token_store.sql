@@ -0,0 +1,12 @@
+-- +goose Up
+-- Backing table for the postgres token store driver.
+CREATE TABLE token_store (
+    key VARCHAR(255) PRIMARY KEY,
+    value TEXT NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_token_store_expires_at ON token_store(expires_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS token_store;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- Region that opened each session. Untagged sessions predate multi-region
+-- support and are cleaned up by every region.
+ALTER TABLE sessions ADD COLUMN region VARCHAR(32) NOT NULL DEFAULT '';
+
+CREATE INDEX idx_sessions_region_expires_at ON sessions(region, expires_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_sessions_region_expires_at;
+ALTER TABLE sessions DROP COLUMN IF EXISTS region;
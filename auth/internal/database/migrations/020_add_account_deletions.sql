@@ -0,0 +1,27 @@
+-- +goose Up
+-- Queue of account deletions worked through in stages by a background worker.
+-- There is no foreign key to users because the user row is deleted before
+-- the job is marked complete.
+CREATE TABLE account_deletions (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    username VARCHAR(50) NOT NULL,
+    mode VARCHAR(16) NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    stages_done INT NOT NULL DEFAULT 0,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    requested_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_account_deletions_pending_user ON account_deletions(user_id) WHERE status = 'pending';
+CREATE INDEX idx_account_deletions_next_attempt ON account_deletions(next_attempt_at) WHERE status = 'pending';
+
+-- Set when deletion is requested; such accounts can no longer log in.
+ALTER TABLE users ADD COLUMN deletion_requested_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN IF EXISTS deletion_requested_at;
+DROP TABLE IF EXISTS account_deletions;
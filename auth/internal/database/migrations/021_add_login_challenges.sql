@@ -0,0 +1,36 @@
+-- +goose Up
+-- TOTP second factor and terms of service acceptance, checked by login
+-- challenges.
+ALTER TABLE users ADD COLUMN totp_secret VARCHAR(64);
+ALTER TABLE users ADD COLUMN totp_pending_secret VARCHAR(64);
+ALTER TABLE users ADD COLUMN totp_enabled_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN totp_last_step BIGINT;
+ALTER TABLE users ADD COLUMN tos_accepted_version VARCHAR(32);
+ALTER TABLE users ADD COLUMN tos_accepted_at TIMESTAMP;
+
+-- A login that still has challenges to pass. user_id is NULL and
+-- password_ok false when the credentials were wrong but a captcha has to be
+-- solved before that is revealed.
+CREATE TABLE login_challenges (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    password_ok BOOLEAN NOT NULL,
+    pending TEXT[] NOT NULL,
+    email_code_hash VARCHAR(64),
+    failed_attempts INT NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_login_challenges_expires_at ON login_challenges(expires_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS login_challenges;
+ALTER TABLE users DROP COLUMN IF EXISTS tos_accepted_at;
+ALTER TABLE users DROP COLUMN IF EXISTS tos_accepted_version;
+ALTER TABLE users DROP COLUMN IF EXISTS totp_last_step;
+ALTER TABLE users DROP COLUMN IF EXISTS totp_enabled_at;
+ALTER TABLE users DROP COLUMN IF EXISTS totp_pending_secret;
+ALTER TABLE users DROP COLUMN IF EXISTS totp_secret;
@@ -0,0 +1,28 @@
+-- +goose Up
+-- Onboarding milestones a user has reached, one row per milestone.
+CREATE TABLE onboarding_milestones (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    milestone VARCHAR(32) NOT NULL,
+    reached_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, milestone)
+);
+
+-- Emails queued for a background worker to send, so requests never wait on
+-- the email service.
+CREATE TABLE email_outbox (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    template VARCHAR(32) NOT NULL,
+    recipient VARCHAR(255) NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    sent_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_email_outbox_next_attempt ON email_outbox(next_attempt_at) WHERE sent_at IS NULL;
+
+-- +goose Down
+DROP TABLE IF EXISTS email_outbox;
+DROP TABLE IF EXISTS onboarding_milestones;
@@ -0,0 +1,7 @@
+-- +goose Up
+-- Opt-in to the public profile card served to unauthenticated viewers of
+-- share links.
+ALTER TABLE users ADD COLUMN public_card BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN IF EXISTS public_card;
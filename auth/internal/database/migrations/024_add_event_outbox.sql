@@ -0,0 +1,15 @@
+-- +goose Up
+-- Events that couldn't be handed to RabbitMQ in time, relayed by a background
+-- job once the broker keeps up again.
+CREATE TABLE event_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    event JSONB NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_event_outbox_next_attempt ON event_outbox(next_attempt_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS event_outbox;
@@ -0,0 +1,15 @@
+-- +goose Up
+-- Every published user event, kept so new consumers can backfill by paging
+-- through it instead of replaying RabbitMQ.
+CREATE TABLE event_journal (
+    id BIGSERIAL PRIMARY KEY,
+    type VARCHAR(64) NOT NULL,
+    user_id VARCHAR(64) NOT NULL,
+    event JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_event_journal_created_at ON event_journal(created_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS event_journal;
@@ -0,0 +1,7 @@
+-- +goose Up
+-- Optional profile field; never shown publicly. Whether it is required is
+-- set by the progressive profiling policy (profile_required_fields).
+ALTER TABLE users ADD COLUMN date_of_birth DATE;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN IF EXISTS date_of_birth;
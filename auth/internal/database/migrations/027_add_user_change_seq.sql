@@ -0,0 +1,7 @@
+-- +goose Up
+-- Bumped on every username or email change and sent with the change events,
+-- so consumers can tell which change is the latest.
+ALTER TABLE users ADD COLUMN change_seq BIGINT NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN IF EXISTS change_seq;
@@ -0,0 +1,36 @@
+-- +goose Up
+-- Usernames and display names that moderation flagged, or couldn't check
+-- because the provider was down, waiting to be checked again. One row per
+-- user and field; a newer change replaces it.
+CREATE TABLE moderation_checks (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    field VARCHAR(32) NOT NULL,
+    content TEXT NOT NULL,
+    outcome VARCHAR(16) NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    next_check_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, field)
+);
+
+CREATE INDEX idx_moderation_checks_next_check_at ON moderation_checks(next_check_at);
+
+-- Every denial, whether a change was rejected or a re-check reverted it.
+-- user_id is NULL for usernames rejected at registration.
+CREATE TABLE moderation_denials (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    field VARCHAR(32) NOT NULL,
+    content TEXT NOT NULL,
+    provider VARCHAR(32) NOT NULL,
+    reason TEXT,
+    source VARCHAR(16) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_moderation_denials_user_id ON moderation_denials(user_id, created_at DESC);
+CREATE INDEX idx_moderation_denials_created_at ON moderation_denials(created_at DESC);
+
+-- +goose Down
+DROP TABLE IF EXISTS moderation_denials;
+DROP TABLE IF EXISTS moderation_checks;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- Inputs of the account security score. Accounts that never changed their
+-- password fall back to created_at; anomaly_at is set when a relaxed session
+-- is refreshed from outside its original network or user agent.
+ALTER TABLE users ADD COLUMN password_changed_at TIMESTAMP;
+ALTER TABLE sessions ADD COLUMN anomaly_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE sessions DROP COLUMN IF EXISTS anomaly_at;
+ALTER TABLE users DROP COLUMN IF EXISTS password_changed_at;
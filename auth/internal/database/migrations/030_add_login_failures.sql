@@ -0,0 +1,18 @@
+-- +goose Up
+-- Audit log of failed logins with their internal reason. user_id is NULL when
+-- the email matched no account or the login was refused before lookup.
+CREATE TABLE login_failures (
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    reason VARCHAR(30) NOT NULL,
+    client_type VARCHAR(20) NOT NULL,
+    ip VARCHAR(45),
+    user_agent TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_login_failures_created_at ON login_failures(created_at);
+CREATE INDEX idx_login_failures_user_id ON login_failures(user_id) WHERE user_id IS NOT NULL;
+
+-- +goose Down
+DROP TABLE IF EXISTS login_failures;
@@ -0,0 +1,16 @@
+-- +goose Up
+-- Emails captured instead of sent while EMAIL_SANDBOX is on
+CREATE TABLE sent_emails (
+    id BIGSERIAL PRIMARY KEY,
+    recipient VARCHAR(255) NOT NULL,
+    template VARCHAR(50) NOT NULL,
+    subject TEXT NOT NULL,
+    body TEXT NOT NULL,
+    data JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_sent_emails_recipient ON sent_emails(recipient, created_at DESC);
+
+-- +goose Down
+DROP TABLE IF EXISTS sent_emails;
@@ -0,0 +1,8 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN plan VARCHAR(20) NOT NULL DEFAULT 'free'
+    CHECK (plan IN ('free', 'plus', 'venue'));
+ALTER TABLE users ADD COLUMN plan_changed_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN IF EXISTS plan_changed_at;
+ALTER TABLE users DROP COLUMN IF EXISTS plan;
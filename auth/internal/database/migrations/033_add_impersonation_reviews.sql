@@ -0,0 +1,21 @@
+-- +goose Up
+-- Usernames, display names and email addresses that resemble a protected
+-- handle closely enough to need a human look. A user has at most one pending
+-- review per field; a newer change replaces it.
+CREATE TABLE impersonation_reviews (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    field VARCHAR(32) NOT NULL,
+    content TEXT NOT NULL,
+    protected_handle VARCHAR(255) NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    reviewed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    reviewed_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX idx_impersonation_reviews_pending ON impersonation_reviews(user_id, field) WHERE status = 'pending';
+CREATE INDEX idx_impersonation_reviews_status ON impersonation_reviews(status, created_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS impersonation_reviews;
@@ -0,0 +1,44 @@
+-- +goose Up
+-- ID_VERSION=7 makes the service generate time-ordered UUIDv7 user and
+-- session IDs. These helpers do the same inside Postgres, for scripts and
+-- for range-partitioning by ID. v4 IDs already stored stay valid.
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION uuid_generate_v7() RETURNS UUID AS $$
+DECLARE
+    ms BYTEA := substring(int8send(floor(extract(epoch FROM clock_timestamp()) * 1000)::BIGINT) FROM 3);
+    bytes BYTEA := ms || substring(uuid_send(uuid_generate_v4()) FROM 7);
+BEGIN
+    -- Version 7 in the high nibble of byte 6; the variant bits come from v4
+    bytes := set_byte(bytes, 6, (get_byte(bytes, 6) & 15) | 112);
+    RETURN encode(bytes, 'hex')::UUID;
+END;
+$$ LANGUAGE plpgsql VOLATILE;
+-- +goose StatementEnd
+
+-- When a UUIDv7 was generated, or NULL for other versions
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION uuid_v7_time(id UUID) RETURNS TIMESTAMP AS $$
+    SELECT CASE WHEN get_byte(uuid_send(id), 6) >> 4 = 7 THEN
+        to_timestamp(('x' || lpad(substring(replace(id::TEXT, '-', '') FROM 1 FOR 12), 16, '0'))::BIT(64)::BIGINT / 1000.0)
+            AT TIME ZONE 'UTC'
+    END;
+$$ LANGUAGE sql IMMUTABLE;
+-- +goose StatementEnd
+
+-- The smallest UUIDv7 of an instant: IDs generated at or after it compare
+-- greater or equal, so "id >= uuid_v7_lower_bound(t)" is an index range scan
+-- on the primary key
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION uuid_v7_lower_bound(ts TIMESTAMP) RETURNS UUID AS $$
+    SELECT encode(
+        substring(int8send(floor(extract(epoch FROM ts AT TIME ZONE 'UTC') * 1000)::BIGINT) FROM 3)
+            || '\x70000000000000000000'::BYTEA,
+        'hex')::UUID;
+$$ LANGUAGE sql IMMUTABLE;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP FUNCTION IF EXISTS uuid_v7_lower_bound(TIMESTAMP);
+DROP FUNCTION IF EXISTS uuid_v7_time(UUID);
+DROP FUNCTION IF EXISTS uuid_generate_v7();
@@ -0,0 +1,20 @@
+-- +goose Up
+-- Long-lived tokens users create for their own integrations and bots. Only
+-- a hash of the token is stored; token_prefix identifies it in listings.
+CREATE TABLE personal_access_tokens (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    token_prefix VARCHAR(16) NOT NULL,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    scopes TEXT[] NOT NULL,
+    expires_at TIMESTAMP,
+    last_used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    revoked_at TIMESTAMP
+);
+
+CREATE INDEX idx_personal_access_tokens_user_id ON personal_access_tokens(user_id, created_at DESC);
+
+-- +goose Down
+DROP TABLE IF EXISTS personal_access_tokens;
@@ -0,0 +1,13 @@
+-- +goose Up
+-- Badges granted by an admin. Email verification and MFA badges are computed
+-- from the users table and never stored here.
+CREATE TABLE user_badges (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    badge VARCHAR(32) NOT NULL CHECK (badge IN ('phone_verified', 'venue_verified')),
+    granted_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    granted_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, badge)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS user_badges;
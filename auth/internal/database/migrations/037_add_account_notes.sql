@@ -0,0 +1,21 @@
+-- +goose Up
+-- Internal notes and support flags admins keep on an account. A flag is a
+-- note with a flag set; a user has each flag at most once.
+CREATE TABLE account_notes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    flag VARCHAR(32) CHECK (flag IN ('vip', 'chargeback_risk')),
+    body TEXT NOT NULL DEFAULT '',
+    -- Only visible to the admins in RESTRICTED_NOTES_ADMINS
+    restricted BOOLEAN NOT NULL DEFAULT false,
+    -- Left out of the user's data export, where the law allows withholding it
+    export_exempt BOOLEAN NOT NULL DEFAULT false,
+    author_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_account_notes_user_id ON account_notes(user_id, created_at DESC);
+CREATE UNIQUE INDEX idx_account_notes_user_flag ON account_notes(user_id, flag) WHERE flag IS NOT NULL;
+
+-- +goose Down
+DROP TABLE IF EXISTS account_notes;
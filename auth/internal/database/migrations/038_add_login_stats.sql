@@ -0,0 +1,9 @@
+-- +goose Up
+-- Kept up to date by the login stats flush, so they lag logins by up to the
+-- flush interval. last_login keeps being set by the same flush.
+ALTER TABLE users ADD COLUMN login_count BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN last_login_ip VARCHAR(45);
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN IF EXISTS last_login_ip;
+ALTER TABLE users DROP COLUMN IF EXISTS login_count;
@@ -0,0 +1,7 @@
+-- +goose Up
+-- Region the user's data must be kept in, set at registration. Empty for
+-- users registered before data residency, who stay in the primary database.
+ALTER TABLE users ADD COLUMN data_region VARCHAR(16) NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN IF EXISTS data_region;
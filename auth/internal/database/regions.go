@@ -0,0 +1,58 @@
+package database
+
+import (
+    "fmt"
+)
+
+// Regions routes storage to the database of a user's data region. Regions
+// without a database of their own use the primary one.
+type Regions struct {
+    primary  *DB
+    byRegion map[string]*DB
+}
+
+// NewRegions connects to the database of each region in urls.
+func NewRegions(primary *DB, urls map[string]string) (*Regions, error) {
+    regions := &Regions{primary: primary, byRegion: map[string]*DB{}}
+    for region, url := range urls {
+        db, err := New(url)
+        if err != nil {
+            regions.Close()
+            return nil, fmt.Errorf("connect to %s database: %w", region, err)
+        }
+        regions.byRegion[region] = db
+    }
+    return regions, nil
+}
+
+// For returns the database holding data of region.
+func (r *Regions) For(region string) *DB {
+    if db, ok := r.byRegion[region]; ok {
+        return db
+    }
+    return r.primary
+}
+
+// Each calls fn with every region that has a database of its own.
+func (r *Regions) Each(fn func(region string, db *DB)) {
+    for region, db := range r.byRegion {
+        fn(region, db)
+    }
+}
+
+// Migrate runs the migrations on every regional database.
+func (r *Regions) Migrate() error {
+    for region, db := range r.byRegion {
+        if err := db.Migrate(); err != nil {
+            return fmt.Errorf("migrate %s database: %w", region, err)
+        }
+    }
+    return nil
+}
+
+// Close closes the regional databases, leaving the primary one open.
+func (r *Regions) Close() {
+    for _, db := range r.byRegion {
+        db.Close()
+    }
+}
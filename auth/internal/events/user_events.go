@@ -12,14 +12,70 @@ const (
     UserLogout   EventType = "user:logout"
     UserRegister EventType = "user:register"
     UserUpdate   EventType = "user:update"
+
+    // Username and email changes carry the old and new values and the
+    // user's change sequence number (seq), which grows with every change so
+    // consumers can skip events older than one they have already applied
+    UserUsernameChanged EventType = "user:username_changed"
+    UserEmailChanged    EventType = "user:email_changed"
+
+    // UserPlanChanged carries the new plan, the previous one, the new plan's
+    // entitlements and seq. Access tokens pick up the new entitlements when
+    // they are next refreshed.
+    UserPlanChanged EventType = "user:plan_changed"
+
+    // UserBadgesChanged carries the badge that was gained or lost (badge,
+    // granted) and all of the user's badges after the change (badges)
+    UserBadgesChanged EventType = "user:badges_changed"
+
+    // UserSnapshot carries the user's whole public profile and seq. It
+    // follows every change to the profile and can be re-requested, so
+    // consumers can keep a read model of users from these events alone.
+    UserSnapshot EventType = "user:snapshot"
+
+    // UserNewDevice follows a login from a user agent the account has never
+    // signed in with before
+    UserNewDevice EventType = "user:new_device"
+
+    // UserSessionAnomaly is a refresh from outside the session's original
+    // network or user agent family that the session policy allowed
+    UserSessionAnomaly EventType = "user:session_anomaly"
+
+    // UserSessionEvicted is a session closed because a login took the
+    // user past the session cap
+    UserSessionEvicted EventType = "user:session_evicted"
+
+    // UserGoneTokenUsed is a still valid access token presented for a user
+    // that no longer exists. The token is blacklisted.
+    UserGoneTokenUsed EventType = "user:gone_token_used"
+
+    // Account deletion, one event per deletion mode. UserDeleted and
+    // UserExportedDeleted mean all of the user's data should be removed;
+    // UserAnonymized means content stays but is attributed to a deleted user.
+    UserDeleted         EventType = "user:deleted"
+    UserAnonymized      EventType = "user:anonymized"
+    UserExportedDeleted EventType = "user:exported_deleted"
+
+    // Onboarding. UserOnboardingStarted follows registration,
+    // UserOnboardingMilestone each milestone reached after that, and
+    // UserOnboardingCompleted the last one.
+    UserOnboardingStarted   EventType = "user:onboarding_started"
+    UserOnboardingMilestone EventType = "user:onboarding_milestone"
+    UserOnboardingCompleted EventType = "user:onboarding_completed"
+
+    // ChatJoined is consumed from the chat service's chat_events exchange
+    // when a user joins a chat room
+    ChatJoined EventType = "chat:joined"
 )
 
 type UserEvent struct {
-    Type      EventType              `json:"type"`
-    UserID    string                 `json:"user_id"`
-    Username  string                 `json:"username"`
-    Timestamp time.Time              `json:"timestamp"`
-    Data      map[string]interface{} `json:"data,omitempty"`
+    Type       EventType              `json:"type"`
+    UserID     string                 `json:"user_id"`
+    Username   string                 `json:"username"`
+    // The user's data region, on registration, login and snapshot events
+    DataRegion string                 `json:"data_region,omitempty"`
+    Timestamp  time.Time              `json:"timestamp"`
+    Data       map[string]interface{} `json:"data,omitempty"`
 }
 
 func NewUserEvent(eventType EventType, userID, username string) *UserEvent {
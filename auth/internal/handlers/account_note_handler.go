@@ -0,0 +1,144 @@
+package handlers
+
+import (
+    "net/http"
+
+    "auth-service/internal/models"
+    "auth-service/internal/response"
+    "auth-service/internal/services"
+
+    "github.com/gin-gonic/gin"
+    "github.com/google/uuid"
+    "go.uber.org/zap"
+)
+
+// AccountNoteHandler lets admins keep internal notes and support flags on
+// accounts.
+type AccountNoteHandler struct {
+    notes        *services.AccountNoteService
+    auditService *services.AdminAuditService
+    logger       *zap.SugaredLogger
+}
+
+func NewAccountNoteHandler(notes *services.AccountNoteService, auditService *services.AdminAuditService, logger *zap.SugaredLogger) *AccountNoteHandler {
+    return &AccountNoteHandler{
+        notes:        notes,
+        auditService: auditService,
+        logger:       logger,
+    }
+}
+
+// ListNotes returns the user's notes and flags newest first, without the
+// restricted ones unless the admin may see them. Every listing is audited with
+// the notes it showed.
+func (h *AccountNoteHandler) ListNotes(c *gin.Context) {
+    userID, err := uuid.Parse(c.Param("id"))
+    if err != nil {
+        response.Error(c, http.StatusBadRequest, "Invalid user ID")
+        return
+    }
+
+    notes, err := h.notes.List(c.Request.Context(), userID, adminID(c))
+    if err != nil {
+        respondError(c, h.logger, err, "Failed to list account notes")
+        return
+    }
+
+    listing := &auditedNoteListing{NoteIDs: make([]uuid.UUID, 0, len(notes))}
+    for _, note := range notes {
+        listing.NoteIDs = append(listing.NoteIDs, note.ID)
+        if note.Restricted {
+            listing.Restricted++
+        }
+    }
+    recordAdminAction(c, h.auditService, h.logger, &models.AdminAuditEntry{
+        Action:       models.AdminActionListAccountNotes,
+        TargetType:   models.AuditTargetUser,
+        TargetID:     userID.String(),
+        TargetUserID: &userID,
+    }, nil, listing)
+
+    response.JSON(c, http.StatusOK, gin.H{"notes": notes})
+}
+
+// CreateNote adds a note or flag to the user's account.
+func (h *AccountNoteHandler) CreateNote(c *gin.Context) {
+    userID, err := uuid.Parse(c.Param("id"))
+    if err != nil {
+        response.Error(c, http.StatusBadRequest, "Invalid user ID")
+        return
+    }
+
+    var req models.CreateAccountNoteRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondBindingError(c, err)
+        return
+    }
+
+    note, err := h.notes.Create(c.Request.Context(), userID, adminID(c), &req)
+    if err != nil {
+        respondError(c, h.logger, err, "Failed to create account note")
+        return
+    }
+
+    recordAdminAction(c, h.auditService, h.logger, &models.AdminAuditEntry{
+        Action:       models.AdminActionCreateAccountNote,
+        TargetType:   models.AuditTargetAccountNote,
+        TargetID:     note.ID.String(),
+        TargetUserID: &userID,
+    }, nil, auditedNote(note))
+
+    response.JSON(c, http.StatusCreated, note)
+}
+
+// DeleteNote removes a note or flag from the user's account.
+func (h *AccountNoteHandler) DeleteNote(c *gin.Context) {
+    userID, err := uuid.Parse(c.Param("id"))
+    if err != nil {
+        response.Error(c, http.StatusBadRequest, "Invalid user ID")
+        return
+    }
+    noteID, err := uuid.Parse(c.Param("note_id"))
+    if err != nil {
+        response.Error(c, http.StatusBadRequest, "Invalid note ID")
+        return
+    }
+
+    note, err := h.notes.Delete(c.Request.Context(), userID, noteID, adminID(c))
+    if err != nil {
+        respondError(c, h.logger, err, "Failed to delete account note")
+        return
+    }
+
+    recordAdminAction(c, h.auditService, h.logger, &models.AdminAuditEntry{
+        Action:       models.AdminActionDeleteAccountNote,
+        TargetType:   models.AuditTargetAccountNote,
+        TargetID:     note.ID.String(),
+        TargetUserID: &userID,
+    }, auditedNote(note), nil)
+
+    response.JSON(c, http.StatusOK, gin.H{"message": "Note deleted"})
+}
+
+// auditedNoteListing records which notes a listing showed, and how many of
+// them were restricted.
+type auditedNoteListing struct {
+    NoteIDs    []uuid.UUID `json:"note_ids"`
+    Restricted int         `json:"restricted"`
+}
+
+// auditedNote is the snapshot of a note kept in the audit log, which every
+// admin can read: restricted notes are recorded without their body.
+func auditedNote(note *models.AccountNote) *models.AccountNote {
+    if !note.Restricted {
+        return note
+    }
+    redacted := *note
+    redacted.Body = ""
+    return &redacted
+}
+
+func adminID(c *gin.Context) uuid.UUID {
+    admin, _ := c.Get("admin")
+    return admin.(*models.User).ID
+}
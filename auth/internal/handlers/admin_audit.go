@@ -0,0 +1,24 @@
+package handlers
+
+import (
+    "auth-service/internal/models"
+    "auth-service/internal/services"
+
+    "github.com/gin-gonic/gin"
+    "go.uber.org/zap"
+)
+
+// recordAdminAction fills in the acting admin and request metadata and appends
+// the entry to the admin audit log. It runs after the action has succeeded, so
+// a failure is logged rather than turned into an error response.
+func recordAdminAction(c *gin.Context, auditService *services.AdminAuditService, logger *zap.SugaredLogger, entry *models.AdminAuditEntry, before, after interface{}) {
+    admin, _ := c.Get("admin")
+    entry.AdminID = admin.(*models.User).ID
+    entry.IP = c.ClientIP()
+    entry.UserAgent = c.Request.UserAgent()
+
+    if err := auditService.Record(c.Request.Context(), entry, before, after); err != nil {
+        logger.Errorf("Failed to record admin audit entry %s on %s %s by %s: %v",
+            entry.Action, entry.TargetType, entry.TargetID, entry.AdminID, err)
+    }
+}
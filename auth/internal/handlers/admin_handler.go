@@ -0,0 +1,668 @@
+package handlers
+
+import (
+    "fmt"
+    "net/http"
+    "strconv"
+    "time"
+
+    "auth-service/internal/models"
+    "auth-service/internal/response"
+    "auth-service/internal/services"
+
+    "github.com/gin-gonic/gin"
+    "github.com/google/uuid"
+    "go.uber.org/zap"
+)
+
+type AdminHandler struct {
+    apiKeyService *services.APIKeyService
+    auditService  *services.AdminAuditService
+    funnelService *services.FunnelService
+    loginFailures *services.LoginFailureService
+    loginStats    *services.LoginStatsService
+    ipBanService  *services.IPBanService
+    rateLimits    *services.RateLimitPolicyService
+    lineage       *services.TokenLineageService
+    geoBlock      *services.GeoBlockService
+    users         *services.UserService
+    sessions      *services.AuthService
+    logger        *zap.SugaredLogger
+}
+
+func NewAdminHandler(apiKeyService *services.APIKeyService, auditService *services.AdminAuditService, funnelService *services.FunnelService, loginFailures *services.LoginFailureService, loginStats *services.LoginStatsService, ipBanService *services.IPBanService, rateLimits *services.RateLimitPolicyService, lineage *services.TokenLineageService, geoBlock *services.GeoBlockService, users *services.UserService, sessions *services.AuthService, logger *zap.SugaredLogger) *AdminHandler {
+    return &AdminHandler{
+        apiKeyService: apiKeyService,
+        auditService:  auditService,
+        funnelService: funnelService,
+        loginFailures: loginFailures,
+        loginStats:    loginStats,
+        ipBanService:  ipBanService,
+        rateLimits:    rateLimits,
+        lineage:       lineage,
+        geoBlock:      geoBlock,
+        users:         users,
+        sessions:      sessions,
+        logger:        logger,
+    }
+}
+
+func (h *AdminHandler) CreateAPIKey(c *gin.Context) {
+    var req models.CreateAPIKeyRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondBindingError(c, err)
+        return
+    }
+
+    key, rawKey, err := h.apiKeyService.CreateKey(c.Request.Context(), &req)
+    if err != nil {
+        h.logger.Errorf("Failed to create API key: %v", err)
+        response.Error(c, http.StatusInternalServerError, "Internal server error")
+        return
+    }
+
+    recordAdminAction(c, h.auditService, h.logger, &models.AdminAuditEntry{
+        Action:     models.AdminActionCreateAPIKey,
+        TargetType: models.AuditTargetAPIKey,
+        TargetID:   key.ID.String(),
+    }, nil, key)
+
+    response.JSON(c, http.StatusCreated, models.CreateAPIKeyResponse{
+        APIKey: key,
+        Key:    rawKey,
+    })
+}
+
+func (h *AdminHandler) ListAPIKeys(c *gin.Context) {
+    keys, err := h.apiKeyService.ListKeys(c.Request.Context())
+    if err != nil {
+        h.logger.Errorf("Failed to list API keys: %v", err)
+        response.Error(c, http.StatusInternalServerError, "Internal server error")
+        return
+    }
+
+    response.JSON(c, http.StatusOK, gin.H{"api_keys": keys})
+}
+
+func (h *AdminHandler) GetAPIKeyUsage(c *gin.Context) {
+    keyID, err := uuid.Parse(c.Param("id"))
+    if err != nil {
+        response.Error(c, http.StatusBadRequest, "Invalid API key ID")
+        return
+    }
+
+    key, err := h.apiKeyService.GetKey(c.Request.Context(), keyID)
+    if err != nil {
+        respondError(c, h.logger, err, "Failed to get API key")
+        return
+    }
+
+    usage, err := h.apiKeyService.GetUsage(c.Request.Context(), key)
+    if err != nil {
+        h.logger.Errorf("Failed to get API key usage: %v", err)
+        response.Error(c, http.StatusInternalServerError, "Internal server error")
+        return
+    }
+
+    response.JSON(c, http.StatusOK, usage)
+}
+
+func (h *AdminHandler) RevokeAPIKey(c *gin.Context) {
+    keyID, err := uuid.Parse(c.Param("id"))
+    if err != nil {
+        response.Error(c, http.StatusBadRequest, "Invalid API key ID")
+        return
+    }
+
+    before, err := h.apiKeyService.GetKey(c.Request.Context(), keyID)
+    if err != nil {
+        respondError(c, h.logger, err, "Failed to get API key")
+        return
+    }
+
+    if err := h.apiKeyService.RevokeKey(c.Request.Context(), keyID); err != nil {
+        respondError(c, h.logger, err, "Failed to revoke API key")
+        return
+    }
+
+    after, err := h.apiKeyService.GetKey(c.Request.Context(), keyID)
+    if err != nil {
+        h.logger.Errorf("Failed to get revoked API key: %v", err)
+    }
+
+    recordAdminAction(c, h.auditService, h.logger, &models.AdminAuditEntry{
+        Action:     models.AdminActionRevokeAPIKey,
+        TargetType: models.AuditTargetAPIKey,
+        TargetID:   keyID.String(),
+    }, before, after)
+
+    response.JSON(c, http.StatusOK, gin.H{"message": "API key revoked successfully"})
+}
+
+// ListAuditLog returns admin audit entries, optionally filtered by the acting
+// admin (admin_id) and the affected user (target_user_id).
+func (h *AdminHandler) ListAuditLog(c *gin.Context) {
+    var filter models.AdminAuditFilter
+
+    if v := c.Query("admin_id"); v != "" {
+        id, err := uuid.Parse(v)
+        if err != nil {
+            response.Error(c, http.StatusBadRequest, "Invalid admin ID")
+            return
+        }
+        filter.AdminID = &id
+    }
+
+    if v := c.Query("target_user_id"); v != "" {
+        id, err := uuid.Parse(v)
+        if err != nil {
+            response.Error(c, http.StatusBadRequest, "Invalid target user ID")
+            return
+        }
+        filter.TargetUserID = &id
+    }
+
+    if v := c.Query("limit"); v != "" {
+        limit, err := strconv.Atoi(v)
+        if err != nil || limit < 1 {
+            response.Error(c, http.StatusBadRequest, "Invalid limit")
+            return
+        }
+        filter.Limit = limit
+    }
+
+    if response.WantsCSV(c) {
+        h.exportAuditLog(c, filter)
+        return
+    }
+
+    entries, err := h.auditService.List(c.Request.Context(), filter)
+    if err != nil {
+        h.logger.Errorf("Failed to list admin audit entries: %v", err)
+        response.Error(c, http.StatusInternalServerError, "Internal server error")
+        return
+    }
+
+    response.JSON(c, http.StatusOK, gin.H{"entries": entries})
+}
+
+// exportAuditLog streams matching audit entries as CSV. Unlike the JSON
+// listing, an export has no limit unless one is given.
+func (h *AdminHandler) exportAuditLog(c *gin.Context, filter models.AdminAuditFilter) {
+    w, err := response.CSV(c, "admin-audit.csv", []string{
+        "id", "admin_id", "action", "target_type", "target_id", "target_user_id", "before", "after",
+        "ip", "user_agent", "created_at",
+    })
+    if err != nil {
+        h.logger.Errorf("Failed to start admin audit export: %v", err)
+        return
+    }
+
+    err = h.auditService.Each(c.Request.Context(), filter, func(e *models.AdminAuditEntry) error {
+        targetUserID := ""
+        if e.TargetUserID != nil {
+            targetUserID = e.TargetUserID.String()
+        }
+        return w.Write([]string{
+            e.ID.String(), e.AdminID.String(), e.Action, e.TargetType, e.TargetID, targetUserID,
+            string(e.Before), string(e.After), e.IP, e.UserAgent, e.CreatedAt.UTC().Format(time.RFC3339),
+        })
+    })
+    if err == nil {
+        err = w.Flush()
+    }
+    if err != nil {
+        h.logger.Errorf("Failed to export admin audit entries: %v", err)
+    }
+}
+
+// GetFunnelStats returns daily login funnel counts between from and to
+// (YYYY-MM-DD, inclusive). The range defaults to the last 30 days.
+func (h *AdminHandler) GetFunnelStats(c *gin.Context) {
+    from, to, ok := statsRange(c)
+    if !ok {
+        return
+    }
+
+    stats, err := h.funnelService.DailyStats(c.Request.Context(), from, to)
+    if err != nil {
+        h.logger.Errorf("Failed to get funnel stats: %v", err)
+        response.Error(c, http.StatusInternalServerError, "Internal server error")
+        return
+    }
+
+    response.JSON(c, http.StatusOK, gin.H{"stats": stats})
+}
+
+// GetLoginFailureStats returns daily counts of failed logins by reason
+// between from and to (YYYY-MM-DD, inclusive). The range defaults to the
+// last 30 days.
+func (h *AdminHandler) GetLoginFailureStats(c *gin.Context) {
+    from, to, ok := statsRange(c)
+    if !ok {
+        return
+    }
+
+    stats, err := h.loginFailures.DailyStats(c.Request.Context(), from, to)
+    if err != nil {
+        h.logger.Errorf("Failed to get login failure stats: %v", err)
+        response.Error(c, http.StatusInternalServerError, "Internal server error")
+        return
+    }
+
+    response.JSON(c, http.StatusOK, gin.H{"stats": stats})
+}
+
+// statsRange reads the from and to dates of a stats query, responding with
+// 400 and returning false if either is malformed.
+func statsRange(c *gin.Context) (time.Time, time.Time, bool) {
+    to := time.Now().UTC()
+    from := to.AddDate(0, 0, -30)
+
+    if v := c.Query("from"); v != "" {
+        t, err := time.Parse("2006-01-02", v)
+        if err != nil {
+            response.Error(c, http.StatusBadRequest, "Invalid from date")
+            return time.Time{}, time.Time{}, false
+        }
+        from = t
+    }
+
+    if v := c.Query("to"); v != "" {
+        t, err := time.Parse("2006-01-02", v)
+        if err != nil {
+            response.Error(c, http.StatusBadRequest, "Invalid to date")
+            return time.Time{}, time.Time{}, false
+        }
+        to = t
+    }
+
+    return from, to, true
+}
+
+func (h *AdminHandler) ListIPBans(c *gin.Context) {
+    bans, err := h.ipBanService.List(c.Request.Context())
+    if err != nil {
+        h.logger.Errorf("Failed to list ip bans: %v", err)
+        response.Error(c, http.StatusInternalServerError, "Internal server error")
+        return
+    }
+
+    response.JSON(c, http.StatusOK, gin.H{"ip_bans": bans})
+}
+
+func (h *AdminHandler) CreateIPBan(c *gin.Context) {
+    var req models.CreateIPBanRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondBindingError(c, err)
+        return
+    }
+
+    ban, err := h.ipBanService.Ban(c.Request.Context(), req.CIDR, req.Reason, models.IPBanSourceAdmin, time.Duration(req.TTLSeconds)*time.Second)
+    if err != nil {
+        respondError(c, h.logger, err, "Failed to create ip ban")
+        return
+    }
+
+    recordAdminAction(c, h.auditService, h.logger, &models.AdminAuditEntry{
+        Action:     models.AdminActionCreateIPBan,
+        TargetType: models.AuditTargetIPBan,
+        TargetID:   ban.CIDR,
+    }, nil, ban)
+
+    response.JSON(c, http.StatusCreated, ban)
+}
+
+// DeleteIPBan lifts a ban. The range is passed as ?cidr= because CIDR
+// notation contains a slash.
+func (h *AdminHandler) DeleteIPBan(c *gin.Context) {
+    network, err := services.ParseIPOrCIDR(c.Query("cidr"))
+    if err != nil {
+        response.Error(c, http.StatusBadRequest, "Invalid IP address or CIDR range")
+        return
+    }
+
+    var before *models.IPBan
+    if bans, err := h.ipBanService.List(c.Request.Context()); err == nil {
+        for _, ban := range bans {
+            if ban.CIDR == network.String() {
+                before = ban
+            }
+        }
+    }
+
+    if err := h.ipBanService.Unban(c.Request.Context(), network.String()); err != nil {
+        respondError(c, h.logger, err, "Failed to delete ip ban")
+        return
+    }
+
+    recordAdminAction(c, h.auditService, h.logger, &models.AdminAuditEntry{
+        Action:     models.AdminActionDeleteIPBan,
+        TargetType: models.AuditTargetIPBan,
+        TargetID:   network.String(),
+    }, before, nil)
+
+    response.JSON(c, http.StatusOK, gin.H{"message": "IP ban removed successfully"})
+}
+
+func (h *AdminHandler) ListTokenFamilies(c *gin.Context) {
+    userID, err := uuid.Parse(c.Param("id"))
+    if err != nil {
+        response.Error(c, http.StatusBadRequest, "Invalid user ID")
+        return
+    }
+
+    families, err := h.lineage.ListFamilies(c.Request.Context(), userID)
+    if err != nil {
+        h.logger.Errorf("Failed to list token families: %v", err)
+        response.Error(c, http.StatusInternalServerError, "Internal server error")
+        return
+    }
+
+    response.JSON(c, http.StatusOK, gin.H{"token_families": families})
+}
+
+// ExportTokenFamily returns the full refresh token lineage of one session as
+// a downloadable JSON document for incident investigations. Exports are
+// recorded in the admin audit log.
+func (h *AdminHandler) ExportTokenFamily(c *gin.Context) {
+    familyID, err := uuid.Parse(c.Param("id"))
+    if err != nil {
+        response.Error(c, http.StatusBadRequest, "Invalid token family ID")
+        return
+    }
+
+    export, err := h.lineage.ExportFamily(c.Request.Context(), familyID)
+    if err != nil {
+        respondError(c, h.logger, err, "Failed to export token family")
+        return
+    }
+
+    recordAdminAction(c, h.auditService, h.logger, &models.AdminAuditEntry{
+        Action:       models.AdminActionExportTokenFamily,
+        TargetType:   models.AuditTargetTokenFamily,
+        TargetID:     familyID.String(),
+        TargetUserID: &export.Family.UserID,
+    }, nil, nil)
+
+    c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="token-family-%s.json"`, familyID))
+    response.JSON(c, http.StatusOK, export)
+}
+
+// SetGeoBlockExempt allows or disallows a user to log in from blocked
+// countries.
+func (h *AdminHandler) SetGeoBlockExempt(c *gin.Context) {
+    userID, err := uuid.Parse(c.Param("id"))
+    if err != nil {
+        response.Error(c, http.StatusBadRequest, "Invalid user ID")
+        return
+    }
+
+    var req models.GeoBlockExemptRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondBindingError(c, err)
+        return
+    }
+
+    before, err := h.geoBlock.GetExempt(c.Request.Context(), userID)
+    if err == nil {
+        err = h.geoBlock.SetExempt(c.Request.Context(), userID, *req.Exempt)
+    }
+    if err != nil {
+        respondError(c, h.logger, err, "Failed to set geo block exemption")
+        return
+    }
+
+    recordAdminAction(c, h.auditService, h.logger, &models.AdminAuditEntry{
+        Action:       models.AdminActionSetGeoBlockExempt,
+        TargetType:   models.AuditTargetUser,
+        TargetID:     userID.String(),
+        TargetUserID: &userID,
+    }, gin.H{"geo_block_exempt": before}, gin.H{"geo_block_exempt": *req.Exempt})
+
+    response.JSON(c, http.StatusOK, gin.H{"user_id": userID, "geo_block_exempt": *req.Exempt})
+}
+
+// SetPlan moves a user to another plan. Access tokens already issued keep
+// the old entitlements until they are refreshed.
+func (h *AdminHandler) SetPlan(c *gin.Context) {
+    userID, err := uuid.Parse(c.Param("id"))
+    if err != nil {
+        response.Error(c, http.StatusBadRequest, "Invalid user ID")
+        return
+    }
+
+    var req models.SetPlanRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondBindingError(c, err)
+        return
+    }
+
+    before, err := h.users.SetPlan(c.Request.Context(), userID, req.Plan)
+    if err != nil {
+        respondError(c, h.logger, err, "Failed to set plan")
+        return
+    }
+
+    recordAdminAction(c, h.auditService, h.logger, &models.AdminAuditEntry{
+        Action:       models.AdminActionSetPlan,
+        TargetType:   models.AuditTargetUser,
+        TargetID:     userID.String(),
+        TargetUserID: &userID,
+    }, gin.H{"plan": before}, gin.H{"plan": req.Plan})
+
+    response.JSON(c, http.StatusOK, gin.H{"user_id": userID, "entitlements": models.EntitlementsFor(req.Plan)})
+}
+
+// GetUser returns a user with their login statistics.
+func (h *AdminHandler) GetUser(c *gin.Context) {
+    userID, err := uuid.Parse(c.Param("id"))
+    if err != nil {
+        response.Error(c, http.StatusBadRequest, "Invalid user ID")
+        return
+    }
+
+    user, err := h.users.GetUserByID(c.Request.Context(), userID)
+    if err != nil {
+        respondError(c, h.logger, err, "Failed to get user")
+        return
+    }
+    stats, err := h.loginStats.Get(c.Request.Context(), userID)
+    if err != nil {
+        respondError(c, h.logger, err, "Failed to get login stats")
+        return
+    }
+
+    response.JSON(c, http.StatusOK, models.NewAdminUser(user, stats))
+}
+
+// GetBadges lists a user's badges and which of them were granted.
+func (h *AdminHandler) GetBadges(c *gin.Context) {
+    userID, err := uuid.Parse(c.Param("id"))
+    if err != nil {
+        response.Error(c, http.StatusBadRequest, "Invalid user ID")
+        return
+    }
+
+    badges, err := h.users.UserBadges(c.Request.Context(), userID)
+    if err != nil {
+        respondError(c, h.logger, err, "Failed to get badges")
+        return
+    }
+
+    response.JSON(c, http.StatusOK, badges)
+}
+
+// GrantBadge grants a user the phone_verified or venue_verified badge.
+// Access tokens already issued pick it up when they are refreshed.
+func (h *AdminHandler) GrantBadge(c *gin.Context) {
+    h.changeBadge(c, true)
+}
+
+// RevokeBadge takes a granted badge away from a user.
+func (h *AdminHandler) RevokeBadge(c *gin.Context) {
+    h.changeBadge(c, false)
+}
+
+func (h *AdminHandler) changeBadge(c *gin.Context, grant bool) {
+    userID, err := uuid.Parse(c.Param("id"))
+    if err != nil {
+        response.Error(c, http.StatusBadRequest, "Invalid user ID")
+        return
+    }
+    badge := c.Param("badge")
+
+    var changed bool
+    action := models.AdminActionGrantBadge
+    if grant {
+        admin, _ := c.Get("admin")
+        changed, err = h.users.GrantBadge(c.Request.Context(), userID, badge, admin.(*models.User).ID)
+    } else {
+        action = models.AdminActionRevokeBadge
+        changed, err = h.users.RevokeBadge(c.Request.Context(), userID, badge)
+    }
+    if err != nil {
+        respondError(c, h.logger, err, "Failed to change badge")
+        return
+    }
+
+    if changed {
+        recordAdminAction(c, h.auditService, h.logger, &models.AdminAuditEntry{
+            Action:       action,
+            TargetType:   models.AuditTargetUser,
+            TargetID:     userID.String(),
+            TargetUserID: &userID,
+        }, gin.H{badge: !grant}, gin.H{badge: grant})
+    }
+
+    badges, err := h.users.UserBadges(c.Request.Context(), userID)
+    if err != nil {
+        respondError(c, h.logger, err, "Failed to get badges")
+        return
+    }
+    response.JSON(c, http.StatusOK, badges)
+}
+
+// ListUserRedisKeys lists the Redis keys holding state about a user (cache,
+// rate limits, presence, one-time codes, device trust and login lockouts).
+func (h *AdminHandler) ListUserRedisKeys(c *gin.Context) {
+    userID, err := uuid.Parse(c.Param("id"))
+    if err != nil {
+        response.Error(c, http.StatusBadRequest, "Invalid user ID")
+        return
+    }
+
+    keys, err := h.users.ListUserState(c.Request.Context(), userID)
+    if err != nil {
+        respondError(c, h.logger, err, "Failed to list user Redis keys")
+        return
+    }
+
+    response.JSON(c, http.StatusOK, gin.H{"keys": keys})
+}
+
+// PurgeUserRedisKeys deletes a user's Redis keys, or only those in the
+// category given by the category query parameter, e.g. login_lockout to
+// unlock a user who is stuck locked out.
+func (h *AdminHandler) PurgeUserRedisKeys(c *gin.Context) {
+    userID, err := uuid.Parse(c.Param("id"))
+    if err != nil {
+        response.Error(c, http.StatusBadRequest, "Invalid user ID")
+        return
+    }
+
+    var req models.PurgeUserStateRequest
+    if err := c.ShouldBindQuery(&req); err != nil {
+        respondBindingError(c, err)
+        return
+    }
+
+    before, err := h.users.ListUserState(c.Request.Context(), userID)
+    var deleted int
+    if err == nil {
+        deleted, err = h.users.PurgeUserState(c.Request.Context(), userID, req.Category)
+    }
+    if err != nil {
+        respondError(c, h.logger, err, "Failed to purge user Redis keys")
+        return
+    }
+
+    recordAdminAction(c, h.auditService, h.logger, &models.AdminAuditEntry{
+        Action:       models.AdminActionPurgeUserState,
+        TargetType:   models.AuditTargetUser,
+        TargetID:     userID.String(),
+        TargetUserID: &userID,
+    }, gin.H{"keys": before}, gin.H{"category": req.Category, "deleted": deleted})
+
+    response.JSON(c, http.StatusOK, gin.H{"deleted": deleted})
+}
+
+func (h *AdminHandler) GetRateLimitPolicy(c *gin.Context) {
+    policy, err := h.rateLimits.Get(c.Request.Context())
+    if err != nil {
+        h.logger.Errorf("Failed to get rate limit policy: %v", err)
+        response.Error(c, http.StatusInternalServerError, "Internal server error")
+        return
+    }
+
+    response.JSON(c, http.StatusOK, policy)
+}
+
+// SetRateLimitPolicy replaces the per-role rate limit overrides. Every
+// instance picks the change up within a few seconds.
+func (h *AdminHandler) SetRateLimitPolicy(c *gin.Context) {
+    var req models.RateLimitPolicy
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondBindingError(c, err)
+        return
+    }
+
+    before, err := h.rateLimits.Get(c.Request.Context())
+    if err != nil {
+        h.logger.Errorf("Failed to get rate limit policy: %v", err)
+        response.Error(c, http.StatusInternalServerError, "Internal server error")
+        return
+    }
+
+    policy, err := h.rateLimits.Set(c.Request.Context(), &req)
+    if err != nil {
+        h.logger.Errorf("Failed to set rate limit policy: %v", err)
+        response.Error(c, http.StatusInternalServerError, "Internal server error")
+        return
+    }
+
+    recordAdminAction(c, h.auditService, h.logger, &models.AdminAuditEntry{
+        Action:     models.AdminActionSetRateLimitPolicy,
+        TargetType: models.AuditTargetRateLimitPolicy,
+        TargetID:   "rate_limit_policy",
+    }, before, policy)
+
+    response.JSON(c, http.StatusOK, policy)
+}
+
+// RevokeSessions revokes every session matching the given criteria, e.g.
+// all sessions of a compromised client build. Dry runs report what would be
+// revoked and aren't audited.
+func (h *AdminHandler) RevokeSessions(c *gin.Context) {
+    var req models.RevokeSessionsRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondBindingError(c, err)
+        return
+    }
+
+    result, err := h.sessions.RevokeSessions(c.Request.Context(), &req)
+    if err != nil {
+        respondError(c, h.logger, err, "Failed to revoke sessions")
+        return
+    }
+
+    if !req.DryRun {
+        recordAdminAction(c, h.auditService, h.logger, &models.AdminAuditEntry{
+            Action:     models.AdminActionRevokeSessions,
+            TargetType: models.AuditTargetSessions,
+            TargetID:   "bulk",
+        }, nil, gin.H{"criteria": req, "result": result})
+    }
+
+    response.JSON(c, http.StatusOK, result)
+}
@@ -1,20 +1,42 @@
 package handlers
 
 import (
+    "errors"
     "net/http"
+    "strconv"
+    "time"
 
+    "auth-service/internal/apperr"
+    "auth-service/internal/metrics"
     "auth-service/internal/models"
+    "auth-service/internal/response"
     "auth-service/internal/services"
+    "auth-service/internal/validation"
 
     "github.com/gin-gonic/gin"
+    "github.com/google/uuid"
     "go.uber.org/zap"
 )
 
+const usernameSuggestionCount = 3
+
+// errInvalidRefreshToken answers refresh tokens that are unknown or expired,
+// which only the refresh endpoints treat as failed authentication
+var errInvalidRefreshToken = apperr.New(apperr.Unauthenticated, "Invalid refresh token", nil)
+
 type AuthHandler struct {
     authService  *services.AuthService
     userService  *services.UserService
     tokenService *services.TokenService
     logger       *zap.SugaredLogger
+
+    // Optional collaborators, see the Set methods
+    handleService *services.HandleService
+    funnelService *services.FunnelService
+    onboarding    *services.OnboardingService
+    refreshGuard  *services.RefreshGuard
+    loginGuard    *services.LoginGuard
+    geoBlock      *services.GeoBlockService
 }
 
 func NewAuthHandler(authService *services.AuthService, userService *services.UserService, tokenService *services.TokenService, logger *zap.SugaredLogger) *AuthHandler {
@@ -26,82 +48,571 @@ func NewAuthHandler(authService *services.AuthService, userService *services.Use
     }
 }
 
+// SetHandles suggests free usernames when a registration's is taken and
+// enables guest logins, which are refused without it.
+func (h *AuthHandler) SetHandles(handleService *services.HandleService) {
+    h.handleService = handleService
+}
+
+// SetFunnel records the login funnel steps reached through the handler.
+func (h *AuthHandler) SetFunnel(funnelService *services.FunnelService) {
+    h.funnelService = funnelService
+}
+
+// SetOnboarding starts onboarding for new accounts and moves it along once
+// their email is verified.
+func (h *AuthHandler) SetOnboarding(onboarding *services.OnboardingService) {
+    h.onboarding = onboarding
+}
+
+// SetLoginGuard locks out password and MFA guessing.
+func (h *AuthHandler) SetLoginGuard(loginGuard *services.LoginGuard) {
+    h.loginGuard = loginGuard
+}
+
+// SetRefreshGuard throttles refresh token guessing.
+func (h *AuthHandler) SetRefreshGuard(refreshGuard *services.RefreshGuard) {
+    h.refreshGuard = refreshGuard
+}
+
+// SetGeoBlock refuses registrations and logins from blocked countries and
+// picks the data region of new accounts from the client's country.
+func (h *AuthHandler) SetGeoBlock(geoBlock *services.GeoBlockService) {
+    h.geoBlock = geoBlock
+}
+
 func (h *AuthHandler) Register(c *gin.Context) {
     var req models.RegisterRequest
     if err := c.ShouldBindJSON(&req); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        respondBindingError(c, err)
+        return
+    }
+
+    if h.rejectBlockedCountry(c, "") {
         return
     }
 
-    user, err := h.authService.Register(c.Request.Context(), &req)
+    h.funnelService.Record(c.Request.Context(), metrics.StepRegisterStarted, metrics.ClientType(c.GetHeader("X-Client-Type")), nil)
+
+    user, err := h.authService.Register(c.Request.Context(), &req, h.dataRegion(c))
     if err != nil {
-        switch err {
-        case services.ErrEmailAlreadyExists:
-            c.JSON(http.StatusConflict, gin.H{"error": "Email already exists"})
-        case services.ErrUsernameAlreadyExists:
-            c.JSON(http.StatusConflict, gin.H{"error": "Username already exists"})
-        default:
-            h.logger.Errorf("Failed to register user: %v", err)
-            c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+        if errors.Is(err, services.ErrUsernameAlreadyExists) && h.handleService != nil {
+            suggestions, suggestErr := h.handleService.Suggest(c.Request.Context(), req.Username, usernameSuggestionCount)
+            if suggestErr != nil {
+                h.logger.Errorf("Failed to suggest usernames: %v", suggestErr)
+            }
+            err = services.ErrUsernameAlreadyExists.WithDetails(gin.H{"suggestions": suggestions})
         }
+        respondError(c, h.logger, err, "Failed to register user")
         return
     }
 
-    c.JSON(http.StatusCreated, user)
+    h.onboarding.Start(c.Request.Context(), user)
+    if user.EmailVerified {
+        h.funnelService.Record(c.Request.Context(), metrics.StepEmailVerified, metrics.ClientType(c.GetHeader("X-Client-Type")), &user.ID)
+        h.onboarding.Verified(c.Request.Context(), user.ID)
+    }
+
+    response.JSON(c, http.StatusCreated, models.NewPrivateUser(user))
+}
+
+// StartRegistration emails the code needed to register with an address,
+// when registration email codes are enabled. The response is the same
+// whether or not the address already has an account.
+func (h *AuthHandler) StartRegistration(c *gin.Context) {
+    if !h.authService.RegistrationCodeRequired() {
+        response.Error(c, http.StatusNotFound, "Not found")
+        return
+    }
+
+    var req models.StartRegistrationRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondBindingError(c, err)
+        return
+    }
+
+    if h.rejectBlockedCountry(c, "") {
+        return
+    }
+
+    if err := h.authService.StartRegistration(c.Request.Context(), req.Email); err != nil {
+        respondError(c, h.logger, err, "Failed to start registration")
+        return
+    }
+
+    response.JSON(c, http.StatusAccepted, gin.H{"message": "If the email can be registered, a code has been sent"})
 }
 
 func (h *AuthHandler) Login(c *gin.Context) {
     var req models.LoginRequest
     if err := c.ShouldBindJSON(&req); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        respondBindingError(c, err)
+        return
+    }
+
+    if h.rejectBlockedCountry(c, req.Email) {
         return
     }
 
     userAgent := c.GetHeader("User-Agent")
     ip := c.ClientIP()
 
-    user, session, err := h.authService.Login(c.Request.Context(), &req, userAgent, ip)
+    // Reject locked accounts and IPs up front; fail open if Redis is unavailable
+    status, err := h.loginGuard.Check(c.Request.Context(), ip, req.Email)
+    if errors.Is(err, services.ErrLoginLocked) {
+        h.authService.RecordLoginFailure(c.Request.Context(), metrics.LoginFailureLocked, nil, userAgent, ip, metrics.ClientType(c.GetHeader("X-Client-Type")))
+        respondLoginLocked(c, status)
+        return
+    } else if err != nil {
+        h.logger.Errorf("Failed to check login lockout: %v", err)
+    }
+
+    user, session, err := h.authService.Login(c.Request.Context(), &req, userAgent, ip, metrics.ClientType(c.GetHeader("X-Client-Type")))
+    var challengeErr *services.ChallengeRequiredError
+    if errors.As(err, &challengeErr) {
+        // A wrong password behind a captcha still counts as a failure, but
+        // the client is only told once the captcha is solved
+        if challengeErr.CredentialsInvalid {
+            if _, err := h.loginGuard.RecordFailure(c.Request.Context(), ip, req.Email); err != nil {
+                h.logger.Errorf("Failed to record login failure: %v", err)
+            }
+        }
+        respondChallenge(c, challengeErr.Challenge)
+        return
+    }
+    if err != nil {
+        switch {
+        case errors.Is(err, services.ErrInvalidCredentials):
+            status, err := h.loginGuard.RecordFailure(c.Request.Context(), ip, req.Email)
+            if err != nil {
+                h.logger.Errorf("Failed to record login failure: %v", err)
+                response.Error(c, http.StatusUnauthorized, "Invalid credentials")
+            } else if status.LockedFor > 0 {
+                respondLoginLocked(c, status)
+            } else {
+                response.ErrorWithDetails(c, http.StatusUnauthorized, "Invalid credentials", gin.H{
+                    "attempts_remaining": status.AttemptsRemaining,
+                })
+            }
+        case errors.Is(err, services.ErrLoginConfirmationRequired):
+            // The password was right, so this isn't a failed attempt
+            if err := h.loginGuard.Reset(c.Request.Context(), req.Email); err != nil {
+                h.logger.Errorf("Failed to reset login failures: %v", err)
+            }
+            response.JSON(c, http.StatusAccepted, gin.H{
+                "message":               "Travel mode is on; confirm this login from the link sent to your email",
+                "confirmation_required": true,
+            })
+        default:
+            respondError(c, h.logger, err, "Failed to login")
+        }
+        return
+    }
+
+    if err := h.loginGuard.Reset(c.Request.Context(), req.Email); err != nil {
+        h.logger.Errorf("Failed to reset login failures: %v", err)
+    }
+
+    // Login returns the user as it was before this login was recorded
+    if user.LastLogin == nil {
+        h.funnelService.Record(c.Request.Context(), metrics.StepFirstLogin, metrics.ClientType(c.GetHeader("X-Client-Type")), &user.ID)
+    }
+
+    h.respondLogin(c, user, session)
+}
+
+// ConfirmLogin completes a login that travel mode held back for email
+// confirmation.
+func (h *AuthHandler) ConfirmLogin(c *gin.Context) {
+    var req models.ConfirmLoginRequest
+
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondBindingError(c, err)
+        return
+    }
+
+    user, session, err := h.authService.ConfirmLogin(c.Request.Context(), req.Token, c.GetHeader("User-Agent"), c.ClientIP(), metrics.ClientType(c.GetHeader("X-Client-Type")))
+    if err != nil {
+        respondError(c, h.logger, err, "Failed to confirm login")
+        return
+    }
+
+    h.respondLogin(c, user, session)
+}
+
+// AnswerChallenge answers the current challenge of a challenged login. The
+// response is the next challenge, or tokens once all are passed.
+func (h *AuthHandler) AnswerChallenge(c *gin.Context) {
+    challengeType := c.Param("type")
+    switch challengeType {
+    case models.ChallengeCaptcha, models.ChallengeEmailCode, models.ChallengeTOTP, models.ChallengeTOS:
+    default:
+        response.Error(c, http.StatusNotFound, "Unknown challenge type")
+        return
+    }
+
+    var req models.ChallengeAnswer
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondBindingError(c, err)
+        return
+    }
+
+    clientType := metrics.ClientType(c.GetHeader("X-Client-Type"))
+    user, session, next, err := h.authService.AnswerChallenge(c.Request.Context(), req.ChallengeToken, challengeType, req.Response, c.GetHeader("User-Agent"), c.ClientIP(), clientType)
+    var failedErr *services.ChallengeFailedError
+    if errors.As(err, &failedErr) && services.IsMFAChallenge(challengeType) && h.recordChallengeFailure(c, failedErr.Email) {
+        return
+    }
+    if err != nil {
+        switch {
+        case errors.Is(err, services.ErrInvalidToken):
+            err = apperr.New(apperr.Invalid, "Invalid or expired challenge token", err)
+        case errors.Is(err, services.ErrChallengeOutOfOrder):
+            err = services.ErrChallengeOutOfOrder.WithDetails(gin.H{"challenges": next.Challenges})
+        }
+        respondError(c, h.logger, err, "Failed to answer login challenge")
+        return
+    }
+
+    if next != nil {
+        respondChallenge(c, next)
+        return
+    }
+
+    if err := h.loginGuard.Reset(c.Request.Context(), user.Email); err != nil {
+        h.logger.Errorf("Failed to reset login failures: %v", err)
+    }
+    if user.LastLogin == nil {
+        h.funnelService.Record(c.Request.Context(), metrics.StepFirstLogin, clientType, &user.ID)
+    }
+
+    h.respondLogin(c, user, session)
+}
+
+// recordChallengeFailure counts a wrong MFA answer against the account and
+// IP like a wrong password, so logging in again for a fresh challenge doesn't
+// reset the guessing. It reports whether that locked the login out, in which
+// case the account's outstanding challenges are abandoned and the lockout
+// has been responded.
+func (h *AuthHandler) recordChallengeFailure(c *gin.Context, email string) bool {
+    status, err := h.loginGuard.RecordFailure(c.Request.Context(), c.ClientIP(), email)
+    if err != nil {
+        h.logger.Errorf("Failed to record login failure: %v", err)
+        return false
+    }
+    if status.LockedFor == 0 {
+        return false
+    }
+
+    if err := h.authService.AbandonChallenges(c.Request.Context(), email); err != nil {
+        h.logger.Errorf("Failed to abandon login challenges: %v", err)
+    }
+    respondLoginLocked(c, status)
+    return true
+}
+
+// EnrollTOTP starts TOTP enrollment and returns the secret to add to an
+// authenticator app. TOTP is on once ConfirmTOTP accepts a code.
+func (h *AuthHandler) EnrollTOTP(c *gin.Context) {
+    claims, _ := c.Get("claims")
+    tokenClaims := claims.(*services.TokenClaims)
+
+    enrollment, err := h.authService.StartTOTPEnrollment(c.Request.Context(), tokenClaims.UserID)
+    if err != nil {
+        respondError(c, h.logger, err, "Failed to start TOTP enrollment")
+        return
+    }
+
+    response.JSON(c, http.StatusOK, enrollment)
+}
+
+func (h *AuthHandler) ConfirmTOTP(c *gin.Context) {
+    claims, _ := c.Get("claims")
+    tokenClaims := claims.(*services.TokenClaims)
+
+    var req models.TOTPCodeRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondBindingError(c, err)
+        return
+    }
+
+    if err := h.authService.ConfirmTOTPEnrollment(c.Request.Context(), tokenClaims.UserID, req.Code); err != nil {
+        if errors.Is(err, services.ErrTOTPNotEnabled) {
+            err = apperr.New(apperr.Invalid, "Start TOTP enrollment first", err)
+        }
+        respondError(c, h.logger, err, "Failed to confirm TOTP enrollment")
+        return
+    }
+
+    h.funnelService.Record(c.Request.Context(), metrics.StepMFAEnrolled, metrics.ClientType(c.GetHeader("X-Client-Type")), &tokenClaims.UserID)
+
+    response.JSON(c, http.StatusOK, gin.H{"message": "TOTP enabled"})
+}
+
+func (h *AuthHandler) DisableTOTP(c *gin.Context) {
+    claims, _ := c.Get("claims")
+    tokenClaims := claims.(*services.TokenClaims)
+
+    var req models.TOTPCodeRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondBindingError(c, err)
+        return
+    }
+
+    if err := h.authService.DisableTOTP(c.Request.Context(), tokenClaims.UserID, req.Code); err != nil {
+        respondError(c, h.logger, err, "Failed to disable TOTP")
+        return
+    }
+
+    response.JSON(c, http.StatusOK, gin.H{"message": "TOTP disabled"})
+}
+
+// Sudo elevates the current access token after re-checking the password
+// (and TOTP code, when enabled), unlocking routes behind RequireSudo for a
+// few minutes.
+func (h *AuthHandler) Sudo(c *gin.Context) {
+    claims, _ := c.Get("claims")
+    tokenClaims := claims.(*services.TokenClaims)
+
+    var req models.SudoRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondBindingError(c, err)
+        return
+    }
+
+    expiresAt, err := h.authService.ElevateSession(c.Request.Context(), tokenClaims.UserID, tokenClaims.ID, req.Password, req.Code)
     if err != nil {
-        if err == services.ErrInvalidCredentials {
-            c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
-        } else {
-            h.logger.Errorf("Failed to login: %v", err)
-            c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+        // Both are answered 401, as the password and code authenticate
+        switch {
+        case errors.Is(err, services.ErrInvalidCredentials):
+            err = apperr.New(apperr.Unauthenticated, "Invalid password", err)
+        case errors.Is(err, services.ErrInvalidTOTPCode):
+            err = apperr.New(apperr.Unauthenticated, "Invalid code", err)
         }
+        respondError(c, h.logger, err, "Failed to elevate session")
         return
     }
 
-    // Generate access token
-    accessToken, expiresAt, err := h.tokenService.GenerateToken(user.ID, user.Email, user.Username)
+    response.JSON(c, http.StatusOK, gin.H{"sudo_until": expiresAt})
+}
+
+// profileComplete reports whether the user has filled in every required
+// profile field, for the access token's profile_complete claim. If that
+// can't be checked the user isn't prompted.
+func (h *AuthHandler) profileComplete(c *gin.Context, userID uuid.UUID) bool {
+    completion, err := h.userService.ProfileCompletion(c.Request.Context(), userID)
+    if err != nil {
+        h.logger.Errorf("Failed to check profile completion: %v", err)
+        return true
+    }
+    return completion.Complete
+}
+
+// entitlements returns the limits of the user's plan for the access token's
+// ent claim. If the plan can't be loaded the token gets the free plan's.
+func (h *AuthHandler) entitlements(c *gin.Context, userID uuid.UUID) models.Entitlements {
+    entitlements, err := h.userService.Entitlements(c.Request.Context(), userID)
+    if err != nil {
+        h.logger.Errorf("Failed to load entitlements: %v", err)
+        return models.EntitlementsFor(models.PlanFree)
+    }
+    return entitlements
+}
+
+// badges returns the user's badges for the access token's badges claim. If
+// they can't be loaded the token has none.
+func (h *AuthHandler) badges(c *gin.Context, userID uuid.UUID) []string {
+    badges, err := h.userService.Badges(c.Request.Context(), userID)
+    if err != nil {
+        h.logger.Errorf("Failed to load badges: %v", err)
+        return nil
+    }
+    return badges
+}
+
+// respondLogin issues an access token for a new session.
+func (h *AuthHandler) respondLogin(c *gin.Context, user *models.User, session *models.Session) {
+    accessToken, expiresAt, err := h.tokenService.GenerateToken(user.ID, session.ID, user.Email, user.Username, h.profileComplete(c, user.ID), h.entitlements(c, user.ID), h.badges(c, user.ID), user.DataRegion)
     if err != nil {
         h.logger.Errorf("Failed to generate token: %v", err)
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+        response.Error(c, http.StatusInternalServerError, "Internal server error")
         return
     }
 
-    c.JSON(http.StatusOK, models.TokenResponse{
+    response.JSON(c, http.StatusOK, tokenResponse(accessToken, expiresAt, h.tokenService.Now(), session))
+}
+
+// tokenResponse describes an access token issued for session at now, the
+// token service's time.
+func tokenResponse(accessToken string, expiresAt, now time.Time, session *models.Session) models.TokenResponse {
+    return models.TokenResponse{
         AccessToken:  accessToken,
         RefreshToken: session.RefreshToken,
+        TokenType:    models.TokenType,
         ExpiresAt:    expiresAt,
+        ExpiresIn:    int64(expiresAt.Sub(now).Round(time.Second) / time.Second),
+        SessionID:    session.ID,
+        Device: models.SessionDevice{
+            UserAgent:  session.UserAgent,
+            IP:         session.IP,
+            ClientType: session.ClientType,
+            Region:     session.Region,
+        },
+        EvictedSessions: session.Evicted,
+    }
+}
+
+// respondChallenge hands a challenged login's token and remaining
+// challenges to the client, which answers them in order at
+// /auth/challenge/:type.
+func respondChallenge(c *gin.Context, challenge *models.LoginChallenge) {
+    response.JSON(c, http.StatusAccepted, gin.H{
+        "message":            "Additional verification required",
+        "challenge_required": true,
+        "challenge_token":    challenge.Token,
+        "challenges":         challenge.Challenges,
+        "expires_at":         challenge.ExpiresAt,
+    })
+}
+
+// respondLoginLocked reports a lockout with the seconds left until the next
+// attempt is accepted.
+func respondLoginLocked(c *gin.Context, status services.LoginStatus) {
+    seconds := int(status.LockedFor.Seconds()) + 1
+    c.Header("Retry-After", strconv.Itoa(seconds))
+    response.ErrorWithDetails(c, http.StatusTooManyRequests, "Too many failed login attempts", gin.H{
+        "lockout_seconds":    seconds,
+        "attempts_remaining": 0,
+    })
+}
+
+// dataRegion picks the data region of an account registering from c, from
+// the X-Data-Region client hint or the client's country.
+func (h *AuthHandler) dataRegion(c *gin.Context) string {
+    country := ""
+    if h.geoBlock != nil {
+        country = h.geoBlock.Country(c.ClientIP(), c.GetHeader(h.geoBlock.Header()))
+    }
+    return h.authService.DataRegion(c.GetHeader("X-Data-Region"), country)
+}
+
+// rejectBlockedCountry responds with 451 and returns true if the client is in
+// a blocked country. For logins, email names the account whose exemption is
+// honored; registrations pass "" and are never exempt.
+func (h *AuthHandler) rejectBlockedCountry(c *gin.Context, email string) bool {
+    if h.geoBlock == nil || !h.geoBlock.Enabled() {
+        return false
+    }
+
+    country := h.geoBlock.Country(c.ClientIP(), c.GetHeader(h.geoBlock.Header()))
+    if !h.geoBlock.IsBlocked(country) {
+        return false
+    }
+
+    if email != "" {
+        exempt, err := h.geoBlock.IsExempt(c.Request.Context(), email)
+        if err != nil {
+            h.logger.Errorf("Failed to check geo block exemption: %v", err)
+        }
+        if exempt {
+            return false
+        }
+    }
+
+    response.Error(c, http.StatusUnavailableForLegalReasons, "Service is not available in your region")
+    return true
+}
+
+func (h *AuthHandler) GuestLogin(c *gin.Context) {
+    if h.rejectBlockedCountry(c, "") {
+        return
+    }
+
+    if h.handleService == nil {
+        response.Error(c, http.StatusNotFound, "Guest accounts are not available")
+        return
+    }
+
+    handle, err := h.handleService.GenerateGuest(c.Request.Context())
+    if err != nil {
+        h.logger.Errorf("Failed to generate guest handle: %v", err)
+        response.Error(c, http.StatusInternalServerError, "Internal server error")
+        return
+    }
+
+    user, session, err := h.authService.RegisterGuest(c.Request.Context(), handle, c.GetHeader("User-Agent"), c.ClientIP(), metrics.ClientType(c.GetHeader("X-Client-Type")), h.dataRegion(c))
+    if err != nil {
+        respondError(c, h.logger, err, "Failed to register guest")
+        return
+    }
+
+    accessToken, expiresAt, err := h.tokenService.GenerateToken(user.ID, session.ID, user.Email, user.Username, h.profileComplete(c, user.ID), h.entitlements(c, user.ID), h.badges(c, user.ID), user.DataRegion)
+    if err != nil {
+        h.logger.Errorf("Failed to generate token: %v", err)
+        response.Error(c, http.StatusInternalServerError, "Internal server error")
+        return
+    }
+
+    response.JSON(c, http.StatusCreated, tokenResponse(accessToken, expiresAt, h.tokenService.Now(), session))
+}
+
+// IssueScheduledToken pre-issues an access token for a user that becomes valid
+// at not_before. It is served to internal callers only.
+func (h *AuthHandler) IssueScheduledToken(c *gin.Context) {
+    var req models.ScheduledTokenRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondBindingError(c, err)
+        return
+    }
+
+    user, err := h.userService.GetUserByID(c.Request.Context(), req.UserID)
+    if err != nil {
+        respondError(c, h.logger, err, "Failed to get user")
+        return
+    }
+
+    validFor := time.Duration(req.ValidForSeconds) * time.Second
+    accessToken, expiresAt, err := h.tokenService.GenerateScheduledToken(user.ID, user.Email, user.Username, req.NotBefore, validFor)
+    if err != nil {
+        respondError(c, h.logger, err, "Failed to generate scheduled token")
+        return
+    }
+
+    response.JSON(c, http.StatusCreated, models.ScheduledTokenResponse{
+        AccessToken: accessToken,
+        NotBefore:   req.NotBefore,
+        ExpiresAt:   expiresAt,
     })
 }
 
 func (h *AuthHandler) RefreshToken(c *gin.Context) {
     var req models.RefreshRequest
     if err := c.ShouldBindJSON(&req); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        respondBindingError(c, err)
+        return
+    }
+
+    ip := c.ClientIP()
+
+    // Throttle refresh token guessing; fail open if Redis is unavailable
+    wait, err := h.refreshGuard.Check(c.Request.Context(), ip, req.RefreshToken)
+    if errors.Is(err, services.ErrRefreshThrottled) {
+        respondError(c, h.logger, services.ErrRefreshThrottled.WithRetryAfter(wait+time.Second), "Failed to check refresh throttle")
         return
+    } else if err != nil {
+        h.logger.Errorf("Failed to check refresh throttle: %v", err)
     }
 
-    // Get session
-    session, err := h.authService.GetSessionByRefreshToken(c.Request.Context(), req.RefreshToken)
+    // Rotate the refresh token
+    session, err := h.authService.RotateRefreshToken(c.Request.Context(), req.RefreshToken, c.GetHeader("User-Agent"), ip)
     if err != nil {
-        if err == services.ErrInvalidToken {
-            c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
-        } else {
-            h.logger.Errorf("Failed to get session: %v", err)
-            c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+        if errors.Is(err, services.ErrInvalidToken) {
+            if err := h.refreshGuard.RecordFailure(c.Request.Context(), ip, req.RefreshToken); err != nil {
+                h.logger.Errorf("Failed to record refresh failure: %v", err)
+            }
+            err = errInvalidRefreshToken
         }
+        respondError(c, h.logger, err, "Failed to rotate refresh token")
         return
     }
 
@@ -109,22 +620,77 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
     user, err := h.userService.GetUserByID(c.Request.Context(), session.UserID)
     if err != nil {
         h.logger.Errorf("Failed to get user: %v", err)
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+        response.Error(c, http.StatusInternalServerError, "Internal server error")
         return
     }
 
     // Generate new access token
-    accessToken, expiresAt, err := h.tokenService.GenerateToken(user.ID, user.Email, user.Username)
+    accessToken, expiresAt, err := h.tokenService.GenerateToken(user.ID, session.ID, user.Email, user.Username, h.profileComplete(c, user.ID), h.entitlements(c, user.ID), h.badges(c, user.ID), user.DataRegion)
     if err != nil {
         h.logger.Errorf("Failed to generate token: %v", err)
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+        response.Error(c, http.StatusInternalServerError, "Internal server error")
         return
     }
 
-    c.JSON(http.StatusOK, models.TokenResponse{
-        AccessToken:  accessToken,
-        RefreshToken: session.RefreshToken,
-        ExpiresAt:    expiresAt,
+    body := tokenResponse(accessToken, expiresAt, h.tokenService.Now(), session)
+    body.Rotation = &models.RefreshRotation{
+        SessionID:            session.ID,
+        SessionExpiresAt:     session.ExpiresAt,
+        PreviousTokenRevoked: true,
+    }
+    response.JSON(c, http.StatusOK, body)
+}
+
+// ViewOnlyToken trades a recently expired refresh token for a read-only access
+// token. The session stays expired; any write needs a fresh login.
+func (h *AuthHandler) ViewOnlyToken(c *gin.Context) {
+    var req models.RefreshRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondBindingError(c, err)
+        return
+    }
+
+    ip := c.ClientIP()
+
+    // Shares the refresh guard, as both endpoints accept refresh tokens
+    wait, err := h.refreshGuard.Check(c.Request.Context(), ip, req.RefreshToken)
+    if errors.Is(err, services.ErrRefreshThrottled) {
+        respondError(c, h.logger, services.ErrRefreshThrottled.WithRetryAfter(wait+time.Second), "Failed to check refresh throttle")
+        return
+    } else if err != nil {
+        h.logger.Errorf("Failed to check refresh throttle: %v", err)
+    }
+
+    session, err := h.authService.GetRecentlyExpiredSession(c.Request.Context(), req.RefreshToken)
+    if err != nil {
+        if errors.Is(err, services.ErrInvalidToken) {
+            if err := h.refreshGuard.RecordFailure(c.Request.Context(), ip, req.RefreshToken); err != nil {
+                h.logger.Errorf("Failed to record refresh failure: %v", err)
+            }
+            err = errInvalidRefreshToken
+        }
+        respondError(c, h.logger, err, "Failed to get expired session")
+        return
+    }
+
+    user, err := h.userService.GetUserByID(c.Request.Context(), session.UserID)
+    if err != nil {
+        h.logger.Errorf("Failed to get user: %v", err)
+        response.Error(c, http.StatusInternalServerError, "Internal server error")
+        return
+    }
+
+    accessToken, expiresAt, err := h.tokenService.GenerateViewOnlyToken(user.ID, user.Email, user.Username)
+    if err != nil {
+        h.logger.Errorf("Failed to generate token: %v", err)
+        response.Error(c, http.StatusInternalServerError, "Internal server error")
+        return
+    }
+
+    response.JSON(c, http.StatusOK, models.ViewOnlyTokenResponse{
+        AccessToken: accessToken,
+        Scope:       services.ScopeViewOnly,
+        ExpiresAt:   expiresAt,
     })
 }
 
@@ -140,41 +706,56 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 
     // Delete all user sessions if requested
     if c.Query("all") == "true" {
-        if err := h.authService.DeleteAllUserSessions(c.Request.Context(), tokenClaims.UserID); err != nil {
+        if err := h.authService.DeleteAllUserSessions(c.Request.Context(), tokenClaims.UserID, models.LogoutReasonLogoutAll); err != nil {
             h.logger.Errorf("Failed to delete sessions: %v", err)
         }
     }
 
-    c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+    response.JSON(c, http.StatusOK, gin.H{"message": "Logged out successfully"})
 }
 
 func (h *AuthHandler) VerifyEmail(c *gin.Context) {
     token := c.Query("token")
     if token == "" {
-        c.JSON(http.StatusBadRequest, gin.H{"error": "Token is required"})
+        response.Error(c, http.StatusBadRequest, "Token is required")
         return
     }
 
-    if err := h.authService.VerifyEmail(c.Request.Context(), token); err != nil {
-        if err == services.ErrInvalidToken {
-            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
-        } else {
-            h.logger.Errorf("Failed to verify email: %v", err)
-            c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
-        }
+    userID, firstTime, err := h.authService.VerifyEmail(c.Request.Context(), token)
+    if err != nil {
+        respondError(c, h.logger, err, "Failed to verify email")
         return
     }
 
-    c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+    if firstTime {
+        h.funnelService.Record(c.Request.Context(), metrics.StepEmailVerified, metrics.ClientType(c.GetHeader("X-Client-Type")), &userID)
+        h.onboarding.Verified(c.Request.Context(), userID)
+    }
+
+    response.JSON(c, http.StatusOK, gin.H{"message": "Email verified successfully"})
 }
 
-func (h *AuthHandler) ForgotPassword(c *gin.Context) {
-    var req struct {
-        Email string `json:"email" binding:"required,email"`
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+    var req models.EmailRequest
+
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondBindingError(c, err)
+        return
     }
 
+    if err := h.authService.ResendVerification(c.Request.Context(), req.Email); err != nil {
+        h.logger.Errorf("Failed to resend verification email: %v", err)
+    }
+
+    // Always return success to prevent email enumeration
+    response.JSON(c, http.StatusOK, gin.H{"message": "If the email needs verification, a new link has been sent"})
+}
+
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+    var req models.EmailRequest
+
     if err := c.ShouldBindJSON(&req); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        respondBindingError(c, err)
         return
     }
 
@@ -183,29 +764,27 @@ func (h *AuthHandler) ForgotPassword(c *gin.Context) {
     }
 
     // Always return success to prevent email enumeration
-    c.JSON(http.StatusOK, gin.H{"message": "If the email exists, a reset link has been sent"})
+    response.JSON(c, http.StatusOK, gin.H{"message": "If the email exists, a reset link has been sent"})
 }
 
 func (h *AuthHandler) ResetPassword(c *gin.Context) {
-    var req struct {
-        Token    string `json:"token" binding:"required"`
-        Password string `json:"password" binding:"required,min=8"`
-    }
+    var req models.ResetPasswordRequest
 
     if err := c.ShouldBindJSON(&req); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        respondBindingError(c, err)
         return
     }
 
     if err := h.authService.ResetPassword(c.Request.Context(), req.Token, req.Password); err != nil {
-        if err == services.ErrInvalidToken {
-            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
-        } else {
-            h.logger.Errorf("Failed to reset password: %v", err)
-            c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
-        }
+        respondError(c, h.logger, err, "Failed to reset password")
         return
     }
 
-    c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully"})
-}
\ No newline at end of file
+    response.JSON(c, http.StatusOK, gin.H{"message": "Password reset successfully"})
+}
+
+// ValidationRules reports the active length limits of usernames, passwords
+// and display names, so clients can check input before submitting it.
+func (h *AuthHandler) ValidationRules(c *gin.Context) {
+    response.JSON(c, http.StatusOK, validation.ActiveLimits())
+}
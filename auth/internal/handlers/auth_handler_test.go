@@ -7,18 +7,25 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"auth-service/internal/models"
 	"auth-service/internal/services"
+	"auth-service/internal/store"
+	"auth-service/internal/validation"
 	"auth-service/test"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 func setupTestRouter(authHandler *AuthHandler, userHandler *UserHandler, tokenService *services.TokenService) *gin.Engine {
 	gin.SetMode(gin.TestMode)
+	if err := validation.Setup(validation.DefaultLimits); err != nil {
+		panic(err)
+	}
 	router := gin.New()
 
 	v1 := router.Group("/api/v1")
@@ -27,6 +34,7 @@ func setupTestRouter(authHandler *AuthHandler, userHandler *UserHandler, tokenSe
 		{
 			auth.POST("/register", authHandler.Register)
 			auth.POST("/login", authHandler.Login)
+			auth.POST("/challenge/:type", authHandler.AnswerChallenge)
 			auth.POST("/refresh", authHandler.RefreshToken)
 			auth.POST("/verify-email", authHandler.VerifyEmail)
 			auth.POST("/forgot-password", authHandler.ForgotPassword)
@@ -37,16 +45,32 @@ func setupTestRouter(authHandler *AuthHandler, userHandler *UserHandler, tokenSe
 	return router
 }
 
+// newTestHandlers builds the auth and user handlers with all of their
+// collaborators on the suite's database and Redis.
+func newTestHandlers(t *testing.T, suite *test.TestSuite) (*AuthHandler, *UserHandler, *services.TokenService) {
+	authService := services.NewAuthService(suite.DB.DB, suite.Redis.Client, suite.Config, suite.Logger, suite.Events)
+	tokenService := services.NewTokenService(test.Keyring(t, suite.Config.JWTSecret), suite.Config.JWTExpiry, store.NewRedis(suite.Redis.Client), suite.Logger)
+	userService := services.NewUserService(suite.DB.DB, suite.Redis.Client, suite.Logger)
+	onboarding := services.NewOnboardingService(suite.DB.DB, suite.Events, suite.Logger)
+
+	authHandler := NewAuthHandler(authService, userService, tokenService, suite.Logger)
+	authHandler.SetHandles(services.NewHandleService(suite.DB.DB, suite.Logger))
+	authHandler.SetFunnel(services.NewFunnelService(suite.DB.DB, suite.Logger))
+	authHandler.SetOnboarding(onboarding)
+	authHandler.SetLoginGuard(services.NewLoginGuard(suite.Redis.Client, suite.Logger))
+	authHandler.SetRefreshGuard(services.NewRefreshGuard(suite.Redis.Client, services.NewIPBanService(suite.Redis.Client, suite.Logger), suite.Logger))
+
+	accountDeletion := services.NewAccountDeletionService(suite.DB.DB, userService, services.NewWebhookService(suite.DB.DB, suite.Logger), suite.Events, suite.Logger)
+	userHandler := NewUserHandler(userService, accountDeletion, onboarding, suite.Logger)
+
+	return authHandler, userHandler, tokenService
+}
+
 func TestAuthHandler_Register(t *testing.T) {
 	suite := test.NewTestSuite(t)
 	defer suite.Cleanup(t)
 
-	authService := services.NewAuthService(suite.DB.DB, suite.Redis.Client, suite.Config, suite.Logger)
-	tokenService := services.NewTokenService(suite.Config.JWTSecret, suite.Config.JWTExpiry, suite.Redis.Client, suite.Logger)
-	userService := services.NewUserService(suite.DB.DB, suite.Logger)
-
-	authHandler := NewAuthHandler(authService, userService, tokenService, suite.Logger)
-	userHandler := NewUserHandler(userService, suite.Logger)
+	authHandler, userHandler, tokenService := newTestHandlers(t, suite)
 
 	router := setupTestRouter(authHandler, userHandler, tokenService)
 
@@ -127,7 +151,7 @@ func TestAuthHandler_Register(t *testing.T) {
 			assert.Equal(t, tt.expectedStatus, w.Code)
 
 			if tt.expectUser {
-				var user models.User
+				var user models.PrivateUser
 				err = json.Unmarshal(w.Body.Bytes(), &user)
 				require.NoError(t, err)
 				assert.NotZero(t, user.ID)
@@ -142,12 +166,7 @@ func TestAuthHandler_Login(t *testing.T) {
 	suite := test.NewTestSuite(t)
 	defer suite.Cleanup(t)
 
-	authService := services.NewAuthService(suite.DB.DB, suite.Redis.Client, suite.Config, suite.Logger)
-	tokenService := services.NewTokenService(suite.Config.JWTSecret, suite.Config.JWTExpiry, suite.Redis.Client, suite.Logger)
-	userService := services.NewUserService(suite.DB.DB, suite.Logger)
-
-	authHandler := NewAuthHandler(authService, userService, tokenService, suite.Logger)
-	userHandler := NewUserHandler(userService, suite.Logger)
+	authHandler, userHandler, tokenService := newTestHandlers(t, suite)
 
 	router := setupTestRouter(authHandler, userHandler, tokenService)
 
@@ -219,21 +238,74 @@ func TestAuthHandler_Login(t *testing.T) {
 				assert.NotEmpty(t, tokenResponse.AccessToken)
 				assert.NotEmpty(t, tokenResponse.RefreshToken)
 				assert.NotZero(t, tokenResponse.ExpiresAt)
+				assert.Equal(t, models.TokenType, tokenResponse.TokenType)
+				assert.InDelta(t, suite.Config.JWTExpiry.Seconds(), tokenResponse.ExpiresIn, 1)
+				assert.NotEqual(t, uuid.Nil, tokenResponse.SessionID)
+				assert.NotEmpty(t, tokenResponse.Device.IP)
 			}
 		})
 	}
 }
 
-func TestAuthHandler_RefreshToken(t *testing.T) {
+func TestAuthHandler_ChallengeFailuresLockLogin(t *testing.T) {
 	suite := test.NewTestSuite(t)
 	defer suite.Cleanup(t)
 
-	authService := services.NewAuthService(suite.DB.DB, suite.Redis.Client, suite.Config, suite.Logger)
-	tokenService := services.NewTokenService(suite.Config.JWTSecret, suite.Config.JWTExpiry, suite.Redis.Client, suite.Logger)
-	userService := services.NewUserService(suite.DB.DB, suite.Logger)
+	authHandler, userHandler, tokenService := newTestHandlers(t, suite)
 
-	authHandler := NewAuthHandler(authService, userService, tokenService, suite.Logger)
-	userHandler := NewUserHandler(userService, suite.Logger)
+	router := setupTestRouter(authHandler, userHandler, tokenService)
+
+	testUser := suite.CreateTestUser(t, test.TestData.ValidEmail, test.TestData.ValidUsername, test.TestData.ValidPassword)
+	_, err := suite.DB.Pool().Exec(context.Background(),
+		"UPDATE users SET totp_secret = 'JBSWY3DPEHPK3PXP', totp_enabled_at = NOW() WHERE id = $1", testUser.ID,
+	)
+	require.NoError(t, err)
+
+	post := func(path string, payload interface{}) *httptest.ResponseRecorder {
+		body, err := json.Marshal(payload)
+		require.NoError(t, err)
+		req, err := http.NewRequest("POST", path, bytes.NewBuffer(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	login := func() *httptest.ResponseRecorder {
+		return post("/api/v1/auth/login", models.LoginRequest{Email: testUser.Email, Password: test.TestData.ValidPassword})
+	}
+	challengeToken := func() string {
+		w := login()
+		require.Equal(t, http.StatusAccepted, w.Code)
+		var body struct {
+			ChallengeToken string `json:"challenge_token"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return body.ChallengeToken
+	}
+	guess := func(token string) int {
+		return post("/api/v1/auth/challenge/totp", models.ChallengeAnswer{ChallengeToken: token, Response: "000000"}).Code
+	}
+
+	// Wrong codes count across logins, so a fresh challenge doesn't reset them
+	first := challengeToken()
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusUnauthorized, guess(first))
+	}
+	second := challengeToken()
+	assert.Equal(t, http.StatusUnauthorized, guess(second))
+	assert.Equal(t, http.StatusTooManyRequests, guess(second))
+
+	// The account is locked and its outstanding challenges are gone
+	assert.Equal(t, http.StatusTooManyRequests, login().Code)
+	assert.Equal(t, http.StatusBadRequest, guess(first))
+}
+
+func TestAuthHandler_RefreshToken(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	authHandler, userHandler, tokenService := newTestHandlers(t, suite)
 
 	router := setupTestRouter(authHandler, userHandler, tokenService)
 
@@ -300,23 +372,20 @@ func TestAuthHandler_VerifyEmail(t *testing.T) {
 	suite := test.NewTestSuite(t)
 	defer suite.Cleanup(t)
 
-	authService := services.NewAuthService(suite.DB.DB, suite.Redis.Client, suite.Config, suite.Logger)
-	tokenService := services.NewTokenService(suite.Config.JWTSecret, suite.Config.JWTExpiry, suite.Redis.Client, suite.Logger)
-	userService := services.NewUserService(suite.DB.DB, suite.Logger)
-
-	authHandler := NewAuthHandler(authService, userService, tokenService, suite.Logger)
-	userHandler := NewUserHandler(userService, suite.Logger)
+	authHandler, userHandler, tokenService := newTestHandlers(t, suite)
 
 	router := setupTestRouter(authHandler, userHandler, tokenService)
 
 	// Create unverified user with email token
 	emailToken := "test-email-token"
-	_, err := suite.DB.Pool().Exec(context.Background(),
-		`INSERT INTO users (email, username, password_hash, email_verified, email_token)
-		 VALUES ($1, $2, $3, false, $4)`,
-		"unverified@example.com", "unverified", "hashedpass", emailToken,
-	)
+	var userID uuid.UUID
+	err := suite.DB.Pool().QueryRow(context.Background(),
+		`INSERT INTO users (email, username, password_hash, email_verified)
+		 VALUES ($1, $2, $3, false) RETURNING id`,
+		"unverified@example.com", "unverified", "hashedpass",
+	).Scan(&userID)
 	require.NoError(t, err)
+	suite.CreateEmailVerificationToken(t, userID, "unverified@example.com", emailToken, time.Now().Add(time.Hour))
 
 	tests := []struct {
 		name           string
@@ -362,12 +431,7 @@ func TestAuthHandler_ForgotPassword(t *testing.T) {
 	suite := test.NewTestSuite(t)
 	defer suite.Cleanup(t)
 
-	authService := services.NewAuthService(suite.DB.DB, suite.Redis.Client, suite.Config, suite.Logger)
-	tokenService := services.NewTokenService(suite.Config.JWTSecret, suite.Config.JWTExpiry, suite.Redis.Client, suite.Logger)
-	userService := services.NewUserService(suite.DB.DB, suite.Logger)
-
-	authHandler := NewAuthHandler(authService, userService, tokenService, suite.Logger)
-	userHandler := NewUserHandler(userService, suite.Logger)
+	authHandler, userHandler, tokenService := newTestHandlers(t, suite)
 
 	router := setupTestRouter(authHandler, userHandler, tokenService)
 
@@ -426,12 +490,7 @@ func TestAuthHandler_ResetPassword(t *testing.T) {
 	suite := test.NewTestSuite(t)
 	defer suite.Cleanup(t)
 
-	authService := services.NewAuthService(suite.DB.DB, suite.Redis.Client, suite.Config, suite.Logger)
-	tokenService := services.NewTokenService(suite.Config.JWTSecret, suite.Config.JWTExpiry, suite.Redis.Client, suite.Logger)
-	userService := services.NewUserService(suite.DB.DB, suite.Logger)
-
-	authHandler := NewAuthHandler(authService, userService, tokenService, suite.Logger)
-	userHandler := NewUserHandler(userService, suite.Logger)
+	authHandler, userHandler, tokenService := newTestHandlers(t, suite)
 
 	router := setupTestRouter(authHandler, userHandler, tokenService)
 
@@ -496,4 +555,12 @@ func TestAuthHandler_ResetPassword(t *testing.T) {
 			assert.Equal(t, tt.expectedStatus, w.Code)
 		})
 	}
-}
\ No newline at end of file
+}
+func TestTokenResponse_ExpiresInFollowsClock(t *testing.T) {
+	// A travelled clock is far from the system time
+	now := time.Now().Add(48 * time.Hour)
+	session := &models.Session{ID: uuid.New(), RefreshToken: "refresh"}
+
+	body := tokenResponse("access", now.Add(15*time.Minute), now, session)
+	assert.Equal(t, int64(15*60), body.ExpiresIn)
+}
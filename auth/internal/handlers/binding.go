@@ -0,0 +1,17 @@
+package handlers
+
+import (
+    "net/http"
+
+    "auth-service/internal/response"
+    "auth-service/internal/validation"
+
+    "github.com/gin-gonic/gin"
+)
+
+// respondBindingError reports a ShouldBind* failure as localized, per-field
+// errors instead of the raw validator message.
+func respondBindingError(c *gin.Context, err error) {
+    message, fields := validation.Translate(err, c.GetHeader("Accept-Language"))
+    response.ErrorWithDetails(c, http.StatusBadRequest, message, gin.H{"fields": fields})
+}
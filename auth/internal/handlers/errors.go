@@ -0,0 +1,37 @@
+package handlers
+
+import (
+    "net/http"
+    "strconv"
+
+    "auth-service/internal/apperr"
+    "auth-service/internal/response"
+
+    "github.com/gin-gonic/gin"
+    "go.uber.org/zap"
+)
+
+// respondError answers a failed service call. An *apperr.Error in err's
+// chain is answered with its status, message, code and details; anything
+// else is logged with action, e.g. "Failed to get user", and answered 500.
+func respondError(c *gin.Context, logger *zap.SugaredLogger, err error, action string) {
+    if respondContentDenied(c, err) {
+        return
+    }
+
+    appErr, ok := apperr.As(err)
+    if !ok || appErr.Status() == http.StatusInternalServerError {
+        logger.Errorf("%s: %v", action, err)
+        response.Error(c, http.StatusInternalServerError, "Internal server error")
+        return
+    }
+
+    if appErr.RetryAfter > 0 {
+        c.Header("Retry-After", strconv.Itoa(int(appErr.RetryAfter.Seconds())))
+    }
+    details := gin.H{"code": appErr.Code}
+    for k, v := range appErr.Details {
+        details[k] = v
+    }
+    response.ErrorWithDetails(c, appErr.Status(), appErr.Message, details)
+}
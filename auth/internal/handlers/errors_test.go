@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"auth-service/internal/apperr"
+	"auth-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func respondErrorTo(t *testing.T, path string, err error) (*httptest.ResponseRecorder, map[string]interface{}) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("GET", path, nil)
+
+	respondError(c, zap.NewNop().Sugar(), err, "Failed to test")
+
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	return w, body
+}
+
+func TestRespondError_MapsServiceErrors(t *testing.T) {
+	w, body := respondErrorTo(t, "/api/v1/users/me", fmt.Errorf("load: %w", services.ErrUserNotFound))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "User not found", body["error"])
+	assert.Equal(t, string(apperr.NotFound), body["code"])
+
+	w, body = respondErrorTo(t, "/api/v2/users/me", services.ErrTOTPRequired)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	errBody := body["error"].(map[string]interface{})
+	assert.Equal(t, "TOTP code required", errBody["message"])
+	assert.Equal(t, true, errBody["details"].(map[string]interface{})["totp_required"])
+}
+
+func TestRespondError_RetryAfter(t *testing.T) {
+	w, _ := respondErrorTo(t, "/api/v1/auth/refresh", services.ErrRefreshThrottled.WithRetryAfter(30*time.Second))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "30", w.Header().Get("Retry-After"))
+}
+
+func TestRespondError_HidesUnknownErrors(t *testing.T) {
+	w, body := respondErrorTo(t, "/api/v1/users/me", errors.New("connection refused"))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, "Internal server error", body["error"])
+	assert.NotContains(t, body, "code")
+}
+
+func TestRespondError_ContentDenied(t *testing.T) {
+	w, body := respondErrorTo(t, "/api/v1/users/me", &services.ContentDeniedError{Field: "username"})
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, "username", body["field"])
+}
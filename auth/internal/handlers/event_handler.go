@@ -0,0 +1,45 @@
+package handlers
+
+import (
+    "net/http"
+
+    "auth-service/internal/models"
+    "auth-service/internal/response"
+    "auth-service/internal/services"
+
+    "github.com/gin-gonic/gin"
+    "go.uber.org/zap"
+)
+
+// EventHandler serves the event journal to internal consumers.
+type EventHandler struct {
+    journal *services.EventJournal
+    logger  *zap.SugaredLogger
+}
+
+func NewEventHandler(journal *services.EventJournal, logger *zap.SugaredLogger) *EventHandler {
+    return &EventHandler{
+        journal: journal,
+        logger:  logger,
+    }
+}
+
+// ListEvents pages through journaled events after the given ID, oldest
+// first, so new consumers can backfill user state. Start with after=0 and
+// pass next_after back until has_more is false.
+func (h *EventHandler) ListEvents(c *gin.Context) {
+    var query models.JournalQuery
+    if err := c.ShouldBindQuery(&query); err != nil {
+        respondBindingError(c, err)
+        return
+    }
+
+    page, err := h.journal.List(c.Request.Context(), query)
+    if err != nil {
+        h.logger.Errorf("Failed to list journaled events: %v", err)
+        response.Error(c, http.StatusInternalServerError, "Internal server error")
+        return
+    }
+
+    response.JSON(c, http.StatusOK, page)
+}
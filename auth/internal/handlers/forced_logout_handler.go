@@ -0,0 +1,102 @@
+package handlers
+
+import (
+    "context"
+    "net/http"
+    "sync"
+    "time"
+
+    "auth-service/internal/response"
+    "auth-service/internal/services"
+
+    "github.com/gin-gonic/gin"
+    "github.com/google/uuid"
+    "go.uber.org/zap"
+)
+
+// Idle streams get a comment this often so proxies don't time them out
+const forcedLogoutHeartbeat = 15 * time.Second
+
+// ForcedLogoutHandler bridges forced logout notifications to internal
+// services that can't subscribe to Redis themselves.
+type ForcedLogoutHandler struct {
+    forcedLogouts *services.ForcedLogoutService
+    logger        *zap.SugaredLogger
+
+    // Closed on shutdown to end open streams, which would otherwise hold
+    // the server open
+    done      chan struct{}
+    closeOnce sync.Once
+}
+
+func NewForcedLogoutHandler(forcedLogouts *services.ForcedLogoutService, logger *zap.SugaredLogger) *ForcedLogoutHandler {
+    return &ForcedLogoutHandler{
+        forcedLogouts: forcedLogouts,
+        logger:        logger,
+        done:          make(chan struct{}),
+    }
+}
+
+// Close ends every open stream.
+func (h *ForcedLogoutHandler) Close() {
+    h.closeOnce.Do(func() { close(h.done) })
+}
+
+// Stream sends forced logouts as server-sent events named forced_logout
+// until the caller disconnects. user_id, which may be repeated, limits the
+// stream to those users. Logouts published while the caller is not
+// connected are not replayed.
+func (h *ForcedLogoutHandler) Stream(c *gin.Context) {
+    var userIDs []uuid.UUID
+    for _, raw := range c.QueryArray("user_id") {
+        userID, err := uuid.Parse(raw)
+        if err != nil {
+            response.Error(c, http.StatusBadRequest, "Invalid user ID")
+            return
+        }
+        userIDs = append(userIDs, userID)
+    }
+
+    ctx, cancel := context.WithCancel(c.Request.Context())
+    defer cancel()
+    go func() {
+        select {
+        case <-h.done:
+            cancel()
+        case <-ctx.Done():
+        }
+    }()
+
+    logouts, err := h.forcedLogouts.Subscribe(ctx, userIDs)
+    if err != nil {
+        h.logger.Errorf("Failed to subscribe to forced logouts: %v", err)
+        response.Error(c, http.StatusServiceUnavailable, "Forced logout notifications are unavailable")
+        return
+    }
+
+    c.Header("Content-Type", "text/event-stream")
+    c.Header("Cache-Control", "no-cache")
+    c.Header("X-Accel-Buffering", "no")
+    c.Status(http.StatusOK)
+    c.Writer.Flush()
+
+    heartbeat := time.NewTicker(forcedLogoutHeartbeat)
+    defer heartbeat.Stop()
+
+    for {
+        select {
+        case logout, ok := <-logouts:
+            if !ok {
+                return
+            }
+            c.SSEvent("forced_logout", logout)
+        case <-heartbeat.C:
+            if _, err := c.Writer.WriteString(": ping\n\n"); err != nil {
+                return
+            }
+        case <-ctx.Done():
+            return
+        }
+        c.Writer.Flush()
+    }
+}
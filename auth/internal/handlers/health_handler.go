@@ -0,0 +1,125 @@
+package handlers
+
+import (
+    "context"
+    "net/http"
+    "runtime/debug"
+    "time"
+
+    "auth-service/internal/database"
+    "auth-service/internal/redis"
+    "auth-service/internal/response"
+
+    "github.com/gin-gonic/gin"
+)
+
+const readinessTimeout = 2 * time.Second
+
+// The version only changes on deploy, so clients polling it mostly get 304s
+const versionCacheControl = "public, max-age=60"
+
+// BuildVersion describes the running build.
+type BuildVersion struct {
+    Service     string   `json:"service"`
+    Revision    string   `json:"revision,omitempty"`
+    BuiltAt     string   `json:"built_at,omitempty"`
+    GoVersion   string   `json:"go_version"`
+    APIVersions []string `json:"api_versions"`
+}
+
+type HealthHandler struct {
+    db          *database.DB
+    redis       *redis.Client
+    // Reports whether the event broker is connected; nil without one
+    broker      func() bool
+    // Databases of data regions; nil without any
+    regions     *database.Regions
+    version     BuildVersion
+    versionETag string
+}
+
+func NewHealthHandler(db *database.DB, redis *redis.Client) *HealthHandler {
+    version := buildVersion()
+    // A BuildVersion always encodes
+    etag, _ := response.ETag(version)
+    return &HealthHandler{
+        db:          db,
+        redis:       redis,
+        version:     version,
+        versionETag: etag,
+    }
+}
+
+// SetBroker reports the event broker as degraded while available returns
+// false.
+func (h *HealthHandler) SetBroker(available func() bool) {
+    h.broker = available
+}
+
+// SetRegions reports regional databases that don't answer as degraded.
+func (h *HealthHandler) SetRegions(regions *database.Regions) {
+    h.regions = regions
+}
+
+// buildVersion reads the VCS revision stamped into the binary by go build.
+func buildVersion() BuildVersion {
+    version := BuildVersion{Service: "auth-service", APIVersions: []string{response.V1, response.V2}}
+    info, ok := debug.ReadBuildInfo()
+    if !ok {
+        return version
+    }
+
+    version.GoVersion = info.GoVersion
+    for _, setting := range info.Settings {
+        switch setting.Key {
+        case "vcs.revision":
+            version.Revision = setting.Value
+        case "vcs.time":
+            version.BuiltAt = setting.Value
+        }
+    }
+    return version
+}
+
+// Version reports the running build. Responses carry an ETag that changes
+// with the build; a matching If-None-Match gets 304.
+func (h *HealthHandler) Version(c *gin.Context) {
+    if response.NotModified(c, h.versionETag, versionCacheControl) {
+        return
+    }
+    c.JSON(http.StatusOK, h.version)
+}
+
+// Ready answers readiness probes. Postgres is required. Without Redis,
+// RabbitMQ or a regional database the service keeps serving in degraded mode
+// and stays ready, reporting "degraded", so an outage doesn't pull every
+// instance out of rotation.
+func (h *HealthHandler) Ready(c *gin.Context) {
+    ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+    defer cancel()
+
+    if err := h.db.Pool().Ping(ctx); err != nil {
+        c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "unavailable": []string{"postgres"}})
+        return
+    }
+
+    var degraded []string
+    if !h.redis.Available() {
+        degraded = append(degraded, "redis")
+    }
+    if h.broker != nil && !h.broker() {
+        degraded = append(degraded, "rabbitmq")
+    }
+    if h.regions != nil {
+        h.regions.Each(func(region string, db *database.DB) {
+            if err := db.Pool().Ping(ctx); err != nil {
+                degraded = append(degraded, "postgres:"+region)
+            }
+        })
+    }
+    if len(degraded) > 0 {
+        c.JSON(http.StatusOK, gin.H{"status": "degraded", "degraded": degraded})
+        return
+    }
+    c.JSON(http.StatusOK, gin.H{"status": "ready"})
+}
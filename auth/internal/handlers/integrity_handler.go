@@ -0,0 +1,37 @@
+package handlers
+
+import (
+    "net/http"
+
+    "auth-service/internal/response"
+    "auth-service/internal/services"
+
+    "github.com/gin-gonic/gin"
+    "go.uber.org/zap"
+)
+
+// IntegrityHandler reports data integrity anomalies to admins.
+type IntegrityHandler struct {
+    checker *services.IntegrityChecker
+    logger  *zap.SugaredLogger
+}
+
+func NewIntegrityHandler(checker *services.IntegrityChecker, logger *zap.SugaredLogger) *IntegrityHandler {
+    return &IntegrityHandler{
+        checker: checker,
+        logger:  logger,
+    }
+}
+
+// GetIntegrityReport runs the integrity checks now and reports what they
+// find. It never repairs; that is left to the scheduled job.
+func (h *IntegrityHandler) GetIntegrityReport(c *gin.Context) {
+    report, err := h.checker.Check(c.Request.Context(), false)
+    if err != nil {
+        h.logger.Errorf("Failed to check data integrity: %v", err)
+        response.Error(c, http.StatusInternalServerError, "Internal server error")
+        return
+    }
+
+    response.JSON(c, http.StatusOK, report)
+}
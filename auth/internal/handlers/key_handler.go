@@ -0,0 +1,83 @@
+package handlers
+
+import (
+    "net/http"
+    "strings"
+
+    "auth-service/internal/models"
+    "auth-service/internal/response"
+    "auth-service/internal/secrets"
+    "auth-service/internal/services"
+
+    "github.com/gin-gonic/gin"
+    "go.uber.org/zap"
+)
+
+// KeyHandler lets admins reload the JWT signing key, TLS certificate and
+// GeoIP database after rotating them.
+type KeyHandler struct {
+    keys         *secrets.Keyring
+    reloader     *services.Reloader
+    auditService *services.AdminAuditService
+    logger       *zap.SugaredLogger
+}
+
+func NewKeyHandler(keys *secrets.Keyring, reloader *services.Reloader, auditService *services.AdminAuditService, logger *zap.SugaredLogger) *KeyHandler {
+    return &KeyHandler{
+        keys:         keys,
+        reloader:     reloader,
+        auditService: auditService,
+        logger:       logger,
+    }
+}
+
+// ReloadSigningKey re-reads the JWT signing key from its file, like SIGHUP
+// does. Keys are identified by fingerprint only.
+func (h *KeyHandler) ReloadSigningKey(c *gin.Context) {
+    before := h.keys.Fingerprint()
+    rotated, err := h.keys.Reload()
+    if err != nil {
+        if err == secrets.ErrNotReloadable {
+            response.Error(c, http.StatusConflict, "Signing key is not loaded from a file")
+            return
+        }
+        h.logger.Errorf("Failed to reload signing key: %v", err)
+        response.Error(c, http.StatusInternalServerError, "Failed to reload signing key")
+        return
+    }
+    after := h.keys.Fingerprint()
+
+    if rotated {
+        h.logger.Infow("Signing key rotated", "previous", before, "current", after)
+        recordAdminAction(c, h.auditService, h.logger, &models.AdminAuditEntry{
+            Action:     models.AdminActionReloadSigningKey,
+            TargetType: models.AuditTargetSigningKey,
+            TargetID:   after,
+        }, gin.H{"fingerprint": before}, gin.H{"fingerprint": after})
+    }
+
+    response.JSON(c, http.StatusOK, gin.H{"rotated": rotated, "fingerprint": after})
+}
+
+// Reload re-reads every resource loaded from a file, like SIGHUP does.
+// Returns 200 with the per-resource results even when some failed, since the
+// others were still swapped.
+func (h *KeyHandler) Reload(c *gin.Context) {
+    results := h.reloader.Reload()
+
+    var changed []string
+    for _, result := range results {
+        if result.Changed {
+            changed = append(changed, result.Name)
+        }
+    }
+    if len(changed) > 0 {
+        recordAdminAction(c, h.auditService, h.logger, &models.AdminAuditEntry{
+            Action:     models.AdminActionReload,
+            TargetType: models.AuditTargetReloadable,
+            TargetID:   strings.Join(changed, ","),
+        }, nil, results)
+    }
+
+    response.JSON(c, http.StatusOK, gin.H{"results": results})
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+    "context"
+    "errors"
+    "net/http"
+
+    "auth-service/internal/models"
+    "auth-service/internal/response"
+    "auth-service/internal/services"
+
+    "github.com/gin-gonic/gin"
+    "github.com/google/uuid"
+    "go.uber.org/zap"
+)
+
+// ModerationHandler serves the record of content denied by moderation, and
+// the queue of suspected impersonations, to admins.
+type ModerationHandler struct {
+    moderation   *services.ModerationService
+    auditService *services.AdminAuditService
+    logger       *zap.SugaredLogger
+}
+
+func NewModerationHandler(moderation *services.ModerationService, auditService *services.AdminAuditService, logger *zap.SugaredLogger) *ModerationHandler {
+    return &ModerationHandler{
+        moderation:   moderation,
+        auditService: auditService,
+        logger:       logger,
+    }
+}
+
+// ListDenials returns denied usernames and display names, newest first,
+// optionally for one user.
+func (h *ModerationHandler) ListDenials(c *gin.Context) {
+    var filter models.ModerationDenialFilter
+    if err := c.ShouldBindQuery(&filter); err != nil {
+        respondBindingError(c, err)
+        return
+    }
+
+    if v := c.Query("user_id"); v != "" {
+        id, err := uuid.Parse(v)
+        if err != nil {
+            response.Error(c, http.StatusBadRequest, "Invalid user ID")
+            return
+        }
+        filter.UserID = &id
+    }
+
+    denials, err := h.moderation.ListDenials(c.Request.Context(), filter)
+    if err != nil {
+        h.logger.Errorf("Failed to list moderation denials: %v", err)
+        response.Error(c, http.StatusInternalServerError, "Internal server error")
+        return
+    }
+
+    response.JSON(c, http.StatusOK, gin.H{"denials": denials})
+}
+
+// ListImpersonationReviews returns content flagged as resembling a
+// protected handle, oldest first, optionally with one status.
+func (h *ModerationHandler) ListImpersonationReviews(c *gin.Context) {
+    reviews, err := h.moderation.ListImpersonationReviews(c.Request.Context(), c.Query("status"))
+    if err != nil {
+        h.logger.Errorf("Failed to list impersonation reviews: %v", err)
+        response.Error(c, http.StatusInternalServerError, "Internal server error")
+        return
+    }
+
+    response.JSON(c, http.StatusOK, gin.H{"reviews": reviews})
+}
+
+func (h *ModerationHandler) DismissImpersonation(c *gin.Context) {
+    h.review(c, h.moderation.DismissImpersonationReview, models.AdminActionDismissImpersonation, "Review dismissed")
+}
+
+func (h *ModerationHandler) RevertImpersonation(c *gin.Context) {
+    h.review(c, h.moderation.RevertImpersonationReview, models.AdminActionRevertImpersonation, "Content reverted")
+}
+
+func (h *ModerationHandler) review(c *gin.Context, action func(ctx context.Context, reviewID, adminID uuid.UUID) error, auditAction, message string) {
+    reviewID, err := uuid.Parse(c.Param("id"))
+    if err != nil {
+        response.Error(c, http.StatusBadRequest, "Invalid review ID")
+        return
+    }
+
+    admin, _ := c.Get("admin")
+    adminUser := admin.(*models.User)
+
+    before, err := h.moderation.GetImpersonationReview(c.Request.Context(), reviewID)
+    if err == nil {
+        err = action(c.Request.Context(), reviewID, adminUser.ID)
+    }
+    if err != nil {
+        respondError(c, h.logger, err, "Failed to review impersonation")
+        return
+    }
+
+    after, err := h.moderation.GetImpersonationReview(c.Request.Context(), reviewID)
+    if err != nil {
+        h.logger.Errorf("Failed to get reviewed impersonation: %v", err)
+    }
+
+    recordAdminAction(c, h.auditService, h.logger, &models.AdminAuditEntry{
+        Action:       auditAction,
+        TargetType:   models.AuditTargetImpersonationReview,
+        TargetID:     reviewID.String(),
+        TargetUserID: &before.UserID,
+    }, before, after)
+
+    response.JSON(c, http.StatusOK, gin.H{"message": message})
+}
+
+// respondContentDenied answers 422 if err is a moderation denial and reports
+// whether it did.
+func respondContentDenied(c *gin.Context, err error) bool {
+    var denied *services.ContentDeniedError
+    if !errors.As(err, &denied) {
+        return false
+    }
+
+    details := gin.H{"field": denied.Field}
+    if denied.Reason != "" {
+        details["reason"] = denied.Reason
+    }
+    response.ErrorWithDetails(c, http.StatusUnprocessableEntity, "Content not allowed", details)
+    return true
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+    "net/http"
+
+    "auth-service/internal/models"
+    "auth-service/internal/response"
+    "auth-service/internal/services"
+
+    "github.com/gin-gonic/gin"
+    "github.com/google/uuid"
+    "go.uber.org/zap"
+)
+
+// PersonalTokenHandler lets users manage personal access tokens for their
+// own integrations and bots.
+type PersonalTokenHandler struct {
+    personalTokens *services.PersonalTokenService
+    logger         *zap.SugaredLogger
+}
+
+func NewPersonalTokenHandler(personalTokens *services.PersonalTokenService, logger *zap.SugaredLogger) *PersonalTokenHandler {
+    return &PersonalTokenHandler{
+        personalTokens: personalTokens,
+        logger:         logger,
+    }
+}
+
+func (h *PersonalTokenHandler) ListTokens(c *gin.Context) {
+    claims, _ := c.Get("claims")
+    tokenClaims := claims.(*services.TokenClaims)
+
+    tokens, err := h.personalTokens.List(c.Request.Context(), tokenClaims.UserID)
+    if err != nil {
+        respondError(c, h.logger, err, "Failed to list personal access tokens")
+        return
+    }
+
+    response.JSON(c, http.StatusOK, gin.H{"personal_access_tokens": tokens})
+}
+
+func (h *PersonalTokenHandler) CreateToken(c *gin.Context) {
+    claims, _ := c.Get("claims")
+    tokenClaims := claims.(*services.TokenClaims)
+
+    var req models.CreatePersonalAccessTokenRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondBindingError(c, err)
+        return
+    }
+
+    token, rawToken, err := h.personalTokens.Create(c.Request.Context(), tokenClaims.UserID, &req)
+    if err != nil {
+        respondError(c, h.logger, err, "Failed to create personal access token")
+        return
+    }
+
+    response.JSON(c, http.StatusCreated, models.CreatePersonalAccessTokenResponse{PersonalAccessToken: token, Token: rawToken})
+}
+
+func (h *PersonalTokenHandler) RevokeToken(c *gin.Context) {
+    claims, _ := c.Get("claims")
+    tokenClaims := claims.(*services.TokenClaims)
+
+    tokenID, err := uuid.Parse(c.Param("id"))
+    if err != nil {
+        response.Error(c, http.StatusBadRequest, "Invalid token ID")
+        return
+    }
+
+    if err := h.personalTokens.Revoke(c.Request.Context(), tokenClaims.UserID, tokenID); err != nil {
+        respondError(c, h.logger, err, "Failed to revoke personal access token")
+        return
+    }
+
+    response.JSON(c, http.StatusOK, gin.H{"message": "Personal access token revoked"})
+}
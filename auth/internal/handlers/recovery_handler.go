@@ -0,0 +1,158 @@
+package handlers
+
+import (
+    "context"
+    "errors"
+    "net/http"
+
+    "auth-service/internal/apperr"
+    "auth-service/internal/models"
+    "auth-service/internal/response"
+    "auth-service/internal/services"
+
+    "github.com/gin-gonic/gin"
+    "github.com/google/uuid"
+    "go.uber.org/zap"
+)
+
+type RecoveryHandler struct {
+    recoveryService *services.RecoveryService
+    auditService    *services.AdminAuditService
+    logger          *zap.SugaredLogger
+}
+
+func NewRecoveryHandler(recoveryService *services.RecoveryService, auditService *services.AdminAuditService, logger *zap.SugaredLogger) *RecoveryHandler {
+    return &RecoveryHandler{
+        recoveryService: recoveryService,
+        auditService:    auditService,
+        logger:          logger,
+    }
+}
+
+func (h *RecoveryHandler) SetRecoveryEmail(c *gin.Context) {
+    claims, _ := c.Get("claims")
+    tokenClaims := claims.(*services.TokenClaims)
+
+    var req models.RecoveryEmailRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondBindingError(c, err)
+        return
+    }
+
+    if err := h.recoveryService.SetRecoveryEmail(c.Request.Context(), tokenClaims.UserID, req.RecoveryEmail); err != nil {
+        h.logger.Errorf("Failed to set recovery email: %v", err)
+        response.Error(c, http.StatusInternalServerError, "Internal server error")
+        return
+    }
+
+    response.JSON(c, http.StatusOK, gin.H{"message": "Recovery email updated successfully"})
+}
+
+func (h *RecoveryHandler) GenerateRecoveryCodes(c *gin.Context) {
+    claims, _ := c.Get("claims")
+    tokenClaims := claims.(*services.TokenClaims)
+
+    codes, err := h.recoveryService.GenerateRecoveryCodes(c.Request.Context(), tokenClaims.UserID)
+    if err != nil {
+        h.logger.Errorf("Failed to generate recovery codes: %v", err)
+        response.Error(c, http.StatusInternalServerError, "Internal server error")
+        return
+    }
+
+    response.JSON(c, http.StatusOK, models.RecoveryCodesResponse{Codes: codes})
+}
+
+func (h *RecoveryHandler) StartRecovery(c *gin.Context) {
+    var req models.StartRecoveryRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondBindingError(c, err)
+        return
+    }
+
+    token, err := h.recoveryService.StartRecovery(c.Request.Context(), &req)
+    if err != nil {
+        // Also returned for unknown accounts, so codes can't be used to
+        // probe for them
+        if errors.Is(err, services.ErrInvalidToken) {
+            err = apperr.New(apperr.Invalid, "Invalid recovery code", err)
+        }
+        respondError(c, h.logger, err, "Failed to start recovery")
+        return
+    }
+
+    if token != "" {
+        response.JSON(c, http.StatusOK, gin.H{"recovery_token": token})
+        return
+    }
+
+    // Always return the same response to prevent account enumeration
+    response.JSON(c, http.StatusAccepted, gin.H{"message": "If the account exists, recovery instructions will be sent"})
+}
+
+func (h *RecoveryHandler) CompleteRecovery(c *gin.Context) {
+    var req models.CompleteRecoveryRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondBindingError(c, err)
+        return
+    }
+
+    if err := h.recoveryService.CompleteRecovery(c.Request.Context(), &req); err != nil {
+        respondError(c, h.logger, err, "Failed to complete recovery")
+        return
+    }
+
+    response.JSON(c, http.StatusOK, gin.H{"message": "Account recovered successfully"})
+}
+
+func (h *RecoveryHandler) ListRequests(c *gin.Context) {
+    requests, err := h.recoveryService.ListRequests(c.Request.Context(), c.Query("status"))
+    if err != nil {
+        h.logger.Errorf("Failed to list recovery requests: %v", err)
+        response.Error(c, http.StatusInternalServerError, "Internal server error")
+        return
+    }
+
+    response.JSON(c, http.StatusOK, gin.H{"recovery_requests": requests})
+}
+
+func (h *RecoveryHandler) ApproveRequest(c *gin.Context) {
+    h.review(c, h.recoveryService.ApproveRequest, models.AdminActionApproveRecovery, "Recovery request approved")
+}
+
+func (h *RecoveryHandler) RejectRequest(c *gin.Context) {
+    h.review(c, h.recoveryService.RejectRequest, models.AdminActionRejectRecovery, "Recovery request rejected")
+}
+
+func (h *RecoveryHandler) review(c *gin.Context, action func(ctx context.Context, requestID, adminID uuid.UUID) error, auditAction, message string) {
+    requestID, err := uuid.Parse(c.Param("id"))
+    if err != nil {
+        response.Error(c, http.StatusBadRequest, "Invalid recovery request ID")
+        return
+    }
+
+    admin, _ := c.Get("admin")
+    adminUser := admin.(*models.User)
+
+    before, err := h.recoveryService.GetRequest(c.Request.Context(), requestID)
+    if err == nil {
+        err = action(c.Request.Context(), requestID, adminUser.ID)
+    }
+    if err != nil {
+        respondError(c, h.logger, err, "Failed to review recovery request")
+        return
+    }
+
+    after, err := h.recoveryService.GetRequest(c.Request.Context(), requestID)
+    if err != nil {
+        h.logger.Errorf("Failed to get reviewed recovery request: %v", err)
+    }
+
+    recordAdminAction(c, h.auditService, h.logger, &models.AdminAuditEntry{
+        Action:       auditAction,
+        TargetType:   models.AuditTargetRecoveryRequest,
+        TargetID:     requestID.String(),
+        TargetUserID: &before.UserID,
+    }, before, after)
+
+    response.JSON(c, http.StatusOK, gin.H{"message": message})
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+    "net/http"
+
+    "auth-service/internal/middleware"
+    "auth-service/internal/models"
+    "auth-service/internal/validation"
+)
+
+// Schemas lists the request and response models of the endpoints, keyed as
+// middleware.Schema expects. Keep it in step with the routes and handlers;
+// endpoints missing here are simply not checked.
+var Schemas = map[string]middleware.SchemaEndpoint{
+    // Authentication
+    "POST /auth/register": {
+        Request:   models.RegisterRequest{},
+        Responses: map[int]interface{}{http.StatusCreated: models.PrivateUser{}},
+    },
+    "POST /auth/start-registration": {Request: models.StartRegistrationRequest{}},
+    "GET /auth/validation-rules": {
+        Responses: map[int]interface{}{http.StatusOK: validation.Limits{}},
+    },
+    "POST /auth/login": {
+        Request:   models.LoginRequest{},
+        Responses: map[int]interface{}{http.StatusOK: models.TokenResponse{}},
+    },
+    "POST /auth/login/confirm": {
+        Request:   models.ConfirmLoginRequest{},
+        Responses: map[int]interface{}{http.StatusOK: models.TokenResponse{}},
+    },
+    "POST /auth/challenge/:type": {
+        Request:   models.ChallengeAnswer{},
+        Responses: map[int]interface{}{http.StatusOK: models.TokenResponse{}},
+    },
+    "POST /auth/guest": {
+        Responses: map[int]interface{}{http.StatusCreated: models.TokenResponse{}},
+    },
+    "POST /auth/refresh": {
+        Request:   models.RefreshRequest{},
+        Responses: map[int]interface{}{http.StatusOK: models.TokenResponse{}},
+    },
+    "POST /auth/view-only": {
+        Request:   models.RefreshRequest{},
+        Responses: map[int]interface{}{http.StatusOK: models.ViewOnlyTokenResponse{}},
+    },
+    "POST /auth/sudo": {
+        Request:   models.SudoRequest{},
+        Responses: map[int]interface{}{http.StatusOK: models.TokenResponse{}},
+    },
+    "POST /auth/resend-verification": {Request: models.EmailRequest{}},
+    "POST /auth/forgot-password":     {Request: models.EmailRequest{}},
+    "POST /auth/reset-password":      {Request: models.ResetPasswordRequest{}},
+    "POST /auth/recovery/start":      {Request: models.StartRecoveryRequest{}},
+    "POST /auth/recovery/complete":   {Request: models.CompleteRecoveryRequest{}},
+
+    // Users
+    "GET /public/profiles/:handle": {
+        Responses: map[int]interface{}{http.StatusOK: models.PublicProfileCard{}},
+    },
+    "GET /users/me": {
+        Responses: map[int]interface{}{http.StatusOK: models.PrivateUser{}},
+    },
+    "PUT /users/me":          {Request: models.UpdateProfileRequest{}},
+    "PUT /users/me/password": {Request: models.ChangePasswordRequest{}},
+    "GET /users/me/deletion-status": {
+        Responses: map[int]interface{}{http.StatusOK: models.AccountDeletion{}},
+    },
+    "GET /users/me/onboarding": {
+        Responses: map[int]interface{}{http.StatusOK: models.OnboardingProgress{}},
+    },
+    "PUT /users/me/recovery-email": {Request: models.RecoveryEmailRequest{}},
+    "POST /users/me/recovery-codes": {
+        Responses: map[int]interface{}{http.StatusOK: models.RecoveryCodesResponse{}},
+    },
+    "POST /users/me/webhooks": {
+        Request:   models.CreateWebhookRequest{},
+        Responses: map[int]interface{}{http.StatusCreated: models.CreateWebhookResponse{}},
+    },
+    "POST /users/me/tokens": {
+        Request:   models.CreatePersonalAccessTokenRequest{},
+        Responses: map[int]interface{}{http.StatusCreated: models.CreatePersonalAccessTokenResponse{}},
+    },
+    "GET /users/me/sessions/current": {
+        Responses: map[int]interface{}{http.StatusOK: models.SessionInfo{}},
+    },
+    "PATCH /users/me/sessions/:id": {
+        Request:   models.UpdateSessionRequest{},
+        Responses: map[int]interface{}{http.StatusOK: models.SessionInfo{}},
+    },
+    "PUT /users/me/travel-mode": {Request: models.TravelModeRequest{}},
+    "POST /users/me/mfa/totp": {
+        Responses: map[int]interface{}{http.StatusOK: models.TOTPEnrollment{}},
+    },
+    "POST /users/me/mfa/totp/confirm": {Request: models.TOTPCodeRequest{}},
+    "DELETE /users/me/mfa/totp":       {Request: models.TOTPCodeRequest{}},
+    "PUT /users/me/public-profile":    {Request: models.PublicProfileRequest{}},
+    "GET /users/me/profile/completion": {
+        Responses: map[int]interface{}{http.StatusOK: models.ProfileCompletion{}},
+    },
+    "GET /users/me/security": {
+        Responses: map[int]interface{}{http.StatusOK: models.SecurityScore{}},
+    },
+
+    // Admin
+    "POST /admin/api-keys": {
+        Request:   models.CreateAPIKeyRequest{},
+        Responses: map[int]interface{}{http.StatusCreated: models.CreateAPIKeyResponse{}},
+    },
+    "GET /admin/api-keys/:id/usage": {
+        Responses: map[int]interface{}{http.StatusOK: models.APIKeyUsage{}},
+    },
+    "POST /admin/ip-bans": {
+        Request:   models.CreateIPBanRequest{},
+        Responses: map[int]interface{}{http.StatusCreated: models.IPBan{}},
+    },
+    "GET /admin/rate-limit-policy": {
+        Responses: map[int]interface{}{http.StatusOK: models.RateLimitPolicy{}},
+    },
+    "PUT /admin/rate-limit-policy": {
+        Request:   models.RateLimitPolicy{},
+        Responses: map[int]interface{}{http.StatusOK: models.RateLimitPolicy{}},
+    },
+    "POST /admin/sessions/revoke": {
+        Request:   models.RevokeSessionsRequest{},
+        Responses: map[int]interface{}{http.StatusOK: models.SessionRevocation{}},
+    },
+    "GET /admin/token-families/:id/export": {
+        Responses: map[int]interface{}{http.StatusOK: models.TokenFamilyExport{}},
+    },
+    "PUT /admin/users/:id/geo-block-exempt": {Request: models.GeoBlockExemptRequest{}},
+    "PUT /admin/users/:id/plan":             {Request: models.SetPlanRequest{}},
+    "GET /admin/users/:id": {
+        Responses: map[int]interface{}{http.StatusOK: models.AdminUser{}},
+    },
+    "GET /admin/users/:id/badges": {
+        Responses: map[int]interface{}{http.StatusOK: models.UserBadges{}},
+    },
+    "PUT /admin/users/:id/badges/:badge": {
+        Responses: map[int]interface{}{http.StatusOK: models.UserBadges{}},
+    },
+    "DELETE /admin/users/:id/badges/:badge": {
+        Responses: map[int]interface{}{http.StatusOK: models.UserBadges{}},
+    },
+    "POST /admin/users/:id/notes": {
+        Request:   models.CreateAccountNoteRequest{},
+        Responses: map[int]interface{}{http.StatusCreated: models.AccountNote{}},
+    },
+    "GET /admin/test/clock": {
+        Responses: map[int]interface{}{http.StatusOK: models.TestClock{}},
+    },
+    "POST /admin/test/clock": {
+        Request:   models.AdvanceClockRequest{},
+        Responses: map[int]interface{}{http.StatusOK: models.TestClock{}},
+    },
+    "DELETE /admin/test/clock": {
+        Responses: map[int]interface{}{http.StatusOK: models.TestClock{}},
+    },
+
+    // Internal
+    "GET /internal/users/:id": {
+        Responses: map[int]interface{}{http.StatusOK: models.PrivateUser{}},
+    },
+    "POST /internal/users/batch": {Request: models.BatchUserRequest{}},
+    "POST /internal/users/snapshots": {
+        Request:   models.BatchUserRequest{},
+        Responses: map[int]interface{}{http.StatusAccepted: models.SnapshotRepublish{}},
+    },
+    "POST /internal/tokens/scheduled": {
+        Request:   models.ScheduledTokenRequest{},
+        Responses: map[int]interface{}{http.StatusCreated: models.ScheduledTokenResponse{}},
+    },
+    "POST /internal/email-bounces": {Request: models.EmailRequest{}},
+    "GET /internal/events": {
+        Responses: map[int]interface{}{http.StatusOK: models.JournalPage{}},
+    },
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+    "net/http"
+
+    "auth-service/internal/models"
+    "auth-service/internal/response"
+    "auth-service/internal/services"
+
+    "github.com/gin-gonic/gin"
+    "go.uber.org/zap"
+)
+
+// SentEmailHandler exposes the emails captured by the sandbox mailer. Its
+// routes are only registered while EMAIL_SANDBOX is on.
+type SentEmailHandler struct {
+    sandbox      *services.SandboxMailer
+    auditService *services.AdminAuditService
+    logger       *zap.SugaredLogger
+}
+
+func NewSentEmailHandler(sandbox *services.SandboxMailer, auditService *services.AdminAuditService, logger *zap.SugaredLogger) *SentEmailHandler {
+    return &SentEmailHandler{
+        sandbox:      sandbox,
+        auditService: auditService,
+        logger:       logger,
+    }
+}
+
+// ListSentEmails returns captured emails newest first, optionally filtered
+// by recipient and template, so tests can pick up verification and reset
+// tokens.
+func (h *SentEmailHandler) ListSentEmails(c *gin.Context) {
+    var filter models.SentEmailFilter
+    if err := c.ShouldBindQuery(&filter); err != nil {
+        respondBindingError(c, err)
+        return
+    }
+
+    emails, err := h.sandbox.List(c.Request.Context(), filter)
+    if err != nil {
+        h.logger.Errorf("Failed to list sent emails: %v", err)
+        response.Error(c, http.StatusInternalServerError, "Internal server error")
+        return
+    }
+
+    response.JSON(c, http.StatusOK, gin.H{"emails": emails})
+}
+
+// ClearSentEmails deletes every captured email, e.g. between test runs.
+func (h *SentEmailHandler) ClearSentEmails(c *gin.Context) {
+    deleted, err := h.sandbox.Clear(c.Request.Context())
+    if err != nil {
+        h.logger.Errorf("Failed to clear sent emails: %v", err)
+        response.Error(c, http.StatusInternalServerError, "Internal server error")
+        return
+    }
+
+    recordAdminAction(c, h.auditService, h.logger, &models.AdminAuditEntry{
+        Action:     models.AdminActionClearSentEmails,
+        TargetType: models.AuditTargetSentEmails,
+        TargetID:   "all",
+    }, nil, gin.H{"deleted": deleted})
+
+    response.JSON(c, http.StatusOK, gin.H{"deleted": deleted})
+}
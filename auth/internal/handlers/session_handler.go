@@ -0,0 +1,121 @@
+package handlers
+
+import (
+    "net/http"
+    "strconv"
+    "time"
+
+    "auth-service/internal/models"
+    "auth-service/internal/response"
+    "auth-service/internal/services"
+
+    "github.com/gin-gonic/gin"
+    "github.com/google/uuid"
+    "go.uber.org/zap"
+)
+
+// SessionHandler lets users review their sessions, name them and mark their
+// devices as trusted.
+type SessionHandler struct {
+    authService *services.AuthService
+    logger      *zap.SugaredLogger
+}
+
+func NewSessionHandler(authService *services.AuthService, logger *zap.SugaredLogger) *SessionHandler {
+    return &SessionHandler{
+        authService: authService,
+        logger:      logger,
+    }
+}
+
+func (h *SessionHandler) ListSessions(c *gin.Context) {
+    claims, _ := c.Get("claims")
+    tokenClaims := claims.(*services.TokenClaims)
+
+    if response.WantsCSV(c) {
+        h.exportSessions(c, tokenClaims.UserID)
+        return
+    }
+
+    sessions, err := h.authService.ListSessions(c.Request.Context(), tokenClaims.UserID)
+    if err != nil {
+        h.logger.Errorf("Failed to list sessions: %v", err)
+        response.Error(c, http.StatusInternalServerError, "Internal server error")
+        return
+    }
+
+    response.JSON(c, http.StatusOK, gin.H{"sessions": sessions})
+}
+
+// CurrentSession returns the session the access token was issued for, so
+// clients can tell which of the listed sessions is their own.
+func (h *SessionHandler) CurrentSession(c *gin.Context) {
+    claims, _ := c.Get("claims")
+    tokenClaims := claims.(*services.TokenClaims)
+
+    if tokenClaims.SessionID == nil {
+        response.Error(c, http.StatusNotFound, "Token is not bound to a session")
+        return
+    }
+
+    session, err := h.authService.GetSession(c.Request.Context(), tokenClaims.UserID, *tokenClaims.SessionID)
+    if err != nil {
+        respondError(c, h.logger, err, "Failed to get session")
+        return
+    }
+
+    response.JSON(c, http.StatusOK, session)
+}
+
+// exportSessions streams the user's sessions as CSV.
+func (h *SessionHandler) exportSessions(c *gin.Context, userID uuid.UUID) {
+    w, err := response.CSV(c, "sessions.csv", []string{
+        "id", "label", "trusted", "user_agent", "ip", "client_type", "region", "created_at", "expires_at",
+    })
+    if err != nil {
+        h.logger.Errorf("Failed to start sessions export: %v", err)
+        return
+    }
+
+    err = h.authService.EachSession(c.Request.Context(), userID, func(s *models.SessionInfo) error {
+        label := ""
+        if s.Label != nil {
+            label = *s.Label
+        }
+        return w.Write([]string{
+            s.ID.String(), label, strconv.FormatBool(s.Trusted), s.UserAgent, s.IP, s.ClientType, s.Region,
+            s.CreatedAt.UTC().Format(time.RFC3339), s.ExpiresAt.UTC().Format(time.RFC3339),
+        })
+    })
+    if err == nil {
+        err = w.Flush()
+    }
+    if err != nil {
+        h.logger.Errorf("Failed to export sessions: %v", err)
+    }
+}
+
+func (h *SessionHandler) UpdateSession(c *gin.Context) {
+    claims, _ := c.Get("claims")
+    tokenClaims := claims.(*services.TokenClaims)
+
+    sessionID, err := uuid.Parse(c.Param("id"))
+    if err != nil {
+        response.Error(c, http.StatusBadRequest, "Invalid session ID")
+        return
+    }
+
+    var req models.UpdateSessionRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondBindingError(c, err)
+        return
+    }
+
+    session, err := h.authService.UpdateSession(c.Request.Context(), tokenClaims.UserID, sessionID, &req)
+    if err != nil {
+        respondError(c, h.logger, err, "Failed to update session")
+        return
+    }
+
+    response.JSON(c, http.StatusOK, session)
+}
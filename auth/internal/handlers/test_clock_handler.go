@@ -0,0 +1,78 @@
+package handlers
+
+import (
+    "net/http"
+    "time"
+
+    "auth-service/internal/clock"
+    "auth-service/internal/models"
+    "auth-service/internal/response"
+    "auth-service/internal/services"
+
+    "github.com/gin-gonic/gin"
+    "go.uber.org/zap"
+)
+
+// TestClockHandler lets end-to-end tests move the service's clock forward
+// to expire tokens, sessions and reset links. Its routes are only registered
+// while TEST_MODE is on. Moving the clock expires credentials for every
+// user, so each move is audited.
+type TestClockHandler struct {
+    clock        *clock.Travel
+    auditService *services.AdminAuditService
+    logger       *zap.SugaredLogger
+}
+
+func NewTestClockHandler(travel *clock.Travel, auditService *services.AdminAuditService, logger *zap.SugaredLogger) *TestClockHandler {
+    return &TestClockHandler{
+        clock:        travel,
+        auditService: auditService,
+        logger:       logger,
+    }
+}
+
+// GetClock returns the service's current time and how far it has travelled.
+func (h *TestClockHandler) GetClock(c *gin.Context) {
+    response.JSON(c, http.StatusOK, h.state())
+}
+
+// AdvanceClock moves the clock forward by the requested number of seconds.
+func (h *TestClockHandler) AdvanceClock(c *gin.Context) {
+    var req models.AdvanceClockRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondBindingError(c, err)
+        return
+    }
+
+    before := h.state()
+    h.clock.Advance(time.Duration(req.Seconds) * time.Second)
+    after := h.state()
+    h.audit(c, models.AdminActionAdvanceTestClock, before, after)
+
+    response.JSON(c, http.StatusOK, after)
+}
+
+// ResetClock brings the clock back to the system time, e.g. between tests.
+func (h *TestClockHandler) ResetClock(c *gin.Context) {
+    before := h.state()
+    h.clock.Reset()
+    after := h.state()
+    h.audit(c, models.AdminActionResetTestClock, before, after)
+
+    response.JSON(c, http.StatusOK, after)
+}
+
+func (h *TestClockHandler) audit(c *gin.Context, action string, before, after *models.TestClock) {
+    recordAdminAction(c, h.auditService, h.logger, &models.AdminAuditEntry{
+        Action:     action,
+        TargetType: models.AuditTargetTestClock,
+        TargetID:   "clock",
+    }, before, after)
+}
+
+func (h *TestClockHandler) state() *models.TestClock {
+    return &models.TestClock{
+        Now:           h.clock.Now(),
+        OffsetSeconds: int64(h.clock.Offset() / time.Second),
+    }
+}
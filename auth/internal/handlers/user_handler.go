@@ -1,98 +1,487 @@
 package handlers
 
 import (
+    "errors"
+    "fmt"
     "net/http"
+    "strings"
 
+    "auth-service/internal/apperr"
+    "auth-service/internal/events"
+    "auth-service/internal/models"
+    "auth-service/internal/response"
     "auth-service/internal/services"
 
     "github.com/gin-gonic/gin"
+    "github.com/google/uuid"
     "go.uber.org/zap"
 )
 
+// Largest batch accepted by ResolveUsernames, matching GetUsers
+const maxResolveUsernames = 100
+
+// Error code for a valid token whose user no longer exists
+const codeUserGone = "AUTH_USER_GONE"
+
 type UserHandler struct {
-    userService *services.UserService
-    logger      *zap.SugaredLogger
+    userService     *services.UserService
+    accountDeletion *services.AccountDeletionService
+    onboarding      *services.OnboardingService
+    logger          *zap.SugaredLogger
+
+    // Handling of tokens of users that no longer exist; see SetGoneUserResponse
+    goneUserStatus int
+    tokenService   *services.TokenService
+    publisher      services.EventPublisher
 }
 
-func NewUserHandler(userService *services.UserService, logger *zap.SugaredLogger) *UserHandler {
+func NewUserHandler(userService *services.UserService, accountDeletion *services.AccountDeletionService, onboarding *services.OnboardingService, logger *zap.SugaredLogger) *UserHandler {
     return &UserHandler{
-        userService: userService,
-        logger:      logger,
+        userService:     userService,
+        accountDeletion: accountDeletion,
+        onboarding:      onboarding,
+        logger:          logger,
+        goneUserStatus:  http.StatusUnauthorized,
     }
 }
 
+// SetGoneUserResponse sets the status returned when a valid token belongs
+// to a user that no longer exists, and has such tokens blacklisted and
+// reported as user:gone_token_used.
+func (h *UserHandler) SetGoneUserResponse(status int, tokenService *services.TokenService, publisher services.EventPublisher) {
+    h.goneUserStatus = status
+    h.tokenService = tokenService
+    h.publisher = publisher
+}
+
 func (h *UserHandler) GetCurrentUser(c *gin.Context) {
     claims, _ := c.Get("claims")
     tokenClaims := claims.(*services.TokenClaims)
 
     user, err := h.userService.GetUserByID(c.Request.Context(), tokenClaims.UserID)
     if err != nil {
-        h.logger.Errorf("Failed to get user: %v", err)
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+        if errors.Is(err, services.ErrUserNotFound) {
+            h.respondUserGone(c, tokenClaims)
+            return
+        }
+        respondError(c, h.logger, err, "Failed to get user")
+        return
+    }
+
+    response.JSON(c, http.StatusOK, models.NewPrivateUser(user))
+}
+
+// respondUserGone answers a request whose valid token belongs to a user that
+// no longer exists, e.g. one hard-deleted while the token was still live.
+// The token is blacklisted so it stops working on every route.
+func (h *UserHandler) respondUserGone(c *gin.Context, claims *services.TokenClaims) {
+    h.logger.Warnw("Token used for a user that no longer exists", "user_id", claims.UserID, "token_id", claims.ID)
+
+    if h.tokenService != nil && claims.ExpiresAt != nil {
+        if err := h.tokenService.BlacklistToken(c.Request.Context(), claims.ID, claims.ExpiresAt.Time); err != nil {
+            h.logger.Errorf("Failed to blacklist token of deleted user: %v", err)
+        }
+    }
+    if h.publisher != nil {
+        event := events.NewUserEvent(events.UserGoneTokenUsed, claims.UserID.String(), claims.Username)
+        event.Data["token_id"] = claims.ID
+        event.Data["ip"] = c.ClientIP()
+        event.Data["user_agent"] = c.Request.UserAgent()
+        if err := h.publisher.PublishUserEvent(event); err != nil {
+            h.logger.Errorf("Failed to publish gone token event: %v", err)
+        }
+    }
+
+    response.ErrorWithDetails(c, h.goneUserStatus, "User no longer exists", gin.H{"code": codeUserGone})
+}
+
+// GetUser looks up any user by ID for internal service callers.
+func (h *UserHandler) GetUser(c *gin.Context) {
+    userID, err := uuid.Parse(c.Param("id"))
+    if err != nil {
+        response.Error(c, http.StatusBadRequest, "Invalid user ID")
         return
     }
 
-    c.JSON(http.StatusOK, user)
+    user, err := h.userService.GetUserByID(c.Request.Context(), userID)
+    if err != nil {
+        respondError(c, h.logger, err, "Failed to get user")
+        return
+    }
+
+    response.JSON(c, http.StatusOK, models.NewPrivateUser(user))
+}
+
+// ResolveUsernames maps usernames to user IDs for internal callers, e.g. to
+// resolve @mentions. Names may be repeated or comma-separated:
+// ?username=alice&username=bob or ?username=alice,bob.
+func (h *UserHandler) ResolveUsernames(c *gin.Context) {
+    var usernames []string
+    for _, value := range c.QueryArray("username") {
+        for _, username := range strings.Split(value, ",") {
+            if username = strings.TrimSpace(username); username != "" {
+                usernames = append(usernames, username)
+            }
+        }
+    }
+    if len(usernames) == 0 {
+        response.Error(c, http.StatusBadRequest, "At least one username is required")
+        return
+    }
+    if len(usernames) > maxResolveUsernames {
+        response.Error(c, http.StatusBadRequest, fmt.Sprintf("At most %d usernames can be resolved at once", maxResolveUsernames))
+        return
+    }
+
+    resolved, err := h.userService.ResolveUsernames(c.Request.Context(), usernames)
+    if err != nil {
+        h.logger.Errorf("Failed to resolve usernames: %v", err)
+        response.Error(c, http.StatusInternalServerError, "Internal server error")
+        return
+    }
+
+    unknown := []string{}
+    seen := make(map[string]bool, len(usernames))
+    for _, username := range usernames {
+        if _, ok := resolved[username]; !ok && !seen[username] {
+            unknown = append(unknown, username)
+        }
+        seen[username] = true
+    }
+
+    response.JSON(c, http.StatusOK, gin.H{"users": resolved, "unknown": unknown})
+}
+
+// GetUsers looks up several users at once for internal service callers.
+// IDs that don't match a user are left out of the result.
+func (h *UserHandler) GetUsers(c *gin.Context) {
+    var req models.BatchUserRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondBindingError(c, err)
+        return
+    }
+
+    users, err := h.userService.GetUsersByIDs(c.Request.Context(), req.IDs)
+    if err != nil {
+        h.logger.Errorf("Failed to get users: %v", err)
+        response.Error(c, http.StatusInternalServerError, "Internal server error")
+        return
+    }
+
+    response.JSON(c, http.StatusOK, gin.H{"users": models.NewPrivateUsers(users)})
+}
+
+// RepublishSnapshots queues a fresh user:snapshot event for each of the
+// requested users, for internal services rebuilding their copy of them.
+func (h *UserHandler) RepublishSnapshots(c *gin.Context) {
+    var req models.BatchUserRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondBindingError(c, err)
+        return
+    }
+
+    result, err := h.userService.RepublishSnapshots(c.Request.Context(), req.IDs)
+    if err != nil {
+        h.logger.Errorf("Failed to republish user snapshots: %v", err)
+        response.Error(c, http.StatusInternalServerError, "Internal server error")
+        return
+    }
+
+    response.JSON(c, http.StatusAccepted, result)
 }
 
 func (h *UserHandler) UpdateProfile(c *gin.Context) {
     claims, _ := c.Get("claims")
     tokenClaims := claims.(*services.TokenClaims)
 
-    var req struct {
-        Username string `json:"username" binding:"required,min=3,max=50"`
-    }
+    var req models.UpdateProfileRequest
 
     if err := c.ShouldBindJSON(&req); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        respondBindingError(c, err)
         return
     }
 
     if err := h.userService.UpdateProfile(c.Request.Context(), tokenClaims.UserID, req.Username); err != nil {
-        h.logger.Errorf("Failed to update profile: %v", err)
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+        respondError(c, h.logger, err, "Failed to update profile")
         return
     }
 
-    c.JSON(http.StatusOK, gin.H{"message": "Profile updated successfully"})
+    response.JSON(c, http.StatusOK, gin.H{"message": "Profile updated successfully"})
 }
 
 func (h *UserHandler) ChangePassword(c *gin.Context) {
     claims, _ := c.Get("claims")
     tokenClaims := claims.(*services.TokenClaims)
 
-    var req struct {
-        OldPassword string `json:"old_password" binding:"required"`
-        NewPassword string `json:"new_password" binding:"required,min=8"`
-    }
+    var req models.ChangePasswordRequest
 
     if err := c.ShouldBindJSON(&req); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        respondBindingError(c, err)
         return
     }
 
     if err := h.userService.ChangePassword(c.Request.Context(), tokenClaims.UserID, req.OldPassword, req.NewPassword); err != nil {
-        if err == services.ErrInvalidCredentials {
-            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid old password"})
-        } else {
-            h.logger.Errorf("Failed to change password: %v", err)
-            c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+        if errors.Is(err, services.ErrInvalidCredentials) {
+            err = apperr.New(apperr.Invalid, "Invalid old password", err)
         }
+        respondError(c, h.logger, err, "Failed to change password")
         return
     }
 
-    c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully"})
+    response.JSON(c, http.StatusOK, gin.H{"message": "Password changed successfully"})
 }
 
+// DeleteAccount schedules the deletion of the caller's account. Access is
+// revoked right away and the rest happens in the background; progress is
+// reported by DeletionStatus. The mode query parameter picks between deleting
+// everything (default), anonymizing the account while keeping its content,
+// and exporting the account's data before deleting it.
 func (h *UserHandler) DeleteAccount(c *gin.Context) {
     claims, _ := c.Get("claims")
     tokenClaims := claims.(*services.TokenClaims)
 
-    if err := h.userService.DeleteUser(c.Request.Context(), tokenClaims.UserID); err != nil {
-        h.logger.Errorf("Failed to delete user: %v", err)
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+    var req models.DeleteAccountRequest
+    if err := c.ShouldBindQuery(&req); err != nil {
+        respondBindingError(c, err)
+        return
+    }
+
+    deletion, export, err := h.accountDeletion.Request(c.Request.Context(), tokenClaims.UserID, req.Mode)
+    if err != nil {
+        respondError(c, h.logger, err, "Failed to request account deletion")
+        return
+    }
+
+    body := gin.H{"message": "Account deletion scheduled", "deletion": deletion}
+    if export != nil {
+        body["export"] = export
+    }
+    response.JSON(c, http.StatusAccepted, body)
+}
+
+// DeletionStatus reports the progress of the caller's latest deletion
+// request. It keeps working after the account is gone for as long as the
+// caller's access token is valid.
+func (h *UserHandler) DeletionStatus(c *gin.Context) {
+    claims, _ := c.Get("claims")
+    tokenClaims := claims.(*services.TokenClaims)
+
+    deletion, err := h.accountDeletion.Status(c.Request.Context(), tokenClaims.UserID)
+    if err != nil {
+        respondError(c, h.logger, err, "Failed to get deletion status")
+        return
+    }
+
+    response.JSON(c, http.StatusOK, deletion)
+}
+
+// EnableTravelMode pins new logins to the device making this request for the
+// given number of days. Logins from other devices must be confirmed by email.
+func (h *UserHandler) EnableTravelMode(c *gin.Context) {
+    claims, _ := c.Get("claims")
+    tokenClaims := claims.(*services.TokenClaims)
+
+    var req models.TravelModeRequest
+
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondBindingError(c, err)
         return
     }
 
-    c.JSON(http.StatusOK, gin.H{"message": "Account deleted successfully"})
-}
\ No newline at end of file
+    until, err := h.userService.EnableTravelMode(c.Request.Context(), tokenClaims.UserID, c.GetHeader("User-Agent"), req.Days)
+    if err != nil {
+        h.logger.Errorf("Failed to enable travel mode: %v", err)
+        response.Error(c, http.StatusInternalServerError, "Internal server error")
+        return
+    }
+
+    response.JSON(c, http.StatusOK, gin.H{"travel_mode_until": until})
+}
+
+func (h *UserHandler) DisableTravelMode(c *gin.Context) {
+    claims, _ := c.Get("claims")
+    tokenClaims := claims.(*services.TokenClaims)
+
+    if err := h.userService.DisableTravelMode(c.Request.Context(), tokenClaims.UserID); err != nil {
+        h.logger.Errorf("Failed to disable travel mode: %v", err)
+        response.Error(c, http.StatusInternalServerError, "Internal server error")
+        return
+    }
+
+    response.JSON(c, http.StatusOK, gin.H{"message": "Travel mode disabled"})
+}
+
+// ReportEmailBounce lets the email service flag an address that bounced, so
+// its account has to re-verify it.
+func (h *UserHandler) ReportEmailBounce(c *gin.Context) {
+    var req models.EmailRequest
+
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondBindingError(c, err)
+        return
+    }
+
+    found, err := h.userService.RecordEmailBounce(c.Request.Context(), req.Email)
+    if err != nil {
+        h.logger.Errorf("Failed to record email bounce: %v", err)
+        response.Error(c, http.StatusInternalServerError, "Internal server error")
+        return
+    }
+    if !found {
+        response.Error(c, http.StatusNotFound, "User not found")
+        return
+    }
+
+    response.JSON(c, http.StatusOK, gin.H{"message": "Bounce recorded"})
+}
+
+// SearchUsers finds discoverable users by handle or display name prefix and
+// returns only their public profile.
+func (h *UserHandler) SearchUsers(c *gin.Context) {
+    claims, _ := c.Get("claims")
+    tokenClaims := claims.(*services.TokenClaims)
+
+    var req models.UserSearchRequest
+    if err := c.ShouldBindQuery(&req); err != nil {
+        respondBindingError(c, err)
+        return
+    }
+
+    users, err := h.userService.SearchUsers(c.Request.Context(), tokenClaims.UserID, req.Query, req.Limit)
+    if err != nil {
+        respondError(c, h.logger, err, "Failed to search users")
+        return
+    }
+
+    response.JSON(c, http.StatusOK, gin.H{"users": users})
+}
+
+func (h *UserHandler) UpdatePublicProfile(c *gin.Context) {
+    claims, _ := c.Get("claims")
+    tokenClaims := claims.(*services.TokenClaims)
+
+    var req models.PublicProfileRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondBindingError(c, err)
+        return
+    }
+
+    if err := h.userService.UpdatePublicProfile(c.Request.Context(), tokenClaims.UserID, &req); err != nil {
+        respondError(c, h.logger, err, "Failed to update public profile")
+        return
+    }
+
+    h.onboarding.ProfileUpdated(c.Request.Context(), tokenClaims.UserID)
+
+    response.JSON(c, http.StatusOK, gin.H{"message": "Public profile updated successfully"})
+}
+
+// Public profile cards may be stored by viewers and CDNs but are revalidated
+// on every use, so a profile change or opting out of the card shows at once.
+// Unchanged cards cost a 304 without a body.
+const publicCardCacheControl = "public, no-cache"
+
+// PublicProfileCard serves the minimal profile card shown on share links. It
+// needs no authentication, so it is rate limited per IP and reveals nothing
+// beyond the card. Responses carry an ETag; a matching If-None-Match gets 304.
+func (h *UserHandler) PublicProfileCard(c *gin.Context) {
+    card, err := h.userService.PublicCard(c.Request.Context(), c.Param("handle"), c.ClientIP())
+    if err != nil {
+        respondError(c, h.logger, err, "Failed to get public profile card")
+        return
+    }
+
+    etag, err := response.ETag(card)
+    if err != nil {
+        h.logger.Errorf("Failed to encode public profile card: %v", err)
+        response.Error(c, http.StatusInternalServerError, "Internal server error")
+        return
+    }
+    if response.NotModified(c, etag, publicCardCacheControl) {
+        return
+    }
+
+    response.JSON(c, http.StatusOK, card)
+}
+
+// Onboarding lists the onboarding milestones the user has reached.
+func (h *UserHandler) Onboarding(c *gin.Context) {
+    claims, _ := c.Get("claims")
+    tokenClaims := claims.(*services.TokenClaims)
+
+    progress, err := h.onboarding.Progress(c.Request.Context(), tokenClaims.UserID)
+    if err != nil {
+        h.logger.Errorf("Failed to get onboarding progress: %v", err)
+        response.Error(c, http.StatusInternalServerError, "Internal server error")
+        return
+    }
+
+    response.JSON(c, http.StatusOK, progress)
+}
+
+// ProfileCompletion reports which required profile fields the user has yet
+// to fill in, so clients can prompt for them one at a time.
+func (h *UserHandler) ProfileCompletion(c *gin.Context) {
+    claims, _ := c.Get("claims")
+    tokenClaims := claims.(*services.TokenClaims)
+
+    completion, err := h.userService.ProfileCompletion(c.Request.Context(), tokenClaims.UserID)
+    if err != nil {
+        respondError(c, h.logger, err, "Failed to get profile completion")
+        return
+    }
+
+    response.JSON(c, http.StatusOK, completion)
+}
+
+// SecurityScore returns the account's security checklist and score for the
+// app's security health screen.
+func (h *UserHandler) SecurityScore(c *gin.Context) {
+    claims, _ := c.Get("claims")
+    tokenClaims := claims.(*services.TokenClaims)
+
+    score, err := h.userService.SecurityScore(c.Request.Context(), tokenClaims.UserID)
+    if err != nil {
+        respondError(c, h.logger, err, "Failed to get security score")
+        return
+    }
+
+    response.JSON(c, http.StatusOK, score)
+}
+
+func (h *UserHandler) BlockUser(c *gin.Context) {
+    claims, _ := c.Get("claims")
+    tokenClaims := claims.(*services.TokenClaims)
+
+    blockedID, err := uuid.Parse(c.Param("id"))
+    if err != nil {
+        response.Error(c, http.StatusBadRequest, "Invalid user ID")
+        return
+    }
+
+    if err := h.userService.BlockUser(c.Request.Context(), tokenClaims.UserID, blockedID); err != nil {
+        respondError(c, h.logger, err, "Failed to block user")
+        return
+    }
+
+    response.JSON(c, http.StatusOK, gin.H{"message": "User blocked"})
+}
+
+func (h *UserHandler) UnblockUser(c *gin.Context) {
+    claims, _ := c.Get("claims")
+    tokenClaims := claims.(*services.TokenClaims)
+
+    blockedID, err := uuid.Parse(c.Param("id"))
+    if err != nil {
+        response.Error(c, http.StatusBadRequest, "Invalid user ID")
+        return
+    }
+
+    if err := h.userService.UnblockUser(c.Request.Context(), tokenClaims.UserID, blockedID); err != nil {
+        h.logger.Errorf("Failed to unblock user: %v", err)
+        response.Error(c, http.StatusInternalServerError, "Internal server error")
+        return
+    }
+
+    response.JSON(c, http.StatusOK, gin.H{"message": "User unblocked"})
+}
@@ -2,22 +2,29 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"auth-service/internal/events"
 	"auth-service/internal/models"
 	"auth-service/internal/services"
+	"auth-service/internal/validation"
 	"auth-service/test"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 func setupTestRouterWithAuth(authHandler *AuthHandler, userHandler *UserHandler, tokenService *services.TokenService) *gin.Engine {
 	gin.SetMode(gin.TestMode)
+	if err := validation.Setup(validation.DefaultLimits); err != nil {
+		panic(err)
+	}
 	router := gin.New()
 
 	// Add middleware to set claims for protected routes
@@ -48,7 +55,10 @@ func setupTestRouterWithAuth(authHandler *AuthHandler, userHandler *UserHandler,
 			users.PUT("/me", userHandler.UpdateProfile)
 			users.PUT("/me/password", userHandler.ChangePassword)
 			users.DELETE("/me", userHandler.DeleteAccount)
+			users.GET("/me/deletion-status", userHandler.DeletionStatus)
 		}
+
+		v1.GET("/public/profiles/:handle", userHandler.PublicProfileCard)
 	}
 
 	return router
@@ -58,12 +68,7 @@ func TestUserHandler_GetCurrentUser(t *testing.T) {
 	suite := test.NewTestSuite(t)
 	defer suite.Cleanup(t)
 
-	authService := services.NewAuthService(suite.DB.DB, suite.Redis.Client, suite.Config, suite.Logger)
-	tokenService := services.NewTokenService(suite.Config.JWTSecret, suite.Config.JWTExpiry, suite.Redis.Client, suite.Logger)
-	userService := services.NewUserService(suite.DB.DB, suite.Logger)
-
-	authHandler := NewAuthHandler(authService, userService, tokenService, suite.Logger)
-	userHandler := NewUserHandler(userService, suite.Logger)
+	authHandler, userHandler, tokenService := newTestHandlers(t, suite)
 
 	router := setupTestRouterWithAuth(authHandler, userHandler, tokenService)
 
@@ -71,7 +76,7 @@ func TestUserHandler_GetCurrentUser(t *testing.T) {
 	testUser := suite.CreateTestUser(t, test.TestData.ValidEmail, test.TestData.ValidUsername, test.TestData.ValidPassword)
 
 	// Generate token for user
-	token, _, err := tokenService.GenerateToken(testUser.ID, testUser.Email, testUser.Username)
+	token, _, err := tokenService.GenerateToken(testUser.ID, uuid.New(), testUser.Email, testUser.Username, true, models.EntitlementsFor(models.PlanFree), nil, "")
 	require.NoError(t, err)
 
 	tests := []struct {
@@ -115,7 +120,7 @@ func TestUserHandler_GetCurrentUser(t *testing.T) {
 			assert.Equal(t, tt.expectedStatus, w.Code)
 
 			if tt.expectUser {
-				var user models.User
+				var user models.PrivateUser
 				err = json.Unmarshal(w.Body.Bytes(), &user)
 				require.NoError(t, err)
 				assert.Equal(t, testUser.ID, user.ID)
@@ -126,16 +131,46 @@ func TestUserHandler_GetCurrentUser(t *testing.T) {
 	}
 }
 
-func TestUserHandler_UpdateProfile(t *testing.T) {
+func TestUserHandler_GetCurrentUser_UserGone(t *testing.T) {
 	suite := test.NewTestSuite(t)
 	defer suite.Cleanup(t)
 
-	authService := services.NewAuthService(suite.DB.DB, suite.Redis.Client, suite.Config, suite.Logger)
-	tokenService := services.NewTokenService(suite.Config.JWTSecret, suite.Config.JWTExpiry, suite.Redis.Client, suite.Logger)
-	userService := services.NewUserService(suite.DB.DB, suite.Logger)
+	authHandler, userHandler, tokenService := newTestHandlers(t, suite)
+	userHandler.SetGoneUserResponse(http.StatusGone, tokenService, suite.Events)
+
+	router := setupTestRouterWithAuth(authHandler, userHandler, tokenService)
+
+	// A token for a user that was never stored looks like one for a hard-deleted user
+	token, _, err := tokenService.GenerateToken(uuid.New(), uuid.New(), "gone@example.com", "gone", true, models.EntitlementsFor(models.PlanFree), nil, "")
+	require.NoError(t, err)
+
+	get := func() *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", "/api/v1/users/me", nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get()
+	assert.Equal(t, http.StatusGone, w.Code)
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "AUTH_USER_GONE", body["code"])
 
-	authHandler := NewAuthHandler(authService, userService, tokenService, suite.Logger)
-	userHandler := NewUserHandler(userService, suite.Logger)
+	require.Len(t, suite.Events.Events, 1)
+	assert.Equal(t, events.UserGoneTokenUsed, suite.Events.Events[0].Type)
+
+	// The token is blacklisted
+	assert.Equal(t, http.StatusUnauthorized, get().Code)
+}
+
+func TestUserHandler_UpdateProfile(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	authHandler, userHandler, tokenService := newTestHandlers(t, suite)
 
 	router := setupTestRouterWithAuth(authHandler, userHandler, tokenService)
 
@@ -143,7 +178,7 @@ func TestUserHandler_UpdateProfile(t *testing.T) {
 	testUser := suite.CreateTestUser(t, test.TestData.ValidEmail, test.TestData.ValidUsername, test.TestData.ValidPassword)
 
 	// Generate token for user
-	token, _, err := tokenService.GenerateToken(testUser.ID, testUser.Email, testUser.Username)
+	token, _, err := tokenService.GenerateToken(testUser.ID, uuid.New(), testUser.Email, testUser.Username, true, models.EntitlementsFor(models.PlanFree), nil, "")
 	require.NoError(t, err)
 
 	tests := []struct {
@@ -209,12 +244,7 @@ func TestUserHandler_ChangePassword(t *testing.T) {
 	suite := test.NewTestSuite(t)
 	defer suite.Cleanup(t)
 
-	authService := services.NewAuthService(suite.DB.DB, suite.Redis.Client, suite.Config, suite.Logger)
-	tokenService := services.NewTokenService(suite.Config.JWTSecret, suite.Config.JWTExpiry, suite.Redis.Client, suite.Logger)
-	userService := services.NewUserService(suite.DB.DB, suite.Logger)
-
-	authHandler := NewAuthHandler(authService, userService, tokenService, suite.Logger)
-	userHandler := NewUserHandler(userService, suite.Logger)
+	authHandler, userHandler, tokenService := newTestHandlers(t, suite)
 
 	router := setupTestRouterWithAuth(authHandler, userHandler, tokenService)
 
@@ -222,7 +252,7 @@ func TestUserHandler_ChangePassword(t *testing.T) {
 	testUser := suite.CreateTestUser(t, test.TestData.ValidEmail, test.TestData.ValidUsername, test.TestData.ValidPassword)
 
 	// Generate token for user
-	token, _, err := tokenService.GenerateToken(testUser.ID, testUser.Email, testUser.Username)
+	token, _, err := tokenService.GenerateToken(testUser.ID, uuid.New(), testUser.Email, testUser.Username, true, models.EntitlementsFor(models.PlanFree), nil, "")
 	require.NoError(t, err)
 
 	tests := []struct {
@@ -302,12 +332,7 @@ func TestUserHandler_DeleteAccount(t *testing.T) {
 	suite := test.NewTestSuite(t)
 	defer suite.Cleanup(t)
 
-	authService := services.NewAuthService(suite.DB.DB, suite.Redis.Client, suite.Config, suite.Logger)
-	tokenService := services.NewTokenService(suite.Config.JWTSecret, suite.Config.JWTExpiry, suite.Redis.Client, suite.Logger)
-	userService := services.NewUserService(suite.DB.DB, suite.Logger)
-
-	authHandler := NewAuthHandler(authService, userService, tokenService, suite.Logger)
-	userHandler := NewUserHandler(userService, suite.Logger)
+	authHandler, userHandler, tokenService := newTestHandlers(t, suite)
 
 	router := setupTestRouterWithAuth(authHandler, userHandler, tokenService)
 
@@ -323,7 +348,7 @@ func TestUserHandler_DeleteAccount(t *testing.T) {
 			authHeader: func(token string) string {
 				return "Bearer " + token
 			},
-			expectedStatus: http.StatusOK,
+			expectedStatus: http.StatusAccepted,
 		},
 		{
 			name:      "no auth header",
@@ -354,7 +379,7 @@ func TestUserHandler_DeleteAccount(t *testing.T) {
 
 				// Generate token for user
 				var err error
-				token, _, err = tokenService.GenerateToken(testUser.ID, testUser.Email, testUser.Username)
+				token, _, err = tokenService.GenerateToken(testUser.ID, uuid.New(), testUser.Email, testUser.Username, true, models.EntitlementsFor(models.PlanFree), nil, "")
 				require.NoError(t, err)
 			}
 
@@ -371,18 +396,73 @@ func TestUserHandler_DeleteAccount(t *testing.T) {
 
 			assert.Equal(t, tt.expectedStatus, w.Code)
 
-			if tt.expectedStatus == http.StatusOK {
-				// Verify user was deleted by trying to get current user
-				req2, err := http.NewRequest("GET", "/api/v1/users/me", nil)
+			if tt.expectedStatus == http.StatusAccepted {
+				// The deletion is queued and its progress can be polled
+				req2, err := http.NewRequest("GET", "/api/v1/users/me/deletion-status", nil)
 				require.NoError(t, err)
 				req2.Header.Set("Authorization", "Bearer "+token)
 
 				w2 := httptest.NewRecorder()
 				router.ServeHTTP(w2, req2)
 
-				// Should fail because user no longer exists
-				assert.Equal(t, http.StatusInternalServerError, w2.Code)
+				assert.Equal(t, http.StatusOK, w2.Code)
+				assert.Contains(t, w2.Body.String(), models.DeletionStatusPending)
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+func TestUserHandler_PublicProfileCard(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	authHandler, userHandler, tokenService := newTestHandlers(t, suite)
+
+	router := setupTestRouterWithAuth(authHandler, userHandler, tokenService)
+	testUser := suite.CreateTestUser(t, test.TestData.ValidEmail, test.TestData.ValidUsername, test.TestData.ValidPassword)
+
+	get := func(etag string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", "/api/v1/public/profiles/"+testUser.Username, nil)
+		require.NoError(t, err)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Cards are opt-in
+	assert.Equal(t, http.StatusNotFound, get("").Code)
+
+	name := "Test User"
+	enabled := true
+	err := userHandler.userService.UpdatePublicProfile(context.Background(), testUser.ID, &models.PublicProfileRequest{DisplayName: &name, PublicCard: &enabled})
+	require.NoError(t, err)
+
+	w := get("")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Cache-Control"), "public")
+	etag := w.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	var card map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &card))
+	assert.Equal(t, testUser.Username, card["handle"])
+	assert.Equal(t, name, card["display_name"])
+	assert.NotContains(t, card, "email")
+
+	assert.Equal(t, http.StatusNotModified, get(etag).Code)
+
+	// Changing the card changes its ETag
+	name = "Renamed"
+	err = userHandler.userService.UpdatePublicProfile(context.Background(), testUser.ID, &models.PublicProfileRequest{DisplayName: &name})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, get(etag).Code)
+
+	// Lookups are limited per IP
+	for i := 0; i < 30 && w.Code != http.StatusTooManyRequests; i++ {
+		w = get("")
+	}
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+    "net/http"
+
+    "auth-service/internal/models"
+    "auth-service/internal/response"
+    "auth-service/internal/services"
+
+    "github.com/gin-gonic/gin"
+    "github.com/google/uuid"
+    "go.uber.org/zap"
+)
+
+// WebhookHandler lets users manage webhooks for their own account events.
+type WebhookHandler struct {
+    webhookService *services.WebhookService
+    logger         *zap.SugaredLogger
+}
+
+func NewWebhookHandler(webhookService *services.WebhookService, logger *zap.SugaredLogger) *WebhookHandler {
+    return &WebhookHandler{
+        webhookService: webhookService,
+        logger:         logger,
+    }
+}
+
+func (h *WebhookHandler) ListWebhooks(c *gin.Context) {
+    claims, _ := c.Get("claims")
+    tokenClaims := claims.(*services.TokenClaims)
+
+    hooks, err := h.webhookService.List(c.Request.Context(), tokenClaims.UserID)
+    if err != nil {
+        h.logger.Errorf("Failed to list webhooks: %v", err)
+        response.Error(c, http.StatusInternalServerError, "Internal server error")
+        return
+    }
+
+    response.JSON(c, http.StatusOK, gin.H{"webhooks": hooks})
+}
+
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+    claims, _ := c.Get("claims")
+    tokenClaims := claims.(*services.TokenClaims)
+
+    var req models.CreateWebhookRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondBindingError(c, err)
+        return
+    }
+
+    hook, secret, err := h.webhookService.Create(c.Request.Context(), tokenClaims.UserID, &req)
+    if err != nil {
+        respondError(c, h.logger, err, "Failed to create webhook")
+        return
+    }
+
+    response.JSON(c, http.StatusCreated, models.CreateWebhookResponse{Webhook: hook, Secret: secret})
+}
+
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+    claims, _ := c.Get("claims")
+    tokenClaims := claims.(*services.TokenClaims)
+
+    webhookID, err := uuid.Parse(c.Param("id"))
+    if err != nil {
+        response.Error(c, http.StatusBadRequest, "Invalid webhook ID")
+        return
+    }
+
+    if err := h.webhookService.Delete(c.Request.Context(), tokenClaims.UserID, webhookID); err != nil {
+        respondError(c, h.logger, err, "Failed to delete webhook")
+        return
+    }
+
+    response.JSON(c, http.StatusOK, gin.H{"message": "Webhook deleted"})
+}
+
+func (h *WebhookHandler) RotateWebhookSecret(c *gin.Context) {
+    claims, _ := c.Get("claims")
+    tokenClaims := claims.(*services.TokenClaims)
+
+    webhookID, err := uuid.Parse(c.Param("id"))
+    if err != nil {
+        response.Error(c, http.StatusBadRequest, "Invalid webhook ID")
+        return
+    }
+
+    secret, err := h.webhookService.RotateSecret(c.Request.Context(), tokenClaims.UserID, webhookID)
+    if err != nil {
+        respondError(c, h.logger, err, "Failed to rotate webhook secret")
+        return
+    }
+
+    response.JSON(c, http.StatusOK, gin.H{"secret": secret})
+}
@@ -0,0 +1,137 @@
+// Package keybundle seals the service's key material and critical settings
+// into a passphrase-encrypted bundle, so they can be restored after a
+// disaster or copied to another environment without handling raw secrets.
+package keybundle
+
+import (
+    "crypto/aes"
+    "crypto/cipher"
+    "crypto/rand"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "time"
+
+    "golang.org/x/crypto/scrypt"
+)
+
+// Format version written into every bundle
+const Version = 1
+
+// scrypt parameters for deriving the AES-256 key from the passphrase
+const (
+    scryptN             = 1 << 15
+    scryptR             = 8
+    scryptP             = 1
+    keyLength           = 32
+    saltLength          = 16
+    minPassphraseLength = 12
+)
+
+var (
+    ErrWeakPassphrase    = fmt.Errorf("passphrase must be at least %d characters", minPassphraseLength)
+    ErrWrongPassphrase   = errors.New("wrong passphrase or corrupted bundle")
+    ErrUnsupportedBundle = errors.New("unsupported bundle version")
+)
+
+// Snapshot is the plaintext content of a bundle.
+type Snapshot struct {
+    Environment string            `json:"environment"`
+    CreatedAt   time.Time         `json:"created_at"`
+    Settings    map[string]string `json:"settings"`
+}
+
+// envelope is the on-disk form of a bundle. Only the ciphertext is secret.
+type envelope struct {
+    Version    int    `json:"version"`
+    KDF        string `json:"kdf"`
+    Salt       []byte `json:"salt"`
+    Nonce      []byte `json:"nonce"`
+    Ciphertext []byte `json:"ciphertext"`
+}
+
+// Seal encrypts snapshot with AES-256-GCM under a key derived from
+// passphrase with scrypt.
+func Seal(snapshot *Snapshot, passphrase string) ([]byte, error) {
+    if len(passphrase) < minPassphraseLength {
+        return nil, ErrWeakPassphrase
+    }
+
+    plaintext, err := json.Marshal(snapshot)
+    if err != nil {
+        return nil, fmt.Errorf("marshal snapshot: %w", err)
+    }
+
+    salt := make([]byte, saltLength)
+    if _, err := rand.Read(salt); err != nil {
+        return nil, fmt.Errorf("generate salt: %w", err)
+    }
+
+    gcm, err := newGCM(passphrase, salt)
+    if err != nil {
+        return nil, err
+    }
+
+    nonce := make([]byte, gcm.NonceSize())
+    if _, err := rand.Read(nonce); err != nil {
+        return nil, fmt.Errorf("generate nonce: %w", err)
+    }
+
+    env := envelope{
+        Version: Version,
+        KDF:     "scrypt",
+        Salt:    salt,
+        Nonce:   nonce,
+    }
+    // The header is authenticated so it can't be swapped between bundles
+    env.Ciphertext = gcm.Seal(nil, nonce, plaintext, additionalData(env))
+
+    return json.MarshalIndent(env, "", "  ")
+}
+
+// Open decrypts a bundle produced by Seal.
+func Open(data []byte, passphrase string) (*Snapshot, error) {
+    var env envelope
+    if err := json.Unmarshal(data, &env); err != nil {
+        return nil, fmt.Errorf("parse bundle: %w", err)
+    }
+    if env.Version != Version || env.KDF != "scrypt" {
+        return nil, ErrUnsupportedBundle
+    }
+
+    gcm, err := newGCM(passphrase, env.Salt)
+    if err != nil {
+        return nil, err
+    }
+    if len(env.Nonce) != gcm.NonceSize() {
+        return nil, ErrWrongPassphrase
+    }
+
+    plaintext, err := gcm.Open(nil, env.Nonce, env.Ciphertext, additionalData(env))
+    if err != nil {
+        return nil, ErrWrongPassphrase
+    }
+
+    var snapshot Snapshot
+    if err := json.Unmarshal(plaintext, &snapshot); err != nil {
+        return nil, fmt.Errorf("parse snapshot: %w", err)
+    }
+    return &snapshot, nil
+}
+
+func newGCM(passphrase string, salt []byte) (cipher.AEAD, error) {
+    key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, keyLength)
+    if err != nil {
+        return nil, fmt.Errorf("derive key: %w", err)
+    }
+
+    block, err := aes.NewCipher(key)
+    if err != nil {
+        return nil, fmt.Errorf("create cipher: %w", err)
+    }
+    return cipher.NewGCM(block)
+}
+
+func additionalData(env envelope) []byte {
+    return []byte(fmt.Sprintf("tapin-keybundle:v%d:%s:%x", env.Version, env.KDF, env.Salt))
+}
@@ -0,0 +1,59 @@
+package keybundle
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPassphrase = "correct horse battery staple"
+
+func TestSealAndOpen(t *testing.T) {
+	snapshot := &Snapshot{
+		Environment: "production",
+		CreatedAt:   time.Now().UTC().Truncate(time.Second),
+		Settings:    map[string]string{"jwt_secret": "super-secret", "session_policy": "strict"},
+	}
+
+	data, err := Seal(snapshot, testPassphrase)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "super-secret")
+
+	opened, err := Open(data, testPassphrase)
+	require.NoError(t, err)
+	assert.Equal(t, snapshot, opened)
+}
+
+func TestOpenRejects(t *testing.T) {
+	data, err := Seal(&Snapshot{Settings: map[string]string{"jwt_secret": "super-secret"}}, testPassphrase)
+	require.NoError(t, err)
+
+	_, err = Open(data, "wrong passphrase!")
+	assert.Equal(t, ErrWrongPassphrase, err)
+
+	var env envelope
+	require.NoError(t, json.Unmarshal(data, &env))
+
+	tampered := env
+	tampered.Ciphertext = append([]byte{}, env.Ciphertext...)
+	tampered.Ciphertext[0] ^= 0xff
+	raw, err := json.Marshal(tampered)
+	require.NoError(t, err)
+	_, err = Open(raw, testPassphrase)
+	assert.Equal(t, ErrWrongPassphrase, err)
+
+	future := env
+	future.Version = Version + 1
+	raw, err = json.Marshal(future)
+	require.NoError(t, err)
+	_, err = Open(raw, testPassphrase)
+	assert.Equal(t, ErrUnsupportedBundle, err)
+}
+
+func TestSealRejectsWeakPassphrase(t *testing.T) {
+	_, err := Seal(&Snapshot{}, "short")
+	assert.Equal(t, ErrWeakPassphrase, err)
+}
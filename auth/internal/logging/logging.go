@@ -0,0 +1,29 @@
+package logging
+
+import (
+    "fmt"
+
+    "auth-service/internal/config"
+
+    "go.uber.org/zap"
+)
+
+// New builds the service logger for a config profile. Console encoding uses
+// zap's development settings (colored levels, stack traces on warn); JSON
+// uses the production settings with sampling.
+func New(profile config.Profile) (*zap.Logger, error) {
+    level, err := zap.ParseAtomicLevel(profile.LogLevel)
+    if err != nil {
+        return nil, fmt.Errorf("parse log level: %w", err)
+    }
+
+    var cfg zap.Config
+    if profile.LogEncoding == "console" {
+        cfg = zap.NewDevelopmentConfig()
+    } else {
+        cfg = zap.NewProductionConfig()
+    }
+    cfg.Level = level
+
+    return cfg.Build(zap.Fields(zap.String("profile", profile.Name)))
+}
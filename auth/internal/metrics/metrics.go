@@ -0,0 +1,178 @@
+package metrics
+
+import (
+    "net/http"
+    "strings"
+
+    "github.com/prometheus/client_golang/prometheus"
+    "github.com/prometheus/client_golang/prometheus/collectors"
+    "github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Login funnel steps, in the order a new account normally reaches them.
+const (
+    StepRegisterStarted = "register_started"
+    StepEmailVerified   = "email_verified"
+    StepFirstLogin      = "first_login"
+    StepMFAEnrolled     = "mfa_enrolled"
+)
+
+// Why a login failed. These are for internal analytics only; clients are
+// told no more than "Invalid credentials" or "Challenge failed".
+const (
+    LoginFailureUnknownEmail  = "unknown_email"
+    LoginFailureBadPassword   = "bad_password"
+    LoginFailureDeleted       = "account_deleted"
+    LoginFailureLocked        = "locked"
+    LoginFailureMFAFailed     = "mfa_failed"
+    LoginFailureCaptchaFailed = "captcha_failed"
+)
+
+// Client types accepted from the X-Client-Type header. Anything else is
+// reported as "other" to keep label cardinality bounded.
+var clientTypes = map[string]bool{
+    "web":     true,
+    "ios":     true,
+    "android": true,
+    "desktop": true,
+}
+
+var Registry = prometheus.NewRegistry()
+
+var (
+    FunnelSteps = prometheus.NewCounterVec(prometheus.CounterOpts{
+        Name: "auth_funnel_steps_total",
+        Help: "Number of times each login funnel step was reached.",
+    }, []string{"step", "client"})
+
+    // FunnelStepDelay measures how long after account creation a step was
+    // reached. It is not observed for register_started, which has no account.
+    FunnelStepDelay = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+        Name: "auth_funnel_step_delay_seconds",
+        Help: "Time from account creation until the funnel step was reached.",
+        // 1m, 5m, 15m, 1h, 6h, 1d, 3d, 7d, 30d
+        Buckets: []float64{60, 300, 900, 3600, 21600, 86400, 259200, 604800, 2592000},
+    }, []string{"step", "client"})
+
+    RefreshBruteForceAlerts = prometheus.NewCounterVec(prometheus.CounterOpts{
+        Name: "auth_refresh_bruteforce_alerts_total",
+        Help: "Sustained refresh token guessing detected, by scope (ip or token_prefix).",
+    }, []string{"scope"})
+
+    RateLimitExceeded = prometheus.NewCounterVec(prometheus.CounterOpts{
+        Name: "auth_rate_limit_exceeded_total",
+        Help: "Requests over a rate limit rule, by rule and mode (enforce blocked them, shadow only counted them).",
+    }, []string{"rule", "mode"})
+
+    BcryptQueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+        Name: "auth_bcrypt_queue_depth",
+        Help: "Password hash operations waiting for a free bcrypt slot.",
+    })
+
+    BcryptQueueTimeouts = prometheus.NewCounter(prometheus.CounterOpts{
+        Name: "auth_bcrypt_queue_timeouts_total",
+        Help: "Password hash operations rejected after waiting too long for a bcrypt slot.",
+    })
+
+    EventBufferDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+        Name: "auth_event_buffer_depth",
+        Help: "Events waiting in the in-process buffer to be published to RabbitMQ.",
+    })
+
+    EventOutboxWrites = prometheus.NewCounterVec(prometheus.CounterOpts{
+        Name: "auth_event_outbox_writes_total",
+        Help: "Events diverted to the Postgres outbox, by reason (buffer_full, circuit_open, publish_failed or shutdown).",
+    }, []string{"reason"})
+
+    EventPublisherCircuitOpen = prometheus.NewGauge(prometheus.GaugeOpts{
+        Name: "auth_event_publisher_circuit_open",
+        Help: "1 while event publishing is in outbox-only mode because RabbitMQ can't keep up.",
+    })
+
+    RedisAvailable = prometheus.NewGauge(prometheus.GaugeOpts{
+        Name: "auth_redis_available",
+        Help: "0 while Redis is unreachable and the service runs in degraded mode.",
+    })
+
+    RabbitMQAvailable = prometheus.NewGauge(prometheus.GaugeOpts{
+        Name: "auth_rabbitmq_available",
+        Help: "0 while RabbitMQ is unreachable and user events are held in the outbox.",
+    })
+
+    InFlightRequests = prometheus.NewGauge(prometheus.GaugeOpts{
+        Name: "auth_inflight_requests",
+        Help: "Requests being served, across both listeners.",
+    })
+
+    DependencyLatency = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+        Name: "auth_dependency_latency_seconds",
+        Help: "Smoothed round-trip time of health probes, by dependency.",
+    }, []string{"dependency"})
+
+    RequestsShed = prometheus.NewCounterVec(prometheus.CounterOpts{
+        Name: "auth_requests_shed_total",
+        Help: "Low-priority requests rejected with 503 under load, by reason.",
+    }, []string{"reason"})
+
+    FaultsInjected = prometheus.NewCounterVec(prometheus.CounterOpts{
+        Name: "auth_faults_injected_total",
+        Help: "Faults injected by CHAOS_RULES, by fault: latency, error, redis or db.",
+    }, []string{"fault"})
+
+    LoginFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+        Name: "auth_login_failures_total",
+        Help: "Failed login attempts, by reason.",
+    }, []string{"reason", "client"})
+
+    IntegrityAnomalies = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+        Name: "auth_integrity_anomalies",
+        Help: "Rows found inconsistent by the last integrity check, by check.",
+    }, []string{"check"})
+
+    IntegrityRepairs = prometheus.NewCounterVec(prometheus.CounterOpts{
+        Name: "auth_integrity_repairs_total",
+        Help: "Inconsistent rows repaired by the integrity checker, by check.",
+    }, []string{"check"})
+)
+
+func init() {
+    Registry.MustRegister(
+        collectors.NewGoCollector(),
+        collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+        FunnelSteps,
+        FunnelStepDelay,
+        RefreshBruteForceAlerts,
+        RateLimitExceeded,
+        BcryptQueueDepth,
+        BcryptQueueTimeouts,
+        EventBufferDepth,
+        EventOutboxWrites,
+        EventPublisherCircuitOpen,
+        RedisAvailable,
+        RabbitMQAvailable,
+        InFlightRequests,
+        DependencyLatency,
+        RequestsShed,
+        FaultsInjected,
+        LoginFailures,
+        IntegrityAnomalies,
+        IntegrityRepairs,
+    )
+}
+
+// Handler serves the registry, in OpenMetrics format when the scraper asks for it.
+func Handler() http.Handler {
+    return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{EnableOpenMetrics: true})
+}
+
+// ClientType normalizes an X-Client-Type header value into a metric label.
+func ClientType(header string) string {
+    client := strings.ToLower(strings.TrimSpace(header))
+    if client == "" {
+        return "unknown"
+    }
+    if clientTypes[client] {
+        return client
+    }
+    return "other"
+}
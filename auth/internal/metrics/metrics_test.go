@@ -0,0 +1,24 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientType(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"web", "web"},
+		{" iOS ", "ios"},
+		{"android", "android"},
+		{"", "unknown"},
+		{"curl/8.0", "other"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, ClientType(tt.header), tt.header)
+	}
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+    "net/http"
+
+    "auth-service/internal/models"
+    "auth-service/internal/response"
+    "auth-service/internal/services"
+
+    "github.com/gin-gonic/gin"
+)
+
+// RequireAdmin must run after Auth. The role is read from the database on
+// every request so that demoting an admin takes effect immediately. View-only
+// tokens are refused even for reads: they stand in for an expired session.
+func RequireAdmin(userService *services.UserService) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        claims, _ := c.Get("claims")
+        tokenClaims := claims.(*services.TokenClaims)
+
+        if tokenClaims.Scope == services.ScopeViewOnly {
+            response.ErrorWithDetails(c, http.StatusForbidden, "Admin access requires a full login", gin.H{
+                "reauth_required": true,
+            })
+            c.Abort()
+            return
+        }
+
+        user, err := userService.GetUserByID(c.Request.Context(), tokenClaims.UserID)
+        if err != nil || user.Role != models.RoleAdmin {
+            response.Error(c, http.StatusForbidden, "Admin access required")
+            c.Abort()
+            return
+        }
+
+        c.Set("admin", user)
+        c.Next()
+    }
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"auth-service/internal/models"
+	"auth-service/internal/services"
+	"auth-service/internal/store"
+	"auth-service/test"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequireAdmin_RejectsViewOnlyTokens(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	tokenService := services.NewTokenService(test.Keyring(t, suite.Config.JWTSecret), suite.Config.JWTExpiry, store.NewRedis(suite.Redis.Client), suite.Logger)
+	userService := services.NewUserService(suite.DB.DB, suite.Redis.Client, suite.Logger)
+	admin := suite.CreateTestUser(t, "admin@example.com", "admin", "password123")
+	_, err := suite.DB.Pool().Exec(context.Background(), "UPDATE users SET role = $1 WHERE id = $2", models.RoleAdmin, admin.ID)
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/admin/audit", Auth(tokenService, nil), RequireAdmin(userService), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	get := func(token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/admin/audit", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	viewOnly, _, err := tokenService.GenerateViewOnlyToken(admin.ID, admin.Email, admin.Username)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, get(viewOnly))
+
+	full, _, err := tokenService.GenerateToken(admin.ID, uuid.New(), admin.Email, admin.Username, true, models.Entitlements{}, nil, "")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, get(full))
+}
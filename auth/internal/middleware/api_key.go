@@ -0,0 +1,99 @@
+package middleware
+
+import (
+    "errors"
+    "net/http"
+    "strconv"
+    "time"
+
+    "auth-service/internal/models"
+    "auth-service/internal/response"
+    "auth-service/internal/services"
+
+    "github.com/gin-gonic/gin"
+)
+
+func APIKey(apiKeyService *services.APIKeyService) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        rawKey := c.GetHeader("X-API-Key")
+        if rawKey == "" {
+            response.Error(c, http.StatusUnauthorized, "API key required")
+            c.Abort()
+            return
+        }
+
+        key, err := apiKeyService.Authenticate(c.Request.Context(), rawKey)
+        if err != nil {
+            response.Error(c, http.StatusUnauthorized, "Invalid API key")
+            c.Abort()
+            return
+        }
+
+        usage, err := apiKeyService.TrackUsage(c.Request.Context(), key)
+        if err != nil && !errors.Is(err, services.ErrQuotaExceeded) {
+            // Fail open: quota accounting must not take internal callers down
+            c.Set("api_key", key)
+            c.Next()
+            return
+        }
+
+        setQuotaHeaders(c, usage)
+
+        if errors.Is(err, services.ErrQuotaExceeded) {
+            retryAfter := time.Until(usage.DailyResetAt)
+            if usage.MonthlyQuota > 0 && usage.MonthlyCount > int64(usage.MonthlyQuota) {
+                retryAfter = time.Until(usage.MonthlyResetAt)
+            }
+            c.Header("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+            response.ErrorWithDetails(c, http.StatusTooManyRequests, "API key quota exceeded", gin.H{"usage": usage})
+            c.Abort()
+            return
+        }
+
+        c.Set("api_key", key)
+        c.Next()
+    }
+}
+
+// OptionalAPIKey identifies callers that present an API key on routes that
+// don't require one, so rate limit policy can tell services apart. A key
+// that is presented must be valid; its usage isn't counted against quotas.
+func OptionalAPIKey(apiKeyService *services.APIKeyService) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        rawKey := c.GetHeader("X-API-Key")
+        if rawKey == "" {
+            c.Next()
+            return
+        }
+
+        key, err := apiKeyService.Authenticate(c.Request.Context(), rawKey)
+        if err != nil {
+            response.Error(c, http.StatusUnauthorized, "Invalid API key")
+            c.Abort()
+            return
+        }
+
+        c.Set("api_key", key)
+        c.Next()
+    }
+}
+
+func setQuotaHeaders(c *gin.Context, usage *models.APIKeyUsage) {
+    if usage.DailyQuota > 0 {
+        c.Header("X-Quota-Daily-Limit", strconv.Itoa(usage.DailyQuota))
+        c.Header("X-Quota-Daily-Remaining", strconv.FormatInt(remaining(usage.DailyQuota, usage.DailyCount), 10))
+        c.Header("X-Quota-Daily-Reset", strconv.FormatInt(usage.DailyResetAt.Unix(), 10))
+    }
+    if usage.MonthlyQuota > 0 {
+        c.Header("X-Quota-Monthly-Limit", strconv.Itoa(usage.MonthlyQuota))
+        c.Header("X-Quota-Monthly-Remaining", strconv.FormatInt(remaining(usage.MonthlyQuota, usage.MonthlyCount), 10))
+        c.Header("X-Quota-Monthly-Reset", strconv.FormatInt(usage.MonthlyResetAt.Unix(), 10))
+    }
+}
+
+func remaining(quota int, count int64) int64 {
+    if left := int64(quota) - count; left > 0 {
+        return left
+    }
+    return 0
+}
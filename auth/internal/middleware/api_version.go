@@ -0,0 +1,39 @@
+package middleware
+
+import (
+    "fmt"
+    "net/http"
+    "strings"
+    "time"
+
+    "auth-service/internal/response"
+
+    "github.com/gin-gonic/gin"
+)
+
+// APIVersion tags requests with the API version of their route group so the
+// shared handlers know which response shape to produce.
+func APIVersion(version string) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        response.SetVersion(c, version)
+        c.Next()
+    }
+}
+
+// Deprecated marks every response in the group as deprecated and points
+// clients at the equivalent path under the successor version. A zero sunset
+// omits the Sunset header.
+func Deprecated(version, successor string, sunset time.Time) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        c.Header("Deprecation", "true")
+
+        successorPath := strings.Replace(c.Request.URL.Path, "/api/"+version+"/", "/api/"+successor+"/", 1)
+        c.Header("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", successorPath))
+
+        if !sunset.IsZero() {
+            c.Header("Sunset", sunset.UTC().Format(http.TimeFormat))
+        }
+
+        c.Next()
+    }
+}
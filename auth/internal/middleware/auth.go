@@ -1,33 +1,58 @@
 package middleware
 
 import (
+    "errors"
     "net/http"
     "strings"
 
+    "auth-service/internal/models"
+    "auth-service/internal/response"
     "auth-service/internal/services"
 
     "github.com/gin-gonic/gin"
 )
 
-func Auth(tokenService *services.TokenService) gin.HandlerFunc {
+// Auth accepts access tokens, and personal access tokens when personalTokens
+// is set (nil to reject them). Every route behind an Auth that accepts them
+// must declare the scope it needs with RequireTokenScope.
+func Auth(tokenService *services.TokenService, personalTokens *services.PersonalTokenService) gin.HandlerFunc {
     return func(c *gin.Context) {
         authHeader := c.GetHeader("Authorization")
         if authHeader == "" {
-            c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
+            response.Error(c, http.StatusUnauthorized, "Authorization header required")
             c.Abort()
             return
         }
 
         tokenString := strings.TrimPrefix(authHeader, "Bearer ")
         if tokenString == authHeader {
-            c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
+            response.Error(c, http.StatusUnauthorized, "Invalid authorization header format")
             c.Abort()
             return
         }
 
+        if strings.HasPrefix(tokenString, services.PersonalTokenPrefix) {
+            authenticatePersonalToken(c, personalTokens, tokenString)
+            return
+        }
+
         claims, err := tokenService.ValidateToken(tokenString)
+        if errors.Is(err, services.ErrTokenNotYetValid) {
+            response.Error(c, http.StatusUnauthorized, "Token not yet valid")
+            c.Abort()
+            return
+        }
         if err != nil {
-            c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+            response.Error(c, http.StatusUnauthorized, "Invalid token")
+            c.Abort()
+            return
+        }
+
+        // View-only tokens can read; writes need a fresh login
+        if claims.Scope == services.ScopeViewOnly && !isReadOnlyMethod(c.Request.Method) {
+            response.ErrorWithDetails(c, http.StatusUnauthorized, "Login required", gin.H{
+                "reauth_required": true,
+            })
             c.Abort()
             return
         }
@@ -35,4 +60,54 @@ func Auth(tokenService *services.TokenService) gin.HandlerFunc {
         c.Set("claims", claims)
         c.Next()
     }
-}
\ No newline at end of file
+}
+
+func authenticatePersonalToken(c *gin.Context, personalTokens *services.PersonalTokenService, rawToken string) {
+    if personalTokens == nil {
+        response.Error(c, http.StatusForbidden, "Personal access tokens are not accepted here")
+        c.Abort()
+        return
+    }
+
+    token, claims, err := personalTokens.Authenticate(c.Request.Context(), rawToken)
+    if errors.Is(err, services.ErrInvalidPersonalToken) {
+        response.Error(c, http.StatusUnauthorized, "Invalid token")
+        c.Abort()
+        return
+    }
+    if err != nil {
+        response.Error(c, http.StatusInternalServerError, "Internal server error")
+        c.Abort()
+        return
+    }
+
+    c.Set("claims", claims)
+    c.Set("personal_token", token)
+    c.Next()
+}
+
+// RequireTokenScope must run after Auth. It lets a request made with a
+// personal access token through only if the token was granted scope;
+// requests made with an access token are unaffected.
+func RequireTokenScope(scope string) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        value, ok := c.Get("personal_token")
+        if !ok {
+            c.Next()
+            return
+        }
+
+        if !value.(*models.PersonalAccessToken).HasScope(scope) {
+            response.ErrorWithDetails(c, http.StatusForbidden, "Insufficient token scope", gin.H{
+                "required_scope": scope,
+            })
+            c.Abort()
+            return
+        }
+        c.Next()
+    }
+}
+
+func isReadOnlyMethod(method string) bool {
+    return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
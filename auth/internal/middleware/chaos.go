@@ -0,0 +1,61 @@
+package middleware
+
+import (
+    "net/http"
+
+    "auth-service/internal/chaos"
+    "auth-service/internal/config"
+    "auth-service/internal/metrics"
+    "auth-service/internal/response"
+
+    "github.com/gin-gonic/gin"
+)
+
+// Routes the ChaosRouteAll rule leaves alone, so probes and scrapes keep
+// working while everything else misbehaves
+var chaosExempt = map[string]bool{
+    "/health":  true,
+    "/readyz":  true,
+    "/version": true,
+    "/metrics": true,
+}
+
+// Chaos injects the faults of the rule for each request's route, or of the
+// ChaosRouteAll rule for routes without one: added latency, a 503 instead of
+// the handler, and failing or slow Redis and Postgres calls (see
+// chaos.WithFault). Only wired in when CHAOS_RULES is set, which production
+// refuses.
+func Chaos(rules map[string]config.ChaosRule) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        rule, ok := rules[c.Request.Method+" "+c.FullPath()]
+        if !ok && !chaosExempt[c.FullPath()] {
+            rule, ok = rules[config.ChaosRouteAll]
+        }
+        if !ok {
+            c.Next()
+            return
+        }
+
+        if rule.Latency > 0 {
+            metrics.FaultsInjected.WithLabelValues("latency").Inc()
+            if !chaos.Sleep(c.Request.Context(), rule.Latency) {
+                c.Abort()
+                return
+            }
+        }
+        if chaos.Roll(rule.ErrorRate) {
+            metrics.FaultsInjected.WithLabelValues("error").Inc()
+            response.Error(c, http.StatusServiceUnavailable, "Injected fault")
+            c.Abort()
+            return
+        }
+
+        c.Request = c.Request.WithContext(chaos.WithFault(c.Request.Context(), &chaos.Fault{
+            DropRedis: rule.DropRedis,
+            DropDB:    rule.DropDB,
+            SlowRedis: rule.SlowRedis,
+            SlowDB:    rule.SlowDB,
+        }))
+        c.Next()
+    }
+}
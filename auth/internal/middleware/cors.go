@@ -1,29 +1,70 @@
 package middleware
 
 import (
+    "strings"
+
+    "auth-service/internal/config"
+
     "github.com/gin-gonic/gin"
 )
 
-func CORS(allowedOrigins []string) gin.HandlerFunc {
+// CORSRoute applies a CORS policy to every path under Prefix.
+type CORSRoute struct {
+    Prefix string
+    Policy config.CORSPolicy
+}
+
+func CORS(policy config.CORSPolicy) gin.HandlerFunc {
+    methods := strings.Join(policy.Methods, ", ")
+    headers := strings.Join(policy.Headers, ", ")
+
     return func(c *gin.Context) {
-        origin := c.GetHeader("Origin")
-        
-        for _, allowed := range allowedOrigins {
-            if allowed == "*" || allowed == origin {
-                c.Header("Access-Control-Allow-Origin", origin)
-                break
+        applyCORS(c, policy, methods, headers)
+    }
+}
+
+// CORSRoutes applies the policy of the first route whose prefix matches the
+// request path. It runs on the whole router rather than per route group so
+// that preflight requests, which match no route, still get their policy.
+// Paths no route matches get no CORS headers.
+func CORSRoutes(routes []CORSRoute) gin.HandlerFunc {
+    methods := make([]string, len(routes))
+    headers := make([]string, len(routes))
+    for i, route := range routes {
+        methods[i] = strings.Join(route.Policy.Methods, ", ")
+        headers[i] = strings.Join(route.Policy.Headers, ", ")
+    }
+
+    return func(c *gin.Context) {
+        for i, route := range routes {
+            if strings.HasPrefix(c.Request.URL.Path, route.Prefix) {
+                applyCORS(c, route.Policy, methods[i], headers[i])
+                return
             }
         }
+        c.Next()
+    }
+}
 
-        c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-        c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
-        c.Header("Access-Control-Allow-Credentials", "true")
+func applyCORS(c *gin.Context, policy config.CORSPolicy, methods, headers string) {
+    origin := c.GetHeader("Origin")
+    c.Header("Vary", "Origin")
 
-        if c.Request.Method == "OPTIONS" {
-            c.AbortWithStatus(204)
-            return
+    for _, allowed := range policy.Origins {
+        if allowed == "*" || allowed == origin {
+            c.Header("Access-Control-Allow-Origin", origin)
+            break
         }
+    }
 
-        c.Next()
+    c.Header("Access-Control-Allow-Methods", methods)
+    c.Header("Access-Control-Allow-Headers", headers)
+    c.Header("Access-Control-Allow-Credentials", "true")
+
+    if c.Request.Method == "OPTIONS" {
+        c.AbortWithStatus(204)
+        return
     }
-}
\ No newline at end of file
+
+    c.Next()
+}
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"auth-service/internal/config"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCORS_PublicPreflight(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(CORS(config.DefaultCORSPolicies([]string{"https://app.tapin.app"})[config.CORSGroupPublic]))
+	router.POST("/api/v1/auth/login", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	req := httptest.NewRequest(http.MethodOptions, "/api/v1/auth/login", nil)
+	req.Header.Set("Origin", "https://app.tapin.app")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	req.Header.Set("Access-Control-Request-Headers", "content-type, x-client-type, accept-language, x-data-region")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "https://app.tapin.app", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, w.Header().Get("Access-Control-Allow-Methods"), http.MethodPost)
+	for _, header := range []string{"Content-Type", "Authorization", "Accept-Language", "X-Client-Type", "X-Data-Region"} {
+		assert.Contains(t, w.Header().Get("Access-Control-Allow-Headers"), header)
+	}
+}
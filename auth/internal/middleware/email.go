@@ -0,0 +1,39 @@
+package middleware
+
+import (
+    "net/http"
+    "time"
+
+    "auth-service/internal/response"
+    "auth-service/internal/services"
+
+    "github.com/gin-gonic/gin"
+)
+
+// RequireFreshEmail must run after Auth. It blocks sensitive operations for
+// accounts that are due to re-verify their email (see
+// services.EmailNeedsReverification) until they follow a new verification
+// link. now is the clock verification dates are compared against.
+func RequireFreshEmail(userService *services.UserService, months int, now func() time.Time) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        claims, _ := c.Get("claims")
+        tokenClaims := claims.(*services.TokenClaims)
+
+        user, err := userService.GetUserByID(c.Request.Context(), tokenClaims.UserID)
+        if err != nil {
+            response.Error(c, http.StatusInternalServerError, "Internal server error")
+            c.Abort()
+            return
+        }
+
+        if services.EmailNeedsReverification(user, months, now()) {
+            response.ErrorWithDetails(c, http.StatusForbidden, "Email re-verification required", gin.H{
+                "reverification_required": true,
+            })
+            c.Abort()
+            return
+        }
+
+        c.Next()
+    }
+}
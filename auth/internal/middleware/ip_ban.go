@@ -0,0 +1,24 @@
+package middleware
+
+import (
+    "net/http"
+
+    "auth-service/internal/response"
+    "auth-service/internal/services"
+
+    "github.com/gin-gonic/gin"
+)
+
+// IPBan rejects requests from banned IPs and ranges. It should run before any
+// other work is done for the request.
+func IPBan(ipBanService *services.IPBanService) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        if ipBanService.IsBanned(c.ClientIP()) {
+            response.Error(c, http.StatusForbidden, "Access denied")
+            c.Abort()
+            return
+        }
+
+        c.Next()
+    }
+}
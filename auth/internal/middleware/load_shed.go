@@ -0,0 +1,37 @@
+package middleware
+
+import (
+    "net/http"
+    "strconv"
+
+    "auth-service/internal/metrics"
+    "auth-service/internal/response"
+    "auth-service/internal/services"
+
+    "github.com/gin-gonic/gin"
+)
+
+// InFlight counts every request with the shedder while it is served.
+func InFlight(shedder *services.LoadShedder) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        done := shedder.Begin()
+        defer done()
+        c.Next()
+    }
+}
+
+// Shed marks a route as low priority: while the service is saturated its
+// requests are rejected with 503 and a Retry-After header before doing any
+// work. Login, refresh and the other critical routes never use it.
+func Shed(shedder *services.LoadShedder) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        if saturated, reason := shedder.Saturated(); saturated {
+            metrics.RequestsShed.WithLabelValues(reason).Inc()
+            c.Header("Retry-After", strconv.Itoa(int(shedder.RetryAfter().Seconds())))
+            response.Error(c, http.StatusServiceUnavailable, "Service is busy, please retry later")
+            c.Abort()
+            return
+        }
+        c.Next()
+    }
+}
@@ -1,14 +1,24 @@
 package middleware
 
 import (
+    "net/http"
     "time"
 
+    "auth-service/internal/config"
+
     "github.com/gin-gonic/gin"
     "go.uber.org/zap"
 )
 
-func Logger(logger *zap.SugaredLogger) gin.HandlerFunc {
+// Logger logs requests according to verbosity: every request, only those
+// that ended in an error status, or none.
+func Logger(logger *zap.SugaredLogger, verbosity string) gin.HandlerFunc {
     return func(c *gin.Context) {
+        if verbosity == config.RequestLogNone {
+            c.Next()
+            return
+        }
+
         start := time.Now()
         path := c.Request.URL.Path
         raw := c.Request.URL.RawQuery
@@ -20,6 +30,10 @@ func Logger(logger *zap.SugaredLogger) gin.HandlerFunc {
         method := c.Request.Method
         statusCode := c.Writer.Status()
 
+        if verbosity == config.RequestLogErrors && statusCode < http.StatusBadRequest {
+            return
+        }
+
         if raw != "" {
             path = path + "?" + raw
         }
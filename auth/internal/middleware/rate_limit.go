@@ -5,7 +5,14 @@ import (
     "sync"
     "time"
 
+    "auth-service/internal/config"
+    "auth-service/internal/metrics"
+    "auth-service/internal/models"
+    "auth-service/internal/response"
+    "auth-service/internal/services"
+
     "github.com/gin-gonic/gin"
+    "go.uber.org/zap"
     "golang.org/x/time/rate"
 )
 
@@ -19,24 +26,35 @@ var (
     mu       sync.RWMutex
 )
 
-func RateLimit(ratePerMinute int) gin.HandlerFunc {
+// RateLimit applies the global per-IP limit, scaled or lifted for the
+// caller's role by policy (rule name "global"). It runs before per-route
+// authentication, so only service callers identified by IdentifyService
+// are told apart from anonymous ones.
+func RateLimit(ratePerMinute int, policy *services.RateLimitPolicyService) gin.HandlerFunc {
     go cleanupVisitors()
 
     return func(c *gin.Context) {
-        ip := c.ClientIP()
+        role := rateLimitRole(c, policy, nil)
+        multiplier, exempt := policy.Resolve(role, models.RateLimitRuleGlobal)
+        if exempt {
+            c.Next()
+            return
+        }
+        perMinute := scaledLimit(ratePerMinute, multiplier)
+        key := role + ":" + c.ClientIP()
         
         mu.Lock()
-        v, exists := visitors[ip]
+        v, exists := visitors[key]
         if !exists {
-            limiter := rate.NewLimiter(rate.Limit(ratePerMinute)/60, ratePerMinute)
-            visitors[ip] = &visitor{limiter, time.Now()}
-            v = visitors[ip]
+            limiter := rate.NewLimiter(rate.Limit(perMinute)/60, perMinute)
+            visitors[key] = &visitor{limiter, time.Now()}
+            v = visitors[key]
         }
         v.lastSeen = time.Now()
         mu.Unlock()
 
-        if !v.limiter.Allow() {
-            c.JSON(http.StatusTooManyRequests, gin.H{"error": "Rate limit exceeded"})
+        if !allowAt(v.limiter, perMinute) {
+            response.Error(c, http.StatusTooManyRequests, "Rate limit exceeded")
             c.Abort()
             return
         }
@@ -57,4 +75,163 @@ func cleanupVisitors() {
         }
         mu.Unlock()
     }
-}
\ No newline at end of file
+}
+
+// RateLimitRules applies the configured per-endpoint rules. Rules in shadow
+// mode never block: requests over the limit are logged and counted in
+// auth_rate_limit_exceeded_total with mode="shadow" instead. Each rule keeps
+// one bucket per IP and caller role, shared by every route the rule is
+// attached to. The role is resolved when the rule runs, so rules attached
+// after Auth see authenticated users; policy can scale or lift the rule per
+// role.
+type RateLimitRules struct {
+    rules    map[string]config.RateLimitRule
+    limiters map[string]*ipLimiter
+    policy   *services.RateLimitPolicyService
+    users    *services.UserService
+    logger   *zap.SugaredLogger
+}
+
+func NewRateLimitRules(rules map[string]config.RateLimitRule, policy *services.RateLimitPolicyService, users *services.UserService, logger *zap.SugaredLogger) *RateLimitRules {
+    limiters := make(map[string]*ipLimiter, len(rules))
+    for name := range rules {
+        limiters[name] = newIPLimiter()
+    }
+
+    return &RateLimitRules{
+        rules:    rules,
+        limiters: limiters,
+        policy:   policy,
+        users:    users,
+        logger:   logger,
+    }
+}
+
+// For returns the middleware for the named rule, or a pass-through if no
+// such rule is configured.
+func (r *RateLimitRules) For(name string) gin.HandlerFunc {
+    rule, ok := r.rules[name]
+    if !ok {
+        return func(c *gin.Context) { c.Next() }
+    }
+
+    limiter := r.limiters[name]
+    mode := "enforce"
+    if rule.Shadow {
+        mode = "shadow"
+    }
+
+    return func(c *gin.Context) {
+        ip := c.ClientIP()
+        role := rateLimitRole(c, r.policy, r.users)
+        multiplier, exempt := r.policy.Resolve(role, rule.Name)
+        if exempt || limiter.allow(role+":"+ip, scaledLimit(rule.PerMinute, multiplier)) {
+            c.Next()
+            return
+        }
+
+        metrics.RateLimitExceeded.WithLabelValues(rule.Name, mode).Inc()
+
+        if rule.Shadow {
+            r.logger.Infow("Shadow rate limit rule would block request",
+                "rule", rule.Name,
+                "role", role,
+                "ip", ip,
+                "path", c.FullPath(),
+            )
+            c.Next()
+            return
+        }
+
+        response.Error(c, http.StatusTooManyRequests, "Rate limit exceeded")
+        c.Abort()
+    }
+}
+
+// ipLimiter is a token bucket per client IP (and role) for a single rule.
+type ipLimiter struct {
+    mu       sync.Mutex
+    visitors map[string]*visitor
+}
+
+func newIPLimiter() *ipLimiter {
+    l := &ipLimiter{
+        visitors: make(map[string]*visitor),
+    }
+    go l.cleanup()
+    return l
+}
+
+func (l *ipLimiter) allow(key string, perMinute int) bool {
+    l.mu.Lock()
+    v, exists := l.visitors[key]
+    if !exists {
+        v = &visitor{limiter: rate.NewLimiter(rate.Limit(perMinute)/60, perMinute)}
+        l.visitors[key] = v
+    }
+    v.lastSeen = time.Now()
+    l.mu.Unlock()
+
+    return allowAt(v.limiter, perMinute)
+}
+
+func (l *ipLimiter) cleanup() {
+    for {
+        time.Sleep(time.Minute)
+
+        l.mu.Lock()
+        for ip, v := range l.visitors {
+            if time.Since(v.lastSeen) > 3*time.Minute {
+                delete(l.visitors, ip)
+            }
+        }
+        l.mu.Unlock()
+    }
+}
+
+// allowAt takes a token from limiter after adjusting it to perMinute, which
+// changes when the rate limit policy does.
+func allowAt(limiter *rate.Limiter, perMinute int) bool {
+    if limiter.Burst() != perMinute {
+        limiter.SetLimit(rate.Limit(perMinute) / 60)
+        limiter.SetBurst(perMinute)
+    }
+    return limiter.Allow()
+}
+
+// scaledLimit multiplies a per-minute limit, never going below one request.
+func scaledLimit(perMinute int, multiplier float64) int {
+    scaled := int(float64(perMinute) * multiplier)
+    if scaled < 1 {
+        return 1
+    }
+    return scaled
+}
+
+// rateLimitRole resolves the caller's role from what authentication
+// middleware earlier in the chain left on the context. Telling admins from
+// other users costs a user lookup, so it is only done when users is set and
+// the policy has overrides for admins.
+func rateLimitRole(c *gin.Context, policy *services.RateLimitPolicyService, users *services.UserService) string {
+    if _, ok := c.Get("api_key"); ok {
+        return models.RateLimitRoleService
+    }
+    if _, ok := c.Get("signing_key"); ok {
+        return models.RateLimitRoleService
+    }
+    if _, ok := c.Get("admin"); ok {
+        return models.RateLimitRoleAdmin
+    }
+
+    claims, ok := c.Get("claims")
+    if !ok {
+        return models.RateLimitRoleAnonymous
+    }
+    if users != nil && policy.HasRole(models.RateLimitRoleAdmin) {
+        tokenClaims := claims.(*services.TokenClaims)
+        if user, err := users.GetUserByID(c.Request.Context(), tokenClaims.UserID); err == nil && user.Role == models.RoleAdmin {
+            return models.RateLimitRoleAdmin
+        }
+    }
+    return models.RateLimitRoleUser
+}
@@ -0,0 +1,114 @@
+package middleware
+
+import (
+    "bytes"
+    "encoding/json"
+    "io"
+    "net/http"
+    "strings"
+
+    "auth-service/internal/response"
+    "auth-service/internal/validation"
+
+    "github.com/gin-gonic/gin"
+    "go.uber.org/zap"
+)
+
+// SchemaEndpoint is the schema of one endpoint: the model its JSON body is
+// bound to and the model of its response for each status. Nil Request and
+// statuses without a model aren't checked.
+type SchemaEndpoint struct {
+    Request   interface{}
+    Responses map[int]interface{}
+}
+
+// Schema checks JSON request bodies against the schemas of their endpoints,
+// keyed by method and route without the /api/vN prefix, e.g.
+// "POST /auth/login". Bodies with unknown fields or values of the wrong type
+// are rejected with the same 400 as binding errors. With checkResponses,
+// responses are checked too and mismatches logged, to catch drift between the
+// models and what the handlers actually send.
+func Schema(endpoints map[string]SchemaEndpoint, checkResponses bool, logger *zap.SugaredLogger) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        route := c.Request.Method + " " + schemaPath(c.FullPath())
+        endpoint, ok := endpoints[route]
+        if !ok {
+            c.Next()
+            return
+        }
+
+        if endpoint.Request != nil && c.Request.Body != nil {
+            body, err := io.ReadAll(c.Request.Body)
+            if err != nil {
+                response.Error(c, http.StatusBadRequest, "Invalid request body")
+                c.Abort()
+                return
+            }
+            c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+            // Empty bodies are left to binding, which reports them
+            if len(bytes.TrimSpace(body)) > 0 {
+                if err := validation.CheckSchema(body, endpoint.Request); err != nil {
+                    message, fields := validation.Translate(err, c.GetHeader("Accept-Language"))
+                    response.ErrorWithDetails(c, http.StatusBadRequest, message, gin.H{"fields": fields})
+                    c.Abort()
+                    return
+                }
+            }
+        }
+
+        if !checkResponses || len(endpoint.Responses) == 0 {
+            c.Next()
+            return
+        }
+
+        writer := &recordingWriter{ResponseWriter: c.Writer}
+        c.Writer = writer
+        c.Next()
+
+        model, ok := endpoint.Responses[writer.Status()]
+        if !ok {
+            return
+        }
+        body := writer.body.Bytes()
+        if response.Version(c) == response.V2 {
+            var envelope struct {
+                Data json.RawMessage `json:"data"`
+            }
+            if err := json.Unmarshal(body, &envelope); err != nil {
+                logger.Warnw("Response is not a valid envelope", "route", route, "status", writer.Status(), "error", err)
+                return
+            }
+            body = envelope.Data
+        }
+        if err := validation.CheckSchema(body, model); err != nil {
+            logger.Warnw("Response does not match schema", "route", route, "status", writer.Status(), "error", err)
+        }
+    }
+}
+
+// schemaPath strips the API version prefix from a route.
+func schemaPath(route string) string {
+    for _, prefix := range []string{"/api/v1/", "/api/v2/"} {
+        if strings.HasPrefix(route, prefix) {
+            return route[len(prefix)-1:]
+        }
+    }
+    return route
+}
+
+// recordingWriter keeps a copy of the response body as it is written.
+type recordingWriter struct {
+    gin.ResponseWriter
+    body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+    w.body.Write(data)
+    return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+    w.body.WriteString(s)
+    return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+    "net/http"
+
+    "auth-service/internal/response"
+    "auth-service/internal/services"
+
+    "github.com/gin-gonic/gin"
+)
+
+// RequireSudo must run after Auth. It blocks sensitive operations unless the
+// access token was elevated through POST /auth/sudo within the sudo TTL.
+func RequireSudo(authService *services.AuthService) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        claims, _ := c.Get("claims")
+        tokenClaims := claims.(*services.TokenClaims)
+
+        elevated, err := authService.IsElevated(c.Request.Context(), tokenClaims.UserID, tokenClaims.ID)
+        if err != nil {
+            response.Error(c, http.StatusInternalServerError, "Internal server error")
+            c.Abort()
+            return
+        }
+
+        if !elevated {
+            response.ErrorWithDetails(c, http.StatusForbidden, "Sudo required", gin.H{
+                "sudo_required": true,
+            })
+            c.Abort()
+            return
+        }
+
+        c.Next()
+    }
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+    "time"
+
+    "github.com/google/uuid"
+)
+
+// Support flags admins can set on an account
+const (
+    AccountFlagVIP            = "vip"
+    AccountFlagChargebackRisk = "chargeback_risk"
+)
+
+// AccountNote is an internal note on an account, or a support flag when Flag
+// is set. Users never see notes outside their data export, and restricted
+// notes are only shown to the admins allowed to read them.
+type AccountNote struct {
+    ID           uuid.UUID  `json:"id"`
+    UserID       uuid.UUID  `json:"user_id"`
+    Flag         *string    `json:"flag"`
+    Body         string     `json:"body"`
+    Restricted   bool       `json:"restricted"`
+    ExportExempt bool       `json:"export_exempt"`
+    AuthorID     *uuid.UUID `json:"author_id"`
+    CreatedAt    time.Time  `json:"created_at"`
+}
+
+// CreateAccountNoteRequest adds a note, a flag, or a flag with a note.
+// ExportExempt should only be set where the law allows withholding the note
+// from the user, e.g. for fraud prevention.
+type CreateAccountNoteRequest struct {
+    Flag         string `json:"flag" binding:"omitempty,oneof=vip chargeback_risk"`
+    Body         string `json:"body" binding:"required_without=Flag,max=2000"`
+    Restricted   bool   `json:"restricted"`
+    ExportExempt bool   `json:"export_exempt"`
+}
+
+// ExportedAccountNote is a note as it appears in the user's data export,
+// without who wrote it.
+type ExportedAccountNote struct {
+    Flag      *string   `json:"flag"`
+    Body      string    `json:"body"`
+    CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+    "encoding/json"
+    "time"
+    "github.com/google/uuid"
+)
+
+const (
+    AdminActionCreateAPIKey         = "api_key.create"
+    AdminActionRevokeAPIKey         = "api_key.revoke"
+    AdminActionApproveRecovery      = "recovery.approve"
+    AdminActionRejectRecovery       = "recovery.reject"
+    AdminActionCreateIPBan          = "ip_ban.create"
+    AdminActionDeleteIPBan          = "ip_ban.delete"
+    AdminActionExportTokenFamily    = "token_family.export"
+    AdminActionSetGeoBlockExempt    = "user.geo_block_exempt"
+    AdminActionSetPlan              = "user.plan"
+    AdminActionPurgeUserState       = "user.purge_redis_state"
+    AdminActionSetRateLimitPolicy   = "rate_limit_policy.set"
+    AdminActionRevokeSessions       = "sessions.revoke"
+    AdminActionReloadSigningKey     = "signing_key.reload"
+    AdminActionReload               = "config.reload"
+    AdminActionDismissImpersonation = "impersonation.dismiss"
+    AdminActionRevertImpersonation  = "impersonation.revert"
+    AdminActionGrantBadge           = "user.badge_grant"
+    AdminActionRevokeBadge          = "user.badge_revoke"
+    AdminActionCreateAccountNote    = "account_note.create"
+    AdminActionDeleteAccountNote    = "account_note.delete"
+    AdminActionListAccountNotes     = "account_note.list"
+    AdminActionClearSentEmails      = "sent_emails.clear"
+    AdminActionAdvanceTestClock     = "test_clock.advance"
+    AdminActionResetTestClock       = "test_clock.reset"
+
+    AuditTargetAPIKey              = "api_key"
+    AuditTargetRecoveryRequest     = "recovery_request"
+    AuditTargetIPBan               = "ip_ban"
+    AuditTargetTokenFamily         = "token_family"
+    AuditTargetUser                = "user"
+    AuditTargetRateLimitPolicy     = "rate_limit_policy"
+    AuditTargetSessions            = "sessions"
+    AuditTargetSigningKey          = "signing_key"
+    AuditTargetReloadable          = "reloadable"
+    AuditTargetImpersonationReview = "impersonation_review"
+    AuditTargetAccountNote         = "account_note"
+    AuditTargetSentEmails          = "sent_emails"
+    AuditTargetTestClock           = "test_clock"
+)
+
+// AdminAuditEntry records one administrative action. Entries are append-only;
+// Before and After hold JSON snapshots of the target around the change.
+type AdminAuditEntry struct {
+    ID           uuid.UUID       `db:"id" json:"id"`
+    AdminID      uuid.UUID       `db:"admin_id" json:"admin_id"`
+    Action       string          `db:"action" json:"action"`
+    TargetType   string          `db:"target_type" json:"target_type"`
+    TargetID     string          `db:"target_id" json:"target_id"`
+    TargetUserID *uuid.UUID      `db:"target_user_id" json:"target_user_id,omitempty"`
+    Before       json.RawMessage `db:"before_state" json:"before,omitempty"`
+    After        json.RawMessage `db:"after_state" json:"after,omitempty"`
+    IP           string          `db:"ip" json:"ip,omitempty"`
+    UserAgent    string          `db:"user_agent" json:"user_agent,omitempty"`
+    CreatedAt    time.Time       `db:"created_at" json:"created_at"`
+}
+
+type AdminAuditFilter struct {
+    AdminID      *uuid.UUID
+    TargetUserID *uuid.UUID
+    Limit        int
+}
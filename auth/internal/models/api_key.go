@@ -0,0 +1,38 @@
+package models
+
+import (
+    "time"
+    "github.com/google/uuid"
+)
+
+type APIKey struct {
+    ID           uuid.UUID  `db:"id" json:"id"`
+    Name         string     `db:"name" json:"name"`
+    KeyPrefix    string     `db:"key_prefix" json:"key_prefix"`
+    KeyHash      string     `db:"key_hash" json:"-"`
+    DailyQuota   int        `db:"daily_quota" json:"daily_quota"`
+    MonthlyQuota int        `db:"monthly_quota" json:"monthly_quota"`
+    CreatedAt    time.Time  `db:"created_at" json:"created_at"`
+    RevokedAt    *time.Time `db:"revoked_at" json:"revoked_at,omitempty"`
+}
+
+type APIKeyUsage struct {
+    KeyID          uuid.UUID `json:"key_id"`
+    DailyCount     int64     `json:"daily_count"`
+    DailyQuota     int       `json:"daily_quota"`
+    DailyResetAt   time.Time `json:"daily_reset_at"`
+    MonthlyCount   int64     `json:"monthly_count"`
+    MonthlyQuota   int       `json:"monthly_quota"`
+    MonthlyResetAt time.Time `json:"monthly_reset_at"`
+}
+
+type CreateAPIKeyRequest struct {
+    Name         string `json:"name" binding:"required,max=100"`
+    DailyQuota   int    `json:"daily_quota" binding:"min=0"`
+    MonthlyQuota int    `json:"monthly_quota" binding:"min=0"`
+}
+
+type CreateAPIKeyResponse struct {
+    APIKey *APIKey `json:"api_key"`
+    Key    string  `json:"key"`
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+    "time"
+
+    "github.com/google/uuid"
+)
+
+// Badges shown on public profiles and carried in access tokens, in the order
+// they are listed. Email verification and MFA are computed from the account;
+// phone and venue verification are granted by an admin, since this service
+// doesn't keep phone numbers or venues.
+const (
+    BadgeEmailVerified = "email_verified"
+    BadgePhoneVerified = "phone_verified"
+    BadgeMFAEnabled    = "mfa_enabled"
+    BadgeVenueVerified = "venue_verified"
+)
+
+var Badges = []string{
+    BadgeEmailVerified,
+    BadgePhoneVerified,
+    BadgeMFAEnabled,
+    BadgeVenueVerified,
+}
+
+// IsGrantableBadge reports whether badge is granted by an admin rather than
+// computed.
+func IsGrantableBadge(badge string) bool {
+    return badge == BadgePhoneVerified || badge == BadgeVenueVerified
+}
+
+// BadgeGrant is a badge granted to a user by an admin.
+type BadgeGrant struct {
+    Badge     string     `json:"badge"`
+    GrantedBy *uuid.UUID `json:"granted_by"`
+    GrantedAt time.Time  `json:"granted_at"`
+}
+
+// UserBadges lists a user's badges and, for the admin API, which of them
+// were granted and by whom.
+type UserBadges struct {
+    UserID uuid.UUID     `json:"user_id"`
+    Badges []string      `json:"badges"`
+    Grants []*BadgeGrant `json:"grants"`
+}
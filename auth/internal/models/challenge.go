@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// Login challenge types, in the order they are asked for
+const (
+    ChallengeCaptcha   = "captcha"
+    ChallengeEmailCode = "email_code"
+    ChallengeTOTP      = "totp"
+    ChallengeTOS       = "tos"
+)
+
+// LoginChallenge is returned instead of tokens while a login still has
+// challenges to pass. Challenges lists them in the order they must be
+// answered; the first is the current one.
+type LoginChallenge struct {
+    Token      string    `json:"challenge_token"`
+    Challenges []string  `json:"challenges"`
+    ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// ChallengeAnswer answers the current challenge: the captcha provider's
+// token, the emailed or TOTP code, or the accepted terms of service version.
+type ChallengeAnswer struct {
+    ChallengeToken string `json:"challenge_token" binding:"required"`
+    Response       string `json:"response" binding:"required,max=4096"`
+}
+
+type TOTPEnrollment struct {
+    Secret string `json:"secret"`
+    URI    string `json:"otpauth_uri"`
+}
+
+type TOTPCodeRequest struct {
+    Code string `json:"code" binding:"required,len=6,numeric"`
+}
+
+// SudoRequest re-confirms the user's credentials before sensitive
+// operations. Code is required when TOTP is enabled.
+type SudoRequest struct {
+    Password string `json:"password" binding:"required"`
+    Code     string `json:"code" binding:"omitempty,len=6,numeric"`
+}
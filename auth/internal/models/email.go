@@ -0,0 +1,28 @@
+package models
+
+import (
+    "time"
+)
+
+// Email is a rendered templated email. Data holds the values filled into
+// the template, such as the verification token.
+type Email struct {
+    To       string            `json:"to"`
+    Template string            `json:"template"`
+    Subject  string            `json:"subject"`
+    Body     string            `json:"body"`
+    Data     map[string]string `json:"data"`
+}
+
+// SentEmail is an email captured by the sandbox mailer.
+type SentEmail struct {
+    ID        int64     `db:"id" json:"id"`
+    Email
+    CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+type SentEmailFilter struct {
+    To       string `form:"to"`
+    Template string `form:"template"`
+    Limit    int    `form:"limit" binding:"omitempty,min=1,max=500"`
+}
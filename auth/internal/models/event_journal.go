@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// JournalEvent is a published user event with its position in the journal.
+type JournalEvent struct {
+    ID        int64                  `json:"id"`
+    Type      string                 `json:"type"`
+    UserID    string                 `json:"user_id"`
+    Username  string                 `json:"username"`
+    Timestamp time.Time              `json:"timestamp"`
+    Data      map[string]interface{} `json:"data,omitempty"`
+}
+
+// JournalQuery pages through the journal. Types narrows it to some event
+// types; an empty list means all of them.
+type JournalQuery struct {
+    After int64    `form:"after" binding:"min=0"`
+    Limit int      `form:"limit" binding:"omitempty,min=1,max=1000"`
+    Types []string `form:"type"`
+}
+
+type JournalPage struct {
+    Events []*JournalEvent `json:"events"`
+    // Pass as after to get the next page. It equals after when there were
+    // no new events.
+    NextAfter int64 `json:"next_after"`
+    HasMore   bool  `json:"has_more"`
+}
@@ -0,0 +1,12 @@
+package models
+
+import (
+    "time"
+)
+
+type FunnelDailyStat struct {
+    Day        time.Time `db:"day" json:"day"`
+    Step       string    `db:"step" json:"step"`
+    ClientType string    `db:"client_type" json:"client_type"`
+    Count      int       `db:"count" json:"count"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+    "time"
+)
+
+// IntegrityReport is the outcome of one run of the integrity checks.
+type IntegrityReport struct {
+    CheckedAt time.Time          `json:"checked_at"`
+    Repair    bool               `json:"repair"`
+    Anomalies []IntegrityAnomaly `json:"anomalies"`
+}
+
+// IntegrityAnomaly counts the inconsistent rows one check found, and how
+// many of them were repaired.
+type IntegrityAnomaly struct {
+    Check       string `json:"check"`
+    Description string `json:"description"`
+    Count       int64  `json:"count"`
+    Repaired    int64  `json:"repaired"`
+}
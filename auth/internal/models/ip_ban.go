@@ -0,0 +1,24 @@
+package models
+
+import (
+    "time"
+)
+
+const (
+    IPBanSourceAuto  = "auto"
+    IPBanSourceAdmin = "admin"
+)
+
+type IPBan struct {
+    CIDR      string    `json:"cidr"`
+    Reason    string    `json:"reason"`
+    Source    string    `json:"source"`
+    CreatedAt time.Time `json:"created_at"`
+    ExpiresAt time.Time `json:"expires_at"`
+}
+
+type CreateIPBanRequest struct {
+    CIDR       string `json:"cidr" binding:"required"`
+    Reason     string `json:"reason" binding:"max=255"`
+    TTLSeconds int    `json:"ttl_seconds" binding:"required,min=1,max=2592000"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+    "time"
+
+    "github.com/google/uuid"
+)
+
+// LoginFailure is one failed login in the audit log. UserID is nil when no
+// account was identified.
+type LoginFailure struct {
+    ID         int64      `db:"id" json:"id"`
+    UserID     *uuid.UUID `db:"user_id" json:"user_id,omitempty"`
+    Reason     string     `db:"reason" json:"reason"`
+    ClientType string     `db:"client_type" json:"client_type"`
+    IP         string     `db:"ip" json:"ip,omitempty"`
+    UserAgent  string     `db:"user_agent" json:"user_agent,omitempty"`
+    CreatedAt  time.Time  `db:"created_at" json:"created_at"`
+}
+
+// LoginFailureStat counts the failures with one reason on one UTC day.
+type LoginFailureStat struct {
+    Day    time.Time `db:"day" json:"day"`
+    Reason string    `db:"reason" json:"reason"`
+    Count  int       `db:"count" json:"count"`
+}
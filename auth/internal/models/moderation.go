@@ -0,0 +1,74 @@
+package models
+
+import (
+    "time"
+
+    "github.com/google/uuid"
+)
+
+// Moderation outcomes. Flagged content is accepted but checked again later;
+// denied content is rejected.
+const (
+    ModerationAllow = "allow"
+    ModerationFlag  = "flag"
+    ModerationDeny  = "deny"
+)
+
+// Fields screened by content moderation
+const (
+    ModerationFieldUsername    = "username"
+    ModerationFieldDisplayName = "display_name"
+    // Only screened for impersonation, by its local part
+    ModerationFieldEmail = "email"
+)
+
+// Where a denial came from: a rejected change, or a re-check that reverted
+// content accepted earlier.
+const (
+    ModerationSourceChange  = "change"
+    ModerationSourceRecheck = "recheck"
+    ModerationSourceReview  = "review"
+)
+
+type ModerationResult struct {
+    Outcome string `json:"outcome"`
+    Reason  string `json:"reason,omitempty"`
+}
+
+type ModerationDenial struct {
+    ID        uuid.UUID  `json:"id"`
+    UserID    *uuid.UUID `json:"user_id,omitempty"`
+    Field     string     `json:"field"`
+    Content   string     `json:"content"`
+    Provider  string     `json:"provider"`
+    Reason    *string    `json:"reason,omitempty"`
+    Source    string     `json:"source"`
+    CreatedAt time.Time  `json:"created_at"`
+}
+
+type ModerationDenialFilter struct {
+    UserID *uuid.UUID `form:"-"`
+    Limit  int        `form:"limit" binding:"omitempty,min=1,max=500"`
+}
+
+// Impersonation review statuses. Reviews start pending; an admin dismisses
+// them or reverts the content.
+const (
+    ImpersonationReviewPending   = "pending"
+    ImpersonationReviewDismissed = "dismissed"
+    ImpersonationReviewReverted  = "reverted"
+)
+
+// ImpersonationReview is content that looks like a protected handle without
+// matching it outright, waiting for an admin to decide.
+type ImpersonationReview struct {
+    ID              uuid.UUID  `json:"id"`
+    UserID          uuid.UUID  `json:"user_id"`
+    Field           string     `json:"field"`
+    Content         string     `json:"content"`
+    ProtectedHandle string     `json:"protected_handle"`
+    Status          string     `json:"status"`
+    ReviewedBy      *uuid.UUID `json:"reviewed_by,omitempty"`
+    ReviewedAt      *time.Time `json:"reviewed_at,omitempty"`
+    CreatedAt       time.Time  `json:"created_at"`
+}
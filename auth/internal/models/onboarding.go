@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Onboarding milestones, in the order new users usually reach them
+const (
+    MilestoneRegistered       = "registered"
+    MilestoneVerified         = "verified"
+    MilestoneProfileCompleted = "profile_completed"
+    MilestoneFirstChatJoined  = "first_chat_joined"
+)
+
+var OnboardingMilestones = []string{
+    MilestoneRegistered,
+    MilestoneVerified,
+    MilestoneProfileCompleted,
+    MilestoneFirstChatJoined,
+}
+
+type OnboardingMilestone struct {
+    Name      string     `json:"name"`
+    Reached   bool       `json:"reached"`
+    ReachedAt *time.Time `json:"reached_at,omitempty"`
+}
+
+// OnboardingProgress lists every milestone, reached or not. Accounts created
+// before onboarding was tracked have no milestones.
+type OnboardingProgress struct {
+    Milestones []OnboardingMilestone `json:"milestones"`
+    Completed  bool                  `json:"completed"`
+}
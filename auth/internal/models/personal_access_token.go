@@ -0,0 +1,52 @@
+package models
+
+import (
+    "time"
+    "github.com/google/uuid"
+)
+
+// Scopes a personal access token can be granted. Each one opens a fixed set
+// of routes; every other route rejects personal access tokens.
+const (
+    TokenScopeProfileRead  = "profile:read"
+    TokenScopeProfileWrite = "profile:write"
+    TokenScopeUsersSearch  = "users:search"
+    TokenScopeBlocksWrite  = "blocks:write"
+)
+
+type PersonalAccessToken struct {
+    ID          uuid.UUID  `db:"id" json:"id"`
+    UserID      uuid.UUID  `db:"user_id" json:"user_id"`
+    Name        string     `db:"name" json:"name"`
+    TokenPrefix string     `db:"token_prefix" json:"token_prefix"`
+    TokenHash   string     `db:"token_hash" json:"-"`
+    Scopes      []string   `db:"scopes" json:"scopes"`
+    ExpiresAt   *time.Time `db:"expires_at" json:"expires_at,omitempty"`
+    LastUsedAt  *time.Time `db:"last_used_at" json:"last_used_at,omitempty"`
+    CreatedAt   time.Time  `db:"created_at" json:"created_at"`
+    RevokedAt   *time.Time `db:"revoked_at" json:"revoked_at,omitempty"`
+}
+
+// HasScope reports whether the token was granted scope.
+func (t *PersonalAccessToken) HasScope(scope string) bool {
+    for _, granted := range t.Scopes {
+        if granted == scope {
+            return true
+        }
+    }
+    return false
+}
+
+type CreatePersonalAccessTokenRequest struct {
+    Name   string   `json:"name" binding:"required,max=100"`
+    Scopes []string `json:"scopes" binding:"required,min=1,dive,oneof=profile:read profile:write users:search blocks:write"`
+    // Days until the token expires; 0 for a token that never does
+    ExpiresInDays int `json:"expires_in_days" binding:"min=0,max=365"`
+}
+
+// CreatePersonalAccessTokenResponse carries the plaintext token, which is
+// only ever returned here.
+type CreatePersonalAccessTokenResponse struct {
+    PersonalAccessToken *PersonalAccessToken `json:"personal_access_token"`
+    Token               string               `json:"token"`
+}
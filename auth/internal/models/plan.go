@@ -0,0 +1,36 @@
+package models
+
+// Plans a user can be on
+const (
+    PlanFree  = "free"
+    PlanPlus  = "plus"
+    PlanVenue = "venue"
+)
+
+// Entitlements are the limits of a plan that other TapIn services enforce.
+// They are embedded in access tokens so that needs no call back to this
+// service.
+type Entitlements struct {
+    Plan        string `json:"plan"`
+    MaxRoomSize int    `json:"max_room_size"`
+    PinnedChats int    `json:"pinned_chats"`
+}
+
+var planEntitlements = map[string]Entitlements{
+    PlanFree:  {Plan: PlanFree, MaxRoomSize: 50, PinnedChats: 1},
+    PlanPlus:  {Plan: PlanPlus, MaxRoomSize: 200, PinnedChats: 10},
+    PlanVenue: {Plan: PlanVenue, MaxRoomSize: 2000, PinnedChats: 50},
+}
+
+// EntitlementsFor returns the entitlements of plan. Unknown plans get the
+// free plan's.
+func EntitlementsFor(plan string) Entitlements {
+    if entitlements, ok := planEntitlements[plan]; ok {
+        return entitlements
+    }
+    return planEntitlements[PlanFree]
+}
+
+type SetPlanRequest struct {
+    Plan string `json:"plan" binding:"required,oneof=free plus venue"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+    "time"
+)
+
+// Caller roles that rate limit overrides apply to, resolved once the request
+// has been authenticated. Service callers use an API key or a request
+// signature.
+const (
+    RateLimitRoleAnonymous = "anonymous"
+    RateLimitRoleUser      = "user"
+    RateLimitRoleAdmin     = "admin"
+    RateLimitRoleService   = "service"
+)
+
+// Rule names an override can target besides the configured rate limit
+// rules: the global per-IP limit, and every rule at once.
+const (
+    RateLimitRuleGlobal = "global"
+    RateLimitRuleAll    = "*"
+)
+
+// RateLimitOverride scales or lifts one rate limit rule for one role. An
+// exempt role bypasses the rule; otherwise its limit is multiplied.
+type RateLimitOverride struct {
+    Role       string  `json:"role" binding:"required,oneof=anonymous user admin service"`
+    Rule       string  `json:"rule" binding:"required,max=50"`
+    Multiplier float64 `json:"multiplier,omitempty" binding:"omitempty,gt=0,lte=100"`
+    Exempt     bool    `json:"exempt,omitempty"`
+}
+
+// RateLimitPolicy holds the overrides set by admins. It is stored in Redis
+// and takes effect on every instance without a redeploy.
+type RateLimitPolicy struct {
+    Overrides []RateLimitOverride `json:"overrides" binding:"max=100,dive"`
+    UpdatedAt *time.Time          `json:"updated_at,omitempty"`
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+    "time"
+    "github.com/google/uuid"
+)
+
+const (
+    RecoveryMethodEmail        = "recovery_email"
+    RecoveryMethodCode         = "recovery_code"
+    RecoveryMethodManualReview = "manual_review"
+
+    RecoveryStatusPending   = "pending"
+    RecoveryStatusApproved  = "approved"
+    RecoveryStatusRejected  = "rejected"
+    RecoveryStatusCompleted = "completed"
+)
+
+type RecoveryRequest struct {
+    ID             uuid.UUID  `db:"id" json:"id"`
+    UserID         uuid.UUID  `db:"user_id" json:"user_id"`
+    Method         string     `db:"method" json:"method"`
+    Status         string     `db:"status" json:"status"`
+    ContactEmail   *string    `db:"contact_email" json:"contact_email,omitempty"`
+    Details        *string    `db:"details" json:"details,omitempty"`
+    TokenHash      *string    `db:"token_hash" json:"-"`
+    TokenExpiresAt *time.Time `db:"token_expires_at" json:"token_expires_at,omitempty"`
+    ReviewedBy     *uuid.UUID `db:"reviewed_by" json:"reviewed_by,omitempty"`
+    ReviewedAt     *time.Time `db:"reviewed_at" json:"reviewed_at,omitempty"`
+    CreatedAt      time.Time  `db:"created_at" json:"created_at"`
+}
+
+type StartRecoveryRequest struct {
+    Identifier   string `json:"identifier" binding:"required"`
+    Method       string `json:"method" binding:"required,oneof=recovery_email recovery_code manual_review"`
+    RecoveryCode string `json:"recovery_code" binding:"required_if=Method recovery_code"`
+    ContactEmail string `json:"contact_email" binding:"required_if=Method manual_review,omitempty,email"`
+    Details      string `json:"details" binding:"max=2000"`
+}
+
+type CompleteRecoveryRequest struct {
+    Token    string `json:"token" binding:"required"`
+    Email    string `json:"email" binding:"required,email"`
+    Password string `json:"password" binding:"required,password_length,strong_password"`
+}
+
+type RecoveryEmailRequest struct {
+    RecoveryEmail string `json:"recovery_email" binding:"required,email"`
+}
+
+type RecoveryCodesResponse struct {
+    Codes []string `json:"codes"`
+}
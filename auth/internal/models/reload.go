@@ -0,0 +1,10 @@
+package models
+
+// ReloadResult is the outcome of re-reading one reloadable resource: the JWT
+// signing key, the TLS certificate or the GeoIP database.
+type ReloadResult struct {
+    Name        string `json:"name"`
+    Changed     bool   `json:"changed"`
+    Fingerprint string `json:"fingerprint,omitempty"`
+    Error       string `json:"error,omitempty"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+    "time"
+)
+
+// TestClock is the service's clock while TEST_MODE is on.
+type TestClock struct {
+    Now           time.Time `json:"now"`
+    OffsetSeconds int64     `json:"offset_seconds"`
+}
+
+// AdvanceClockRequest moves the test clock forward. Going back isn't
+// supported, except to the system time by resetting the clock.
+type AdvanceClockRequest struct {
+    Seconds int64 `json:"seconds" binding:"required,min=1"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+    "time"
+    "github.com/google/uuid"
+)
+
+// RefreshTokenIssue is one link in a refresh token family. The token itself
+// is never stored; ReusedAt is set when an already rotated token is presented.
+type RefreshTokenIssue struct {
+    ID              uuid.UUID  `db:"id" json:"id"`
+    FamilyID        uuid.UUID  `db:"family_id" json:"family_id"`
+    ParentID        *uuid.UUID `db:"parent_id" json:"parent_id,omitempty"`
+    IP              string     `db:"ip" json:"ip"`
+    UserAgent       string     `db:"user_agent" json:"user_agent"`
+    IssuedAt        time.Time  `db:"issued_at" json:"issued_at"`
+    RotatedAt       *time.Time `db:"rotated_at" json:"rotated_at,omitempty"`
+    ReusedAt        *time.Time `db:"reused_at" json:"reused_at,omitempty"`
+    ReusedIP        *string    `db:"reused_ip" json:"reused_ip,omitempty"`
+    ReusedUserAgent *string    `db:"reused_user_agent" json:"reused_user_agent,omitempty"`
+}
+
+// TokenFamily summarizes the refresh tokens issued for one session.
+type TokenFamily struct {
+    FamilyID      uuid.UUID `json:"family_id"`
+    UserID        uuid.UUID `json:"user_id"`
+    Active        bool      `json:"active"`
+    Rotations     int       `json:"rotations"`
+    DistinctIPs   int       `json:"distinct_ips"`
+    ReuseDetected bool      `json:"reuse_detected"`
+    StartedAt     time.Time `json:"started_at"`
+    LastIssuedAt  time.Time `json:"last_issued_at"`
+}
+
+type TokenFamilyExport struct {
+    Family     *TokenFamily         `json:"family"`
+    Lineage    []*RefreshTokenIssue `json:"lineage"`
+    ExportedAt time.Time            `json:"exported_at"`
+}
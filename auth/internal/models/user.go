@@ -5,18 +5,31 @@ import (
     "github.com/google/uuid"
 )
 
+const (
+    RoleUser  = "user"
+    RoleAdmin = "admin"
+)
+
+// User is an account as stored. It never goes out as JSON: responses convert
+// it to PrivateUser or AdminUser, so a new column stays internal until one of
+// them is given a matching field.
 type User struct {
-    ID             uuid.UUID  `db:"id" json:"id"`
-    Email          string     `db:"email" json:"email"`
-    Username       string     `db:"username" json:"username"`
+    ID             uuid.UUID  `db:"id" json:"-"`
+    Email          string     `db:"email" json:"-"`
+    Username       string     `db:"username" json:"-"`
     PasswordHash   string     `db:"password_hash" json:"-"`
-    EmailVerified  bool       `db:"email_verified" json:"email_verified"`
-    EmailToken     *string    `db:"email_token" json:"-"`
+    EmailVerified  bool       `db:"email_verified" json:"-"`
+    EmailVerifiedAt *time.Time `db:"email_verified_at" json:"-"`
+    EmailBouncedAt *time.Time `db:"email_bounced_at" json:"-"`
+    IsGuest        bool       `db:"is_guest" json:"-"`
+    Role           string     `db:"role" json:"-"`
+    Plan           string     `db:"plan" json:"-"`
     ResetToken     *string    `db:"reset_token" json:"-"`
     ResetExpiry    *time.Time `db:"reset_expiry" json:"-"`
-    CreatedAt      time.Time  `db:"created_at" json:"created_at"`
-    UpdatedAt      time.Time  `db:"updated_at" json:"updated_at"`
-    LastLogin      *time.Time `db:"last_login" json:"last_login"`
+    CreatedAt      time.Time  `db:"created_at" json:"-"`
+    UpdatedAt      time.Time  `db:"updated_at" json:"-"`
+    LastLogin      *time.Time `db:"last_login" json:"-"`
+    DataRegion     string     `db:"data_region" json:"-"`
 }
 
 type Session struct {
@@ -25,14 +38,143 @@ type Session struct {
     RefreshToken string    `db:"refresh_token" json:"refresh_token"`
     UserAgent    string    `db:"user_agent" json:"user_agent"`
     IP           string    `db:"ip" json:"ip"`
+    ClientType   string    `db:"client_type" json:"client_type"`
+    Region       string    `db:"region" json:"region,omitempty"`
     ExpiresAt    time.Time `db:"expires_at" json:"expires_at"`
     CreatedAt    time.Time `db:"created_at" json:"created_at"`
+    // Sessions closed at login to stay within the session cap
+    Evicted []*SessionInfo `db:"-" json:"-"`
+}
+
+// Account deletion modes
+const (
+    DeletionModeDelete    = "delete"
+    DeletionModeAnonymize = "anonymize"
+    DeletionModeExport    = "export"
+)
+
+type DeleteAccountRequest struct {
+    Mode string `form:"mode" binding:"omitempty,oneof=delete anonymize export"`
+}
+
+// Account deletion stages, in the order they run. Access is revoked when
+// the deletion is requested; the rest is done by the deletion worker.
+const (
+    DeletionStageRevokeAccess = "revoke_access"
+    DeletionStagePurgeData    = "purge_data"
+    DeletionStagePurgeCache   = "purge_cache"
+    DeletionStageFinalize     = "finalize"
+)
+
+var DeletionStages = []string{
+    DeletionStageRevokeAccess,
+    DeletionStagePurgeData,
+    DeletionStagePurgeCache,
+    DeletionStageFinalize,
+}
+
+// Account deletion statuses
+const (
+    DeletionStatusPending   = "pending"
+    DeletionStatusCompleted = "completed"
+    DeletionStatusFailed    = "failed"
+)
+
+// AccountDeletion reports the progress of an account deletion.
+type AccountDeletion struct {
+    ID          uuid.UUID       `json:"id"`
+    Mode        string          `json:"mode"`
+    Status      string          `json:"status"`
+    Stages      []DeletionStage `json:"stages"`
+    RequestedAt time.Time       `json:"requested_at"`
+    CompletedAt *time.Time      `json:"completed_at,omitempty"`
+}
+
+type DeletionStage struct {
+    Name string `json:"name"`
+    Done bool   `json:"done"`
+}
+
+// AccountExport is the copy of an account's data returned by export-then-delete.
+type AccountExport struct {
+    User       *PrivateUser           `json:"user"`
+    Sessions   []*SessionInfo         `json:"sessions"`
+    Webhooks   []*UserWebhook         `json:"webhooks"`
+    // Admin notes and flags on the account, except those exempt from export
+    Notes      []*ExportedAccountNote `json:"notes"`
+    ExportedAt time.Time              `json:"exported_at"`
+}
+
+// SessionInfo describes one of a user's sessions without its refresh token.
+type SessionInfo struct {
+    ID         uuid.UUID `json:"id"`
+    Label      *string   `json:"label"`
+    Trusted    bool      `json:"trusted"`
+    UserAgent  string    `json:"user_agent"`
+    IP         string    `json:"ip"`
+    ClientType string    `json:"client_type"`
+    Region     string    `json:"region,omitempty"`
+    CreatedAt  time.Time `json:"created_at"`
+    ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// RevokeSessionsRequest selects sessions to revoke in bulk. Every criterion
+// that is set must match; at least one is required. UserAgent is matched
+// whole, with * as a wildcard.
+type RevokeSessionsRequest struct {
+    CreatedBefore *time.Time `json:"created_before"`
+    CIDR          string     `json:"cidr" binding:"omitempty,max=64"`
+    UserAgent     string     `json:"user_agent" binding:"omitempty,max=512"`
+    DryRun        bool       `json:"dry_run"`
+}
+
+// SessionRevocation reports a bulk revocation. A dry run only counts the
+// matching sessions and shows a sample of them.
+type SessionRevocation struct {
+    DryRun  bool           `json:"dry_run"`
+    Matched int            `json:"matched"`
+    Revoked int            `json:"revoked"`
+    Users   int            `json:"users"`
+    Sample  []*SessionInfo `json:"sample,omitempty"`
+}
+
+// Why sessions were revoked, in forced logout notifications
+const (
+    LogoutReasonLogoutAll      = "logout_all"
+    LogoutReasonRefreshReuse   = "refresh_reuse"
+    LogoutReasonAdminRevoked   = "admin_revoked"
+    LogoutReasonRecovered      = "account_recovered"
+    LogoutReasonAccountDeleted = "account_deleted"
+    LogoutReasonSessionCap     = "session_cap"
+)
+
+// ForcedLogout tells the gateway and chat service to disconnect the clients
+// of a revoked session, or of every session of the user when SessionID is
+// nil.
+type ForcedLogout struct {
+    UserID    uuid.UUID  `json:"user_id"`
+    SessionID *uuid.UUID `json:"session_id,omitempty"`
+    Reason    string     `json:"reason"`
+    At        time.Time  `json:"at"`
+}
+
+// UpdateSessionRequest changes only the fields that are set.
+type UpdateSessionRequest struct {
+    Label   *string `json:"label" binding:"omitempty,max=100"`
+    Trusted *bool   `json:"trusted"`
 }
 
 type RegisterRequest struct {
     Email    string `json:"email" binding:"required,email"`
-    Username string `json:"username" binding:"required,min=3,max=50"`
-    Password string `json:"password" binding:"required,min=8"`
+    Username string `json:"username" binding:"required,username_length,username_charset"`
+    Password string `json:"password" binding:"required,password_length,strong_password"`
+    // Code from /auth/start-registration, required when registration email
+    // codes are enabled
+    EmailCode string `json:"email_code" binding:"omitempty,len=6,numeric"`
+}
+
+type StartRegistrationRequest struct {
+    Email string `json:"email" binding:"required,email"`
 }
 
 type LoginRequest struct {
@@ -40,12 +182,173 @@ type LoginRequest struct {
     Password string `json:"password" binding:"required"`
 }
 
+// ConfirmLoginRequest carries the token from a travel mode confirmation email.
+type ConfirmLoginRequest struct {
+    Token string `json:"token" binding:"required"`
+}
+
+// EmailRequest is the body of endpoints that only take an address, such as
+// forgot-password.
+type EmailRequest struct {
+    Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+    Token    string `json:"token" binding:"required"`
+    Password string `json:"password" binding:"required,password_length,strong_password"`
+}
+
+type UpdateProfileRequest struct {
+    Username string `json:"username" binding:"required,username_length,username_charset"`
+}
+
+type ChangePasswordRequest struct {
+    OldPassword string `json:"old_password" binding:"required"`
+    NewPassword string `json:"new_password" binding:"required,password_length,strong_password"`
+}
+
+type TravelModeRequest struct {
+    Days int `json:"days" binding:"required,min=1,max=30"`
+}
+
+// TokenType is the OAuth 2.0 token type of issued access tokens
+const TokenType = "Bearer"
+
+// TokenResponse carries the session an access token belongs to alongside the
+// tokens, so clients and the API gateway don't have to decode the JWT.
 type TokenResponse struct {
-    AccessToken  string    `json:"access_token"`
-    RefreshToken string    `json:"refresh_token"`
-    ExpiresAt    time.Time `json:"expires_at"`
+    AccessToken  string        `json:"access_token"`
+    RefreshToken string        `json:"refresh_token"`
+    TokenType    string        `json:"token_type"`
+    ExpiresAt    time.Time     `json:"expires_at"`
+    ExpiresIn    int64         `json:"expires_in"`
+    SessionID    uuid.UUID     `json:"session_id"`
+    Device       SessionDevice `json:"device"`
+    // Only set by refreshes
+    Rotation *RefreshRotation `json:"rotation,omitempty"`
+    // Only set by logins that signed out other devices to stay within the
+    // session cap
+    EvictedSessions []*SessionInfo `json:"evicted_sessions,omitempty"`
+}
+
+// RefreshRotation reports what a refresh did to the session, so clients can
+// reconcile their stored state. The session keeps its ID and expiry; the
+// refresh token presented is revoked, and presenting it again revokes the
+// whole session.
+type RefreshRotation struct {
+    SessionID            uuid.UUID `json:"session_id"`
+    SessionExpiresAt     time.Time `json:"session_expires_at"`
+    PreviousTokenRevoked bool      `json:"previous_token_revoked"`
+}
+
+// SessionDevice echoes the device a session was opened from.
+type SessionDevice struct {
+    UserAgent  string `json:"user_agent"`
+    IP         string `json:"ip"`
+    ClientType string `json:"client_type"`
+    Region     string `json:"region,omitempty"`
+}
+
+type ScheduledTokenRequest struct {
+    UserID          uuid.UUID `json:"user_id" binding:"required"`
+    NotBefore       time.Time `json:"not_before" binding:"required"`
+    ValidForSeconds int       `json:"valid_for_seconds" binding:"min=0,max=604800"`
+}
+
+type ViewOnlyTokenResponse struct {
+    AccessToken string    `json:"access_token"`
+    Scope       string    `json:"scope"`
+    ExpiresAt   time.Time `json:"expires_at"`
+}
+
+type ScheduledTokenResponse struct {
+    AccessToken string    `json:"access_token"`
+    NotBefore   time.Time `json:"not_before"`
+    ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// PublicUser is what user search reveals about another user.
+type PublicUser struct {
+    Handle      string  `json:"handle"`
+    DisplayName *string `json:"display_name"`
+    AvatarURL   *string `json:"avatar_url"`
+}
+
+// UserSearchRequest is the query of the user search.
+type UserSearchRequest struct {
+    Query string `form:"q" binding:"required,min=2,max=50"`
+    Limit int    `form:"limit" binding:"omitempty,min=1,max=20"`
+}
+
+// PublicProfileRequest changes only the fields that are set. An empty
+// display_name, avatar_url or date_of_birth clears it. date_of_birth
+// (YYYY-MM-DD) is never shown to other users.
+type PublicProfileRequest struct {
+    DisplayName  *string `json:"display_name" binding:"omitempty,display_name_length"`
+    AvatarURL    *string `json:"avatar_url" binding:"omitempty,max=2048,safe_url"`
+    DateOfBirth  *string `json:"date_of_birth" binding:"omitempty,datetime=2006-01-02"`
+    Discoverable *bool   `json:"discoverable"`
+    PublicCard   *bool   `json:"public_card"`
+}
+
+// PublicProfileCard is what share links show to anyone, signed in or not.
+// Only users who turned on public_card have one.
+type PublicProfileCard struct {
+    Handle      string   `json:"handle"`
+    DisplayName *string  `json:"display_name"`
+    AvatarURL   *string  `json:"avatar_url"`
+    Badges      []string `json:"badges"`
+}
+
+// BatchUserRequest is the body of the internal batch user lookup.
+type BatchUserRequest struct {
+    IDs []uuid.UUID `json:"ids" binding:"required,min=1,max=100"`
+}
+
+// SnapshotRepublish reports a request to re-publish user snapshots. Missing
+// lists requested users that don't exist (anymore).
+type SnapshotRepublish struct {
+    Published int         `json:"published"`
+    Missing   []uuid.UUID `json:"missing"`
+}
+
+type GeoBlockExemptRequest struct {
+    Exempt *bool `json:"exempt" binding:"required"`
 }
 
 type RefreshRequest struct {
     RefreshToken string `json:"refresh_token" binding:"required"`
-}
\ No newline at end of file
+}
+
+// ProfileCompletion reports which of the profile fields required by the
+// progressive profiling policy the user has yet to fill in.
+type ProfileCompletion struct {
+    Complete bool     `json:"complete"`
+    Required []string `json:"required"`
+    Missing  []string `json:"missing"`
+}
+
+// Security checklist items, in the order they are reported
+const (
+    SecurityCheckMFA             = "mfa_enabled"
+    SecurityCheckRecoveryCodes   = "recovery_codes_saved"
+    SecurityCheckRecentPassword  = "recent_password"
+    SecurityCheckVerifiedEmail   = "verified_email"
+    SecurityCheckNoRiskySessions = "no_risky_sessions"
+)
+
+// SecurityCheck is one item of the account security checklist. Points is
+// what the item adds to the score when it passes.
+type SecurityCheck struct {
+    Check  string `json:"check"`
+    Passed bool   `json:"passed"`
+    Points int    `json:"points"`
+}
+
+// SecurityScore is the account's security health: the sum of the points of
+// the passed checks, out of 100.
+type SecurityScore struct {
+    Score      int             `json:"score"`
+    Checks     []SecurityCheck `json:"checks"`
+    ComputedAt time.Time       `json:"computed_at"`
+}
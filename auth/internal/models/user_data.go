@@ -0,0 +1,22 @@
+package models
+
+// Categories of per-user Redis keys
+const (
+    UserKeyCategoryCache        = "cache"
+    UserKeyCategoryRateLimit    = "rate_limit"
+    UserKeyCategoryPresence     = "presence"
+    UserKeyCategoryOTP          = "otp"
+    UserKeyCategoryDeviceTrust  = "device_trust"
+    UserKeyCategoryLoginLockout = "login_lockout"
+)
+
+// UserRedisKey is one Redis key holding state about a user.
+type UserRedisKey struct {
+    Key        string `json:"key"`
+    Category   string `json:"category"`
+    TTLSeconds int64  `json:"ttl_seconds"`
+}
+
+type PurgeUserStateRequest struct {
+    Category string `form:"category" binding:"omitempty,oneof=cache rate_limit presence otp device_trust login_lockout"`
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+    "time"
+
+    "github.com/google/uuid"
+)
+
+// PrivateUser is what a user sees of their own account, and what internal
+// services get when they look a user up. Other users only ever see a
+// PublicUser or PublicProfileCard.
+type PrivateUser struct {
+    ID              uuid.UUID  `json:"id"`
+    Email           string     `json:"email"`
+    Username        string     `json:"username"`
+    EmailVerified   bool       `json:"email_verified"`
+    EmailVerifiedAt *time.Time `json:"email_verified_at"`
+    IsGuest         bool       `json:"is_guest"`
+    Role            string     `json:"role"`
+    Plan            string     `json:"plan"`
+    CreatedAt       time.Time  `json:"created_at"`
+    UpdatedAt       time.Time  `json:"updated_at"`
+    LastLogin       *time.Time `json:"last_login"`
+    DataRegion      string     `json:"data_region,omitempty"`
+}
+
+// NewPrivateUser converts a stored user to its private representation.
+func NewPrivateUser(user *User) *PrivateUser {
+    return &PrivateUser{
+        ID:              user.ID,
+        Email:           user.Email,
+        Username:        user.Username,
+        EmailVerified:   user.EmailVerified,
+        EmailVerifiedAt: user.EmailVerifiedAt,
+        IsGuest:         user.IsGuest,
+        Role:            user.Role,
+        Plan:            user.Plan,
+        CreatedAt:       user.CreatedAt,
+        UpdatedAt:       user.UpdatedAt,
+        LastLogin:       user.LastLogin,
+        DataRegion:      user.DataRegion,
+    }
+}
+
+// NewPrivateUsers converts stored users to their private representation.
+func NewPrivateUsers(users []*User) []*PrivateUser {
+    out := make([]*PrivateUser, len(users))
+    for i, user := range users {
+        out[i] = NewPrivateUser(user)
+    }
+    return out
+}
+
+// LoginStats are a user's login statistics, shown to admins.
+type LoginStats struct {
+    LoginCount  int64
+    LastLogin   *time.Time
+    LastLoginIP *string
+}
+
+// AdminUser is a user as admins see it: the private representation plus
+// login statistics, which include logins not yet written to the user's row.
+type AdminUser struct {
+    PrivateUser
+    EmailBouncedAt *time.Time `json:"email_bounced_at"`
+    LoginCount     int64      `json:"login_count"`
+    LastLoginIP    *string    `json:"last_login_ip"`
+}
+
+// NewAdminUser converts a stored user and their login statistics to the
+// admin representation.
+func NewAdminUser(user *User, stats *LoginStats) *AdminUser {
+    admin := &AdminUser{
+        PrivateUser:    *NewPrivateUser(user),
+        EmailBouncedAt: user.EmailBouncedAt,
+        LoginCount:     stats.LoginCount,
+        LastLoginIP:    stats.LastLoginIP,
+    }
+    admin.LastLogin = stats.LastLogin
+    return admin
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserRepresentations(t *testing.T) {
+	resetToken := "reset"
+	ip := "10.0.0.1"
+	lastLogin := time.Now().UTC()
+	user := &User{
+		ID:           uuid.New(),
+		Email:        "user@example.com",
+		Username:     "user",
+		PasswordHash: "hash",
+		Role:         RoleUser,
+		Plan:         PlanFree,
+		ResetToken:   &resetToken,
+	}
+
+	// The stored user never serializes, whatever fields it gains
+	raw, err := json.Marshal(user)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{}`, string(raw))
+
+	raw, err = json.Marshal(NewPrivateUser(user))
+	require.NoError(t, err)
+	var private map[string]interface{}
+	require.NoError(t, json.Unmarshal(raw, &private))
+	assert.Equal(t, "user@example.com", private["email"])
+	assert.NotContains(t, private, "password_hash")
+	assert.NotContains(t, private, "reset_token")
+	assert.NotContains(t, private, "login_count")
+
+	admin := NewAdminUser(user, &LoginStats{LoginCount: 3, LastLogin: &lastLogin, LastLoginIP: &ip})
+	raw, err = json.Marshal(admin)
+	require.NoError(t, err)
+	var shown map[string]interface{}
+	require.NoError(t, json.Unmarshal(raw, &shown))
+	assert.Equal(t, "user@example.com", shown["email"])
+	assert.Equal(t, float64(3), shown["login_count"])
+	assert.Equal(t, ip, shown["last_login_ip"])
+	assert.NotNil(t, shown["last_login"])
+	assert.NotContains(t, shown, "password_hash")
+}
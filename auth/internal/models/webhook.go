@@ -0,0 +1,38 @@
+package models
+
+import (
+    "time"
+    "github.com/google/uuid"
+)
+
+type UserWebhook struct {
+    ID             uuid.UUID  `db:"id" json:"id"`
+    UserID         uuid.UUID  `db:"user_id" json:"user_id"`
+    URL            string     `db:"url" json:"url"`
+    Secret         string     `db:"secret" json:"-"`
+    Events         []string   `db:"events" json:"events"`
+    CreatedAt      time.Time  `db:"created_at" json:"created_at"`
+    LastDeliveryAt *time.Time `db:"last_delivery_at" json:"last_delivery_at,omitempty"`
+    LastStatusCode *int       `db:"last_status_code" json:"last_status_code,omitempty"`
+}
+
+type CreateWebhookRequest struct {
+    URL    string   `json:"url" binding:"required,max=2048,safe_url"`
+    Events []string `json:"events" binding:"required,min=1,dive,oneof=user:login user:new_device user:session_anomaly user:session_evicted"`
+}
+
+// CreateWebhookResponse carries the signing secret, which is only returned
+// when a webhook is created or its secret is rotated.
+type CreateWebhookResponse struct {
+    Webhook *UserWebhook `json:"webhook"`
+    Secret  string       `json:"secret"`
+}
+
+// WebhookDelivery is the JSON body POSTed to a user's webhook URL.
+type WebhookDelivery struct {
+    ID        uuid.UUID              `json:"id"`
+    Event     string                 `json:"event"`
+    UserID    string                 `json:"user_id"`
+    Timestamp time.Time              `json:"timestamp"`
+    Data      map[string]interface{} `json:"data,omitempty"`
+}
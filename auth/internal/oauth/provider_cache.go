@@ -0,0 +1,334 @@
+package oauth
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "strings"
+    "sync"
+    "time"
+
+    "auth-service/internal/redis"
+
+    "go.uber.org/zap"
+    "golang.org/x/sync/singleflight"
+)
+
+const (
+    documentKeyPrefix = "oauth:doc:"
+    discoveryPath     = "/.well-known/openid-configuration"
+    providerTimeout   = 5 * time.Second
+    // Provider documents are small; anything bigger is not one
+    maxDocumentSize = 1 << 20
+    // Unknown key IDs trigger at most one JWKS refetch per interval, so
+    // tokens with made-up kids can't be used to hammer the provider
+    minKeyRefetchInterval = time.Minute
+)
+
+var (
+    ErrDocumentUnavailable = errors.New("provider document unavailable")
+    ErrKeyNotFound         = errors.New("signing key not found")
+)
+
+// SharedCache is the second tier of a ProviderCache, shared by all instances.
+// *redis.Client satisfies it.
+type SharedCache interface {
+    Get(ctx context.Context, key string) (string, error)
+    Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error
+}
+
+// ProviderMetadata is the part of an OIDC discovery document the service uses.
+type ProviderMetadata struct {
+    Issuer                string `json:"issuer"`
+    AuthorizationEndpoint string `json:"authorization_endpoint"`
+    TokenEndpoint         string `json:"token_endpoint"`
+    JWKSURI               string `json:"jwks_uri"`
+}
+
+// JSONWebKey is one key of a JWKS. Key material is kept raw for the verifier.
+type JSONWebKey struct {
+    KeyID     string          `json:"kid"`
+    KeyType   string          `json:"kty"`
+    Algorithm string          `json:"alg,omitempty"`
+    Use       string          `json:"use,omitempty"`
+    Raw       json.RawMessage `json:"-"`
+}
+
+type cachedDocument struct {
+    Body      json.RawMessage `json:"b"`
+    ETag      string          `json:"e,omitempty"`
+    FetchedAt time.Time       `json:"f"`
+}
+
+// ProviderCache caches the discovery documents and JWKS of OIDC providers
+// such as Apple and Google in process memory and in the shared cache.
+// Documents are served from memory while fresh. Once stale they are still
+// served, up to maxStale old, while a refresh runs in the background, so a
+// provider outage only breaks social logins after maxStale. A new instance
+// starts from the shared cache rather than going to the provider. Refreshes
+// are conditional on the provider's ETag, so an unchanged document costs a
+// 304; a rotated JWKS comes back with a new ETag and replaces the old one.
+type ProviderCache struct {
+    shared   SharedCache
+    client   *http.Client
+    logger   *zap.SugaredLogger
+    ttl      time.Duration
+    maxStale time.Duration
+    now      func() time.Time
+
+    mu         sync.Mutex
+    documents  map[string]cachedDocument
+    keyRefetch map[string]time.Time
+
+    fetches singleflight.Group
+}
+
+// NewProviderCache returns a cache whose documents are fresh for ttl and may
+// be served stale until maxStale after they were fetched. shared may be nil
+// to keep documents in memory only.
+func NewProviderCache(shared SharedCache, ttl, maxStale time.Duration, logger *zap.SugaredLogger) *ProviderCache {
+    return &ProviderCache{
+        shared:     shared,
+        client:     &http.Client{Timeout: providerTimeout},
+        logger:     logger,
+        ttl:        ttl,
+        maxStale:   maxStale,
+        now:        time.Now,
+        documents:  make(map[string]cachedDocument),
+        keyRefetch: make(map[string]time.Time),
+    }
+}
+
+// Metadata returns the discovery document of the provider at issuer.
+func (p *ProviderCache) Metadata(ctx context.Context, issuer string) (*ProviderMetadata, error) {
+    body, err := p.document(ctx, strings.TrimSuffix(issuer, "/")+discoveryPath)
+    if err != nil {
+        return nil, err
+    }
+
+    var metadata ProviderMetadata
+    if err := json.Unmarshal(body, &metadata); err != nil {
+        return nil, fmt.Errorf("decode provider metadata: %w", err)
+    }
+    return &metadata, nil
+}
+
+// Key returns the key with ID kid from the JWKS at jwksURI. A kid missing
+// from a cached JWKS usually means the provider rotated its keys, so the JWKS
+// is refetched once before giving up.
+func (p *ProviderCache) Key(ctx context.Context, jwksURI, kid string) (*JSONWebKey, error) {
+    body, err := p.document(ctx, jwksURI)
+    if err != nil {
+        return nil, err
+    }
+    key, err := findKey(body, kid)
+    if err != ErrKeyNotFound || !p.allowKeyRefetch(jwksURI) {
+        return key, err
+    }
+
+    body, err = p.refresh(ctx, jwksURI)
+    if err != nil {
+        return nil, err
+    }
+    return findKey(body, kid)
+}
+
+// Run refreshes every document that has gone stale each interval until ctx
+// is cancelled, so that requests rarely see a stale document at all.
+func (p *ProviderCache) Run(ctx context.Context, interval time.Duration) {
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+            for _, url := range p.staleURLs() {
+                if _, err := p.refresh(ctx, url); err != nil {
+                    p.logger.Warnf("Failed to refresh provider document %s: %v", url, err)
+                }
+            }
+        }
+    }
+}
+
+// document returns the document at url from the first tier that has it,
+// fetching it from the provider when neither does or it is too old to serve.
+func (p *ProviderCache) document(ctx context.Context, url string) (json.RawMessage, error) {
+    doc, ok := p.cached(url)
+    if !ok {
+        doc, ok = p.loadShared(ctx, url)
+    }
+    if !ok {
+        return p.refresh(ctx, url)
+    }
+
+    age := p.now().Sub(doc.FetchedAt)
+    switch {
+    case age < p.ttl:
+    case age < p.maxStale:
+        // Stale while revalidate. The refresh outlives the request.
+        go func() {
+            ctx, cancel := context.WithTimeout(context.Background(), providerTimeout)
+            defer cancel()
+            if _, err := p.refresh(ctx, url); err != nil {
+                p.logger.Warnf("Serving stale provider document %s: %v", url, err)
+            }
+        }()
+    default:
+        return p.refresh(ctx, url)
+    }
+    return doc.Body, nil
+}
+
+// refresh fetches the document at url and stores it in both tiers.
+// Concurrent refreshes of the same url share one fetch.
+func (p *ProviderCache) refresh(ctx context.Context, url string) (json.RawMessage, error) {
+    body, err, _ := p.fetches.Do(url, func() (interface{}, error) {
+        doc, _ := p.cached(url)
+        body, etag, err := p.fetch(ctx, url, doc.ETag)
+        if err != nil {
+            return nil, err
+        }
+        if body == nil {
+            // Not modified
+            body, etag = doc.Body, doc.ETag
+        }
+
+        doc = cachedDocument{Body: body, ETag: etag, FetchedAt: p.now()}
+        p.mu.Lock()
+        p.documents[url] = doc
+        p.mu.Unlock()
+
+        if p.shared != nil {
+            if payload, err := json.Marshal(doc); err == nil {
+                if err := p.shared.Set(ctx, documentKeyPrefix+url, payload, p.maxStale); err != nil {
+                    p.logger.Debugf("Failed to share provider document %s: %v", url, err)
+                }
+            }
+        }
+        return body, nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("%w: %v", ErrDocumentUnavailable, err)
+    }
+    return body.(json.RawMessage), nil
+}
+
+// fetch gets the document at url and its ETag. With etag set the request is
+// conditional, and a nil body means the document has not changed.
+func (p *ProviderCache) fetch(ctx context.Context, url, etag string) (json.RawMessage, string, error) {
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+    if err != nil {
+        return nil, "", fmt.Errorf("build provider request: %w", err)
+    }
+    req.Header.Set("Accept", "application/json")
+    if etag != "" {
+        req.Header.Set("If-None-Match", etag)
+    }
+
+    resp, err := p.client.Do(req)
+    if err != nil {
+        return nil, "", fmt.Errorf("fetch %s: %w", url, err)
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode == http.StatusNotModified && etag != "" {
+        return nil, etag, nil
+    }
+    if resp.StatusCode != http.StatusOK {
+        return nil, "", fmt.Errorf("fetch %s: status %d", url, resp.StatusCode)
+    }
+
+    body, err := io.ReadAll(io.LimitReader(resp.Body, maxDocumentSize))
+    if err != nil {
+        return nil, "", fmt.Errorf("read %s: %w", url, err)
+    }
+    if !json.Valid(body) {
+        return nil, "", fmt.Errorf("fetch %s: response is not JSON", url)
+    }
+    return body, resp.Header.Get("ETag"), nil
+}
+
+func (p *ProviderCache) cached(url string) (cachedDocument, bool) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    doc, ok := p.documents[url]
+    return doc, ok
+}
+
+// loadShared reads the document at url from the shared cache into memory.
+// Shared cache errors, including Redis being unavailable, count as a miss.
+func (p *ProviderCache) loadShared(ctx context.Context, url string) (cachedDocument, bool) {
+    if p.shared == nil {
+        return cachedDocument{}, false
+    }
+
+    payload, err := p.shared.Get(ctx, documentKeyPrefix+url)
+    if err != nil {
+        if err != redis.Nil {
+            p.logger.Debugf("Failed to read shared provider document %s: %v", url, err)
+        }
+        return cachedDocument{}, false
+    }
+
+    var doc cachedDocument
+    if err := json.Unmarshal([]byte(payload), &doc); err != nil {
+        return cachedDocument{}, false
+    }
+
+    p.mu.Lock()
+    p.documents[url] = doc
+    p.mu.Unlock()
+    return doc, true
+}
+
+func (p *ProviderCache) staleURLs() []string {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+
+    var urls []string
+    for url, doc := range p.documents {
+        if p.now().Sub(doc.FetchedAt) >= p.ttl {
+            urls = append(urls, url)
+        }
+    }
+    return urls
+}
+
+func (p *ProviderCache) allowKeyRefetch(jwksURI string) bool {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+
+    now := p.now()
+    if last, ok := p.keyRefetch[jwksURI]; ok && now.Sub(last) < minKeyRefetchInterval {
+        return false
+    }
+    p.keyRefetch[jwksURI] = now
+    return true
+}
+
+func findKey(body json.RawMessage, kid string) (*JSONWebKey, error) {
+    var set struct {
+        Keys []json.RawMessage `json:"keys"`
+    }
+    if err := json.Unmarshal(body, &set); err != nil {
+        return nil, fmt.Errorf("decode jwks: %w", err)
+    }
+
+    for _, raw := range set.Keys {
+        var key JSONWebKey
+        if err := json.Unmarshal(raw, &key); err != nil {
+            continue
+        }
+        if key.KeyID == kid {
+            key.Raw = raw
+            return &key, nil
+        }
+    }
+    return nil, ErrKeyNotFound
+}
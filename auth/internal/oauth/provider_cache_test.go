@@ -0,0 +1,185 @@
+package oauth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"auth-service/internal/redis"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type fakeSharedCache struct {
+	mu      sync.Mutex
+	entries map[string]string
+}
+
+func (f *fakeSharedCache) Get(ctx context.Context, key string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	value, ok := f.entries[key]
+	if !ok {
+		return "", redis.Nil
+	}
+	return value, nil
+}
+
+func (f *fakeSharedCache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.entries[key] = string(value.([]byte))
+	return nil
+}
+
+type fakeProvider struct {
+	server      *httptest.Server
+	hits        atomic.Int32
+	notModified atomic.Int32
+	down        atomic.Bool
+	jwks        atomic.Value
+}
+
+func newFakeProvider(t *testing.T) *fakeProvider {
+	p := &fakeProvider{}
+	p.jwks.Store(`{"keys":[{"kid":"k1","kty":"RSA","n":"abc","e":"AQAB"}]}`)
+	p.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.hits.Add(1)
+		if p.down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		switch r.URL.Path {
+		case discoveryPath:
+			w.Write([]byte(`{"issuer":"` + p.server.URL + `","jwks_uri":"` + p.server.URL + `/keys"}`))
+		case "/keys":
+			jwks := p.jwks.Load().(string)
+			etag := `"` + strconv.Itoa(len(jwks)) + `"`
+			w.Header().Set("ETag", etag)
+			if r.Header.Get("If-None-Match") == etag {
+				p.notModified.Add(1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Write([]byte(jwks))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+func newTestProviderCache(shared SharedCache, now *time.Time) *ProviderCache {
+	cache := NewProviderCache(shared, time.Hour, 24*time.Hour, zap.NewNop().Sugar())
+	cache.now = func() time.Time { return *now }
+	return cache
+}
+
+func TestProviderCache_ServesFromMemoryWhileFresh(t *testing.T) {
+	provider := newFakeProvider(t)
+	now := time.Now()
+	cache := newTestProviderCache(nil, &now)
+
+	metadata, err := cache.Metadata(context.Background(), provider.server.URL+"/")
+	require.NoError(t, err)
+	assert.Equal(t, provider.server.URL+"/keys", metadata.JWKSURI)
+
+	now = now.Add(30 * time.Minute)
+	_, err = cache.Metadata(context.Background(), provider.server.URL)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), provider.hits.Load())
+}
+
+func TestProviderCache_ServesStaleDuringOutage(t *testing.T) {
+	provider := newFakeProvider(t)
+	now := time.Now()
+	cache := newTestProviderCache(nil, &now)
+
+	_, err := cache.Key(context.Background(), provider.server.URL+"/keys", "k1")
+	require.NoError(t, err)
+
+	provider.down.Store(true)
+	now = now.Add(2 * time.Hour)
+	key, err := cache.Key(context.Background(), provider.server.URL+"/keys", "k1")
+	require.NoError(t, err)
+	assert.Equal(t, "RSA", key.KeyType)
+
+	// The background revalidation fails and the stale copy is kept
+	assert.Eventually(t, func() bool { return provider.hits.Load() == 2 }, time.Second, 10*time.Millisecond)
+
+	now = now.Add(24 * time.Hour)
+	_, err = cache.Key(context.Background(), provider.server.URL+"/keys", "k1")
+	assert.ErrorIs(t, err, ErrDocumentUnavailable)
+}
+
+func TestProviderCache_LoadsFromSharedCache(t *testing.T) {
+	provider := newFakeProvider(t)
+	shared := &fakeSharedCache{entries: make(map[string]string)}
+	now := time.Now()
+
+	_, err := newTestProviderCache(shared, &now).Metadata(context.Background(), provider.server.URL)
+	require.NoError(t, err)
+
+	// A second instance starts from the shared copy even with the provider down
+	provider.down.Store(true)
+	metadata, err := newTestProviderCache(shared, &now).Metadata(context.Background(), provider.server.URL)
+	require.NoError(t, err)
+	assert.Equal(t, provider.server.URL, metadata.Issuer)
+	assert.Equal(t, int32(1), provider.hits.Load())
+}
+
+func TestProviderCache_RefetchesOnUnknownKey(t *testing.T) {
+	provider := newFakeProvider(t)
+	now := time.Now()
+	cache := newTestProviderCache(nil, &now)
+	jwksURI := provider.server.URL + "/keys"
+
+	_, err := cache.Key(context.Background(), jwksURI, "k1")
+	require.NoError(t, err)
+
+	provider.jwks.Store(`{"keys":[{"kid":"k2","kty":"EC"}]}`)
+	key, err := cache.Key(context.Background(), jwksURI, "k2")
+	require.NoError(t, err)
+	assert.Equal(t, "EC", key.KeyType)
+	assert.JSONEq(t, `{"kid":"k2","kty":"EC"}`, string(key.Raw))
+
+	// Refetches for unknown kids are rate limited
+	_, err = cache.Key(context.Background(), jwksURI, "k3")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	assert.Equal(t, int32(2), provider.hits.Load())
+}
+
+func TestProviderCache_RevalidatesWithETag(t *testing.T) {
+	provider := newFakeProvider(t)
+	now := time.Now()
+	cache := newTestProviderCache(nil, &now)
+	jwksURI := provider.server.URL + "/keys"
+
+	_, err := cache.Key(context.Background(), jwksURI, "k1")
+	require.NoError(t, err)
+
+	// An unchanged JWKS is revalidated with a 304 and kept
+	_, err = cache.refresh(context.Background(), jwksURI)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), provider.notModified.Load())
+	key, err := cache.Key(context.Background(), jwksURI, "k1")
+	require.NoError(t, err)
+	assert.Equal(t, "RSA", key.KeyType)
+
+	// A rotated JWKS has a new ETag and replaces the cached one
+	provider.jwks.Store(`{"keys":[{"kid":"k2","kty":"EC"}]}`)
+	_, err = cache.refresh(context.Background(), jwksURI)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), provider.notModified.Load())
+	key, err = cache.Key(context.Background(), jwksURI, "k2")
+	require.NoError(t, err)
+	assert.Equal(t, "EC", key.KeyType)
+}
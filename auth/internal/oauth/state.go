@@ -0,0 +1,153 @@
+package oauth
+
+import (
+    "context"
+    "crypto/hmac"
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "strings"
+    "time"
+
+    "auth-service/internal/store"
+)
+
+const nonceKeyPrefix = "oauth:nonce:"
+
+var (
+    ErrInvalidState  = errors.New("invalid oauth state")
+    ErrStateExpired  = errors.New("oauth state expired")
+    ErrStateMismatch = errors.New("oauth state does not match request")
+    ErrNonceUsed     = errors.New("oauth nonce already used")
+)
+
+// State is the payload carried in the OAuth state parameter. Nonce is also
+// sent as the OIDC nonce so the ID token can be tied back to this flow.
+type State struct {
+    Nonce      string    `json:"n"`
+    Provider   string    `json:"p"`
+    Origin     string    `json:"o"`
+    RedirectTo string    `json:"r,omitempty"`
+    ExpiresAt  time.Time `json:"e"`
+}
+
+// StateManager issues and verifies HMAC-signed state values for social login
+// and OIDC flows. The signature stops forged states (CSRF), the origin binding
+// stops a state issued to one client being replayed from another, and the
+// single-use nonce kept in the token store stops a callback being replayed.
+type StateManager struct {
+    secret []byte
+    tokens store.TokenStore
+    ttl    time.Duration
+}
+
+func NewStateManager(secret string, tokens store.TokenStore, ttl time.Duration) *StateManager {
+    return &StateManager{
+        secret: []byte(secret),
+        tokens: tokens,
+        ttl:    ttl,
+    }
+}
+
+// Issue starts a flow for provider from origin and returns the state value to
+// send to the provider along with its nonce.
+func (m *StateManager) Issue(ctx context.Context, provider, origin, redirectTo string) (string, *State, error) {
+    nonce := make([]byte, 16)
+    if _, err := rand.Read(nonce); err != nil {
+        return "", nil, fmt.Errorf("generate nonce: %w", err)
+    }
+
+    state := &State{
+        Nonce:      hex.EncodeToString(nonce),
+        Provider:   provider,
+        Origin:     origin,
+        RedirectTo: redirectTo,
+        ExpiresAt:  time.Now().Add(m.ttl).UTC().Truncate(time.Second),
+    }
+
+    if err := m.tokens.Put(ctx, nonceKeyPrefix+state.Nonce, provider, m.ttl); err != nil {
+        return "", nil, fmt.Errorf("store nonce: %w", err)
+    }
+
+    value, err := m.sign(state)
+    if err != nil {
+        return "", nil, err
+    }
+
+    return value, state, nil
+}
+
+// Verify checks a state value returned by provider to a callback served for
+// origin, then consumes its nonce. A state can be verified only once.
+func (m *StateManager) Verify(ctx context.Context, value, provider, origin string) (*State, error) {
+    state, err := m.parse(value, provider, origin)
+    if err != nil {
+        return nil, err
+    }
+
+    stored, err := m.tokens.Take(ctx, nonceKeyPrefix+state.Nonce)
+    if err != nil {
+        if err == store.ErrNotFound {
+            return nil, ErrNonceUsed
+        }
+        return nil, fmt.Errorf("consume nonce: %w", err)
+    }
+    if stored != provider {
+        return nil, ErrStateMismatch
+    }
+
+    return state, nil
+}
+
+func (m *StateManager) sign(state *State) (string, error) {
+    payload, err := json.Marshal(state)
+    if err != nil {
+        return "", fmt.Errorf("marshal state: %w", err)
+    }
+
+    encoded := base64.RawURLEncoding.EncodeToString(payload)
+    return encoded + "." + base64.RawURLEncoding.EncodeToString(m.mac(encoded)), nil
+}
+
+// parse validates the signature, expiry and bindings of a state value without
+// touching its nonce.
+func (m *StateManager) parse(value, provider, origin string) (*State, error) {
+    encoded, sig, ok := strings.Cut(value, ".")
+    if !ok {
+        return nil, ErrInvalidState
+    }
+
+    mac, err := base64.RawURLEncoding.DecodeString(sig)
+    if err != nil || !hmac.Equal(mac, m.mac(encoded)) {
+        return nil, ErrInvalidState
+    }
+
+    payload, err := base64.RawURLEncoding.DecodeString(encoded)
+    if err != nil {
+        return nil, ErrInvalidState
+    }
+
+    var state State
+    if err := json.Unmarshal(payload, &state); err != nil {
+        return nil, ErrInvalidState
+    }
+
+    if time.Now().After(state.ExpiresAt) {
+        return nil, ErrStateExpired
+    }
+    if state.Provider != provider || state.Origin != origin {
+        return nil, ErrStateMismatch
+    }
+
+    return &state, nil
+}
+
+func (m *StateManager) mac(encoded string) []byte {
+    h := hmac.New(sha256.New, m.secret)
+    h.Write([]byte(encoded))
+    return h.Sum(nil)
+}
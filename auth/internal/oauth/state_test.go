@@ -0,0 +1,84 @@
+package oauth
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"auth-service/internal/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestState(ttl time.Duration) *State {
+	return &State{
+		Nonce:     "0123456789abcdef",
+		Provider:  "google",
+		Origin:    "https://app.tapin.example",
+		ExpiresAt: time.Now().Add(ttl).UTC().Truncate(time.Second),
+	}
+}
+
+func TestStateManager_SignAndParse(t *testing.T) {
+	m := NewStateManager("test-secret", nil, time.Minute)
+
+	value, err := m.sign(newTestState(time.Minute))
+	require.NoError(t, err)
+
+	state, err := m.parse(value, "google", "https://app.tapin.example")
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789abcdef", state.Nonce)
+}
+
+func TestStateManager_ParseRejects(t *testing.T) {
+	m := NewStateManager("test-secret", nil, time.Minute)
+
+	valid, err := m.sign(newTestState(time.Minute))
+	require.NoError(t, err)
+	expired, err := m.sign(newTestState(-time.Minute))
+	require.NoError(t, err)
+	otherKey, err := NewStateManager("other-secret", nil, time.Minute).sign(newTestState(time.Minute))
+	require.NoError(t, err)
+
+	payload, sig, _ := strings.Cut(valid, ".")
+	tampered := payload[:len(payload)-2] + "AA." + sig
+
+	tests := []struct {
+		name     string
+		value    string
+		provider string
+		origin   string
+		wantErr  error
+	}{
+		{"missing signature", payload, "google", "https://app.tapin.example", ErrInvalidState},
+		{"tampered payload", tampered, "google", "https://app.tapin.example", ErrInvalidState},
+		{"signed with another key", otherKey, "google", "https://app.tapin.example", ErrInvalidState},
+		{"expired", expired, "google", "https://app.tapin.example", ErrStateExpired},
+		{"other provider", valid, "github", "https://app.tapin.example", ErrStateMismatch},
+		{"other origin", valid, "google", "https://evil.example", ErrStateMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := m.parse(tt.value, tt.provider, tt.origin)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestStateManager_VerifyConsumesNonce(t *testing.T) {
+	ctx := context.Background()
+	m := NewStateManager("test-secret", store.NewMemory(), time.Minute)
+
+	value, issued, err := m.Issue(ctx, "google", "https://app.tapin.example", "/home")
+	require.NoError(t, err)
+
+	state, err := m.Verify(ctx, value, "google", "https://app.tapin.example")
+	require.NoError(t, err)
+	assert.Equal(t, issued.Nonce, state.Nonce)
+
+	_, err = m.Verify(ctx, value, "google", "https://app.tapin.example")
+	assert.Equal(t, ErrNonceUsed, err)
+}
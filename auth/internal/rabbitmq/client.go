@@ -1,8 +1,11 @@
 package rabbitmq
 
 import (
+    "context"
     "encoding/json"
+    "errors"
     "fmt"
+    "sync"
     "time"
 
     "auth-service/internal/events"
@@ -10,21 +13,57 @@ import (
     amqp "github.com/rabbitmq/amqp091-go"
 )
 
+// Longest a connection attempt may take, so an unreachable broker doesn't
+// hold up startup
+const dialTimeout = 5 * time.Second
+
+// ErrUnavailable is returned without contacting RabbitMQ while the client
+// has no connection.
+var ErrUnavailable = errors.New("rabbitmq unavailable")
+
+// Client publishes user events to RabbitMQ. It connects lazily: a broker
+// that can't be reached at startup doesn't stop the service, publishes fail
+// fast with ErrUnavailable (and end up in the outbox) and Monitor connects
+// once the broker answers.
 type Client struct {
+    url string
+
+    mu      sync.RWMutex
     conn    *amqp.Connection
     channel *amqp.Channel
 }
 
-func New(url string) (*Client, error) {
-    conn, err := amqp.Dial(url)
+// New connects to url, or returns a client without a connection if that
+// fails.
+func New(url string) *Client {
+    c := &Client{url: url}
+    c.connect()
+    return c
+}
+
+// connect dials the broker unless already connected, and reports whether
+// the client is connected afterwards.
+func (c *Client) connect() bool {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    if c.connectedLocked() {
+        return true
+    }
+    c.closeLocked()
+
+    conn, err := amqp.DialConfig(c.url, amqp.Config{
+        Locale: "en_US",
+        Dial:   amqp.DefaultDial(dialTimeout),
+    })
     if err != nil {
-        return nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+        return false
     }
 
     ch, err := conn.Channel()
     if err != nil {
         conn.Close()
-        return nil, fmt.Errorf("failed to open channel: %w", err)
+        return false
     }
 
     // Declare the exchange
@@ -40,13 +79,52 @@ func New(url string) (*Client, error) {
     if err != nil {
         ch.Close()
         conn.Close()
-        return nil, fmt.Errorf("failed to declare exchange: %w", err)
+        return false
     }
 
-    return &Client{
-        conn:    conn,
-        channel: ch,
-    }, nil
+    c.conn = conn
+    c.channel = ch
+    return true
+}
+
+// Available reports whether the client is connected.
+func (c *Client) Available() bool {
+    c.mu.RLock()
+    defer c.mu.RUnlock()
+    return c.connectedLocked()
+}
+
+// connectedLocked reports whether both the connection and the publishing
+// channel are open. A channel closed by a channel exception is reopened
+// along with the connection.
+func (c *Client) connectedLocked() bool {
+    return c.conn != nil && !c.conn.IsClosed() && c.channel != nil && !c.channel.IsClosed()
+}
+
+// Monitor reconnects every interval while the client has no connection,
+// until ctx is cancelled. onChange, if set, is called with the new state
+// whenever it changes, and once at the start.
+func (c *Client) Monitor(ctx context.Context, interval time.Duration, onChange func(available bool)) {
+    available := c.Available()
+    if onChange != nil {
+        onChange(available)
+    }
+
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+            now := c.connect()
+            if now != available && onChange != nil {
+                onChange(now)
+            }
+            available = now
+        }
+    }
 }
 
 func (c *Client) PublishUserEvent(event *events.UserEvent) error {
@@ -55,6 +133,13 @@ func (c *Client) PublishUserEvent(event *events.UserEvent) error {
         return fmt.Errorf("failed to marshal event: %w", err)
     }
 
+    c.mu.RLock()
+    defer c.mu.RUnlock()
+
+    if !c.connectedLocked() {
+        return ErrUnavailable
+    }
+
     routingKey := string(event.Type)
 
     err = c.channel.Publish(
@@ -76,10 +161,76 @@ func (c *Client) PublishUserEvent(event *events.UserEvent) error {
 }
 
 func (c *Client) Close() {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.closeLocked()
+}
+
+func (c *Client) closeLocked() {
     if c.channel != nil {
         c.channel.Close()
+        c.channel = nil
     }
     if c.conn != nil {
         c.conn.Close()
+        c.conn = nil
+    }
+}
+
+// Subscribe binds queue to exchange for routingKeys and passes each event to
+// handle until ctx is cancelled. Events handle fails on are requeued;
+// malformed ones are dropped. It returns ErrUnavailable without a
+// connection, and an error when the connection drops, so callers retry it.
+func (c *Client) Subscribe(ctx context.Context, exchange, queue string, routingKeys []string, handle func(context.Context, *events.UserEvent) error) error {
+    c.mu.RLock()
+    conn := c.conn
+    c.mu.RUnlock()
+    if conn == nil || conn.IsClosed() {
+        return ErrUnavailable
+    }
+
+    ch, err := conn.Channel()
+    if err != nil {
+        return fmt.Errorf("failed to open channel: %w", err)
+    }
+    defer ch.Close()
+
+    if err := ch.ExchangeDeclare(exchange, "topic", true, false, false, false, nil); err != nil {
+        return fmt.Errorf("failed to declare exchange: %w", err)
+    }
+    if _, err := ch.QueueDeclare(queue, true, false, false, false, nil); err != nil {
+        return fmt.Errorf("failed to declare queue: %w", err)
+    }
+    for _, key := range routingKeys {
+        if err := ch.QueueBind(queue, key, exchange, false, nil); err != nil {
+            return fmt.Errorf("failed to bind queue: %w", err)
+        }
+    }
+
+    deliveries, err := ch.Consume(queue, "", false, false, false, false, nil)
+    if err != nil {
+        return fmt.Errorf("failed to consume queue: %w", err)
     }
-}
\ No newline at end of file
+
+    for {
+        select {
+        case <-ctx.Done():
+            return nil
+        case delivery, ok := <-deliveries:
+            if !ok {
+                return fmt.Errorf("delivery channel closed")
+            }
+
+            event, err := events.ParseUserEvent(string(delivery.Body))
+            if err != nil {
+                delivery.Nack(false, false)
+                continue
+            }
+            if err := handle(ctx, event); err != nil {
+                delivery.Nack(false, true)
+                continue
+            }
+            delivery.Ack(false)
+        }
+    }
+}
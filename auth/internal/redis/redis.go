@@ -2,51 +2,308 @@ package redis
 
 import (
     "context"
+    "errors"
+    "sync/atomic"
     "time"
 
     "github.com/redis/go-redis/v9"
 )
 
+// Nil is returned by Get when the key does not exist.
+var Nil = redis.Nil
+
+// ErrUnavailable is returned without contacting Redis while the client is
+// marked unavailable, so callers fail fast during an outage instead of
+// waiting out connection timeouts.
+var ErrUnavailable = errors.New("redis unavailable")
+
+const pingTimeout = 2 * time.Second
+
 type Client struct {
-    client *redis.Client
+    client      *redis.Client
+    unavailable atomic.Bool
 }
 
+// New connects to redisURL. A Redis that can't be reached at startup doesn't
+// stop the service: the client starts out unavailable and Monitor brings it
+// back once Redis answers.
 func New(redisURL string) *Client {
     opt, err := redis.ParseURL(redisURL)
     if err != nil {
         panic(err)
     }
 
-    client := redis.NewClient(opt)
-    
-    // Test connection
-    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    c := &Client{client: redis.NewClient(opt)}
+    c.unavailable.Store(!c.ping(context.Background()))
+    return c
+}
+
+func (c *Client) ping(ctx context.Context) bool {
+    ctx, cancel := context.WithTimeout(ctx, pingTimeout)
     defer cancel()
-    
-    if err := client.Ping(ctx).Err(); err != nil {
-        panic(err)
+    return c.client.Ping(ctx).Err() == nil
+}
+
+// Ping round-trips to Redis, e.g. to measure its latency.
+func (c *Client) Ping(ctx context.Context) error {
+    if err := c.check(); err != nil {
+        return err
+    }
+    return c.client.Ping(ctx).Err()
+}
+
+// Available reports whether Redis answered the last health check.
+func (c *Client) Available() bool {
+    return !c.unavailable.Load()
+}
+
+// Monitor pings Redis every interval until ctx is cancelled and marks the
+// client available or unavailable accordingly. onChange, if set, is called
+// with the new state whenever it changes, and once at the start.
+func (c *Client) Monitor(ctx context.Context, interval time.Duration, onChange func(available bool)) {
+    if onChange != nil {
+        onChange(c.Available())
+    }
+
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+            available := c.ping(ctx)
+            if c.unavailable.Swap(!available) == available && onChange != nil {
+                onChange(available)
+            }
+        }
+    }
+}
+
+// InjectFaults runs fault before every command: it may delay the command,
+// and returning true fails it with ErrUnavailable as if Redis were down. It
+// is meant for resilience testing.
+func (c *Client) InjectFaults(fault func(ctx context.Context) bool) {
+    c.client.AddHook(faultHook(fault))
+}
+
+type faultHook func(ctx context.Context) bool
+
+func (h faultHook) DialHook(next redis.DialHook) redis.DialHook {
+    return next
+}
+
+func (h faultHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+    return func(ctx context.Context, cmd redis.Cmder) error {
+        if h(ctx) {
+            return ErrUnavailable
+        }
+        return next(ctx, cmd)
+    }
+}
+
+func (h faultHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+    return func(ctx context.Context, cmds []redis.Cmder) error {
+        if h(ctx) {
+            for _, cmd := range cmds {
+                cmd.SetErr(ErrUnavailable)
+            }
+            return ErrUnavailable
+        }
+        return next(ctx, cmds)
     }
+}
 
-    return &Client{client: client}
+// check fails fast while Redis is marked unavailable.
+func (c *Client) check() error {
+    if c.unavailable.Load() {
+        return ErrUnavailable
+    }
+    return nil
 }
 
 func (c *Client) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+    if err := c.check(); err != nil {
+        return err
+    }
     return c.client.Set(ctx, key, value, expiration).Err()
 }
 
 func (c *Client) Get(ctx context.Context, key string) (string, error) {
+    if err := c.check(); err != nil {
+        return "", err
+    }
     return c.client.Get(ctx, key).Result()
 }
 
 func (c *Client) Delete(ctx context.Context, keys ...string) error {
+    if err := c.check(); err != nil {
+        return err
+    }
     return c.client.Del(ctx, keys...).Err()
 }
 
 func (c *Client) Exists(ctx context.Context, key string) (bool, error) {
+    if err := c.check(); err != nil {
+        return false, err
+    }
     n, err := c.client.Exists(ctx, key).Result()
     return n > 0, err
 }
 
 func (c *Client) Close() error {
     return c.client.Close()
-}
\ No newline at end of file
+}
+
+func (c *Client) Incr(ctx context.Context, key string) (int64, error) {
+    if err := c.check(); err != nil {
+        return 0, err
+    }
+    return c.client.Incr(ctx, key).Result()
+}
+
+func (c *Client) ExpireAt(ctx context.Context, key string, at time.Time) error {
+    if err := c.check(); err != nil {
+        return err
+    }
+    return c.client.ExpireAt(ctx, key, at).Err()
+}
+
+// Scan returns every key matching pattern, walking the keyspace with SCAN
+// so large databases aren't blocked the way KEYS would block them.
+func (c *Client) Scan(ctx context.Context, pattern string) ([]string, error) {
+    if err := c.check(); err != nil {
+        return nil, err
+    }
+    var keys []string
+    iter := c.client.Scan(ctx, 0, pattern, 100).Iterator()
+    for iter.Next(ctx) {
+        keys = append(keys, iter.Val())
+    }
+    return keys, iter.Err()
+}
+
+func (c *Client) Expire(ctx context.Context, key string, expiration time.Duration) error {
+    if err := c.check(); err != nil {
+        return err
+    }
+    return c.client.Expire(ctx, key, expiration).Err()
+}
+
+// TTL returns the remaining time to live of key, or a negative duration if
+// the key does not exist or has no expiry.
+func (c *Client) TTL(ctx context.Context, key string) (time.Duration, error) {
+    if err := c.check(); err != nil {
+        return 0, err
+    }
+    return c.client.TTL(ctx, key).Result()
+}
+
+// GetDel returns the value of key and deletes it in one step, so only one
+// caller can ever observe a given value.
+func (c *Client) GetDel(ctx context.Context, key string) (string, error) {
+    if err := c.check(); err != nil {
+        return "", err
+    }
+    return c.client.GetDel(ctx, key).Result()
+}
+
+// SetNX sets key to value only if it does not exist yet, and reports whether
+// it was set.
+func (c *Client) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+    if err := c.check(); err != nil {
+        return false, err
+    }
+    return c.client.SetNX(ctx, key, value, expiration).Result()
+}
+
+// Eval runs a Lua script atomically on Redis.
+func (c *Client) Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+    if err := c.check(); err != nil {
+        return nil, err
+    }
+    return c.client.Eval(ctx, script, keys, args...).Result()
+}
+
+// HGetAll returns every field of the hash at key, or an empty map if it
+// doesn't exist.
+func (c *Client) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+    if err := c.check(); err != nil {
+        return nil, err
+    }
+    return c.client.HGetAll(ctx, key).Result()
+}
+
+// HMGet returns the given fields of the hash at key, with nil for missing
+// ones.
+func (c *Client) HMGet(ctx context.Context, key string, fields ...string) ([]interface{}, error) {
+    if err := c.check(); err != nil {
+        return nil, err
+    }
+    return c.client.HMGet(ctx, key, fields...).Result()
+}
+
+// Rename moves key to newKey, replacing whatever newKey held. It fails if
+// key doesn't exist.
+func (c *Client) Rename(ctx context.Context, key, newKey string) error {
+    if err := c.check(); err != nil {
+        return err
+    }
+    return c.client.Rename(ctx, key, newKey).Err()
+}
+
+// Publish sends message to every client subscribed to channel.
+func (c *Client) Publish(ctx context.Context, channel string, message interface{}) error {
+    if err := c.check(); err != nil {
+        return err
+    }
+    return c.client.Publish(ctx, channel, message).Err()
+}
+
+// Message is one message received on a subscription.
+type Message struct {
+    Channel string
+    Payload string
+}
+
+// PSubscribe subscribes to the channels matching patterns and delivers their
+// messages until ctx is done, then closes the returned channel. It returns
+// once Redis has confirmed the subscription. If the connection drops the
+// subscription is re-established, but messages published meanwhile are
+// lost.
+func (c *Client) PSubscribe(ctx context.Context, patterns ...string) (<-chan Message, error) {
+    if err := c.check(); err != nil {
+        return nil, err
+    }
+
+    pubsub := c.client.PSubscribe(ctx, patterns...)
+    if _, err := pubsub.Receive(ctx); err != nil {
+        pubsub.Close()
+        return nil, err
+    }
+
+    out := make(chan Message)
+    go func() {
+        defer close(out)
+        defer pubsub.Close()
+
+        messages := pubsub.Channel()
+        for {
+            select {
+            case <-ctx.Done():
+                return
+            case msg, ok := <-messages:
+                if !ok {
+                    return
+                }
+                select {
+                case out <- Message{Channel: msg.Channel, Payload: msg.Payload}:
+                case <-ctx.Done():
+                    return
+                }
+            }
+        }
+    }()
+    return out, nil
+}
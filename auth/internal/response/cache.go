@@ -0,0 +1,55 @@
+package response
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "net/http"
+    "strings"
+
+    "github.com/gin-gonic/gin"
+)
+
+// ETag returns a strong entity tag for payload, derived from its JSON
+// encoding so that it changes whenever the payload does.
+func ETag(payload interface{}) (string, error) {
+    body, err := json.Marshal(payload)
+    if err != nil {
+        return "", err
+    }
+    sum := sha256.Sum256(body)
+    return `"` + hex.EncodeToString(sum[:16]) + `"`, nil
+}
+
+// NotModified sets the ETag and Cache-Control headers and answers 304 if the
+// request's If-None-Match matches etag. It reports whether it did, in which
+// case the handler must not write a body.
+func NotModified(c *gin.Context, etag, cacheControl string) bool {
+    c.Header("ETag", etag)
+    c.Header("Cache-Control", cacheControl)
+
+    if !etagMatches(c.GetHeader("If-None-Match"), etag) {
+        return false
+    }
+    c.Status(http.StatusNotModified)
+    return true
+}
+
+// etagMatches compares an If-None-Match header against etag. The header may
+// list several tags or be "*"; tags compare weakly, ignoring any W/ prefix.
+func etagMatches(header, etag string) bool {
+    if header == "" {
+        return false
+    }
+    if strings.TrimSpace(header) == "*" {
+        return true
+    }
+
+    etag = strings.TrimPrefix(etag, "W/")
+    for _, candidate := range strings.Split(header, ",") {
+        if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == etag {
+            return true
+        }
+    }
+    return false
+}
@@ -0,0 +1,51 @@
+package response
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestETag_ChangesWithPayload(t *testing.T) {
+	first, err := ETag(gin.H{"name": "a"})
+	require.NoError(t, err)
+	same, err := ETag(gin.H{"name": "a"})
+	require.NoError(t, err)
+	other, err := ETag(gin.H{"name": "b"})
+	require.NoError(t, err)
+
+	assert.Equal(t, first, same)
+	assert.NotEqual(t, first, other)
+}
+
+func TestNotModified(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const etag = `"abc"`
+
+	tests := []struct {
+		ifNoneMatch string
+		want        bool
+	}{
+		{"", false},
+		{`"abc"`, true},
+		{`W/"abc"`, true},
+		{`"xyz", "abc"`, true},
+		{`"xyz"`, false},
+		{"*", true},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest("GET", "/thing", nil)
+		c.Request.Header.Set("If-None-Match", tt.ifNoneMatch)
+
+		assert.Equal(t, tt.want, NotModified(c, etag, "public, max-age=60"), "If-None-Match %q", tt.ifNoneMatch)
+		assert.Equal(t, etag, w.Header().Get("ETag"))
+		assert.Equal(t, "public, max-age=60", w.Header().Get("Cache-Control"))
+	}
+}
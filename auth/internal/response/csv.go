@@ -0,0 +1,73 @@
+package response
+
+import (
+    "encoding/csv"
+    "fmt"
+    "net/http"
+    "strings"
+
+    "github.com/gin-gonic/gin"
+)
+
+// Rows written between flushes to the client
+const csvFlushEvery = 100
+
+// WantsCSV reports whether the client asked for CSV, with ?format=csv or an
+// Accept header naming text/csv.
+func WantsCSV(c *gin.Context) bool {
+    if format := c.Query("format"); format != "" {
+        return format == "csv"
+    }
+    return strings.Contains(c.GetHeader("Accept"), "text/csv")
+}
+
+// CSVWriter streams rows to the client as they are produced, so exports are
+// never held in memory. Once the header is written the status is 200;
+// failures after that can only be reported by cutting the stream short.
+type CSVWriter struct {
+    c    *gin.Context
+    w    *csv.Writer
+    rows int
+}
+
+// CSV starts a CSV attachment named filename with the given header row.
+func CSV(c *gin.Context, filename string, header []string) (*CSVWriter, error) {
+    c.Header("Content-Type", "text/csv; charset=utf-8")
+    c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+    c.Status(http.StatusOK)
+
+    writer := &CSVWriter{c: c, w: csv.NewWriter(c.Writer)}
+    if err := writer.w.Write(header); err != nil {
+        return nil, err
+    }
+    return writer, nil
+}
+
+// Write adds a row. Cells that a spreadsheet would run as a formula are
+// prefixed with a quote.
+func (w *CSVWriter) Write(record []string) error {
+    for i, cell := range record {
+        if cell != "" && strings.ContainsAny(cell[:1], "=+-@\t\r") {
+            record[i] = "'" + cell
+        }
+    }
+    if err := w.w.Write(record); err != nil {
+        return err
+    }
+
+    w.rows++
+    if w.rows%csvFlushEvery == 0 {
+        return w.Flush()
+    }
+    return nil
+}
+
+// Flush sends buffered rows to the client.
+func (w *CSVWriter) Flush() error {
+    w.w.Flush()
+    if err := w.w.Error(); err != nil {
+        return err
+    }
+    w.c.Writer.Flush()
+    return nil
+}
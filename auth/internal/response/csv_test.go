@@ -0,0 +1,49 @@
+package response
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWantsCSV(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		url    string
+		accept string
+		want   bool
+	}{
+		{"/export?format=csv", "", true},
+		{"/export", "text/csv", true},
+		{"/export", "application/json", false},
+		{"/export?format=json", "text/csv", false},
+		{"/export", "", false},
+	}
+
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request, _ = http.NewRequest("GET", tt.url, nil)
+		c.Request.Header.Set("Accept", tt.accept)
+		assert.Equal(t, tt.want, WantsCSV(c), "%s with Accept %q", tt.url, tt.accept)
+	}
+}
+
+func TestCSV_StreamsRows(t *testing.T) {
+	w := serve("/export", func(c *gin.Context) {
+		writer, err := CSV(c, "export.csv", []string{"name", "note"})
+		require.NoError(t, err)
+		require.NoError(t, writer.Write([]string{"alice", "plain, with comma"}))
+		require.NoError(t, writer.Write([]string{"bob", "=HYPERLINK(\"x\")"}))
+		require.NoError(t, writer.Flush())
+	})
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="export.csv"`, w.Header().Get("Content-Disposition"))
+	assert.Equal(t, "name,note\nalice,\"plain, with comma\"\nbob,\"'=HYPERLINK(\"\"x\"\")\"\n", w.Body.String())
+}
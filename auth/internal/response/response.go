@@ -0,0 +1,144 @@
+package response
+
+import (
+    "encoding/json"
+    "strings"
+    "time"
+
+    "github.com/gin-gonic/gin"
+)
+
+const (
+    V1 = "v1"
+    V2 = "v2"
+
+    versionKey = "api_version"
+)
+
+// Envelope is the v2 response shape. Exactly one of Data and Error is set.
+type Envelope struct {
+    Data  interface{} `json:"data,omitempty"`
+    Error *ErrorBody  `json:"error,omitempty"`
+    Meta  Meta        `json:"meta"`
+}
+
+type ErrorBody struct {
+    Message string                 `json:"message"`
+    Details map[string]interface{} `json:"details,omitempty"`
+}
+
+type Meta struct {
+    APIVersion string `json:"api_version"`
+    Timestamp  string `json:"timestamp"`
+}
+
+// SetVersion records which API version the current request is served under.
+func SetVersion(c *gin.Context, version string) {
+    c.Set(versionKey, version)
+}
+
+// Version returns the API version of the current request. Requests rejected
+// before the version middleware runs fall back to the URL prefix.
+func Version(c *gin.Context) string {
+    if v, ok := c.Get(versionKey); ok {
+        return v.(string)
+    }
+    if strings.HasPrefix(c.Request.URL.Path, "/api/v2/") {
+        return V2
+    }
+    return V1
+}
+
+// JSON writes a success payload, enveloped under "data" for v2 requests.
+func JSON(c *gin.Context, status int, payload interface{}) {
+    if Version(c) != V2 {
+        c.JSON(status, payload)
+        return
+    }
+
+    c.JSON(status, Envelope{
+        Data: normalizeTimestamps(payload),
+        Meta: newMeta(),
+    })
+}
+
+// Error writes an error message as {"error": msg} for v1 and as an
+// enveloped error object for v2.
+func Error(c *gin.Context, status int, message string) {
+    ErrorWithDetails(c, status, message, nil)
+}
+
+// ErrorWithDetails writes an error with extra fields. v1 merges the details
+// into the top-level object to keep its existing shape; v2 nests them.
+func ErrorWithDetails(c *gin.Context, status int, message string, details map[string]interface{}) {
+    if Version(c) != V2 {
+        body := gin.H{"error": message}
+        for k, v := range details {
+            body[k] = v
+        }
+        c.JSON(status, body)
+        return
+    }
+
+    c.JSON(status, Envelope{
+        Error: &ErrorBody{
+            Message: message,
+            Details: normalizeDetails(details),
+        },
+        Meta: newMeta(),
+    })
+}
+
+func newMeta() Meta {
+    return Meta{
+        APIVersion: V2,
+        Timestamp:  time.Now().UTC().Format(time.RFC3339),
+    }
+}
+
+func normalizeDetails(details map[string]interface{}) map[string]interface{} {
+    if details == nil {
+        return nil
+    }
+    normalized, _ := normalizeTimestamps(details).(map[string]interface{})
+    return normalized
+}
+
+// normalizeTimestamps round-trips the payload through JSON and rewrites every
+// timestamp value as RFC3339 in UTC, so v2 clients never see local offsets or
+// fractional seconds regardless of where the time.Time values came from.
+func normalizeTimestamps(payload interface{}) interface{} {
+    raw, err := json.Marshal(payload)
+    if err != nil {
+        return payload
+    }
+
+    var generic interface{}
+    if err := json.Unmarshal(raw, &generic); err != nil {
+        return payload
+    }
+
+    return rewriteTimestamps(generic)
+}
+
+func rewriteTimestamps(value interface{}) interface{} {
+    switch v := value.(type) {
+    case map[string]interface{}:
+        for key, item := range v {
+            v[key] = rewriteTimestamps(item)
+        }
+        return v
+    case []interface{}:
+        for i, item := range v {
+            v[i] = rewriteTimestamps(item)
+        }
+        return v
+    case string:
+        if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+            return t.UTC().Format(time.RFC3339)
+        }
+        return v
+    default:
+        return v
+    }
+}
@@ -0,0 +1,76 @@
+package response
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serve(path string, handler gin.HandlerFunc) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET(path, handler)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", path, nil)
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestJSON_V1IsUnwrapped(t *testing.T) {
+	w := serve("/api/v1/thing", func(c *gin.Context) {
+		JSON(c, http.StatusOK, gin.H{"message": "ok"})
+	})
+
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "ok", body["message"])
+	assert.NotContains(t, body, "data")
+}
+
+func TestJSON_V2Envelope(t *testing.T) {
+	local := time.FixedZone("UTC+2", 2*60*60)
+	at := time.Date(2025, 1, 2, 5, 4, 5, 123456789, local)
+
+	w := serve("/api/v2/thing", func(c *gin.Context) {
+		JSON(c, http.StatusOK, gin.H{"expires_at": at, "name": "otter"})
+	})
+
+	var body struct {
+		Data  map[string]interface{} `json:"data"`
+		Error *ErrorBody             `json:"error"`
+		Meta  Meta                   `json:"meta"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Nil(t, body.Error)
+	assert.Equal(t, "2025-01-02T03:04:05Z", body.Data["expires_at"])
+	assert.Equal(t, "otter", body.Data["name"])
+	assert.Equal(t, V2, body.Meta.APIVersion)
+
+	_, err := time.Parse(time.RFC3339, body.Meta.Timestamp)
+	assert.NoError(t, err)
+}
+
+func TestErrorWithDetails(t *testing.T) {
+	handler := func(c *gin.Context) {
+		ErrorWithDetails(c, http.StatusConflict, "Username already exists", gin.H{"suggestions": []string{"a"}})
+	}
+
+	w := serve("/api/v1/thing", handler)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.JSONEq(t, `{"error":"Username already exists","suggestions":["a"]}`, w.Body.String())
+
+	w = serve("/api/v2/thing", handler)
+	var body Envelope
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.NotNil(t, body.Error)
+	assert.Equal(t, "Username already exists", body.Error.Message)
+	assert.Equal(t, []interface{}{"a"}, body.Error.Details["suggestions"])
+	assert.Nil(t, body.Data)
+}
@@ -0,0 +1,71 @@
+package secrets
+
+import (
+    "crypto/sha256"
+    "crypto/tls"
+    "encoding/hex"
+    "fmt"
+    "sync"
+)
+
+// Certificate holds a TLS certificate loaded from a PEM certificate and key
+// file pair. Servers pick it up per handshake through GetCertificate, so
+// Reload swaps it without dropping established connections.
+type Certificate struct {
+    certFile string
+    keyFile  string
+
+    mu   sync.RWMutex
+    cert *tls.Certificate
+}
+
+// LoadCertificate reads the first certificate from certFile and keyFile.
+func LoadCertificate(certFile, keyFile string) (*Certificate, error) {
+    cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+    if err != nil {
+        return nil, fmt.Errorf("load tls certificate: %w", err)
+    }
+    return &Certificate{certFile: certFile, keyFile: keyFile, cert: &cert}, nil
+}
+
+// GetCertificate returns the current certificate. It fits
+// tls.Config.GetCertificate.
+func (c *Certificate) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+    c.mu.RLock()
+    defer c.mu.RUnlock()
+    return c.cert, nil
+}
+
+// Fingerprint identifies the current certificate by its leaf.
+func (c *Certificate) Fingerprint() string {
+    c.mu.RLock()
+    defer c.mu.RUnlock()
+    return certificateFingerprint(c.cert)
+}
+
+// Reload reads both files again. A pair that fails to load leaves the
+// current certificate in place. Reload reports whether the certificate
+// changed.
+func (c *Certificate) Reload() (bool, error) {
+    cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+    if err != nil {
+        return false, fmt.Errorf("load tls certificate: %w", err)
+    }
+
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    if certificateFingerprint(&cert) == certificateFingerprint(c.cert) {
+        return false, nil
+    }
+    c.cert = &cert
+    return true, nil
+}
+
+func certificateFingerprint(cert *tls.Certificate) string {
+    if len(cert.Certificate) == 0 {
+        return ""
+    }
+    sum := sha256.Sum256(cert.Certificate[0])
+    return hex.EncodeToString(sum[:8])
+}
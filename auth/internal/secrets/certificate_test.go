@@ -0,0 +1,76 @@
+package secrets
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCertificate writes a fresh self-signed certificate and its key.
+func writeCertificate(t *testing.T, certFile, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "auth.test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+}
+
+func TestCertificate_Reload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeCertificate(t, certFile, keyFile)
+
+	cert, err := LoadCertificate(certFile, keyFile)
+	require.NoError(t, err)
+	first := cert.Fingerprint()
+	assert.NotEmpty(t, first)
+
+	changed, err := cert.Reload()
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	served, err := cert.GetCertificate(nil)
+	require.NoError(t, err)
+
+	writeCertificate(t, certFile, keyFile)
+	changed, err = cert.Reload()
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.NotEqual(t, first, cert.Fingerprint())
+
+	// Handshakes after the reload get the new certificate
+	current, err := cert.GetCertificate(nil)
+	require.NoError(t, err)
+	assert.NotSame(t, served, current)
+
+	// A broken pair keeps the loaded certificate
+	require.NoError(t, os.WriteFile(keyFile, []byte("not a key"), 0o600))
+	_, err = cert.Reload()
+	assert.Error(t, err)
+	assert.NotEqual(t, first, cert.Fingerprint())
+}
@@ -0,0 +1,82 @@
+// Package secrets keeps key material out of strings and the garbage-collected
+// heap. Keys live in buffers that are locked against being swapped to disk
+// where the platform allows it, are wiped when replaced, and never print.
+package secrets
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+)
+
+const redacted = "[redacted]"
+
+var ErrEmptyKey = errors.New("key is empty")
+
+// Key is a secret held in its own buffer. The zero value is not usable; get
+// one from NewKey.
+type Key struct {
+    buf    []byte
+    locked bool
+}
+
+// NewKey copies material into a new buffer and wipes material, so the only
+// copy left is the Key's.
+func NewKey(material []byte) (*Key, error) {
+    if len(material) == 0 {
+        return nil, ErrEmptyKey
+    }
+
+    buf, locked := allocate(len(material))
+    copy(buf, material)
+    Wipe(material)
+    return &Key{buf: buf, locked: locked}, nil
+}
+
+// Bytes returns the key material. Callers must not keep or modify it; it is
+// wiped when the key is destroyed.
+func (k *Key) Bytes() []byte {
+    return k.buf
+}
+
+// Locked reports whether the key's memory is locked against swapping.
+func (k *Key) Locked() bool {
+    return k.locked
+}
+
+// Fingerprint identifies the key in logs and audit entries without revealing
+// it.
+func (k *Key) Fingerprint() string {
+    sum := sha256.Sum256(k.buf)
+    return hex.EncodeToString(sum[:8])
+}
+
+// Destroy wipes the key and releases its buffer. The key must not be used
+// afterwards.
+func (k *Key) Destroy() {
+    if k.buf == nil {
+        return
+    }
+    Wipe(k.buf)
+    release(k.buf, k.locked)
+    k.buf = nil
+}
+
+func (k *Key) String() string {
+    return redacted
+}
+
+func (k *Key) GoString() string {
+    return redacted
+}
+
+func (k *Key) MarshalJSON() ([]byte, error) {
+    return []byte(`"` + redacted + `"`), nil
+}
+
+// Wipe overwrites b with zeros.
+func Wipe(b []byte) {
+    for i := range b {
+        b[i] = 0
+    }
+}
@@ -0,0 +1,126 @@
+package secrets
+
+import (
+    "bytes"
+    "crypto/subtle"
+    "errors"
+    "fmt"
+    "os"
+    "sync"
+)
+
+var ErrNotReloadable = errors.New("key was not loaded from a file and cannot be reloaded")
+
+// Source reads fresh key material. The caller wipes what it returns.
+type Source func() ([]byte, error)
+
+// FileSource reads key material from path, ignoring a trailing newline.
+func FileSource(path string) Source {
+    return func() ([]byte, error) {
+        material, err := os.ReadFile(path)
+        if err != nil {
+            return nil, fmt.Errorf("read key file: %w", err)
+        }
+        return bytes.TrimRight(material, "\r\n"), nil
+    }
+}
+
+// Keyring holds the current key and the one it replaced. New signatures use
+// the current key; verification also accepts the previous one, so reloading
+// a rotated key doesn't invalidate everything signed just before.
+type Keyring struct {
+    source Source
+
+    mu       sync.RWMutex
+    current  *Key
+    previous *Key
+}
+
+// NewKeyring returns a keyring holding key. source is read again on Reload;
+// it may be nil for a key that can't be reloaded.
+func NewKeyring(key *Key, source Source) *Keyring {
+    return &Keyring{source: source, current: key}
+}
+
+// LoadKeyring reads the first key from source.
+func LoadKeyring(source Source) (*Keyring, error) {
+    material, err := source()
+    if err != nil {
+        return nil, err
+    }
+    key, err := NewKey(material)
+    if err != nil {
+        return nil, err
+    }
+    return NewKeyring(key, source), nil
+}
+
+// StaticKeyring holds a fixed key that can't be reloaded, e.g. one given in
+// an environment variable. The string itself can't be wiped.
+func StaticKeyring(secret string) (*Keyring, error) {
+    key, err := NewKey([]byte(secret))
+    if err != nil {
+        return nil, err
+    }
+    return NewKeyring(key, nil), nil
+}
+
+// Use calls fn with the current key first, then the previous one if there is
+// one. The keys are only valid during the call.
+func (r *Keyring) Use(fn func(keys [][]byte) error) error {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+
+    keys := [][]byte{r.current.Bytes()}
+    if r.previous != nil {
+        keys = append(keys, r.previous.Bytes())
+    }
+    return fn(keys)
+}
+
+// Fingerprint identifies the current key.
+func (r *Keyring) Fingerprint() string {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    return r.current.Fingerprint()
+}
+
+// Locked reports whether the current key's memory is locked against
+// swapping.
+func (r *Keyring) Locked() bool {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    return r.current.Locked()
+}
+
+// Reload reads the source again. If the key changed, it becomes current, the
+// old current key is kept as previous and the one before is destroyed.
+// Reload reports whether the key changed.
+func (r *Keyring) Reload() (bool, error) {
+    if r.source == nil {
+        return false, ErrNotReloadable
+    }
+
+    material, err := r.source()
+    if err != nil {
+        return false, err
+    }
+    key, err := NewKey(material)
+    if err != nil {
+        return false, err
+    }
+
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    if subtle.ConstantTimeCompare(key.Bytes(), r.current.Bytes()) == 1 {
+        key.Destroy()
+        return false, nil
+    }
+    if r.previous != nil {
+        r.previous.Destroy()
+    }
+    r.previous = r.current
+    r.current = key
+    return true, nil
+}
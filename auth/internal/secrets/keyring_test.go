@@ -0,0 +1,80 @@
+package secrets
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewKey_WipesMaterial(t *testing.T) {
+	material := []byte("super-secret")
+	key, err := NewKey(material)
+	require.NoError(t, err)
+	defer key.Destroy()
+
+	assert.Equal(t, make([]byte, len(material)), material)
+	assert.Equal(t, "super-secret", string(key.Bytes()))
+
+	_, err = NewKey(nil)
+	assert.ErrorIs(t, err, ErrEmptyKey)
+}
+
+func TestKey_NeverPrints(t *testing.T) {
+	key, err := NewKey([]byte("super-secret"))
+	require.NoError(t, err)
+	defer key.Destroy()
+
+	for _, format := range []string{"%v", "%s", "%+v", "%#v"} {
+		assert.NotContains(t, fmt.Sprintf(format, key), "super-secret", format)
+	}
+	raw, err := key.MarshalJSON()
+	require.NoError(t, err)
+	assert.NotContains(t, string(raw), "super-secret")
+}
+
+func TestKeyring_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jwt_secret")
+	require.NoError(t, os.WriteFile(path, []byte("first-secret\n"), 0o600))
+
+	keys, err := LoadKeyring(FileSource(path))
+	require.NoError(t, err)
+	first := keys.Fingerprint()
+
+	rotated, err := keys.Reload()
+	require.NoError(t, err)
+	assert.False(t, rotated)
+
+	require.NoError(t, os.WriteFile(path, []byte("second-secret\n"), 0o600))
+	rotated, err = keys.Reload()
+	require.NoError(t, err)
+	assert.True(t, rotated)
+	assert.NotEqual(t, first, keys.Fingerprint())
+
+	// The replaced key is kept for verification only
+	var seen []string
+	require.NoError(t, keys.Use(func(k [][]byte) error {
+		for _, key := range k {
+			seen = append(seen, string(key))
+		}
+		return nil
+	}))
+	assert.Equal(t, []string{"second-secret", "first-secret"}, seen)
+
+	// A broken file keeps the loaded keys
+	require.NoError(t, os.Remove(path))
+	_, err = keys.Reload()
+	assert.Error(t, err)
+	assert.NotEqual(t, first, keys.Fingerprint())
+}
+
+func TestStaticKeyring_CannotReload(t *testing.T) {
+	keys, err := StaticKeyring("inline-secret")
+	require.NoError(t, err)
+
+	_, err = keys.Reload()
+	assert.ErrorIs(t, err, ErrNotReloadable)
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package secrets
+
+// allocate returns a heap buffer; memory locking is only supported on unix.
+func allocate(size int) ([]byte, bool) {
+    return make([]byte, size), false
+}
+
+func release(buf []byte, locked bool) {}
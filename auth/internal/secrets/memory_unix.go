@@ -0,0 +1,28 @@
+//go:build unix
+
+package secrets
+
+import "golang.org/x/sys/unix"
+
+// allocate maps a buffer outside the Go heap, so the garbage collector never
+// copies it, and locks it into memory. If locking fails, e.g. because
+// RLIMIT_MEMLOCK is too low, the buffer is still used, unlocked.
+func allocate(size int) ([]byte, bool) {
+    buf, err := unix.Mmap(-1, 0, size, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_ANON|unix.MAP_PRIVATE)
+    if err != nil {
+        return make([]byte, size), false
+    }
+    if err := unix.Mlock(buf); err != nil {
+        return buf, false
+    }
+    return buf, true
+}
+
+func release(buf []byte, locked bool) {
+    if locked {
+        unix.Munlock(buf)
+    }
+    // Munmap refuses heap buffers from a failed mmap; the garbage collector
+    // frees those
+    unix.Munmap(buf)
+}
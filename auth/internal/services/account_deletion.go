@@ -0,0 +1,438 @@
+package services
+
+import (
+    "context"
+    "fmt"
+    "time"
+
+    "auth-service/internal/apperr"
+    "auth-service/internal/database"
+    "auth-service/internal/events"
+    "auth-service/internal/models"
+
+    "github.com/google/uuid"
+    "github.com/jackc/pgx/v5"
+    "go.uber.org/zap"
+)
+
+var (
+    ErrDeletionInProgress = apperr.New(apperr.Conflict, "Account deletion already in progress", nil)
+    ErrDeletionNotFound   = apperr.New(apperr.NotFound, "No account deletion requested", nil)
+)
+
+const (
+    // Failed stages are retried after attempts * deletionRetryBackoff, up
+    // to deletionMaxAttempts times
+    deletionMaxAttempts  = 5
+    deletionRetryBackoff = time.Minute
+    // How long a worker owns a claimed job before another may pick it up
+    deletionLease = 5 * time.Minute
+)
+
+// Tables holding per-user rows that are purged before the account itself
+var deletionUserTables = []string{
+    "sessions",
+    "refresh_token_lineage",
+    "recovery_codes",
+    "recovery_requests",
+    "email_verification_tokens",
+    "login_confirmation_tokens",
+    "login_challenges",
+    "user_webhooks",
+    "onboarding_milestones",
+    "email_outbox",
+    "moderation_checks",
+    "moderation_denials",
+    "login_failures",
+    "account_notes",
+}
+
+// AccountDeletionService deletes accounts in one of the modes users can
+// choose from and tells downstream services which one was chosen. A request
+// revokes access right away and queues the rest, which a background worker
+// works through stage by stage (models.DeletionStages).
+type AccountDeletionService struct {
+    db       *database.DB
+    users    *UserService
+    webhooks *WebhookService
+    events   EventPublisher
+    logger   *zap.SugaredLogger
+    // Announces the sessions revoked by a deletion request; nil to skip
+    forcedLogouts *ForcedLogoutService
+}
+
+func NewAccountDeletionService(db *database.DB, users *UserService, webhooks *WebhookService, events EventPublisher, logger *zap.SugaredLogger) *AccountDeletionService {
+    return &AccountDeletionService{
+        db:       db,
+        users:    users,
+        webhooks: webhooks,
+        events:   events,
+        logger:   logger,
+    }
+}
+
+// SetForcedLogouts announces the sessions revoked when deletion is
+// requested.
+func (s *AccountDeletionService) SetForcedLogouts(forcedLogouts *ForcedLogoutService) {
+    s.forcedLogouts = forcedLogouts
+}
+
+// deletionJob is a queued deletion as seen by the worker.
+type deletionJob struct {
+    id         uuid.UUID
+    userID     uuid.UUID
+    username   string
+    mode       string
+    stagesDone int
+    attempts   int
+}
+
+// Request queues the deletion of an account in the given mode
+// (models.DeletionMode*, default delete) and revokes its access: sessions and
+// pending login links are deleted and the account can no longer log in. The
+// export mode also returns the account's data as it was before the request;
+// the other modes return a nil export.
+func (s *AccountDeletionService) Request(ctx context.Context, userID uuid.UUID, mode string) (*models.AccountDeletion, *models.AccountExport, error) {
+    if mode == "" {
+        mode = models.DeletionModeDelete
+    }
+
+    user, err := s.users.getUserByID(ctx, userID)
+    if err != nil {
+        return nil, nil, err
+    }
+
+    var export *models.AccountExport
+    if mode == models.DeletionModeExport {
+        if export, err = s.export(ctx, user); err != nil {
+            return nil, nil, err
+        }
+    }
+
+    tx, err := s.db.Pool().Begin(ctx)
+    if err != nil {
+        return nil, nil, fmt.Errorf("begin transaction: %w", err)
+    }
+    defer tx.Rollback(ctx)
+
+    deletion := &models.AccountDeletion{
+        ID:          uuid.New(),
+        Mode:        mode,
+        Status:      models.DeletionStatusPending,
+        RequestedAt: time.Now().UTC().Truncate(time.Microsecond),
+    }
+    result, err := tx.Exec(ctx,
+        `INSERT INTO account_deletions (id, user_id, username, mode, stages_done, requested_at, next_attempt_at)
+         VALUES ($1, $2, $3, $4, 1, $5, $5)
+         ON CONFLICT (user_id) WHERE status = 'pending' DO NOTHING`,
+        deletion.ID, userID, user.Username, mode, deletion.RequestedAt,
+    )
+    if err != nil {
+        return nil, nil, fmt.Errorf("queue account deletion: %w", err)
+    }
+    if result.RowsAffected() == 0 {
+        return nil, nil, ErrDeletionInProgress
+    }
+
+    // Revoke access in the same transaction so a queued account can't be
+    // used in the meantime
+    _, err = tx.Exec(ctx,
+        `UPDATE users SET deletion_requested_at = NOW(), reset_token = NULL, reset_expiry = NULL,
+             change_seq = change_seq + 1
+         WHERE id = $1`,
+        userID,
+    )
+    if err != nil {
+        return nil, nil, fmt.Errorf("revoke access: %w", err)
+    }
+    if err := enqueueSnapshot(ctx, tx, userID); err != nil {
+        return nil, nil, err
+    }
+    for _, table := range []string{"sessions", "login_confirmation_tokens", "login_challenges", "recovery_requests"} {
+        if _, err := tx.Exec(ctx, "DELETE FROM "+table+" WHERE user_id = $1", userID); err != nil {
+            return nil, nil, fmt.Errorf("delete %s: %w", table, err)
+        }
+    }
+
+    if err := tx.Commit(ctx); err != nil {
+        return nil, nil, fmt.Errorf("commit transaction: %w", err)
+    }
+    s.forcedLogouts.Publish(ctx, userID, nil, models.LogoutReasonAccountDeleted)
+
+    deletion.Stages = deletionStages(1)
+    return deletion, export, nil
+}
+
+// Status returns the user's most recent deletion request.
+func (s *AccountDeletionService) Status(ctx context.Context, userID uuid.UUID) (*models.AccountDeletion, error) {
+    deletion := &models.AccountDeletion{}
+    var stagesDone int
+    err := s.db.Pool().QueryRow(ctx,
+        `SELECT id, mode, status, stages_done, requested_at, completed_at FROM account_deletions
+         WHERE user_id = $1 ORDER BY requested_at DESC LIMIT 1`,
+        userID,
+    ).Scan(&deletion.ID, &deletion.Mode, &deletion.Status, &stagesDone, &deletion.RequestedAt, &deletion.CompletedAt)
+    if err != nil {
+        if err == pgx.ErrNoRows {
+            return nil, ErrDeletionNotFound
+        }
+        return nil, fmt.Errorf("get account deletion: %w", err)
+    }
+
+    deletion.Stages = deletionStages(stagesDone)
+    return deletion, nil
+}
+
+func deletionStages(done int) []models.DeletionStage {
+    stages := make([]models.DeletionStage, len(models.DeletionStages))
+    for i, name := range models.DeletionStages {
+        stages[i] = models.DeletionStage{Name: name, Done: i < done}
+    }
+    return stages
+}
+
+// RunWorker works through up to batchSize queued deletions every interval
+// until ctx is cancelled. The batch size throttles how much deletion work
+// each instance does at once.
+func (s *AccountDeletionService) RunWorker(ctx context.Context, interval time.Duration, batchSize int) {
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+            if _, err := s.ProcessPending(ctx, batchSize); err != nil {
+                s.logger.Errorf("Failed to process account deletions: %v", err)
+            }
+        }
+    }
+}
+
+// ProcessPending claims up to limit due deletions and runs their remaining
+// stages. It returns how many deletions it claimed. Claims are leased, so
+// several instances can run the worker side by side.
+func (s *AccountDeletionService) ProcessPending(ctx context.Context, limit int) (int, error) {
+    rows, err := s.db.Pool().Query(ctx,
+        `UPDATE account_deletions SET next_attempt_at = $2
+         WHERE id IN (
+             SELECT id FROM account_deletions
+             WHERE status = 'pending' AND next_attempt_at <= NOW()
+             ORDER BY requested_at
+             LIMIT $1
+             FOR UPDATE SKIP LOCKED
+         )
+         RETURNING id, user_id, username, mode, stages_done, attempts`,
+        limit, time.Now().Add(deletionLease),
+    )
+    if err != nil {
+        return 0, fmt.Errorf("claim account deletions: %w", err)
+    }
+
+    var jobs []*deletionJob
+    for rows.Next() {
+        job := &deletionJob{}
+        if err := rows.Scan(&job.id, &job.userID, &job.username, &job.mode, &job.stagesDone, &job.attempts); err != nil {
+            rows.Close()
+            return 0, fmt.Errorf("scan account deletion: %w", err)
+        }
+        jobs = append(jobs, job)
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil {
+        return 0, fmt.Errorf("claim account deletions: %w", err)
+    }
+
+    for _, job := range jobs {
+        if err := s.process(ctx, job); err != nil {
+            s.fail(ctx, job, err)
+        }
+    }
+    return len(jobs), nil
+}
+
+// process runs a job's remaining stages, recording each one as it completes.
+func (s *AccountDeletionService) process(ctx context.Context, job *deletionJob) error {
+    for i := job.stagesDone; i < len(models.DeletionStages); i++ {
+        stage := models.DeletionStages[i]
+        if err := s.runStage(ctx, job, stage); err != nil {
+            return fmt.Errorf("%s: %w", stage, err)
+        }
+
+        status := models.DeletionStatusPending
+        if i == len(models.DeletionStages)-1 {
+            status = models.DeletionStatusCompleted
+        }
+        _, err := s.db.Pool().Exec(ctx,
+            `UPDATE account_deletions SET stages_done = $2, status = $3,
+                 completed_at = CASE WHEN $3 = 'completed' THEN NOW() END
+             WHERE id = $1`,
+            job.id, i+1, status,
+        )
+        if err != nil {
+            return fmt.Errorf("record %s: %w", stage, err)
+        }
+    }
+
+    // Attributed to the old username so downstream services can match it
+    eventType := events.UserDeleted
+    switch job.mode {
+    case models.DeletionModeAnonymize:
+        eventType = events.UserAnonymized
+    case models.DeletionModeExport:
+        eventType = events.UserExportedDeleted
+    }
+    event := events.NewUserEvent(eventType, job.userID.String(), job.username)
+    if err := s.events.PublishUserEvent(event); err != nil {
+        s.logger.Errorf("Failed to publish %s event: %v", eventType, err)
+    }
+
+    return nil
+}
+
+func (s *AccountDeletionService) runStage(ctx context.Context, job *deletionJob, stage string) error {
+    switch stage {
+    case models.DeletionStagePurgeData:
+        return s.purgeData(ctx, job.userID)
+    case models.DeletionStagePurgeCache:
+        if _, err := s.users.PurgeUserData(ctx, job.userID); err != nil {
+            return err
+        }
+        return forgetUsernames(ctx, s.users.redis, job.username)
+    case models.DeletionStageFinalize:
+        if job.mode == models.DeletionModeAnonymize {
+            return s.anonymize(ctx, job.userID)
+        }
+        return s.users.DeleteUser(ctx, job.userID)
+    }
+    // Access is revoked when the deletion is requested
+    return nil
+}
+
+// fail records a failed attempt and schedules a retry, giving up after
+// deletionMaxAttempts.
+func (s *AccountDeletionService) fail(ctx context.Context, job *deletionJob, cause error) {
+    attempts := job.attempts + 1
+    status := models.DeletionStatusPending
+    if attempts >= deletionMaxAttempts {
+        status = models.DeletionStatusFailed
+        s.logger.Errorf("Giving up on deletion %s of user %s: %v", job.id, job.userID, cause)
+    } else {
+        s.logger.Warnf("Deletion %s of user %s failed (attempt %d): %v", job.id, job.userID, attempts, cause)
+    }
+
+    _, err := s.db.Pool().Exec(ctx,
+        `UPDATE account_deletions SET attempts = $2, status = $3, last_error = $4, next_attempt_at = $5
+         WHERE id = $1`,
+        job.id, attempts, status, cause.Error(), time.Now().Add(time.Duration(attempts)*deletionRetryBackoff),
+    )
+    if err != nil {
+        s.logger.Errorf("Failed to record deletion failure: %v", err)
+    }
+}
+
+// purgeData deletes the user's rows in related tables and the blocks they
+// made.
+func (s *AccountDeletionService) purgeData(ctx context.Context, userID uuid.UUID) error {
+    tx, err := s.db.Pool().Begin(ctx)
+    if err != nil {
+        return fmt.Errorf("begin transaction: %w", err)
+    }
+    defer tx.Rollback(ctx)
+
+    for _, table := range deletionUserTables {
+        if _, err := tx.Exec(ctx, "DELETE FROM "+table+" WHERE user_id = $1", userID); err != nil {
+            return fmt.Errorf("delete %s: %w", table, err)
+        }
+    }
+
+    if _, err := tx.Exec(ctx, "DELETE FROM user_blocks WHERE blocker_id = $1", userID); err != nil {
+        return fmt.Errorf("delete user_blocks: %w", err)
+    }
+
+    if err := tx.Commit(ctx); err != nil {
+        return fmt.Errorf("commit transaction: %w", err)
+    }
+    return nil
+}
+
+// anonymize strips everything that identifies the user but keeps the row, so
+// content elsewhere stays attributed to a "deleted user". The account can't
+// be logged into or recovered afterwards.
+func (s *AccountDeletionService) anonymize(ctx context.Context, userID uuid.UUID) error {
+    tx, err := s.db.Pool().Begin(ctx)
+    if err != nil {
+        return fmt.Errorf("begin transaction: %w", err)
+    }
+    defer tx.Rollback(ctx)
+
+    _, err = tx.Exec(ctx,
+        `UPDATE users SET
+             email = id::text || '@deleted.invalid',
+             username = 'deleted_' || replace(id::text, '-', ''),
+             password_hash = '',
+             email_verified = false, email_verified_at = NULL, email_bounced_at = NULL,
+             recovery_email = NULL, reset_token = NULL, reset_expiry = NULL,
+             travel_mode_until = NULL, travel_mode_user_agent = NULL,
+             totp_secret = NULL, totp_pending_secret = NULL, totp_enabled_at = NULL, totp_last_step = NULL,
+             display_name = NULL, avatar_url = NULL, date_of_birth = NULL, discoverable = false, public_card = false,
+             role = 'user', last_login = NULL, last_login_ip = NULL, change_seq = change_seq + 1, updated_at = NOW()
+         WHERE id = $1`,
+        userID,
+    )
+    if err != nil {
+        return fmt.Errorf("anonymize user: %w", err)
+    }
+    if _, err := tx.Exec(ctx, "DELETE FROM user_badges WHERE user_id = $1", userID); err != nil {
+        return fmt.Errorf("delete badges: %w", err)
+    }
+    if err := enqueueSnapshot(ctx, tx, userID); err != nil {
+        return err
+    }
+    if err := tx.Commit(ctx); err != nil {
+        return fmt.Errorf("commit transaction: %w", err)
+    }
+    return nil
+}
+
+func (s *AccountDeletionService) export(ctx context.Context, user *models.User) (*models.AccountExport, error) {
+    rows, err := s.db.Pool().Query(ctx,
+        `SELECT `+sessionInfoColumns+` FROM sessions WHERE user_id = $1 ORDER BY created_at DESC`,
+        user.ID,
+    )
+    if err != nil {
+        return nil, fmt.Errorf("export sessions: %w", err)
+    }
+    defer rows.Close()
+
+    sessions := []*models.SessionInfo{}
+    for rows.Next() {
+        info, err := scanSessionInfo(rows)
+        if err != nil {
+            return nil, fmt.Errorf("scan session: %w", err)
+        }
+        sessions = append(sessions, info)
+    }
+    if err := rows.Err(); err != nil {
+        return nil, fmt.Errorf("export sessions: %w", err)
+    }
+
+    webhooks, err := s.webhooks.List(ctx, user.ID)
+    if err != nil {
+        return nil, err
+    }
+
+    notes, err := exportAccountNotes(ctx, s.db, user.ID)
+    if err != nil {
+        return nil, err
+    }
+
+    return &models.AccountExport{
+        User:       models.NewPrivateUser(user),
+        Sessions:   sessions,
+        Webhooks:   webhooks,
+        Notes:      notes,
+        ExportedAt: time.Now().UTC(),
+    }, nil
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"auth-service/internal/events"
+	"auth-service/internal/models"
+	"auth-service/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccountDeletionService_Modes(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	userService := NewUserService(suite.DB.DB, suite.Redis.Client, suite.Logger)
+	authService := NewAuthService(suite.DB.DB, suite.Redis.Client, suite.Config, suite.Logger, suite.Events)
+	deletion := NewAccountDeletionService(suite.DB.DB, userService, NewWebhookService(suite.DB.DB, suite.Logger), suite.Events, suite.Logger)
+	ctx := context.Background()
+
+	t.Run("anonymize keeps the account row", func(t *testing.T) {
+		user := suite.CreateTestUser(t, "anon@example.com", "anon", test.TestData.ValidPassword)
+		suite.CreateTestSession(t, user.ID)
+
+		request, export, err := deletion.Request(ctx, user.ID, models.DeletionModeAnonymize)
+		require.NoError(t, err)
+		assert.Nil(t, export)
+		assert.Equal(t, models.DeletionStatusPending, request.Status)
+
+		// Access is revoked before the worker runs
+		sessions, err := authService.ListSessions(ctx, user.ID)
+		require.NoError(t, err)
+		assert.Empty(t, sessions)
+
+		_, _, err = authService.Login(ctx, &models.LoginRequest{Email: user.Email, Password: test.TestData.ValidPassword}, "test-agent", "10.0.0.1", "web")
+		assert.Equal(t, ErrInvalidCredentials, err)
+
+		_, _, err = deletion.Request(ctx, user.ID, models.DeletionModeDelete)
+		assert.Equal(t, ErrDeletionInProgress, err)
+
+		processed, err := deletion.ProcessPending(ctx, 10)
+		require.NoError(t, err)
+		assert.Equal(t, 1, processed)
+
+		status, err := deletion.Status(ctx, user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.DeletionStatusCompleted, status.Status)
+		assert.NotNil(t, status.CompletedAt)
+		for _, stage := range status.Stages {
+			assert.True(t, stage.Done, stage.Name)
+		}
+
+		anonymized, err := userService.GetUserByID(ctx, user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "deleted_"+strings.ReplaceAll(user.ID.String(), "-", ""), anonymized.Username)
+		assert.NotEqual(t, user.Email, anonymized.Email)
+
+		_, _, err = authService.Login(ctx, &models.LoginRequest{Email: user.Email, Password: test.TestData.ValidPassword}, "test-agent", "10.0.0.1", "web")
+		assert.Equal(t, ErrInvalidCredentials, err)
+	})
+
+	t.Run("export returns the data before deleting", func(t *testing.T) {
+		user := suite.CreateTestUser(t, "export@example.com", "export", test.TestData.ValidPassword)
+		session := suite.CreateTestSession(t, user.ID)
+
+		_, export, err := deletion.Request(ctx, user.ID, models.DeletionModeExport)
+		require.NoError(t, err)
+		require.NotNil(t, export)
+		assert.Equal(t, user.ID, export.User.ID)
+		require.Len(t, export.Sessions, 1)
+		assert.Equal(t, session.ID, export.Sessions[0].ID)
+
+		_, err = deletion.ProcessPending(ctx, 10)
+		require.NoError(t, err)
+
+		_, err = userService.GetUserByID(ctx, user.ID)
+		assert.Equal(t, ErrUserNotFound, err)
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		user := suite.CreateTestUser(t, "gone@example.com", "gone", test.TestData.ValidPassword)
+		require.NoError(t, userService.DeleteUser(ctx, user.ID))
+
+		_, _, err := deletion.Request(ctx, user.ID, models.DeletionModeDelete)
+		assert.Equal(t, ErrUserNotFound, err)
+
+		_, err = deletion.Status(ctx, user.ID)
+		assert.Equal(t, ErrDeletionNotFound, err)
+	})
+
+	var published []events.EventType
+	for _, event := range suite.Events.Events {
+		published = append(published, event.Type)
+	}
+	assert.Contains(t, published, events.UserAnonymized)
+	assert.Contains(t, published, events.UserExportedDeleted)
+}
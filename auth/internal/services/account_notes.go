@@ -0,0 +1,179 @@
+package services
+
+import (
+    "context"
+    "fmt"
+
+    "auth-service/internal/apperr"
+    "auth-service/internal/database"
+    "auth-service/internal/models"
+
+    "github.com/google/uuid"
+    "github.com/jackc/pgx/v5"
+    "go.uber.org/zap"
+)
+
+var (
+    ErrAccountNoteNotFound  = apperr.New(apperr.NotFound, "Note not found", nil)
+    ErrAccountFlagSet       = apperr.New(apperr.Conflict, "The user already has this flag", nil)
+    ErrRestrictedNoteDenied = apperr.New(apperr.Forbidden, "Not allowed to write restricted notes", nil)
+)
+
+const accountNoteColumns = `id, user_id, flag, body, restricted, export_exempt, author_id, created_at`
+
+// AccountNoteService keeps the internal notes and support flags admins put
+// on accounts. Restricted notes are hidden from every admin but the ones
+// configured in RESTRICTED_NOTES_ADMINS.
+type AccountNoteService struct {
+    db               *database.DB
+    restrictedAdmins map[uuid.UUID]bool
+    logger           *zap.SugaredLogger
+}
+
+func NewAccountNoteService(db *database.DB, restrictedAdmins []uuid.UUID, logger *zap.SugaredLogger) *AccountNoteService {
+    allowed := make(map[uuid.UUID]bool, len(restrictedAdmins))
+    for _, id := range restrictedAdmins {
+        allowed[id] = true
+    }
+    return &AccountNoteService{
+        db:               db,
+        restrictedAdmins: allowed,
+        logger:           logger,
+    }
+}
+
+// CanSeeRestricted reports whether the admin may read and write restricted
+// notes.
+func (s *AccountNoteService) CanSeeRestricted(adminID uuid.UUID) bool {
+    return s.restrictedAdmins[adminID]
+}
+
+// List returns the user's notes and flags newest first, leaving out
+// restricted ones unless the admin may see them.
+func (s *AccountNoteService) List(ctx context.Context, userID, adminID uuid.UUID) ([]*models.AccountNote, error) {
+    rows, err := s.db.Pool().Query(ctx,
+        `SELECT `+accountNoteColumns+` FROM account_notes
+         WHERE user_id = $1 AND (NOT restricted OR $2)
+         ORDER BY created_at DESC`,
+        userID, s.CanSeeRestricted(adminID),
+    )
+    if err != nil {
+        return nil, fmt.Errorf("list account notes: %w", err)
+    }
+    defer rows.Close()
+
+    notes := []*models.AccountNote{}
+    for rows.Next() {
+        note, err := scanAccountNote(rows)
+        if err != nil {
+            return nil, fmt.Errorf("scan account note: %w", err)
+        }
+        notes = append(notes, note)
+    }
+    return notes, rows.Err()
+}
+
+// Create adds a note or flag to the user's account, written by adminID.
+func (s *AccountNoteService) Create(ctx context.Context, userID, adminID uuid.UUID, req *models.CreateAccountNoteRequest) (*models.AccountNote, error) {
+    if req.Restricted && !s.CanSeeRestricted(adminID) {
+        return nil, ErrRestrictedNoteDenied
+    }
+
+    tx, err := s.db.Pool().Begin(ctx)
+    if err != nil {
+        return nil, fmt.Errorf("begin transaction: %w", err)
+    }
+    defer tx.Rollback(ctx)
+
+    // Locking the user serializes flags being set concurrently
+    var exists bool
+    err = tx.QueryRow(ctx, "SELECT true FROM users WHERE id = $1 FOR UPDATE", userID).Scan(&exists)
+    if err != nil {
+        if err == pgx.ErrNoRows {
+            return nil, ErrUserNotFound
+        }
+        return nil, fmt.Errorf("get user: %w", err)
+    }
+
+    if req.Flag != "" {
+        var flagged bool
+        err = tx.QueryRow(ctx,
+            "SELECT EXISTS(SELECT 1 FROM account_notes WHERE user_id = $1 AND flag = $2)",
+            userID, req.Flag,
+        ).Scan(&flagged)
+        if err != nil {
+            return nil, fmt.Errorf("check account flag: %w", err)
+        }
+        if flagged {
+            return nil, ErrAccountFlagSet
+        }
+    }
+
+    note, err := scanAccountNote(tx.QueryRow(ctx,
+        `INSERT INTO account_notes (user_id, flag, body, restricted, export_exempt, author_id)
+         VALUES ($1, NULLIF($2, ''), $3, $4, $5, $6)
+         RETURNING `+accountNoteColumns,
+        userID, req.Flag, req.Body, req.Restricted, req.ExportExempt, adminID,
+    ))
+    if err != nil {
+        return nil, fmt.Errorf("create account note: %w", err)
+    }
+
+    if err := tx.Commit(ctx); err != nil {
+        return nil, fmt.Errorf("commit transaction: %w", err)
+    }
+    return note, nil
+}
+
+// Delete removes one of the user's notes or flags and returns it. Restricted
+// notes the admin can't see are reported as not found.
+func (s *AccountNoteService) Delete(ctx context.Context, userID, noteID, adminID uuid.UUID) (*models.AccountNote, error) {
+    note, err := scanAccountNote(s.db.Pool().QueryRow(ctx,
+        `DELETE FROM account_notes
+         WHERE id = $1 AND user_id = $2 AND (NOT restricted OR $3)
+         RETURNING `+accountNoteColumns,
+        noteID, userID, s.CanSeeRestricted(adminID),
+    ))
+    if err != nil {
+        if err == pgx.ErrNoRows {
+            return nil, ErrAccountNoteNotFound
+        }
+        return nil, fmt.Errorf("delete account note: %w", err)
+    }
+    return note, nil
+}
+
+// exportAccountNotes returns the notes and flags that belong in the user's
+// data export, oldest first.
+func exportAccountNotes(ctx context.Context, db *database.DB, userID uuid.UUID) ([]*models.ExportedAccountNote, error) {
+    rows, err := db.Pool().Query(ctx,
+        `SELECT flag, body, created_at FROM account_notes
+         WHERE user_id = $1 AND NOT export_exempt
+         ORDER BY created_at`,
+        userID,
+    )
+    if err != nil {
+        return nil, fmt.Errorf("export account notes: %w", err)
+    }
+    defer rows.Close()
+
+    notes := []*models.ExportedAccountNote{}
+    for rows.Next() {
+        note := &models.ExportedAccountNote{}
+        if err := rows.Scan(&note.Flag, &note.Body, &note.CreatedAt); err != nil {
+            return nil, fmt.Errorf("scan account note: %w", err)
+        }
+        notes = append(notes, note)
+    }
+    return notes, rows.Err()
+}
+
+func scanAccountNote(row pgx.Row) (*models.AccountNote, error) {
+    note := &models.AccountNote{}
+    err := row.Scan(&note.ID, &note.UserID, &note.Flag, &note.Body, &note.Restricted,
+        &note.ExportExempt, &note.AuthorID, &note.CreatedAt)
+    if err != nil {
+        return nil, err
+    }
+    return note, nil
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"auth-service/internal/models"
+	"auth-service/test"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccountNoteService(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	ctx := context.Background()
+	support := suite.CreateTestUser(t, "support@example.com", "support", test.TestData.ValidPassword)
+	risk := suite.CreateTestUser(t, "risk@example.com", "risk", test.TestData.ValidPassword)
+	user := suite.CreateTestUser(t, test.TestData.ValidEmail, test.TestData.ValidUsername, test.TestData.ValidPassword)
+	notes := NewAccountNoteService(suite.DB.DB, []uuid.UUID{risk.ID}, suite.Logger)
+
+	_, err := notes.Create(ctx, user.ID, support.ID, &models.CreateAccountNoteRequest{Body: "Asked about refunds"})
+	require.NoError(t, err)
+	_, err = notes.Create(ctx, user.ID, support.ID, &models.CreateAccountNoteRequest{Flag: models.AccountFlagVIP})
+	require.NoError(t, err)
+	_, err = notes.Create(ctx, user.ID, support.ID, &models.CreateAccountNoteRequest{Flag: models.AccountFlagVIP})
+	assert.ErrorIs(t, err, ErrAccountFlagSet)
+	_, err = notes.Create(ctx, uuid.New(), support.ID, &models.CreateAccountNoteRequest{Body: "Nobody"})
+	assert.ErrorIs(t, err, ErrUserNotFound)
+
+	// Only configured admins write and read restricted notes
+	restricted := &models.CreateAccountNoteRequest{
+		Flag:         models.AccountFlagChargebackRisk,
+		Body:         "Two disputed payments",
+		Restricted:   true,
+		ExportExempt: true,
+	}
+	_, err = notes.Create(ctx, user.ID, support.ID, restricted)
+	assert.ErrorIs(t, err, ErrRestrictedNoteDenied)
+	flag, err := notes.Create(ctx, user.ID, risk.ID, restricted)
+	require.NoError(t, err)
+
+	visible, err := notes.List(ctx, user.ID, support.ID)
+	require.NoError(t, err)
+	assert.Len(t, visible, 2)
+	all, err := notes.List(ctx, user.ID, risk.ID)
+	require.NoError(t, err)
+	assert.Len(t, all, 3)
+
+	_, err = notes.Delete(ctx, user.ID, flag.ID, support.ID)
+	assert.ErrorIs(t, err, ErrAccountNoteNotFound)
+
+	// Exempt notes stay out of the user's export
+	exported, err := exportAccountNotes(ctx, suite.DB.DB, user.ID)
+	require.NoError(t, err)
+	require.Len(t, exported, 2)
+	assert.Equal(t, "Asked about refunds", exported[0].Body)
+
+	deleted, err := notes.Delete(ctx, user.ID, flag.ID, risk.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.AccountFlagChargebackRisk, *deleted.Flag)
+}
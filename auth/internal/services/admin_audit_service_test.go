@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"auth-service/internal/models"
+	"auth-service/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminAuditService_RecordAndList(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	auditService := NewAdminAuditService(suite.DB.DB, suite.Logger)
+	ctx := context.Background()
+
+	admin := suite.CreateTestUser(t, "admin@example.com", "admin", "password123")
+	target := suite.CreateTestUser(t, "target@example.com", "target", "password123")
+
+	err := auditService.Record(ctx, &models.AdminAuditEntry{
+		AdminID:      admin.ID,
+		Action:       models.AdminActionApproveRecovery,
+		TargetType:   models.AuditTargetRecoveryRequest,
+		TargetID:     "request-1",
+		TargetUserID: &target.ID,
+		IP:           "127.0.0.1",
+	}, map[string]string{"status": "pending"}, map[string]string{"status": "approved"})
+	require.NoError(t, err)
+
+	err = auditService.Record(ctx, &models.AdminAuditEntry{
+		AdminID:    admin.ID,
+		Action:     models.AdminActionCreateAPIKey,
+		TargetType: models.AuditTargetAPIKey,
+		TargetID:   "key-1",
+	}, nil, map[string]string{"name": "chat"})
+	require.NoError(t, err)
+
+	byAdmin, err := auditService.List(ctx, models.AdminAuditFilter{AdminID: &admin.ID})
+	require.NoError(t, err)
+	assert.Len(t, byAdmin, 2)
+
+	byTarget, err := auditService.List(ctx, models.AdminAuditFilter{TargetUserID: &target.ID})
+	require.NoError(t, err)
+	require.Len(t, byTarget, 1)
+	assert.Equal(t, models.AdminActionApproveRecovery, byTarget[0].Action)
+	assert.JSONEq(t, `{"status":"pending"}`, string(byTarget[0].Before))
+	assert.JSONEq(t, `{"status":"approved"}`, string(byTarget[0].After))
+
+	byOther, err := auditService.List(ctx, models.AdminAuditFilter{AdminID: &target.ID})
+	require.NoError(t, err)
+	assert.Empty(t, byOther)
+}
+
+func TestAdminAuditService_EntriesAreImmutable(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	auditService := NewAdminAuditService(suite.DB.DB, suite.Logger)
+	ctx := context.Background()
+
+	admin := suite.CreateTestUser(t, "admin@example.com", "admin", "password123")
+	entry := &models.AdminAuditEntry{
+		AdminID:    admin.ID,
+		Action:     models.AdminActionRevokeAPIKey,
+		TargetType: models.AuditTargetAPIKey,
+		TargetID:   "key-1",
+	}
+	require.NoError(t, auditService.Record(ctx, entry, nil, nil))
+
+	_, err := suite.DB.Pool().Exec(ctx, "UPDATE admin_audit_log SET action = 'x' WHERE id = $1", entry.ID)
+	assert.Error(t, err)
+
+	_, err = suite.DB.Pool().Exec(ctx, "DELETE FROM admin_audit_log WHERE id = $1", entry.ID)
+	assert.Error(t, err)
+}
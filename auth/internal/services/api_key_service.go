@@ -0,0 +1,228 @@
+package services
+
+import (
+    "context"
+    "fmt"
+    "strconv"
+    "time"
+
+    "auth-service/internal/apperr"
+    "auth-service/internal/database"
+    "auth-service/internal/models"
+    "auth-service/internal/redis"
+
+    "github.com/google/uuid"
+    "github.com/jackc/pgx/v5"
+    "go.uber.org/zap"
+)
+
+const apiKeyPrefix = "tk_"
+
+var (
+    ErrInvalidAPIKey  = apperr.New(apperr.Unauthenticated, "Invalid API key", nil)
+    ErrQuotaExceeded  = apperr.New(apperr.RateLimited, "API key quota exceeded", nil)
+    ErrAPIKeyNotFound = apperr.New(apperr.NotFound, "API key not found", nil)
+)
+
+type APIKeyService struct {
+    db     *database.DB
+    redis  *redis.Client
+    logger *zap.SugaredLogger
+}
+
+func NewAPIKeyService(db *database.DB, redis *redis.Client, logger *zap.SugaredLogger) *APIKeyService {
+    return &APIKeyService{
+        db:     db,
+        redis:  redis,
+        logger: logger,
+    }
+}
+
+// CreateKey stores a new API key and returns it together with the plaintext
+// key, which is never persisted and cannot be retrieved again.
+func (s *APIKeyService) CreateKey(ctx context.Context, req *models.CreateAPIKeyRequest) (*models.APIKey, string, error) {
+    rawKey := apiKeyPrefix + generateToken()
+
+    key := &models.APIKey{}
+    err := s.db.Pool().QueryRow(ctx,
+        `INSERT INTO api_keys (name, key_prefix, key_hash, daily_quota, monthly_quota)
+         VALUES ($1, $2, $3, $4, $5)
+         RETURNING id, name, key_prefix, daily_quota, monthly_quota, created_at, revoked_at`,
+        req.Name, rawKey[:len(apiKeyPrefix)+8], hashToken(rawKey), req.DailyQuota, req.MonthlyQuota,
+    ).Scan(&key.ID, &key.Name, &key.KeyPrefix, &key.DailyQuota, &key.MonthlyQuota, &key.CreatedAt, &key.RevokedAt)
+    if err != nil {
+        return nil, "", fmt.Errorf("create api key: %w", err)
+    }
+
+    return key, rawKey, nil
+}
+
+func (s *APIKeyService) ListKeys(ctx context.Context) ([]*models.APIKey, error) {
+    rows, err := s.db.Pool().Query(ctx,
+        `SELECT id, name, key_prefix, daily_quota, monthly_quota, created_at, revoked_at
+         FROM api_keys ORDER BY created_at DESC`,
+    )
+    if err != nil {
+        return nil, fmt.Errorf("list api keys: %w", err)
+    }
+    defer rows.Close()
+
+    keys := []*models.APIKey{}
+    for rows.Next() {
+        key := &models.APIKey{}
+        if err := rows.Scan(&key.ID, &key.Name, &key.KeyPrefix, &key.DailyQuota,
+            &key.MonthlyQuota, &key.CreatedAt, &key.RevokedAt); err != nil {
+            return nil, fmt.Errorf("scan api key: %w", err)
+        }
+        keys = append(keys, key)
+    }
+
+    return keys, rows.Err()
+}
+
+func (s *APIKeyService) GetKey(ctx context.Context, keyID uuid.UUID) (*models.APIKey, error) {
+    key := &models.APIKey{}
+    err := s.db.Pool().QueryRow(ctx,
+        `SELECT id, name, key_prefix, daily_quota, monthly_quota, created_at, revoked_at
+         FROM api_keys WHERE id = $1`,
+        keyID,
+    ).Scan(&key.ID, &key.Name, &key.KeyPrefix, &key.DailyQuota, &key.MonthlyQuota, &key.CreatedAt, &key.RevokedAt)
+    if err != nil {
+        if err == pgx.ErrNoRows {
+            return nil, ErrAPIKeyNotFound
+        }
+        return nil, fmt.Errorf("get api key: %w", err)
+    }
+
+    return key, nil
+}
+
+func (s *APIKeyService) RevokeKey(ctx context.Context, keyID uuid.UUID) error {
+    result, err := s.db.Pool().Exec(ctx,
+        "UPDATE api_keys SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL",
+        keyID,
+    )
+    if err != nil {
+        return fmt.Errorf("revoke api key: %w", err)
+    }
+
+    if result.RowsAffected() == 0 {
+        return ErrAPIKeyNotFound
+    }
+
+    return nil
+}
+
+// Authenticate resolves a plaintext key to its active API key record.
+func (s *APIKeyService) Authenticate(ctx context.Context, rawKey string) (*models.APIKey, error) {
+    key := &models.APIKey{}
+    err := s.db.Pool().QueryRow(ctx,
+        `SELECT id, name, key_prefix, daily_quota, monthly_quota, created_at, revoked_at
+         FROM api_keys WHERE key_hash = $1 AND revoked_at IS NULL`,
+        hashToken(rawKey),
+    ).Scan(&key.ID, &key.Name, &key.KeyPrefix, &key.DailyQuota, &key.MonthlyQuota, &key.CreatedAt, &key.RevokedAt)
+    if err != nil {
+        if err == pgx.ErrNoRows {
+            return nil, ErrInvalidAPIKey
+        }
+        return nil, fmt.Errorf("authenticate api key: %w", err)
+    }
+
+    return key, nil
+}
+
+// TrackUsage counts one request against the key's daily and monthly windows.
+// The returned usage is always populated; ErrQuotaExceeded is returned when
+// either window is over its configured quota (a quota of 0 means unlimited).
+func (s *APIKeyService) TrackUsage(ctx context.Context, key *models.APIKey) (*models.APIKeyUsage, error) {
+    now := time.Now().UTC()
+    usage := newAPIKeyUsage(key, now)
+
+    dailyKey, monthlyKey := usageKeys(key.ID, now)
+
+    daily, err := s.redis.Incr(ctx, dailyKey)
+    if err != nil {
+        return nil, fmt.Errorf("increment daily usage: %w", err)
+    }
+    if daily == 1 {
+        if err := s.redis.ExpireAt(ctx, dailyKey, usage.DailyResetAt.Add(time.Hour)); err != nil {
+            s.logger.Errorf("Failed to set daily usage expiry: %v", err)
+        }
+    }
+
+    monthly, err := s.redis.Incr(ctx, monthlyKey)
+    if err != nil {
+        return nil, fmt.Errorf("increment monthly usage: %w", err)
+    }
+    if monthly == 1 {
+        if err := s.redis.ExpireAt(ctx, monthlyKey, usage.MonthlyResetAt.Add(time.Hour)); err != nil {
+            s.logger.Errorf("Failed to set monthly usage expiry: %v", err)
+        }
+    }
+
+    usage.DailyCount = daily
+    usage.MonthlyCount = monthly
+
+    if (key.DailyQuota > 0 && daily > int64(key.DailyQuota)) ||
+        (key.MonthlyQuota > 0 && monthly > int64(key.MonthlyQuota)) {
+        return usage, ErrQuotaExceeded
+    }
+
+    return usage, nil
+}
+
+// GetUsage reports the current counters for a key without incrementing them.
+func (s *APIKeyService) GetUsage(ctx context.Context, key *models.APIKey) (*models.APIKeyUsage, error) {
+    now := time.Now().UTC()
+    usage := newAPIKeyUsage(key, now)
+
+    dailyKey, monthlyKey := usageKeys(key.ID, now)
+
+    daily, err := s.getCounter(ctx, dailyKey)
+    if err != nil {
+        return nil, err
+    }
+    monthly, err := s.getCounter(ctx, monthlyKey)
+    if err != nil {
+        return nil, err
+    }
+
+    usage.DailyCount = daily
+    usage.MonthlyCount = monthly
+
+    return usage, nil
+}
+
+func (s *APIKeyService) getCounter(ctx context.Context, key string) (int64, error) {
+    value, err := s.redis.Get(ctx, key)
+    if err != nil {
+        if err == redis.Nil {
+            return 0, nil
+        }
+        return 0, fmt.Errorf("get usage: %w", err)
+    }
+
+    count, err := strconv.ParseInt(value, 10, 64)
+    if err != nil {
+        return 0, fmt.Errorf("parse usage: %w", err)
+    }
+    return count, nil
+}
+
+func newAPIKeyUsage(key *models.APIKey, now time.Time) *models.APIKeyUsage {
+    startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+    startOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+    return &models.APIKeyUsage{
+        KeyID:          key.ID,
+        DailyQuota:     key.DailyQuota,
+        DailyResetAt:   startOfDay.AddDate(0, 0, 1),
+        MonthlyQuota:   key.MonthlyQuota,
+        MonthlyResetAt: startOfMonth.AddDate(0, 1, 0),
+    }
+}
+
+func usageKeys(keyID uuid.UUID, now time.Time) (string, string) {
+    return fmt.Sprintf("apikey:usage:%s:daily:%s", keyID, now.Format("20060102")),
+        fmt.Sprintf("apikey:usage:%s:monthly:%s", keyID, now.Format("200601"))
+}
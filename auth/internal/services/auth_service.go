@@ -3,37 +3,56 @@ package services
 import (
     "context"
     "crypto/rand"
+    "crypto/sha256"
     "encoding/hex"
     "errors"
     "fmt"
     "time"
 
+    "auth-service/internal/apperr"
     "auth-service/internal/config"
     "auth-service/internal/database"
     "auth-service/internal/events"
+    "auth-service/internal/metrics"
     "auth-service/internal/models"
     "auth-service/internal/redis"
 
     "github.com/google/uuid"
     "github.com/jackc/pgx/v5"
     "go.uber.org/zap"
-    "golang.org/x/crypto/bcrypt"
 )
 
 var (
-    ErrInvalidCredentials = errors.New("invalid credentials")
-    ErrEmailAlreadyExists = errors.New("email already exists")
-    ErrUsernameAlreadyExists = errors.New("username already exists")
-    ErrInvalidToken = errors.New("invalid token")
-    ErrTokenExpired = errors.New("token expired")
+    ErrInvalidCredentials = apperr.New(apperr.Unauthenticated, "Invalid credentials", nil)
+    ErrEmailAlreadyExists = apperr.New(apperr.Conflict, "Email already exists", nil)
+    ErrUsernameAlreadyExists = apperr.New(apperr.Conflict, "Username already exists", nil)
+    ErrInvalidToken = apperr.New(apperr.Invalid, "Invalid or expired token", nil)
+    ErrTokenExpired = apperr.New(apperr.Unauthenticated, "Token expired", nil)
+    ErrRefreshTokenReused = apperr.New(apperr.Unauthenticated, "Refresh token has already been used; session revoked", nil)
 )
 
 type AuthService struct {
-    db       *database.DB
-    redis    *redis.Client
-    config   *config.Config
-    logger   *zap.SugaredLogger
-    rabbitMQ EventPublisher
+    db         *database.DB
+    redis      *redis.Client
+    config     *config.Config
+    logger     *zap.SugaredLogger
+    rabbitMQ   EventPublisher
+    captcha    CaptchaVerifier
+    // Screens usernames chosen at registration; nil when moderation is off
+    moderation *ModerationService
+    // Records why logins fail; nil to skip
+    loginFailures *LoginFailureService
+    // Buffers last_login and login counts; nil to write last_login directly
+    loginStats *LoginStatsService
+    // Delivers verification, reset and login emails; nil to skip
+    mailer Mailer
+    // Announces revoked sessions; nil to skip
+    forcedLogouts *ForcedLogoutService
+    // Alerts the security team of new-device login spikes; nil to skip
+    alerts *OpsAlertService
+    // Dates sessions and reset tokens; replaced by a travelling clock in
+    // test mode
+    now func() time.Time
 }
 
 type EventPublisher interface {
@@ -47,10 +66,77 @@ func NewAuthService(db *database.DB, redis *redis.Client, config *config.Config,
         config:   config,
         logger:   logger,
         rabbitMQ: rabbitMQ,
+        now:      time.Now,
     }
 }
 
-func (s *AuthService) Register(ctx context.Context, req *models.RegisterRequest) (*models.User, error) {
+// SetClock makes the service date sessions and reset tokens, and check their
+// expiry, against now instead of the system clock.
+func (s *AuthService) SetClock(now func() time.Time) {
+    s.now = now
+}
+
+// Now returns the time sessions and reset tokens are dated and checked
+// against.
+func (s *AuthService) Now() time.Time {
+    return s.now()
+}
+
+// SetModeration screens usernames chosen at registration.
+func (s *AuthService) SetModeration(moderation *ModerationService) {
+    s.moderation = moderation
+}
+
+// SetMailer sends the service's emails through mailer.
+func (s *AuthService) SetMailer(mailer Mailer) {
+    s.mailer = mailer
+}
+
+// SetLoginFailures records the reason of every failed login.
+func (s *AuthService) SetLoginFailures(loginFailures *LoginFailureService) {
+    s.loginFailures = loginFailures
+}
+
+// SetLoginStats buffers successful logins for the login statistics instead
+// of updating the user's row on every login.
+func (s *AuthService) SetLoginStats(loginStats *LoginStatsService) {
+    s.loginStats = loginStats
+}
+
+// SetForcedLogouts announces revoked sessions so their clients are
+// disconnected.
+func (s *AuthService) SetForcedLogouts(forcedLogouts *ForcedLogoutService) {
+    s.forcedLogouts = forcedLogouts
+}
+
+// SetAlerts alerts the security team when logins from new devices spike.
+func (s *AuthService) SetAlerts(alerts *OpsAlertService) {
+    s.alerts = alerts
+}
+
+// DataRegion picks the data region for a new account registering with the
+// client hint (possibly "") from country (possibly "" if unknown).
+func (s *AuthService) DataRegion(hint, country string) string {
+    return s.config.DataResidency.Region(hint, country)
+}
+
+// RecordLoginFailure notes that a login failed for reason, one of the
+// metrics.LoginFailure* values. userID is nil when no account was identified.
+func (s *AuthService) RecordLoginFailure(ctx context.Context, reason string, userID *uuid.UUID, userAgent, ip, clientType string) {
+    if s.loginFailures == nil {
+        return
+    }
+    s.loginFailures.Record(ctx, &models.LoginFailure{
+        UserID:     userID,
+        Reason:     reason,
+        ClientType: clientType,
+        IP:         ip,
+        UserAgent:  userAgent,
+    })
+}
+
+// Register creates an account tagged with dataRegion, see DataRegion.
+func (s *AuthService) Register(ctx context.Context, req *models.RegisterRequest, dataRegion string) (*models.User, error) {
     // Check if email exists
     var exists bool
     err := s.db.Pool().QueryRow(ctx, 
@@ -76,33 +162,74 @@ func (s *AuthService) Register(ctx context.Context, req *models.RegisterRequest)
         return nil, ErrUsernameAlreadyExists
     }
 
-    // Hash password
-    hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+    outcome, err := s.moderation.Check(ctx, nil, models.ModerationFieldUsername, req.Username)
     if err != nil {
-        return nil, fmt.Errorf("hash password: %w", err)
+        return nil, err
     }
 
-    // Generate email verification token
-    emailToken := generateToken()
+    // With pre-registration codes the address is verified by the code
+    verified := false
+    if s.config.RegistrationEmailCode {
+        if err := s.checkRegistrationCode(ctx, req.Email, req.EmailCode); err != nil {
+            return nil, err
+        }
+        verified = true
+    }
+
+    // Hash password
+    hashedPassword, err := passwords.Hash(ctx, req.Password)
+    if err != nil {
+        return nil, err
+    }
 
     // Create user
-    user := &models.User{}
-    err = s.db.Pool().QueryRow(ctx,
-        `INSERT INTO users (email, username, password_hash, email_token)
-         VALUES ($1, $2, $3, $4)
-         RETURNING id, email, username, email_verified, created_at, updated_at`,
-        req.Email, req.Username, string(hashedPassword), emailToken,
-    ).Scan(&user.ID, &user.Email, &user.Username, &user.EmailVerified, &user.CreatedAt, &user.UpdatedAt)
+    tx, err := s.db.Pool().Begin(ctx)
+    if err != nil {
+        return nil, fmt.Errorf("begin transaction: %w", err)
+    }
+    defer tx.Rollback(ctx)
+
+    user := &models.User{Plan: models.PlanFree}
+    err = tx.QueryRow(ctx,
+        `INSERT INTO users (id, email, username, password_hash, email_verified, email_verified_at, data_region)
+         VALUES ($1, $2, $3, $4, $5, CASE WHEN $5 THEN NOW() END, $6)
+         RETURNING id, email, username, email_verified, email_verified_at, data_region, created_at, updated_at`,
+        NewID(s.config.IDVersion), req.Email, req.Username, hashedPassword, verified, dataRegion,
+    ).Scan(&user.ID, &user.Email, &user.Username, &user.EmailVerified, &user.EmailVerifiedAt, &user.DataRegion,
+        &user.CreatedAt, &user.UpdatedAt)
     
     if err != nil {
         return nil, fmt.Errorf("create user: %w", err)
     }
+    if err := enqueueSnapshot(ctx, tx, user.ID); err != nil {
+        return nil, err
+    }
+    if err := tx.Commit(ctx); err != nil {
+        return nil, fmt.Errorf("commit transaction: %w", err)
+    }
 
-    // Send verification email (implement email service)
-    // s.emailService.SendVerificationEmail(user.Email, emailToken)
+    // The name may have been cached as unknown
+    if err := forgetUsernames(ctx, s.redis, user.Username); err != nil {
+        s.logger.Errorf("Failed to invalidate username cache: %v", err)
+    }
+    s.moderation.Track(ctx, user.ID, models.ModerationFieldUsername, user.Username, outcome)
+    s.moderation.ScreenEmail(ctx, user.ID, user.Email)
+
+    if verified {
+        s.forgetRegistrationCode(ctx, user.Email)
+    } else {
+        // Generate email verification token
+        emailToken, err := issueEmailVerificationToken(ctx, s.db.Pool(), user.ID, user.Email, s.now().Add(s.config.EmailVerificationExpiry))
+        if err != nil {
+            return nil, err
+        }
+
+        sendEmail(ctx, s.mailer, s.logger, emailTemplateVerification, user.Email, map[string]string{"token": emailToken})
+    }
 
     // Publish user registration event
     event := events.NewUserEvent(events.UserRegister, user.ID.String(), user.Username)
+    event.DataRegion = user.DataRegion
     event.Data["email"] = user.Email
     if err := s.rabbitMQ.PublishUserEvent(event); err != nil {
         s.logger.Errorf("Failed to publish user registration event: %v", err)
@@ -112,82 +239,365 @@ func (s *AuthService) Register(ctx context.Context, req *models.RegisterRequest)
     return user, nil
 }
 
-func (s *AuthService) Login(ctx context.Context, req *models.LoginRequest, userAgent, ip string) (*models.User, *models.Session, error) {
+// Login checks a user's credentials and opens a session. Logins that must
+// pass challenges first (see loginChallenges) return a
+// *ChallengeRequiredError instead; accounts or IPs with recent failures are
+// asked for a captcha before learning whether the password was right.
+func (s *AuthService) Login(ctx context.Context, req *models.LoginRequest, userAgent, ip, clientType string) (*models.User, *models.Session, error) {
+    captcha := s.captchaRequired(ctx, ip, req.Email)
+
     // Get user by email
     user := &models.User{}
+    var travelUntil *time.Time
+    var travelAgent *string
+    var deletionRequestedAt *time.Time
+    var totpEnabledAt *time.Time
+    var tosAccepted *string
     err := s.db.Pool().QueryRow(ctx,
-        `SELECT id, email, username, password_hash, email_verified, created_at, updated_at, last_login
+        `SELECT id, email, username, password_hash, email_verified, is_guest, role, data_region, created_at, updated_at,
+                last_login, travel_mode_until, travel_mode_user_agent, deletion_requested_at, totp_enabled_at, tos_accepted_version
          FROM users WHERE email = $1`,
         req.Email,
     ).Scan(&user.ID, &user.Email, &user.Username, &user.PasswordHash, 
-           &user.EmailVerified, &user.CreatedAt, &user.UpdatedAt, &user.LastLogin)
+           &user.EmailVerified, &user.IsGuest, &user.Role, &user.DataRegion, &user.CreatedAt, &user.UpdatedAt, &user.LastLogin,
+           &travelUntil, &travelAgent, &deletionRequestedAt, &totpEnabledAt, &tosAccepted)
     
     if err != nil {
         if err == pgx.ErrNoRows {
-            return nil, nil, ErrInvalidCredentials
+            // Spend the same bcrypt time as a real account would
+            if err := passwords.Compare(ctx, dummyPasswordHash, req.Password); errors.Is(err, ErrPasswordBusy) {
+                return nil, nil, err
+            }
+            s.RecordLoginFailure(ctx, metrics.LoginFailureUnknownEmail, nil, userAgent, ip, clientType)
+            return nil, nil, s.invalidCredentials(ctx, req.Email, captcha)
         }
         return nil, nil, fmt.Errorf("get user: %w", err)
     }
 
     // Verify password
-    if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
-        return nil, nil, ErrInvalidCredentials
+    if err := passwords.Compare(ctx, user.PasswordHash, req.Password); err != nil {
+        if errors.Is(err, ErrInvalidCredentials) {
+            s.RecordLoginFailure(ctx, metrics.LoginFailureBadPassword, &user.ID, userAgent, ip, clientType)
+            return nil, nil, s.invalidCredentials(ctx, req.Email, captcha)
+        }
+        return nil, nil, err
     }
 
-    // Update last login
-    _, err = s.db.Pool().Exec(ctx,
-        "UPDATE users SET last_login = NOW() WHERE id = $1",
-        user.ID,
-    )
+    // Accounts queued for deletion look like they're already gone
+    if deletionRequestedAt != nil {
+        s.RecordLoginFailure(ctx, metrics.LoginFailureDeleted, &user.ID, userAgent, ip, clientType)
+        return nil, nil, s.invalidCredentials(ctx, req.Email, captcha)
+    }
+
+    // Travel mode only lets the pinned device and trusted devices log in
+    // directly
+    if travelModeBlocks(travelUntil, travelAgent, userAgent) {
+        trusted, err := s.isTrustedDevice(ctx, user.ID, userAgent)
+        if err != nil {
+            return nil, nil, err
+        }
+        if !trusted {
+            if err := s.requestLoginConfirmation(ctx, user); err != nil {
+                return nil, nil, err
+            }
+            return nil, nil, ErrLoginConfirmationRequired
+        }
+    }
+
+    challenges, err := s.loginChallenges(ctx, user, totpEnabledAt != nil, tosAccepted, userAgent)
+    if err != nil {
+        return nil, nil, err
+    }
+    if captcha {
+        challenges = append([]string{models.ChallengeCaptcha}, challenges...)
+    }
+    if len(challenges) > 0 {
+        return nil, nil, s.startChallenge(ctx, user, req.Email, challenges)
+    }
+
+    session, err := s.completeLogin(ctx, user, userAgent, ip, clientType)
     if err != nil {
+        return nil, nil, err
+    }
+    return user, session, nil
+}
+
+// invalidCredentials rejects a login, or hides the rejection behind a captcha
+// challenge when one is required.
+func (s *AuthService) invalidCredentials(ctx context.Context, email string, captcha bool) error {
+    if !captcha {
+        return ErrInvalidCredentials
+    }
+    return s.startChallenge(ctx, nil, email, []string{models.ChallengeCaptcha})
+}
+
+// completeLogin records a login for an authenticated user and opens its
+// session.
+func (s *AuthService) completeLogin(ctx context.Context, user *models.User, userAgent, ip, clientType string) (*models.Session, error) {
+    // The first login is written straight away: the funnel tells first
+    // logins apart by last_login
+    if s.loginStats != nil {
+        s.loginStats.Record(ctx, user.ID, ip, s.now(), user.LastLogin == nil)
+    } else if err := applyLogins(ctx, s.db.Pool(), user.ID, &pendingLogins{count: 1, at: s.now(), ip: ip}); err != nil {
         s.logger.Errorf("Failed to update last login: %v", err)
     }
 
-    // Create session
+    // Checked before the new session is recorded in the lineage
+    newDevice, err := s.isNewDevice(ctx, user.ID, userAgent)
+    if err != nil {
+        s.logger.Errorf("Failed to check for new device: %v", err)
+    }
+
+    session, err := s.createSession(ctx, user.ID, userAgent, ip, clientType)
+    if err != nil {
+        return nil, err
+    }
+
+    // A failure leaves the user over the cap until their next login
+    session.Evicted, err = s.evictSessions(ctx, user, session.ID)
+    if err != nil {
+        s.logger.Errorf("Failed to enforce session cap: %v", err)
+    }
+
+    event := events.NewUserEvent(events.UserLogin, user.ID.String(), user.Username)
+    event.DataRegion = user.DataRegion
+    event.Data["ip"] = ip
+    event.Data["user_agent"] = userAgent
+    event.Data["new_device"] = newDevice
+    if err := s.rabbitMQ.PublishUserEvent(event); err != nil {
+        s.logger.Errorf("Failed to publish user login event: %v", err)
+    }
+
+    if newDevice {
+        event := events.NewUserEvent(events.UserNewDevice, user.ID.String(), user.Username)
+        event.Data["ip"] = ip
+        event.Data["user_agent"] = userAgent
+        if err := s.rabbitMQ.PublishUserEvent(event); err != nil {
+            s.logger.Errorf("Failed to publish new device event: %v", err)
+        }
+
+        s.alerts.Count(ctx, config.AlertNewDeviceLoginSpike, func(threshold config.AlertThreshold) *OpsAlert {
+            return &OpsAlert{
+                Name:     config.AlertNewDeviceLoginSpike,
+                Severity: SeverityHigh,
+                Title:    "New device login spike",
+                Message:  fmt.Sprintf("%d logins from new devices within %s", threshold.Count, threshold.Window),
+                Fields: map[string]string{
+                    "last_user_id": user.ID.String(),
+                    "last_ip":      ip,
+                },
+            }
+        })
+    }
+
+    return session, nil
+}
+
+// isNewDevice reports whether the user has signed in before, but never with
+// this user agent. The refresh token lineage is used because it outlives
+// logged-out sessions.
+func (s *AuthService) isNewDevice(ctx context.Context, userID uuid.UUID, userAgent string) (bool, error) {
+    var seenBefore, seenAgent bool
+    err := s.db.Pool().QueryRow(ctx,
+        `SELECT EXISTS(SELECT 1 FROM refresh_token_lineage WHERE user_id = $1),
+                EXISTS(SELECT 1 FROM refresh_token_lineage WHERE user_id = $1 AND user_agent = $2)`,
+        userID, userAgent,
+    ).Scan(&seenBefore, &seenAgent)
+    if err != nil {
+        return false, fmt.Errorf("check device: %w", err)
+    }
+    return seenBefore && !seenAgent, nil
+}
+
+// RegisterGuest creates a guest account under the given generated handle and
+// opens a session for it. Guests have no usable password or real email.
+func (s *AuthService) RegisterGuest(ctx context.Context, handle, userAgent, ip, clientType, dataRegion string) (*models.User, *models.Session, error) {
+    userID := NewID(s.config.IDVersion)
+
+    hashedPassword, err := passwords.Hash(ctx, generateToken())
+    if err != nil {
+        return nil, nil, err
+    }
+
+    tx, err := s.db.Pool().Begin(ctx)
+    if err != nil {
+        return nil, nil, fmt.Errorf("begin transaction: %w", err)
+    }
+    defer tx.Rollback(ctx)
+
+    user := &models.User{Plan: models.PlanFree}
+    err = tx.QueryRow(ctx,
+        `INSERT INTO users (id, email, username, password_hash, is_guest, data_region)
+         VALUES ($1, $2, $3, $4, true, $5)
+         RETURNING id, email, username, email_verified, is_guest, data_region, created_at, updated_at`,
+        userID, fmt.Sprintf("%s@guest.invalid", userID), handle, hashedPassword, dataRegion,
+    ).Scan(&user.ID, &user.Email, &user.Username, &user.EmailVerified, &user.IsGuest, &user.DataRegion,
+        &user.CreatedAt, &user.UpdatedAt)
+    if err != nil {
+        return nil, nil, fmt.Errorf("create guest: %w", err)
+    }
+    if err := enqueueSnapshot(ctx, tx, user.ID); err != nil {
+        return nil, nil, err
+    }
+    if err := tx.Commit(ctx); err != nil {
+        return nil, nil, fmt.Errorf("commit transaction: %w", err)
+    }
+
+    if err := forgetUsernames(ctx, s.redis, user.Username); err != nil {
+        s.logger.Errorf("Failed to invalidate username cache: %v", err)
+    }
+
+    session, err := s.createSession(ctx, user.ID, userAgent, ip, clientType)
+    if err != nil {
+        return nil, nil, err
+    }
+
+    event := events.NewUserEvent(events.UserRegister, user.ID.String(), user.Username)
+    event.DataRegion = user.DataRegion
+    event.Data["guest"] = true
+    if err := s.rabbitMQ.PublishUserEvent(event); err != nil {
+        s.logger.Errorf("Failed to publish guest registration event: %v", err)
+    }
+
+    return user, session, nil
+}
+
+func (s *AuthService) createSession(ctx context.Context, userID uuid.UUID, userAgent, ip, clientType string) (*models.Session, error) {
     session := &models.Session{
-        ID:           uuid.New(),
-        UserID:       user.ID,
+        ID:           NewID(s.config.IDVersion),
+        UserID:       userID,
         RefreshToken: generateToken(),
         UserAgent:    userAgent,
         IP:           ip,
-        ExpiresAt:    time.Now().Add(s.config.RefreshExpiry),
+        ClientType:   clientType,
+        Region:       s.config.Region,
+        ExpiresAt:    s.now().Add(s.config.RefreshExpiry),
     }
 
-    _, err = s.db.Pool().Exec(ctx,
-        `INSERT INTO sessions (id, user_id, refresh_token, user_agent, ip, expires_at)
-         VALUES ($1, $2, $3, $4, $5, $6)`,
+    _, err := s.db.Pool().Exec(ctx,
+        `INSERT INTO sessions (id, user_id, refresh_token, user_agent, ip, client_type, region, expires_at)
+         VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
         session.ID, session.UserID, session.RefreshToken, 
-        session.UserAgent, session.IP, session.ExpiresAt,
+        session.UserAgent, session.IP, session.ClientType, session.Region, session.ExpiresAt,
     )
     if err != nil {
-        return nil, nil, fmt.Errorf("create session: %w", err)
+        return nil, fmt.Errorf("create session: %w", err)
     }
 
-    return user, session, nil
+    if err := recordRefreshTokenIssued(ctx, s.db.Pool(), session.ID, userID, nil, session.RefreshToken, userAgent, ip); err != nil {
+        return nil, err
+    }
+
+    return session, nil
 }
 
-func (s *AuthService) VerifyEmail(ctx context.Context, token string) error {
-    // Update user
-    result, err := s.db.Pool().Exec(ctx,
-        `UPDATE users SET email_verified = true, email_token = NULL
-         WHERE email_token = $1 AND email_verified = false`,
-        token,
+// VerifyEmail consumes a verification token and returns the verified user's
+// ID and whether this was the account's first verification. Tokens are single use, expire, and only verify the address they were
+// issued for, so a token sent before an email change can't verify the new
+// address.
+func (s *AuthService) VerifyEmail(ctx context.Context, token string) (uuid.UUID, bool, error) {
+    tx, err := s.db.Pool().Begin(ctx)
+    if err != nil {
+        return uuid.Nil, false, fmt.Errorf("begin transaction: %w", err)
+    }
+    defer tx.Rollback(ctx)
+
+    var userID uuid.UUID
+    var email string
+    err = tx.QueryRow(ctx,
+        `UPDATE email_verification_tokens SET used_at = NOW()
+         WHERE token_hash = $1 AND used_at IS NULL AND expires_at > $2
+         RETURNING user_id, email`,
+        hashToken(token), s.now(),
+    ).Scan(&userID, &email)
+    if err != nil {
+        if err == pgx.ErrNoRows {
+            return uuid.Nil, false, ErrInvalidToken
+        }
+        return uuid.Nil, false, fmt.Errorf("consume verification token: %w", err)
+    }
+
+    // Verified accounts use the same tokens to re-verify
+    var wasVerified bool
+    var username string
+    err = tx.QueryRow(ctx,
+        "SELECT email_verified, username FROM users WHERE id = $1 AND email = $2 FOR UPDATE",
+        userID, email,
+    ).Scan(&wasVerified, &username)
+    if err != nil {
+        if err == pgx.ErrNoRows {
+            return uuid.Nil, false, ErrInvalidToken
+        }
+        return uuid.Nil, false, fmt.Errorf("get user: %w", err)
+    }
+
+    _, err = tx.Exec(ctx,
+        `UPDATE users SET email_verified = true, email_verified_at = NOW(), email_bounced_at = NULL, updated_at = NOW()
+         WHERE id = $1`,
+        userID,
     )
     if err != nil {
-        return fmt.Errorf("verify email: %w", err)
+        return uuid.Nil, false, fmt.Errorf("verify email: %w", err)
+    }
+    if !wasVerified {
+        if err := enqueueBadgesChanged(ctx, tx, userID, username, models.BadgeEmailVerified, true); err != nil {
+            return uuid.Nil, false, err
+        }
     }
 
-    if result.RowsAffected() == 0 {
-        return ErrInvalidToken
+    if err := tx.Commit(ctx); err != nil {
+        return uuid.Nil, false, fmt.Errorf("commit transaction: %w", err)
+    }
+
+    if err := forgetSecurityScore(ctx, s.redis, userID); err != nil {
+        s.logger.Errorf("Failed to invalidate security score: %v", err)
+    }
+
+    return userID, !wasVerified, nil
+}
+
+// ResendVerification issues a fresh verification token for an unverified
+// account or one due for re-verification, invalidating any earlier ones.
+// Unknown or up-to-date emails and requests inside the cooldown are silently
+// ignored.
+func (s *AuthService) ResendVerification(ctx context.Context, email string) error {
+    user := &models.User{}
+    var lastSent *time.Time
+    err := s.db.Pool().QueryRow(ctx,
+        `SELECT u.id, u.email_verified, u.email_verified_at, u.email_bounced_at,
+                (SELECT MAX(t.created_at) FROM email_verification_tokens t WHERE t.user_id = u.id)
+         FROM users u WHERE u.email = $1 AND u.is_guest = false`,
+        email,
+    ).Scan(&user.ID, &user.EmailVerified, &user.EmailVerifiedAt, &user.EmailBouncedAt, &lastSent)
+    if err != nil {
+        if err == pgx.ErrNoRows {
+            return nil
+        }
+        return fmt.Errorf("get user: %w", err)
+    }
+
+    if user.EmailVerified && !EmailNeedsReverification(user, s.config.EmailReverifyMonths, s.now()) {
+        return nil
     }
 
+    if lastSent != nil && s.now().Sub(*lastSent) < emailVerificationResendCooldown {
+        return nil
+    }
+
+    emailToken, err := issueEmailVerificationToken(ctx, s.db.Pool(), user.ID, email, s.now().Add(s.config.EmailVerificationExpiry))
+    if err != nil {
+        return err
+    }
+
+    sendEmail(ctx, s.mailer, s.logger, emailTemplateVerification, email, map[string]string{"token": emailToken})
+
     return nil
 }
 
 func (s *AuthService) ForgotPassword(ctx context.Context, email string) error {
     // Generate reset token
     resetToken := generateToken()
-    resetExpiry := time.Now().Add(1 * time.Hour)
+    resetExpiry := s.now().Add(1 * time.Hour)
 
     // Update user
     result, err := s.db.Pool().Exec(ctx,
@@ -204,31 +614,35 @@ func (s *AuthService) ForgotPassword(ctx context.Context, email string) error {
         return nil
     }
 
-    // Send reset email (implement email service)
-    // s.emailService.SendResetEmail(email, resetToken)
+    sendEmail(ctx, s.mailer, s.logger, emailTemplatePasswordReset, email, map[string]string{"token": resetToken})
 
     return nil
 }
 
 func (s *AuthService) ResetPassword(ctx context.Context, token, newPassword string) error {
     // Hash new password
-    hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+    hashedPassword, err := passwords.Hash(ctx, newPassword)
     if err != nil {
-        return fmt.Errorf("hash password: %w", err)
+        return err
     }
 
     // Update password
-    result, err := s.db.Pool().Exec(ctx,
-        `UPDATE users SET password_hash = $1, reset_token = NULL, reset_expiry = NULL
-         WHERE reset_token = $2 AND reset_expiry > NOW()`,
-        string(hashedPassword), token,
-    )
+    var userID uuid.UUID
+    err = s.db.Pool().QueryRow(ctx,
+        `UPDATE users SET password_hash = $1, password_changed_at = NOW(), reset_token = NULL, reset_expiry = NULL
+         WHERE reset_token = $2 AND reset_expiry > $3
+         RETURNING id`,
+        hashedPassword, token, s.now(),
+    ).Scan(&userID)
     if err != nil {
+        if err == pgx.ErrNoRows {
+            return ErrInvalidToken
+        }
         return fmt.Errorf("reset password: %w", err)
     }
 
-    if result.RowsAffected() == 0 {
-        return ErrInvalidToken
+    if err := forgetSecurityScore(ctx, s.redis, userID); err != nil {
+        s.logger.Errorf("Failed to invalidate security score: %v", err)
     }
 
     return nil
@@ -237,11 +651,11 @@ func (s *AuthService) ResetPassword(ctx context.Context, token, newPassword stri
 func (s *AuthService) GetSessionByRefreshToken(ctx context.Context, token string) (*models.Session, error) {
     session := &models.Session{}
     err := s.db.Pool().QueryRow(ctx,
-        `SELECT id, user_id, refresh_token, user_agent, ip, expires_at, created_at
-         FROM sessions WHERE refresh_token = $1 AND expires_at > NOW()`,
-        token,
+        `SELECT id, user_id, refresh_token, user_agent, ip, client_type, expires_at, created_at
+         FROM sessions WHERE refresh_token = $1 AND expires_at > $2`,
+        token, s.now(),
     ).Scan(&session.ID, &session.UserID, &session.RefreshToken, 
-           &session.UserAgent, &session.IP, &session.ExpiresAt, &session.CreatedAt)
+           &session.UserAgent, &session.IP, &session.ClientType, &session.ExpiresAt, &session.CreatedAt)
     
     if err != nil {
         if err == pgx.ErrNoRows {
@@ -253,24 +667,225 @@ func (s *AuthService) GetSessionByRefreshToken(ctx context.Context, token string
     return session, nil
 }
 
-func (s *AuthService) DeleteSession(ctx context.Context, sessionID uuid.UUID) error {
-    _, err := s.db.Pool().Exec(ctx,
-        "DELETE FROM sessions WHERE id = $1",
-        sessionID,
+// GetRecentlyExpiredSession returns the session of a refresh token that
+// expired within the view-only grace period. Logged-out and revoked sessions
+// are deleted, so they never qualify.
+func (s *AuthService) GetRecentlyExpiredSession(ctx context.Context, token string) (*models.Session, error) {
+    if s.config.ViewOnlyGrace <= 0 {
+        return nil, ErrInvalidToken
+    }
+
+    now := s.now()
+    session := &models.Session{}
+    err := s.db.Pool().QueryRow(ctx,
+        `SELECT id, user_id, refresh_token, user_agent, ip, client_type, expires_at, created_at
+         FROM sessions WHERE refresh_token = $1 AND expires_at <= $2 AND expires_at > $3`,
+        token, now, now.Add(-s.config.ViewOnlyGrace),
+    ).Scan(&session.ID, &session.UserID, &session.RefreshToken,
+        &session.UserAgent, &session.IP, &session.ClientType, &session.ExpiresAt, &session.CreatedAt)
+    if err != nil {
+        if err == pgx.ErrNoRows {
+            return nil, ErrInvalidToken
+        }
+        return nil, fmt.Errorf("get session: %w", err)
+    }
+
+    return session, nil
+}
+
+// RotateRefreshToken exchanges a refresh token for a new one on the same
+// session and records the new token in the session's lineage. Presenting a
+// token that was already rotated means it has leaked: the reuse is recorded
+// and the whole session is revoked. A refresh from outside the session's
+// original network or user agent family is handled by the session policy
+// for the session's client type.
+func (s *AuthService) RotateRefreshToken(ctx context.Context, token, userAgent, ip string) (*models.Session, error) {
+    tx, err := s.db.Pool().Begin(ctx)
+    if err != nil {
+        return nil, fmt.Errorf("begin transaction: %w", err)
+    }
+    defer tx.Rollback(ctx)
+
+    session := &models.Session{}
+    err = tx.QueryRow(ctx,
+        `SELECT id, user_id, refresh_token, user_agent, ip, client_type, region, expires_at, created_at
+         FROM sessions WHERE refresh_token = $1 AND expires_at > $2
+         FOR UPDATE`,
+        token, s.now(),
+    ).Scan(&session.ID, &session.UserID, &session.RefreshToken,
+        &session.UserAgent, &session.IP, &session.ClientType, &session.Region, &session.ExpiresAt, &session.CreatedAt)
+    if err != nil {
+        if err == pgx.ErrNoRows {
+            return nil, s.detectRefreshTokenReuse(ctx, token, userAgent, ip)
+        }
+        return nil, fmt.Errorf("get session: %w", err)
+    }
+
+    anomaly, err := s.enforceSessionPolicy(ctx, tx, session, userAgent, ip)
+    if err != nil {
+        return nil, err
+    }
+
+    // Sessions created before lineage tracking have no parent row
+    var parentID *uuid.UUID
+    err = tx.QueryRow(ctx,
+        `UPDATE refresh_token_lineage SET rotated_at = NOW()
+         WHERE token_hash = $1 RETURNING id`,
+        hashToken(token),
+    ).Scan(&parentID)
+    if err != nil && err != pgx.ErrNoRows {
+        return nil, fmt.Errorf("rotate refresh token lineage: %w", err)
+    }
+
+    session.RefreshToken = generateToken()
+    if _, err := tx.Exec(ctx,
+        "UPDATE sessions SET refresh_token = $1 WHERE id = $2",
+        session.RefreshToken, session.ID,
+    ); err != nil {
+        return nil, fmt.Errorf("rotate refresh token: %w", err)
+    }
+
+    if err := recordRefreshTokenIssued(ctx, tx, session.ID, session.UserID, parentID, session.RefreshToken, userAgent, ip); err != nil {
+        return nil, err
+    }
+
+    if err := tx.Commit(ctx); err != nil {
+        return nil, fmt.Errorf("commit transaction: %w", err)
+    }
+
+    if anomaly {
+        if err := forgetSecurityScore(ctx, s.redis, session.UserID); err != nil {
+            s.logger.Errorf("Failed to invalidate security score: %v", err)
+        }
+    }
+
+    return session, nil
+}
+
+// enforceSessionPolicy checks a refresh against the session's original IP and
+// user agent. Strict sessions are refused on any mismatch; relaxed sessions
+// are let through with a warning and a user:session_anomaly event, and the
+// anomaly is recorded on the session in tx. It reports whether it recorded
+// one.
+func (s *AuthService) enforceSessionPolicy(ctx context.Context, tx pgx.Tx, session *models.Session, userAgent, ip string) (bool, error) {
+    mode := s.config.SessionPolicy.For(session.ClientType)
+    if mode == config.SessionPolicyOff {
+        return false, nil
+    }
+
+    violations := sessionBindingViolations(session, userAgent, ip)
+    if len(violations) == 0 {
+        return false, nil
+    }
+
+    s.logger.Warnw("Refresh outside session binding",
+        "session_id", session.ID,
+        "policy", mode,
+        "violations", violations,
+        "ip", ip,
+    )
+
+    if mode == config.SessionPolicyStrict {
+        return false, ErrSessionPolicyViolation
+    }
+
+    if _, err := tx.Exec(ctx, "UPDATE sessions SET anomaly_at = NOW() WHERE id = $1", session.ID); err != nil {
+        return false, fmt.Errorf("record session anomaly: %w", err)
+    }
+
+    event := events.NewUserEvent(events.UserSessionAnomaly, session.UserID.String(), "")
+    event.Data["session_id"] = session.ID.String()
+    event.Data["violations"] = violations
+    event.Data["ip"] = ip
+    event.Data["user_agent"] = userAgent
+    if err := s.rabbitMQ.PublishUserEvent(event); err != nil {
+        s.logger.Errorf("Failed to publish session anomaly event: %v", err)
+    }
+    return true, nil
+}
+
+// detectRefreshTokenReuse handles a refresh token that matches no live
+// session. If it is a rotated token from a known family, the reuse is recorded
+// and the family revoked. It returns the error to report to the caller.
+func (s *AuthService) detectRefreshTokenReuse(ctx context.Context, token, userAgent, ip string) error {
+    var familyID uuid.UUID
+    err := s.db.Pool().QueryRow(ctx,
+        `UPDATE refresh_token_lineage
+         SET reused_at = COALESCE(reused_at, NOW()),
+             reused_ip = COALESCE(reused_ip, $2),
+             reused_user_agent = COALESCE(reused_user_agent, $3)
+         WHERE token_hash = $1 AND rotated_at IS NOT NULL
+         RETURNING family_id`,
+        hashToken(token), ip, userAgent,
+    ).Scan(&familyID)
+    if err != nil {
+        if err == pgx.ErrNoRows {
+            return ErrInvalidToken
+        }
+        return fmt.Errorf("record refresh token reuse: %w", err)
+    }
+
+    s.logger.Warnw("Rotated refresh token reused, revoking session",
+        "family_id", familyID,
+        "ip", ip,
     )
-    return err
+
+    if err := s.DeleteSession(ctx, familyID, models.LogoutReasonRefreshReuse); err != nil {
+        return fmt.Errorf("revoke reused session: %w", err)
+    }
+
+    return ErrRefreshTokenReused
+}
+
+// DeleteSession revokes a session, announcing it as a forced logout for
+// reason.
+func (s *AuthService) DeleteSession(ctx context.Context, sessionID uuid.UUID, reason string) error {
+    var userID uuid.UUID
+    err := s.db.Pool().QueryRow(ctx,
+        "DELETE FROM sessions WHERE id = $1 RETURNING user_id",
+        sessionID,
+    ).Scan(&userID)
+    if err != nil {
+        if err == pgx.ErrNoRows {
+            return nil
+        }
+        return err
+    }
+
+    // The session may have been the one keeping the score down
+    if err := forgetSecurityScore(ctx, s.redis, userID); err != nil {
+        s.logger.Errorf("Failed to invalidate security score: %v", err)
+    }
+    s.forcedLogouts.Publish(ctx, userID, &sessionID, reason)
+    return nil
 }
 
-func (s *AuthService) DeleteAllUserSessions(ctx context.Context, userID uuid.UUID) error {
+// DeleteAllUserSessions revokes every session of the user, announcing it as
+// a forced logout for reason.
+func (s *AuthService) DeleteAllUserSessions(ctx context.Context, userID uuid.UUID, reason string) error {
     _, err := s.db.Pool().Exec(ctx,
         "DELETE FROM sessions WHERE user_id = $1",
         userID,
     )
-    return err
+    if err != nil {
+        return err
+    }
+
+    if err := forgetSecurityScore(ctx, s.redis, userID); err != nil {
+        s.logger.Errorf("Failed to invalidate security score: %v", err)
+    }
+    s.forcedLogouts.Publish(ctx, userID, nil, reason)
+    return nil
 }
 
 func generateToken() string {
     b := make([]byte, 32)
     rand.Read(b)
     return hex.EncodeToString(b)
+}
+
+// hashToken returns the SHA-256 of a high-entropy secret for storage at rest.
+func hashToken(token string) string {
+    sum := sha256.Sum256([]byte(token))
+    return hex.EncodeToString(sum[:])
 }
\ No newline at end of file
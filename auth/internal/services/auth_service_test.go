@@ -8,6 +8,7 @@ import (
 	"auth-service/internal/models"
 	"auth-service/test"
 
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/crypto/bcrypt"
@@ -17,7 +18,7 @@ func TestAuthService_Register(t *testing.T) {
 	suite := test.NewTestSuite(t)
 	defer suite.Cleanup(t)
 
-	authService := NewAuthService(suite.DB.DB, suite.Redis.Client, suite.Config, suite.Logger)
+	authService := NewAuthService(suite.DB.DB, suite.Redis.Client, suite.Config, suite.Logger, suite.Events)
 
 	tests := []struct {
 		name    string
@@ -61,7 +62,7 @@ func TestAuthService_Register(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			user, err := authService.Register(context.Background(), tt.req)
+			user, err := authService.Register(context.Background(), tt.req, "")
 
 			if tt.wantErr {
 				require.Error(t, err)
@@ -84,7 +85,7 @@ func TestAuthService_Login(t *testing.T) {
 	suite := test.NewTestSuite(t)
 	defer suite.Cleanup(t)
 
-	authService := NewAuthService(suite.DB.DB, suite.Redis.Client, suite.Config, suite.Logger)
+	authService := NewAuthService(suite.DB.DB, suite.Redis.Client, suite.Config, suite.Logger, suite.Events)
 
 	// Create test user
 	testUser := suite.CreateTestUser(t, test.TestData.ValidEmail, test.TestData.ValidUsername, test.TestData.ValidPassword)
@@ -133,7 +134,7 @@ func TestAuthService_Login(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			user, session, err := authService.Login(context.Background(), tt.req, tt.userAgent, tt.ip)
+			user, session, err := authService.Login(context.Background(), tt.req, tt.userAgent, tt.ip, "web")
 
 			if tt.wantErr {
 				require.Error(t, err)
@@ -159,16 +160,18 @@ func TestAuthService_VerifyEmail(t *testing.T) {
 	suite := test.NewTestSuite(t)
 	defer suite.Cleanup(t)
 
-	authService := NewAuthService(suite.DB.DB, suite.Redis.Client, suite.Config, suite.Logger)
+	authService := NewAuthService(suite.DB.DB, suite.Redis.Client, suite.Config, suite.Logger, suite.Events)
 
 	// Create unverified user with email token
 	emailToken := "test-email-token"
-	_, err := suite.DB.Pool().Exec(context.Background(),
-		`INSERT INTO users (email, username, password_hash, email_verified, email_token)
-		 VALUES ($1, $2, $3, false, $4)`,
-		"unverified@example.com", "unverified", "hashedpass", emailToken,
-	)
+	var userID uuid.UUID
+	err := suite.DB.Pool().QueryRow(context.Background(),
+		`INSERT INTO users (email, username, password_hash, email_verified)
+		 VALUES ($1, $2, $3, false) RETURNING id`,
+		"unverified@example.com", "unverified", "hashedpass",
+	).Scan(&userID)
 	require.NoError(t, err)
+	suite.CreateEmailVerificationToken(t, userID, "unverified@example.com", emailToken, time.Now().Add(time.Hour))
 
 	tests := []struct {
 		name    string
@@ -197,7 +200,7 @@ func TestAuthService_VerifyEmail(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := authService.VerifyEmail(context.Background(), tt.token)
+			_, _, err := authService.VerifyEmail(context.Background(), tt.token)
 
 			if tt.wantErr {
 				require.Error(t, err)
@@ -208,7 +211,7 @@ func TestAuthService_VerifyEmail(t *testing.T) {
 				// Verify user is now verified
 				var verified bool
 				err = suite.DB.Pool().QueryRow(context.Background(),
-					"SELECT email_verified FROM users WHERE email_token IS NULL AND email = $1",
+					"SELECT email_verified FROM users WHERE email = $1",
 					"unverified@example.com",
 				).Scan(&verified)
 				require.NoError(t, err)
@@ -218,11 +221,62 @@ func TestAuthService_VerifyEmail(t *testing.T) {
 	}
 }
 
+func TestAuthService_VerifyEmailExpiredAndReused(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	authService := NewAuthService(suite.DB.DB, suite.Redis.Client, suite.Config, suite.Logger, suite.Events)
+
+	user := suite.CreateTestUser(t, "expired@example.com", "expired", "password123")
+	_, err := suite.DB.Pool().Exec(context.Background(),
+		"UPDATE users SET email_verified = false WHERE id = $1", user.ID)
+	require.NoError(t, err)
+
+	suite.CreateEmailVerificationToken(t, user.ID, user.Email, "expired-token", time.Now().Add(-time.Minute))
+	_, _, err = authService.VerifyEmail(context.Background(), "expired-token")
+	assert.Equal(t, ErrInvalidToken, err)
+
+	suite.CreateEmailVerificationToken(t, user.ID, user.Email, "valid-token", time.Now().Add(time.Hour))
+	_, _, err = authService.VerifyEmail(context.Background(), "valid-token")
+	require.NoError(t, err)
+	_, _, err = authService.VerifyEmail(context.Background(), "valid-token")
+	assert.Equal(t, ErrInvalidToken, err)
+}
+
+func TestAuthService_ResendVerificationInvalidatesPreviousToken(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	authService := NewAuthService(suite.DB.DB, suite.Redis.Client, suite.Config, suite.Logger, suite.Events)
+
+	user := suite.CreateTestUser(t, "resend@example.com", "resend", "password123")
+	_, err := suite.DB.Pool().Exec(context.Background(),
+		"UPDATE users SET email_verified = false WHERE id = $1", user.ID)
+	require.NoError(t, err)
+
+	// Backdate the first token so the resend is outside the cooldown
+	suite.CreateEmailVerificationToken(t, user.ID, user.Email, "first-token", time.Now().Add(time.Hour))
+	_, err = suite.DB.Pool().Exec(context.Background(),
+		"UPDATE email_verification_tokens SET created_at = NOW() - INTERVAL '5 minutes' WHERE user_id = $1", user.ID)
+	require.NoError(t, err)
+
+	require.NoError(t, authService.ResendVerification(context.Background(), user.Email))
+	_, _, err = authService.VerifyEmail(context.Background(), "first-token")
+	assert.Equal(t, ErrInvalidToken, err)
+
+	var outstanding int
+	err = suite.DB.Pool().QueryRow(context.Background(),
+		"SELECT COUNT(*) FROM email_verification_tokens WHERE user_id = $1 AND used_at IS NULL", user.ID,
+	).Scan(&outstanding)
+	require.NoError(t, err)
+	assert.Equal(t, 1, outstanding)
+}
+
 func TestAuthService_ForgotPassword(t *testing.T) {
 	suite := test.NewTestSuite(t)
 	defer suite.Cleanup(t)
 
-	authService := NewAuthService(suite.DB.DB, suite.Redis.Client, suite.Config, suite.Logger)
+	authService := NewAuthService(suite.DB.DB, suite.Redis.Client, suite.Config, suite.Logger, suite.Events)
 
 	// Create test user
 	testUser := suite.CreateTestUser(t, test.TestData.ValidEmail, test.TestData.ValidUsername, test.TestData.ValidPassword)
@@ -265,7 +319,7 @@ func TestAuthService_ResetPassword(t *testing.T) {
 	suite := test.NewTestSuite(t)
 	defer suite.Cleanup(t)
 
-	authService := NewAuthService(suite.DB.DB, suite.Redis.Client, suite.Config, suite.Logger)
+	authService := NewAuthService(suite.DB.DB, suite.Redis.Client, suite.Config, suite.Logger, suite.Events)
 
 	// Create user with reset token
 	resetToken := "valid-reset-token"
@@ -333,7 +387,7 @@ func TestAuthService_GetSessionByRefreshToken(t *testing.T) {
 	suite := test.NewTestSuite(t)
 	defer suite.Cleanup(t)
 
-	authService := NewAuthService(suite.DB.DB, suite.Redis.Client, suite.Config, suite.Logger)
+	authService := NewAuthService(suite.DB.DB, suite.Redis.Client, suite.Config, suite.Logger, suite.Events)
 
 	// Create test user and session
 	testUser := suite.CreateTestUser(t, test.TestData.ValidEmail, test.TestData.ValidUsername, test.TestData.ValidPassword)
@@ -381,14 +435,14 @@ func TestAuthService_DeleteSession(t *testing.T) {
 	suite := test.NewTestSuite(t)
 	defer suite.Cleanup(t)
 
-	authService := NewAuthService(suite.DB.DB, suite.Redis.Client, suite.Config, suite.Logger)
+	authService := NewAuthService(suite.DB.DB, suite.Redis.Client, suite.Config, suite.Logger, suite.Events)
 
 	// Create test user and session
 	testUser := suite.CreateTestUser(t, test.TestData.ValidEmail, test.TestData.ValidUsername, test.TestData.ValidPassword)
 	testSession := suite.CreateTestSession(t, testUser.ID)
 
 	// Delete session
-	err := authService.DeleteSession(context.Background(), testSession.ID)
+	err := authService.DeleteSession(context.Background(), testSession.ID, models.LogoutReasonRefreshReuse)
 	require.NoError(t, err)
 
 	// Verify session was deleted
@@ -400,7 +454,7 @@ func TestAuthService_DeleteAllUserSessions(t *testing.T) {
 	suite := test.NewTestSuite(t)
 	defer suite.Cleanup(t)
 
-	authService := NewAuthService(suite.DB.DB, suite.Redis.Client, suite.Config, suite.Logger)
+	authService := NewAuthService(suite.DB.DB, suite.Redis.Client, suite.Config, suite.Logger, suite.Events)
 
 	// Create test user and multiple sessions
 	testUser := suite.CreateTestUser(t, test.TestData.ValidEmail, test.TestData.ValidUsername, test.TestData.ValidPassword)
@@ -408,7 +462,7 @@ func TestAuthService_DeleteAllUserSessions(t *testing.T) {
 	session2 := suite.CreateTestSession(t, testUser.ID)
 
 	// Delete all sessions for user
-	err := authService.DeleteAllUserSessions(context.Background(), testUser.ID)
+	err := authService.DeleteAllUserSessions(context.Background(), testUser.ID, models.LogoutReasonLogoutAll)
 	require.NoError(t, err)
 
 	// Verify all sessions were deleted
@@ -417,4 +471,231 @@ func TestAuthService_DeleteAllUserSessions(t *testing.T) {
 
 	_, err = authService.GetSessionByRefreshToken(context.Background(), session2.RefreshToken)
 	assert.Equal(t, ErrInvalidToken, err)
-}
\ No newline at end of file
+}
+func TestAuthService_RotateRefreshTokenDetectsReuse(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	authService := NewAuthService(suite.DB.DB, suite.Redis.Client, suite.Config, suite.Logger, suite.Events)
+	lineageService := NewTokenLineageService(suite.DB.DB, suite.Logger)
+	ctx := context.Background()
+
+	user := suite.CreateTestUser(t, test.TestData.ValidEmail, test.TestData.ValidUsername, test.TestData.ValidPassword)
+	_, session, err := authService.Login(ctx, &models.LoginRequest{
+		Email:    test.TestData.ValidEmail,
+		Password: test.TestData.ValidPassword,
+	}, "test-agent", "10.0.0.1", "web")
+	require.NoError(t, err)
+
+	rotated, err := authService.RotateRefreshToken(ctx, session.RefreshToken, "test-agent", "10.0.0.2")
+	require.NoError(t, err)
+	assert.Equal(t, session.ID, rotated.ID)
+	assert.NotEqual(t, session.RefreshToken, rotated.RefreshToken)
+
+	// Replaying the first token revokes the whole family
+	_, err = authService.RotateRefreshToken(ctx, session.RefreshToken, "attacker", "203.0.113.9")
+	assert.Equal(t, ErrRefreshTokenReused, err)
+
+	_, err = authService.RotateRefreshToken(ctx, rotated.RefreshToken, "test-agent", "10.0.0.2")
+	assert.Equal(t, ErrInvalidToken, err)
+
+	families, err := lineageService.ListFamilies(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, families, 1)
+	assert.False(t, families[0].Active)
+	assert.True(t, families[0].ReuseDetected)
+	assert.Equal(t, 1, families[0].Rotations)
+
+	export, err := lineageService.ExportFamily(ctx, session.ID)
+	require.NoError(t, err)
+	require.Len(t, export.Lineage, 2)
+	assert.Nil(t, export.Lineage[0].ParentID)
+	require.NotNil(t, export.Lineage[0].ReusedIP)
+	assert.Equal(t, "203.0.113.9", *export.Lineage[0].ReusedIP)
+	assert.Equal(t, export.Lineage[0].ID, *export.Lineage[1].ParentID)
+}
+
+func TestAuthService_UpdateSession(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	authService := NewAuthService(suite.DB.DB, suite.Redis.Client, suite.Config, suite.Logger, suite.Events)
+	ctx := context.Background()
+
+	user := suite.CreateTestUser(t, test.TestData.ValidEmail, test.TestData.ValidUsername, test.TestData.ValidPassword)
+	session := suite.CreateTestSession(t, user.ID)
+
+	label := "My iPhone"
+	trusted := true
+	info, err := authService.UpdateSession(ctx, user.ID, session.ID, &models.UpdateSessionRequest{Label: &label, Trusted: &trusted})
+	require.NoError(t, err)
+	require.NotNil(t, info.Label)
+	assert.Equal(t, label, *info.Label)
+	assert.True(t, info.Trusted)
+	assert.WithinDuration(t, time.Now().Add(suite.Config.TrustedRefreshExpiry), info.ExpiresAt, time.Minute)
+
+	// Untrusting caps the lifetime again and leaves the label alone
+	trusted = false
+	info, err = authService.UpdateSession(ctx, user.ID, session.ID, &models.UpdateSessionRequest{Trusted: &trusted})
+	require.NoError(t, err)
+	assert.Equal(t, label, *info.Label)
+	assert.False(t, info.Trusted)
+	assert.WithinDuration(t, time.Now().Add(suite.Config.RefreshExpiry), info.ExpiresAt, time.Minute)
+
+	// Other users' sessions can't be edited
+	_, err = authService.UpdateSession(ctx, uuid.New(), session.ID, &models.UpdateSessionRequest{Label: &label})
+	assert.Equal(t, ErrSessionNotFound, err)
+
+	sessions, err := authService.ListSessions(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, session.ID, sessions[0].ID)
+}
+
+func TestAuthService_DeleteExpiredSessions(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	suite.Config.Region = "eu-west"
+	authService := NewAuthService(suite.DB.DB, suite.Redis.Client, suite.Config, suite.Logger, suite.Events)
+	ctx := context.Background()
+
+	user := suite.CreateTestUser(t, test.TestData.ValidEmail, test.TestData.ValidUsername, test.TestData.ValidPassword)
+	expired := time.Now().Add(-suite.Config.ViewOnlyGrace - time.Hour)
+
+	tagged := map[string]*models.Session{}
+	for _, region := range []string{"eu-west", "", "us-east"} {
+		session := suite.CreateTestSession(t, user.ID)
+		_, err := suite.DB.DB.Pool().Exec(ctx,
+			"UPDATE sessions SET region = $2, expires_at = $3 WHERE id = $1",
+			session.ID, region, expired,
+		)
+		require.NoError(t, err)
+		tagged[region] = session
+	}
+	live, err := authService.createSession(ctx, user.ID, "test-agent", "127.0.0.1", "web")
+	require.NoError(t, err)
+	assert.Equal(t, "eu-west", live.Region)
+
+	deleted, err := authService.DeleteExpiredSessions(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), deleted)
+
+	// Other regions' sessions are left to their own cleanup job
+	var remaining []uuid.UUID
+	rows, err := suite.DB.DB.Pool().Query(ctx, "SELECT id FROM sessions WHERE user_id = $1", user.ID)
+	require.NoError(t, err)
+	defer rows.Close()
+	for rows.Next() {
+		var id uuid.UUID
+		require.NoError(t, rows.Scan(&id))
+		remaining = append(remaining, id)
+	}
+	assert.ElementsMatch(t, []uuid.UUID{tagged["us-east"].ID, live.ID}, remaining)
+}
+
+func TestAuthService_LoginChallenges(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	suite.Config.TOSVersion = "2026-01"
+	authService := NewAuthService(suite.DB.DB, suite.Redis.Client, suite.Config, suite.Logger, suite.Events)
+	ctx := context.Background()
+
+	user := suite.CreateTestUser(t, test.TestData.ValidEmail, test.TestData.ValidUsername, test.TestData.ValidPassword)
+
+	enrollment, err := authService.StartTOTPEnrollment(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Contains(t, enrollment.URI, "otpauth://totp/")
+
+	step := time.Now().Unix() / totpPeriod
+	code, err := totpCode(enrollment.Secret, step)
+	require.NoError(t, err)
+	require.NoError(t, authService.ConfirmTOTPEnrollment(ctx, user.ID, code))
+
+	_, err = authService.StartTOTPEnrollment(ctx, user.ID)
+	assert.Equal(t, ErrTOTPAlreadyEnabled, err)
+
+	_, _, err = authService.Login(ctx, &models.LoginRequest{
+		Email:    test.TestData.ValidEmail,
+		Password: test.TestData.ValidPassword,
+	}, "test-agent", "10.0.0.1", "web")
+	var required *ChallengeRequiredError
+	require.ErrorAs(t, err, &required)
+	assert.False(t, required.CredentialsInvalid)
+	assert.Equal(t, []string{models.ChallengeTOTP, models.ChallengeTOS}, required.Challenge.Challenges)
+	token := required.Challenge.Token
+
+	// Challenges are answered in order
+	_, _, current, err := authService.AnswerChallenge(ctx, token, models.ChallengeTOS, "2026-01", "test-agent", "10.0.0.1", "web")
+	assert.Equal(t, ErrChallengeOutOfOrder, err)
+	assert.Equal(t, required.Challenge.Challenges, current.Challenges)
+
+	// The code used to enroll can't be replayed
+	_, _, _, err = authService.AnswerChallenge(ctx, token, models.ChallengeTOTP, code, "test-agent", "10.0.0.1", "web")
+	assert.ErrorIs(t, err, ErrChallengeFailed)
+
+	next, err := totpCode(enrollment.Secret, step+1)
+	require.NoError(t, err)
+	_, _, current, err = authService.AnswerChallenge(ctx, token, models.ChallengeTOTP, next, "test-agent", "10.0.0.1", "web")
+	require.NoError(t, err)
+	assert.Equal(t, []string{models.ChallengeTOS}, current.Challenges)
+
+	_, _, _, err = authService.AnswerChallenge(ctx, token, models.ChallengeTOS, "2025-01", "test-agent", "10.0.0.1", "web")
+	assert.ErrorIs(t, err, ErrChallengeFailed)
+
+	loggedIn, session, current, err := authService.AnswerChallenge(ctx, token, models.ChallengeTOS, "2026-01", "test-agent", "10.0.0.1", "web")
+	require.NoError(t, err)
+	assert.Nil(t, current)
+	assert.Equal(t, user.ID, loggedIn.ID)
+	assert.NotEmpty(t, session.RefreshToken)
+
+	// The challenge is used up
+	_, _, _, err = authService.AnswerChallenge(ctx, token, models.ChallengeTOS, "2026-01", "test-agent", "10.0.0.1", "web")
+	assert.Equal(t, ErrInvalidToken, err)
+}
+
+func TestAuthService_RegistrationEmailCode(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	suite.Config.RegistrationEmailCode = true
+	authService := NewAuthService(suite.DB.DB, suite.Redis.Client, suite.Config, suite.Logger, suite.Events)
+	ctx := context.Background()
+
+	req := &models.RegisterRequest{Email: "early@example.com", Username: "earlybird", Password: "password123"}
+	_, err := authService.Register(ctx, req, "")
+	assert.Equal(t, ErrRegistrationCodeRequired, err)
+
+	require.NoError(t, authService.StartRegistration(ctx, req.Email))
+	assert.Equal(t, ErrRegistrationCodeThrottled, authService.StartRegistration(ctx, req.Email))
+
+	// The code itself is only emailed, so replace it with a known one
+	require.NoError(t, suite.Redis.Set(ctx, "registration_code:early@example.com", hashToken("123456"), time.Minute))
+
+	req.EmailCode = "654321"
+	_, err = authService.Register(ctx, req, "")
+	assert.Equal(t, ErrInvalidRegistrationCode, err)
+
+	req.EmailCode = "123456"
+	user, err := authService.Register(ctx, req, "")
+	require.NoError(t, err)
+	assert.True(t, user.EmailVerified)
+	assert.NotNil(t, user.EmailVerifiedAt)
+
+	// The code is used up
+	exists, err := suite.Redis.Exists(ctx, "registration_code:early@example.com")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	// Too many wrong codes throw the code away
+	require.NoError(t, suite.Redis.Set(ctx, "registration_code:late@example.com", hashToken("123456"), time.Minute))
+	late := &models.RegisterRequest{Email: "late@example.com", Username: "latebird", Password: "password123", EmailCode: "000000"}
+	for i := 0; i < maxRegistrationCodeFailures; i++ {
+		_, err = authService.Register(ctx, late, "")
+		assert.Equal(t, ErrInvalidRegistrationCode, err)
+	}
+	late.EmailCode = "123456"
+	_, err = authService.Register(ctx, late, "")
+	assert.Equal(t, ErrInvalidRegistrationCode, err)
+}
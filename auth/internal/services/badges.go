@@ -0,0 +1,164 @@
+package services
+
+import (
+    "context"
+    "fmt"
+
+    "auth-service/internal/apperr"
+    "auth-service/internal/events"
+    "auth-service/internal/models"
+
+    "github.com/google/uuid"
+    "github.com/jackc/pgx/v5"
+)
+
+var ErrBadgeNotGrantable = apperr.New(apperr.Invalid, "Badge is computed and can't be granted or revoked", nil)
+
+// loadBadges returns the user's badges as of q, in models.Badges order.
+func loadBadges(ctx context.Context, q queryExecer, userID uuid.UUID) ([]string, error) {
+    var emailVerified, mfaEnabled bool
+    var granted []string
+    err := q.QueryRow(ctx,
+        `SELECT email_verified, totp_enabled_at IS NOT NULL,
+                ARRAY(SELECT badge FROM user_badges WHERE user_id = $1)
+         FROM users WHERE id = $1`,
+        userID,
+    ).Scan(&emailVerified, &mfaEnabled, &granted)
+    if err != nil {
+        if err == pgx.ErrNoRows {
+            return nil, ErrUserNotFound
+        }
+        return nil, fmt.Errorf("get badges: %w", err)
+    }
+
+    has := map[string]bool{
+        models.BadgeEmailVerified: emailVerified,
+        models.BadgeMFAEnabled:    mfaEnabled,
+    }
+    for _, badge := range granted {
+        has[badge] = true
+    }
+
+    badges := []string{}
+    for _, badge := range models.Badges {
+        if has[badge] {
+            badges = append(badges, badge)
+        }
+    }
+    return badges, nil
+}
+
+// enqueueBadgesChanged queues a user:badges_changed event for badge being
+// gained or lost, with the user's badges as of tx. Call it in the
+// transaction of the change, after making it.
+func enqueueBadgesChanged(ctx context.Context, tx queryExecer, userID uuid.UUID, username, badge string, granted bool) error {
+    badges, err := loadBadges(ctx, tx, userID)
+    if err != nil {
+        return err
+    }
+
+    event := events.NewUserEvent(events.UserBadgesChanged, userID.String(), username)
+    event.Data["badge"] = badge
+    event.Data["granted"] = granted
+    event.Data["badges"] = badges
+    return enqueueEvent(ctx, tx, event)
+}
+
+// Badges returns the user's badges.
+func (s *UserService) Badges(ctx context.Context, userID uuid.UUID) ([]string, error) {
+    return loadBadges(ctx, s.db.Pool(), userID)
+}
+
+// UserBadges returns the user's badges along with the grants among them.
+func (s *UserService) UserBadges(ctx context.Context, userID uuid.UUID) (*models.UserBadges, error) {
+    badges, err := s.Badges(ctx, userID)
+    if err != nil {
+        return nil, err
+    }
+
+    rows, err := s.db.Pool().Query(ctx,
+        "SELECT badge, granted_by, granted_at FROM user_badges WHERE user_id = $1 ORDER BY granted_at",
+        userID,
+    )
+    if err != nil {
+        return nil, fmt.Errorf("list badge grants: %w", err)
+    }
+    defer rows.Close()
+
+    result := &models.UserBadges{UserID: userID, Badges: badges, Grants: []*models.BadgeGrant{}}
+    for rows.Next() {
+        grant := &models.BadgeGrant{}
+        if err := rows.Scan(&grant.Badge, &grant.GrantedBy, &grant.GrantedAt); err != nil {
+            return nil, fmt.Errorf("scan badge grant: %w", err)
+        }
+        result.Grants = append(result.Grants, grant)
+    }
+    return result, rows.Err()
+}
+
+// GrantBadge grants one of the admin-granted badges and queues a
+// user:badges_changed event in the same transaction. It reports false if
+// the user already had the badge.
+func (s *UserService) GrantBadge(ctx context.Context, userID uuid.UUID, badge string, grantedBy uuid.UUID) (bool, error) {
+    return s.changeBadge(ctx, userID, badge, true, func(tx pgx.Tx) (int64, error) {
+        tag, err := tx.Exec(ctx,
+            `INSERT INTO user_badges (user_id, badge, granted_by) VALUES ($1, $2, $3)
+             ON CONFLICT (user_id, badge) DO NOTHING`,
+            userID, badge, grantedBy,
+        )
+        if err != nil {
+            return 0, fmt.Errorf("grant badge: %w", err)
+        }
+        return tag.RowsAffected(), nil
+    })
+}
+
+// RevokeBadge takes away an admin-granted badge and queues a
+// user:badges_changed event in the same transaction. It reports false if
+// the user didn't have the badge.
+func (s *UserService) RevokeBadge(ctx context.Context, userID uuid.UUID, badge string) (bool, error) {
+    return s.changeBadge(ctx, userID, badge, false, func(tx pgx.Tx) (int64, error) {
+        tag, err := tx.Exec(ctx, "DELETE FROM user_badges WHERE user_id = $1 AND badge = $2", userID, badge)
+        if err != nil {
+            return 0, fmt.Errorf("revoke badge: %w", err)
+        }
+        return tag.RowsAffected(), nil
+    })
+}
+
+func (s *UserService) changeBadge(ctx context.Context, userID uuid.UUID, badge string, granted bool, change func(tx pgx.Tx) (int64, error)) (bool, error) {
+    if !models.IsGrantableBadge(badge) {
+        return false, ErrBadgeNotGrantable
+    }
+
+    tx, err := s.db.Pool().Begin(ctx)
+    if err != nil {
+        return false, fmt.Errorf("begin transaction: %w", err)
+    }
+    defer tx.Rollback(ctx)
+
+    var username string
+    err = tx.QueryRow(ctx, "SELECT username FROM users WHERE id = $1 FOR UPDATE", userID).Scan(&username)
+    if err != nil {
+        if err == pgx.ErrNoRows {
+            return false, ErrUserNotFound
+        }
+        return false, fmt.Errorf("get user: %w", err)
+    }
+
+    changed, err := change(tx)
+    if err != nil {
+        return false, err
+    }
+    if changed == 0 {
+        return false, nil
+    }
+
+    if err := enqueueBadgesChanged(ctx, tx, userID, username, badge, granted); err != nil {
+        return false, err
+    }
+    if err := tx.Commit(ctx); err != nil {
+        return false, fmt.Errorf("commit transaction: %w", err)
+    }
+    return true, nil
+}
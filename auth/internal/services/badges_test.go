@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"auth-service/internal/events"
+	"auth-service/internal/models"
+	"auth-service/test"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserService_Badges(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	ctx := context.Background()
+	userService := NewUserService(suite.DB.DB, suite.Redis.Client, suite.Logger)
+	relay := NewBufferedPublisher(suite.Events, suite.DB.DB, 10, suite.Logger)
+	admin := suite.CreateTestUser(t, "admin@example.com", "admin", test.TestData.ValidPassword)
+	user := suite.CreateTestUser(t, test.TestData.ValidEmail, test.TestData.ValidUsername, test.TestData.ValidPassword)
+
+	_, err := suite.DB.Pool().Exec(ctx, "UPDATE users SET email_verified = true WHERE id = $1", user.ID)
+	require.NoError(t, err)
+
+	badges, err := userService.Badges(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{models.BadgeEmailVerified}, badges)
+
+	changed, err := userService.GrantBadge(ctx, user.ID, models.BadgeVenueVerified, admin.ID)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	changed, err = userService.GrantBadge(ctx, user.ID, models.BadgeVenueVerified, admin.ID)
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	userBadges, err := userService.UserBadges(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{models.BadgeEmailVerified, models.BadgeVenueVerified}, userBadges.Badges)
+	require.Len(t, userBadges.Grants, 1)
+	assert.Equal(t, admin.ID, *userBadges.Grants[0].GrantedBy)
+
+	// Computed badges follow the account
+	_, err = userService.GrantBadge(ctx, user.ID, models.BadgeMFAEnabled, admin.ID)
+	assert.ErrorIs(t, err, ErrBadgeNotGrantable)
+	_, err = userService.GrantBadge(ctx, uuid.New(), models.BadgePhoneVerified, admin.ID)
+	assert.ErrorIs(t, err, ErrUserNotFound)
+
+	changed, err = userService.RevokeBadge(ctx, user.ID, models.BadgeVenueVerified)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	changed, err = userService.RevokeBadge(ctx, user.ID, models.BadgeVenueVerified)
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	_, err = relay.RelayOutbox(ctx, 10)
+	require.NoError(t, err)
+
+	var changes []*events.UserEvent
+	for _, event := range suite.Events.Events {
+		if event.Type == events.UserBadgesChanged {
+			changes = append(changes, event)
+		}
+	}
+	require.Len(t, changes, 2)
+	assert.Equal(t, models.BadgeVenueVerified, changes[0].Data["badge"])
+	assert.Equal(t, true, changes[0].Data["granted"])
+	assert.Equal(t, false, changes[1].Data["granted"])
+}
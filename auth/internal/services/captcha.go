@@ -0,0 +1,66 @@
+package services
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "net/url"
+    "strings"
+    "time"
+)
+
+const captchaVerifyTimeout = 5 * time.Second
+
+// CaptchaVerifier checks a captcha token solved by the client.
+type CaptchaVerifier interface {
+    Verify(ctx context.Context, token, ip string) (bool, error)
+}
+
+// siteVerifyCaptcha verifies tokens against a siteverify endpoint as offered
+// by reCAPTCHA, hCaptcha and Turnstile.
+type siteVerifyCaptcha struct {
+    url    string
+    secret string
+    client *http.Client
+}
+
+// NewCaptchaVerifier returns a verifier for the siteverify endpoint at
+// verifyURL, or nil if captchas aren't configured.
+func NewCaptchaVerifier(verifyURL, secret string) CaptchaVerifier {
+    if verifyURL == "" || secret == "" {
+        return nil
+    }
+    return &siteVerifyCaptcha{
+        url:    verifyURL,
+        secret: secret,
+        client: &http.Client{Timeout: captchaVerifyTimeout},
+    }
+}
+
+func (v *siteVerifyCaptcha) Verify(ctx context.Context, token, ip string) (bool, error) {
+    form := url.Values{"secret": {v.secret}, "response": {token}, "remoteip": {ip}}
+    req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.url, strings.NewReader(form.Encode()))
+    if err != nil {
+        return false, fmt.Errorf("build captcha request: %w", err)
+    }
+    req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+    resp, err := v.client.Do(req)
+    if err != nil {
+        return false, fmt.Errorf("verify captcha: %w", err)
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode != http.StatusOK {
+        return false, fmt.Errorf("verify captcha: status %d", resp.StatusCode)
+    }
+
+    var result struct {
+        Success bool `json:"success"`
+    }
+    if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+        return false, fmt.Errorf("decode captcha response: %w", err)
+    }
+    return result.Success, nil
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"auth-service/internal/events"
+	"auth-service/internal/models"
+	"auth-service/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangeEvents_PublishedInOrderThroughOutbox(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	ctx := context.Background()
+	userService := NewUserService(suite.DB.DB, suite.Redis.Client, suite.Logger)
+	recoveryService := NewRecoveryService(suite.DB.DB, suite.Redis.Client, suite.Config, suite.Logger)
+	relay := NewBufferedPublisher(suite.Events, suite.DB.DB, 10, suite.Logger)
+
+	user := suite.CreateTestUser(t, "old@example.com", "oldname", test.TestData.ValidPassword)
+	suite.CreateTestUser(t, "taken@example.com", "taken", test.TestData.ValidPassword)
+
+	require.NoError(t, userService.UpdateProfile(ctx, user.ID, "middlename"))
+	// Setting the same username again is not a change
+	require.NoError(t, userService.UpdateProfile(ctx, user.ID, "middlename"))
+	// A failed change queues nothing
+	assert.Error(t, userService.UpdateProfile(ctx, user.ID, "taken"))
+
+	codes, err := recoveryService.GenerateRecoveryCodes(ctx, user.ID)
+	require.NoError(t, err)
+	token, err := recoveryService.StartRecovery(ctx, &models.StartRecoveryRequest{
+		Identifier:   "middlename",
+		Method:       models.RecoveryMethodCode,
+		RecoveryCode: codes[0],
+	})
+	require.NoError(t, err)
+	require.NoError(t, recoveryService.CompleteRecovery(ctx, &models.CompleteRecoveryRequest{
+		Token:    token,
+		Email:    "new@example.com",
+		Password: "newpassword123",
+	}))
+
+	require.NoError(t, userService.UpdateProfile(ctx, user.ID, "newname"))
+
+	// Nothing is published until the relay runs
+	assert.Empty(t, suite.Events.Events)
+
+	published, err := relay.RelayOutbox(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 3, published)
+	require.Len(t, suite.Events.Events, 3)
+
+	first, second, third := suite.Events.Events[0], suite.Events.Events[1], suite.Events.Events[2]
+	assert.Equal(t, events.UserUsernameChanged, first.Type)
+	assert.Equal(t, "oldname", first.Data["old_username"])
+	assert.Equal(t, "middlename", first.Data["new_username"])
+
+	assert.Equal(t, events.UserEmailChanged, second.Type)
+	assert.Equal(t, "old@example.com", second.Data["old_email"])
+	assert.Equal(t, "new@example.com", second.Data["new_email"])
+	assert.Equal(t, "middlename", second.Username)
+
+	assert.Equal(t, events.UserUsernameChanged, third.Type)
+	assert.Equal(t, "middlename", third.Data["old_username"])
+	assert.Equal(t, "newname", third.Data["new_username"])
+
+	// Sequence numbers are shared by both kinds of change and grow by one
+	// per change
+	for i, event := range suite.Events.Events {
+		assert.EqualValues(t, i+1, event.Data["seq"])
+	}
+
+	// The changes are journaled for replay as well
+	var journaled int
+	require.NoError(t, suite.DB.Pool().QueryRow(ctx,
+		"SELECT COUNT(*) FROM event_journal WHERE user_id = $1", user.ID.String(),
+	).Scan(&journaled))
+	assert.Equal(t, 3, journaled)
+}
@@ -0,0 +1,232 @@
+package services
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "net/url"
+    "strings"
+    "time"
+
+    "auth-service/internal/config"
+    "auth-service/internal/models"
+)
+
+const (
+    moderationTimeout = 5 * time.Second
+    perspectiveURL    = "https://commentanalyzer.googleapis.com/v1alpha1/comments:analyze"
+)
+
+// Perspective attributes scored for every check; the highest score decides
+var perspectiveAttributes = []string{"TOXICITY", "SEVERE_TOXICITY", "IDENTITY_ATTACK", "INSULT", "PROFANITY", "THREAT"}
+
+// ContentModerator screens user-chosen text such as usernames and display
+// names. An error means the content couldn't be checked; it is accepted and
+// checked again later.
+type ContentModerator interface {
+    Name() string
+    Moderate(ctx context.Context, field, text string) (*models.ModerationResult, error)
+}
+
+// NewContentModerator builds the configured moderator, or returns nil if
+// moderation is off.
+func NewContentModerator(cfg config.ModerationConfig) ContentModerator {
+    client := &http.Client{Timeout: moderationTimeout}
+
+    switch cfg.Provider {
+    case config.ModerationProviderWordlist:
+        return NewWordlistModerator(cfg.DenyWords, cfg.FlagWords)
+    case config.ModerationProviderPerspective:
+        endpoint := cfg.URL
+        if endpoint == "" {
+            endpoint = perspectiveURL
+        }
+        return &perspectiveModerator{
+            url:           endpoint,
+            apiKey:        cfg.APIKey,
+            flagThreshold: cfg.FlagThreshold,
+            denyThreshold: cfg.DenyThreshold,
+            client:        client,
+        }
+    case config.ModerationProviderHTTP:
+        return &httpModerator{url: cfg.URL, token: cfg.APIKey, client: client}
+    }
+    return nil
+}
+
+// wordlistModerator matches text against local word lists after folding
+// case, common digit-for-letter swaps and separators, so "B.a_d" and "b4d"
+// both match "bad".
+type wordlistModerator struct {
+    deny []string
+    flag []string
+}
+
+var moderationFolding = strings.NewReplacer(
+    "0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "@", "a", "$", "s",
+    "-", "", "_", "", ".", "", " ", "",
+)
+
+// NewWordlistModerator returns a moderator for the given words. Without any
+// words it flags anything on the generated-handle blocklist; substring
+// matches are too fuzzy to deny on.
+func NewWordlistModerator(deny, flag []string) ContentModerator {
+    if len(deny) == 0 && len(flag) == 0 {
+        flag = handleBlocklist
+    }
+    return &wordlistModerator{deny: foldWords(deny), flag: foldWords(flag)}
+}
+
+func foldWords(words []string) []string {
+    folded := make([]string, 0, len(words))
+    for _, word := range words {
+        if word = moderationFolding.Replace(strings.ToLower(word)); word != "" {
+            folded = append(folded, word)
+        }
+    }
+    return folded
+}
+
+func (m *wordlistModerator) Name() string {
+    return config.ModerationProviderWordlist
+}
+
+func (m *wordlistModerator) Moderate(ctx context.Context, field, text string) (*models.ModerationResult, error) {
+    folded := moderationFolding.Replace(strings.ToLower(text))
+    for _, word := range m.deny {
+        if strings.Contains(folded, word) {
+            return &models.ModerationResult{Outcome: models.ModerationDeny, Reason: "blocked word"}, nil
+        }
+    }
+    for _, word := range m.flag {
+        if strings.Contains(folded, word) {
+            return &models.ModerationResult{Outcome: models.ModerationFlag, Reason: "flagged word"}, nil
+        }
+    }
+    return &models.ModerationResult{Outcome: models.ModerationAllow}, nil
+}
+
+// perspectiveModerator scores text with Google's Perspective API.
+type perspectiveModerator struct {
+    url           string
+    apiKey        string
+    flagThreshold float64
+    denyThreshold float64
+    client        *http.Client
+}
+
+func (m *perspectiveModerator) Name() string {
+    return config.ModerationProviderPerspective
+}
+
+func (m *perspectiveModerator) Moderate(ctx context.Context, field, text string) (*models.ModerationResult, error) {
+    attributes := map[string]struct{}{}
+    for _, attribute := range perspectiveAttributes {
+        attributes[attribute] = struct{}{}
+    }
+    body, err := json.Marshal(map[string]interface{}{
+        "comment":             map[string]string{"text": text},
+        "requestedAttributes": attributes,
+        "doNotStore":          true,
+    })
+    if err != nil {
+        return nil, fmt.Errorf("encode perspective request: %w", err)
+    }
+
+    endpoint := m.url + "?key=" + url.QueryEscape(m.apiKey)
+    req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+    if err != nil {
+        return nil, fmt.Errorf("build perspective request: %w", err)
+    }
+    req.Header.Set("Content-Type", "application/json")
+
+    resp, err := m.client.Do(req)
+    if err != nil {
+        // The URL carries the API key, so the error is not wrapped as is
+        return nil, fmt.Errorf("call perspective: request failed")
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode != http.StatusOK {
+        return nil, fmt.Errorf("call perspective: status %d", resp.StatusCode)
+    }
+
+    var result struct {
+        AttributeScores map[string]struct {
+            SummaryScore struct {
+                Value float64 `json:"value"`
+            } `json:"summaryScore"`
+        } `json:"attributeScores"`
+    }
+    if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+        return nil, fmt.Errorf("decode perspective response: %w", err)
+    }
+
+    var top string
+    var score float64
+    for attribute, s := range result.AttributeScores {
+        if s.SummaryScore.Value > score {
+            top, score = attribute, s.SummaryScore.Value
+        }
+    }
+
+    reason := fmt.Sprintf("%s %.2f", strings.ToLower(top), score)
+    switch {
+    case score >= m.denyThreshold:
+        return &models.ModerationResult{Outcome: models.ModerationDeny, Reason: reason}, nil
+    case score >= m.flagThreshold:
+        return &models.ModerationResult{Outcome: models.ModerationFlag, Reason: reason}, nil
+    }
+    return &models.ModerationResult{Outcome: models.ModerationAllow}, nil
+}
+
+// httpModerator delegates to a custom service. It POSTs {"field", "text"}
+// and expects {"outcome": "allow"|"flag"|"deny", "reason"} back.
+type httpModerator struct {
+    url    string
+    token  string
+    client *http.Client
+}
+
+func (m *httpModerator) Name() string {
+    return config.ModerationProviderHTTP
+}
+
+func (m *httpModerator) Moderate(ctx context.Context, field, text string) (*models.ModerationResult, error) {
+    body, err := json.Marshal(map[string]string{"field": field, "text": text})
+    if err != nil {
+        return nil, fmt.Errorf("encode moderation request: %w", err)
+    }
+
+    req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.url, bytes.NewReader(body))
+    if err != nil {
+        return nil, fmt.Errorf("build moderation request: %w", err)
+    }
+    req.Header.Set("Content-Type", "application/json")
+    if m.token != "" {
+        req.Header.Set("Authorization", "Bearer "+m.token)
+    }
+
+    resp, err := m.client.Do(req)
+    if err != nil {
+        return nil, fmt.Errorf("call moderation service: %w", err)
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode != http.StatusOK {
+        return nil, fmt.Errorf("call moderation service: status %d", resp.StatusCode)
+    }
+
+    var result models.ModerationResult
+    if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+        return nil, fmt.Errorf("decode moderation response: %w", err)
+    }
+    switch result.Outcome {
+    case models.ModerationAllow, models.ModerationFlag, models.ModerationDeny:
+    default:
+        return nil, fmt.Errorf("moderation service returned unknown outcome %q", result.Outcome)
+    }
+    return &result, nil
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"auth-service/internal/config"
+	"auth-service/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWordlistModerator(t *testing.T) {
+	moderator := NewWordlistModerator([]string{"badword"}, []string{"meh"})
+	ctx := context.Background()
+
+	tests := map[string]string{
+		"nice_name":   models.ModerationAllow,
+		"B4d.W0rd_99": models.ModerationDeny,
+		"so-MEH":      models.ModerationFlag,
+	}
+	for text, want := range tests {
+		result, err := moderator.Moderate(ctx, models.ModerationFieldUsername, text)
+		require.NoError(t, err)
+		assert.Equal(t, want, result.Outcome, text)
+	}
+
+	// Without words, the handle blocklist flags
+	result, err := NewWordlistModerator(nil, nil).Moderate(ctx, models.ModerationFieldDisplayName, "Pi$$ed")
+	require.NoError(t, err)
+	assert.Equal(t, models.ModerationFlag, result.Outcome)
+}
+
+func TestHTTPModerator(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		var req map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, models.ModerationFieldUsername, req["field"])
+
+		switch req["text"] {
+		case "denied":
+			json.NewEncoder(w).Encode(models.ModerationResult{Outcome: models.ModerationDeny, Reason: "spam"})
+		case "weird":
+			json.NewEncoder(w).Encode(map[string]string{"outcome": "maybe"})
+		default:
+			json.NewEncoder(w).Encode(models.ModerationResult{Outcome: models.ModerationAllow})
+		}
+	}))
+	defer server.Close()
+
+	moderator := NewContentModerator(config.ModerationConfig{Provider: config.ModerationProviderHTTP, URL: server.URL, APIKey: "token"})
+	ctx := context.Background()
+
+	result, err := moderator.Moderate(ctx, models.ModerationFieldUsername, "denied")
+	require.NoError(t, err)
+	assert.Equal(t, models.ModerationDeny, result.Outcome)
+	assert.Equal(t, "spam", result.Reason)
+
+	result, err = moderator.Moderate(ctx, models.ModerationFieldUsername, "fine")
+	require.NoError(t, err)
+	assert.Equal(t, models.ModerationAllow, result.Outcome)
+
+	_, err = moderator.Moderate(ctx, models.ModerationFieldUsername, "weird")
+	assert.Error(t, err)
+}
+
+func TestPerspectiveModerator(t *testing.T) {
+	scores := map[string]float64{"toxic": 0.95, "rude": 0.75, "kind": 0.1}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "key", r.URL.Query().Get("key"))
+		var req struct {
+			Comment struct {
+				Text string `json:"text"`
+			} `json:"comment"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"attributeScores": map[string]interface{}{
+				"TOXICITY": map[string]interface{}{"summaryScore": map[string]float64{"value": scores[req.Comment.Text]}},
+				"INSULT":   map[string]interface{}{"summaryScore": map[string]float64{"value": 0.05}},
+			},
+		})
+	}))
+	defer server.Close()
+
+	moderator := NewContentModerator(config.ModerationConfig{
+		Provider:      config.ModerationProviderPerspective,
+		URL:           server.URL,
+		APIKey:        "key",
+		FlagThreshold: 0.7,
+		DenyThreshold: 0.9,
+	})
+	ctx := context.Background()
+
+	for text, want := range map[string]string{"toxic": models.ModerationDeny, "rude": models.ModerationFlag, "kind": models.ModerationAllow} {
+		result, err := moderator.Moderate(ctx, models.ModerationFieldDisplayName, text)
+		require.NoError(t, err)
+		assert.Equal(t, want, result.Outcome, text)
+	}
+}
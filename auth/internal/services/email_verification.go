@@ -0,0 +1,63 @@
+package services
+
+import (
+    "context"
+    "fmt"
+    "time"
+
+    "auth-service/internal/models"
+
+    "github.com/google/uuid"
+    "github.com/jackc/pgx/v5/pgconn"
+)
+
+// Minimum time between two verification emails for the same account.
+const emailVerificationResendCooldown = time.Minute
+
+// EmailNeedsReverification reports whether a verified account must prove it
+// still owns its email before sensitive operations: after a bounce was
+// reported since the last verification, or, when months is positive, once the
+// last verification is that many months old. Unverified accounts and guests
+// never need re-verification.
+func EmailNeedsReverification(user *models.User, months int, now time.Time) bool {
+    if !user.EmailVerified || user.IsGuest {
+        return false
+    }
+    if user.EmailBouncedAt != nil && (user.EmailVerifiedAt == nil || user.EmailBouncedAt.After(*user.EmailVerifiedAt)) {
+        return true
+    }
+    if months <= 0 || user.EmailVerifiedAt == nil {
+        return false
+    }
+    return !now.Before(user.EmailVerifiedAt.AddDate(0, months, 0))
+}
+
+// execer is satisfied by both the connection pool and transactions.
+type execer interface {
+    Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
+// issueEmailVerificationToken invalidates any outstanding verification tokens
+// for the user and stores a new one bound to email, valid until expiresAt.
+// Only the token's hash is persisted; the plaintext is returned for delivery.
+func issueEmailVerificationToken(ctx context.Context, db execer, userID uuid.UUID, email string, expiresAt time.Time) (string, error) {
+    _, err := db.Exec(ctx,
+        "DELETE FROM email_verification_tokens WHERE user_id = $1 AND used_at IS NULL",
+        userID,
+    )
+    if err != nil {
+        return "", fmt.Errorf("invalidate verification tokens: %w", err)
+    }
+
+    token := generateToken()
+    _, err = db.Exec(ctx,
+        `INSERT INTO email_verification_tokens (user_id, email, token_hash, expires_at)
+         VALUES ($1, $2, $3, $4)`,
+        userID, email, hashToken(token), expiresAt,
+    )
+    if err != nil {
+        return "", fmt.Errorf("create verification token: %w", err)
+    }
+
+    return token, nil
+}
@@ -0,0 +1,36 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"auth-service/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmailNeedsReverification(t *testing.T) {
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	at := func(t time.Time) *time.Time { return &t }
+
+	tests := []struct {
+		name   string
+		user   models.User
+		months int
+		want   bool
+	}{
+		{"unverified", models.User{}, 6, false},
+		{"guest", models.User{EmailVerified: true, IsGuest: true, EmailVerifiedAt: at(now.AddDate(-2, 0, 0))}, 6, false},
+		{"recently verified", models.User{EmailVerified: true, EmailVerifiedAt: at(now.AddDate(0, -5, 0))}, 6, false},
+		{"verification due", models.User{EmailVerified: true, EmailVerifiedAt: at(now.AddDate(0, -6, 0))}, 6, true},
+		{"cadence disabled", models.User{EmailVerified: true, EmailVerifiedAt: at(now.AddDate(-2, 0, 0))}, 0, false},
+		{"bounced since verification", models.User{EmailVerified: true, EmailVerifiedAt: at(now.AddDate(0, -1, 0)), EmailBouncedAt: at(now.AddDate(0, 0, -1))}, 0, true},
+		{"re-verified after bounce", models.User{EmailVerified: true, EmailVerifiedAt: at(now.AddDate(0, 0, -1)), EmailBouncedAt: at(now.AddDate(0, -1, 0))}, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, EmailNeedsReverification(&tt.user, tt.months, now))
+		})
+	}
+}
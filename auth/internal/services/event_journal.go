@@ -0,0 +1,148 @@
+package services
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "time"
+
+    "auth-service/internal/database"
+    "auth-service/internal/events"
+    "auth-service/internal/models"
+
+    "go.uber.org/zap"
+)
+
+const (
+    defaultJournalPageSize = 100
+    // Events newer than this aren't served yet. IDs are handed out before
+    // inserts commit, so a later ID can become visible before an earlier one;
+    // holding back recent events keeps a consumer's cursor from skipping past
+    // one that is still committing.
+    journalSettleDelay = 2 * time.Second
+)
+
+// EventJournal records every published user event so downstream services
+// can backfill by paging through it. It wraps the publisher that sends events
+// on, and journals each one before passing it along.
+type EventJournal struct {
+    next   EventPublisher
+    db     *database.DB
+    logger *zap.SugaredLogger
+}
+
+func NewEventJournal(next EventPublisher, db *database.DB, logger *zap.SugaredLogger) *EventJournal {
+    return &EventJournal{
+        next:   next,
+        db:     db,
+        logger: logger,
+    }
+}
+
+// PublishUserEvent journals the event and publishes it. A failure to journal
+// is logged and doesn't stop the event from being published.
+func (j *EventJournal) PublishUserEvent(event *events.UserEvent) error {
+    if err := j.record(event); err != nil {
+        j.logger.Errorf("Failed to journal %s event: %v", event.Type, err)
+    }
+    return j.next.PublishUserEvent(event)
+}
+
+func (j *EventJournal) record(event *events.UserEvent) error {
+    raw, err := json.Marshal(event)
+    if err != nil {
+        return fmt.Errorf("marshal event: %w", err)
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), eventOutboxWriteTimeout)
+    defer cancel()
+
+    _, err = j.db.Pool().Exec(ctx,
+        "INSERT INTO event_journal (type, user_id, event) VALUES ($1, $2, $3)",
+        string(event.Type), event.UserID, raw,
+    )
+    if err != nil {
+        return fmt.Errorf("write event journal: %w", err)
+    }
+    return nil
+}
+
+// List returns a page of journaled events after the given ID, oldest first.
+func (j *EventJournal) List(ctx context.Context, query models.JournalQuery) (*models.JournalPage, error) {
+    limit := query.Limit
+    if limit <= 0 {
+        limit = defaultJournalPageSize
+    }
+
+    // One extra row tells whether there is another page
+    rows, err := j.db.Pool().Query(ctx,
+        `SELECT id, event FROM event_journal
+         WHERE id > $1 AND created_at <= NOW() - $2 * INTERVAL '1 second'
+           AND (COALESCE(cardinality($3::text[]), 0) = 0 OR type = ANY($3))
+         ORDER BY id
+         LIMIT $4`,
+        query.After, journalSettleDelay.Seconds(), query.Types, limit+1,
+    )
+    if err != nil {
+        return nil, fmt.Errorf("list event journal: %w", err)
+    }
+    defer rows.Close()
+
+    page := &models.JournalPage{Events: []*models.JournalEvent{}, NextAfter: query.After}
+    for rows.Next() {
+        var id int64
+        var raw []byte
+        if err := rows.Scan(&id, &raw); err != nil {
+            return nil, fmt.Errorf("scan journal event: %w", err)
+        }
+        if len(page.Events) == limit {
+            page.HasMore = true
+            break
+        }
+
+        var event events.UserEvent
+        if err := json.Unmarshal(raw, &event); err != nil {
+            return nil, fmt.Errorf("decode journal event %d: %w", id, err)
+        }
+        page.Events = append(page.Events, &models.JournalEvent{
+            ID:        id,
+            Type:      string(event.Type),
+            UserID:    event.UserID,
+            Username:  event.Username,
+            Timestamp: event.Timestamp,
+            Data:      event.Data,
+        })
+        page.NextAfter = id
+    }
+    return page, rows.Err()
+}
+
+// DeleteOlderThan removes events journaled before the cutoff.
+func (j *EventJournal) DeleteOlderThan(ctx context.Context, cutoff time.Time) (int64, error) {
+    result, err := j.db.Pool().Exec(ctx, "DELETE FROM event_journal WHERE created_at < $1", cutoff)
+    if err != nil {
+        return 0, fmt.Errorf("delete old journal events: %w", err)
+    }
+    return result.RowsAffected(), nil
+}
+
+// RunCleanup deletes events older than retention every interval until ctx is
+// cancelled.
+func (j *EventJournal) RunCleanup(ctx context.Context, interval, retention time.Duration) {
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+            deleted, err := j.DeleteOlderThan(ctx, time.Now().Add(-retention))
+            if err != nil {
+                j.logger.Errorf("Failed to clean up event journal: %v", err)
+            } else if deleted > 0 {
+                j.logger.Infof("Deleted %d journaled events", deleted)
+            }
+        }
+    }
+}
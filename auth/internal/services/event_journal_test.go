@@ -0,0 +1,54 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"auth-service/internal/events"
+	"auth-service/internal/models"
+	"auth-service/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventJournal_Replay(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	journal := NewEventJournal(suite.Events, suite.DB.DB, suite.Logger)
+	ctx := context.Background()
+
+	for _, eventType := range []events.EventType{events.UserRegister, events.UserLogin, events.UserLogout} {
+		require.NoError(t, journal.PublishUserEvent(events.NewUserEvent(eventType, "user-1", "alice")))
+	}
+	assert.Len(t, suite.Events.Events, 3)
+
+	// Fresh events are held back until they have settled
+	page, err := journal.List(ctx, models.JournalQuery{})
+	require.NoError(t, err)
+	assert.Empty(t, page.Events)
+	assert.Zero(t, page.NextAfter)
+
+	_, err = suite.DB.DB.Pool().Exec(ctx, "UPDATE event_journal SET created_at = created_at - INTERVAL '1 minute'")
+	require.NoError(t, err)
+
+	page, err = journal.List(ctx, models.JournalQuery{Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, page.Events, 2)
+	assert.True(t, page.HasMore)
+	assert.Equal(t, "user:register", page.Events[0].Type)
+	assert.Equal(t, "alice", page.Events[0].Username)
+	assert.Equal(t, page.Events[1].ID, page.NextAfter)
+
+	page, err = journal.List(ctx, models.JournalQuery{After: page.NextAfter, Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, page.Events, 1)
+	assert.False(t, page.HasMore)
+	assert.Equal(t, "user:logout", page.Events[0].Type)
+
+	page, err = journal.List(ctx, models.JournalQuery{Types: []string{"user:login"}})
+	require.NoError(t, err)
+	require.Len(t, page.Events, 1)
+	assert.Equal(t, "user:login", page.Events[0].Type)
+}
@@ -0,0 +1,306 @@
+package services
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "sync"
+    "time"
+
+    "auth-service/internal/database"
+    "auth-service/internal/events"
+    "auth-service/internal/metrics"
+
+    "go.uber.org/zap"
+)
+
+const (
+    // Longest a request waits to write an event to the outbox
+    eventOutboxWriteTimeout = 2 * time.Second
+    // Publishes slower than this count as pressure on the broker
+    slowPublishThreshold = 500 * time.Millisecond
+    // This many overflows, failures or slow publishes within the window open
+    // the circuit for the cooldown
+    circuitPressureThreshold = 20
+    circuitWindow            = 30 * time.Second
+    circuitCooldown          = 30 * time.Second
+    // How long relayed outbox rows are reserved for the instance relaying them
+    eventOutboxLease = time.Minute
+    // Longest delay between retries of an outbox row
+    eventOutboxMaxBackoff = 10 * time.Minute
+)
+
+// NoopPublisher discards events, for running without a broker. They are
+// still written to the journal by EventJournal.
+type NoopPublisher struct{}
+
+func (NoopPublisher) PublishUserEvent(*events.UserEvent) error {
+    return nil
+}
+
+// BufferedPublisher keeps a slow RabbitMQ from blocking request handlers.
+// Events go into a bounded in-process buffer that Run publishes from; when
+// the buffer is full they are written to the Postgres outbox instead. Under
+// sustained pressure (overflows, failed or slow publishes) the circuit opens
+// and every event goes straight to the outbox until the cooldown ends.
+// RunRelay publishes outbox rows once the circuit is closed, so events can
+// arrive out of order while the broker is struggling.
+type BufferedPublisher struct {
+    next   EventPublisher
+    db     *database.DB
+    buffer chan *events.UserEvent
+    logger *zap.SugaredLogger
+    now    func() time.Time
+
+    mu          sync.Mutex
+    pressure    int
+    windowStart time.Time
+    openUntil   time.Time
+}
+
+func NewBufferedPublisher(next EventPublisher, db *database.DB, size int, logger *zap.SugaredLogger) *BufferedPublisher {
+    return &BufferedPublisher{
+        next:   next,
+        db:     db,
+        buffer: make(chan *events.UserEvent, size),
+        logger: logger,
+        now:    time.Now,
+    }
+}
+
+// PublishUserEvent queues the event without waiting for the broker. It only
+// fails if the event could be neither buffered nor written to the outbox.
+func (p *BufferedPublisher) PublishUserEvent(event *events.UserEvent) error {
+    if p.circuitOpen() {
+        return p.toOutbox(event, "circuit_open")
+    }
+
+    select {
+    case p.buffer <- event:
+        metrics.EventBufferDepth.Set(float64(len(p.buffer)))
+        return nil
+    default:
+        p.recordPressure()
+        return p.toOutbox(event, "buffer_full")
+    }
+}
+
+// Run publishes buffered events until ctx is cancelled, then moves whatever
+// is left in the buffer to the outbox.
+func (p *BufferedPublisher) Run(ctx context.Context) {
+    for {
+        select {
+        case <-ctx.Done():
+            p.drain()
+            return
+        case event := <-p.buffer:
+            metrics.EventBufferDepth.Set(float64(len(p.buffer)))
+            p.publish(event)
+        }
+    }
+}
+
+func (p *BufferedPublisher) publish(event *events.UserEvent) {
+    start := p.now()
+    err := p.next.PublishUserEvent(event)
+    if err != nil || p.now().Sub(start) > slowPublishThreshold {
+        p.recordPressure()
+    }
+    if err != nil {
+        p.logger.Errorf("Failed to publish %s event: %v", event.Type, err)
+        if err := p.toOutbox(event, "publish_failed"); err != nil {
+            p.logger.Errorf("Dropped %s event: %v", event.Type, err)
+        }
+    }
+}
+
+func (p *BufferedPublisher) drain() {
+    for {
+        select {
+        case event := <-p.buffer:
+            if err := p.toOutbox(event, "shutdown"); err != nil {
+                p.logger.Errorf("Dropped %s event: %v", event.Type, err)
+            }
+        default:
+            metrics.EventBufferDepth.Set(0)
+            return
+        }
+    }
+}
+
+func (p *BufferedPublisher) toOutbox(event *events.UserEvent, reason string) error {
+    raw, err := json.Marshal(event)
+    if err != nil {
+        return fmt.Errorf("marshal event: %w", err)
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), eventOutboxWriteTimeout)
+    defer cancel()
+
+    if _, err := p.db.Pool().Exec(ctx, "INSERT INTO event_outbox (event) VALUES ($1)", raw); err != nil {
+        return fmt.Errorf("write event outbox: %w", err)
+    }
+    metrics.EventOutboxWrites.WithLabelValues(reason).Inc()
+    return nil
+}
+
+// recordPressure counts a sign that the broker isn't keeping up and opens
+// the circuit once there are enough of them within the window.
+func (p *BufferedPublisher) recordPressure() {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+
+    now := p.now()
+    if now.Sub(p.windowStart) > circuitWindow {
+        p.windowStart = now
+        p.pressure = 0
+    }
+    p.pressure++
+
+    if p.pressure >= circuitPressureThreshold && !now.Before(p.openUntil) {
+        p.openUntil = now.Add(circuitCooldown)
+        p.pressure = 0
+        metrics.EventPublisherCircuitOpen.Set(1)
+        p.logger.Warnf("Event publishing switched to outbox-only for %s", circuitCooldown)
+    }
+}
+
+func (p *BufferedPublisher) circuitOpen() bool {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+
+    if p.openUntil.IsZero() {
+        return false
+    }
+    if p.now().Before(p.openUntil) {
+        return true
+    }
+
+    p.openUntil = time.Time{}
+    metrics.EventPublisherCircuitOpen.Set(0)
+    p.logger.Info("Event publishing resumed")
+    return false
+}
+
+// RunRelay publishes up to batchSize outbox events every interval while the
+// circuit is closed, until ctx is cancelled.
+func (p *BufferedPublisher) RunRelay(ctx context.Context, interval time.Duration, batchSize int) {
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+            if p.circuitOpen() {
+                continue
+            }
+            if _, err := p.RelayOutbox(ctx, batchSize); err != nil {
+                p.logger.Errorf("Failed to relay event outbox: %v", err)
+            }
+        }
+    }
+}
+
+type outboxEvent struct {
+    id       int64
+    raw      []byte
+    attempts int
+}
+
+// RelayOutbox claims up to limit due outbox events, oldest first, and
+// publishes them. It returns how many were published. The batch stops at
+// the first failure and the rest are retried later. Claims are leased, so
+// several instances can relay side by side.
+func (p *BufferedPublisher) RelayOutbox(ctx context.Context, limit int) (int, error) {
+    rows, err := p.db.Pool().Query(ctx,
+        `UPDATE event_outbox SET next_attempt_at = $2
+         WHERE id IN (
+             SELECT id FROM event_outbox
+             WHERE next_attempt_at <= NOW()
+             ORDER BY id
+             LIMIT $1
+             FOR UPDATE SKIP LOCKED
+         )
+         RETURNING id, event, attempts`,
+        limit, p.now().Add(eventOutboxLease),
+    )
+    if err != nil {
+        return 0, fmt.Errorf("claim outbox events: %w", err)
+    }
+
+    var claimed []*outboxEvent
+    for rows.Next() {
+        e := &outboxEvent{}
+        if err := rows.Scan(&e.id, &e.raw, &e.attempts); err != nil {
+            rows.Close()
+            return 0, fmt.Errorf("scan outbox event: %w", err)
+        }
+        claimed = append(claimed, e)
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil {
+        return 0, fmt.Errorf("claim outbox events: %w", err)
+    }
+
+    published := 0
+    for i, e := range claimed {
+        var event events.UserEvent
+        if err := json.Unmarshal(e.raw, &event); err != nil {
+            p.logger.Errorf("Dropping malformed outbox event %d: %v", e.id, err)
+        } else if err := p.next.PublishUserEvent(&event); err != nil {
+            p.recordPressure()
+            p.release(ctx, claimed[i:])
+            return published, fmt.Errorf("publish outbox event: %w", err)
+        }
+
+        if _, err := p.db.Pool().Exec(ctx, "DELETE FROM event_outbox WHERE id = $1", e.id); err != nil {
+            return published, fmt.Errorf("delete outbox event: %w", err)
+        }
+        published++
+    }
+    return published, nil
+}
+
+// release schedules unpublished claimed events for a retry with a growing
+// delay.
+func (p *BufferedPublisher) release(ctx context.Context, claimed []*outboxEvent) {
+    for _, e := range claimed {
+        attempts := e.attempts + 1
+        delay := time.Duration(attempts*attempts) * time.Second
+        if delay > eventOutboxMaxBackoff {
+            delay = eventOutboxMaxBackoff
+        }
+        _, err := p.db.Pool().Exec(ctx,
+            "UPDATE event_outbox SET attempts = $2, next_attempt_at = $3 WHERE id = $1",
+            e.id, attempts, p.now().Add(delay),
+        )
+        if err != nil {
+            p.logger.Errorf("Failed to reschedule outbox event %d: %v", e.id, err)
+        }
+    }
+}
+
+// enqueueEvent writes an event to the outbox and the journal as part of the
+// caller's transaction, so it is published if and only if the change it
+// describes commits. The relay publishes it in outbox order, which for one
+// user is the order the changes committed in.
+func enqueueEvent(ctx context.Context, tx execer, event *events.UserEvent) error {
+    raw, err := json.Marshal(event)
+    if err != nil {
+        return fmt.Errorf("marshal event: %w", err)
+    }
+
+    if _, err := tx.Exec(ctx, "INSERT INTO event_outbox (event) VALUES ($1)", raw); err != nil {
+        return fmt.Errorf("write event outbox: %w", err)
+    }
+    _, err = tx.Exec(ctx,
+        "INSERT INTO event_journal (type, user_id, event) VALUES ($1, $2, $3)",
+        string(event.Type), event.UserID, raw,
+    )
+    if err != nil {
+        return fmt.Errorf("write event journal: %w", err)
+    }
+    return nil
+}
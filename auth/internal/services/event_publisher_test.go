@@ -0,0 +1,105 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"auth-service/internal/events"
+	"auth-service/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyPublisher fails while down is set and records what it published.
+type flakyPublisher struct {
+	mu        sync.Mutex
+	down      bool
+	published []*events.UserEvent
+}
+
+func (p *flakyPublisher) PublishUserEvent(event *events.UserEvent) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.down {
+		return errors.New("broker unavailable")
+	}
+	p.published = append(p.published, event)
+	return nil
+}
+
+func countOutbox(t *testing.T, suite *test.TestSuite) int {
+	var count int
+	require.NoError(t, suite.DB.DB.Pool().QueryRow(context.Background(), "SELECT COUNT(*) FROM event_outbox").Scan(&count))
+	return count
+}
+
+func TestBufferedPublisher_OverflowAndCircuit(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	broker := &flakyPublisher{}
+	publisher := NewBufferedPublisher(broker, suite.DB.DB, 2, suite.Logger)
+	now := time.Now()
+	publisher.now = func() time.Time { return now }
+
+	// Nothing drains the buffer, so everything past its size overflows
+	for i := 0; i < 5; i++ {
+		require.NoError(t, publisher.PublishUserEvent(events.NewUserEvent(events.UserLogin, "user", "user")))
+	}
+	assert.Equal(t, 3, countOutbox(t, suite))
+	assert.False(t, publisher.circuitOpen())
+
+	// Sustained overflow opens the circuit
+	for i := 0; i < circuitPressureThreshold; i++ {
+		require.NoError(t, publisher.PublishUserEvent(events.NewUserEvent(events.UserLogin, "user", "user")))
+	}
+	assert.True(t, publisher.circuitOpen())
+
+	// Once open, events skip the buffer even when it has room
+	<-publisher.buffer
+	require.NoError(t, publisher.PublishUserEvent(events.NewUserEvent(events.UserLogin, "user", "user")))
+	assert.Len(t, publisher.buffer, 1)
+
+	now = now.Add(circuitCooldown + time.Second)
+	assert.False(t, publisher.circuitOpen())
+
+	// Shutting down moves buffered events to the outbox
+	publisher.drain()
+	assert.Empty(t, publisher.buffer)
+	assert.Equal(t, 5+circuitPressureThreshold, countOutbox(t, suite))
+}
+
+func TestBufferedPublisher_RelayOutbox(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	broker := &flakyPublisher{down: true}
+	publisher := NewBufferedPublisher(broker, suite.DB.DB, 10, suite.Logger)
+	ctx := context.Background()
+
+	for _, eventType := range []events.EventType{events.UserRegister, events.UserLogin} {
+		require.NoError(t, publisher.toOutbox(events.NewUserEvent(eventType, "user", "user"), "publish_failed"))
+	}
+
+	// Failures leave the events for a later retry
+	published, err := publisher.RelayOutbox(ctx, 10)
+	require.Error(t, err)
+	assert.Zero(t, published)
+	assert.Equal(t, 2, countOutbox(t, suite))
+
+	broker.down = false
+	_, err = suite.DB.DB.Pool().Exec(ctx, "UPDATE event_outbox SET next_attempt_at = NOW()")
+	require.NoError(t, err)
+
+	published, err = publisher.RelayOutbox(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 2, published)
+	assert.Zero(t, countOutbox(t, suite))
+	require.Len(t, broker.published, 2)
+	assert.Equal(t, events.UserRegister, broker.published[0].Type)
+	assert.Equal(t, events.UserLogin, broker.published[1].Type)
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "time"
+
+    "auth-service/internal/models"
+    "auth-service/internal/redis"
+
+    "github.com/google/uuid"
+    "go.uber.org/zap"
+)
+
+// Forced logouts of a user are published on forcedLogoutChannelPrefix
+// followed by the user's ID
+const forcedLogoutChannelPrefix = "auth:logout:"
+
+// ForcedLogoutChannel is the Redis channel the forced logouts of userID are
+// published on. Subscribe to "auth:logout:*" for every user's.
+func ForcedLogoutChannel(userID uuid.UUID) string {
+    return forcedLogoutChannelPrefix + userID.String()
+}
+
+// ForcedLogoutService tells the gateway and chat service, over Redis pub/sub,
+// when sessions are revoked, so clients still holding a valid access token
+// are disconnected within seconds instead of when it runs out. Like funnel
+// tracking, failures are logged rather than returned: the revocation itself
+// has already happened. A nil service publishes nothing.
+type ForcedLogoutService struct {
+    redis  *redis.Client
+    logger *zap.SugaredLogger
+}
+
+func NewForcedLogoutService(redis *redis.Client, logger *zap.SugaredLogger) *ForcedLogoutService {
+    return &ForcedLogoutService{
+        redis:  redis,
+        logger: logger,
+    }
+}
+
+// Publish announces that sessionID of userID was revoked, or every session
+// of userID when sessionID is nil.
+func (s *ForcedLogoutService) Publish(ctx context.Context, userID uuid.UUID, sessionID *uuid.UUID, reason string) {
+    if s == nil {
+        return
+    }
+
+    payload, err := json.Marshal(&models.ForcedLogout{
+        UserID:    userID,
+        SessionID: sessionID,
+        Reason:    reason,
+        At:        time.Now().UTC(),
+    })
+    if err != nil {
+        s.logger.Errorf("Failed to encode forced logout: %v", err)
+        return
+    }
+    if err := s.redis.Publish(ctx, ForcedLogoutChannel(userID), payload); err != nil {
+        s.logger.Errorf("Failed to publish forced logout for user %s: %v", userID, err)
+    }
+}
+
+// Subscribe delivers the forced logouts of userIDs, or of every user when
+// there are none, until ctx is done.
+func (s *ForcedLogoutService) Subscribe(ctx context.Context, userIDs []uuid.UUID) (<-chan *models.ForcedLogout, error) {
+    patterns := []string{forcedLogoutChannelPrefix + "*"}
+    if len(userIDs) > 0 {
+        patterns = make([]string, len(userIDs))
+        for i, userID := range userIDs {
+            patterns[i] = ForcedLogoutChannel(userID)
+        }
+    }
+
+    messages, err := s.redis.PSubscribe(ctx, patterns...)
+    if err != nil {
+        return nil, fmt.Errorf("subscribe to forced logouts: %w", err)
+    }
+
+    out := make(chan *models.ForcedLogout)
+    go func() {
+        defer close(out)
+        for msg := range messages {
+            logout := &models.ForcedLogout{}
+            if err := json.Unmarshal([]byte(msg.Payload), logout); err != nil {
+                s.logger.Warnf("Ignoring malformed forced logout on %s: %v", msg.Channel, err)
+                continue
+            }
+            select {
+            case out <- logout:
+            case <-ctx.Done():
+                return
+            }
+        }
+    }()
+    return out, nil
+}
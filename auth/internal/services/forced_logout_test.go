@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"auth-service/internal/models"
+	"auth-service/test"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForcedLogoutService_PublishesRevokedSessions(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	forcedLogouts := NewForcedLogoutService(suite.Redis.Client, suite.Logger)
+	authService := NewAuthService(suite.DB.DB, suite.Redis.Client, suite.Config, suite.Logger, suite.Events)
+	authService.SetForcedLogouts(forcedLogouts)
+
+	user := suite.CreateTestUser(t, test.TestData.ValidEmail, test.TestData.ValidUsername, test.TestData.ValidPassword)
+	session := suite.CreateTestSession(t, user.ID)
+
+	logouts, err := forcedLogouts.Subscribe(ctx, []uuid.UUID{user.ID})
+	require.NoError(t, err)
+
+	// Other users' logouts aren't delivered
+	forcedLogouts.Publish(ctx, uuid.New(), nil, models.LogoutReasonLogoutAll)
+	require.NoError(t, authService.DeleteSession(ctx, session.ID, models.LogoutReasonRefreshReuse))
+
+	select {
+	case logout := <-logouts:
+		assert.Equal(t, user.ID, logout.UserID)
+		require.NotNil(t, logout.SessionID)
+		assert.Equal(t, session.ID, *logout.SessionID)
+		assert.Equal(t, models.LogoutReasonRefreshReuse, logout.Reason)
+	case <-ctx.Done():
+		t.Fatal("forced logout was not delivered")
+	}
+
+	require.NoError(t, authService.DeleteAllUserSessions(ctx, user.ID, models.LogoutReasonLogoutAll))
+
+	select {
+	case logout := <-logouts:
+		assert.Nil(t, logout.SessionID)
+		assert.Equal(t, models.LogoutReasonLogoutAll, logout.Reason)
+	case <-ctx.Done():
+		t.Fatal("forced logout was not delivered")
+	}
+}
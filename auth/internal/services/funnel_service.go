@@ -0,0 +1,159 @@
+package services
+
+import (
+    "context"
+    "fmt"
+    "time"
+
+    "auth-service/internal/database"
+    "auth-service/internal/metrics"
+    "auth-service/internal/models"
+
+    "github.com/google/uuid"
+    "go.uber.org/zap"
+)
+
+// FunnelService tracks new accounts through the login funnel. Each step is
+// counted in Prometheus for live dashboards and stored as an event so a daily
+// job can roll it up into funnel_daily_stats for the admin dashboard.
+type FunnelService struct {
+    db     *database.DB
+    logger *zap.SugaredLogger
+    // AggregateDay; replaced in tests to make aggregation fail
+    aggregate func(ctx context.Context, day time.Time) error
+}
+
+func NewFunnelService(db *database.DB, logger *zap.SugaredLogger) *FunnelService {
+    s := &FunnelService{
+        db:     db,
+        logger: logger,
+    }
+    s.aggregate = s.AggregateDay
+    return s
+}
+
+// Record notes that a funnel step was reached. userID is nil for steps that
+// happen before an account exists. Failures are logged, never returned, so
+// analytics can't break the flow being measured. A nil service records
+// nothing.
+func (s *FunnelService) Record(ctx context.Context, step, clientType string, userID *uuid.UUID) {
+    if s == nil {
+        return
+    }
+
+    metrics.FunnelSteps.WithLabelValues(step, clientType).Inc()
+
+    var delay *float64
+    err := s.db.Pool().QueryRow(ctx,
+        `INSERT INTO funnel_events (user_id, step, client_type) VALUES ($1, $2, $3)
+         RETURNING (SELECT EXTRACT(EPOCH FROM NOW() - created_at)::float8 FROM users WHERE id = $1)`,
+        userID, step, clientType,
+    ).Scan(&delay)
+    if err != nil {
+        s.logger.Errorf("Failed to record funnel step %s: %v", step, err)
+        return
+    }
+
+    if delay != nil {
+        metrics.FunnelStepDelay.WithLabelValues(step, clientType).Observe(*delay)
+    }
+}
+
+// AggregateDay recomputes the stats rows for the UTC day containing day. It is
+// idempotent, so re-running it for a partial day just refreshes the counts.
+func (s *FunnelService) AggregateDay(ctx context.Context, day time.Time) error {
+    start := day.UTC().Truncate(24 * time.Hour)
+
+    _, err := s.db.Pool().Exec(ctx,
+        `INSERT INTO funnel_daily_stats (day, step, client_type, count)
+         SELECT $1::date, step, client_type, COUNT(*)
+         FROM funnel_events
+         WHERE created_at >= $1 AND created_at < $2
+         GROUP BY step, client_type
+         ON CONFLICT (day, step, client_type)
+         DO UPDATE SET count = EXCLUDED.count, updated_at = NOW()`,
+        start, start.Add(24*time.Hour),
+    )
+    if err != nil {
+        return fmt.Errorf("aggregate funnel stats: %w", err)
+    }
+
+    return nil
+}
+
+// PruneEvents deletes the events recorded before the given time, returning
+// how many were removed.
+func (s *FunnelService) PruneEvents(ctx context.Context, before time.Time) (int64, error) {
+    tag, err := s.db.Pool().Exec(ctx, "DELETE FROM funnel_events WHERE created_at < $1", before.UTC())
+    if err != nil {
+        return 0, fmt.Errorf("prune funnel events: %w", err)
+    }
+    return tag.RowsAffected(), nil
+}
+
+// RunAggregation refreshes yesterday's and today's stats every interval until
+// ctx is cancelled. Yesterday is included so late events are picked up once
+// the day has closed.
+func (s *FunnelService) RunAggregation(ctx context.Context, interval time.Duration) {
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+
+    for {
+        s.aggregateRecent(ctx, time.Now())
+
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+        }
+    }
+}
+
+// aggregateRecent refreshes yesterday's and today's stats as of now. Events
+// from before yesterday are no longer aggregated, so they are deleted, but
+// only after both days aggregated cleanly; while the database is failing
+// they are kept for a later run.
+func (s *FunnelService) aggregateRecent(ctx context.Context, now time.Time) {
+    yesterday := now.Add(-24 * time.Hour)
+    failed := false
+    for _, day := range []time.Time{yesterday, now} {
+        if err := s.aggregate(ctx, day); err != nil {
+            s.logger.Errorf("Failed to aggregate funnel stats for %s: %v", day.Format("2006-01-02"), err)
+            failed = true
+        }
+    }
+    if failed {
+        return
+    }
+
+    if pruned, err := s.PruneEvents(ctx, yesterday.UTC().Truncate(24*time.Hour)); err != nil {
+        s.logger.Errorf("Failed to prune funnel events: %v", err)
+    } else if pruned > 0 {
+        s.logger.Infof("Deleted %d aggregated funnel events", pruned)
+    }
+}
+
+// DailyStats returns the aggregated rows for the given inclusive UTC date range.
+func (s *FunnelService) DailyStats(ctx context.Context, from, to time.Time) ([]*models.FunnelDailyStat, error) {
+    rows, err := s.db.Pool().Query(ctx,
+        `SELECT day, step, client_type, count FROM funnel_daily_stats
+         WHERE day BETWEEN $1::date AND $2::date
+         ORDER BY day, step, client_type`,
+        from.UTC(), to.UTC(),
+    )
+    if err != nil {
+        return nil, fmt.Errorf("list funnel stats: %w", err)
+    }
+    defer rows.Close()
+
+    stats := []*models.FunnelDailyStat{}
+    for rows.Next() {
+        st := &models.FunnelDailyStat{}
+        if err := rows.Scan(&st.Day, &st.Step, &st.ClientType, &st.Count); err != nil {
+            return nil, fmt.Errorf("scan funnel stat: %w", err)
+        }
+        stats = append(stats, st)
+    }
+
+    return stats, rows.Err()
+}
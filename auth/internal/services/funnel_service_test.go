@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"auth-service/internal/metrics"
+	"auth-service/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFunnelService_AggregateDay(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	funnelService := NewFunnelService(suite.DB.DB, suite.Logger)
+	ctx := context.Background()
+
+	user := suite.CreateTestUser(t, test.TestData.ValidEmail, test.TestData.ValidUsername, test.TestData.ValidPassword)
+
+	funnelService.Record(ctx, metrics.StepRegisterStarted, "web", nil)
+	funnelService.Record(ctx, metrics.StepRegisterStarted, "web", nil)
+	funnelService.Record(ctx, metrics.StepRegisterStarted, "ios", nil)
+	funnelService.Record(ctx, metrics.StepFirstLogin, "web", &user.ID)
+
+	today := time.Now().UTC()
+	require.NoError(t, funnelService.AggregateDay(ctx, today))
+
+	// Re-running is idempotent and picks up new events
+	funnelService.Record(ctx, metrics.StepFirstLogin, "web", &user.ID)
+	require.NoError(t, funnelService.AggregateDay(ctx, today))
+
+	stats, err := funnelService.DailyStats(ctx, today, today)
+	require.NoError(t, err)
+
+	counts := map[string]int{}
+	for _, st := range stats {
+		counts[st.Step+"/"+st.ClientType] = st.Count
+	}
+	assert.Equal(t, map[string]int{
+		"first_login/web":      2,
+		"register_started/ios": 1,
+		"register_started/web": 2,
+	}, counts)
+}
+
+func TestFunnelService_PrunesAggregatedEvents(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	funnelService := NewFunnelService(suite.DB.DB, suite.Logger)
+	ctx := context.Background()
+	pool := suite.DB.Pool()
+
+	now := time.Now().UTC()
+	cutoff := now.Add(-24 * time.Hour).Truncate(24 * time.Hour)
+	for _, at := range []time.Time{cutoff.Add(-time.Second), cutoff, now} {
+		_, err := pool.Exec(ctx,
+			"INSERT INTO funnel_events (step, client_type, created_at) VALUES ($1, 'web', $2)",
+			metrics.StepRegisterStarted, at,
+		)
+		require.NoError(t, err)
+	}
+
+	createdAt := func() []time.Time {
+		rows, err := pool.Query(ctx, "SELECT created_at FROM funnel_events ORDER BY created_at")
+		require.NoError(t, err)
+		defer rows.Close()
+		var times []time.Time
+		for rows.Next() {
+			var at time.Time
+			require.NoError(t, rows.Scan(&at))
+			times = append(times, at)
+		}
+		require.NoError(t, rows.Err())
+		return times
+	}
+
+	// Nothing is deleted while aggregation fails
+	funnelService.aggregate = func(ctx context.Context, day time.Time) error {
+		if day.Equal(now) {
+			return errors.New("connection reset")
+		}
+		return funnelService.AggregateDay(ctx, day)
+	}
+	funnelService.aggregateRecent(ctx, now)
+	assert.Len(t, createdAt(), 3)
+
+	// Once both days aggregate, events from before yesterday go
+	funnelService.aggregate = funnelService.AggregateDay
+	funnelService.aggregateRecent(ctx, now)
+	remaining := createdAt()
+	require.Len(t, remaining, 2)
+	assert.True(t, remaining[0].Equal(cutoff))
+}
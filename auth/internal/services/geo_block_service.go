@@ -0,0 +1,246 @@
+package services
+
+import (
+    "bufio"
+    "bytes"
+    "context"
+    "crypto/sha256"
+    "encoding/hex"
+    "fmt"
+    "io"
+    "net"
+    "os"
+    "sort"
+    "strings"
+    "sync"
+
+    "auth-service/internal/database"
+
+    "github.com/google/uuid"
+    "github.com/jackc/pgx/v5"
+    "go.uber.org/zap"
+)
+
+// GeoBlockService blocks registration and login from configured countries.
+// The country comes from a GeoIP database when one is loaded, otherwise from
+// a header set by a trusted proxy or CDN. Individual accounts can be exempted
+// from the login block by an admin.
+type GeoBlockService struct {
+    db      *database.DB
+    blocked map[string]bool
+    header  string
+    logger  *zap.SugaredLogger
+
+    // Swapped by ReloadGeoIP
+    geoIPMu sync.RWMutex
+    geoIP   *GeoIPDatabase
+}
+
+// NewGeoBlockService blocks the given ISO 3166-1 alpha-2 country codes.
+// header names the request header carrying the country code from a trusted
+// proxy; geoIP may be nil to rely on the header alone.
+func NewGeoBlockService(db *database.DB, countries []string, header string, geoIP *GeoIPDatabase, logger *zap.SugaredLogger) *GeoBlockService {
+    blocked := make(map[string]bool, len(countries))
+    for _, country := range countries {
+        if country = strings.ToUpper(strings.TrimSpace(country)); country != "" {
+            blocked[country] = true
+        }
+    }
+
+    return &GeoBlockService{
+        db:      db,
+        blocked: blocked,
+        header:  header,
+        geoIP:   geoIP,
+        logger:  logger,
+    }
+}
+
+// Enabled reports whether any country is blocked.
+func (s *GeoBlockService) Enabled() bool {
+    return len(s.blocked) > 0
+}
+
+// Header returns the name of the trusted country header, "" if none.
+func (s *GeoBlockService) Header() string {
+    return s.header
+}
+
+// Country resolves a client's country code, preferring the GeoIP database
+// over the proxy-supplied header value. It returns "" if unknown.
+func (s *GeoBlockService) Country(ip, header string) string {
+    s.geoIPMu.RLock()
+    geoIP := s.geoIP
+    s.geoIPMu.RUnlock()
+
+    if geoIP != nil {
+        return geoIP.lookup(ip)
+    }
+    if s.header == "" {
+        return ""
+    }
+    return strings.ToUpper(strings.TrimSpace(header))
+}
+
+// ReloadGeoIP loads the GeoIP database at path and uses it for lookups from
+// then on. A database that fails to load leaves the current one in place.
+// ReloadGeoIP reports whether the database changed.
+func (s *GeoBlockService) ReloadGeoIP(path string) (bool, error) {
+    geoIP, err := LoadGeoIPDatabase(path)
+    if err != nil {
+        return false, err
+    }
+
+    s.geoIPMu.Lock()
+    defer s.geoIPMu.Unlock()
+
+    if s.geoIP != nil && s.geoIP.fingerprint == geoIP.fingerprint {
+        return false, nil
+    }
+    s.geoIP = geoIP
+    return true, nil
+}
+
+// GeoIPFingerprint identifies the loaded GeoIP database, "" if none.
+func (s *GeoBlockService) GeoIPFingerprint() string {
+    s.geoIPMu.RLock()
+    defer s.geoIPMu.RUnlock()
+
+    if s.geoIP == nil {
+        return ""
+    }
+    return s.geoIP.fingerprint
+}
+
+// IsBlocked reports whether a country is on the block list. Unknown
+// countries are not blocked.
+func (s *GeoBlockService) IsBlocked(country string) bool {
+    return country != "" && s.blocked[country]
+}
+
+// IsExempt reports whether the account with this email may log in from a
+// blocked country. Unknown emails are not exempt.
+func (s *GeoBlockService) IsExempt(ctx context.Context, email string) (bool, error) {
+    var exempt bool
+    err := s.db.Pool().QueryRow(ctx,
+        "SELECT geo_block_exempt FROM users WHERE email = $1",
+        email,
+    ).Scan(&exempt)
+    if err != nil {
+        if err == pgx.ErrNoRows {
+            return false, nil
+        }
+        return false, fmt.Errorf("get geo block exemption: %w", err)
+    }
+    return exempt, nil
+}
+
+// GetExempt returns the exemption flag of a user.
+func (s *GeoBlockService) GetExempt(ctx context.Context, userID uuid.UUID) (bool, error) {
+    var exempt bool
+    err := s.db.Pool().QueryRow(ctx,
+        "SELECT geo_block_exempt FROM users WHERE id = $1",
+        userID,
+    ).Scan(&exempt)
+    if err != nil {
+        if err == pgx.ErrNoRows {
+            return false, ErrUserNotFound
+        }
+        return false, fmt.Errorf("get geo block exemption: %w", err)
+    }
+    return exempt, nil
+}
+
+func (s *GeoBlockService) SetExempt(ctx context.Context, userID uuid.UUID, exempt bool) error {
+    result, err := s.db.Pool().Exec(ctx,
+        "UPDATE users SET geo_block_exempt = $1, updated_at = NOW() WHERE id = $2",
+        exempt, userID,
+    )
+    if err != nil {
+        return fmt.Errorf("set geo block exemption: %w", err)
+    }
+    if result.RowsAffected() == 0 {
+        return ErrUserNotFound
+    }
+    return nil
+}
+
+type geoIPRange struct {
+    network *net.IPNet
+    start   net.IP
+    country string
+}
+
+// GeoIPDatabase maps networks to countries. Networks are kept sorted by start
+// address so a lookup is a binary search for the last network starting at or
+// before the address.
+type GeoIPDatabase struct {
+    ranges      []geoIPRange
+    // SHA-256 prefix of the file it was loaded from
+    fingerprint string
+}
+
+// LoadGeoIPDatabase reads a CSV file of "network,country" rows (e.g.
+// "1.0.0.0/24,AU") with non-overlapping networks.
+func LoadGeoIPDatabase(path string) (*GeoIPDatabase, error) {
+    file, err := os.Open(path)
+    if err != nil {
+        return nil, fmt.Errorf("open geoip database: %w", err)
+    }
+    defer file.Close()
+
+    db := &GeoIPDatabase{}
+    hash := sha256.New()
+    scanner := bufio.NewScanner(io.TeeReader(file, hash))
+    for line := 1; scanner.Scan(); line++ {
+        text := strings.TrimSpace(scanner.Text())
+        if text == "" || strings.HasPrefix(text, "#") {
+            continue
+        }
+
+        cidr, country, ok := strings.Cut(text, ",")
+        _, network, err := net.ParseCIDR(strings.TrimSpace(cidr))
+        if !ok || err != nil {
+            // Tolerate a CSV header row
+            if line == 1 {
+                continue
+            }
+            return nil, fmt.Errorf("geoip database line %d: invalid row %q", line, text)
+        }
+
+        db.ranges = append(db.ranges, geoIPRange{
+            network: network,
+            start:   network.IP.To16(),
+            country: strings.ToUpper(strings.TrimSpace(country)),
+        })
+    }
+    if err := scanner.Err(); err != nil {
+        return nil, fmt.Errorf("read geoip database: %w", err)
+    }
+    db.fingerprint = hex.EncodeToString(hash.Sum(nil)[:8])
+
+    sort.Slice(db.ranges, func(i, j int) bool {
+        return bytes.Compare(db.ranges[i].start, db.ranges[j].start) < 0
+    })
+    return db, nil
+}
+
+func (db *GeoIPDatabase) lookup(rawIP string) string {
+    ip := net.ParseIP(rawIP)
+    if ip == nil {
+        return ""
+    }
+    ip = ip.To16()
+
+    // Index of the first network starting after ip
+    i := sort.Search(len(db.ranges), func(i int) bool {
+        return bytes.Compare(db.ranges[i].start, ip) > 0
+    })
+    if i == 0 {
+        return ""
+    }
+    if r := db.ranges[i-1]; r.network.Contains(ip) {
+        return r.country
+    }
+    return ""
+}
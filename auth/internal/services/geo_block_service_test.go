@@ -0,0 +1,49 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeoIPDatabase_Lookup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "geoip.csv")
+	require.NoError(t, os.WriteFile(path, []byte("network,country\n10.1.0.0/16,de\n10.0.0.0/24,FR\n2001:db8::/32,NL\n"), 0o600))
+
+	db, err := LoadGeoIPDatabase(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, "FR", db.lookup("10.0.0.7"))
+	assert.Equal(t, "DE", db.lookup("10.1.200.1"))
+	assert.Equal(t, "NL", db.lookup("2001:db8::1"))
+	assert.Equal(t, "", db.lookup("10.0.1.1"))
+	assert.Equal(t, "", db.lookup("9.9.9.9"))
+	assert.Equal(t, "", db.lookup("not-an-ip"))
+}
+
+func TestLoadGeoIPDatabase_InvalidRow(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "geoip.csv")
+	require.NoError(t, os.WriteFile(path, []byte("10.0.0.0/24,FR\nbogus\n"), 0o600))
+
+	_, err := LoadGeoIPDatabase(path)
+	assert.Error(t, err)
+}
+
+func TestGeoBlockService_Country(t *testing.T) {
+	headerOnly := NewGeoBlockService(nil, []string{" ru ", "KP"}, "CF-IPCountry", nil, nil)
+	assert.True(t, headerOnly.Enabled())
+	assert.Equal(t, "RU", headerOnly.Country("10.0.0.1", "ru"))
+	assert.True(t, headerOnly.IsBlocked("RU"))
+	assert.True(t, headerOnly.IsBlocked("KP"))
+	assert.False(t, headerOnly.IsBlocked("US"))
+	assert.False(t, headerOnly.IsBlocked(""))
+
+	// Without a trusted header, client-supplied values are ignored
+	untrusted := NewGeoBlockService(nil, []string{"RU"}, "", nil, nil)
+	assert.Equal(t, "", untrusted.Country("10.0.0.1", "RU"))
+
+	assert.False(t, NewGeoBlockService(nil, nil, "", nil, nil).Enabled())
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+    "context"
+    "crypto/sha256"
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "strings"
+
+    "auth-service/internal/database"
+
+    "github.com/google/uuid"
+    "go.uber.org/zap"
+)
+
+const maxHandleAttempts = 8
+
+var ErrNoHandleAvailable = errors.New("no handle available")
+
+var handleAdjectives = []string{
+    "amber", "brave", "breezy", "bright", "bubbly", "calm", "cheery", "chill",
+    "clever", "cosmic", "cozy", "crispy", "curious", "daring", "dizzy", "dreamy",
+    "eager", "fancy", "fizzy", "fluffy", "frosty", "funky", "gentle", "giddy",
+    "glowing", "groovy", "happy", "hazy", "jazzy", "jolly", "lucky", "mellow",
+    "merry", "mighty", "misty", "nimble", "peppy", "plucky", "quick", "quirky",
+    "rapid", "rosy", "rusty", "shiny", "silly", "sleepy", "snappy", "sneaky",
+    "sparkly", "speedy", "spicy", "sunny", "swift", "tiny", "witty", "zesty",
+}
+
+var handleAnimals = []string{
+    "alpaca", "badger", "beaver", "bison", "bunny", "capybara", "cheetah", "coyote",
+    "crane", "dingo", "dolphin", "falcon", "ferret", "finch", "fox", "gecko",
+    "giraffe", "hedgehog", "heron", "ibis", "iguana", "jackal", "koala", "lemur",
+    "llama", "lynx", "marmot", "meerkat", "moose", "narwhal", "newt", "ocelot",
+    "octopus", "otter", "owl", "panda", "pelican", "penguin", "puffin", "quokka",
+    "raccoon", "raven", "robin", "salmon", "seal", "sloth", "squid", "tapir",
+    "tiger", "toucan", "turtle", "walrus", "wombat", "yak", "zebra",
+}
+
+// Substrings that must never appear in a generated handle, including across
+// word boundaries once the separators are stripped.
+var handleBlocklist = []string{
+    "anal", "anus", "arse", "cock", "crap", "cum", "damn", "dick", "fag",
+    "fuck", "hell", "jizz", "kkk", "nazi", "nig", "piss", "porn", "rape",
+    "sex", "shit", "slut", "tit", "twat", "wank", "whore",
+}
+
+type HandleService struct {
+    db     *database.DB
+    logger *zap.SugaredLogger
+}
+
+func NewHandleService(db *database.DB, logger *zap.SugaredLogger) *HandleService {
+    return &HandleService{
+        db:     db,
+        logger: logger,
+    }
+}
+
+// GenerateHandle returns an adjective-animal-number handle derived from seed.
+// The same seed and attempt always produce the same handle, so retries after a
+// collision walk a stable sequence instead of rolling fresh randomness.
+func GenerateHandle(seed string, attempt int) string {
+    sum := sha256.Sum256([]byte(fmt.Sprintf("%s:%d", seed, attempt)))
+
+    adjective := handleAdjectives[binary.BigEndian.Uint32(sum[0:4])%uint32(len(handleAdjectives))]
+    animal := handleAnimals[binary.BigEndian.Uint32(sum[4:8])%uint32(len(handleAnimals))]
+    number := binary.BigEndian.Uint32(sum[8:12]) % 10000
+
+    return fmt.Sprintf("%s-%s-%d", adjective, animal, number)
+}
+
+// IsHandleClean reports whether the handle passes the profanity screen.
+func IsHandleClean(handle string) bool {
+    normalized := strings.ToLower(handle)
+    joined := strings.NewReplacer("-", "", "_", "", ".", "").Replace(normalized)
+
+    for _, word := range handleBlocklist {
+        if strings.Contains(normalized, word) || strings.Contains(joined, word) {
+            return false
+        }
+    }
+    return true
+}
+
+// Generate returns an unused handle for the given seed, retrying on
+// collisions and on candidates rejected by the profanity screen.
+func (s *HandleService) Generate(ctx context.Context, seed string) (string, error) {
+    for attempt := 0; attempt < maxHandleAttempts; attempt++ {
+        handle := GenerateHandle(seed, attempt)
+        if !IsHandleClean(handle) {
+            continue
+        }
+
+        taken, err := s.isTaken(ctx, handle)
+        if err != nil {
+            return "", err
+        }
+        if !taken {
+            return handle, nil
+        }
+    }
+
+    return "", ErrNoHandleAvailable
+}
+
+// GenerateGuest returns an unused handle for a new guest account.
+func (s *HandleService) GenerateGuest(ctx context.Context) (string, error) {
+    return s.Generate(ctx, uuid.New().String())
+}
+
+// Suggest returns up to n unused handles seeded from a requested username,
+// offered to the client when that username is already taken.
+func (s *HandleService) Suggest(ctx context.Context, username string, n int) ([]string, error) {
+    seed := strings.ToLower(username)
+    suggestions := make([]string, 0, n)
+
+    for attempt := 0; attempt < n*maxHandleAttempts && len(suggestions) < n; attempt++ {
+        handle := GenerateHandle(seed, attempt)
+        if !IsHandleClean(handle) {
+            continue
+        }
+
+        taken, err := s.isTaken(ctx, handle)
+        if err != nil {
+            return nil, err
+        }
+        if !taken {
+            suggestions = append(suggestions, handle)
+        }
+    }
+
+    return suggestions, nil
+}
+
+func (s *HandleService) isTaken(ctx context.Context, handle string) (bool, error) {
+    var exists bool
+    err := s.db.Pool().QueryRow(ctx,
+        "SELECT EXISTS(SELECT 1 FROM users WHERE username = $1)",
+        handle,
+    ).Scan(&exists)
+    if err != nil {
+        return false, fmt.Errorf("check handle: %w", err)
+    }
+    return exists, nil
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateHandle_Deterministic(t *testing.T) {
+	first := GenerateHandle("seed", 0)
+	second := GenerateHandle("seed", 0)
+	retry := GenerateHandle("seed", 1)
+
+	assert.Equal(t, first, second)
+	assert.NotEqual(t, first, retry)
+	assert.Regexp(t, regexp.MustCompile(`^[a-z]+-[a-z]+-\d{1,4}$`), first)
+}
+
+func TestGenerateHandle_WordListsAreClean(t *testing.T) {
+	for _, word := range handleAdjectives {
+		assert.True(t, IsHandleClean(word), word)
+	}
+	for _, word := range handleAnimals {
+		assert.True(t, IsHandleClean(word), word)
+	}
+}
+
+func TestIsHandleClean(t *testing.T) {
+	tests := []struct {
+		handle string
+		clean  bool
+	}{
+		{"brave-otter-42", true},
+		{"Sunny-Koala-7", true},
+		{"shit-otter-1", false},
+		{"sneaky-SHIT-1", false},
+		{"fu-ck-12", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.handle, func(t *testing.T) {
+			assert.Equal(t, tt.clean, IsHandleClean(tt.handle))
+		})
+	}
+}
@@ -0,0 +1,15 @@
+package services
+
+import "github.com/google/uuid"
+
+// NewID returns a new primary key of the configured UUID version. Version 7
+// IDs start with their creation time in milliseconds, so they sort by age
+// and new rows land at the end of the primary key index instead of at
+// random pages; anything else gets a random version 4 ID. Both kinds live
+// side by side in the same columns.
+func NewID(version int) uuid.UUID {
+    if version == 7 {
+        return uuid.Must(uuid.NewV7())
+    }
+    return uuid.New()
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"auth-service/test"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewID(t *testing.T) {
+	assert.Equal(t, uuid.Version(4), NewID(4).Version())
+	assert.Equal(t, uuid.Version(4), NewID(0).Version())
+
+	before := time.Now().Truncate(time.Millisecond)
+	id := NewID(7)
+	assert.Equal(t, uuid.Version(7), id.Version())
+
+	at := time.Unix(id.Time().UnixTime())
+	assert.False(t, at.Before(before))
+	assert.WithinDuration(t, time.Now(), at, time.Second)
+
+	// Later IDs sort after earlier ones
+	time.Sleep(2 * time.Millisecond)
+	assert.Less(t, id.String(), NewID(7).String())
+}
+
+func TestAuthService_SortableIDs(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	ctx := context.Background()
+	cfg := *suite.Config
+	cfg.IDVersion = 7
+	authService := NewAuthService(suite.DB.DB, suite.Redis.Client, &cfg, suite.Logger, suite.Events)
+
+	user, session, err := authService.RegisterGuest(ctx, "guest_sortable", "test-agent", "127.0.0.1", "", "")
+	require.NoError(t, err)
+	assert.Equal(t, uuid.Version(7), user.ID.Version())
+	assert.Equal(t, uuid.Version(7), session.ID.Version())
+
+	// Postgres reads the same creation time out of the ID
+	var at time.Time
+	var inRange bool
+	require.NoError(t, suite.DB.Pool().QueryRow(ctx,
+		`SELECT uuid_v7_time(id), id >= uuid_v7_lower_bound(created_at - INTERVAL '1 second') FROM users WHERE id = $1`,
+		user.ID,
+	).Scan(&at, &inRange))
+	assert.WithinDuration(t, time.Unix(user.ID.Time().UnixTime()), at, time.Millisecond)
+	assert.True(t, inRange)
+
+	var generated uuid.UUID
+	require.NoError(t, suite.DB.Pool().QueryRow(ctx, "SELECT uuid_generate_v7()").Scan(&generated))
+	assert.Equal(t, uuid.Version(7), generated.Version())
+}
@@ -0,0 +1,229 @@
+package services
+
+import (
+    "context"
+    "fmt"
+    "strings"
+    "sync"
+    "time"
+    "unicode"
+
+    "auth-service/internal/database"
+    "auth-service/internal/models"
+
+    "github.com/google/uuid"
+    "go.uber.org/zap"
+    "golang.org/x/text/unicode/norm"
+)
+
+const (
+    // How long the protected handles of admins and venue accounts are cached
+    protectedHandlesTTL = 5 * time.Minute
+    // How long to keep using the last known handles after failing to load them
+    protectedHandlesRetry = 30 * time.Second
+    // Skeletons shorter than this are only denied on an exact match; one
+    // edit apart is too common among short names to be suspicious
+    minSimilarSkeleton = 5
+    // Skeletons at least this long are also flagged when they appear inside
+    // other content, e.g. "tapinsupport_official"
+    minContainedSkeleton = 6
+)
+
+// impersonationProvider names the guard in moderation_denials.
+const impersonationProvider = "impersonation"
+
+// Letters from other scripts that render like Latin ones, after lowercasing
+var confusables = map[rune]rune{
+    // Cyrillic
+    'а': 'a', 'в': 'b', 'е': 'e', 'ё': 'e', 'һ': 'h', 'і': 'i', 'ї': 'i', 'ј': 'j', 'к': 'k',
+    'м': 'm', 'н': 'h', 'о': 'o', 'р': 'p', 'с': 'c', 'т': 't', 'у': 'y', 'х': 'x', 'ѕ': 's',
+    'ԁ': 'd', 'ԛ': 'q', 'ԝ': 'w', 'ӏ': 'l',
+    // Greek
+    'α': 'a', 'β': 'b', 'ε': 'e', 'η': 'n', 'ι': 'i', 'κ': 'k', 'ν': 'v', 'ο': 'o', 'ρ': 'p',
+    'τ': 't', 'υ': 'u', 'χ': 'x', 'ω': 'w', 'ϲ': 'c',
+    // Latin lookalikes
+    'ı': 'i', 'ȷ': 'j', 'ɡ': 'g', 'ł': 'l', 'ø': 'o', 'đ': 'd', 'ħ': 'h',
+}
+
+// Letter pairs that read as one letter at a glance
+var skeletonDigraphs = strings.NewReplacer("rn", "m", "vv", "w", "cl", "d")
+
+// Digits and symbols standing in for letters. i, l, 1 and | all become l.
+var skeletonFolding = strings.NewReplacer(
+    "0", "o", "1", "l", "i", "l", "|", "l", "!", "l", "3", "e", "4", "a", "5", "s",
+    "7", "t", "8", "b", "9", "g", "@", "a", "$", "s",
+)
+
+// Skeleton reduces text to what it looks like: compatibility forms such as
+// fullwidth letters are unified, accents, zero-width and other invisible
+// characters dropped, lookalike letters and digits folded, everything but
+// letters and digits removed and digraphs folded. Texts with the same skeleton
+// are hard to tell apart.
+func Skeleton(text string) string {
+    var b strings.Builder
+    for _, r := range norm.NFKD.String(text) {
+        if unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Cf, r) {
+            continue
+        }
+        r = unicode.ToLower(r)
+        if mapped, ok := confusables[r]; ok {
+            r = mapped
+        }
+        b.WriteRune(r)
+    }
+
+    letters := strings.Map(func(r rune) rune {
+        if r <= unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+            return r
+        }
+        return -1
+    }, skeletonFolding.Replace(b.String()))
+    return skeletonDigraphs.Replace(letters)
+}
+
+type protectedHandle struct {
+    handle   string
+    skeleton string
+    // nil for configured handles, which belong to nobody
+    owner *uuid.UUID
+}
+
+// ImpersonationGuard protects the handles of admins, venue accounts and a
+// configured list against lookalikes. Content with the same skeleton as a
+// protected handle is denied; content one edit away from it, or containing
+// it, is flagged for an admin to review.
+type ImpersonationGuard struct {
+    db         *database.DB
+    configured []protectedHandle
+    logger     *zap.SugaredLogger
+
+    mu       sync.Mutex
+    accounts []protectedHandle
+    // When the account handles are next loaded; claimed by the caller
+    // loading them so the others keep using the last known ones meanwhile
+    reloadAt time.Time
+}
+
+func NewImpersonationGuard(db *database.DB, handles []string, logger *zap.SugaredLogger) *ImpersonationGuard {
+    configured := make([]protectedHandle, 0, len(handles))
+    for _, handle := range handles {
+        if skeleton := Skeleton(handle); skeleton != "" {
+            configured = append(configured, protectedHandle{handle: handle, skeleton: skeleton})
+        }
+    }
+    return &ImpersonationGuard{
+        db:         db,
+        configured: configured,
+        logger:     logger,
+    }
+}
+
+// Match compares text, about to become userID's, against the protected
+// handles other than userID's own. It returns the outcome and the handle
+// matched, if any.
+func (g *ImpersonationGuard) Match(ctx context.Context, userID *uuid.UUID, text string) (string, string) {
+    skeleton := Skeleton(text)
+    if skeleton == "" {
+        return models.ModerationAllow, ""
+    }
+
+    outcome, matched := models.ModerationAllow, ""
+    for _, protected := range g.protected(ctx) {
+        if userID != nil && protected.owner != nil && *protected.owner == *userID {
+            continue
+        }
+        switch {
+        case skeleton == protected.skeleton:
+            return models.ModerationDeny, protected.handle
+        case outcome == models.ModerationAllow && resembles(skeleton, protected.skeleton):
+            outcome, matched = models.ModerationFlag, protected.handle
+        }
+    }
+    return outcome, matched
+}
+
+func resembles(skeleton, protected string) bool {
+    if len(protected) >= minContainedSkeleton && strings.Contains(skeleton, protected) {
+        return true
+    }
+    return len(protected) >= minSimilarSkeleton && withinOneEdit(skeleton, protected)
+}
+
+// withinOneEdit reports whether a and b differ by at most one inserted,
+// deleted or substituted byte. Skeletons are ASCII.
+func withinOneEdit(a, b string) bool {
+    if len(a) > len(b) {
+        a, b = b, a
+    }
+    if len(b)-len(a) > 1 {
+        return false
+    }
+
+    i := 0
+    for i < len(a) && a[i] == b[i] {
+        i++
+    }
+    if i == len(a) {
+        return true
+    }
+    if len(a) == len(b) {
+        return a[i+1:] == b[i+1:]
+    }
+    return a[i:] == b[i+1:]
+}
+
+// protected returns the configured handles and those of admin and venue
+// accounts, reloading the latter when stale. If they can't be loaded the
+// last known ones are used and loading is retried later.
+func (g *ImpersonationGuard) protected(ctx context.Context) []protectedHandle {
+    g.mu.Lock()
+    reload := !time.Now().Before(g.reloadAt)
+    if reload {
+        g.reloadAt = time.Now().Add(protectedHandlesRetry)
+    }
+    g.mu.Unlock()
+
+    if reload {
+        accounts, err := g.loadAccounts(ctx)
+        if err != nil {
+            g.logger.Errorf("Failed to load protected handles: %v", err)
+        } else {
+            g.mu.Lock()
+            g.accounts = accounts
+            g.reloadAt = time.Now().Add(protectedHandlesTTL)
+            g.mu.Unlock()
+        }
+    }
+
+    g.mu.Lock()
+    defer g.mu.Unlock()
+    handles := make([]protectedHandle, 0, len(g.configured)+len(g.accounts))
+    handles = append(handles, g.configured...)
+    return append(handles, g.accounts...)
+}
+
+func (g *ImpersonationGuard) loadAccounts(ctx context.Context) ([]protectedHandle, error) {
+    rows, err := g.db.Pool().Query(ctx,
+        "SELECT id, username FROM users WHERE role = $1 OR plan = $2",
+        models.RoleAdmin, models.PlanVenue,
+    )
+    if err != nil {
+        return nil, fmt.Errorf("list protected handles: %w", err)
+    }
+    defer rows.Close()
+
+    var handles []protectedHandle
+    for rows.Next() {
+        var id uuid.UUID
+        var username string
+        if err := rows.Scan(&id, &username); err != nil {
+            return nil, fmt.Errorf("scan protected handle: %w", err)
+        }
+        if skeleton := Skeleton(username); skeleton != "" {
+            owner := id
+            handles = append(handles, protectedHandle{handle: username, skeleton: skeleton, owner: &owner})
+        }
+    }
+    return handles, rows.Err()
+}
+
@@ -0,0 +1,200 @@
+package services
+
+import (
+    "context"
+    "fmt"
+    "strings"
+
+    "auth-service/internal/apperr"
+    "auth-service/internal/config"
+    "auth-service/internal/models"
+
+    "github.com/google/uuid"
+    "github.com/jackc/pgx/v5"
+)
+
+var (
+    ErrImpersonationReviewNotFound   = apperr.New(apperr.NotFound, "Impersonation review not found", nil)
+    ErrImpersonationReviewNotPending = apperr.New(apperr.Conflict, "Impersonation review is not pending", nil)
+    // Email addresses are proven by verification and can't be swapped for a
+    // generated one
+    ErrImpersonationNotRevertible = apperr.New(apperr.Invalid, "Email addresses can only be dismissed", nil)
+)
+
+// trackImpersonation queues text, just stored in the user's field, for
+// review if it resembles a protected handle, replacing any pending review of
+// the field. Text that doesn't drops the pending review.
+func (s *ModerationService) trackImpersonation(ctx context.Context, userID uuid.UUID, field, text string) {
+    if s.guard == nil {
+        return
+    }
+
+    outcome, handle := s.guard.Match(ctx, &userID, text)
+    if outcome != models.ModerationAllow {
+        s.queueReview(ctx, userID, field, text, handle)
+        return
+    }
+
+    _, err := s.db.Pool().Exec(ctx,
+        "DELETE FROM impersonation_reviews WHERE user_id = $1 AND field = $2 AND status = $3",
+        userID, field, models.ImpersonationReviewPending,
+    )
+    if err != nil {
+        s.logger.Errorf("Failed to drop impersonation review of %s for user %s: %v", field, userID, err)
+    }
+}
+
+// ScreenEmail queues a new account's email address for review if its local
+// part resembles a protected handle, e.g. tapin.support@example.com. Emails
+// are never denied: the address has to be verified, and it isn't shown to
+// other users.
+func (s *ModerationService) ScreenEmail(ctx context.Context, userID uuid.UUID, email string) {
+    if s == nil || s.guard == nil {
+        return
+    }
+
+    local := email
+    if at := strings.LastIndex(email, "@"); at >= 0 {
+        local = email[:at]
+    }
+    // Even an exact lookalike is only flagged
+    if outcome, handle := s.guard.Match(ctx, &userID, local); outcome != models.ModerationAllow {
+        s.queueReview(ctx, userID, models.ModerationFieldEmail, email, handle)
+    }
+}
+
+func (s *ModerationService) queueReview(ctx context.Context, userID uuid.UUID, field, text, handle string) {
+    s.logger.Infow("Content flagged for impersonation review",
+        "user_id", userID,
+        "field", field,
+        "protected_handle", handle,
+    )
+
+    _, err := s.db.Pool().Exec(ctx,
+        `INSERT INTO impersonation_reviews (user_id, field, content, protected_handle)
+         VALUES ($1, $2, $3, $4)
+         ON CONFLICT (user_id, field) WHERE status = 'pending' DO UPDATE SET
+             content = EXCLUDED.content, protected_handle = EXCLUDED.protected_handle, created_at = NOW()`,
+        userID, field, text, handle,
+    )
+    if err != nil {
+        s.logger.Errorf("Failed to queue impersonation review of %s for user %s: %v", field, userID, err)
+        return
+    }
+
+    s.alerts.Raise(ctx, &OpsAlert{
+        Name:     config.AlertImpersonation,
+        Severity: SeverityHigh,
+        Title:    "Possible impersonation",
+        Message:  fmt.Sprintf("A %s resembling %s was queued for review", field, handle),
+        Fields: map[string]string{
+            "user_id": userID.String(),
+            "field":   field,
+            "content": text,
+        },
+    })
+}
+
+// ListImpersonationReviews returns reviews, oldest first, optionally only
+// those with status.
+func (s *ModerationService) ListImpersonationReviews(ctx context.Context, status string) ([]*models.ImpersonationReview, error) {
+    rows, err := s.db.Pool().Query(ctx,
+        `SELECT id, user_id, field, content, protected_handle, status, reviewed_by, reviewed_at, created_at
+         FROM impersonation_reviews WHERE ($1 = '' OR status = $1)
+         ORDER BY created_at`,
+        status,
+    )
+    if err != nil {
+        return nil, fmt.Errorf("list impersonation reviews: %w", err)
+    }
+    defer rows.Close()
+
+    reviews := []*models.ImpersonationReview{}
+    for rows.Next() {
+        r := &models.ImpersonationReview{}
+        if err := rows.Scan(&r.ID, &r.UserID, &r.Field, &r.Content, &r.ProtectedHandle, &r.Status,
+            &r.ReviewedBy, &r.ReviewedAt, &r.CreatedAt); err != nil {
+            return nil, fmt.Errorf("scan impersonation review: %w", err)
+        }
+        reviews = append(reviews, r)
+    }
+    return reviews, rows.Err()
+}
+
+func (s *ModerationService) GetImpersonationReview(ctx context.Context, reviewID uuid.UUID) (*models.ImpersonationReview, error) {
+    r := &models.ImpersonationReview{}
+    err := s.db.Pool().QueryRow(ctx,
+        `SELECT id, user_id, field, content, protected_handle, status, reviewed_by, reviewed_at, created_at
+         FROM impersonation_reviews WHERE id = $1`,
+        reviewID,
+    ).Scan(&r.ID, &r.UserID, &r.Field, &r.Content, &r.ProtectedHandle, &r.Status,
+        &r.ReviewedBy, &r.ReviewedAt, &r.CreatedAt)
+    if err != nil {
+        if err == pgx.ErrNoRows {
+            return nil, ErrImpersonationReviewNotFound
+        }
+        return nil, fmt.Errorf("get impersonation review: %w", err)
+    }
+    return r, nil
+}
+
+// DismissImpersonationReview leaves the content as it is.
+func (s *ModerationService) DismissImpersonationReview(ctx context.Context, reviewID, adminID uuid.UUID) error {
+    return s.closeReview(ctx, reviewID, adminID, models.ImpersonationReviewDismissed)
+}
+
+// RevertImpersonationReview takes the content off the account like a denied
+// re-check would: display names are cleared and usernames replaced with a
+// generated handle. Content changed since the review was queued is left
+// alone.
+func (s *ModerationService) RevertImpersonationReview(ctx context.Context, reviewID, adminID uuid.UUID) error {
+    review, err := s.GetImpersonationReview(ctx, reviewID)
+    if err != nil {
+        return err
+    }
+    if review.Field == models.ModerationFieldEmail {
+        return ErrImpersonationNotRevertible
+    }
+
+    // Closed first, as reverting a username replaces the pending review
+    if err := s.closeReview(ctx, reviewID, adminID, models.ImpersonationReviewReverted); err != nil {
+        return err
+    }
+
+    current, err := s.currentValue(ctx, review.UserID, review.Field)
+    if err == nil && current != nil && *current == review.Content {
+        err = s.revert(ctx, &moderationCheck{userID: review.UserID, field: review.Field, content: review.Content})
+        if err == nil {
+            s.recordDenial(ctx, &review.UserID, review.Field, review.Content, impersonationProvider,
+                "resembles @"+review.ProtectedHandle, models.ModerationSourceReview)
+        }
+    }
+    if err != nil {
+        if _, reopenErr := s.db.Pool().Exec(ctx,
+            "UPDATE impersonation_reviews SET status = $1, reviewed_by = NULL, reviewed_at = NULL WHERE id = $2",
+            models.ImpersonationReviewPending, reviewID,
+        ); reopenErr != nil {
+            s.logger.Errorf("Failed to reopen impersonation review %s: %v", reviewID, reopenErr)
+        }
+        return err
+    }
+    return nil
+}
+
+func (s *ModerationService) closeReview(ctx context.Context, reviewID, adminID uuid.UUID, status string) error {
+    result, err := s.db.Pool().Exec(ctx,
+        `UPDATE impersonation_reviews SET status = $1, reviewed_by = $2, reviewed_at = NOW()
+         WHERE id = $3 AND status = $4`,
+        status, adminID, reviewID, models.ImpersonationReviewPending,
+    )
+    if err != nil {
+        return fmt.Errorf("close impersonation review: %w", err)
+    }
+    if result.RowsAffected() == 0 {
+        if _, err := s.GetImpersonationReview(ctx, reviewID); err != nil {
+            return err
+        }
+        return ErrImpersonationReviewNotPending
+    }
+    return nil
+}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"auth-service/internal/models"
+	"auth-service/test"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestSkeleton(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"plain", "tapin", "tapln"},
+		{"case and separators", "Tap-In", "tapln"},
+		{"digits for letters", "T4P1N", "tapln"},
+		{"cyrillic lookalikes", "tаpіn", "tapln"},
+		{"fullwidth", "ｔａｐｉｎ", "tapln"},
+		{"zero width", "tap​in", "tapln"},
+		{"accents", "tápín", "tapln"},
+		{"rn for m", "rnoderator", "moderator"},
+		{"nothing visible", "​‍", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Skeleton(tt.text))
+		})
+	}
+}
+
+func TestWithinOneEdit(t *testing.T) {
+	assert.True(t, withinOneEdit("support", "support"))
+	assert.True(t, withinOneEdit("suport", "support"))
+	assert.True(t, withinOneEdit("supportx", "support"))
+	assert.True(t, withinOneEdit("sapport", "support"))
+	assert.False(t, withinOneEdit("sapp0rt", "support"))
+	assert.False(t, withinOneEdit("sup", "support"))
+}
+
+func TestImpersonationGuard_Match(t *testing.T) {
+	guard := NewImpersonationGuard(nil, []string{"tapin", "support"}, zap.NewNop().Sugar())
+	// No accounts to load without a database
+	guard.reloadAt = time.Now().Add(time.Hour)
+
+	owner := uuid.New()
+	guard.accounts = []protectedHandle{{handle: "venue_owner", skeleton: Skeleton("venue_owner"), owner: &owner}}
+
+	tests := []struct {
+		name    string
+		userID  *uuid.UUID
+		text    string
+		outcome string
+		handle  string
+	}{
+		{"lookalike", nil, "Suppоrt", models.ModerationDeny, "support"},
+		{"one edit away", nil, "supp0rts", models.ModerationFlag, "support"},
+		{"contains handle", nil, "official_support_team", models.ModerationFlag, "support"},
+		{"one edit from short handle", nil, "tapins", models.ModerationFlag, "tapin"},
+		{"short handle inside", nil, "thetapinapp", models.ModerationAllow, ""},
+		{"unrelated", nil, "alice", models.ModerationAllow, ""},
+		{"account handle", nil, "venue_0wner", models.ModerationDeny, "venue_owner"},
+		{"own handle", &owner, "venue_owner", models.ModerationAllow, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outcome, handle := guard.Match(context.Background(), tt.userID, tt.text)
+			assert.Equal(t, tt.outcome, outcome)
+			assert.Equal(t, tt.handle, handle)
+		})
+	}
+}
+
+func TestModerationService_Impersonation(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	ctx := context.Background()
+	userService := NewUserService(suite.DB.DB, suite.Redis.Client, suite.Logger)
+	moderation := NewModerationService(suite.DB.DB, nil, userService, NewHandleService(suite.DB.DB, suite.Logger), suite.Logger)
+	moderation.SetImpersonationGuard(NewImpersonationGuard(suite.DB.DB, []string{"support"}, suite.Logger))
+	userService.SetModeration(moderation)
+
+	user := suite.CreateTestUser(t, test.TestData.ValidEmail, test.TestData.ValidUsername, test.TestData.ValidPassword)
+	admin := suite.CreateTestUser(t, "admin@example.com", "admin_user", test.TestData.ValidPassword)
+
+	// Lookalikes are denied without a moderation provider
+	err := userService.UpdateProfile(ctx, user.ID, "5upp0rt")
+	var denied *ContentDeniedError
+	require.ErrorAs(t, err, &denied)
+
+	denials, err := moderation.ListDenials(ctx, models.ModerationDenialFilter{UserID: &user.ID})
+	require.NoError(t, err)
+	require.Len(t, denials, 1)
+	assert.Equal(t, impersonationProvider, denials[0].Provider)
+
+	// Near misses are accepted and queued
+	require.NoError(t, userService.UpdateProfile(ctx, user.ID, "the_support_desk"))
+
+	reviews, err := moderation.ListImpersonationReviews(ctx, models.ImpersonationReviewPending)
+	require.NoError(t, err)
+	require.Len(t, reviews, 1)
+	assert.Equal(t, "the_support_desk", reviews[0].Content)
+	assert.Equal(t, "support", reviews[0].ProtectedHandle)
+
+	// Reverting replaces the username and closes the review
+	require.NoError(t, moderation.RevertImpersonationReview(ctx, reviews[0].ID, admin.ID))
+	assert.ErrorIs(t, moderation.DismissImpersonationReview(ctx, reviews[0].ID, admin.ID), ErrImpersonationReviewNotPending)
+
+	current, err := userService.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.NotEqual(t, "the_support_desk", current.Username)
+
+	review, err := moderation.GetImpersonationReview(ctx, reviews[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.ImpersonationReviewReverted, review.Status)
+	assert.Equal(t, &admin.ID, review.ReviewedBy)
+
+	// Emails are only ever flagged, and can't be reverted
+	moderation.ScreenEmail(ctx, user.ID, "support@example.com")
+	reviews, err = moderation.ListImpersonationReviews(ctx, models.ImpersonationReviewPending)
+	require.NoError(t, err)
+	require.Len(t, reviews, 1)
+	assert.Equal(t, models.ModerationFieldEmail, reviews[0].Field)
+	assert.ErrorIs(t, moderation.RevertImpersonationReview(ctx, reviews[0].ID, admin.ID), ErrImpersonationNotRevertible)
+}
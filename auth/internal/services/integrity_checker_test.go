@@ -0,0 +1,70 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"auth-service/internal/models"
+	"auth-service/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIntegrityChecker_CheckAndRepair(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	authService := NewAuthService(suite.DB.DB, suite.Redis.Client, suite.Config, suite.Logger, suite.Events)
+	checker := NewIntegrityChecker(suite.DB.DB, true, suite.Logger)
+	ctx := context.Background()
+	pool := suite.DB.DB.Pool()
+
+	user := suite.CreateTestUser(t, test.TestData.ValidEmail, test.TestData.ValidUsername, test.TestData.ValidPassword)
+	_, _, err := authService.Login(ctx, &models.LoginRequest{Email: test.TestData.ValidEmail, Password: test.TestData.ValidPassword}, "agent", "10.0.0.1", "web")
+	require.NoError(t, err)
+	_, err = issueEmailVerificationToken(ctx, pool, user.ID, user.Email, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+
+	// Left behind by a deletion request and a verification that didn't
+	// clean up after themselves
+	_, err = pool.Exec(ctx,
+		`UPDATE users SET deletion_requested_at = NOW(), email_verified = true, email_verified_at = NOW() + INTERVAL '1 second' WHERE id = $1`,
+		user.ID)
+	require.NoError(t, err)
+	_, err = pool.Exec(ctx,
+		`INSERT INTO token_store (key, value, expires_at) VALUES
+		 ('blacklist:old', '1', NOW() - INTERVAL '2 hours'), ('blacklist:recent', '1', NOW() - INTERVAL '1 minute')`)
+	require.NoError(t, err)
+
+	counts := func(report *models.IntegrityReport) map[string][2]int64 {
+		out := map[string][2]int64{}
+		for _, anomaly := range report.Anomalies {
+			out[anomaly.Check] = [2]int64{anomaly.Count, anomaly.Repaired}
+		}
+		return out
+	}
+
+	report, err := checker.Check(ctx, false)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][2]int64{
+		"sessions_of_deleted_users": {1, 0},
+		"verified_email_tokens":     {1, 0},
+		"expired_blacklist_entries": {1, 0},
+	}, counts(report))
+
+	report, err = checker.Check(ctx, true)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][2]int64{
+		"sessions_of_deleted_users": {1, 1},
+		"verified_email_tokens":     {1, 1},
+		"expired_blacklist_entries": {1, 1},
+	}, counts(report))
+
+	report, err = checker.Check(ctx, false)
+	require.NoError(t, err)
+	for _, anomaly := range report.Anomalies {
+		assert.Zero(t, anomaly.Count, anomaly.Check)
+	}
+}
@@ -0,0 +1,233 @@
+package services
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "net"
+    "strings"
+    "sync"
+    "time"
+
+    "auth-service/internal/apperr"
+    "auth-service/internal/models"
+    "auth-service/internal/redis"
+
+    "go.uber.org/zap"
+)
+
+const ipBanKeyPrefix = "ipban:"
+
+var (
+    ErrInvalidCIDR   = apperr.New(apperr.Invalid, "Invalid IP address or CIDR range", nil)
+    ErrIPBanNotFound = apperr.New(apperr.NotFound, "IP ban not found", nil)
+)
+
+// IPBanService keeps a TTL'd list of banned IPs and CIDR ranges in Redis,
+// one key per range so each ban expires on its own. Lookups are served from
+// an in-process copy that is refreshed periodically, so the ban check does
+// not cost a Redis round trip per request.
+type IPBanService struct {
+    redis  *redis.Client
+    logger *zap.SugaredLogger
+
+    mu   sync.RWMutex
+    bans []cachedIPBan
+}
+
+type cachedIPBan struct {
+    network   *net.IPNet
+    expiresAt time.Time
+}
+
+type storedIPBan struct {
+    Reason    string    `json:"reason"`
+    Source    string    `json:"source"`
+    CreatedAt time.Time `json:"created_at"`
+}
+
+func NewIPBanService(redis *redis.Client, logger *zap.SugaredLogger) *IPBanService {
+    return &IPBanService{
+        redis:  redis,
+        logger: logger,
+    }
+}
+
+// Ban bans an IP or CIDR range for ttl. A bare IP is stored as a /32 (or
+// /128); banning a range that is already banned replaces the entry.
+func (s *IPBanService) Ban(ctx context.Context, cidr, reason, source string, ttl time.Duration) (*models.IPBan, error) {
+    network, err := ParseIPOrCIDR(cidr)
+    if err != nil {
+        return nil, err
+    }
+
+    stored := storedIPBan{Reason: reason, Source: source, CreatedAt: time.Now().UTC()}
+    value, err := json.Marshal(stored)
+    if err != nil {
+        return nil, fmt.Errorf("marshal ip ban: %w", err)
+    }
+
+    if err := s.redis.Set(ctx, ipBanKeyPrefix+network.String(), value, ttl); err != nil {
+        return nil, fmt.Errorf("store ip ban: %w", err)
+    }
+
+    ban := &models.IPBan{
+        CIDR:      network.String(),
+        Reason:    reason,
+        Source:    source,
+        CreatedAt: stored.CreatedAt,
+        ExpiresAt: stored.CreatedAt.Add(ttl),
+    }
+
+    // Apply locally right away rather than waiting for the next refresh
+    s.mu.Lock()
+    s.bans = append(s.bans, cachedIPBan{network: network, expiresAt: ban.ExpiresAt})
+    s.mu.Unlock()
+
+    return ban, nil
+}
+
+func (s *IPBanService) Unban(ctx context.Context, cidr string) error {
+    network, err := ParseIPOrCIDR(cidr)
+    if err != nil {
+        return err
+    }
+
+    key := ipBanKeyPrefix + network.String()
+    exists, err := s.redis.Exists(ctx, key)
+    if err != nil {
+        return fmt.Errorf("check ip ban: %w", err)
+    }
+    if !exists {
+        return ErrIPBanNotFound
+    }
+
+    if err := s.redis.Delete(ctx, key); err != nil {
+        return fmt.Errorf("delete ip ban: %w", err)
+    }
+
+    return s.Refresh(ctx)
+}
+
+// List returns all active bans read directly from Redis.
+func (s *IPBanService) List(ctx context.Context) ([]*models.IPBan, error) {
+    keys, err := s.redis.Scan(ctx, ipBanKeyPrefix+"*")
+    if err != nil {
+        return nil, fmt.Errorf("scan ip bans: %w", err)
+    }
+
+    bans := []*models.IPBan{}
+    for _, key := range keys {
+        value, err := s.redis.Get(ctx, key)
+        if err != nil {
+            if err == redis.Nil {
+                continue // expired between SCAN and GET
+            }
+            return nil, fmt.Errorf("get ip ban: %w", err)
+        }
+        ttl, err := s.redis.TTL(ctx, key)
+        if err != nil {
+            return nil, fmt.Errorf("get ip ban ttl: %w", err)
+        }
+
+        var stored storedIPBan
+        if err := json.Unmarshal([]byte(value), &stored); err != nil {
+            s.logger.Warnf("Skipping malformed ip ban %s: %v", key, err)
+            continue
+        }
+
+        bans = append(bans, &models.IPBan{
+            CIDR:      strings.TrimPrefix(key, ipBanKeyPrefix),
+            Reason:    stored.Reason,
+            Source:    stored.Source,
+            CreatedAt: stored.CreatedAt,
+            ExpiresAt: time.Now().UTC().Add(ttl).Truncate(time.Second),
+        })
+    }
+
+    return bans, nil
+}
+
+// IsBanned reports whether ip falls in any cached ban range.
+func (s *IPBanService) IsBanned(ip string) bool {
+    parsed := net.ParseIP(ip)
+    if parsed == nil {
+        return false
+    }
+
+    now := time.Now()
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+
+    for _, ban := range s.bans {
+        if now.Before(ban.expiresAt) && ban.network.Contains(parsed) {
+            return true
+        }
+    }
+    return false
+}
+
+// Refresh reloads the in-process ban list from Redis.
+func (s *IPBanService) Refresh(ctx context.Context) error {
+    bans, err := s.List(ctx)
+    if err != nil {
+        return err
+    }
+
+    cached := make([]cachedIPBan, 0, len(bans))
+    for _, ban := range bans {
+        _, network, err := net.ParseCIDR(ban.CIDR)
+        if err != nil {
+            continue
+        }
+        cached = append(cached, cachedIPBan{network: network, expiresAt: ban.ExpiresAt})
+    }
+
+    s.mu.Lock()
+    s.bans = cached
+    s.mu.Unlock()
+
+    return nil
+}
+
+// RunRefresh refreshes the cached ban list every interval until ctx is
+// cancelled, so bans made on other instances take effect here too.
+func (s *IPBanService) RunRefresh(ctx context.Context, interval time.Duration) {
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+
+    for {
+        if err := s.Refresh(ctx); err != nil {
+            s.logger.Errorf("Failed to refresh ip bans: %v", err)
+        }
+
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+        }
+    }
+}
+
+// ParseIPOrCIDR accepts a bare IPv4/IPv6 address or a CIDR range and returns
+// the normalized network.
+func ParseIPOrCIDR(value string) (*net.IPNet, error) {
+    value = strings.TrimSpace(value)
+
+    if strings.Contains(value, "/") {
+        _, network, err := net.ParseCIDR(value)
+        if err != nil {
+            return nil, ErrInvalidCIDR
+        }
+        return network, nil
+    }
+
+    ip := net.ParseIP(value)
+    if ip == nil {
+        return nil, ErrInvalidCIDR
+    }
+    if v4 := ip.To4(); v4 != nil {
+        return &net.IPNet{IP: v4, Mask: net.CIDRMask(32, 32)}, nil
+    }
+    return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIPOrCIDR(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"10.0.0.1", "10.0.0.1/32", false},
+		{" 10.0.0.1 ", "10.0.0.1/32", false},
+		{"10.0.0.7/24", "10.0.0.0/24", false},
+		{"2001:db8::1", "2001:db8::1/128", false},
+		{"2001:db8::/32", "2001:db8::/32", false},
+		{"not-an-ip", "", true},
+		{"10.0.0.0/33", "", true},
+	}
+
+	for _, tt := range tests {
+		network, err := ParseIPOrCIDR(tt.input)
+		if tt.wantErr {
+			assert.Equal(t, ErrInvalidCIDR, err, tt.input)
+			continue
+		}
+		require.NoError(t, err, tt.input)
+		assert.Equal(t, tt.want, network.String(), tt.input)
+	}
+}
+
+func TestIPBanService_IsBanned(t *testing.T) {
+	s := &IPBanService{}
+
+	rangeBan, err := ParseIPOrCIDR("192.168.0.0/16")
+	require.NoError(t, err)
+	expiredBan, err := ParseIPOrCIDR("10.0.0.1")
+	require.NoError(t, err)
+
+	s.bans = []cachedIPBan{
+		{network: rangeBan, expiresAt: time.Now().Add(time.Hour)},
+		{network: expiredBan, expiresAt: time.Now().Add(-time.Second)},
+	}
+
+	assert.True(t, s.IsBanned("192.168.4.20"))
+	assert.False(t, s.IsBanned("192.169.0.1"))
+	assert.False(t, s.IsBanned("10.0.0.1"))
+	assert.False(t, s.IsBanned("garbage"))
+}
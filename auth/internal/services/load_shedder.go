@@ -0,0 +1,126 @@
+package services
+
+import (
+    "context"
+    "sync"
+    "sync/atomic"
+    "time"
+
+    "auth-service/internal/config"
+    "auth-service/internal/metrics"
+
+    "go.uber.org/zap"
+)
+
+const (
+    // Weight of the newest probe in a dependency's smoothed latency
+    latencySmoothing = 0.3
+    // Probes slower than this are abandoned and count as this slow
+    probeTimeout = 2 * time.Second
+)
+
+// Reasons a request is shed, also used as metric labels
+const (
+    ShedReasonInFlight = "inflight"
+    ShedReasonLatency  = "latency"
+)
+
+// Probe checks one dependency, e.g. by pinging it.
+type Probe func(ctx context.Context) error
+
+// LoadShedder tracks how busy the service is: requests in flight and the
+// smoothed latency of its dependencies. Low-priority endpoints ask it
+// whether to turn a request away so that logins and refreshes keep working
+// during traffic spikes.
+type LoadShedder struct {
+    limits   config.LoadShedding
+    logger   *zap.SugaredLogger
+    inFlight atomic.Int64
+
+    mu        sync.Mutex
+    latencies map[string]time.Duration
+}
+
+func NewLoadShedder(limits config.LoadShedding, logger *zap.SugaredLogger) *LoadShedder {
+    return &LoadShedder{
+        limits:    limits,
+        logger:    logger,
+        latencies: make(map[string]time.Duration),
+    }
+}
+
+// RetryAfter is how long shed clients are asked to wait.
+func (s *LoadShedder) RetryAfter() time.Duration {
+    return s.limits.RetryAfter
+}
+
+// Begin counts a request as in flight until the returned func is called.
+func (s *LoadShedder) Begin() func() {
+    metrics.InFlightRequests.Set(float64(s.inFlight.Add(1)))
+    return func() {
+        metrics.InFlightRequests.Set(float64(s.inFlight.Add(-1)))
+    }
+}
+
+// Observe folds a dependency's latency into its smoothed value.
+func (s *LoadShedder) Observe(dependency string, latency time.Duration) {
+    s.mu.Lock()
+    smoothed, ok := s.latencies[dependency]
+    if ok {
+        smoothed = time.Duration(latencySmoothing*float64(latency) + (1-latencySmoothing)*float64(smoothed))
+    } else {
+        smoothed = latency
+    }
+    s.latencies[dependency] = smoothed
+    s.mu.Unlock()
+
+    metrics.DependencyLatency.WithLabelValues(dependency).Set(smoothed.Seconds())
+}
+
+// Saturated reports whether low-priority requests should be shed right now,
+// and why. The request asking is counted as in flight itself.
+func (s *LoadShedder) Saturated() (bool, string) {
+    if s.limits.MaxInFlight > 0 && s.inFlight.Load() > int64(s.limits.MaxInFlight) {
+        return true, ShedReasonInFlight
+    }
+    if s.limits.MaxLatency > 0 {
+        s.mu.Lock()
+        defer s.mu.Unlock()
+        for _, latency := range s.latencies {
+            if latency > s.limits.MaxLatency {
+                return true, ShedReasonLatency
+            }
+        }
+    }
+    return false, ""
+}
+
+// RunProbes runs each probe every interval until ctx is cancelled and
+// observes how long it took. Failed probes are logged but not observed;
+// outages are handled by the readiness check and degraded mode, not here.
+func (s *LoadShedder) RunProbes(ctx context.Context, interval time.Duration, probes map[string]Probe) {
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+            for dependency, probe := range probes {
+                probeCtx, cancel := context.WithTimeout(ctx, probeTimeout)
+                start := time.Now()
+                err := probe(probeCtx)
+                latency := time.Since(start)
+                timedOut := probeCtx.Err() != nil
+                cancel()
+
+                if err != nil && !timedOut {
+                    s.logger.Debugf("Latency probe for %s failed: %v", dependency, err)
+                    continue
+                }
+                s.Observe(dependency, latency)
+            }
+        }
+    }
+}
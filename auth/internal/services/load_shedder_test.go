@@ -0,0 +1,76 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"auth-service/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestLoadShedder_Saturated(t *testing.T) {
+	shedder := NewLoadShedder(config.LoadShedding{MaxInFlight: 1, MaxLatency: 100 * time.Millisecond}, zap.NewNop().Sugar())
+
+	done := shedder.Begin()
+	saturated, _ := shedder.Saturated()
+	assert.False(t, saturated)
+
+	second := shedder.Begin()
+	saturated, reason := shedder.Saturated()
+	assert.True(t, saturated)
+	assert.Equal(t, ShedReasonInFlight, reason)
+	second()
+	done()
+
+	shedder.Observe("postgres", 300*time.Millisecond)
+	saturated, reason = shedder.Saturated()
+	assert.True(t, saturated)
+	assert.Equal(t, ShedReasonLatency, reason)
+
+	// Latency is smoothed, so one fast probe doesn't clear it
+	shedder.Observe("postgres", 10*time.Millisecond)
+	saturated, _ = shedder.Saturated()
+	assert.True(t, saturated)
+	for i := 0; i < 5; i++ {
+		shedder.Observe("postgres", 10*time.Millisecond)
+	}
+	saturated, _ = shedder.Saturated()
+	assert.False(t, saturated)
+}
+
+func TestLoadShedder_Disabled(t *testing.T) {
+	shedder := NewLoadShedder(config.LoadShedding{}, zap.NewNop().Sugar())
+	defer shedder.Begin()()
+
+	shedder.Observe("redis", time.Minute)
+	saturated, _ := shedder.Saturated()
+	assert.False(t, saturated)
+}
+
+func TestLoadShedder_RunProbes(t *testing.T) {
+	shedder := NewLoadShedder(config.LoadShedding{MaxLatency: time.Millisecond}, zap.NewNop().Sugar())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go shedder.RunProbes(ctx, 5*time.Millisecond, map[string]Probe{
+		"slow": func(ctx context.Context) error {
+			time.Sleep(20 * time.Millisecond)
+			return nil
+		},
+		"down": func(ctx context.Context) error { return errors.New("connection refused") },
+	})
+
+	assert.Eventually(t, func() bool {
+		saturated, _ := shedder.Saturated()
+		return saturated
+	}, time.Second, 10*time.Millisecond)
+
+	shedder.mu.Lock()
+	_, observed := shedder.latencies["down"]
+	shedder.mu.Unlock()
+	assert.False(t, observed)
+}
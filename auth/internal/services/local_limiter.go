@@ -0,0 +1,101 @@
+package services
+
+import (
+    "sync"
+    "time"
+)
+
+// Every this many calls, localLimiter drops expired counters and locks
+const localLimiterSweepEvery = 1000
+
+// localLimiter counts failures and holds locks in process memory. The
+// guards and per-user limits fall back to it while Redis is unavailable, at
+// degradedLimit of their usual thresholds since each instance only sees its
+// own share of the traffic.
+type localLimiter struct {
+    mu      sync.Mutex
+    entries map[string]*localEntry
+    now     func() time.Time
+    calls   int
+}
+
+type localEntry struct {
+    count     int64
+    expiresAt time.Time
+}
+
+func newLocalLimiter() *localLimiter {
+    return &localLimiter{
+        entries: make(map[string]*localEntry),
+        now:     time.Now,
+    }
+}
+
+// Incr counts one event against key and returns the count in the current
+// window, which starts with the first event and lasts window.
+func (l *localLimiter) Incr(key string, window time.Duration) int64 {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+
+    now := l.now()
+    l.sweep(now)
+
+    entry, ok := l.entries[key]
+    if !ok || !now.Before(entry.expiresAt) {
+        entry = &localEntry{expiresAt: now.Add(window)}
+        l.entries[key] = entry
+    }
+    entry.count++
+    return entry.count
+}
+
+// Lock sets key for ttl.
+func (l *localLimiter) Lock(key string, ttl time.Duration) {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+
+    l.entries[key] = &localEntry{count: 1, expiresAt: l.now().Add(ttl)}
+}
+
+// TTL returns how long key has left, zero if it isn't set.
+func (l *localLimiter) TTL(key string) time.Duration {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+
+    entry, ok := l.entries[key]
+    if !ok {
+        return 0
+    }
+    if ttl := entry.expiresAt.Sub(l.now()); ttl > 0 {
+        return ttl
+    }
+    return 0
+}
+
+func (l *localLimiter) Delete(key string) {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+
+    delete(l.entries, key)
+}
+
+func (l *localLimiter) sweep(now time.Time) {
+    l.calls++
+    if l.calls%localLimiterSweepEvery != 0 {
+        return
+    }
+    for key, entry := range l.entries {
+        if !now.Before(entry.expiresAt) {
+            delete(l.entries, key)
+        }
+    }
+}
+
+// degradedLimit halves a threshold for use with localLimiter, never going
+// below one.
+func degradedLimit(limit int) int {
+    if limit /= 2; limit < 1 {
+        return 1
+    }
+    return limit
+}
@@ -0,0 +1,34 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalLimiter(t *testing.T) {
+	l := newLocalLimiter()
+	now := time.Now()
+	l.now = func() time.Time { return now }
+
+	assert.Equal(t, int64(1), l.Incr("a", time.Minute))
+	assert.Equal(t, int64(2), l.Incr("a", time.Minute))
+	assert.Equal(t, int64(1), l.Incr("b", time.Minute))
+
+	// A new window starts once the old one is over
+	now = now.Add(time.Minute)
+	assert.Equal(t, int64(1), l.Incr("a", time.Minute))
+
+	l.Lock("a:locked", 15*time.Minute)
+	assert.Equal(t, 15*time.Minute, l.TTL("a:locked"))
+	now = now.Add(15 * time.Minute)
+	assert.Zero(t, l.TTL("a:locked"))
+	assert.Zero(t, l.TTL("missing"))
+}
+
+func TestDegradedLimit(t *testing.T) {
+	assert.Equal(t, 2, degradedLimit(5))
+	assert.Equal(t, 10, degradedLimit(20))
+	assert.Equal(t, 1, degradedLimit(1))
+}
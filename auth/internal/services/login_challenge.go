@@ -0,0 +1,371 @@
+package services
+
+import (
+    "context"
+    "crypto/rand"
+    "crypto/subtle"
+    "fmt"
+    "math/big"
+    "time"
+
+    "auth-service/internal/apperr"
+    "auth-service/internal/metrics"
+    "auth-service/internal/models"
+
+    "github.com/google/uuid"
+    "github.com/jackc/pgx/v5"
+)
+
+const (
+    // How long a login has to pass all of its challenges
+    loginChallengeExpiry = 10 * time.Minute
+    // Wrong answers allowed per challenged login before it is abandoned
+    maxChallengeFailures = 5
+)
+
+var (
+    ErrChallengeFailed     = apperr.New(apperr.Unauthenticated, "Challenge failed", nil)
+    ErrChallengeOutOfOrder = apperr.New(apperr.Conflict, "Answer the challenges in order", nil)
+)
+
+// ChallengeRequiredError is returned by Login when the login has to pass
+// challenges before tokens are issued. CredentialsInvalid is set when the
+// password was wrong but a captcha must be solved before that is revealed;
+// it is for counting the failure and must not be shown to the client.
+type ChallengeRequiredError struct {
+    Challenge          *models.LoginChallenge
+    CredentialsInvalid bool
+}
+
+func (e *ChallengeRequiredError) Error() string {
+    return "login challenge required"
+}
+
+// ChallengeFailedError is returned by AnswerChallenge for a wrong answer and
+// unwraps to ErrChallengeFailed. Email is the challenged login's, so wrong
+// MFA answers can be counted against the account like wrong passwords.
+type ChallengeFailedError struct {
+    Email string
+}
+
+func (e *ChallengeFailedError) Error() string {
+    return ErrChallengeFailed.Error()
+}
+
+func (e *ChallengeFailedError) Unwrap() error {
+    return ErrChallengeFailed
+}
+
+// IsMFAChallenge reports whether challengeType proves a second factor, so
+// that wrong answers count towards the login lockout.
+func IsMFAChallenge(challengeType string) bool {
+    return challengeType == models.ChallengeEmailCode || challengeType == models.ChallengeTOTP
+}
+
+// SetCaptchaVerifier enables the captcha challenge for logins from accounts
+// or IPs with recent failures.
+func (s *AuthService) SetCaptchaVerifier(verifier CaptchaVerifier) {
+    s.captcha = verifier
+}
+
+// captchaRequired reports whether the account or IP has failed enough logins
+// recently to be asked for a captcha. It fails open if Redis is unavailable.
+func (s *AuthService) captchaRequired(ctx context.Context, ip, email string) bool {
+    if s.captcha == nil || s.config.CaptchaAfterFailures <= 0 {
+        return false
+    }
+
+    for _, scope := range loginScopes(ip, email) {
+        value, err := s.redis.Get(ctx, scope.key+":failures")
+        if err != nil {
+            continue
+        }
+        var failures int
+        if _, err := fmt.Sscan(value, &failures); err == nil && failures >= s.config.CaptchaAfterFailures {
+            return true
+        }
+    }
+    return false
+}
+
+// loginChallenges lists the challenges a login with the right password must
+// pass, in the order they are asked for.
+func (s *AuthService) loginChallenges(ctx context.Context, user *models.User, totpEnabled bool, tosAccepted *string, userAgent string) ([]string, error) {
+    var challenges []string
+
+    if s.config.EmailCodeNewDevice && user.EmailVerified {
+        newDevice, err := s.isNewDevice(ctx, user.ID, userAgent)
+        if err != nil {
+            return nil, err
+        }
+        if newDevice {
+            trusted, err := s.isTrustedDevice(ctx, user.ID, userAgent)
+            if err != nil {
+                return nil, err
+            }
+            if !trusted {
+                challenges = append(challenges, models.ChallengeEmailCode)
+            }
+        }
+    }
+
+    if totpEnabled {
+        challenges = append(challenges, models.ChallengeTOTP)
+    }
+
+    if s.config.TOSVersion != "" && stringValue(tosAccepted) != s.config.TOSVersion {
+        challenges = append(challenges, models.ChallengeTOS)
+    }
+
+    return challenges, nil
+}
+
+// startChallenge records a challenged login and returns the error that hands
+// its token to the client. user is nil when the credentials were invalid.
+func (s *AuthService) startChallenge(ctx context.Context, user *models.User, email string, challenges []string) error {
+    token := generateToken()
+    challenge := &models.LoginChallenge{
+        Token:      token,
+        Challenges: challenges,
+        ExpiresAt:  s.now().Add(loginChallengeExpiry),
+    }
+
+    var userID *uuid.UUID
+    if user != nil {
+        userID = &user.ID
+    }
+
+    var codeHash *string
+    code := ""
+    if challenges[0] == models.ChallengeEmailCode {
+        code = generateEmailCode()
+        hash := hashToken(code)
+        codeHash = &hash
+    }
+
+    _, err := s.db.Pool().Exec(ctx,
+        `INSERT INTO login_challenges (token_hash, user_id, email, password_ok, pending, email_code_hash, expires_at)
+         VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+        hashToken(token), userID, email, user != nil, challenges, codeHash, challenge.ExpiresAt,
+    )
+    if err != nil {
+        return fmt.Errorf("create login challenge: %w", err)
+    }
+
+    if code != "" {
+        s.sendLoginCode(ctx, email, code)
+    }
+
+    return &ChallengeRequiredError{Challenge: challenge, CredentialsInvalid: user == nil}
+}
+
+// AnswerChallenge checks the answer to a challenged login's current
+// challenge. While challenges remain it returns the next one; once all are
+// passed the login completes and its user and session are returned. Too many
+// wrong answers abandon the login.
+func (s *AuthService) AnswerChallenge(ctx context.Context, token, challengeType, answer, userAgent, ip, clientType string) (*models.User, *models.Session, *models.LoginChallenge, error) {
+    tx, err := s.db.Pool().Begin(ctx)
+    if err != nil {
+        return nil, nil, nil, fmt.Errorf("begin transaction: %w", err)
+    }
+    defer tx.Rollback(ctx)
+
+    var (
+        id         uuid.UUID
+        userID     *uuid.UUID
+        email      string
+        passwordOK bool
+        pending    []string
+        codeHash   *string
+        failures   int
+        expiresAt  time.Time
+    )
+    err = tx.QueryRow(ctx,
+        `SELECT id, user_id, email, password_ok, pending, email_code_hash, failed_attempts, expires_at
+         FROM login_challenges WHERE token_hash = $1 AND expires_at > $2
+         FOR UPDATE`,
+        hashToken(token), s.now(),
+    ).Scan(&id, &userID, &email, &passwordOK, &pending, &codeHash, &failures, &expiresAt)
+    if err != nil {
+        if err == pgx.ErrNoRows {
+            return nil, nil, nil, ErrInvalidToken
+        }
+        return nil, nil, nil, fmt.Errorf("get login challenge: %w", err)
+    }
+
+    current := &models.LoginChallenge{Token: token, Challenges: pending, ExpiresAt: expiresAt}
+    if len(pending) == 0 || pending[0] != challengeType {
+        return nil, nil, current, ErrChallengeOutOfOrder
+    }
+
+    passed, err := s.checkChallenge(ctx, tx, challengeType, answer, userID, codeHash, ip)
+    if err != nil {
+        return nil, nil, nil, err
+    }
+    if !passed {
+        failures++
+        if failures >= maxChallengeFailures {
+            _, err = tx.Exec(ctx, "DELETE FROM login_challenges WHERE id = $1", id)
+        } else {
+            _, err = tx.Exec(ctx, "UPDATE login_challenges SET failed_attempts = $2 WHERE id = $1", id, failures)
+        }
+        if err != nil {
+            return nil, nil, nil, fmt.Errorf("record challenge failure: %w", err)
+        }
+        if err := tx.Commit(ctx); err != nil {
+            return nil, nil, nil, fmt.Errorf("commit transaction: %w", err)
+        }
+        if reason := challengeFailureReason(challengeType); reason != "" {
+            s.RecordLoginFailure(ctx, reason, userID, userAgent, ip, clientType)
+        }
+        return nil, nil, nil, &ChallengeFailedError{Email: email}
+    }
+
+    pending = pending[1:]
+    if !passwordOK || len(pending) == 0 {
+        if _, err := tx.Exec(ctx, "DELETE FROM login_challenges WHERE id = $1", id); err != nil {
+            return nil, nil, nil, fmt.Errorf("delete login challenge: %w", err)
+        }
+        if err := tx.Commit(ctx); err != nil {
+            return nil, nil, nil, fmt.Errorf("commit transaction: %w", err)
+        }
+        // Only now that the captcha is solved is a wrong password revealed
+        if !passwordOK {
+            return nil, nil, nil, ErrInvalidCredentials
+        }
+
+        user, err := scanUser(s.db.Pool().QueryRow(ctx,
+            `SELECT `+userColumns+` FROM users WHERE id = $1`,
+            *userID,
+        ))
+        if err != nil {
+            if err == pgx.ErrNoRows {
+                return nil, nil, nil, ErrInvalidToken
+            }
+            return nil, nil, nil, fmt.Errorf("get user: %w", err)
+        }
+
+        session, err := s.completeLogin(ctx, user, userAgent, ip, clientType)
+        if err != nil {
+            return nil, nil, nil, err
+        }
+        return user, session, nil, nil
+    }
+
+    code := ""
+    codeHash = nil
+    if pending[0] == models.ChallengeEmailCode {
+        code = generateEmailCode()
+        hash := hashToken(code)
+        codeHash = &hash
+    }
+    _, err = tx.Exec(ctx,
+        `UPDATE login_challenges SET pending = $2, email_code_hash = COALESCE($3, email_code_hash) WHERE id = $1`,
+        id, pending, codeHash,
+    )
+    if err != nil {
+        return nil, nil, nil, fmt.Errorf("update login challenge: %w", err)
+    }
+    if err := tx.Commit(ctx); err != nil {
+        return nil, nil, nil, fmt.Errorf("commit transaction: %w", err)
+    }
+
+    if code != "" {
+        s.sendLoginCode(ctx, email, code)
+    }
+
+    current.Challenges = pending
+    return nil, nil, current, nil
+}
+
+// AbandonChallenges deletes the outstanding challenged logins for email, so
+// a locked out account can't keep answering challenges it was given before.
+func (s *AuthService) AbandonChallenges(ctx context.Context, email string) error {
+    _, err := s.db.Pool().Exec(ctx, "DELETE FROM login_challenges WHERE LOWER(email) = LOWER($1)", email)
+    if err != nil {
+        return fmt.Errorf("abandon login challenges: %w", err)
+    }
+    return nil
+}
+
+// challengeFailureReason is the login failure reason recorded for a wrong
+// answer to challengeType, or "" for challenges that don't fail a login
+// attempt by themselves.
+func challengeFailureReason(challengeType string) string {
+    switch {
+    case IsMFAChallenge(challengeType):
+        return metrics.LoginFailureMFAFailed
+    case challengeType == models.ChallengeCaptcha:
+        return metrics.LoginFailureCaptchaFailed
+    }
+    return ""
+}
+
+func (s *AuthService) checkChallenge(ctx context.Context, tx pgx.Tx, challengeType, answer string, userID *uuid.UUID, codeHash *string, ip string) (bool, error) {
+    switch challengeType {
+    case models.ChallengeCaptcha:
+        if s.captcha == nil {
+            // Captchas were turned off after this login was challenged
+            return true, nil
+        }
+        return s.captcha.Verify(ctx, answer, ip)
+
+    case models.ChallengeEmailCode:
+        if codeHash == nil {
+            return false, nil
+        }
+        return subtle.ConstantTimeCompare([]byte(hashToken(answer)), []byte(*codeHash)) == 1, nil
+
+    case models.ChallengeTOTP:
+        var secret *string
+        var lastStep *int64
+        err := tx.QueryRow(ctx,
+            "SELECT totp_secret, totp_last_step FROM users WHERE id = $1 FOR UPDATE",
+            *userID,
+        ).Scan(&secret, &lastStep)
+        if err != nil {
+            return false, fmt.Errorf("get totp secret: %w", err)
+        }
+        if secret == nil {
+            // TOTP was disabled after this login was challenged
+            return true, nil
+        }
+        var last int64
+        if lastStep != nil {
+            last = *lastStep
+        }
+        step, ok := verifyTOTP(*secret, answer, s.now(), last)
+        if !ok {
+            return false, nil
+        }
+        if _, err := tx.Exec(ctx, "UPDATE users SET totp_last_step = $2 WHERE id = $1", *userID, step); err != nil {
+            return false, fmt.Errorf("record totp step: %w", err)
+        }
+        return true, nil
+
+    case models.ChallengeTOS:
+        if answer != s.config.TOSVersion {
+            return false, nil
+        }
+        _, err := tx.Exec(ctx,
+            "UPDATE users SET tos_accepted_version = $2, tos_accepted_at = NOW() WHERE id = $1",
+            *userID, answer,
+        )
+        if err != nil {
+            return false, fmt.Errorf("record tos acceptance: %w", err)
+        }
+        return true, nil
+    }
+
+    return false, nil
+}
+
+func (s *AuthService) sendLoginCode(ctx context.Context, email, code string) {
+    sendEmail(ctx, s.mailer, s.logger, emailTemplateLoginCode, email, map[string]string{"code": code})
+}
+
+// generateEmailCode returns a random six digit code.
+func generateEmailCode() string {
+    n, _ := rand.Int(rand.Reader, big.NewInt(1000000))
+    return fmt.Sprintf("%06d", n.Int64())
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+    "context"
+    "fmt"
+    "time"
+
+    "auth-service/internal/config"
+    "auth-service/internal/database"
+    "auth-service/internal/metrics"
+    "auth-service/internal/models"
+
+    "go.uber.org/zap"
+)
+
+// LoginFailureService records why logins fail. Reasons are counted in
+// Prometheus and appended to the login_failures audit log for the admin
+// stats API, but never returned to clients, who mustn't learn whether an
+// email has an account.
+type LoginFailureService struct {
+    db     *database.DB
+    alerts *OpsAlertService
+    logger *zap.SugaredLogger
+}
+
+func NewLoginFailureService(db *database.DB, logger *zap.SugaredLogger) *LoginFailureService {
+    return &LoginFailureService{
+        db:     db,
+        logger: logger,
+    }
+}
+
+// Record notes a failed login. Failures are logged, never returned, so
+// analytics can't break the login being refused.
+func (s *LoginFailureService) Record(ctx context.Context, failure *models.LoginFailure) {
+    metrics.LoginFailures.WithLabelValues(failure.Reason, failure.ClientType).Inc()
+
+    _, err := s.db.Pool().Exec(ctx,
+        `INSERT INTO login_failures (user_id, reason, client_type, ip, user_agent) VALUES ($1, $2, $3, $4, $5)`,
+        failure.UserID, failure.Reason, failure.ClientType, failure.IP, failure.UserAgent,
+    )
+    if err != nil {
+        s.logger.Errorf("Failed to record login failure %s: %v", failure.Reason, err)
+    }
+
+    s.alerts.Count(ctx, config.AlertFailedLoginSpike, func(threshold config.AlertThreshold) *OpsAlert {
+        return &OpsAlert{
+            Name:     config.AlertFailedLoginSpike,
+            Severity: SeverityHigh,
+            Title:    "Failed login spike",
+            Message:  fmt.Sprintf("%d failed logins within %s", threshold.Count, threshold.Window),
+            Fields: map[string]string{
+                "last_reason": failure.Reason,
+                "last_ip":     failure.IP,
+            },
+        }
+    })
+}
+
+// SetAlerts alerts the security team when failed logins spike.
+func (s *LoginFailureService) SetAlerts(alerts *OpsAlertService) {
+    s.alerts = alerts
+}
+
+// DailyStats counts failures by UTC day and reason for the given inclusive
+// date range.
+func (s *LoginFailureService) DailyStats(ctx context.Context, from, to time.Time) ([]*models.LoginFailureStat, error) {
+    rows, err := s.db.Pool().Query(ctx,
+        `SELECT created_at::date AS day, reason, COUNT(*) FROM login_failures
+         WHERE created_at >= $1::date AND created_at < $2::date + 1
+         GROUP BY day, reason
+         ORDER BY day, reason`,
+        from.UTC(), to.UTC(),
+    )
+    if err != nil {
+        return nil, fmt.Errorf("list login failure stats: %w", err)
+    }
+    defer rows.Close()
+
+    stats := []*models.LoginFailureStat{}
+    for rows.Next() {
+        st := &models.LoginFailureStat{}
+        if err := rows.Scan(&st.Day, &st.Reason, &st.Count); err != nil {
+            return nil, fmt.Errorf("scan login failure stat: %w", err)
+        }
+        stats = append(stats, st)
+    }
+
+    return stats, rows.Err()
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"auth-service/internal/metrics"
+	"auth-service/internal/models"
+	"auth-service/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoginFailureService_RecordsReasons(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	loginFailures := NewLoginFailureService(suite.DB.DB, suite.Logger)
+	authService := NewAuthService(suite.DB.DB, suite.Redis.Client, suite.Config, suite.Logger, suite.Events)
+	authService.SetLoginFailures(loginFailures)
+	ctx := context.Background()
+
+	user := suite.CreateTestUser(t, test.TestData.ValidEmail, test.TestData.ValidUsername, test.TestData.ValidPassword)
+
+	_, _, err := authService.Login(ctx, &models.LoginRequest{Email: "nobody@example.com", Password: "whatever"}, "agent", "10.0.0.1", "web")
+	assert.Equal(t, ErrInvalidCredentials, err)
+	_, _, err = authService.Login(ctx, &models.LoginRequest{Email: test.TestData.ValidEmail, Password: "wrong-password"}, "agent", "10.0.0.1", "web")
+	assert.Equal(t, ErrInvalidCredentials, err)
+	authService.RecordLoginFailure(ctx, metrics.LoginFailureLocked, nil, "agent", "10.0.0.1", "ios")
+
+	var userID *string
+	err = suite.DB.DB.Pool().QueryRow(ctx,
+		"SELECT user_id::text FROM login_failures WHERE reason = $1", metrics.LoginFailureBadPassword,
+	).Scan(&userID)
+	require.NoError(t, err)
+	require.NotNil(t, userID)
+	assert.Equal(t, user.ID.String(), *userID)
+
+	today := time.Now().UTC()
+	stats, err := loginFailures.DailyStats(ctx, today, today)
+	require.NoError(t, err)
+
+	counts := map[string]int{}
+	for _, st := range stats {
+		counts[st.Reason] = st.Count
+	}
+	assert.Equal(t, map[string]int{
+		metrics.LoginFailureBadPassword:  1,
+		metrics.LoginFailureLocked:       1,
+		metrics.LoginFailureUnknownEmail: 1,
+	}, counts)
+}
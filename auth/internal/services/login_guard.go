@@ -0,0 +1,171 @@
+package services
+
+import (
+    "context"
+    "fmt"
+    "strings"
+    "time"
+
+    "auth-service/internal/apperr"
+    "auth-service/internal/redis"
+
+    "go.uber.org/zap"
+)
+
+const (
+    loginFailureWindow    = 15 * time.Minute
+    loginAccountThreshold = 5
+    loginIPThreshold      = 20
+    loginLockoutDuration  = 15 * time.Minute
+)
+
+var ErrLoginLocked = apperr.New(apperr.RateLimited, "Too many failed login attempts", nil)
+
+// LoginStatus describes how close a login is to being locked out, so clients
+// can tell the user how many attempts are left or how long to wait.
+type LoginStatus struct {
+    // AttemptsRemaining is the number of failures still allowed before the
+    // tightest scope locks
+    AttemptsRemaining int
+    // LockedFor is the remaining lockout, zero if not locked
+    LockedFor time.Duration
+}
+
+// LoginGuard locks out password guessing. Failed logins are counted per
+// account (email) and per client IP within a window; a scope that reaches its
+// threshold is locked for loginLockoutDuration. A successful login clears the
+// account's count. While Redis is unavailable, failures are counted and locks
+// held in process memory at half the thresholds. A nil LoginGuard counts
+// nothing and never locks.
+type LoginGuard struct {
+    redis  *redis.Client
+    local  *localLimiter
+    logger *zap.SugaredLogger
+}
+
+func NewLoginGuard(redis *redis.Client, logger *zap.SugaredLogger) *LoginGuard {
+    return &LoginGuard{
+        redis:  redis,
+        local:  newLocalLimiter(),
+        logger: logger,
+    }
+}
+
+// Check returns ErrLoginLocked and the remaining lockout if either the account
+// or the IP is currently locked.
+func (g *LoginGuard) Check(ctx context.Context, ip, email string) (LoginStatus, error) {
+    if g == nil {
+        return LoginStatus{}, nil
+    }
+
+    var status LoginStatus
+    for _, scope := range loginScopes(ip, email) {
+        ttl, err := g.redis.TTL(ctx, scope.key+":locked")
+        if err != nil {
+            if err != redis.ErrUnavailable {
+                return LoginStatus{}, fmt.Errorf("check login lock: %w", err)
+            }
+            ttl = g.local.TTL(scope.key + ":locked")
+        }
+        if ttl > status.LockedFor {
+            status.LockedFor = ttl
+        }
+    }
+
+    if status.LockedFor > 0 {
+        return status, ErrLoginLocked
+    }
+    return status, nil
+}
+
+// RecordFailure counts a failed login against the account and the IP, locks
+// any scope that reached its threshold and reports the resulting status.
+func (g *LoginGuard) RecordFailure(ctx context.Context, ip, email string) (LoginStatus, error) {
+    status := LoginStatus{AttemptsRemaining: loginIPThreshold}
+    if g == nil {
+        return status, nil
+    }
+
+    for _, scope := range loginScopes(ip, email) {
+        failures, err := g.redis.Incr(ctx, scope.key+":failures")
+        if err == redis.ErrUnavailable {
+            g.recordLocalFailure(scope, &status)
+            continue
+        }
+        if err != nil {
+            return LoginStatus{}, fmt.Errorf("count login failure: %w", err)
+        }
+        if failures == 1 {
+            if err := g.redis.Expire(ctx, scope.key+":failures", loginFailureWindow); err != nil {
+                return LoginStatus{}, fmt.Errorf("expire login failures: %w", err)
+            }
+        }
+
+        remaining := scope.threshold - int(failures)
+        if remaining <= 0 {
+            remaining = 0
+            if err := g.redis.Set(ctx, scope.key+":locked", "1", loginLockoutDuration); err != nil {
+                return LoginStatus{}, fmt.Errorf("lock login: %w", err)
+            }
+            if err := g.redis.Delete(ctx, scope.key+":failures"); err != nil {
+                return LoginStatus{}, fmt.Errorf("reset login failures: %w", err)
+            }
+            status.LockedFor = loginLockoutDuration
+            g.logger.Warnw("Login locked after repeated failures", "scope", scope.name, "value", scope.value)
+        }
+        if remaining < status.AttemptsRemaining {
+            status.AttemptsRemaining = remaining
+        }
+    }
+
+    return status, nil
+}
+
+// recordLocalFailure is RecordFailure for one scope while Redis is
+// unavailable.
+func (g *LoginGuard) recordLocalFailure(scope loginScope, status *LoginStatus) {
+    threshold := degradedLimit(scope.threshold)
+    remaining := threshold - int(g.local.Incr(scope.key+":failures", loginFailureWindow))
+    if remaining <= 0 {
+        remaining = 0
+        g.local.Lock(scope.key+":locked", loginLockoutDuration)
+        g.local.Delete(scope.key + ":failures")
+        status.LockedFor = loginLockoutDuration
+        g.logger.Warnw("Login locked after repeated failures while Redis is unavailable", "scope", scope.name, "value", scope.value)
+    }
+    if remaining < status.AttemptsRemaining {
+        status.AttemptsRemaining = remaining
+    }
+}
+
+// Reset clears the account's failure count after a successful login. The IP
+// count is left alone so one valid account can't mask guessing at others.
+func (g *LoginGuard) Reset(ctx context.Context, email string) error {
+    if g == nil {
+        return nil
+    }
+
+    g.local.Delete(loginAccountKey(email) + ":failures")
+    if err := g.redis.Delete(ctx, loginAccountKey(email)+":failures"); err != nil && err != redis.ErrUnavailable {
+        return err
+    }
+    return nil
+}
+
+type loginScope struct {
+    name      string
+    value     string
+    key       string
+    threshold int
+}
+
+func loginScopes(ip, email string) []loginScope {
+    return []loginScope{
+        {name: "account", value: email, key: loginAccountKey(email), threshold: loginAccountThreshold},
+        {name: "ip", value: ip, key: "login_guard:ip:" + ip, threshold: loginIPThreshold},
+    }
+}
+
+func loginAccountKey(email string) string {
+    return "login_guard:account:" + strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"auth-service/internal/redis"
+	"auth-service/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestLoginScopes(t *testing.T) {
+	scopes := loginScopes("10.0.0.1", " User@Example.com ")
+
+	assert.Equal(t, "login_guard:account:user@example.com", scopes[0].key)
+	assert.Equal(t, "login_guard:ip:10.0.0.1", scopes[1].key)
+}
+
+func TestLoginGuard_LocksAccount(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	guard := NewLoginGuard(suite.Redis.Client, suite.Logger)
+	ctx := context.Background()
+	email := "locked@example.com"
+
+	for i := 1; i < loginAccountThreshold; i++ {
+		status, err := guard.RecordFailure(ctx, "10.0.0.1", email)
+		require.NoError(t, err)
+		assert.Equal(t, loginAccountThreshold-i, status.AttemptsRemaining)
+		assert.Zero(t, status.LockedFor)
+	}
+
+	status, err := guard.RecordFailure(ctx, "10.0.0.1", email)
+	require.NoError(t, err)
+	assert.Equal(t, 0, status.AttemptsRemaining)
+	assert.Equal(t, loginLockoutDuration, status.LockedFor)
+
+	// Locked from any IP
+	status, err = guard.Check(ctx, "10.0.0.2", email)
+	assert.Equal(t, ErrLoginLocked, err)
+	assert.Greater(t, status.LockedFor.Seconds(), float64(0))
+
+	// Other accounts are unaffected
+	_, err = guard.Check(ctx, "10.0.0.1", "other@example.com")
+	assert.NoError(t, err)
+}
+
+func TestLoginGuard_ResetClearsAccountFailures(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	guard := NewLoginGuard(suite.Redis.Client, suite.Logger)
+	ctx := context.Background()
+	email := "reset@example.com"
+
+	for i := 1; i < loginAccountThreshold; i++ {
+		_, err := guard.RecordFailure(ctx, "10.0.0.1", email)
+		require.NoError(t, err)
+	}
+	require.NoError(t, guard.Reset(ctx, email))
+
+	status, err := guard.RecordFailure(ctx, "10.0.0.1", email)
+	require.NoError(t, err)
+	assert.Equal(t, loginAccountThreshold-1, status.AttemptsRemaining)
+}
+
+func TestLoginGuard_DegradedWithoutRedis(t *testing.T) {
+	// Nothing listens here, so the client starts out unavailable
+	client := redis.New("redis://127.0.0.1:1")
+	defer client.Close()
+	require.False(t, client.Available())
+
+	guard := NewLoginGuard(client, zap.NewNop().Sugar())
+	ctx := context.Background()
+
+	_, err := guard.Check(ctx, "10.0.0.1", "user@example.com")
+	require.NoError(t, err)
+
+	// The account locks at half the usual threshold
+	status, err := guard.RecordFailure(ctx, "10.0.0.1", "user@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, 1, status.AttemptsRemaining)
+
+	status, err = guard.RecordFailure(ctx, "10.0.0.1", "user@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, loginLockoutDuration, status.LockedFor)
+
+	status, err = guard.Check(ctx, "10.0.0.1", "user@example.com")
+	assert.ErrorIs(t, err, ErrLoginLocked)
+	assert.Greater(t, status.LockedFor, time.Duration(0))
+
+	require.NoError(t, guard.Reset(ctx, "other@example.com"))
+}
@@ -0,0 +1,258 @@
+package services
+
+import (
+    "context"
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+
+    "auth-service/internal/database"
+    "auth-service/internal/models"
+    "auth-service/internal/redis"
+
+    "github.com/google/uuid"
+    "github.com/jackc/pgx/v5"
+    "go.uber.org/zap"
+)
+
+const (
+    // Logins recorded since the last flush, as <user id>:count and
+    // <user id>:last (unix millis|ip) fields
+    loginStatsPendingKey = "login_stats:pending"
+    // The pending logins a flush is writing; left behind if it fails, and
+    // written by the next flush before it takes new ones
+    loginStatsFlushingKey = "login_stats:flushing"
+    // Held by the one instance flushing at a time
+    loginStatsLockKey = "login_stats:flush_lock"
+    loginStatsLockTTL = time.Minute
+)
+
+// Counts a login and keeps the latest one, atomically so concurrent logins
+// on different instances are never lost or reordered
+const recordLoginScript = `
+redis.call('HINCRBY', KEYS[1], ARGV[1] .. ':count', 1)
+local last = redis.call('HGET', KEYS[1], ARGV[1] .. ':last')
+if not last or tonumber(string.match(last, '^%d+')) <= tonumber(ARGV[2]) then
+    redis.call('HSET', KEYS[1], ARGV[1] .. ':last', ARGV[2] .. '|' .. ARGV[3])
+end
+return 1
+`
+
+// LoginStatsService keeps users' last_login, last_login_ip and login_count.
+// Logins are buffered in Redis and written to Postgres in one transaction
+// per flush, so a login costs a Redis round trip instead of a row update.
+// A user's first login, and every login while Redis is down, is written
+// straight away.
+type LoginStatsService struct {
+    db     *database.DB
+    redis  *redis.Client
+    logger *zap.SugaredLogger
+}
+
+func NewLoginStatsService(db *database.DB, redis *redis.Client, logger *zap.SugaredLogger) *LoginStatsService {
+    return &LoginStatsService{
+        db:     db,
+        redis:  redis,
+        logger: logger,
+    }
+}
+
+// pendingLogins are the logins of one user buffered since the last flush.
+type pendingLogins struct {
+    count int64
+    at    time.Time
+    ip    string
+}
+
+// Record notes a login at at from ip. Failures are logged, never returned,
+// so bookkeeping can't fail the login.
+func (s *LoginStatsService) Record(ctx context.Context, userID uuid.UUID, ip string, at time.Time, first bool) {
+    if !first {
+        _, err := s.redis.Eval(ctx, recordLoginScript, []string{loginStatsPendingKey},
+            userID.String(), at.UnixMilli(), ip)
+        if err == nil {
+            return
+        }
+        s.logger.Warnf("Failed to buffer login, writing it directly: %v", err)
+    }
+
+    if err := applyLogins(ctx, s.db.Pool(), userID, &pendingLogins{count: 1, at: at, ip: ip}); err != nil {
+        s.logger.Errorf("Failed to record login: %v", err)
+    }
+}
+
+// Flush writes the buffered logins to Postgres and returns how many users
+// they belonged to. Only one instance flushes at a time; the others return
+// 0 until it is done.
+func (s *LoginStatsService) Flush(ctx context.Context) (int, error) {
+    acquired, err := s.redis.SetNX(ctx, loginStatsLockKey, 1, loginStatsLockTTL)
+    if err != nil {
+        return 0, fmt.Errorf("lock login stats flush: %w", err)
+    }
+    if !acquired {
+        return 0, nil
+    }
+    defer func() {
+        if err := s.redis.Delete(ctx, loginStatsLockKey); err != nil {
+            s.logger.Errorf("Failed to release login stats flush lock: %v", err)
+        }
+    }()
+
+    // Finish a failed flush before taking the logins recorded since
+    leftover, err := s.redis.Exists(ctx, loginStatsFlushingKey)
+    if err != nil {
+        return 0, fmt.Errorf("check login stats flush: %w", err)
+    }
+    if !leftover {
+        pending, err := s.redis.Exists(ctx, loginStatsPendingKey)
+        if err != nil {
+            return 0, fmt.Errorf("check pending logins: %w", err)
+        }
+        if !pending {
+            return 0, nil
+        }
+        if err := s.redis.Rename(ctx, loginStatsPendingKey, loginStatsFlushingKey); err != nil {
+            return 0, fmt.Errorf("take pending logins: %w", err)
+        }
+    }
+
+    fields, err := s.redis.HGetAll(ctx, loginStatsFlushingKey)
+    if err != nil {
+        return 0, fmt.Errorf("read pending logins: %w", err)
+    }
+    logins := parsePendingLogins(fields)
+
+    tx, err := s.db.Pool().Begin(ctx)
+    if err != nil {
+        return 0, fmt.Errorf("begin transaction: %w", err)
+    }
+    defer tx.Rollback(ctx)
+
+    for userID, pending := range logins {
+        if err := applyLogins(ctx, tx, userID, pending); err != nil {
+            return 0, err
+        }
+    }
+    if err := tx.Commit(ctx); err != nil {
+        return 0, fmt.Errorf("commit transaction: %w", err)
+    }
+
+    if err := s.redis.Delete(ctx, loginStatsFlushingKey); err != nil {
+        return 0, fmt.Errorf("clear flushed logins: %w", err)
+    }
+    return len(logins), nil
+}
+
+// RunFlush flushes buffered logins every interval until ctx is cancelled.
+func (s *LoginStatsService) RunFlush(ctx context.Context, interval time.Duration) {
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+            if _, err := s.Flush(ctx); err != nil {
+                s.logger.Errorf("Failed to flush login stats: %v", err)
+            }
+        }
+    }
+}
+
+// Get returns the user's login statistics, including logins not flushed
+// yet.
+func (s *LoginStatsService) Get(ctx context.Context, userID uuid.UUID) (*models.LoginStats, error) {
+    stats := &models.LoginStats{}
+    err := s.db.Pool().QueryRow(ctx,
+        "SELECT login_count, last_login, last_login_ip FROM users WHERE id = $1",
+        userID,
+    ).Scan(&stats.LoginCount, &stats.LastLogin, &stats.LastLoginIP)
+    if err != nil {
+        if err == pgx.ErrNoRows {
+            return nil, ErrUserNotFound
+        }
+        return nil, fmt.Errorf("get login stats: %w", err)
+    }
+
+    fields := []string{userID.String() + ":count", userID.String() + ":last"}
+    for _, key := range []string{loginStatsFlushingKey, loginStatsPendingKey} {
+        values, err := s.redis.HMGet(ctx, key, fields...)
+        if err != nil {
+            s.logger.Warnf("Failed to read pending logins: %v", err)
+            break
+        }
+        raw := map[string]string{}
+        for i, value := range values {
+            if value, ok := value.(string); ok {
+                raw[fields[i]] = value
+            }
+        }
+        if pending := parsePendingLogins(raw)[userID]; pending != nil {
+            stats.LoginCount += pending.count
+            if !pending.at.IsZero() && (stats.LastLogin == nil || pending.at.After(*stats.LastLogin)) {
+                stats.LastLogin = &pending.at
+                stats.LastLoginIP = &pending.ip
+            }
+        }
+    }
+    return stats, nil
+}
+
+// parsePendingLogins groups buffered login fields by user. Malformed fields
+// are skipped.
+func parsePendingLogins(fields map[string]string) map[uuid.UUID]*pendingLogins {
+    logins := map[uuid.UUID]*pendingLogins{}
+    for field, value := range fields {
+        id, kind, ok := strings.Cut(field, ":")
+        if !ok {
+            continue
+        }
+        userID, err := uuid.Parse(id)
+        if err != nil {
+            continue
+        }
+        pending := logins[userID]
+        if pending == nil {
+            pending = &pendingLogins{}
+            logins[userID] = pending
+        }
+
+        switch kind {
+        case "count":
+            pending.count, _ = strconv.ParseInt(value, 10, 64)
+        case "last":
+            millis, ip, _ := strings.Cut(value, "|")
+            if ms, err := strconv.ParseInt(millis, 10, 64); err == nil {
+                pending.at = time.UnixMilli(ms)
+                pending.ip = ip
+            }
+        }
+    }
+    return logins
+}
+
+// applyLogins adds a user's logins to their row. An older last login than
+// the one stored, from a flush that lost a race with a direct write, leaves
+// last_login and last_login_ip alone.
+func applyLogins(ctx context.Context, db execer, userID uuid.UUID, pending *pendingLogins) error {
+    var at *time.Time
+    if !pending.at.IsZero() {
+        utc := pending.at.UTC()
+        at = &utc
+    }
+    _, err := db.Exec(ctx,
+        `UPDATE users SET
+             login_count = login_count + $2,
+             last_login_ip = CASE WHEN $3::timestamp IS NOT NULL AND (last_login IS NULL OR last_login <= $3)
+                                  THEN NULLIF($4, '') ELSE last_login_ip END,
+             last_login = GREATEST(last_login, $3)
+         WHERE id = $1`,
+        userID, pending.count, at, pending.ip,
+    )
+    if err != nil {
+        return fmt.Errorf("record logins: %w", err)
+    }
+    return nil
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"auth-service/test"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePendingLogins(t *testing.T) {
+	userID := uuid.New()
+	logins := parsePendingLogins(map[string]string{
+		userID.String() + ":count": "3",
+		userID.String() + ":last":  "1700000000000|10.0.0.1",
+		"not-a-user:count":         "1",
+		"garbage":                  "1",
+	})
+
+	require.Len(t, logins, 1)
+	assert.Equal(t, int64(3), logins[userID].count)
+	assert.Equal(t, time.UnixMilli(1700000000000), logins[userID].at)
+	assert.Equal(t, "10.0.0.1", logins[userID].ip)
+}
+
+func TestLoginStatsService_BufferAndFlush(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	ctx := context.Background()
+	stats := NewLoginStatsService(suite.DB.DB, suite.Redis.Client, suite.Logger)
+	user := suite.CreateTestUser(t, test.TestData.ValidEmail, test.TestData.ValidUsername, test.TestData.ValidPassword)
+
+	// The first login is written straight away, later ones are buffered
+	first := time.Now().UTC().Truncate(time.Millisecond)
+	stats.Record(ctx, user.ID, "10.0.0.1", first, true)
+	stats.Record(ctx, user.ID, "10.0.0.3", first.Add(2*time.Minute), false)
+	stats.Record(ctx, user.ID, "10.0.0.2", first.Add(time.Minute), false)
+
+	var count int64
+	require.NoError(t, suite.DB.Pool().QueryRow(ctx, "SELECT login_count FROM users WHERE id = $1", user.ID).Scan(&count))
+	assert.Equal(t, int64(1), count)
+
+	// Buffered logins already show up for admins
+	got, err := stats.Get(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), got.LoginCount)
+	assert.Equal(t, "10.0.0.3", *got.LastLoginIP)
+
+	flushed, err := stats.Flush(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, flushed)
+
+	var lastLogin time.Time
+	var lastIP string
+	require.NoError(t, suite.DB.Pool().QueryRow(ctx,
+		"SELECT login_count, last_login, last_login_ip FROM users WHERE id = $1", user.ID,
+	).Scan(&count, &lastLogin, &lastIP))
+	assert.Equal(t, int64(3), count)
+	assert.True(t, first.Add(2*time.Minute).Equal(lastLogin))
+	assert.Equal(t, "10.0.0.3", lastIP)
+
+	// Nothing is left to flush or double count
+	flushed, err = stats.Flush(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, flushed)
+	got, err = stats.Get(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), got.LoginCount)
+}
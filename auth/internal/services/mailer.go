@@ -0,0 +1,194 @@
+package services
+
+import (
+    "context"
+    "fmt"
+    "net"
+    "net/smtp"
+    "sort"
+    "strconv"
+    "strings"
+
+    "auth-service/internal/config"
+    "auth-service/internal/database"
+    "auth-service/internal/models"
+
+    "go.uber.org/zap"
+)
+
+// Email templates
+const (
+    emailTemplateVerification      = "verify_email"
+    emailTemplatePasswordReset     = "password_reset"
+    emailTemplateLoginCode         = "login_code"
+    emailTemplateRegistrationCode  = "registration_code"
+    emailTemplateLoginConfirmation = "login_confirmation"
+    emailTemplateRecovery          = "account_recovery"
+    emailTemplateWelcome           = "welcome"
+    emailTemplateSessionsEvicted   = "sessions_evicted"
+)
+
+var emailSubjects = map[string]string{
+    emailTemplateVerification:      "Verify your email address",
+    emailTemplatePasswordReset:     "Reset your password",
+    emailTemplateLoginCode:         "Your login code",
+    emailTemplateRegistrationCode:  "Your registration code",
+    emailTemplateLoginConfirmation: "Confirm your login",
+    emailTemplateRecovery:          "Recover your account",
+    emailTemplateWelcome:           "Welcome to TapIn",
+    emailTemplateSessionsEvicted:   "You were signed out on another device",
+}
+
+const (
+    defaultSentEmailLimit = 50
+    maxSentEmailLimit     = 500
+)
+
+// Mailer delivers templated emails.
+type Mailer interface {
+    Send(ctx context.Context, email *models.Email) error
+}
+
+// newEmail renders template for to. The body lists data as plain text until
+// the templates get real copy.
+func newEmail(template, to string, data map[string]string) *models.Email {
+    if data == nil {
+        data = map[string]string{}
+    }
+
+    keys := make([]string, 0, len(data))
+    for key := range data {
+        keys = append(keys, key)
+    }
+    sort.Strings(keys)
+
+    var body strings.Builder
+    for _, key := range keys {
+        fmt.Fprintf(&body, "%s: %s\n", key, data[key])
+    }
+
+    return &models.Email{
+        To:       to,
+        Template: template,
+        Subject:  emailSubjects[template],
+        Body:     body.String(),
+        Data:     data,
+    }
+}
+
+// sendEmail renders and sends a templated email through mailer, if there is
+// one. Failures are logged rather than returned, so a mail outage doesn't
+// fail the request that triggered the email.
+func sendEmail(ctx context.Context, mailer Mailer, logger *zap.SugaredLogger, template, to string, data map[string]string) {
+    if mailer == nil {
+        return
+    }
+    if err := mailer.Send(ctx, newEmail(template, to, data)); err != nil {
+        logger.Errorf("Failed to send %s email: %v", template, err)
+    }
+}
+
+// smtpMailer sends emails through an SMTP relay.
+type smtpMailer struct {
+    addr string
+    from string
+    auth smtp.Auth
+}
+
+// NewSMTPMailer returns a mailer for the configured SMTP relay, or nil if
+// none is configured.
+func NewSMTPMailer(cfg *config.Config) Mailer {
+    if cfg.SMTPHost == "" {
+        return nil
+    }
+
+    var auth smtp.Auth
+    if cfg.SMTPUser != "" {
+        auth = smtp.PlainAuth("", cfg.SMTPUser, cfg.SMTPPass, cfg.SMTPHost)
+    }
+    return &smtpMailer{
+        addr: net.JoinHostPort(cfg.SMTPHost, strconv.Itoa(cfg.SMTPPort)),
+        from: cfg.EmailFrom,
+        auth: auth,
+    }
+}
+
+func (m *smtpMailer) Send(ctx context.Context, email *models.Email) error {
+    message := "From: " + m.from + "\r\n" +
+        "To: " + email.To + "\r\n" +
+        "Subject: " + email.Subject + "\r\n" +
+        "MIME-Version: 1.0\r\n" +
+        "Content-Type: text/plain; charset=UTF-8\r\n" +
+        "\r\n" +
+        strings.ReplaceAll(email.Body, "\n", "\r\n")
+
+    if err := smtp.SendMail(m.addr, m.auth, m.from, []string{email.To}, []byte(message)); err != nil {
+        return fmt.Errorf("send email: %w", err)
+    }
+    return nil
+}
+
+// SandboxMailer stores emails in sent_emails instead of sending them, so
+// staging and integration tests can follow verification and reset flows end
+// to end without an SMTP server.
+type SandboxMailer struct {
+    db *database.DB
+}
+
+func NewSandboxMailer(db *database.DB) *SandboxMailer {
+    return &SandboxMailer{db: db}
+}
+
+func (m *SandboxMailer) Send(ctx context.Context, email *models.Email) error {
+    _, err := m.db.Pool().Exec(ctx,
+        `INSERT INTO sent_emails (recipient, template, subject, body, data) VALUES ($1, $2, $3, $4, $5)`,
+        email.To, email.Template, email.Subject, email.Body, email.Data,
+    )
+    if err != nil {
+        return fmt.Errorf("store sandboxed email: %w", err)
+    }
+    return nil
+}
+
+// List returns captured emails newest first, optionally narrowed to a
+// recipient and/or template.
+func (m *SandboxMailer) List(ctx context.Context, filter models.SentEmailFilter) ([]*models.SentEmail, error) {
+    if filter.Limit <= 0 {
+        filter.Limit = defaultSentEmailLimit
+    }
+    if filter.Limit > maxSentEmailLimit {
+        filter.Limit = maxSentEmailLimit
+    }
+
+    rows, err := m.db.Pool().Query(ctx,
+        `SELECT id, recipient, template, subject, body, data, created_at FROM sent_emails
+         WHERE ($1 = '' OR recipient = $1) AND ($2 = '' OR template = $2)
+         ORDER BY created_at DESC, id DESC
+         LIMIT $3`,
+        filter.To, filter.Template, filter.Limit,
+    )
+    if err != nil {
+        return nil, fmt.Errorf("list sent emails: %w", err)
+    }
+    defer rows.Close()
+
+    emails := []*models.SentEmail{}
+    for rows.Next() {
+        e := &models.SentEmail{}
+        if err := rows.Scan(&e.ID, &e.To, &e.Template, &e.Subject, &e.Body, &e.Data, &e.CreatedAt); err != nil {
+            return nil, fmt.Errorf("scan sent email: %w", err)
+        }
+        emails = append(emails, e)
+    }
+
+    return emails, rows.Err()
+}
+
+// Clear deletes every captured email and returns how many there were.
+func (m *SandboxMailer) Clear(ctx context.Context) (int64, error) {
+    result, err := m.db.Pool().Exec(ctx, "DELETE FROM sent_emails")
+    if err != nil {
+        return 0, fmt.Errorf("clear sent emails: %w", err)
+    }
+    return result.RowsAffected(), nil
+}
@@ -0,0 +1,47 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"auth-service/internal/models"
+	"auth-service/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewEmail(t *testing.T) {
+	email := newEmail(emailTemplatePasswordReset, "a@example.com", map[string]string{"token": "abc", "expires": "1h"})
+	assert.Equal(t, "Reset your password", email.Subject)
+	assert.Equal(t, "expires: 1h\ntoken: abc\n", email.Body)
+
+	assert.NotNil(t, newEmail(emailTemplateWelcome, "a@example.com", nil).Data)
+}
+
+func TestSandboxMailer_CapturesResetEmail(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	sandbox := NewSandboxMailer(suite.DB.DB)
+	authService := NewAuthService(suite.DB.DB, suite.Redis.Client, suite.Config, suite.Logger, suite.Events)
+	authService.SetMailer(sandbox)
+	ctx := context.Background()
+
+	suite.CreateTestUser(t, test.TestData.ValidEmail, test.TestData.ValidUsername, test.TestData.ValidPassword)
+	require.NoError(t, authService.ForgotPassword(ctx, test.TestData.ValidEmail))
+	// Unknown addresses get no email
+	require.NoError(t, authService.ForgotPassword(ctx, "nobody@example.com"))
+
+	emails, err := sandbox.List(ctx, models.SentEmailFilter{Template: emailTemplatePasswordReset})
+	require.NoError(t, err)
+	require.Len(t, emails, 1)
+	assert.Equal(t, test.TestData.ValidEmail, emails[0].To)
+
+	// The captured token completes the reset
+	require.NoError(t, authService.ResetPassword(ctx, emails[0].Data["token"], "N3w-Passw0rd!"))
+
+	deleted, err := sandbox.Clear(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+}
@@ -0,0 +1,390 @@
+package services
+
+import (
+    "context"
+    "fmt"
+    "time"
+
+    "auth-service/internal/database"
+    "auth-service/internal/models"
+
+    "github.com/google/uuid"
+    "go.uber.org/zap"
+)
+
+const (
+    // Flagged content is checked again after this long, in case the
+    // provider's verdict (or a word list) has changed
+    moderationFlagRecheckDelay = 24 * time.Hour
+    // First retry after the provider failed; doubles per attempt up to
+    // moderationMaxRetryDelay
+    moderationRetryDelay    = time.Minute
+    moderationMaxRetryDelay = time.Hour
+    // Checks are dropped after this many attempts, leaving the content as is
+    maxModerationAttempts = 5
+    defaultDenialLimit    = 50
+)
+
+// Outcome recorded for content the provider couldn't check
+const moderationPending = "pending"
+
+// ContentDeniedError is returned when moderation rejects a username or
+// display name.
+type ContentDeniedError struct {
+    Field  string
+    Reason string
+}
+
+func (e *ContentDeniedError) Error() string {
+    return fmt.Sprintf("%s denied by moderation", e.Field)
+}
+
+// ModerationService runs usernames and display names past the configured
+// ContentModerator. Denied changes are rejected and recorded; flagged ones,
+// and ones the provider couldn't check, are accepted and re-checked in the
+// background, which reverts them if they are denied then.
+type ModerationService struct {
+    db        *database.DB
+    moderator ContentModerator
+    users     *UserService
+    handles   *HandleService
+    guard     *ImpersonationGuard
+    alerts    *OpsAlertService
+    logger    *zap.SugaredLogger
+}
+
+func NewModerationService(db *database.DB, moderator ContentModerator, users *UserService, handles *HandleService, logger *zap.SugaredLogger) *ModerationService {
+    return &ModerationService{
+        db:        db,
+        moderator: moderator,
+        users:     users,
+        handles:   handles,
+        logger:    logger,
+    }
+}
+
+// SetImpersonationGuard screens usernames and display names for lookalikes
+// of protected handles, whether or not a moderator is configured.
+func (s *ModerationService) SetImpersonationGuard(guard *ImpersonationGuard) {
+    s.guard = guard
+}
+
+// SetAlerts alerts the security team whenever content is queued for
+// impersonation review.
+func (s *ModerationService) SetAlerts(alerts *OpsAlertService) {
+    s.alerts = alerts
+}
+
+// Check moderates text about to be stored in field and returns the outcome,
+// which is moderationPending if the provider failed. Denials, by the
+// impersonation guard or the moderator, are recorded against userID (nil
+// before the account exists) and returned as a *ContentDeniedError. A nil
+// service allows everything.
+func (s *ModerationService) Check(ctx context.Context, userID *uuid.UUID, field, text string) (string, error) {
+    if s == nil || text == "" {
+        return models.ModerationAllow, nil
+    }
+
+    if s.guard != nil {
+        if outcome, handle := s.guard.Match(ctx, userID, text); outcome == models.ModerationDeny {
+            reason := "resembles @" + handle
+            s.recordDenial(ctx, userID, field, text, impersonationProvider, reason, models.ModerationSourceChange)
+            return outcome, &ContentDeniedError{Field: field, Reason: reason}
+        }
+    }
+    if s.moderator == nil {
+        return models.ModerationAllow, nil
+    }
+
+    result, err := s.moderator.Moderate(ctx, field, text)
+    if err != nil {
+        s.logger.Warnf("Content moderation unavailable, accepting %s for re-check: %v", field, err)
+        return moderationPending, nil
+    }
+
+    if result.Outcome == models.ModerationDeny {
+        s.recordDenial(ctx, userID, field, text, s.moderator.Name(), result.Reason, models.ModerationSourceChange)
+        return result.Outcome, &ContentDeniedError{Field: field, Reason: result.Reason}
+    }
+    return result.Outcome, nil
+}
+
+// Track queues text, just stored in the user's field, for a re-check if
+// Check didn't allow it outright, replacing any earlier check of the field,
+// and for an admin's review if it resembles a protected handle. Failures are
+// logged; the change itself already went through.
+func (s *ModerationService) Track(ctx context.Context, userID uuid.UUID, field, text, outcome string) {
+    if s == nil {
+        return
+    }
+    s.trackImpersonation(ctx, userID, field, text)
+    if s.moderator == nil {
+        return
+    }
+
+    var err error
+    switch outcome {
+    case models.ModerationFlag, moderationPending:
+        next := time.Now().Add(moderationRetryDelay)
+        if outcome == models.ModerationFlag {
+            next = time.Now().Add(moderationFlagRecheckDelay)
+        }
+        _, err = s.db.Pool().Exec(ctx,
+            `INSERT INTO moderation_checks (user_id, field, content, outcome, next_check_at)
+             VALUES ($1, $2, $3, $4, $5)
+             ON CONFLICT (user_id, field) DO UPDATE SET
+                 content = EXCLUDED.content, outcome = EXCLUDED.outcome, attempts = 0,
+                 next_check_at = EXCLUDED.next_check_at, created_at = NOW()`,
+            userID, field, text, outcome, next,
+        )
+    default:
+        _, err = s.db.Pool().Exec(ctx,
+            "DELETE FROM moderation_checks WHERE user_id = $1 AND field = $2",
+            userID, field,
+        )
+    }
+    if err != nil {
+        s.logger.Errorf("Failed to track moderation of %s for user %s: %v", field, userID, err)
+    }
+}
+
+func (s *ModerationService) recordDenial(ctx context.Context, userID *uuid.UUID, field, text, provider, reason, source string) {
+    s.logger.Infow("Content denied by moderation",
+        "user_id", userID,
+        "field", field,
+        "provider", provider,
+        "reason", reason,
+        "source", source,
+    )
+
+    _, err := s.db.Pool().Exec(ctx,
+        `INSERT INTO moderation_denials (user_id, field, content, provider, reason, source)
+         VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6)`,
+        userID, field, text, provider, reason, source,
+    )
+    if err != nil {
+        s.logger.Errorf("Failed to record moderation denial: %v", err)
+    }
+}
+
+// ListDenials returns recorded denials, newest first.
+func (s *ModerationService) ListDenials(ctx context.Context, filter models.ModerationDenialFilter) ([]*models.ModerationDenial, error) {
+    limit := filter.Limit
+    if limit <= 0 {
+        limit = defaultDenialLimit
+    }
+
+    rows, err := s.db.Pool().Query(ctx,
+        `SELECT id, user_id, field, content, provider, reason, source, created_at
+         FROM moderation_denials
+         WHERE ($1::uuid IS NULL OR user_id = $1)
+         ORDER BY created_at DESC
+         LIMIT $2`,
+        filter.UserID, limit,
+    )
+    if err != nil {
+        return nil, fmt.Errorf("list moderation denials: %w", err)
+    }
+    defer rows.Close()
+
+    denials := []*models.ModerationDenial{}
+    for rows.Next() {
+        d := &models.ModerationDenial{}
+        if err := rows.Scan(&d.ID, &d.UserID, &d.Field, &d.Content, &d.Provider, &d.Reason, &d.Source, &d.CreatedAt); err != nil {
+            return nil, fmt.Errorf("scan moderation denial: %w", err)
+        }
+        denials = append(denials, d)
+    }
+    return denials, rows.Err()
+}
+
+// RunRechecks re-checks due content every interval until ctx is cancelled.
+func (s *ModerationService) RunRechecks(ctx context.Context, interval time.Duration, batchSize int) {
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+            if _, err := s.Recheck(ctx, batchSize); err != nil {
+                s.logger.Errorf("Failed to re-check moderated content: %v", err)
+            }
+        }
+    }
+}
+
+type moderationCheck struct {
+    userID   uuid.UUID
+    field    string
+    content  string
+    attempts int
+}
+
+// Recheck claims up to limit due checks and moderates their content again.
+// It returns how many it claimed. Claims are leased like deletion jobs, so
+// several instances can run it side by side.
+func (s *ModerationService) Recheck(ctx context.Context, limit int) (int, error) {
+    if s.moderator == nil {
+        return 0, nil
+    }
+
+    rows, err := s.db.Pool().Query(ctx,
+        `UPDATE moderation_checks SET next_check_at = $2
+         WHERE (user_id, field) IN (
+             SELECT user_id, field FROM moderation_checks
+             WHERE next_check_at <= NOW()
+             ORDER BY next_check_at
+             LIMIT $1
+             FOR UPDATE SKIP LOCKED
+         )
+         RETURNING user_id, field, content, attempts`,
+        limit, time.Now().Add(moderationMaxRetryDelay),
+    )
+    if err != nil {
+        return 0, fmt.Errorf("claim moderation checks: %w", err)
+    }
+
+    var checks []*moderationCheck
+    for rows.Next() {
+        check := &moderationCheck{}
+        if err := rows.Scan(&check.userID, &check.field, &check.content, &check.attempts); err != nil {
+            rows.Close()
+            return 0, fmt.Errorf("scan moderation check: %w", err)
+        }
+        checks = append(checks, check)
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil {
+        return 0, fmt.Errorf("claim moderation checks: %w", err)
+    }
+
+    for _, check := range checks {
+        if err := s.recheck(ctx, check); err != nil {
+            s.logger.Errorf("Failed to re-check %s for user %s: %v", check.field, check.userID, err)
+        }
+    }
+    return len(checks), nil
+}
+
+func (s *ModerationService) recheck(ctx context.Context, check *moderationCheck) error {
+    current, err := s.currentValue(ctx, check.userID, check.field)
+    if err != nil {
+        return err
+    }
+    // Changed since; the new value was checked on its own
+    if current == nil || *current != check.content {
+        return s.forget(ctx, check)
+    }
+
+    attempts := check.attempts + 1
+    result, err := s.moderator.Moderate(ctx, check.field, check.content)
+    if err != nil {
+        if attempts >= maxModerationAttempts {
+            s.logger.Warnf("Giving up re-checking %s for user %s: %v", check.field, check.userID, err)
+            return s.forget(ctx, check)
+        }
+        return s.reschedule(ctx, check, moderationPending, attempts, retryDelay(attempts))
+    }
+
+    switch result.Outcome {
+    case models.ModerationDeny:
+        if err := s.revert(ctx, check); err != nil {
+            return err
+        }
+        s.recordDenial(ctx, &check.userID, check.field, check.content, s.moderator.Name(), result.Reason, models.ModerationSourceRecheck)
+        return s.forget(ctx, check)
+    case models.ModerationFlag:
+        if attempts >= maxModerationAttempts {
+            return s.forget(ctx, check)
+        }
+        return s.reschedule(ctx, check, models.ModerationFlag, attempts, moderationFlagRecheckDelay)
+    }
+    return s.forget(ctx, check)
+}
+
+// retryDelay backs off exponentially from moderationRetryDelay.
+func retryDelay(attempts int) time.Duration {
+    delay := moderationRetryDelay << uint(attempts)
+    if delay > moderationMaxRetryDelay {
+        return moderationMaxRetryDelay
+    }
+    return delay
+}
+
+func (s *ModerationService) currentValue(ctx context.Context, userID uuid.UUID, field string) (*string, error) {
+    column := "username"
+    if field == models.ModerationFieldDisplayName {
+        column = "display_name"
+    }
+
+    var value *string
+    err := s.db.Pool().QueryRow(ctx, "SELECT "+column+" FROM users WHERE id = $1", userID).Scan(&value)
+    if err != nil {
+        return nil, fmt.Errorf("get %s: %w", field, err)
+    }
+    return value, nil
+}
+
+// revert takes denied content off the account: display names are cleared
+// and usernames replaced with a generated handle.
+func (s *ModerationService) revert(ctx context.Context, check *moderationCheck) error {
+    if check.field == models.ModerationFieldDisplayName {
+        tx, err := s.db.Pool().Begin(ctx)
+        if err != nil {
+            return fmt.Errorf("begin transaction: %w", err)
+        }
+        defer tx.Rollback(ctx)
+
+        result, err := tx.Exec(ctx,
+            `UPDATE users SET display_name = NULL, change_seq = change_seq + 1, updated_at = NOW()
+             WHERE id = $1 AND display_name = $2`,
+            check.userID, check.content,
+        )
+        if err != nil {
+            return fmt.Errorf("clear display name: %w", err)
+        }
+        if result.RowsAffected() == 0 {
+            return nil
+        }
+        if err := enqueueSnapshot(ctx, tx, check.userID); err != nil {
+            return err
+        }
+        if err := tx.Commit(ctx); err != nil {
+            return fmt.Errorf("commit transaction: %w", err)
+        }
+        return nil
+    }
+
+    handle, err := s.handles.Generate(ctx, check.userID.String())
+    if err != nil {
+        return err
+    }
+    // Goes through the regular path so the change event is published
+    return s.users.UpdateProfile(ctx, check.userID, handle)
+}
+
+func (s *ModerationService) reschedule(ctx context.Context, check *moderationCheck, outcome string, attempts int, delay time.Duration) error {
+    _, err := s.db.Pool().Exec(ctx,
+        `UPDATE moderation_checks SET outcome = $3, attempts = $4, next_check_at = $5
+         WHERE user_id = $1 AND field = $2 AND content = $6`,
+        check.userID, check.field, outcome, attempts, time.Now().Add(delay), check.content,
+    )
+    if err != nil {
+        return fmt.Errorf("reschedule moderation check: %w", err)
+    }
+    return nil
+}
+
+// forget drops the check unless a newer change has replaced it meanwhile.
+func (s *ModerationService) forget(ctx context.Context, check *moderationCheck) error {
+    _, err := s.db.Pool().Exec(ctx,
+        "DELETE FROM moderation_checks WHERE user_id = $1 AND field = $2 AND content = $3",
+        check.userID, check.field, check.content,
+    )
+    if err != nil {
+        return fmt.Errorf("delete moderation check: %w", err)
+    }
+    return nil
+}
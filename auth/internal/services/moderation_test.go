@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"auth-service/internal/models"
+	"auth-service/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubModerator returns a fixed outcome per text, or err when set.
+type stubModerator struct {
+	outcomes map[string]string
+	err      error
+}
+
+func (m *stubModerator) Name() string { return "stub" }
+
+func (m *stubModerator) Moderate(ctx context.Context, field, text string) (*models.ModerationResult, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	outcome, ok := m.outcomes[text]
+	if !ok {
+		outcome = models.ModerationAllow
+	}
+	return &models.ModerationResult{Outcome: outcome, Reason: "stub"}, nil
+}
+
+func TestModerationService(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	ctx := context.Background()
+	moderator := &stubModerator{outcomes: map[string]string{
+		"rude_name": models.ModerationDeny,
+		"Iffy Name": models.ModerationFlag,
+	}}
+	userService := NewUserService(suite.DB.DB, suite.Redis.Client, suite.Logger)
+	moderation := NewModerationService(suite.DB.DB, moderator, userService, NewHandleService(suite.DB.DB, suite.Logger), suite.Logger)
+	userService.SetModeration(moderation)
+
+	user := suite.CreateTestUser(t, test.TestData.ValidEmail, test.TestData.ValidUsername, test.TestData.ValidPassword)
+
+	// Denied changes are rejected and recorded
+	err := userService.UpdateProfile(ctx, user.ID, "rude_name")
+	var denied *ContentDeniedError
+	require.ErrorAs(t, err, &denied)
+	assert.Equal(t, models.ModerationFieldUsername, denied.Field)
+
+	current, err := userService.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, test.TestData.ValidUsername, current.Username)
+
+	denials, err := moderation.ListDenials(ctx, models.ModerationDenialFilter{UserID: &user.ID})
+	require.NoError(t, err)
+	require.Len(t, denials, 1)
+	assert.Equal(t, "rude_name", denials[0].Content)
+	assert.Equal(t, models.ModerationSourceChange, denials[0].Source)
+
+	// Flagged content is accepted and queued for a re-check
+	name := "Iffy Name"
+	require.NoError(t, userService.UpdatePublicProfile(ctx, user.ID, &models.PublicProfileRequest{DisplayName: &name}))
+
+	var outcome string
+	err = suite.DB.DB.Pool().QueryRow(ctx,
+		"SELECT outcome FROM moderation_checks WHERE user_id = $1 AND field = $2",
+		user.ID, models.ModerationFieldDisplayName,
+	).Scan(&outcome)
+	require.NoError(t, err)
+	assert.Equal(t, models.ModerationFlag, outcome)
+
+	// Provider outages accept the change and retry soon
+	moderator.err = errors.New("provider down")
+	require.NoError(t, userService.UpdateProfile(ctx, user.ID, "fresh_name"))
+	moderator.err = nil
+
+	err = suite.DB.DB.Pool().QueryRow(ctx,
+		"SELECT outcome FROM moderation_checks WHERE user_id = $1 AND field = $2",
+		user.ID, models.ModerationFieldUsername,
+	).Scan(&outcome)
+	require.NoError(t, err)
+	assert.Equal(t, moderationPending, outcome)
+
+	// A re-check that now denies the display name clears it
+	moderator.outcomes["Iffy Name"] = models.ModerationDeny
+	_, err = suite.DB.DB.Pool().Exec(ctx, "UPDATE moderation_checks SET next_check_at = $1", time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+
+	claimed, err := moderation.Recheck(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 2, claimed)
+
+	var displayName *string
+	require.NoError(t, suite.DB.DB.Pool().QueryRow(ctx, "SELECT display_name FROM users WHERE id = $1", user.ID).Scan(&displayName))
+	assert.Nil(t, displayName)
+
+	denials, err = moderation.ListDenials(ctx, models.ModerationDenialFilter{UserID: &user.ID})
+	require.NoError(t, err)
+	require.Len(t, denials, 2)
+	assert.Equal(t, models.ModerationSourceRecheck, denials[0].Source)
+
+	// Allowed on re-check, so nothing is left to check
+	var remaining int
+	require.NoError(t, suite.DB.DB.Pool().QueryRow(ctx, "SELECT COUNT(*) FROM moderation_checks").Scan(&remaining))
+	assert.Zero(t, remaining)
+}
@@ -0,0 +1,291 @@
+package services
+
+import (
+    "context"
+    "fmt"
+    "time"
+
+    "auth-service/internal/database"
+    "auth-service/internal/events"
+    "auth-service/internal/models"
+
+    "github.com/google/uuid"
+    "github.com/jackc/pgx/v5"
+    "go.uber.org/zap"
+)
+
+const (
+    // How long a claimed email is reserved for the instance sending it
+    emailOutboxLease = 5 * time.Minute
+    // Sends are retried with a growing delay and given up after this many
+    emailOutboxMaxAttempts = 5
+)
+
+// OnboardingService walks newly registered users through onboarding. It
+// records the milestones they reach (models.OnboardingMilestones), publishes
+// an event for each, and queues the welcome email once their address is
+// verified. Like funnel tracking, failures are logged rather than returned so
+// they never break the flow that reached the milestone.
+type OnboardingService struct {
+    db     *database.DB
+    events EventPublisher
+    logger *zap.SugaredLogger
+    mailer Mailer
+}
+
+func NewOnboardingService(db *database.DB, events EventPublisher, logger *zap.SugaredLogger) *OnboardingService {
+    return &OnboardingService{
+        db:     db,
+        events: events,
+        logger: logger,
+    }
+}
+
+// SetMailer sends the queued emails through mailer. Without one they are
+// marked sent without being delivered.
+func (s *OnboardingService) SetMailer(mailer Mailer) {
+    s.mailer = mailer
+}
+
+// Start begins onboarding for a newly registered user. A nil service does
+// nothing, as does Verified.
+func (s *OnboardingService) Start(ctx context.Context, user *models.User) {
+    if s == nil {
+        return
+    }
+
+    _, err := s.db.Pool().Exec(ctx,
+        `INSERT INTO onboarding_milestones (user_id, milestone) VALUES ($1, $2)
+         ON CONFLICT DO NOTHING`,
+        user.ID, models.MilestoneRegistered,
+    )
+    if err != nil {
+        s.logger.Errorf("Failed to start onboarding: %v", err)
+        return
+    }
+
+    event := events.NewUserEvent(events.UserOnboardingStarted, user.ID.String(), user.Username)
+    if err := s.events.PublishUserEvent(event); err != nil {
+        s.logger.Errorf("Failed to publish onboarding started event: %v", err)
+    }
+}
+
+// Verified records the first verification of the user's email and queues
+// their welcome email.
+func (s *OnboardingService) Verified(ctx context.Context, userID uuid.UUID) {
+    if s == nil {
+        return
+    }
+
+    _, err := s.db.Pool().Exec(ctx,
+        `INSERT INTO email_outbox (user_id, template, recipient)
+         SELECT id, $2, email FROM users WHERE id = $1`,
+        userID, emailTemplateWelcome,
+    )
+    if err != nil {
+        s.logger.Errorf("Failed to queue welcome email: %v", err)
+    }
+
+    if err := s.reach(ctx, userID, models.MilestoneVerified); err != nil {
+        s.logger.Errorf("Failed to record onboarding milestone: %v", err)
+    }
+}
+
+// ProfileUpdated records a completed profile once the user has both a
+// display name and an avatar.
+func (s *OnboardingService) ProfileUpdated(ctx context.Context, userID uuid.UUID) {
+    var complete bool
+    err := s.db.Pool().QueryRow(ctx,
+        "SELECT display_name IS NOT NULL AND avatar_url IS NOT NULL FROM users WHERE id = $1",
+        userID,
+    ).Scan(&complete)
+    if err != nil {
+        if err != pgx.ErrNoRows {
+            s.logger.Errorf("Failed to check profile completion: %v", err)
+        }
+        return
+    }
+    if !complete {
+        return
+    }
+
+    if err := s.reach(ctx, userID, models.MilestoneProfileCompleted); err != nil {
+        s.logger.Errorf("Failed to record onboarding milestone: %v", err)
+    }
+}
+
+// HandleChatEvent records milestones reached in the chat service. Errors are
+// returned so the event can be redelivered.
+func (s *OnboardingService) HandleChatEvent(ctx context.Context, event *events.UserEvent) error {
+    if event.Type != events.ChatJoined {
+        return nil
+    }
+
+    userID, err := uuid.Parse(event.UserID)
+    if err != nil {
+        s.logger.Warnf("Ignoring %s event with invalid user ID %q", event.Type, event.UserID)
+        return nil
+    }
+    return s.reach(ctx, userID, models.MilestoneFirstChatJoined)
+}
+
+// reach records a milestone for a user who is onboarding and, the first time
+// it is reached, publishes it. Users who registered before onboarding was
+// tracked are skipped.
+func (s *OnboardingService) reach(ctx context.Context, userID uuid.UUID, milestone string) error {
+    var username string
+    var reached int
+    err := s.db.Pool().QueryRow(ctx,
+        `INSERT INTO onboarding_milestones (user_id, milestone)
+         SELECT user_id, $2 FROM onboarding_milestones WHERE user_id = $1 AND milestone = $3
+         ON CONFLICT DO NOTHING
+         RETURNING (SELECT username FROM users WHERE id = $1),
+                   (SELECT COUNT(*) FROM onboarding_milestones WHERE user_id = $1)`,
+        userID, milestone, models.MilestoneRegistered,
+    ).Scan(&username, &reached)
+    if err != nil {
+        if err == pgx.ErrNoRows {
+            return nil
+        }
+        return fmt.Errorf("record onboarding milestone: %w", err)
+    }
+
+    event := events.NewUserEvent(events.UserOnboardingMilestone, userID.String(), username)
+    event.Data["milestone"] = milestone
+    if err := s.events.PublishUserEvent(event); err != nil {
+        s.logger.Errorf("Failed to publish onboarding milestone event: %v", err)
+    }
+
+    // The count is taken before this milestone's row was inserted
+    if reached+1 == len(models.OnboardingMilestones) {
+        event := events.NewUserEvent(events.UserOnboardingCompleted, userID.String(), username)
+        if err := s.events.PublishUserEvent(event); err != nil {
+            s.logger.Errorf("Failed to publish onboarding completed event: %v", err)
+        }
+    }
+    return nil
+}
+
+// Progress reports which onboarding milestones the user has reached.
+func (s *OnboardingService) Progress(ctx context.Context, userID uuid.UUID) (*models.OnboardingProgress, error) {
+    rows, err := s.db.Pool().Query(ctx,
+        "SELECT milestone, reached_at FROM onboarding_milestones WHERE user_id = $1",
+        userID,
+    )
+    if err != nil {
+        return nil, fmt.Errorf("list onboarding milestones: %w", err)
+    }
+    defer rows.Close()
+
+    reached := map[string]time.Time{}
+    for rows.Next() {
+        var milestone string
+        var reachedAt time.Time
+        if err := rows.Scan(&milestone, &reachedAt); err != nil {
+            return nil, fmt.Errorf("scan onboarding milestone: %w", err)
+        }
+        reached[milestone] = reachedAt
+    }
+    if err := rows.Err(); err != nil {
+        return nil, fmt.Errorf("list onboarding milestones: %w", err)
+    }
+
+    progress := &models.OnboardingProgress{Completed: len(reached) > 0}
+    for _, name := range models.OnboardingMilestones {
+        milestone := models.OnboardingMilestone{Name: name}
+        if at, ok := reached[name]; ok {
+            milestone.Reached = true
+            milestone.ReachedAt = &at
+        } else {
+            progress.Completed = false
+        }
+        progress.Milestones = append(progress.Milestones, milestone)
+    }
+    return progress, nil
+}
+
+// RunMailer sends up to batchSize queued emails every interval until ctx is
+// cancelled.
+func (s *OnboardingService) RunMailer(ctx context.Context, interval time.Duration, batchSize int) {
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+            if _, err := s.SendPending(ctx, batchSize); err != nil {
+                s.logger.Errorf("Failed to send queued emails: %v", err)
+            }
+        }
+    }
+}
+
+type outboxEmail struct {
+    id        uuid.UUID
+    template  string
+    recipient string
+    attempts  int
+}
+
+// SendPending claims up to limit due emails and sends them. It returns how
+// many it claimed. Claims are leased, so several instances can run the mailer
+// side by side.
+func (s *OnboardingService) SendPending(ctx context.Context, limit int) (int, error) {
+    rows, err := s.db.Pool().Query(ctx,
+        `UPDATE email_outbox SET next_attempt_at = $2
+         WHERE id IN (
+             SELECT id FROM email_outbox
+             WHERE sent_at IS NULL AND attempts < $3 AND next_attempt_at <= NOW()
+             ORDER BY created_at
+             LIMIT $1
+             FOR UPDATE SKIP LOCKED
+         )
+         RETURNING id, template, recipient, attempts`,
+        limit, time.Now().Add(emailOutboxLease), emailOutboxMaxAttempts,
+    )
+    if err != nil {
+        return 0, fmt.Errorf("claim queued emails: %w", err)
+    }
+
+    var emails []*outboxEmail
+    for rows.Next() {
+        email := &outboxEmail{}
+        if err := rows.Scan(&email.id, &email.template, &email.recipient, &email.attempts); err != nil {
+            rows.Close()
+            return 0, fmt.Errorf("scan queued email: %w", err)
+        }
+        emails = append(emails, email)
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil {
+        return 0, fmt.Errorf("claim queued emails: %w", err)
+    }
+
+    for _, email := range emails {
+        if sendErr := s.send(ctx, email); sendErr != nil {
+            attempts := email.attempts + 1
+            _, err := s.db.Pool().Exec(ctx,
+                `UPDATE email_outbox SET attempts = $2, last_error = $3, next_attempt_at = $4 WHERE id = $1`,
+                email.id, attempts, sendErr.Error(), time.Now().Add(time.Duration(attempts*attempts)*time.Minute),
+            )
+            if err != nil {
+                s.logger.Errorf("Failed to record email failure: %v", err)
+            }
+            continue
+        }
+
+        if _, err := s.db.Pool().Exec(ctx, "UPDATE email_outbox SET sent_at = NOW() WHERE id = $1", email.id); err != nil {
+            s.logger.Errorf("Failed to mark email sent: %v", err)
+        }
+    }
+    return len(emails), nil
+}
+
+func (s *OnboardingService) send(ctx context.Context, email *outboxEmail) error {
+    if s.mailer == nil {
+        return nil
+    }
+    return s.mailer.Send(ctx, newEmail(email.template, email.recipient, nil))
+}
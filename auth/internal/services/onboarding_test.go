@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"auth-service/internal/events"
+	"auth-service/internal/models"
+	"auth-service/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOnboardingService_Milestones(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	onboarding := NewOnboardingService(suite.DB.DB, suite.Events, suite.Logger)
+	userService := NewUserService(suite.DB.DB, suite.Redis.Client, suite.Logger)
+	ctx := context.Background()
+
+	user := suite.CreateTestUser(t, test.TestData.ValidEmail, test.TestData.ValidUsername, test.TestData.ValidPassword)
+
+	// Users who never started onboarding aren't tracked
+	require.NoError(t, onboarding.HandleChatEvent(ctx, events.NewUserEvent(events.ChatJoined, user.ID.String(), user.Username)))
+	progress, err := onboarding.Progress(ctx, user.ID)
+	require.NoError(t, err)
+	assert.False(t, progress.Completed)
+	assert.False(t, progress.Milestones[3].Reached)
+
+	onboarding.Start(ctx, user)
+	onboarding.Verified(ctx, user.ID)
+
+	// A half-filled profile isn't complete
+	name := "Test User"
+	require.NoError(t, userService.UpdatePublicProfile(ctx, user.ID, &models.PublicProfileRequest{DisplayName: &name}))
+	onboarding.ProfileUpdated(ctx, user.ID)
+
+	progress, err = onboarding.Progress(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, progress.Milestones, len(models.OnboardingMilestones))
+	assert.True(t, progress.Milestones[0].Reached)
+	assert.True(t, progress.Milestones[1].Reached)
+	assert.False(t, progress.Milestones[2].Reached)
+	assert.False(t, progress.Completed)
+
+	avatar := "https://example.com/avatar.png"
+	require.NoError(t, userService.UpdatePublicProfile(ctx, user.ID, &models.PublicProfileRequest{AvatarURL: &avatar}))
+	onboarding.ProfileUpdated(ctx, user.ID)
+	require.NoError(t, onboarding.HandleChatEvent(ctx, events.NewUserEvent(events.ChatJoined, user.ID.String(), user.Username)))
+	// Joining another chat reaches nothing new
+	require.NoError(t, onboarding.HandleChatEvent(ctx, events.NewUserEvent(events.ChatJoined, user.ID.String(), user.Username)))
+
+	progress, err = onboarding.Progress(ctx, user.ID)
+	require.NoError(t, err)
+	assert.True(t, progress.Completed)
+
+	var types []events.EventType
+	var milestones []interface{}
+	for _, event := range suite.Events.Events {
+		types = append(types, event.Type)
+		if event.Type == events.UserOnboardingMilestone {
+			milestones = append(milestones, event.Data["milestone"])
+		}
+	}
+	assert.Equal(t, []events.EventType{
+		events.UserOnboardingStarted,
+		events.UserOnboardingMilestone,
+		events.UserOnboardingMilestone,
+		events.UserOnboardingMilestone,
+		events.UserOnboardingCompleted,
+	}, types)
+	assert.Equal(t, []interface{}{models.MilestoneVerified, models.MilestoneProfileCompleted, models.MilestoneFirstChatJoined}, milestones)
+
+	// The welcome email is queued and sent once
+	sent, err := onboarding.SendPending(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+
+	sent, err = onboarding.SendPending(ctx, 10)
+	require.NoError(t, err)
+	assert.Zero(t, sent)
+}
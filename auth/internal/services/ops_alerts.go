@@ -0,0 +1,269 @@
+package services
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "sort"
+    "strconv"
+    "strings"
+    "text/template"
+    "time"
+
+    "auth-service/internal/config"
+    "auth-service/internal/redis"
+
+    "go.uber.org/zap"
+)
+
+const (
+    opsAlertDeliveryTimeout = 5 * time.Second
+
+    SeverityHigh     = "high"
+    SeverityCritical = "critical"
+)
+
+// OpsAlert is one alert for the security team. Fields carry the details
+// shown under the message, e.g. the user or the count that was reached.
+type OpsAlert struct {
+    Name     string            `json:"name"`
+    Severity string            `json:"severity"`
+    Title    string            `json:"title"`
+    Message  string            `json:"message"`
+    Fields   map[string]string `json:"fields,omitempty"`
+    At       time.Time         `json:"at"`
+    // Alerts of the same name held back by the cooldown since the last one
+    // was sent
+    Suppressed int64 `json:"suppressed,omitempty"`
+}
+
+// OpsAlertService posts high-severity events to the security team's Slack
+// channels and webhooks. Each alert name is sent at most once per cooldown
+// across all instances; the ones held back are counted and reported with
+// the next. Like funnel tracking, failures are logged rather than returned,
+// and delivery happens in the background so no request waits on it. A nil
+// service sends nothing.
+type OpsAlertService struct {
+    targets    []config.AlertTarget
+    enabled    map[string]bool
+    thresholds map[string]config.AlertThreshold
+    cooldown   time.Duration
+    template   *template.Template
+    redis      *redis.Client
+    local      *localLimiter
+    client     *http.Client
+    logger     *zap.SugaredLogger
+}
+
+// NewOpsAlertService returns nil when cfg has no targets.
+func NewOpsAlertService(cfg config.AlertingConfig, redis *redis.Client, logger *zap.SugaredLogger) (*OpsAlertService, error) {
+    if len(cfg.Targets) == 0 {
+        return nil, nil
+    }
+
+    tmpl, err := template.New("ops_alert").Option("missingkey=zero").Parse(cfg.Template)
+    if err != nil {
+        return nil, fmt.Errorf("parse ops alert template: %w", err)
+    }
+
+    enabled := make(map[string]bool, len(cfg.Alerts))
+    for _, name := range cfg.Alerts {
+        enabled[name] = true
+    }
+
+    return &OpsAlertService{
+        targets:    cfg.Targets,
+        enabled:    enabled,
+        thresholds: cfg.Thresholds,
+        cooldown:   cfg.Cooldown,
+        template:   tmpl,
+        redis:      redis,
+        local:      newLocalLimiter(),
+        client:     &http.Client{Timeout: opsAlertDeliveryTimeout},
+        logger:     logger,
+    }, nil
+}
+
+// Raise sends alert to every target unless its name is disabled or cooling
+// down.
+func (s *OpsAlertService) Raise(ctx context.Context, alert *OpsAlert) {
+    if s == nil || !s.enabled[alert.Name] {
+        return
+    }
+    if alert.At.IsZero() {
+        alert.At = time.Now().UTC()
+    }
+
+    suppressed, ok := s.acquire(ctx, alert.Name)
+    if !ok {
+        return
+    }
+    alert.Suppressed = suppressed
+
+    var text bytes.Buffer
+    if err := s.template.Execute(&text, alert); err != nil {
+        s.logger.Errorf("Failed to render ops alert %s: %v", alert.Name, err)
+        return
+    }
+
+    go s.deliver(alert, text.String())
+}
+
+// Count notes one event towards the spike alert name and raises it when the
+// count reaches the threshold within the window. Events are counted in fixed
+// windows shared by all instances, or per instance while Redis is
+// unavailable.
+func (s *OpsAlertService) Count(ctx context.Context, name string, describe func(threshold config.AlertThreshold) *OpsAlert) {
+    if s == nil || !s.enabled[name] {
+        return
+    }
+    threshold, ok := s.thresholds[name]
+    if !ok {
+        return
+    }
+
+    window := time.Now().Truncate(threshold.Window)
+    key := "ops_alert:count:" + name + ":" + strconv.FormatInt(window.Unix(), 10)
+    count, err := s.redis.Incr(ctx, key)
+    switch {
+    case err == redis.ErrUnavailable:
+        count = s.local.Incr(key, threshold.Window)
+    case err != nil:
+        s.logger.Errorf("Failed to count %s: %v", name, err)
+        return
+    case count == 1:
+        if err := s.redis.ExpireAt(ctx, key, window.Add(2*threshold.Window)); err != nil {
+            s.logger.Errorf("Failed to expire %s count: %v", name, err)
+        }
+    }
+
+    // Exactly once per window, however many events follow
+    if count == threshold.Count {
+        s.Raise(ctx, describe(threshold))
+    }
+}
+
+// acquire starts the cooldown of name. It reports false if one is already
+// running, counting the alert as suppressed, and otherwise the number
+// suppressed since the last alert.
+func (s *OpsAlertService) acquire(ctx context.Context, name string) (int64, bool) {
+    cooldownKey := "ops_alert:cooldown:" + name
+    suppressedKey := "ops_alert:suppressed:" + name
+
+    acquired, err := s.redis.SetNX(ctx, cooldownKey, "1", s.cooldown)
+    if err == redis.ErrUnavailable {
+        if s.local.TTL(cooldownKey) > 0 {
+            s.local.Incr(suppressedKey, 24*time.Hour)
+            return 0, false
+        }
+        s.local.Lock(cooldownKey, s.cooldown)
+        // The limiter has no plain read; count this one and take it off
+        suppressed := s.local.Incr(suppressedKey, 24*time.Hour) - 1
+        s.local.Delete(suppressedKey)
+        return suppressed, true
+    }
+    if err != nil {
+        s.logger.Errorf("Failed to check ops alert cooldown of %s: %v", name, err)
+        return 0, false
+    }
+
+    if !acquired {
+        suppressed, err := s.redis.Incr(ctx, suppressedKey)
+        if err != nil {
+            s.logger.Errorf("Failed to count suppressed ops alert %s: %v", name, err)
+        } else if suppressed == 1 {
+            // Don't outlive alerting being turned off
+            if err := s.redis.Expire(ctx, suppressedKey, 24*time.Hour); err != nil {
+                s.logger.Errorf("Failed to expire suppressed ops alerts of %s: %v", name, err)
+            }
+        }
+        return 0, false
+    }
+
+    raw, err := s.redis.GetDel(ctx, suppressedKey)
+    if err != nil && err != redis.Nil {
+        s.logger.Errorf("Failed to read suppressed ops alerts of %s: %v", name, err)
+    }
+    suppressed, _ := strconv.ParseInt(raw, 10, 64)
+    return suppressed, true
+}
+
+func (s *OpsAlertService) deliver(alert *OpsAlert, text string) {
+    for _, target := range s.targets {
+        var payload interface{}
+        switch target.Kind {
+        case config.AlertTargetSlack:
+            payload = slackMessage(alert, text)
+        default:
+            payload = struct {
+                *OpsAlert
+                Text string `json:"text"`
+            }{alert, text}
+        }
+
+        if err := s.post(target.URL, payload); err != nil {
+            s.logger.Errorw("Failed to deliver ops alert", "alert", alert.Name, "target", target.Kind, "error", err)
+        }
+    }
+}
+
+func (s *OpsAlertService) post(url string, payload interface{}) error {
+    body, err := json.Marshal(payload)
+    if err != nil {
+        return fmt.Errorf("marshal ops alert: %w", err)
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), opsAlertDeliveryTimeout)
+    defer cancel()
+
+    req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+    if err != nil {
+        return fmt.Errorf("build ops alert request: %w", err)
+    }
+    req.Header.Set("Content-Type", "application/json")
+
+    resp, err := s.client.Do(req)
+    if err != nil {
+        return fmt.Errorf("post ops alert: %w", err)
+    }
+    resp.Body.Close()
+
+    if resp.StatusCode >= 300 {
+        return fmt.Errorf("post ops alert: status %d", resp.StatusCode)
+    }
+    return nil
+}
+
+// slackMessage is an incoming webhook payload: the rendered text, with the
+// fields and the suppressed count as a context block underneath.
+func slackMessage(alert *OpsAlert, text string) map[string]interface{} {
+    names := make([]string, 0, len(alert.Fields))
+    for name := range alert.Fields {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+
+    var details []string
+    for _, name := range names {
+        details = append(details, fmt.Sprintf("*%s:* %s", name, alert.Fields[name]))
+    }
+    if alert.Suppressed > 0 {
+        details = append(details, fmt.Sprintf("*suppressed since last alert:* %d", alert.Suppressed))
+    }
+
+    blocks := []interface{}{
+        map[string]interface{}{
+            "type": "section",
+            "text": map[string]string{"type": "mrkdwn", "text": text},
+        },
+    }
+    if len(details) > 0 {
+        blocks = append(blocks, map[string]interface{}{
+            "type":     "context",
+            "elements": []map[string]string{{"type": "mrkdwn", "text": strings.Join(details, "  |  ")}},
+        })
+    }
+    return map[string]interface{}{"text": text, "blocks": blocks}
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"auth-service/internal/config"
+	"auth-service/internal/redis"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestOpsAlertService_SpikesAndCooldown(t *testing.T) {
+	posted := make(chan map[string]interface{}, 10)
+	slack := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		posted <- payload
+	}))
+	defer slack.Close()
+
+	// Nothing listens here, so cooldowns and counts are kept locally
+	client := redis.New("redis://127.0.0.1:1")
+	defer client.Close()
+
+	alerts, err := NewOpsAlertService(config.AlertingConfig{
+		Targets: []config.AlertTarget{{Kind: config.AlertTargetSlack, URL: slack.URL}},
+		Alerts:  []string{config.AlertFailedLoginSpike},
+		Thresholds: map[string]config.AlertThreshold{
+			config.AlertFailedLoginSpike: {Count: 3, Window: time.Hour},
+		},
+		Cooldown: time.Minute,
+		Template: "[{{.Severity}}] {{.Title}}: {{.Message}}",
+	}, client, zap.NewNop().Sugar())
+	require.NoError(t, err)
+
+	now := time.Now()
+	alerts.local.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	describe := func(threshold config.AlertThreshold) *OpsAlert {
+		return &OpsAlert{
+			Name:     config.AlertFailedLoginSpike,
+			Severity: SeverityHigh,
+			Title:    "Failed login spike",
+			Message:  "3 failed logins",
+			Fields:   map[string]string{"last_ip": "10.0.0.1"},
+		}
+	}
+
+	// Below the threshold nothing is sent
+	alerts.Count(ctx, config.AlertFailedLoginSpike, describe)
+	alerts.Count(ctx, config.AlertFailedLoginSpike, describe)
+	alerts.Count(ctx, config.AlertFailedLoginSpike, describe)
+
+	select {
+	case payload := <-posted:
+		assert.Equal(t, "[high] Failed login spike: 3 failed logins", payload["text"])
+		assert.Len(t, payload["blocks"], 2)
+	case <-time.After(5 * time.Second):
+		t.Fatal("alert was not delivered")
+	}
+
+	// Disabled alerts are dropped, and the cooldown holds back the rest
+	alerts.Raise(ctx, &OpsAlert{Name: config.AlertImpersonation, Title: "Possible impersonation"})
+	alerts.Raise(ctx, describe(config.AlertThreshold{}))
+	alerts.Raise(ctx, describe(config.AlertThreshold{}))
+
+	now = now.Add(2 * time.Minute)
+	alerts.Raise(ctx, describe(config.AlertThreshold{}))
+
+	select {
+	case payload := <-posted:
+		blocks := payload["blocks"].([]interface{})
+		details := blocks[1].(map[string]interface{})["elements"].([]interface{})[0].(map[string]interface{})
+		assert.Contains(t, details["text"], "*suppressed since last alert:* 2")
+	case <-time.After(5 * time.Second):
+		t.Fatal("alert was not delivered")
+	}
+	assert.Empty(t, posted)
+}
+
+func TestOpsAlertService_DisabledWithoutTargets(t *testing.T) {
+	alerts, err := NewOpsAlertService(config.AlertingConfig{}, nil, zap.NewNop().Sugar())
+	require.NoError(t, err)
+	assert.Nil(t, alerts)
+
+	// A nil service is safe to use
+	alerts.Raise(context.Background(), &OpsAlert{Name: config.AlertImpersonation})
+}
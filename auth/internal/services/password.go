@@ -0,0 +1,115 @@
+package services
+
+import (
+    "context"
+    "fmt"
+    "runtime"
+    "time"
+
+    "auth-service/internal/apperr"
+    "auth-service/internal/metrics"
+
+    "golang.org/x/crypto/bcrypt"
+    "golang.org/x/sync/semaphore"
+)
+
+const defaultBcryptQueueTimeout = 2 * time.Second
+
+// ErrPasswordBusy is returned when a bcrypt operation waited longer than the
+// queue timeout for a free slot.
+var ErrPasswordBusy = apperr.New(apperr.Unavailable, "Server is busy, please retry", nil).WithRetryAfter(time.Second)
+
+// BcryptPool caps how many bcrypt operations run at once. bcrypt is CPU-bound
+// by design, so a burst of logins would otherwise occupy every core and
+// stall unrelated requests. Callers beyond the cap queue for up to the queue
+// timeout and then fail with ErrPasswordBusy.
+type BcryptPool struct {
+    sem          *semaphore.Weighted
+    queueTimeout time.Duration
+}
+
+// NewBcryptPool creates a pool running at most concurrency operations at a
+// time. A non-positive concurrency uses the number of CPUs.
+func NewBcryptPool(concurrency int, queueTimeout time.Duration) *BcryptPool {
+    if concurrency <= 0 {
+        concurrency = runtime.NumCPU()
+    }
+    if queueTimeout <= 0 {
+        queueTimeout = defaultBcryptQueueTimeout
+    }
+    return &BcryptPool{
+        sem:          semaphore.NewWeighted(int64(concurrency)),
+        queueTimeout: queueTimeout,
+    }
+}
+
+// dummyPasswordHash is compared against when a login names an account that
+// doesn't exist, so those attempts cost the same bcrypt work as real ones
+// and response time doesn't reveal whether the email is registered.
+var dummyPasswordHash = mustDummyHash()
+
+func mustDummyHash() string {
+    hash, err := bcrypt.GenerateFromPassword([]byte("dummy-password-for-timing"), bcrypt.DefaultCost)
+    if err != nil {
+        panic(fmt.Sprintf("generate dummy password hash: %v", err))
+    }
+    return string(hash)
+}
+
+// passwords is shared by every service so the cap applies process-wide.
+var passwords = NewBcryptPool(0, defaultBcryptQueueTimeout)
+
+// SetBcryptPool replaces the process-wide pool. Call it once at startup.
+func SetBcryptPool(pool *BcryptPool) {
+    passwords = pool
+}
+
+// Hash returns the bcrypt hash of password.
+func (p *BcryptPool) Hash(ctx context.Context, password string) (string, error) {
+    release, err := p.acquire(ctx)
+    if err != nil {
+        return "", err
+    }
+    defer release()
+
+    hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+    if err != nil {
+        return "", fmt.Errorf("hash password: %w", err)
+    }
+    return string(hash), nil
+}
+
+// Compare returns ErrInvalidCredentials if password does not match hash.
+func (p *BcryptPool) Compare(ctx context.Context, hash, password string) error {
+    release, err := p.acquire(ctx)
+    if err != nil {
+        return err
+    }
+    defer release()
+
+    if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+        return ErrInvalidCredentials
+    }
+    return nil
+}
+
+func (p *BcryptPool) acquire(ctx context.Context) (func(), error) {
+    if p.sem.TryAcquire(1) {
+        return func() { p.sem.Release(1) }, nil
+    }
+
+    metrics.BcryptQueueDepth.Inc()
+    defer metrics.BcryptQueueDepth.Dec()
+
+    waitCtx, cancel := context.WithTimeout(ctx, p.queueTimeout)
+    defer cancel()
+
+    if err := p.sem.Acquire(waitCtx, 1); err != nil {
+        if ctx.Err() != nil {
+            return nil, ctx.Err()
+        }
+        metrics.BcryptQueueTimeouts.Inc()
+        return nil, ErrPasswordBusy
+    }
+    return func() { p.sem.Release(1) }, nil
+}
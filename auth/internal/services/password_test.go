@@ -0,0 +1,54 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestBcryptPool_HashAndCompare(t *testing.T) {
+	pool := NewBcryptPool(2, time.Second)
+	ctx := context.Background()
+
+	hash, err := pool.Hash(ctx, "Secret123!")
+	require.NoError(t, err)
+
+	assert.NoError(t, pool.Compare(ctx, hash, "Secret123!"))
+	assert.Equal(t, ErrInvalidCredentials, pool.Compare(ctx, hash, "wrong"))
+}
+
+func TestBcryptPool_QueueTimeout(t *testing.T) {
+	pool := NewBcryptPool(1, 20*time.Millisecond)
+	ctx := context.Background()
+
+	// Hold the only slot
+	require.NoError(t, pool.sem.Acquire(ctx, 1))
+
+	_, err := pool.Hash(ctx, "Secret123!")
+	assert.Equal(t, ErrPasswordBusy, err)
+
+	// A caller that gives up first gets its own context error
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = pool.Hash(cancelled, "Secret123!")
+	assert.ErrorIs(t, err, context.Canceled)
+
+	pool.sem.Release(1)
+	_, err = pool.Hash(ctx, "Secret123!")
+	assert.NoError(t, err)
+}
+
+func TestDummyPasswordHash(t *testing.T) {
+	pool := NewBcryptPool(1, time.Second)
+
+	// Must be a valid hash of the default cost so the comparison takes as
+	// long as one against a real account
+	cost, err := bcrypt.Cost([]byte(dummyPasswordHash))
+	require.NoError(t, err)
+	assert.Equal(t, bcrypt.DefaultCost, cost)
+	assert.Equal(t, ErrInvalidCredentials, pool.Compare(context.Background(), dummyPasswordHash, "Secret123!"))
+}
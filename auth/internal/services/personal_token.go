@@ -0,0 +1,184 @@
+package services
+
+import (
+    "context"
+    "fmt"
+    "strings"
+    "time"
+
+    "auth-service/internal/apperr"
+    "auth-service/internal/database"
+    "auth-service/internal/models"
+
+    "github.com/google/uuid"
+    "github.com/jackc/pgx/v5"
+    "go.uber.org/zap"
+)
+
+const (
+    // PersonalTokenPrefix starts every personal access token, so the auth
+    // middleware can tell them from JWTs without parsing
+    PersonalTokenPrefix = "tp_"
+
+    maxPersonalTokensPerUser = 20
+)
+
+// ScopePersonalToken marks the claims of a request authenticated with a
+// personal access token rather than a JWT.
+const ScopePersonalToken = "personal_token"
+
+var (
+    ErrInvalidPersonalToken  = apperr.New(apperr.Unauthenticated, "Invalid personal access token", nil)
+    ErrPersonalTokenNotFound = apperr.New(apperr.NotFound, "Personal access token not found", nil)
+    ErrPersonalTokenLimit    = apperr.New(apperr.Conflict, "Personal access token limit reached", nil)
+)
+
+const personalTokenColumns = `id, user_id, name, token_prefix, scopes, expires_at, last_used_at, created_at, revoked_at`
+
+// PersonalTokenService issues the long-lived, scope-limited tokens users
+// create for their own integrations and bots. Like API keys, only a hash of
+// a token is stored and the plaintext is shown once.
+type PersonalTokenService struct {
+    db     *database.DB
+    logger *zap.SugaredLogger
+}
+
+func NewPersonalTokenService(db *database.DB, logger *zap.SugaredLogger) *PersonalTokenService {
+    return &PersonalTokenService{
+        db:     db,
+        logger: logger,
+    }
+}
+
+// Create issues a token for userID and returns it with the plaintext token.
+// Revoked and expired tokens don't count towards the limit.
+func (s *PersonalTokenService) Create(ctx context.Context, userID uuid.UUID, req *models.CreatePersonalAccessTokenRequest) (*models.PersonalAccessToken, string, error) {
+    var count int
+    err := s.db.Pool().QueryRow(ctx,
+        `SELECT COUNT(*) FROM personal_access_tokens
+         WHERE user_id = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())`,
+        userID,
+    ).Scan(&count)
+    if err != nil {
+        return nil, "", fmt.Errorf("count personal access tokens: %w", err)
+    }
+    if count >= maxPersonalTokensPerUser {
+        return nil, "", ErrPersonalTokenLimit
+    }
+
+    var expiresAt *time.Time
+    if req.ExpiresInDays > 0 {
+        at := time.Now().Add(time.Duration(req.ExpiresInDays) * 24 * time.Hour)
+        expiresAt = &at
+    }
+
+    rawToken := PersonalTokenPrefix + generateToken()
+    token, err := scanPersonalToken(s.db.Pool().QueryRow(ctx,
+        `INSERT INTO personal_access_tokens (user_id, name, token_prefix, token_hash, scopes, expires_at)
+         VALUES ($1, $2, $3, $4, $5, $6)
+         RETURNING `+personalTokenColumns,
+        userID, req.Name, rawToken[:len(PersonalTokenPrefix)+8], hashToken(rawToken), dedupeScopes(req.Scopes), expiresAt,
+    ))
+    if err != nil {
+        return nil, "", fmt.Errorf("create personal access token: %w", err)
+    }
+
+    return token, rawToken, nil
+}
+
+// List returns the user's tokens newest first, including revoked and expired
+// ones.
+func (s *PersonalTokenService) List(ctx context.Context, userID uuid.UUID) ([]*models.PersonalAccessToken, error) {
+    rows, err := s.db.Pool().Query(ctx,
+        `SELECT `+personalTokenColumns+` FROM personal_access_tokens
+         WHERE user_id = $1 ORDER BY created_at DESC`,
+        userID,
+    )
+    if err != nil {
+        return nil, fmt.Errorf("list personal access tokens: %w", err)
+    }
+    defer rows.Close()
+
+    tokens := []*models.PersonalAccessToken{}
+    for rows.Next() {
+        token, err := scanPersonalToken(rows)
+        if err != nil {
+            return nil, fmt.Errorf("scan personal access token: %w", err)
+        }
+        tokens = append(tokens, token)
+    }
+
+    return tokens, rows.Err()
+}
+
+// Revoke stops one of the user's tokens from authenticating.
+func (s *PersonalTokenService) Revoke(ctx context.Context, userID, tokenID uuid.UUID) error {
+    result, err := s.db.Pool().Exec(ctx,
+        `UPDATE personal_access_tokens SET revoked_at = NOW()
+         WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`,
+        tokenID, userID,
+    )
+    if err != nil {
+        return fmt.Errorf("revoke personal access token: %w", err)
+    }
+    if result.RowsAffected() == 0 {
+        return ErrPersonalTokenNotFound
+    }
+    return nil
+}
+
+// Authenticate resolves a plaintext token to its active record and the
+// claims the request runs with, and records its use. The claims carry
+// ScopePersonalToken and the token's ID; they have no session.
+func (s *PersonalTokenService) Authenticate(ctx context.Context, rawToken string) (*models.PersonalAccessToken, *TokenClaims, error) {
+    if !strings.HasPrefix(rawToken, PersonalTokenPrefix) {
+        return nil, nil, ErrInvalidPersonalToken
+    }
+
+    claims := &TokenClaims{Scope: ScopePersonalToken}
+    token := &models.PersonalAccessToken{}
+    err := s.db.Pool().QueryRow(ctx,
+        `WITH used AS (
+            UPDATE personal_access_tokens SET last_used_at = NOW()
+            WHERE token_hash = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())
+            RETURNING `+personalTokenColumns+`
+         )
+         SELECT used.id, used.user_id, used.name, used.token_prefix, used.scopes, used.expires_at,
+                used.last_used_at, used.created_at, used.revoked_at, u.email, u.username
+         FROM used JOIN users u ON u.id = used.user_id`,
+        hashToken(rawToken),
+    ).Scan(&token.ID, &token.UserID, &token.Name, &token.TokenPrefix, &token.Scopes, &token.ExpiresAt,
+        &token.LastUsedAt, &token.CreatedAt, &token.RevokedAt, &claims.Email, &claims.Username)
+    if err != nil {
+        if err == pgx.ErrNoRows {
+            return nil, nil, ErrInvalidPersonalToken
+        }
+        return nil, nil, fmt.Errorf("authenticate personal access token: %w", err)
+    }
+
+    claims.UserID = token.UserID
+    claims.ID = token.ID.String()
+    return token, claims, nil
+}
+
+func scanPersonalToken(row pgx.Row) (*models.PersonalAccessToken, error) {
+    token := &models.PersonalAccessToken{}
+    err := row.Scan(&token.ID, &token.UserID, &token.Name, &token.TokenPrefix, &token.Scopes,
+        &token.ExpiresAt, &token.LastUsedAt, &token.CreatedAt, &token.RevokedAt)
+    if err != nil {
+        return nil, err
+    }
+    return token, nil
+}
+
+func dedupeScopes(scopes []string) []string {
+    seen := make(map[string]bool, len(scopes))
+    unique := make([]string, 0, len(scopes))
+    for _, scope := range scopes {
+        if !seen[scope] {
+            seen[scope] = true
+            unique = append(unique, scope)
+        }
+    }
+    return unique
+}
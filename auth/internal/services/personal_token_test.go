@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"auth-service/internal/models"
+	"auth-service/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPersonalTokenService_Lifecycle(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	ctx := context.Background()
+	personalTokens := NewPersonalTokenService(suite.DB.DB, suite.Logger)
+	user := suite.CreateTestUser(t, test.TestData.ValidEmail, test.TestData.ValidUsername, test.TestData.ValidPassword)
+
+	token, rawToken, err := personalTokens.Create(ctx, user.ID, &models.CreatePersonalAccessTokenRequest{
+		Name:   "standup bot",
+		Scopes: []string{models.TokenScopeProfileRead, models.TokenScopeProfileRead},
+	})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(rawToken, PersonalTokenPrefix))
+	assert.True(t, strings.HasPrefix(rawToken, token.TokenPrefix))
+	assert.Equal(t, []string{models.TokenScopeProfileRead}, token.Scopes)
+	assert.Nil(t, token.ExpiresAt)
+
+	// Only the hash is stored
+	var stored string
+	require.NoError(t, suite.DB.Pool().QueryRow(ctx,
+		"SELECT token_hash FROM personal_access_tokens WHERE id = $1", token.ID,
+	).Scan(&stored))
+	assert.Equal(t, hashToken(rawToken), stored)
+
+	authed, claims, err := personalTokens.Authenticate(ctx, rawToken)
+	require.NoError(t, err)
+	assert.Equal(t, token.ID, authed.ID)
+	assert.NotNil(t, authed.LastUsedAt)
+	assert.True(t, authed.HasScope(models.TokenScopeProfileRead))
+	assert.False(t, authed.HasScope(models.TokenScopeProfileWrite))
+	assert.Equal(t, user.ID, claims.UserID)
+	assert.Equal(t, user.Username, claims.Username)
+	assert.Equal(t, ScopePersonalToken, claims.Scope)
+	assert.Nil(t, claims.SessionID)
+
+	_, _, err = personalTokens.Authenticate(ctx, PersonalTokenPrefix+"unknown")
+	assert.ErrorIs(t, err, ErrInvalidPersonalToken)
+
+	tokens, err := personalTokens.List(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, tokens, 1)
+
+	require.NoError(t, personalTokens.Revoke(ctx, user.ID, token.ID))
+	_, _, err = personalTokens.Authenticate(ctx, rawToken)
+	assert.ErrorIs(t, err, ErrInvalidPersonalToken)
+	assert.ErrorIs(t, personalTokens.Revoke(ctx, user.ID, token.ID), ErrPersonalTokenNotFound)
+}
+
+func TestPersonalTokenService_ExpiryAndLimit(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	ctx := context.Background()
+	personalTokens := NewPersonalTokenService(suite.DB.DB, suite.Logger)
+	user := suite.CreateTestUser(t, test.TestData.ValidEmail, test.TestData.ValidUsername, test.TestData.ValidPassword)
+	req := &models.CreatePersonalAccessTokenRequest{
+		Name:          "bot",
+		Scopes:        []string{models.TokenScopeUsersSearch},
+		ExpiresInDays: 30,
+	}
+
+	token, rawToken, err := personalTokens.Create(ctx, user.ID, req)
+	require.NoError(t, err)
+	require.NotNil(t, token.ExpiresAt)
+
+	_, err = suite.DB.Pool().Exec(ctx,
+		"UPDATE personal_access_tokens SET expires_at = NOW() - INTERVAL '1 minute' WHERE id = $1", token.ID)
+	require.NoError(t, err)
+	_, _, err = personalTokens.Authenticate(ctx, rawToken)
+	assert.ErrorIs(t, err, ErrInvalidPersonalToken)
+
+	// The expired token no longer counts towards the limit
+	for i := 0; i < maxPersonalTokensPerUser; i++ {
+		_, _, err = personalTokens.Create(ctx, user.ID, req)
+		require.NoError(t, err)
+	}
+	_, _, err = personalTokens.Create(ctx, user.ID, req)
+	assert.ErrorIs(t, err, ErrPersonalTokenLimit)
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+    "context"
+    "fmt"
+    "time"
+
+    "auth-service/internal/config"
+    "auth-service/internal/models"
+
+    "github.com/google/uuid"
+    "github.com/jackc/pgx/v5"
+)
+
+// SetRequiredProfileFields sets the profile fields users are asked to fill
+// in after signing up (config.ProfileField*). Signing up never requires
+// them; clients prompt for what ProfileCompletion reports missing.
+func (s *UserService) SetRequiredProfileFields(fields []string) {
+    s.requiredFields = fields
+}
+
+// ProfileCompletion reports which required profile fields the user hasn't
+// filled in yet.
+func (s *UserService) ProfileCompletion(ctx context.Context, userID uuid.UUID) (*models.ProfileCompletion, error) {
+    completion := &models.ProfileCompletion{Required: s.requiredFields, Missing: []string{}}
+    if completion.Required == nil {
+        completion.Required = []string{}
+    }
+    if len(s.requiredFields) == 0 {
+        completion.Complete = true
+        return completion, nil
+    }
+
+    var displayName, avatarURL *string
+    var dateOfBirth *time.Time
+    err := s.db.Pool().QueryRow(ctx,
+        "SELECT display_name, avatar_url, date_of_birth FROM users WHERE id = $1",
+        userID,
+    ).Scan(&displayName, &avatarURL, &dateOfBirth)
+    if err != nil {
+        if err == pgx.ErrNoRows {
+            return nil, ErrUserNotFound
+        }
+        return nil, fmt.Errorf("get profile fields: %w", err)
+    }
+
+    filled := map[string]bool{
+        config.ProfileFieldDisplayName: displayName != nil,
+        config.ProfileFieldAvatarURL:   avatarURL != nil,
+        config.ProfileFieldDateOfBirth: dateOfBirth != nil,
+    }
+    for _, field := range s.requiredFields {
+        if !filled[field] {
+            completion.Missing = append(completion.Missing, field)
+        }
+    }
+    completion.Complete = len(completion.Missing) == 0
+    return completion, nil
+}
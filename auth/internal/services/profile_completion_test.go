@@ -0,0 +1,55 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"auth-service/internal/config"
+	"auth-service/internal/models"
+	"auth-service/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserService_ProfileCompletion(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	userService := NewUserService(suite.DB.DB, suite.Redis.Client, suite.Logger)
+	ctx := context.Background()
+	user := suite.CreateTestUser(t, "test@example.com", "testuser", test.TestData.ValidPassword)
+
+	// Nothing is required by default
+	completion, err := userService.ProfileCompletion(ctx, user.ID)
+	require.NoError(t, err)
+	assert.True(t, completion.Complete)
+	assert.Empty(t, completion.Missing)
+
+	userService.SetRequiredProfileFields([]string{config.ProfileFieldDisplayName, config.ProfileFieldDateOfBirth})
+
+	completion, err = userService.ProfileCompletion(ctx, user.ID)
+	require.NoError(t, err)
+	assert.False(t, completion.Complete)
+	assert.Equal(t, []string{"display_name", "date_of_birth"}, completion.Missing)
+
+	displayName := "Test User"
+	require.NoError(t, userService.UpdatePublicProfile(ctx, user.ID, &models.PublicProfileRequest{DisplayName: &displayName}))
+	completion, err = userService.ProfileCompletion(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"date_of_birth"}, completion.Missing)
+
+	dateOfBirth := "1990-04-01"
+	require.NoError(t, userService.UpdatePublicProfile(ctx, user.ID, &models.PublicProfileRequest{DateOfBirth: &dateOfBirth}))
+	completion, err = userService.ProfileCompletion(ctx, user.ID)
+	require.NoError(t, err)
+	assert.True(t, completion.Complete)
+	assert.Empty(t, completion.Missing)
+
+	// An empty value clears the field again
+	cleared := ""
+	require.NoError(t, userService.UpdatePublicProfile(ctx, user.ID, &models.PublicProfileRequest{DateOfBirth: &cleared}))
+	completion, err = userService.ProfileCompletion(ctx, user.ID)
+	require.NoError(t, err)
+	assert.False(t, completion.Complete)
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "sync"
+    "time"
+
+    "auth-service/internal/models"
+    "auth-service/internal/redis"
+
+    "go.uber.org/zap"
+)
+
+const rateLimitPolicyKey = "rate_limit_policy"
+
+// RateLimitPolicyService keeps the admin-managed rate limit overrides in
+// Redis. Like IP bans, lookups are served from an in-process copy that is
+// refreshed periodically, so a change reaches every instance within one
+// refresh interval. A nil service applies no overrides.
+type RateLimitPolicyService struct {
+    redis  *redis.Client
+    logger *zap.SugaredLogger
+
+    mu        sync.RWMutex
+    overrides map[rateLimitOverrideKey]models.RateLimitOverride
+    roles     map[string]bool
+}
+
+type rateLimitOverrideKey struct {
+    role string
+    rule string
+}
+
+func NewRateLimitPolicyService(redis *redis.Client, logger *zap.SugaredLogger) *RateLimitPolicyService {
+    return &RateLimitPolicyService{
+        redis:  redis,
+        logger: logger,
+    }
+}
+
+// Get returns the stored policy; an empty one if none was ever set.
+func (s *RateLimitPolicyService) Get(ctx context.Context) (*models.RateLimitPolicy, error) {
+    raw, err := s.redis.Get(ctx, rateLimitPolicyKey)
+    if err != nil {
+        if err == redis.Nil {
+            return &models.RateLimitPolicy{Overrides: []models.RateLimitOverride{}}, nil
+        }
+        return nil, fmt.Errorf("get rate limit policy: %w", err)
+    }
+
+    policy := &models.RateLimitPolicy{}
+    if err := json.Unmarshal([]byte(raw), policy); err != nil {
+        return nil, fmt.Errorf("decode rate limit policy: %w", err)
+    }
+    return policy, nil
+}
+
+// Set replaces the stored policy and applies it on this instance right away.
+func (s *RateLimitPolicyService) Set(ctx context.Context, policy *models.RateLimitPolicy) (*models.RateLimitPolicy, error) {
+    now := time.Now().UTC()
+    policy.UpdatedAt = &now
+    if policy.Overrides == nil {
+        policy.Overrides = []models.RateLimitOverride{}
+    }
+
+    raw, err := json.Marshal(policy)
+    if err != nil {
+        return nil, fmt.Errorf("encode rate limit policy: %w", err)
+    }
+    if err := s.redis.Set(ctx, rateLimitPolicyKey, raw, 0); err != nil {
+        return nil, fmt.Errorf("store rate limit policy: %w", err)
+    }
+
+    s.apply(policy)
+    return policy, nil
+}
+
+// Refresh reloads the in-process policy from Redis.
+func (s *RateLimitPolicyService) Refresh(ctx context.Context) error {
+    policy, err := s.Get(ctx)
+    if err != nil {
+        return err
+    }
+    s.apply(policy)
+    return nil
+}
+
+func (s *RateLimitPolicyService) apply(policy *models.RateLimitPolicy) {
+    overrides := make(map[rateLimitOverrideKey]models.RateLimitOverride, len(policy.Overrides))
+    roles := make(map[string]bool)
+    for _, override := range policy.Overrides {
+        overrides[rateLimitOverrideKey{override.Role, override.Rule}] = override
+        roles[override.Role] = true
+    }
+
+    s.mu.Lock()
+    s.overrides = overrides
+    s.roles = roles
+    s.mu.Unlock()
+}
+
+// RunRefresh refreshes the cached policy every interval until ctx is
+// cancelled, so changes made on other instances take effect here too.
+func (s *RateLimitPolicyService) RunRefresh(ctx context.Context, interval time.Duration) {
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+
+    for {
+        if err := s.Refresh(ctx); err != nil {
+            s.logger.Errorf("Failed to refresh rate limit policy: %v", err)
+        }
+
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+        }
+    }
+}
+
+// Resolve returns how a rule applies to a role: whether the role is exempt,
+// and otherwise the factor to multiply the rule's limit by. An override for
+// the rule itself wins over one for every rule.
+func (s *RateLimitPolicyService) Resolve(role, rule string) (float64, bool) {
+    if s == nil {
+        return 1, false
+    }
+
+    s.mu.RLock()
+    override, ok := s.overrides[rateLimitOverrideKey{role, rule}]
+    if !ok {
+        override, ok = s.overrides[rateLimitOverrideKey{role, models.RateLimitRuleAll}]
+    }
+    s.mu.RUnlock()
+
+    if !ok {
+        return 1, false
+    }
+    if override.Exempt {
+        return 0, true
+    }
+    if override.Multiplier <= 0 {
+        return 1, false
+    }
+    return override.Multiplier, false
+}
+
+// HasRole reports whether any override applies to role, so callers can skip
+// work needed only to tell that role apart.
+func (s *RateLimitPolicyService) HasRole(role string) bool {
+    if s == nil {
+        return false
+    }
+
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    return s.roles[role]
+}
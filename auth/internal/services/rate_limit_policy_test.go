@@ -0,0 +1,69 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"auth-service/internal/models"
+	"auth-service/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestRateLimitPolicyService_Resolve(t *testing.T) {
+	var unset *RateLimitPolicyService
+	multiplier, exempt := unset.Resolve(models.RateLimitRoleAdmin, "user_search")
+	assert.Equal(t, 1.0, multiplier)
+	assert.False(t, exempt)
+
+	s := NewRateLimitPolicyService(nil, zap.NewNop().Sugar())
+	s.apply(&models.RateLimitPolicy{Overrides: []models.RateLimitOverride{
+		{Role: models.RateLimitRoleService, Rule: models.RateLimitRuleAll, Exempt: true},
+		{Role: models.RateLimitRoleAdmin, Rule: models.RateLimitRuleAll, Multiplier: 2},
+		{Role: models.RateLimitRoleAdmin, Rule: "public_profile", Multiplier: 10},
+	}})
+
+	multiplier, exempt = s.Resolve(models.RateLimitRoleService, models.RateLimitRuleGlobal)
+	assert.True(t, exempt)
+
+	// A rule's own override wins over the catch-all
+	multiplier, exempt = s.Resolve(models.RateLimitRoleAdmin, "public_profile")
+	assert.Equal(t, 10.0, multiplier)
+	assert.False(t, exempt)
+	multiplier, _ = s.Resolve(models.RateLimitRoleAdmin, "user_search")
+	assert.Equal(t, 2.0, multiplier)
+
+	multiplier, exempt = s.Resolve(models.RateLimitRoleUser, "user_search")
+	assert.Equal(t, 1.0, multiplier)
+	assert.False(t, exempt)
+
+	assert.True(t, s.HasRole(models.RateLimitRoleAdmin))
+	assert.False(t, s.HasRole(models.RateLimitRoleUser))
+}
+
+func TestRateLimitPolicyService_SharedThroughRedis(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	ctx := context.Background()
+	admin := NewRateLimitPolicyService(suite.Redis.Client, suite.Logger)
+	other := NewRateLimitPolicyService(suite.Redis.Client, suite.Logger)
+
+	policy, err := admin.Get(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, policy.Overrides)
+
+	_, err = admin.Set(ctx, &models.RateLimitPolicy{Overrides: []models.RateLimitOverride{
+		{Role: models.RateLimitRoleAdmin, Rule: "user_search", Multiplier: 3},
+	}})
+	require.NoError(t, err)
+
+	// Other instances see the change once they refresh
+	multiplier, _ := other.Resolve(models.RateLimitRoleAdmin, "user_search")
+	assert.Equal(t, 1.0, multiplier)
+	require.NoError(t, other.Refresh(ctx))
+	multiplier, _ = other.Resolve(models.RateLimitRoleAdmin, "user_search")
+	assert.Equal(t, 3.0, multiplier)
+}
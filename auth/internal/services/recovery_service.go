@@ -0,0 +1,395 @@
+package services
+
+import (
+    "context"
+    "crypto/rand"
+    "encoding/hex"
+    "fmt"
+    "strings"
+    "time"
+
+    "auth-service/internal/apperr"
+    "auth-service/internal/config"
+    "auth-service/internal/database"
+    "auth-service/internal/events"
+    "auth-service/internal/models"
+    "auth-service/internal/redis"
+
+    "github.com/google/uuid"
+    "github.com/jackc/pgx/v5"
+    "go.uber.org/zap"
+)
+
+const recoveryCodeCount = 10
+
+var (
+    ErrRecoveryRequestNotFound = apperr.New(apperr.NotFound, "Recovery request not found", nil)
+    ErrRecoveryNotPending      = apperr.New(apperr.Conflict, "Recovery request is not pending", nil)
+)
+
+type RecoveryService struct {
+    db     *database.DB
+    redis  *redis.Client
+    config *config.Config
+    logger *zap.SugaredLogger
+    mailer Mailer
+    // Announces the sessions revoked by a completed recovery; nil to skip
+    forcedLogouts *ForcedLogoutService
+}
+
+func NewRecoveryService(db *database.DB, redis *redis.Client, config *config.Config, logger *zap.SugaredLogger) *RecoveryService {
+    return &RecoveryService{
+        db:     db,
+        redis:  redis,
+        config: config,
+        logger: logger,
+    }
+}
+
+// SetMailer sends recovery and verification emails through mailer.
+func (s *RecoveryService) SetMailer(mailer Mailer) {
+    s.mailer = mailer
+}
+
+// SetForcedLogouts announces the sessions revoked when a recovery completes.
+func (s *RecoveryService) SetForcedLogouts(forcedLogouts *ForcedLogoutService) {
+    s.forcedLogouts = forcedLogouts
+}
+
+func (s *RecoveryService) SetRecoveryEmail(ctx context.Context, userID uuid.UUID, email string) error {
+    _, err := s.db.Pool().Exec(ctx,
+        "UPDATE users SET recovery_email = $1, updated_at = NOW() WHERE id = $2",
+        email, userID,
+    )
+    if err != nil {
+        return fmt.Errorf("set recovery email: %w", err)
+    }
+    return nil
+}
+
+// GenerateRecoveryCodes replaces the user's recovery codes with a fresh set.
+// The plaintext codes are returned once and only their hashes are stored.
+func (s *RecoveryService) GenerateRecoveryCodes(ctx context.Context, userID uuid.UUID) ([]string, error) {
+    tx, err := s.db.Pool().Begin(ctx)
+    if err != nil {
+        return nil, fmt.Errorf("begin transaction: %w", err)
+    }
+    defer tx.Rollback(ctx)
+
+    if _, err := tx.Exec(ctx, "DELETE FROM recovery_codes WHERE user_id = $1", userID); err != nil {
+        return nil, fmt.Errorf("delete recovery codes: %w", err)
+    }
+
+    codes := make([]string, recoveryCodeCount)
+    for i := range codes {
+        codes[i] = generateRecoveryCode()
+        if _, err := tx.Exec(ctx,
+            "INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)",
+            userID, hashToken(normalizeRecoveryCode(codes[i])),
+        ); err != nil {
+            return nil, fmt.Errorf("insert recovery code: %w", err)
+        }
+    }
+
+    if err := tx.Commit(ctx); err != nil {
+        return nil, fmt.Errorf("commit transaction: %w", err)
+    }
+
+    if err := forgetSecurityScore(ctx, s.redis, userID); err != nil {
+        s.logger.Errorf("Failed to invalidate security score: %v", err)
+    }
+
+    return codes, nil
+}
+
+// StartRecovery opens a recovery request for the account matching the email
+// or username in req. A recovery token is returned only for the recovery code
+// method, where possession of the code is the proof; the other methods deliver
+// the token out of band. Unknown accounts and failed checks return no error so
+// the endpoint can't be used to probe for accounts; with the recovery code
+// method, unknown accounts fail like wrong codes, with ErrInvalidToken.
+func (s *RecoveryService) StartRecovery(ctx context.Context, req *models.StartRecoveryRequest) (string, error) {
+    var userID uuid.UUID
+    var recoveryEmail *string
+    err := s.db.Pool().QueryRow(ctx,
+        "SELECT id, recovery_email FROM users WHERE email = $1 OR username = $1",
+        req.Identifier,
+    ).Scan(&userID, &recoveryEmail)
+    if err != nil {
+        if err == pgx.ErrNoRows {
+            if req.Method == models.RecoveryMethodCode {
+                return "", ErrInvalidToken
+            }
+            return "", nil
+        }
+        return "", fmt.Errorf("get user: %w", err)
+    }
+
+    switch req.Method {
+    case models.RecoveryMethodEmail:
+        if recoveryEmail == nil {
+            return "", nil
+        }
+        token, err := s.createApprovedRequest(ctx, userID, req.Method, recoveryEmail)
+        if err != nil {
+            return "", err
+        }
+        sendEmail(ctx, s.mailer, s.logger, emailTemplateRecovery, *recoveryEmail, map[string]string{"token": token})
+        return "", nil
+
+    case models.RecoveryMethodCode:
+        result, err := s.db.Pool().Exec(ctx,
+            `UPDATE recovery_codes SET used_at = NOW()
+             WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`,
+            userID, hashToken(normalizeRecoveryCode(req.RecoveryCode)),
+        )
+        if err != nil {
+            return "", fmt.Errorf("use recovery code: %w", err)
+        }
+        if result.RowsAffected() == 0 {
+            return "", ErrInvalidToken
+        }
+        if err := forgetSecurityScore(ctx, s.redis, userID); err != nil {
+            s.logger.Errorf("Failed to invalidate security score: %v", err)
+        }
+        return s.createApprovedRequest(ctx, userID, req.Method, nil)
+
+    default:
+        _, err := s.db.Pool().Exec(ctx,
+            `INSERT INTO recovery_requests (user_id, method, status, contact_email, details)
+             VALUES ($1, $2, $3, $4, $5)`,
+            userID, req.Method, models.RecoveryStatusPending, req.ContactEmail, req.Details,
+        )
+        if err != nil {
+            return "", fmt.Errorf("create recovery request: %w", err)
+        }
+        return "", nil
+    }
+}
+
+func (s *RecoveryService) ListRequests(ctx context.Context, status string) ([]*models.RecoveryRequest, error) {
+    rows, err := s.db.Pool().Query(ctx,
+        `SELECT id, user_id, method, status, contact_email, details, token_expires_at,
+                reviewed_by, reviewed_at, created_at
+         FROM recovery_requests WHERE method = $1 AND ($2 = '' OR status = $2)
+         ORDER BY created_at`,
+        models.RecoveryMethodManualReview, status,
+    )
+    if err != nil {
+        return nil, fmt.Errorf("list recovery requests: %w", err)
+    }
+    defer rows.Close()
+
+    requests := []*models.RecoveryRequest{}
+    for rows.Next() {
+        r := &models.RecoveryRequest{}
+        if err := rows.Scan(&r.ID, &r.UserID, &r.Method, &r.Status, &r.ContactEmail, &r.Details,
+            &r.TokenExpiresAt, &r.ReviewedBy, &r.ReviewedAt, &r.CreatedAt); err != nil {
+            return nil, fmt.Errorf("scan recovery request: %w", err)
+        }
+        requests = append(requests, r)
+    }
+
+    return requests, rows.Err()
+}
+
+func (s *RecoveryService) GetRequest(ctx context.Context, requestID uuid.UUID) (*models.RecoveryRequest, error) {
+    r := &models.RecoveryRequest{}
+    err := s.db.Pool().QueryRow(ctx,
+        `SELECT id, user_id, method, status, contact_email, details, token_expires_at,
+                reviewed_by, reviewed_at, created_at
+         FROM recovery_requests WHERE id = $1`,
+        requestID,
+    ).Scan(&r.ID, &r.UserID, &r.Method, &r.Status, &r.ContactEmail, &r.Details,
+        &r.TokenExpiresAt, &r.ReviewedBy, &r.ReviewedAt, &r.CreatedAt)
+    if err != nil {
+        if err == pgx.ErrNoRows {
+            return nil, ErrRecoveryRequestNotFound
+        }
+        return nil, fmt.Errorf("get recovery request: %w", err)
+    }
+
+    return r, nil
+}
+
+// ApproveRequest is called by an admin once the requester's identity has been
+// verified. It issues a recovery token delivered to the contact email.
+func (s *RecoveryService) ApproveRequest(ctx context.Context, requestID, adminID uuid.UUID) error {
+    token := generateToken()
+
+    var contactEmail *string
+    err := s.db.Pool().QueryRow(ctx,
+        `UPDATE recovery_requests
+         SET status = $1, token_hash = $2, token_expires_at = $3, reviewed_by = $4, reviewed_at = NOW()
+         WHERE id = $5 AND status = $6
+         RETURNING contact_email`,
+        models.RecoveryStatusApproved, hashToken(token), time.Now().Add(s.config.RecoveryTokenExpiry),
+        adminID, requestID, models.RecoveryStatusPending,
+    ).Scan(&contactEmail)
+    if err != nil {
+        if err == pgx.ErrNoRows {
+            return s.notPendingOrMissing(ctx, requestID)
+        }
+        return fmt.Errorf("approve recovery request: %w", err)
+    }
+
+    if contactEmail != nil {
+        sendEmail(ctx, s.mailer, s.logger, emailTemplateRecovery, *contactEmail, map[string]string{"token": token})
+    }
+
+    return nil
+}
+
+func (s *RecoveryService) RejectRequest(ctx context.Context, requestID, adminID uuid.UUID) error {
+    result, err := s.db.Pool().Exec(ctx,
+        `UPDATE recovery_requests SET status = $1, reviewed_by = $2, reviewed_at = NOW()
+         WHERE id = $3 AND status = $4`,
+        models.RecoveryStatusRejected, adminID, requestID, models.RecoveryStatusPending,
+    )
+    if err != nil {
+        return fmt.Errorf("reject recovery request: %w", err)
+    }
+    if result.RowsAffected() == 0 {
+        return s.notPendingOrMissing(ctx, requestID)
+    }
+    return nil
+}
+
+// CompleteRecovery consumes a recovery token, replaces the account's email
+// and password, and revokes all existing sessions. The new email must be
+// verified again.
+func (s *RecoveryService) CompleteRecovery(ctx context.Context, req *models.CompleteRecoveryRequest) error {
+    hashedPassword, err := passwords.Hash(ctx, req.Password)
+    if err != nil {
+        return err
+    }
+
+    tx, err := s.db.Pool().Begin(ctx)
+    if err != nil {
+        return fmt.Errorf("begin transaction: %w", err)
+    }
+    defer tx.Rollback(ctx)
+
+    var userID uuid.UUID
+    err = tx.QueryRow(ctx,
+        `UPDATE recovery_requests SET status = $1
+         WHERE token_hash = $2 AND status = $3 AND token_expires_at > NOW()
+         RETURNING user_id`,
+        models.RecoveryStatusCompleted, hashToken(req.Token), models.RecoveryStatusApproved,
+    ).Scan(&userID)
+    if err != nil {
+        if err == pgx.ErrNoRows {
+            return ErrInvalidToken
+        }
+        return fmt.Errorf("consume recovery token: %w", err)
+    }
+
+    var exists bool
+    err = tx.QueryRow(ctx,
+        "SELECT EXISTS(SELECT 1 FROM users WHERE email = $1 AND id <> $2)",
+        req.Email, userID,
+    ).Scan(&exists)
+    if err != nil {
+        return fmt.Errorf("check email: %w", err)
+    }
+    if exists {
+        return ErrEmailAlreadyExists
+    }
+
+    var oldEmail, username string
+    var wasVerified bool
+    var seq int64
+    err = tx.QueryRow(ctx,
+        `UPDATE users u SET email = $1, password_hash = $2, password_changed_at = NOW(), email_verified = false,
+                change_seq = u.change_seq + CASE WHEN old.email <> $1 THEN 1 ELSE 0 END,
+                reset_token = NULL, reset_expiry = NULL, updated_at = NOW()
+         FROM (SELECT email, email_verified FROM users WHERE id = $3 FOR UPDATE) old
+         WHERE u.id = $3
+         RETURNING old.email, old.email_verified, u.username, u.change_seq`,
+        req.Email, hashedPassword, userID,
+    ).Scan(&oldEmail, &wasVerified, &username, &seq)
+    if err != nil {
+        return fmt.Errorf("update user: %w", err)
+    }
+
+    if oldEmail != req.Email {
+        event := events.NewUserEvent(events.UserEmailChanged, userID.String(), username)
+        event.Data["old_email"] = oldEmail
+        event.Data["new_email"] = req.Email
+        event.Data["seq"] = seq
+        if err := enqueueEvent(ctx, tx, event); err != nil {
+            return err
+        }
+    }
+
+    if wasVerified {
+        if err := enqueueBadgesChanged(ctx, tx, userID, username, models.BadgeEmailVerified, false); err != nil {
+            return err
+        }
+    }
+
+    emailToken, err := issueEmailVerificationToken(ctx, tx, userID, req.Email, time.Now().Add(s.config.EmailVerificationExpiry))
+    if err != nil {
+        return err
+    }
+
+    if _, err := tx.Exec(ctx, "DELETE FROM sessions WHERE user_id = $1", userID); err != nil {
+        return fmt.Errorf("delete sessions: %w", err)
+    }
+
+    if err := tx.Commit(ctx); err != nil {
+        return fmt.Errorf("commit transaction: %w", err)
+    }
+
+    if err := forgetSecurityScore(ctx, s.redis, userID); err != nil {
+        s.logger.Errorf("Failed to invalidate security score: %v", err)
+    }
+    s.forcedLogouts.Publish(ctx, userID, nil, models.LogoutReasonRecovered)
+
+    sendEmail(ctx, s.mailer, s.logger, emailTemplateVerification, req.Email, map[string]string{"token": emailToken})
+
+    return nil
+}
+
+func (s *RecoveryService) createApprovedRequest(ctx context.Context, userID uuid.UUID, method string, contactEmail *string) (string, error) {
+    token := generateToken()
+
+    _, err := s.db.Pool().Exec(ctx,
+        `INSERT INTO recovery_requests (user_id, method, status, contact_email, token_hash, token_expires_at)
+         VALUES ($1, $2, $3, $4, $5, $6)`,
+        userID, method, models.RecoveryStatusApproved, contactEmail,
+        hashToken(token), time.Now().Add(s.config.RecoveryTokenExpiry),
+    )
+    if err != nil {
+        return "", fmt.Errorf("create recovery request: %w", err)
+    }
+
+    return token, nil
+}
+
+func (s *RecoveryService) notPendingOrMissing(ctx context.Context, requestID uuid.UUID) error {
+    var exists bool
+    err := s.db.Pool().QueryRow(ctx,
+        "SELECT EXISTS(SELECT 1 FROM recovery_requests WHERE id = $1)",
+        requestID,
+    ).Scan(&exists)
+    if err != nil {
+        return fmt.Errorf("check recovery request: %w", err)
+    }
+    if !exists {
+        return ErrRecoveryRequestNotFound
+    }
+    return ErrRecoveryNotPending
+}
+
+// generateRecoveryCode returns a code like "4f1a-9c2e-77b0".
+func generateRecoveryCode() string {
+    b := make([]byte, 6)
+    rand.Read(b)
+    h := hex.EncodeToString(b)
+    return h[0:4] + "-" + h[4:8] + "-" + h[8:12]
+}
+
+func normalizeRecoveryCode(code string) string {
+    return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"auth-service/internal/models"
+	"auth-service/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecoveryService_RecoveryCodeFlow(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	recoveryService := NewRecoveryService(suite.DB.DB, suite.Redis.Client, suite.Config, suite.Logger)
+	user := suite.CreateTestUser(t, "lost@example.com", "lostuser", "password123")
+	suite.CreateTestSession(t, user.ID)
+
+	codes, err := recoveryService.GenerateRecoveryCodes(context.Background(), user.ID)
+	require.NoError(t, err)
+	require.Len(t, codes, recoveryCodeCount)
+
+	// Codes are accepted regardless of case and dashes
+	token, err := recoveryService.StartRecovery(context.Background(), &models.StartRecoveryRequest{
+		Identifier:   "lostuser",
+		Method:       models.RecoveryMethodCode,
+		RecoveryCode: "  " + codes[0],
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, token)
+
+	// A code can only be used once
+	_, err = recoveryService.StartRecovery(context.Background(), &models.StartRecoveryRequest{
+		Identifier:   "lostuser",
+		Method:       models.RecoveryMethodCode,
+		RecoveryCode: codes[0],
+	})
+	assert.Equal(t, ErrInvalidToken, err)
+
+	// Unknown accounts fail like wrong codes, so codes can't probe for accounts
+	_, err = recoveryService.StartRecovery(context.Background(), &models.StartRecoveryRequest{
+		Identifier:   "nobody",
+		Method:       models.RecoveryMethodCode,
+		RecoveryCode: codes[1],
+	})
+	assert.Equal(t, ErrInvalidToken, err)
+
+	err = recoveryService.CompleteRecovery(context.Background(), &models.CompleteRecoveryRequest{
+		Token:    token,
+		Email:    "found@example.com",
+		Password: "newpassword123",
+	})
+	require.NoError(t, err)
+
+	var email string
+	var verified bool
+	var sessions int
+	err = suite.DB.Pool().QueryRow(context.Background(),
+		"SELECT email, email_verified FROM users WHERE id = $1", user.ID,
+	).Scan(&email, &verified)
+	require.NoError(t, err)
+	assert.Equal(t, "found@example.com", email)
+	assert.False(t, verified)
+
+	err = suite.DB.Pool().QueryRow(context.Background(),
+		"SELECT COUNT(*) FROM sessions WHERE user_id = $1", user.ID,
+	).Scan(&sessions)
+	require.NoError(t, err)
+	assert.Zero(t, sessions)
+
+	// The token is single use
+	err = recoveryService.CompleteRecovery(context.Background(), &models.CompleteRecoveryRequest{
+		Token:    token,
+		Email:    "again@example.com",
+		Password: "newpassword123",
+	})
+	assert.Equal(t, ErrInvalidToken, err)
+}
+
+func TestRecoveryService_ManualReview(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	recoveryService := NewRecoveryService(suite.DB.DB, suite.Redis.Client, suite.Config, suite.Logger)
+	suite.CreateTestUser(t, "lost@example.com", "lostuser", "password123")
+	admin := suite.CreateTestUser(t, "admin@example.com", "admin", "password123")
+
+	token, err := recoveryService.StartRecovery(context.Background(), &models.StartRecoveryRequest{
+		Identifier:   "lost@example.com",
+		Method:       models.RecoveryMethodManualReview,
+		ContactEmail: "new-inbox@example.com",
+		Details:      "Lost access to my work email",
+	})
+	require.NoError(t, err)
+	assert.Empty(t, token)
+
+	pending, err := recoveryService.ListRequests(context.Background(), models.RecoveryStatusPending)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+
+	require.NoError(t, recoveryService.ApproveRequest(context.Background(), pending[0].ID, admin.ID))
+	assert.Equal(t, ErrRecoveryNotPending, recoveryService.RejectRequest(context.Background(), pending[0].ID, admin.ID))
+
+	// Unknown accounts are indistinguishable from known ones
+	token, err = recoveryService.StartRecovery(context.Background(), &models.StartRecoveryRequest{
+		Identifier:   "nobody@example.com",
+		Method:       models.RecoveryMethodManualReview,
+		ContactEmail: "x@example.com",
+	})
+	require.NoError(t, err)
+	assert.Empty(t, token)
+}
+
+func TestRecoveryService_DeliversTokens(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	ctx := context.Background()
+	sandbox := NewSandboxMailer(suite.DB.DB)
+	recoveryService := NewRecoveryService(suite.DB.DB, suite.Redis.Client, suite.Config, suite.Logger)
+	recoveryService.SetMailer(sandbox)
+	user := suite.CreateTestUser(t, "lost@example.com", "lostuser", "password123")
+	admin := suite.CreateTestUser(t, "admin@example.com", "admin", "password123")
+	require.NoError(t, recoveryService.SetRecoveryEmail(ctx, user.ID, "backup@example.com"))
+
+	// The recovery email method mails the token to the recovery address
+	token, err := recoveryService.StartRecovery(ctx, &models.StartRecoveryRequest{
+		Identifier: "lostuser",
+		Method:     models.RecoveryMethodEmail,
+	})
+	require.NoError(t, err)
+	assert.Empty(t, token)
+
+	// An approved manual review mails the token to the contact address
+	_, err = recoveryService.StartRecovery(ctx, &models.StartRecoveryRequest{
+		Identifier:   "lost@example.com",
+		Method:       models.RecoveryMethodManualReview,
+		ContactEmail: "new-inbox@example.com",
+	})
+	require.NoError(t, err)
+	pending, err := recoveryService.ListRequests(ctx, models.RecoveryStatusPending)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	require.NoError(t, recoveryService.ApproveRequest(ctx, pending[0].ID, admin.ID))
+
+	emails, err := sandbox.List(ctx, models.SentEmailFilter{Template: emailTemplateRecovery})
+	require.NoError(t, err)
+	require.Len(t, emails, 2)
+	recipients := []string{emails[0].To, emails[1].To}
+	assert.ElementsMatch(t, []string{"backup@example.com", "new-inbox@example.com"}, recipients)
+
+	// Either delivered token completes the recovery
+	require.NoError(t, recoveryService.CompleteRecovery(ctx, &models.CompleteRecoveryRequest{
+		Token:    emails[0].Data["token"],
+		Email:    "found@example.com",
+		Password: "newpassword123",
+	}))
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+    "context"
+    "fmt"
+    "time"
+
+    "auth-service/internal/apperr"
+    "auth-service/internal/metrics"
+    "auth-service/internal/models"
+    "auth-service/internal/redis"
+
+    "go.uber.org/zap"
+)
+
+const (
+    refreshFailureWindow     = 15 * time.Minute
+    refreshFailureThreshold  = 5
+    refreshAlertThreshold    = 50
+    refreshTokenPrefixLength = 8
+    refreshBaseBlock         = 2 * time.Second
+    refreshMaxBlock          = 15 * time.Minute
+    refreshAutoBanDuration   = time.Hour
+)
+
+var ErrRefreshThrottled = apperr.New(apperr.RateLimited, "Too many failed refresh attempts", nil)
+
+// RefreshGuard throttles refresh token guessing. Failed refreshes are counted
+// per client IP and per token prefix; once a counter passes the threshold the
+// scope is blocked for a delay that doubles with every further failure. A
+// sustained run of failures raises an alert once per window, and an IP that
+// triggers it is banned outright for refreshAutoBanDuration. While Redis is
+// unavailable, failures are counted and blocks held in process memory, and
+// blocking starts at half the threshold. A nil RefreshGuard never blocks.
+type RefreshGuard struct {
+    redis  *redis.Client
+    local  *localLimiter
+    ipBans *IPBanService
+    logger *zap.SugaredLogger
+}
+
+func NewRefreshGuard(redis *redis.Client, ipBans *IPBanService, logger *zap.SugaredLogger) *RefreshGuard {
+    return &RefreshGuard{
+        redis:  redis,
+        local:  newLocalLimiter(),
+        ipBans: ipBans,
+        logger: logger,
+    }
+}
+
+// Check returns ErrRefreshThrottled and the remaining block time if either the
+// IP or the token prefix is currently blocked.
+func (g *RefreshGuard) Check(ctx context.Context, ip, token string) (time.Duration, error) {
+    if g == nil {
+        return 0, nil
+    }
+
+    var wait time.Duration
+    for _, scope := range refreshScopes(ip, token) {
+        ttl, err := g.redis.TTL(ctx, scope.key+":blocked")
+        if err != nil {
+            if err != redis.ErrUnavailable {
+                return 0, fmt.Errorf("check refresh block: %w", err)
+            }
+            ttl = g.local.TTL(scope.key + ":blocked")
+        }
+        if ttl > wait {
+            wait = ttl
+        }
+    }
+
+    if wait > 0 {
+        return wait, ErrRefreshThrottled
+    }
+    return 0, nil
+}
+
+// RecordFailure counts a failed refresh against the IP and the token prefix
+// and blocks whichever scope has passed the threshold.
+func (g *RefreshGuard) RecordFailure(ctx context.Context, ip, token string) error {
+    if g == nil {
+        return nil
+    }
+
+    for _, scope := range refreshScopes(ip, token) {
+        failures, err := g.redis.Incr(ctx, scope.key+":failures")
+        if err == redis.ErrUnavailable {
+            // Shift the local count so blocking starts at the degraded
+            // threshold; alerts and bans need Redis and are skipped
+            failures = g.local.Incr(scope.key+":failures", refreshFailureWindow)
+            failures += refreshFailureThreshold - int64(degradedLimit(refreshFailureThreshold))
+            if block := refreshBlockDuration(failures); block > 0 {
+                g.local.Lock(scope.key+":blocked", block)
+            }
+            continue
+        }
+        if err != nil {
+            return fmt.Errorf("count refresh failure: %w", err)
+        }
+        if failures == 1 {
+            if err := g.redis.Expire(ctx, scope.key+":failures", refreshFailureWindow); err != nil {
+                return fmt.Errorf("expire refresh failures: %w", err)
+            }
+        }
+
+        if block := refreshBlockDuration(failures); block > 0 {
+            if err := g.redis.Set(ctx, scope.key+":blocked", "1", block); err != nil {
+                return fmt.Errorf("block refresh: %w", err)
+            }
+        }
+
+        if failures == refreshAlertThreshold {
+            metrics.RefreshBruteForceAlerts.WithLabelValues(scope.name).Inc()
+            g.logger.Warnw("Sustained refresh token guessing detected",
+                "scope", scope.name,
+                "value", scope.value,
+                "failures", failures,
+                "window", refreshFailureWindow.String(),
+            )
+
+            if scope.name == "ip" {
+                if _, err := g.ipBans.Ban(ctx, scope.value, "refresh token guessing", models.IPBanSourceAuto, refreshAutoBanDuration); err != nil {
+                    g.logger.Errorf("Failed to ban %s after refresh token guessing: %v", scope.value, err)
+                }
+            }
+        }
+    }
+
+    return nil
+}
+
+type refreshScope struct {
+    name  string
+    value string
+    key   string
+}
+
+func refreshScopes(ip, token string) []refreshScope {
+    prefix := token
+    if len(prefix) > refreshTokenPrefixLength {
+        prefix = prefix[:refreshTokenPrefixLength]
+    }
+
+    return []refreshScope{
+        {name: "ip", value: ip, key: "refresh_guard:ip:" + ip},
+        {name: "token_prefix", value: prefix, key: "refresh_guard:prefix:" + prefix},
+    }
+}
+
+// refreshBlockDuration returns how long to block after the given number of
+// failures in the window: nothing up to the threshold, then a delay that
+// doubles with each failure, capped at refreshMaxBlock.
+func refreshBlockDuration(failures int64) time.Duration {
+    if failures < refreshFailureThreshold {
+        return 0
+    }
+
+    block := refreshBaseBlock
+    for i := int64(refreshFailureThreshold); i < failures; i++ {
+        block *= 2
+        if block >= refreshMaxBlock {
+            return refreshMaxBlock
+        }
+    }
+    return block
+}
@@ -0,0 +1,36 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRefreshBlockDuration(t *testing.T) {
+	tests := []struct {
+		failures int64
+		want     time.Duration
+	}{
+		{1, 0},
+		{refreshFailureThreshold - 1, 0},
+		{refreshFailureThreshold, refreshBaseBlock},
+		{refreshFailureThreshold + 1, 2 * refreshBaseBlock},
+		{refreshFailureThreshold + 3, 8 * refreshBaseBlock},
+		{refreshFailureThreshold + 30, refreshMaxBlock},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, refreshBlockDuration(tt.failures), "failures=%d", tt.failures)
+	}
+}
+
+func TestRefreshScopes(t *testing.T) {
+	scopes := refreshScopes("10.0.0.1", "abcdef0123456789")
+
+	assert.Equal(t, "refresh_guard:ip:10.0.0.1", scopes[0].key)
+	assert.Equal(t, "refresh_guard:prefix:abcdef01", scopes[1].key)
+
+	short := refreshScopes("10.0.0.1", "abc")
+	assert.Equal(t, "refresh_guard:prefix:abc", short[1].key)
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+    "context"
+    "crypto/subtle"
+    "fmt"
+    "strings"
+    "time"
+
+    "auth-service/internal/apperr"
+    "auth-service/internal/redis"
+)
+
+const (
+    // How long a pre-registration email code stays valid
+    registrationCodeExpiry = 15 * time.Minute
+    // Shortest time between two codes sent to the same address
+    registrationCodeCooldown = time.Minute
+    // Wrong codes allowed before the code is thrown away
+    maxRegistrationCodeFailures = 5
+)
+
+var (
+    ErrRegistrationCodeRequired  = apperr.New(apperr.Invalid, "Email code required", nil)
+    ErrInvalidRegistrationCode   = apperr.New(apperr.Invalid, "Invalid or expired email code", nil)
+    ErrRegistrationCodeThrottled = apperr.New(apperr.RateLimited, "Email code sent too recently", nil).WithRetryAfter(registrationCodeCooldown)
+)
+
+// RegistrationCodeRequired reports whether registering needs an email code
+// from StartRegistration, so only verified addresses get accounts.
+func (s *AuthService) RegistrationCodeRequired() bool {
+    return s.config.RegistrationEmailCode
+}
+
+// StartRegistration emails a code that Register must be given to create an
+// account for email. Addresses that already have an account get no code, but
+// the caller isn't told, so the endpoint can't be used to probe for accounts.
+func (s *AuthService) StartRegistration(ctx context.Context, email string) error {
+    key := strings.ToLower(email)
+
+    sent, err := s.redis.SetNX(ctx, "registration_code_sent:"+key, 1, registrationCodeCooldown)
+    if err != nil {
+        return fmt.Errorf("check registration code cooldown: %w", err)
+    }
+    if !sent {
+        return ErrRegistrationCodeThrottled
+    }
+
+    var exists bool
+    err = s.db.Pool().QueryRow(ctx,
+        "SELECT EXISTS(SELECT 1 FROM users WHERE email = $1)",
+        email,
+    ).Scan(&exists)
+    if err != nil {
+        return fmt.Errorf("check email: %w", err)
+    }
+    if exists {
+        return nil
+    }
+
+    code := generateEmailCode()
+    if err := s.redis.Set(ctx, "registration_code:"+key, hashToken(code), registrationCodeExpiry); err != nil {
+        return fmt.Errorf("store registration code: %w", err)
+    }
+    if err := s.redis.Delete(ctx, "registration_code_failures:"+key); err != nil {
+        return fmt.Errorf("reset registration code failures: %w", err)
+    }
+
+    s.sendRegistrationCode(ctx, email, code)
+    return nil
+}
+
+// checkRegistrationCode checks the code sent to email without using it up,
+// so a registration that fails for another reason can be retried with it.
+// Too many wrong codes throw it away.
+func (s *AuthService) checkRegistrationCode(ctx context.Context, email, code string) error {
+    if code == "" {
+        return ErrRegistrationCodeRequired
+    }
+    key := strings.ToLower(email)
+
+    stored, err := s.redis.Get(ctx, "registration_code:"+key)
+    if err != nil {
+        if err == redis.Nil {
+            return ErrInvalidRegistrationCode
+        }
+        return fmt.Errorf("get registration code: %w", err)
+    }
+    if subtle.ConstantTimeCompare([]byte(stored), []byte(hashToken(code))) == 1 {
+        return nil
+    }
+
+    failures, err := s.redis.Incr(ctx, "registration_code_failures:"+key)
+    if err != nil {
+        return fmt.Errorf("count registration code failure: %w", err)
+    }
+    if failures == 1 {
+        if err := s.redis.Expire(ctx, "registration_code_failures:"+key, registrationCodeExpiry); err != nil {
+            s.logger.Errorf("Failed to expire registration code failures: %v", err)
+        }
+    }
+    if failures >= maxRegistrationCodeFailures {
+        if err := s.redis.Delete(ctx, "registration_code:"+key, "registration_code_failures:"+key); err != nil {
+            s.logger.Errorf("Failed to discard registration code: %v", err)
+        }
+    }
+    return ErrInvalidRegistrationCode
+}
+
+// forgetRegistrationCode discards the code once the account exists.
+func (s *AuthService) forgetRegistrationCode(ctx context.Context, email string) {
+    key := strings.ToLower(email)
+    if err := s.redis.Delete(ctx, "registration_code:"+key, "registration_code_failures:"+key); err != nil {
+        s.logger.Errorf("Failed to discard registration code: %v", err)
+    }
+}
+
+func (s *AuthService) sendRegistrationCode(ctx context.Context, email, code string) {
+    sendEmail(ctx, s.mailer, s.logger, emailTemplateRegistrationCode, email, map[string]string{"code": code})
+}
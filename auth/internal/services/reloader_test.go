@@ -0,0 +1,64 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestReloader_Reload(t *testing.T) {
+	reloader := NewReloader(zap.NewNop().Sugar())
+	reloader.Register("broken", func() (bool, string, error) {
+		return false, "", errors.New("file missing")
+	})
+	reloader.Register("rotated", func() (bool, string, error) {
+		return true, "abc", nil
+	})
+
+	results := reloader.Reload()
+	require.Len(t, results, 2)
+
+	// A failure doesn't stop later resources
+	assert.Equal(t, "broken", results[0].Name)
+	assert.Equal(t, "file missing", results[0].Error)
+	assert.False(t, results[0].Changed)
+	assert.Equal(t, "rotated", results[1].Name)
+	assert.True(t, results[1].Changed)
+	assert.Equal(t, "abc", results[1].Fingerprint)
+}
+
+func TestGeoBlockService_ReloadGeoIP(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "geoip.csv")
+	require.NoError(t, os.WriteFile(path, []byte("10.0.0.0/24,FR\n"), 0o600))
+
+	geoBlock := NewGeoBlockService(nil, []string{"FR"}, "", nil, nil)
+	assert.Equal(t, "", geoBlock.Country("10.0.0.7", ""))
+
+	changed, err := geoBlock.ReloadGeoIP(path)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, "FR", geoBlock.Country("10.0.0.7", ""))
+	first := geoBlock.GeoIPFingerprint()
+
+	changed, err = geoBlock.ReloadGeoIP(path)
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	require.NoError(t, os.WriteFile(path, []byte("10.0.0.0/24,DE\n"), 0o600))
+	changed, err = geoBlock.ReloadGeoIP(path)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.NotEqual(t, first, geoBlock.GeoIPFingerprint())
+	assert.Equal(t, "DE", geoBlock.Country("10.0.0.7", ""))
+
+	// A broken file keeps the loaded database
+	require.NoError(t, os.WriteFile(path, []byte("bogus\nbogus\n"), 0o600))
+	_, err = geoBlock.ReloadGeoIP(path)
+	assert.Error(t, err)
+	assert.Equal(t, "DE", geoBlock.Country("10.0.0.7", ""))
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"auth-service/pkg/signing"
+	"auth-service/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestVerifier_Verify(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	secret := strings.Repeat("k", 32)
+	verifier := NewRequestVerifier(map[string]string{"chat": secret}, suite.Redis.Client)
+	ctx := context.Background()
+	body := []byte(`{"ids":[]}`)
+
+	signed := func(keyID, secret string, at time.Time) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/internal/users/batch", strings.NewReader(string(body)))
+		require.NoError(t, signing.Sign(req, keyID, []byte(secret), at))
+		return req
+	}
+
+	req := signed("chat", secret, time.Now())
+	keyID, err := verifier.Verify(ctx, req, body)
+	require.NoError(t, err)
+	assert.Equal(t, "chat", keyID)
+
+	// The same nonce is only accepted once
+	_, err = verifier.Verify(ctx, req, body)
+	assert.ErrorIs(t, err, ErrSignatureReplayed)
+
+	_, err = verifier.Verify(ctx, signed("chat", secret, time.Now().Add(-10*time.Minute)), body)
+	assert.ErrorIs(t, err, ErrSignatureExpired)
+
+	_, err = verifier.Verify(ctx, signed("chat", strings.Repeat("x", 32), time.Now()), body)
+	assert.ErrorIs(t, err, ErrSignatureInvalid)
+
+	_, err = verifier.Verify(ctx, signed("user", secret, time.Now()), body)
+	assert.ErrorIs(t, err, ErrSignatureInvalid)
+
+	// A tampered body no longer matches the digest
+	_, err = verifier.Verify(ctx, signed("chat", secret, time.Now()), []byte(`{"ids":["x"]}`))
+	assert.ErrorIs(t, err, ErrSignatureInvalid)
+}
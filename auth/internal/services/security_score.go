@@ -0,0 +1,109 @@
+package services
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "time"
+
+    "auth-service/internal/models"
+    "auth-service/internal/redis"
+
+    "github.com/google/uuid"
+    "github.com/jackc/pgx/v5"
+)
+
+const (
+    // Passwords older than this fail the recent password check
+    securityPasswordMaxAge = 365 * 24 * time.Hour
+    // Scores are invalidated when an input changes; the TTL only bounds
+    // staleness from changes that can't be tracked, like a password aging
+    securityScoreTTL = time.Hour
+)
+
+// Checklist items and their points, which add up to 100
+var securityChecks = []struct {
+    check  string
+    points int
+}{
+    {models.SecurityCheckMFA, 30},
+    {models.SecurityCheckRecoveryCodes, 15},
+    {models.SecurityCheckRecentPassword, 15},
+    {models.SecurityCheckVerifiedEmail, 20},
+    {models.SecurityCheckNoRiskySessions, 20},
+}
+
+func securityScoreKey(userID uuid.UUID) string {
+    return fmt.Sprintf("user:%s:security_score", userID)
+}
+
+// forgetSecurityScore drops the user's cached security score so the next
+// request recomputes it. Call it after changing any of its inputs.
+func forgetSecurityScore(ctx context.Context, client *redis.Client, userID uuid.UUID) error {
+    return client.Delete(ctx, securityScoreKey(userID))
+}
+
+// SecurityScore returns the user's security checklist and score, from the
+// cache when possible. Cache errors are logged and the score recomputed.
+func (s *UserService) SecurityScore(ctx context.Context, userID uuid.UUID) (*models.SecurityScore, error) {
+    key := securityScoreKey(userID)
+    raw, err := s.redis.Get(ctx, key)
+    if err == nil {
+        score := &models.SecurityScore{}
+        if err := json.Unmarshal([]byte(raw), score); err == nil {
+            return score, nil
+        }
+    } else if err != redis.Nil {
+        s.logger.Errorf("Failed to get cached security score: %v", err)
+    }
+
+    score, err := s.computeSecurityScore(ctx, userID)
+    if err != nil {
+        return nil, err
+    }
+
+    if encoded, err := json.Marshal(score); err == nil {
+        if err := s.redis.Set(ctx, key, encoded, securityScoreTTL); err != nil {
+            s.logger.Errorf("Failed to cache security score: %v", err)
+        }
+    }
+    return score, nil
+}
+
+func (s *UserService) computeSecurityScore(ctx context.Context, userID uuid.UUID) (*models.SecurityScore, error) {
+    var mfa, recoveryCodes, recentPassword, verified, riskySessions bool
+    err := s.db.Pool().QueryRow(ctx,
+        `SELECT u.totp_enabled_at IS NOT NULL,
+                EXISTS(SELECT 1 FROM recovery_codes WHERE user_id = u.id AND used_at IS NULL),
+                COALESCE(u.password_changed_at, u.created_at) > $2,
+                u.email_verified AND u.email_bounced_at IS NULL,
+                EXISTS(SELECT 1 FROM sessions
+                       WHERE user_id = u.id AND anomaly_at IS NOT NULL AND NOT trusted AND expires_at > NOW())
+         FROM users u WHERE u.id = $1`,
+        userID, time.Now().Add(-securityPasswordMaxAge),
+    ).Scan(&mfa, &recoveryCodes, &recentPassword, &verified, &riskySessions)
+    if err != nil {
+        if err == pgx.ErrNoRows {
+            return nil, ErrUserNotFound
+        }
+        return nil, fmt.Errorf("get security score inputs: %w", err)
+    }
+
+    now := time.Now().UTC()
+    passed := map[string]bool{
+        models.SecurityCheckMFA:             mfa,
+        models.SecurityCheckRecoveryCodes:   recoveryCodes,
+        models.SecurityCheckRecentPassword:  recentPassword,
+        models.SecurityCheckVerifiedEmail:   verified,
+        models.SecurityCheckNoRiskySessions: !riskySessions,
+    }
+
+    score := &models.SecurityScore{Checks: make([]models.SecurityCheck, 0, len(securityChecks)), ComputedAt: now}
+    for _, c := range securityChecks {
+        score.Checks = append(score.Checks, models.SecurityCheck{Check: c.check, Passed: passed[c.check], Points: c.points})
+        if passed[c.check] {
+            score.Score += c.points
+        }
+    }
+    return score, nil
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"auth-service/internal/models"
+	"auth-service/test"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserService_SecurityScore(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	ctx := context.Background()
+	userService := NewUserService(suite.DB.DB, suite.Redis.Client, suite.Logger)
+	authService := NewAuthService(suite.DB.DB, suite.Redis.Client, suite.Config, suite.Logger, suite.Events)
+	recoveryService := NewRecoveryService(suite.DB.DB, suite.Redis.Client, suite.Config, suite.Logger)
+	user := suite.CreateTestUser(t, test.TestData.ValidEmail, test.TestData.ValidUsername, test.TestData.ValidPassword)
+
+	passed := func(score *models.SecurityScore) []string {
+		checks := []string{}
+		for _, check := range score.Checks {
+			if check.Passed {
+				checks = append(checks, check.Check)
+			}
+		}
+		return checks
+	}
+
+	// A fresh, verified account
+	score, err := userService.SecurityScore(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 55, score.Score)
+	assert.Len(t, score.Checks, 5)
+	assert.Equal(t, []string{models.SecurityCheckRecentPassword, models.SecurityCheckVerifiedEmail, models.SecurityCheckNoRiskySessions}, passed(score))
+
+	// Generating recovery codes invalidates the cached score
+	_, err = recoveryService.GenerateRecoveryCodes(ctx, user.ID)
+	require.NoError(t, err)
+	score, err = userService.SecurityScore(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 70, score.Score)
+
+	// An untrusted session with an anomaly is risky
+	session := suite.CreateTestSession(t, user.ID)
+	_, err = suite.DB.DB.Pool().Exec(ctx, "UPDATE sessions SET anomaly_at = NOW() WHERE id = $1", session.ID)
+	require.NoError(t, err)
+	require.NoError(t, forgetSecurityScore(ctx, suite.Redis.Client, user.ID))
+
+	score, err = userService.SecurityScore(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 50, score.Score)
+	assert.NotContains(t, passed(score), models.SecurityCheckNoRiskySessions)
+
+	// Trusting the session vouches for it
+	trusted := true
+	_, err = authService.UpdateSession(ctx, user.ID, session.ID, &models.UpdateSessionRequest{Trusted: &trusted})
+	require.NoError(t, err)
+	score, err = userService.SecurityScore(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 70, score.Score)
+
+	// A bounced email no longer counts as verified
+	found, err := userService.RecordEmailBounce(ctx, user.Email)
+	require.NoError(t, err)
+	assert.True(t, found)
+	score, err = userService.SecurityScore(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 50, score.Score)
+
+	_, err = userService.SecurityScore(ctx, uuid.New())
+	assert.ErrorIs(t, err, ErrUserNotFound)
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+    "net"
+    "strings"
+
+    "auth-service/internal/apperr"
+    "auth-service/internal/models"
+)
+
+var ErrSessionPolicyViolation = apperr.New(apperr.Unauthenticated, "Refresh not allowed from this network or device", nil)
+
+// Session binding mismatches reported by sessionBindingViolations.
+const (
+    violationNetwork         = "network"
+    violationUserAgentFamily = "user_agent_family"
+)
+
+// Product tokens checked in order; later browsers embed the tokens of
+// earlier ones (Edge and Opera claim Chrome, Chrome claims Safari).
+var userAgentFamilies = []struct {
+    token  string
+    family string
+}{
+    {"edg/", "edge"},
+    {"opr/", "opera"},
+    {"chrome/", "chrome"},
+    {"crios/", "chrome"},
+    {"firefox/", "firefox"},
+    {"fxios/", "firefox"},
+    {"safari/", "safari"},
+    {"okhttp/", "okhttp"},
+    {"cfnetwork/", "cfnetwork"},
+}
+
+// sessionBindingViolations compares a refresh request against the IP and
+// user agent the session was opened with.
+func sessionBindingViolations(session *models.Session, userAgent, ip string) []string {
+    var violations []string
+    if !sameNetwork(session.IP, ip) {
+        violations = append(violations, violationNetwork)
+    }
+    if userAgentFamily(session.UserAgent) != userAgentFamily(userAgent) {
+        violations = append(violations, violationUserAgentFamily)
+    }
+    return violations
+}
+
+// sameNetwork reports whether two addresses share a /24 (IPv4) or /64 (IPv6).
+// Unparseable addresses only match themselves.
+func sameNetwork(a, b string) bool {
+    ipA, ipB := net.ParseIP(a), net.ParseIP(b)
+    if ipA == nil || ipB == nil {
+        return a == b
+    }
+
+    if v4A, v4B := ipA.To4(), ipB.To4(); v4A != nil || v4B != nil {
+        if v4A == nil || v4B == nil {
+            return false
+        }
+        mask := net.CIDRMask(24, 32)
+        return v4A.Mask(mask).Equal(v4B.Mask(mask))
+    }
+
+    mask := net.CIDRMask(64, 128)
+    return ipA.Mask(mask).Equal(ipB.Mask(mask))
+}
+
+// userAgentFamily reduces a User-Agent header to its browser or HTTP client
+// family so routine version upgrades don't count as a different device.
+func userAgentFamily(userAgent string) string {
+    ua := strings.ToLower(userAgent)
+    for _, f := range userAgentFamilies {
+        if strings.Contains(ua, f.token) {
+            return f.family
+        }
+    }
+
+    // Fall back to the first product name, e.g. "TapIn" from "TapIn/2.3 (iOS)"
+    product, _, _ := strings.Cut(strings.TrimSpace(ua), "/")
+    product, _, _ = strings.Cut(product, " ")
+    return product
+}
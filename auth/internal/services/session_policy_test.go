@@ -0,0 +1,54 @@
+package services
+
+import (
+	"testing"
+
+	"auth-service/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSameNetwork(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"10.0.0.1", "10.0.0.254", true},
+		{"10.0.0.1", "10.0.1.1", false},
+		{"2001:db8::1", "2001:db8::ffff", true},
+		{"2001:db8::1", "2001:db8:0:1::1", false},
+		{"10.0.0.1", "2001:db8::1", false},
+		{"unknown", "unknown", true},
+		{"unknown", "10.0.0.1", false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, sameNetwork(tt.a, tt.b), "%s vs %s", tt.a, tt.b)
+	}
+}
+
+func TestUserAgentFamily(t *testing.T) {
+	tests := []struct {
+		userAgent string
+		want      string
+	}{
+		{"Mozilla/5.0 (Windows NT 10.0) AppleWebKit/537.36 Chrome/120.0 Safari/537.36", "chrome"},
+		{"Mozilla/5.0 (Windows NT 10.0) AppleWebKit/537.36 Chrome/120.0 Safari/537.36 Edg/120.0", "edge"},
+		{"Mozilla/5.0 (Macintosh) AppleWebKit/605.1.15 Version/17.0 Safari/605.1.15", "safari"},
+		{"Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0", "firefox"},
+		{"TapIn/2.3.1 (iOS 17.2)", "tapin"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, userAgentFamily(tt.userAgent), tt.userAgent)
+	}
+}
+
+func TestSessionBindingViolations(t *testing.T) {
+	session := &models.Session{IP: "10.0.0.1", UserAgent: "Mozilla/5.0 Firefox/120.0"}
+
+	assert.Empty(t, sessionBindingViolations(session, "Mozilla/5.0 Firefox/121.0", "10.0.0.9"))
+	assert.Equal(t, []string{violationNetwork}, sessionBindingViolations(session, "Mozilla/5.0 Firefox/121.0", "203.0.113.9"))
+	assert.Equal(t, []string{violationNetwork, violationUserAgentFamily}, sessionBindingViolations(session, "curl/8.0", "203.0.113.9"))
+}
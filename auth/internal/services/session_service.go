@@ -0,0 +1,374 @@
+package services
+
+import (
+    "context"
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+
+    "auth-service/internal/apperr"
+    "auth-service/internal/events"
+    "auth-service/internal/models"
+
+    "github.com/google/uuid"
+    "github.com/jackc/pgx/v5"
+)
+
+var (
+    ErrSessionNotFound   = apperr.New(apperr.NotFound, "Session not found", nil)
+    ErrNoSessionCriteria = apperr.New(apperr.Invalid, "At least one of created_before, cidr or user_agent is required", nil)
+)
+
+const (
+    // Sessions are revoked in batches of this many, so a broad revocation
+    // doesn't hold locks on the whole table
+    sessionRevokeBatchSize = 500
+    // Matching sessions shown by a dry run
+    sessionRevokeSampleSize = 20
+)
+
+const sessionInfoColumns = `id, label, trusted, user_agent, ip, client_type, region, created_at, expires_at`
+
+func scanSessionInfo(row pgx.Row) (*models.SessionInfo, error) {
+    info := &models.SessionInfo{}
+    err := row.Scan(&info.ID, &info.Label, &info.Trusted, &info.UserAgent, &info.IP, &info.ClientType,
+        &info.Region, &info.CreatedAt, &info.ExpiresAt)
+    return info, err
+}
+
+// ListSessions returns a user's live sessions, newest first.
+func (s *AuthService) ListSessions(ctx context.Context, userID uuid.UUID) ([]*models.SessionInfo, error) {
+    sessions := []*models.SessionInfo{}
+    err := s.EachSession(ctx, userID, func(info *models.SessionInfo) error {
+        sessions = append(sessions, info)
+        return nil
+    })
+    return sessions, err
+}
+
+// GetSession returns one of the user's live sessions.
+func (s *AuthService) GetSession(ctx context.Context, userID, sessionID uuid.UUID) (*models.SessionInfo, error) {
+    info, err := scanSessionInfo(s.db.Pool().QueryRow(ctx,
+        `SELECT `+sessionInfoColumns+` FROM sessions
+         WHERE id = $1 AND user_id = $2 AND expires_at > $3`,
+        sessionID, userID, s.now(),
+    ))
+    if err != nil {
+        if err == pgx.ErrNoRows {
+            return nil, ErrSessionNotFound
+        }
+        return nil, fmt.Errorf("get session: %w", err)
+    }
+    return info, nil
+}
+
+// EachSession passes a user's live sessions to fn newest first, one at a
+// time. Iteration stops at the first error from fn.
+func (s *AuthService) EachSession(ctx context.Context, userID uuid.UUID, fn func(*models.SessionInfo) error) error {
+    rows, err := s.db.Pool().Query(ctx,
+        `SELECT `+sessionInfoColumns+` FROM sessions
+         WHERE user_id = $1 AND expires_at > $2
+         ORDER BY created_at DESC`,
+        userID, s.now(),
+    )
+    if err != nil {
+        return fmt.Errorf("list sessions: %w", err)
+    }
+    defer rows.Close()
+
+    for rows.Next() {
+        info, err := scanSessionInfo(rows)
+        if err != nil {
+            return fmt.Errorf("scan session: %w", err)
+        }
+        if err := fn(info); err != nil {
+            return err
+        }
+    }
+    return rows.Err()
+}
+
+// UpdateSession renames a user's session or changes whether its device is
+// trusted. Trusting a session extends it to the trusted refresh lifetime;
+// untrusting caps it at the regular lifetime again.
+func (s *AuthService) UpdateSession(ctx context.Context, userID, sessionID uuid.UUID, req *models.UpdateSessionRequest) (*models.SessionInfo, error) {
+    now := s.now()
+    info, err := scanSessionInfo(s.db.Pool().QueryRow(ctx,
+        `UPDATE sessions SET
+             label = CASE WHEN $3 THEN NULLIF($4::text, '') ELSE label END,
+             trusted = COALESCE($5, trusted),
+             expires_at = CASE
+                 WHEN $5 AND NOT trusted THEN $6
+                 WHEN NOT $5 AND trusted THEN LEAST(expires_at, $7)
+                 ELSE expires_at
+             END
+         WHERE id = $1 AND user_id = $2 AND expires_at > $8
+         RETURNING `+sessionInfoColumns,
+        sessionID, userID, req.Label != nil, stringValue(req.Label), req.Trusted,
+        now.Add(s.config.TrustedRefreshExpiry), now.Add(s.config.RefreshExpiry), now,
+    ))
+    if err != nil {
+        if err == pgx.ErrNoRows {
+            return nil, ErrSessionNotFound
+        }
+        return nil, fmt.Errorf("update session: %w", err)
+    }
+
+    // Trusting a session vouches for any anomaly recorded on it
+    if req.Trusted != nil {
+        if err := forgetSecurityScore(ctx, s.redis, userID); err != nil {
+            s.logger.Errorf("Failed to invalidate security score: %v", err)
+        }
+    }
+    return info, nil
+}
+
+// isTrustedDevice reports whether the user has a live trusted session opened
+// from this user agent.
+func (s *AuthService) isTrustedDevice(ctx context.Context, userID uuid.UUID, userAgent string) (bool, error) {
+    var trusted bool
+    err := s.db.Pool().QueryRow(ctx,
+        `SELECT EXISTS(SELECT 1 FROM sessions
+                       WHERE user_id = $1 AND user_agent = $2 AND trusted AND expires_at > $3)`,
+        userID, userAgent, s.now(),
+    ).Scan(&trusted)
+    if err != nil {
+        return false, fmt.Errorf("check trusted device: %w", err)
+    }
+    return trusted, nil
+}
+
+// DeleteExpiredSessions removes sessions opened in this instance's region,
+// plus untagged ones, whose view-only grace period has ended. Each region
+// cleans up only its own sessions so regions never contend on the same rows.
+func (s *AuthService) DeleteExpiredSessions(ctx context.Context) (int64, error) {
+    result, err := s.db.Pool().Exec(ctx,
+        `DELETE FROM sessions WHERE region IN ($1, '') AND expires_at <= $2`,
+        s.config.Region, s.now().Add(-s.config.ViewOnlyGrace),
+    )
+    if err != nil {
+        return 0, fmt.Errorf("delete expired sessions: %w", err)
+    }
+    return result.RowsAffected(), nil
+}
+
+// RunSessionCleanup deletes this region's expired sessions every interval
+// until ctx is cancelled.
+func (s *AuthService) RunSessionCleanup(ctx context.Context, interval time.Duration) {
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+            deleted, err := s.DeleteExpiredSessions(ctx)
+            if err != nil {
+                s.logger.Errorf("Failed to clean up expired sessions: %v", err)
+            } else if deleted > 0 {
+                s.logger.Infof("Deleted %d expired sessions in region %q", deleted, s.config.Region)
+            }
+        }
+    }
+}
+
+func stringValue(s *string) string {
+    if s == nil {
+        return ""
+    }
+    return *s
+}
+
+// evictSessions closes the user's live sessions, other than keep, that don't
+// fit within the session cap: untrusted sessions before trusted ones, oldest
+// first. The user is told which devices were signed out, by event and, unless
+// a guest, by email. It returns the closed sessions.
+func (s *AuthService) evictSessions(ctx context.Context, user *models.User, keep uuid.UUID) ([]*models.SessionInfo, error) {
+    if s.config.MaxSessions <= 0 {
+        return nil, nil
+    }
+
+    rows, err := s.db.Pool().Query(ctx,
+        `DELETE FROM sessions WHERE id IN (
+             SELECT id FROM sessions
+             WHERE user_id = $1 AND id <> $2 AND expires_at > $3
+             ORDER BY trusted DESC, created_at DESC
+             OFFSET $4
+         )
+         RETURNING `+sessionInfoColumns,
+        user.ID, keep, s.now(), s.config.MaxSessions-1,
+    )
+    if err != nil {
+        return nil, fmt.Errorf("evict sessions: %w", err)
+    }
+    defer rows.Close()
+
+    var evicted []*models.SessionInfo
+    for rows.Next() {
+        info, err := scanSessionInfo(rows)
+        if err != nil {
+            return nil, fmt.Errorf("scan session: %w", err)
+        }
+        evicted = append(evicted, info)
+    }
+    if err := rows.Err(); err != nil {
+        return nil, fmt.Errorf("evict sessions: %w", err)
+    }
+    if len(evicted) == 0 {
+        return nil, nil
+    }
+
+    if err := forgetSecurityScore(ctx, s.redis, user.ID); err != nil {
+        s.logger.Errorf("Failed to invalidate security score: %v", err)
+    }
+
+    devices := make([]string, 0, len(evicted))
+    for _, info := range evicted {
+        s.forcedLogouts.Publish(ctx, user.ID, &info.ID, models.LogoutReasonSessionCap)
+
+        event := events.NewUserEvent(events.UserSessionEvicted, user.ID.String(), user.Username)
+        event.Data["session_id"] = info.ID.String()
+        event.Data["replaced_by"] = keep.String()
+        event.Data["ip"] = info.IP
+        event.Data["user_agent"] = info.UserAgent
+        event.Data["client_type"] = info.ClientType
+        if err := s.rabbitMQ.PublishUserEvent(event); err != nil {
+            s.logger.Errorf("Failed to publish session eviction event: %v", err)
+        }
+
+        devices = append(devices, fmt.Sprintf("%s (%s)", info.UserAgent, info.IP))
+    }
+
+    if !user.IsGuest {
+        sendEmail(ctx, s.mailer, s.logger, emailTemplateSessionsEvicted, user.Email, map[string]string{
+            "devices":      strings.Join(devices, "; "),
+            "max_sessions": strconv.Itoa(s.config.MaxSessions),
+        })
+    }
+    return evicted, nil
+}
+
+// RevokeSessions deletes every session matching req, live or in its
+// view-only grace period, in batches. With req.DryRun it only counts them.
+// Affected users can't refresh anymore, and each revoked session is announced
+// as a forced logout so its clients are disconnected.
+func (s *AuthService) RevokeSessions(ctx context.Context, req *models.RevokeSessionsRequest) (*models.SessionRevocation, error) {
+    if req.CreatedBefore == nil && req.CIDR == "" && req.UserAgent == "" {
+        return nil, ErrNoSessionCriteria
+    }
+
+    var cidr, userAgent *string
+    if req.CIDR != "" {
+        network, err := ParseIPOrCIDR(req.CIDR)
+        if err != nil {
+            return nil, err
+        }
+        value := network.String()
+        cidr = &value
+    }
+    if req.UserAgent != "" {
+        value := strings.ReplaceAll(escapeLike(req.UserAgent), "*", "%")
+        userAgent = &value
+    }
+
+    // Stored IPs come from the request, but are checked before the cast so
+    // one bad row can't fail the query
+    where := `($1::timestamp IS NULL OR created_at < $1)
+          AND ($2::text IS NULL OR CASE WHEN ip ~ '^[0-9a-fA-F:.]+$' THEN ip::inet <<= $2::inet ELSE false END)
+          AND ($3::text IS NULL OR user_agent LIKE $3 ESCAPE '\')`
+    args := []interface{}{req.CreatedBefore, cidr, userAgent}
+
+    result := &models.SessionRevocation{DryRun: req.DryRun}
+    if req.DryRun {
+        return result, s.countSessions(ctx, where, args, result)
+    }
+
+    users := map[uuid.UUID]bool{}
+    for {
+        rows, err := s.db.Pool().Query(ctx,
+            `DELETE FROM sessions WHERE id IN (
+                 SELECT id FROM sessions WHERE `+where+`
+                 LIMIT $4
+                 FOR UPDATE SKIP LOCKED
+             )
+             RETURNING id, user_id`,
+            append(args, sessionRevokeBatchSize)...,
+        )
+        if err != nil {
+            return nil, fmt.Errorf("revoke sessions: %w", err)
+        }
+
+        deleted := 0
+        for rows.Next() {
+            var sessionID, userID uuid.UUID
+            if err := rows.Scan(&sessionID, &userID); err != nil {
+                rows.Close()
+                return nil, fmt.Errorf("scan session: %w", err)
+            }
+            users[userID] = true
+            deleted++
+            s.forcedLogouts.Publish(ctx, userID, &sessionID, models.LogoutReasonAdminRevoked)
+        }
+        rows.Close()
+        if err := rows.Err(); err != nil {
+            return nil, fmt.Errorf("revoke sessions: %w", err)
+        }
+
+        result.Revoked += deleted
+        if deleted < sessionRevokeBatchSize {
+            break
+        }
+    }
+    result.Matched = result.Revoked
+    result.Users = len(users)
+
+    s.logger.Warnw("Revoked sessions in bulk",
+        "sessions", result.Revoked,
+        "users", result.Users,
+        "created_before", req.CreatedBefore,
+        "cidr", req.CIDR,
+        "user_agent", req.UserAgent,
+    )
+
+    for userID := range users {
+        if err := forgetSecurityScore(ctx, s.redis, userID); err != nil {
+            s.logger.Errorf("Failed to invalidate security score: %v", err)
+        }
+    }
+    return result, nil
+}
+
+// countSessions fills in a dry run: how many sessions and users match, and
+// a sample of the newest matching sessions.
+func (s *AuthService) countSessions(ctx context.Context, where string, args []interface{}, result *models.SessionRevocation) error {
+    err := s.db.Pool().QueryRow(ctx,
+        "SELECT COUNT(*), COUNT(DISTINCT user_id) FROM sessions WHERE "+where,
+        args...,
+    ).Scan(&result.Matched, &result.Users)
+    if err != nil {
+        return fmt.Errorf("count sessions: %w", err)
+    }
+
+    rows, err := s.db.Pool().Query(ctx,
+        `SELECT `+sessionInfoColumns+` FROM sessions WHERE `+where+`
+         ORDER BY created_at DESC
+         LIMIT $4`,
+        append(args, sessionRevokeSampleSize)...,
+    )
+    if err != nil {
+        return fmt.Errorf("sample sessions: %w", err)
+    }
+    defer rows.Close()
+
+    result.Sample = []*models.SessionInfo{}
+    for rows.Next() {
+        info, err := scanSessionInfo(rows)
+        if err != nil {
+            return fmt.Errorf("scan session: %w", err)
+        }
+        result.Sample = append(result.Sample, info)
+    }
+    return rows.Err()
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"auth-service/internal/models"
+	"auth-service/test"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthService_RevokeSessions(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	ctx := context.Background()
+	authService := NewAuthService(suite.DB.DB, suite.Redis.Client, suite.Config, suite.Logger, suite.Events)
+	alice := suite.CreateTestUser(t, "alice@example.com", "alice", test.TestData.ValidPassword)
+	bob := suite.CreateTestUser(t, "bob@example.com", "bob", test.TestData.ValidPassword)
+
+	addSession := func(userID uuid.UUID, userAgent, ip string, age time.Duration) uuid.UUID {
+		id := uuid.New()
+		_, err := suite.DB.DB.Pool().Exec(ctx,
+			`INSERT INTO sessions (id, user_id, refresh_token, user_agent, ip, expires_at, created_at)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			id, userID, uuid.NewString(), userAgent, ip, time.Now().Add(24*time.Hour), time.Now().Add(-age),
+		)
+		require.NoError(t, err)
+		return id
+	}
+
+	addSession(alice.ID, "TapIn/2.3.1 (iOS 17)", "10.1.0.5", time.Hour)
+	addSession(bob.ID, "TapIn/2.3.1 (Android 14)", "10.1.0.6", 48*time.Hour)
+	addSession(bob.ID, "TapIn/2.4.0 (Android 14)", "192.168.1.9", 48*time.Hour)
+	kept := addSession(alice.ID, "Mozilla/5.0", "10.1.0.7", time.Hour)
+
+	_, err := authService.RevokeSessions(ctx, &models.RevokeSessionsRequest{})
+	assert.ErrorIs(t, err, ErrNoSessionCriteria)
+
+	_, err = authService.RevokeSessions(ctx, &models.RevokeSessionsRequest{CIDR: "not-a-range"})
+	assert.ErrorIs(t, err, ErrInvalidCIDR)
+
+	// A dry run counts without revoking
+	result, err := authService.RevokeSessions(ctx, &models.RevokeSessionsRequest{UserAgent: "TapIn/2.3.1 *", DryRun: true})
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Matched)
+	assert.Equal(t, 2, result.Users)
+	assert.Zero(t, result.Revoked)
+	assert.Len(t, result.Sample, 2)
+
+	// Criteria combine
+	cutoff := time.Now().Add(-24 * time.Hour)
+	result, err = authService.RevokeSessions(ctx, &models.RevokeSessionsRequest{CIDR: "10.1.0.0/16", CreatedBefore: &cutoff})
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Revoked)
+	assert.Equal(t, 1, result.Users)
+
+	result, err = authService.RevokeSessions(ctx, &models.RevokeSessionsRequest{UserAgent: "TapIn/*"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Revoked)
+
+	var remaining []uuid.UUID
+	rows, err := suite.DB.DB.Pool().Query(ctx, "SELECT id FROM sessions")
+	require.NoError(t, err)
+	for rows.Next() {
+		var id uuid.UUID
+		require.NoError(t, rows.Scan(&id))
+		remaining = append(remaining, id)
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, []uuid.UUID{kept}, remaining)
+}
+
+func TestAuthService_LoginEvictsSessionsOverCap(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	ctx := context.Background()
+	suite.Config.MaxSessions = 2
+	sandbox := NewSandboxMailer(suite.DB.DB)
+	authService := NewAuthService(suite.DB.DB, suite.Redis.Client, suite.Config, suite.Logger, suite.Events)
+	authService.SetMailer(sandbox)
+	user := suite.CreateTestUser(t, test.TestData.ValidEmail, test.TestData.ValidUsername, test.TestData.ValidPassword)
+
+	addSession := func(userAgent string, trusted bool, age time.Duration) uuid.UUID {
+		id := uuid.New()
+		_, err := suite.DB.DB.Pool().Exec(ctx,
+			`INSERT INTO sessions (id, user_id, refresh_token, user_agent, ip, trusted, expires_at, created_at)
+			 VALUES ($1, $2, $3, $4, '10.0.0.1', $5, $6, $7)`,
+			id, user.ID, uuid.NewString(), userAgent, trusted, time.Now().Add(24*time.Hour), time.Now().Add(-age),
+		)
+		require.NoError(t, err)
+		return id
+	}
+
+	// The trusted session is kept even though it is the oldest
+	trusted := addSession("TapIn/2.4.0 (iOS 17)", true, 48*time.Hour)
+	untrusted := addSession("Mozilla/5.0", false, time.Hour)
+
+	login := &models.LoginRequest{Email: user.Email, Password: test.TestData.ValidPassword}
+	_, session, err := authService.Login(ctx, login, "TapIn/2.4.0 (Android 14)", "10.0.0.2", "android")
+	require.NoError(t, err)
+	require.Len(t, session.Evicted, 1)
+	assert.Equal(t, untrusted, session.Evicted[0].ID)
+	assert.Equal(t, "Mozilla/5.0", session.Evicted[0].UserAgent)
+
+	sessions, err := authService.ListSessions(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	assert.Equal(t, session.ID, sessions[0].ID)
+	assert.Equal(t, trusted, sessions[1].ID)
+
+	emails, err := sandbox.List(ctx, models.SentEmailFilter{Template: emailTemplateSessionsEvicted})
+	require.NoError(t, err)
+	require.Len(t, emails, 1)
+	assert.Equal(t, "Mozilla/5.0 (10.0.0.1)", emails[0].Data["devices"])
+
+	// Within the cap nothing is evicted
+	require.NoError(t, authService.DeleteSession(ctx, trusted, models.LogoutReasonLogoutAll))
+	_, session, err = authService.Login(ctx, login, "TapIn/2.4.0 (Android 14)", "10.0.0.2", "android")
+	require.NoError(t, err)
+	assert.Empty(t, session.Evicted)
+}
+
+func TestAuthService_GetSession(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	ctx := context.Background()
+	authService := NewAuthService(suite.DB.DB, suite.Redis.Client, suite.Config, suite.Logger, suite.Events)
+	alice := suite.CreateTestUser(t, "alice@example.com", "alice", test.TestData.ValidPassword)
+	bob := suite.CreateTestUser(t, "bob@example.com", "bob", test.TestData.ValidPassword)
+	session := suite.CreateTestSession(t, alice.ID)
+
+	info, err := authService.GetSession(ctx, alice.ID, session.ID)
+	require.NoError(t, err)
+	assert.Equal(t, session.ID, info.ID)
+
+	// Sessions of other users and revoked sessions aren't found
+	_, err = authService.GetSession(ctx, bob.ID, session.ID)
+	assert.Equal(t, ErrSessionNotFound, err)
+
+	require.NoError(t, authService.DeleteSession(ctx, session.ID, models.LogoutReasonRefreshReuse))
+	_, err = authService.GetSession(ctx, alice.ID, session.ID)
+	assert.Equal(t, ErrSessionNotFound, err)
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+    "context"
+    "fmt"
+    "time"
+
+    "auth-service/internal/apperr"
+
+    "github.com/google/uuid"
+    "github.com/jackc/pgx/v5"
+)
+
+var ErrTOTPRequired = apperr.New(apperr.Unauthenticated, "TOTP code required", nil).WithDetails(map[string]interface{}{"totp_required": true})
+
+// sudoKey marks one access token as elevated. It lives under the user's key
+// prefix so it is swept when the account is deleted.
+func sudoKey(userID uuid.UUID, tokenID string) string {
+    return fmt.Sprintf("user:%s:sudo:%s", userID, tokenID)
+}
+
+// ElevateSession confirms the user's password, and a TOTP code if TOTP is
+// enabled, then marks the access token tokenID as elevated for SudoTTL. It
+// returns when the elevation ends.
+func (s *AuthService) ElevateSession(ctx context.Context, userID uuid.UUID, tokenID, password, code string) (time.Time, error) {
+    tx, err := s.db.Pool().Begin(ctx)
+    if err != nil {
+        return time.Time{}, fmt.Errorf("begin transaction: %w", err)
+    }
+    defer tx.Rollback(ctx)
+
+    var passwordHash string
+    var secret *string
+    var lastStep *int64
+    err = tx.QueryRow(ctx,
+        "SELECT password_hash, totp_secret, totp_last_step FROM users WHERE id = $1 FOR UPDATE",
+        userID,
+    ).Scan(&passwordHash, &secret, &lastStep)
+    if err != nil {
+        if err == pgx.ErrNoRows {
+            return time.Time{}, ErrUserNotFound
+        }
+        return time.Time{}, fmt.Errorf("get credentials: %w", err)
+    }
+
+    if err := passwords.Compare(ctx, passwordHash, password); err != nil {
+        return time.Time{}, err
+    }
+
+    if secret != nil {
+        if code == "" {
+            return time.Time{}, ErrTOTPRequired
+        }
+        var last int64
+        if lastStep != nil {
+            last = *lastStep
+        }
+        step, ok := verifyTOTP(*secret, code, time.Now(), last)
+        if !ok {
+            return time.Time{}, ErrInvalidTOTPCode
+        }
+        if _, err := tx.Exec(ctx, "UPDATE users SET totp_last_step = $2 WHERE id = $1", userID, step); err != nil {
+            return time.Time{}, fmt.Errorf("record totp step: %w", err)
+        }
+    }
+
+    if err := tx.Commit(ctx); err != nil {
+        return time.Time{}, fmt.Errorf("commit transaction: %w", err)
+    }
+
+    expiresAt := time.Now().Add(s.config.SudoTTL)
+    if err := s.redis.Set(ctx, sudoKey(userID, tokenID), "1", s.config.SudoTTL); err != nil {
+        return time.Time{}, fmt.Errorf("store sudo flag: %w", err)
+    }
+    return expiresAt, nil
+}
+
+// IsElevated reports whether the access token tokenID is currently elevated.
+func (s *AuthService) IsElevated(ctx context.Context, userID uuid.UUID, tokenID string) (bool, error) {
+    elevated, err := s.redis.Exists(ctx, sudoKey(userID, tokenID))
+    if err != nil {
+        return false, fmt.Errorf("check sudo flag: %w", err)
+    }
+    return elevated, nil
+}
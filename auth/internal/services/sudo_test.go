@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"auth-service/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthService_ElevateSession(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	authService := NewAuthService(suite.DB.DB, suite.Redis.Client, suite.Config, suite.Logger, suite.Events)
+	ctx := context.Background()
+
+	user := suite.CreateTestUser(t, test.TestData.ValidEmail, test.TestData.ValidUsername, test.TestData.ValidPassword)
+
+	_, err := authService.ElevateSession(ctx, user.ID, "token-1", "wrong-password", "")
+	assert.Equal(t, ErrInvalidCredentials, err)
+
+	expiresAt, err := authService.ElevateSession(ctx, user.ID, "token-1", test.TestData.ValidPassword, "")
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(suite.Config.SudoTTL), expiresAt, time.Second)
+
+	elevated, err := authService.IsElevated(ctx, user.ID, "token-1")
+	require.NoError(t, err)
+	assert.True(t, elevated)
+
+	// Elevation is per access token
+	elevated, err = authService.IsElevated(ctx, user.ID, "token-2")
+	require.NoError(t, err)
+	assert.False(t, elevated)
+
+	// With TOTP on, the password alone isn't enough
+	enrollment, err := authService.StartTOTPEnrollment(ctx, user.ID)
+	require.NoError(t, err)
+	step := time.Now().Unix() / totpPeriod
+	code, err := totpCode(enrollment.Secret, step)
+	require.NoError(t, err)
+	require.NoError(t, authService.ConfirmTOTPEnrollment(ctx, user.ID, code))
+
+	_, err = authService.ElevateSession(ctx, user.ID, "token-2", test.TestData.ValidPassword, "")
+	assert.Equal(t, ErrTOTPRequired, err)
+
+	// The enrollment code can't be replayed
+	_, err = authService.ElevateSession(ctx, user.ID, "token-2", test.TestData.ValidPassword, code)
+	assert.Equal(t, ErrInvalidTOTPCode, err)
+
+	next, err := totpCode(enrollment.Secret, step+1)
+	require.NoError(t, err)
+	_, err = authService.ElevateSession(ctx, user.ID, "token-2", test.TestData.ValidPassword, next)
+	require.NoError(t, err)
+
+	elevated, err = authService.IsElevated(ctx, user.ID, "token-2")
+	require.NoError(t, err)
+	assert.True(t, elevated)
+}
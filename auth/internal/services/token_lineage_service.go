@@ -0,0 +1,133 @@
+package services
+
+import (
+    "context"
+    "fmt"
+    "time"
+
+    "auth-service/internal/apperr"
+    "auth-service/internal/database"
+    "auth-service/internal/models"
+
+    "github.com/google/uuid"
+    "github.com/jackc/pgx/v5"
+    "go.uber.org/zap"
+)
+
+var ErrTokenFamilyNotFound = apperr.New(apperr.NotFound, "Token family not found", nil)
+
+// TokenLineageService answers forensic questions about refresh token
+// families: which tokens were issued for a session, from where, and whether a
+// rotated token was ever replayed.
+type TokenLineageService struct {
+    db     *database.DB
+    logger *zap.SugaredLogger
+}
+
+func NewTokenLineageService(db *database.DB, logger *zap.SugaredLogger) *TokenLineageService {
+    return &TokenLineageService{
+        db:     db,
+        logger: logger,
+    }
+}
+
+const tokenFamilySummary = `
+    SELECT l.family_id, l.user_id,
+           EXISTS(SELECT 1 FROM sessions s WHERE s.id = l.family_id),
+           COUNT(*) - 1,
+           COUNT(DISTINCT l.ip),
+           BOOL_OR(l.reused_at IS NOT NULL),
+           MIN(l.issued_at),
+           MAX(l.issued_at)
+    FROM refresh_token_lineage l`
+
+// ListFamilies returns a summary of every token family for the user, newest
+// first.
+func (s *TokenLineageService) ListFamilies(ctx context.Context, userID uuid.UUID) ([]*models.TokenFamily, error) {
+    rows, err := s.db.Pool().Query(ctx,
+        tokenFamilySummary+`
+         WHERE l.user_id = $1
+         GROUP BY l.family_id, l.user_id
+         ORDER BY MAX(l.issued_at) DESC`,
+        userID,
+    )
+    if err != nil {
+        return nil, fmt.Errorf("list token families: %w", err)
+    }
+    defer rows.Close()
+
+    families := []*models.TokenFamily{}
+    for rows.Next() {
+        f := &models.TokenFamily{}
+        if err := rows.Scan(&f.FamilyID, &f.UserID, &f.Active, &f.Rotations, &f.DistinctIPs,
+            &f.ReuseDetected, &f.StartedAt, &f.LastIssuedAt); err != nil {
+            return nil, fmt.Errorf("scan token family: %w", err)
+        }
+        families = append(families, f)
+    }
+
+    return families, rows.Err()
+}
+
+// ExportFamily returns the family summary and its full lineage in issue order.
+func (s *TokenLineageService) ExportFamily(ctx context.Context, familyID uuid.UUID) (*models.TokenFamilyExport, error) {
+    family := &models.TokenFamily{}
+    err := s.db.Pool().QueryRow(ctx,
+        tokenFamilySummary+`
+         WHERE l.family_id = $1
+         GROUP BY l.family_id, l.user_id`,
+        familyID,
+    ).Scan(&family.FamilyID, &family.UserID, &family.Active, &family.Rotations, &family.DistinctIPs,
+        &family.ReuseDetected, &family.StartedAt, &family.LastIssuedAt)
+    if err != nil {
+        if err == pgx.ErrNoRows {
+            return nil, ErrTokenFamilyNotFound
+        }
+        return nil, fmt.Errorf("get token family: %w", err)
+    }
+
+    rows, err := s.db.Pool().Query(ctx,
+        `SELECT id, family_id, parent_id, COALESCE(ip, ''), COALESCE(user_agent, ''), issued_at,
+                rotated_at, reused_at, reused_ip, reused_user_agent
+         FROM refresh_token_lineage WHERE family_id = $1
+         ORDER BY issued_at`,
+        familyID,
+    )
+    if err != nil {
+        return nil, fmt.Errorf("get token lineage: %w", err)
+    }
+    defer rows.Close()
+
+    lineage := []*models.RefreshTokenIssue{}
+    for rows.Next() {
+        t := &models.RefreshTokenIssue{}
+        if err := rows.Scan(&t.ID, &t.FamilyID, &t.ParentID, &t.IP, &t.UserAgent, &t.IssuedAt,
+            &t.RotatedAt, &t.ReusedAt, &t.ReusedIP, &t.ReusedUserAgent); err != nil {
+            return nil, fmt.Errorf("scan token lineage: %w", err)
+        }
+        lineage = append(lineage, t)
+    }
+    if err := rows.Err(); err != nil {
+        return nil, err
+    }
+
+    return &models.TokenFamilyExport{
+        Family:     family,
+        Lineage:    lineage,
+        ExportedAt: time.Now().UTC(),
+    }, nil
+}
+
+// recordRefreshTokenIssued adds a token to its family's lineage. parentID is
+// nil for the first token of a session.
+func recordRefreshTokenIssued(ctx context.Context, db execer, familyID, userID uuid.UUID, parentID *uuid.UUID, token, userAgent, ip string) error {
+    _, err := db.Exec(ctx,
+        `INSERT INTO refresh_token_lineage (family_id, user_id, parent_id, token_hash, ip, user_agent)
+         VALUES ($1, $2, $3, $4, $5, $6)`,
+        familyID, userID, parentID, hashToken(token), ip, userAgent,
+    )
+    if err != nil {
+        return fmt.Errorf("record refresh token lineage: %w", err)
+    }
+    return nil
+}
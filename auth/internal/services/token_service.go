@@ -5,64 +5,199 @@ import (
     "fmt"
     "time"
 
-    "auth-service/internal/redis"
+    "auth-service/internal/apperr"
+    "auth-service/internal/models"
+    "auth-service/internal/secrets"
+    "auth-service/internal/store"
     "github.com/golang-jwt/jwt/v5"
     "github.com/google/uuid"
     "go.uber.org/zap"
 )
 
+const (
+    // Allowed difference between our clock and a validator's when checking nbf
+    tokenClockSkew = 30 * time.Second
+    // How far ahead a scheduled token may become valid
+    maxTokenPreIssue = 30 * 24 * time.Hour
+)
+
+// ScopeViewOnly marks access tokens that may read but not write. They are
+// issued for recently expired sessions so returning users can look around
+// before logging in again.
+const ScopeViewOnly = "view_only"
+
+var (
+    ErrTokenNotYetValid = apperr.New(apperr.Unauthenticated, "Token not yet valid", nil)
+    ErrNotBeforeTooFar  = apperr.New(apperr.Invalid, "not_before is too far in the future", nil)
+)
+
 type TokenClaims struct {
     UserID   uuid.UUID `json:"user_id"`
     Email    string    `json:"email"`
     Username string    `json:"username"`
+    Scope    string    `json:"scope,omitempty"`
+    // Whether the user has filled in every required profile field. Only set
+    // on full access tokens.
+    ProfileComplete *bool `json:"profile_complete,omitempty"`
+    // Session the token was issued for. Only set on full access tokens.
+    SessionID *uuid.UUID `json:"sid,omitempty"`
+    // Limits of the user's plan as of issue. Only set on full access tokens.
+    Entitlements *models.Entitlements `json:"ent,omitempty"`
+    // The user's badges as of issue. Only set on full access tokens.
+    Badges []string `json:"badges,omitempty"`
+    // Data region the user's data is kept in. Only set on full access
+    // tokens; empty for users registered before regions were recorded.
+    DataRegion string `json:"data_region,omitempty"`
     jwt.RegisteredClaims
 }
 
 type TokenService struct {
-    jwtSecret     []byte
-    jwtExpiry     time.Duration
-    redis         *redis.Client
-    logger        *zap.SugaredLogger
+    keys      *secrets.Keyring
+    jwtExpiry time.Duration
+    tokens    store.TokenStore
+    logger    *zap.SugaredLogger
+    now       func() time.Time
 }
 
-func NewTokenService(jwtSecret string, jwtExpiry time.Duration, redis *redis.Client, logger *zap.SugaredLogger) *TokenService {
+func NewTokenService(keys *secrets.Keyring, jwtExpiry time.Duration, tokens store.TokenStore, logger *zap.SugaredLogger) *TokenService {
     return &TokenService{
-        jwtSecret: []byte(jwtSecret),
+        keys:      keys,
         jwtExpiry: jwtExpiry,
-        redis:     redis,
+        tokens:    tokens,
         logger:    logger,
+        now:       time.Now,
     }
 }
 
-func (s *TokenService) GenerateToken(userID uuid.UUID, email, username string) (string, time.Time, error) {
-    expiresAt := time.Now().Add(s.jwtExpiry)
+// SetClock makes the service issue and check tokens against now instead of
+// the system clock, for end-to-end tests that travel in time.
+func (s *TokenService) SetClock(now func() time.Time) {
+    s.now = now
+}
+
+// Now returns the time tokens are issued and checked against.
+func (s *TokenService) Now() time.Time {
+    return s.now()
+}
+
+// GenerateToken issues a full access token for the user's session.
+func (s *TokenService) GenerateToken(userID, sessionID uuid.UUID, email, username string, profileComplete bool, entitlements models.Entitlements, badges []string, dataRegion string) (string, time.Time, error) {
+    expiresAt := s.now().Add(s.jwtExpiry)
     
+    claims := TokenClaims{
+        UserID:          userID,
+        Email:           email,
+        Username:        username,
+        ProfileComplete: &profileComplete,
+        SessionID:       &sessionID,
+        Entitlements:    &entitlements,
+        Badges:          badges,
+        DataRegion:      dataRegion,
+        RegisteredClaims: jwt.RegisteredClaims{
+            ExpiresAt: jwt.NewNumericDate(expiresAt),
+            IssuedAt:  jwt.NewNumericDate(s.now()),
+            ID:        uuid.New().String(),
+        },
+    }
+
+    signedToken, err := s.sign(claims)
+    if err != nil {
+        return "", time.Time{}, err
+    }
+
+    return signedToken, expiresAt, nil
+}
+
+// GenerateViewOnlyToken issues a read-only access token with the normal
+// expiry.
+func (s *TokenService) GenerateViewOnlyToken(userID uuid.UUID, email, username string) (string, time.Time, error) {
+    expiresAt := s.now().Add(s.jwtExpiry)
+
     claims := TokenClaims{
         UserID:   userID,
         Email:    email,
         Username: username,
+        Scope:    ScopeViewOnly,
         RegisteredClaims: jwt.RegisteredClaims{
             ExpiresAt: jwt.NewNumericDate(expiresAt),
-            IssuedAt:  jwt.NewNumericDate(time.Now()),
+            IssuedAt:  jwt.NewNumericDate(s.now()),
             ID:        uuid.New().String(),
         },
     }
 
-    token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-    signedToken, err := token.SignedString(s.jwtSecret)
+    signedToken, err := s.sign(claims)
     if err != nil {
-        return "", time.Time{}, fmt.Errorf("sign token: %w", err)
+        return "", time.Time{}, err
     }
 
     return signedToken, expiresAt, nil
 }
 
+// GenerateScheduledToken issues a token now that only becomes valid at
+// notBefore, for access that opens at a set time such as an event-gated room.
+// It stays valid for validFor after notBefore, or the normal expiry if zero.
+func (s *TokenService) GenerateScheduledToken(userID uuid.UUID, email, username string, notBefore time.Time, validFor time.Duration) (string, time.Time, error) {
+    if notBefore.Sub(s.now()) > maxTokenPreIssue {
+        return "", time.Time{}, ErrNotBeforeTooFar
+    }
+    if validFor <= 0 {
+        validFor = s.jwtExpiry
+    }
+    expiresAt := notBefore.Add(validFor)
+
+    claims := TokenClaims{
+        UserID:   userID,
+        Email:    email,
+        Username: username,
+        RegisteredClaims: jwt.RegisteredClaims{
+            ExpiresAt: jwt.NewNumericDate(expiresAt),
+            NotBefore: jwt.NewNumericDate(notBefore),
+            IssuedAt:  jwt.NewNumericDate(s.now()),
+            ID:        uuid.New().String(),
+        },
+    }
+
+    signedToken, err := s.sign(claims)
+    if err != nil {
+        return "", time.Time{}, err
+    }
+
+    return signedToken, expiresAt, nil
+}
+
+func (s *TokenService) sign(claims TokenClaims) (string, error) {
+    token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+    var signedToken string
+    err := s.keys.Use(func(keys [][]byte) error {
+        var err error
+        signedToken, err = token.SignedString(keys[0])
+        return err
+    })
+    if err != nil {
+        return "", fmt.Errorf("sign token: %w", err)
+    }
+    return signedToken, nil
+}
+
 func (s *TokenService) ValidateToken(tokenString string) (*TokenClaims, error) {
-    token, err := jwt.ParseWithClaims(tokenString, &TokenClaims{}, func(token *jwt.Token) (interface{}, error) {
-        if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-            return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+    // Time claims are checked below so that clock skew applies to nbf only;
+    // expiry stays exact.
+    // Tokens signed with the key replaced by the last reload still verify
+    var token *jwt.Token
+    err := s.keys.Use(func(keys [][]byte) error {
+        set := jwt.VerificationKeySet{}
+        for _, key := range keys {
+            set.Keys = append(set.Keys, key)
         }
-        return s.jwtSecret, nil
+
+        var err error
+        token, err = jwt.ParseWithClaims(tokenString, &TokenClaims{}, func(token *jwt.Token) (interface{}, error) {
+            if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+                return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+            }
+            return set, nil
+        }, jwt.WithoutClaimsValidation())
+        return err
     })
 
     if err != nil {
@@ -70,8 +205,16 @@ func (s *TokenService) ValidateToken(tokenString string) (*TokenClaims, error) {
     }
 
     if claims, ok := token.Claims.(*TokenClaims); ok && token.Valid {
+        now := s.now()
+        if claims.ExpiresAt != nil && !now.Before(claims.ExpiresAt.Time) {
+            return nil, fmt.Errorf("parse token: %w", jwt.ErrTokenExpired)
+        }
+        if claims.NotBefore != nil && now.Add(tokenClockSkew).Before(claims.NotBefore.Time) {
+            return nil, ErrTokenNotYetValid
+        }
+
         // Check if token is blacklisted
-        blacklisted, err := s.redis.Exists(context.Background(), fmt.Sprintf("blacklist:%s", claims.ID))
+        blacklisted, err := s.tokens.Has(context.Background(), fmt.Sprintf("blacklist:%s", claims.ID))
         if err != nil {
             s.logger.Errorf("Failed to check blacklist: %v", err)
         }
@@ -87,10 +230,10 @@ func (s *TokenService) ValidateToken(tokenString string) (*TokenClaims, error) {
 
 func (s *TokenService) BlacklistToken(ctx context.Context, tokenID string, expiry time.Time) error {
     key := fmt.Sprintf("blacklist:%s", tokenID)
-    ttl := time.Until(expiry)
+    ttl := expiry.Sub(s.now())
     
     if ttl > 0 {
-        return s.redis.Set(ctx, key, "1", ttl)
+        return s.tokens.Put(ctx, key, "1", ttl)
     }
     
     return nil
@@ -2,46 +2,58 @@ package services
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"auth-service/internal/models"
+	"auth-service/internal/secrets"
+	"auth-service/internal/store"
 	"auth-service/test"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
 )
 
 func TestTokenService_GenerateToken(t *testing.T) {
 	suite := test.NewTestSuite(t)
 	defer suite.Cleanup(t)
 
-	tokenService := NewTokenService(suite.Config.JWTSecret, suite.Config.JWTExpiry, suite.Redis.Client, suite.Logger)
+	tokenService := NewTokenService(test.Keyring(t, suite.Config.JWTSecret), suite.Config.JWTExpiry, store.NewRedis(suite.Redis.Client), suite.Logger)
 
 	userID := uuid.New()
 	email := "test@example.com"
 	username := "testuser"
 
-	token, expiresAt, err := tokenService.GenerateToken(userID, email, username)
+	sessionID := uuid.New()
+	token, expiresAt, err := tokenService.GenerateToken(userID, sessionID, email, username, true, models.EntitlementsFor(models.PlanFree), nil, "")
 
 	require.NoError(t, err)
 	assert.NotEmpty(t, token)
 	assert.True(t, expiresAt.After(time.Now()))
 	assert.True(t, expiresAt.Before(time.Now().Add(suite.Config.JWTExpiry+time.Minute)))
+
+	claims, err := tokenService.ValidateToken(token)
+	require.NoError(t, err)
+	require.NotNil(t, claims.SessionID)
+	assert.Equal(t, sessionID, *claims.SessionID)
 }
 
 func TestTokenService_ValidateToken(t *testing.T) {
 	suite := test.NewTestSuite(t)
 	defer suite.Cleanup(t)
 
-	tokenService := NewTokenService(suite.Config.JWTSecret, suite.Config.JWTExpiry, suite.Redis.Client, suite.Logger)
+	tokenService := NewTokenService(test.Keyring(t, suite.Config.JWTSecret), suite.Config.JWTExpiry, store.NewRedis(suite.Redis.Client), suite.Logger)
 
 	userID := uuid.New()
 	email := "test@example.com"
 	username := "testuser"
 
 	// Generate a valid token
-	validToken, _, err := tokenService.GenerateToken(userID, email, username)
+	validToken, _, err := tokenService.GenerateToken(userID, uuid.New(), email, username, true, models.EntitlementsFor(models.PlanFree), nil, "")
 	require.NoError(t, err)
 
 	tests := []struct {
@@ -95,14 +107,14 @@ func TestTokenService_BlacklistToken(t *testing.T) {
 	suite := test.NewTestSuite(t)
 	defer suite.Cleanup(t)
 
-	tokenService := NewTokenService(suite.Config.JWTSecret, suite.Config.JWTExpiry, suite.Redis.Client, suite.Logger)
+	tokenService := NewTokenService(test.Keyring(t, suite.Config.JWTSecret), suite.Config.JWTExpiry, store.NewRedis(suite.Redis.Client), suite.Logger)
 
 	userID := uuid.New()
 	email := "test@example.com"
 	username := "testuser"
 
 	// Generate a token
-	token, expiresAt, err := tokenService.GenerateToken(userID, email, username)
+	token, expiresAt, err := tokenService.GenerateToken(userID, uuid.New(), email, username, true, models.EntitlementsFor(models.PlanFree), nil, "")
 	require.NoError(t, err)
 
 	// Validate token works initially
@@ -127,14 +139,14 @@ func TestTokenService_ExpiredToken(t *testing.T) {
 
 	// Create token service with very short expiry
 	shortExpiry := 1 * time.Millisecond
-	tokenService := NewTokenService(suite.Config.JWTSecret, shortExpiry, suite.Redis.Client, suite.Logger)
+	tokenService := NewTokenService(test.Keyring(t, suite.Config.JWTSecret), shortExpiry, store.NewRedis(suite.Redis.Client), suite.Logger)
 
 	userID := uuid.New()
 	email := "test@example.com"
 	username := "testuser"
 
 	// Generate token
-	token, _, err := tokenService.GenerateToken(userID, email, username)
+	token, _, err := tokenService.GenerateToken(userID, uuid.New(), email, username, true, models.EntitlementsFor(models.PlanFree), nil, "")
 	require.NoError(t, err)
 
 	// Wait for token to expire
@@ -151,17 +163,166 @@ func TestTokenService_WrongSecret(t *testing.T) {
 	defer suite.Cleanup(t)
 
 	// Create token with one secret
-	tokenService1 := NewTokenService("secret1", suite.Config.JWTExpiry, suite.Redis.Client, suite.Logger)
+	tokenService1 := NewTokenService(test.Keyring(t, "secret1"), suite.Config.JWTExpiry, store.NewRedis(suite.Redis.Client), suite.Logger)
 	userID := uuid.New()
 	email := "test@example.com"
 	username := "testuser"
 
-	token, _, err := tokenService1.GenerateToken(userID, email, username)
+	token, _, err := tokenService1.GenerateToken(userID, uuid.New(), email, username, true, models.EntitlementsFor(models.PlanFree), nil, "")
 	require.NoError(t, err)
 
 	// Try to validate with different secret
-	tokenService2 := NewTokenService("secret2", suite.Config.JWTExpiry, suite.Redis.Client, suite.Logger)
+	tokenService2 := NewTokenService(test.Keyring(t, "secret2"), suite.Config.JWTExpiry, store.NewRedis(suite.Redis.Client), suite.Logger)
 	claims, err := tokenService2.ValidateToken(token)
 	require.Error(t, err)
 	assert.Nil(t, claims)
-}
\ No newline at end of file
+}
+func TestTokenService_ScheduledToken(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	tokenService := NewTokenService(test.Keyring(t, suite.Config.JWTSecret), suite.Config.JWTExpiry, store.NewRedis(suite.Redis.Client), suite.Logger)
+	userID := uuid.New()
+
+	// Not valid until nbf
+	future, expiresAt, err := tokenService.GenerateScheduledToken(userID, "test@example.com", "testuser", time.Now().Add(time.Hour), 2*time.Hour)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(3*time.Hour), expiresAt, time.Second)
+
+	_, err = tokenService.ValidateToken(future)
+	assert.Equal(t, ErrTokenNotYetValid, err)
+
+	// nbf within the allowed clock skew is accepted
+	soon, _, err := tokenService.GenerateScheduledToken(userID, "test@example.com", "testuser", time.Now().Add(tokenClockSkew/2), 0)
+	require.NoError(t, err)
+
+	claims, err := tokenService.ValidateToken(soon)
+	require.NoError(t, err)
+	assert.Equal(t, userID, claims.UserID)
+
+	// Pre-issuance is bounded
+	_, _, err = tokenService.GenerateScheduledToken(userID, "test@example.com", "testuser", time.Now().Add(maxTokenPreIssue+time.Hour), 0)
+	assert.Equal(t, ErrNotBeforeTooFar, err)
+}
+
+func TestTokenService_ViewOnlyToken(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	tokenService := NewTokenService(test.Keyring(t, suite.Config.JWTSecret), suite.Config.JWTExpiry, store.NewRedis(suite.Redis.Client), suite.Logger)
+	userID := uuid.New()
+
+	token, _, err := tokenService.GenerateViewOnlyToken(userID, "test@example.com", "testuser")
+	require.NoError(t, err)
+
+	claims, err := tokenService.ValidateToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, ScopeViewOnly, claims.Scope)
+
+	// Regular tokens carry no scope
+	token, _, err = tokenService.GenerateToken(userID, uuid.New(), "test@example.com", "testuser", true, models.EntitlementsFor(models.PlanFree), nil, "")
+	require.NoError(t, err)
+
+	claims, err = tokenService.ValidateToken(token)
+	require.NoError(t, err)
+	assert.Empty(t, claims.Scope)
+}
+
+func TestTokenService_BlacklistToken_MemoryStore(t *testing.T) {
+	tokenService := NewTokenService(test.Keyring(t, "test-secret"), 15*time.Minute, store.NewMemory(), zap.NewNop().Sugar())
+
+	token, expiresAt, err := tokenService.GenerateToken(uuid.New(), uuid.New(), "test@example.com", "testuser", true, models.EntitlementsFor(models.PlanFree), nil, "")
+	require.NoError(t, err)
+
+	claims, err := tokenService.ValidateToken(token)
+	require.NoError(t, err)
+
+	require.NoError(t, tokenService.BlacklistToken(context.Background(), claims.ID, expiresAt))
+
+	_, err = tokenService.ValidateToken(token)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "blacklisted")
+}
+
+func TestTokenService_ProfileCompleteClaim(t *testing.T) {
+	tokenService := NewTokenService(test.Keyring(t, "test-secret"), 15*time.Minute, store.NewMemory(), zap.NewNop().Sugar())
+
+	token, _, err := tokenService.GenerateToken(uuid.New(), uuid.New(), "test@example.com", "testuser", false, models.EntitlementsFor(models.PlanFree), nil, "")
+	require.NoError(t, err)
+	claims, err := tokenService.ValidateToken(token)
+	require.NoError(t, err)
+	require.NotNil(t, claims.ProfileComplete)
+	assert.False(t, *claims.ProfileComplete)
+
+	// View-only tokens don't carry the claim
+	token, _, err = tokenService.GenerateViewOnlyToken(uuid.New(), "test@example.com", "testuser")
+	require.NoError(t, err)
+	claims, err = tokenService.ValidateToken(token)
+	require.NoError(t, err)
+	assert.Nil(t, claims.ProfileComplete)
+}
+
+func TestTokenService_EntitlementsClaim(t *testing.T) {
+	tokenService := NewTokenService(test.Keyring(t, "test-secret"), 15*time.Minute, store.NewMemory(), zap.NewNop().Sugar())
+
+	token, _, err := tokenService.GenerateToken(uuid.New(), uuid.New(), "test@example.com", "testuser", true, models.EntitlementsFor(models.PlanVenue), nil, "")
+	require.NoError(t, err)
+	claims, err := tokenService.ValidateToken(token)
+	require.NoError(t, err)
+	require.NotNil(t, claims.Entitlements)
+	assert.Equal(t, models.PlanVenue, claims.Entitlements.Plan)
+	assert.Equal(t, models.EntitlementsFor(models.PlanVenue).MaxRoomSize, claims.Entitlements.MaxRoomSize)
+
+	token, _, err = tokenService.GenerateViewOnlyToken(uuid.New(), "test@example.com", "testuser")
+	require.NoError(t, err)
+	claims, err = tokenService.ValidateToken(token)
+	require.NoError(t, err)
+	assert.Nil(t, claims.Entitlements)
+}
+
+func TestTokenService_BadgesClaim(t *testing.T) {
+	tokenService := NewTokenService(test.Keyring(t, "test-secret"), 15*time.Minute, store.NewMemory(), zap.NewNop().Sugar())
+
+	badges := []string{models.BadgeEmailVerified, models.BadgeVenueVerified}
+	token, _, err := tokenService.GenerateToken(uuid.New(), uuid.New(), "test@example.com", "testuser", true, models.EntitlementsFor(models.PlanVenue), badges, "")
+	require.NoError(t, err)
+	claims, err := tokenService.ValidateToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, badges, claims.Badges)
+}
+
+func TestTokenService_AcceptsPreviousKeyAfterReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jwt_secret")
+	require.NoError(t, os.WriteFile(path, []byte("first-secret"), 0o600))
+	keys, err := secrets.LoadKeyring(secrets.FileSource(path))
+	require.NoError(t, err)
+	tokenService := NewTokenService(keys, 15*time.Minute, store.NewMemory(), zap.NewNop().Sugar())
+
+	oldToken, _, err := tokenService.GenerateToken(uuid.New(), uuid.New(), "test@example.com", "testuser", true, models.EntitlementsFor(models.PlanFree), nil, "")
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(path, []byte("second-secret"), 0o600))
+	rotated, err := keys.Reload()
+	require.NoError(t, err)
+	require.True(t, rotated)
+
+	_, err = tokenService.ValidateToken(oldToken)
+	assert.NoError(t, err)
+
+	// After the next rotation the first key is gone
+	require.NoError(t, os.WriteFile(path, []byte("third-secret"), 0o600))
+	_, err = keys.Reload()
+	require.NoError(t, err)
+	_, err = tokenService.ValidateToken(oldToken)
+	assert.Error(t, err)
+}
+
+func TestTokenService_DataRegionClaim(t *testing.T) {
+	tokenService := NewTokenService(test.Keyring(t, "test-secret"), 15*time.Minute, store.NewMemory(), zap.NewNop().Sugar())
+
+	token, _, err := tokenService.GenerateToken(uuid.New(), uuid.New(), "test@example.com", "testuser", true, models.EntitlementsFor(models.PlanFree), nil, "eu")
+	require.NoError(t, err)
+	claims, err := tokenService.ValidateToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, "eu", claims.DataRegion)
+}
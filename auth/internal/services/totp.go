@@ -0,0 +1,236 @@
+package services
+
+import (
+    "context"
+    "crypto/hmac"
+    "crypto/rand"
+    "crypto/sha1"
+    "crypto/subtle"
+    "encoding/base32"
+    "encoding/binary"
+    "fmt"
+    "net/url"
+    "strings"
+    "time"
+
+    "auth-service/internal/apperr"
+    "auth-service/internal/models"
+
+    "github.com/google/uuid"
+    "github.com/jackc/pgx/v5"
+)
+
+// RFC 6238 parameters understood by common authenticator apps
+const (
+    totpPeriod = 30
+    totpDigits = 6
+    // Codes from one step either side are accepted to allow for clock drift
+    totpSkewSteps = 1
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+var (
+    ErrTOTPAlreadyEnabled = apperr.New(apperr.Conflict, "TOTP is already enabled", nil)
+    ErrTOTPNotEnabled     = apperr.New(apperr.Invalid, "TOTP is not enabled", nil)
+    ErrInvalidTOTPCode    = apperr.New(apperr.Invalid, "Invalid code", nil)
+)
+
+// StartTOTPEnrollment generates a new TOTP secret for the user. It only takes
+// effect once ConfirmTOTPEnrollment sees a code generated from it.
+func (s *AuthService) StartTOTPEnrollment(ctx context.Context, userID uuid.UUID) (*models.TOTPEnrollment, error) {
+    secret, err := generateTOTPSecret()
+    if err != nil {
+        return nil, err
+    }
+
+    var email string
+    err = s.db.Pool().QueryRow(ctx,
+        `UPDATE users SET totp_pending_secret = $2, updated_at = NOW()
+         WHERE id = $1 AND totp_enabled_at IS NULL
+         RETURNING email`,
+        userID, secret,
+    ).Scan(&email)
+    if err != nil {
+        if err == pgx.ErrNoRows {
+            return nil, ErrTOTPAlreadyEnabled
+        }
+        return nil, fmt.Errorf("start totp enrollment: %w", err)
+    }
+
+    return &models.TOTPEnrollment{
+        Secret: secret,
+        URI:    totpURI(s.config.TOTPIssuer, email, secret),
+    }, nil
+}
+
+// ConfirmTOTPEnrollment turns TOTP on once the user proves their
+// authenticator has the pending secret. From then on logins are challenged
+// for a code.
+func (s *AuthService) ConfirmTOTPEnrollment(ctx context.Context, userID uuid.UUID, code string) error {
+    var pending *string
+    err := s.db.Pool().QueryRow(ctx,
+        "SELECT totp_pending_secret FROM users WHERE id = $1 AND totp_enabled_at IS NULL",
+        userID,
+    ).Scan(&pending)
+    if err != nil {
+        if err == pgx.ErrNoRows {
+            return ErrTOTPAlreadyEnabled
+        }
+        return fmt.Errorf("get totp enrollment: %w", err)
+    }
+    if pending == nil {
+        return ErrTOTPNotEnabled
+    }
+
+    step, ok := verifyTOTP(*pending, code, time.Now(), 0)
+    if !ok {
+        return ErrInvalidTOTPCode
+    }
+
+    if err := s.setTOTP(ctx, userID, true, step); err != nil {
+        return err
+    }
+
+    if err := forgetSecurityScore(ctx, s.redis, userID); err != nil {
+        s.logger.Errorf("Failed to invalidate security score: %v", err)
+    }
+    return nil
+}
+
+// DisableTOTP turns TOTP off after checking a current code.
+func (s *AuthService) DisableTOTP(ctx context.Context, userID uuid.UUID, code string) error {
+    var secret *string
+    var lastStep *int64
+    err := s.db.Pool().QueryRow(ctx,
+        "SELECT totp_secret, totp_last_step FROM users WHERE id = $1",
+        userID,
+    ).Scan(&secret, &lastStep)
+    if err != nil {
+        if err == pgx.ErrNoRows {
+            return ErrUserNotFound
+        }
+        return fmt.Errorf("get totp secret: %w", err)
+    }
+    if secret == nil {
+        return ErrTOTPNotEnabled
+    }
+
+    var last int64
+    if lastStep != nil {
+        last = *lastStep
+    }
+    if _, ok := verifyTOTP(*secret, code, time.Now(), last); !ok {
+        return ErrInvalidTOTPCode
+    }
+
+    if err := s.setTOTP(ctx, userID, false, 0); err != nil {
+        return err
+    }
+
+    if err := forgetSecurityScore(ctx, s.redis, userID); err != nil {
+        s.logger.Errorf("Failed to invalidate security score: %v", err)
+    }
+    return nil
+}
+
+// setTOTP turns TOTP on, with the pending secret and the step of the code
+// that confirmed it, or off, and queues the user:badges_changed event for the
+// MFA badge in the same transaction.
+func (s *AuthService) setTOTP(ctx context.Context, userID uuid.UUID, enabled bool, step int64) error {
+    tx, err := s.db.Pool().Begin(ctx)
+    if err != nil {
+        return fmt.Errorf("begin transaction: %w", err)
+    }
+    defer tx.Rollback(ctx)
+
+    var username string
+    if enabled {
+        err = tx.QueryRow(ctx,
+            `UPDATE users SET totp_secret = totp_pending_secret, totp_pending_secret = NULL,
+                 totp_enabled_at = NOW(), totp_last_step = $2, updated_at = NOW()
+             WHERE id = $1
+             RETURNING username`,
+            userID, step,
+        ).Scan(&username)
+    } else {
+        err = tx.QueryRow(ctx,
+            `UPDATE users SET totp_secret = NULL, totp_pending_secret = NULL, totp_enabled_at = NULL,
+                 totp_last_step = NULL, updated_at = NOW()
+             WHERE id = $1
+             RETURNING username`,
+            userID,
+        ).Scan(&username)
+    }
+    if err != nil {
+        if err == pgx.ErrNoRows {
+            return ErrUserNotFound
+        }
+        return fmt.Errorf("set totp: %w", err)
+    }
+
+    if err := enqueueBadgesChanged(ctx, tx, userID, username, models.BadgeMFAEnabled, enabled); err != nil {
+        return err
+    }
+    if err := tx.Commit(ctx); err != nil {
+        return fmt.Errorf("commit transaction: %w", err)
+    }
+    return nil
+}
+
+func generateTOTPSecret() (string, error) {
+    secret := make([]byte, 20)
+    if _, err := rand.Read(secret); err != nil {
+        return "", fmt.Errorf("generate totp secret: %w", err)
+    }
+    return totpEncoding.EncodeToString(secret), nil
+}
+
+// totpURI builds the otpauth:// URI authenticator apps scan as a QR code.
+func totpURI(issuer, account, secret string) string {
+    label := url.PathEscape(issuer + ":" + account)
+    query := url.Values{
+        "secret":    {secret},
+        "issuer":    {issuer},
+        "algorithm": {"SHA1"},
+        "digits":    {fmt.Sprint(totpDigits)},
+        "period":    {fmt.Sprint(totpPeriod)},
+    }
+    return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+func totpCode(secret string, step int64) (string, error) {
+    key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+    if err != nil {
+        return "", fmt.Errorf("decode totp secret: %w", err)
+    }
+
+    var msg [8]byte
+    binary.BigEndian.PutUint64(msg[:], uint64(step))
+    mac := hmac.New(sha1.New, key)
+    mac.Write(msg[:])
+    sum := mac.Sum(nil)
+
+    offset := sum[len(sum)-1] & 0x0f
+    value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+    return fmt.Sprintf("%0*d", totpDigits, value%1000000), nil
+}
+
+// verifyTOTP checks code against secret at now and returns the matching time
+// step. Steps at or before lastStep are rejected so a code can't be replayed.
+func verifyTOTP(secret, code string, now time.Time, lastStep int64) (int64, bool) {
+    current := now.Unix() / totpPeriod
+    for step := current - totpSkewSteps; step <= current+totpSkewSteps; step++ {
+        if step <= lastStep {
+            continue
+        }
+        expected, err := totpCode(secret, step)
+        if err != nil {
+            return 0, false
+        }
+        if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+            return step, true
+        }
+    }
+    return 0, false
+}
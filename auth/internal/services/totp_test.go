@@ -0,0 +1,64 @@
+package services
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTOTPCode_RFC6238Vectors(t *testing.T) {
+	// RFC 6238 appendix B SHA1 secret, truncated to 6 digits
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tt := range tests {
+		code, err := totpCode(secret, tt.unix/totpPeriod)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, code, tt.unix)
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	secret, err := generateTOTPSecret()
+	require.NoError(t, err)
+	now := time.Unix(1700000000, 0)
+	step := now.Unix() / totpPeriod
+
+	code, err := totpCode(secret, step)
+	require.NoError(t, err)
+
+	matched, ok := verifyTOTP(secret, code, now, 0)
+	assert.True(t, ok)
+	assert.Equal(t, step, matched)
+
+	// Clock drift of one step is tolerated
+	_, ok = verifyTOTP(secret, code, now.Add(totpPeriod*time.Second), 0)
+	assert.True(t, ok)
+	_, ok = verifyTOTP(secret, code, now.Add(2*totpPeriod*time.Second), 0)
+	assert.False(t, ok)
+
+	// A used code can't be replayed
+	_, ok = verifyTOTP(secret, code, now, step)
+	assert.False(t, ok)
+
+	_, ok = verifyTOTP(secret, "000000", now, 0)
+	assert.Equal(t, code == "000000", ok)
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri := totpURI("TapIn", "user@example.com", "ABC")
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/TapIn:user@example.com?"))
+	assert.Contains(t, uri, "secret=ABC")
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "time"
+
+    "auth-service/internal/models"
+
+    "github.com/google/uuid"
+    "github.com/jackc/pgx/v5"
+)
+
+// How long an emailed login confirmation link stays valid.
+const loginConfirmationExpiry = 15 * time.Minute
+
+var ErrLoginConfirmationRequired = errors.New("login confirmation required")
+
+// EnableTravelMode pins new logins to the device identified by userAgent for
+// the given number of days and returns when the pin expires. Enabling again
+// moves the pin to the current device.
+func (s *UserService) EnableTravelMode(ctx context.Context, userID uuid.UUID, userAgent string, days int) (time.Time, error) {
+    until := time.Now().Add(time.Duration(days) * 24 * time.Hour)
+    result, err := s.db.Pool().Exec(ctx,
+        `UPDATE users SET travel_mode_until = $1, travel_mode_user_agent = $2, updated_at = NOW()
+         WHERE id = $3`,
+        until, userAgent, userID,
+    )
+    if err != nil {
+        return time.Time{}, fmt.Errorf("enable travel mode: %w", err)
+    }
+    if result.RowsAffected() == 0 {
+        return time.Time{}, ErrUserNotFound
+    }
+    return until, nil
+}
+
+func (s *UserService) DisableTravelMode(ctx context.Context, userID uuid.UUID) error {
+    _, err := s.db.Pool().Exec(ctx,
+        `UPDATE users SET travel_mode_until = NULL, travel_mode_user_agent = NULL, updated_at = NOW()
+         WHERE id = $1`,
+        userID,
+    )
+    if err != nil {
+        return fmt.Errorf("disable travel mode: %w", err)
+    }
+    return nil
+}
+
+// travelModeBlocks reports whether travel mode holds back a login from
+// userAgent until it is confirmed by email.
+func travelModeBlocks(until *time.Time, pinnedAgent *string, userAgent string) bool {
+    if until == nil || pinnedAgent == nil || !time.Now().Before(*until) {
+        return false
+    }
+    return *pinnedAgent != userAgent
+}
+
+// requestLoginConfirmation issues a single-use token that completes a login
+// held back by travel mode. Only the token's hash is persisted.
+func (s *AuthService) requestLoginConfirmation(ctx context.Context, user *models.User) error {
+    token := generateToken()
+    _, err := s.db.Pool().Exec(ctx,
+        `INSERT INTO login_confirmation_tokens (user_id, token_hash, expires_at)
+         VALUES ($1, $2, $3)`,
+        user.ID, hashToken(token), time.Now().Add(loginConfirmationExpiry),
+    )
+    if err != nil {
+        return fmt.Errorf("create login confirmation token: %w", err)
+    }
+
+    sendEmail(ctx, s.mailer, s.logger, emailTemplateLoginConfirmation, user.Email, map[string]string{"token": token})
+
+    return nil
+}
+
+// ConfirmLogin consumes a login confirmation token and opens a session for
+// the device presenting it.
+func (s *AuthService) ConfirmLogin(ctx context.Context, token, userAgent, ip, clientType string) (*models.User, *models.Session, error) {
+    var userID uuid.UUID
+    err := s.db.Pool().QueryRow(ctx,
+        `UPDATE login_confirmation_tokens SET used_at = NOW()
+         WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+         RETURNING user_id`,
+        hashToken(token),
+    ).Scan(&userID)
+    if err != nil {
+        if err == pgx.ErrNoRows {
+            return nil, nil, ErrInvalidToken
+        }
+        return nil, nil, fmt.Errorf("consume login confirmation token: %w", err)
+    }
+
+    user, err := scanUser(s.db.Pool().QueryRow(ctx,
+        `SELECT `+userColumns+` FROM users WHERE id = $1`,
+        userID,
+    ))
+    if err != nil {
+        if err == pgx.ErrNoRows {
+            return nil, nil, ErrInvalidToken
+        }
+        return nil, nil, fmt.Errorf("get user: %w", err)
+    }
+
+    session, err := s.completeLogin(ctx, user, userAgent, ip, clientType)
+    if err != nil {
+        return nil, nil, err
+    }
+    return user, session, nil
+}
@@ -0,0 +1,19 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTravelModeBlocks(t *testing.T) {
+	pinned := "Mozilla/5.0 (iPhone)"
+	future := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Hour)
+
+	assert.False(t, travelModeBlocks(nil, nil, "curl/8.0"), "travel mode off")
+	assert.False(t, travelModeBlocks(&future, &pinned, pinned), "pinned device")
+	assert.True(t, travelModeBlocks(&future, &pinned, "curl/8.0"), "other device")
+	assert.False(t, travelModeBlocks(&past, &pinned, "curl/8.0"), "expired pin")
+}
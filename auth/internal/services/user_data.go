@@ -0,0 +1,153 @@
+package services
+
+import (
+    "context"
+    "fmt"
+    "sort"
+    "strings"
+
+    "auth-service/internal/models"
+
+    "github.com/google/uuid"
+)
+
+const purgeBatchSize = 100
+
+// Per-user Redis key conventions. Anything cached about a user must live under
+// one of these patterns so it is swept when the account is deleted or suspended,
+// and shows up when an admin inspects the user's Redis state.
+var userKeyPatterns = []struct {
+    pattern  string
+    category string
+}{
+    {"user:%s", models.UserKeyCategoryCache},                 // cached profile
+    {"user:%s:*", models.UserKeyCategoryCache},               // other per-user cache entries
+    {"ratelimit:user:%s:*", models.UserKeyCategoryRateLimit}, // per-user rate limit buckets
+    {"presence:%s", models.UserKeyCategoryPresence},          // online presence
+    {"presence:%s:*", models.UserKeyCategoryPresence},        // per-device presence
+    {"otp:%s:*", models.UserKeyCategoryOTP},                  // one-time codes
+    {"device_trust:%s:*", models.UserKeyCategoryDeviceTrust}, // trusted device entries
+}
+
+// PurgeUserData removes every Redis key belonging to the user and returns how
+// many were deleted. Keys are found by SCAN over the conventions above.
+func (s *UserService) PurgeUserData(ctx context.Context, userID uuid.UUID) (int, error) {
+    deleted := 0
+    for _, p := range userKeyPatterns {
+        n, err := s.purgeKeys(ctx, fmt.Sprintf(p.pattern, userID))
+        deleted += n
+        if err != nil {
+            return deleted, err
+        }
+    }
+
+    return deleted, nil
+}
+
+// userStatePatterns returns the SCAN patterns for all of a user's Redis
+// state: the per-ID conventions plus keys named after the user's email
+// (login lockouts) and username (ID lookup cache).
+func userStatePatterns(user *models.User) map[string][]string {
+    patterns := make(map[string][]string)
+    for _, p := range userKeyPatterns {
+        patterns[p.category] = append(patterns[p.category], fmt.Sprintf(p.pattern, user.ID))
+    }
+    patterns[models.UserKeyCategoryCache] = append(patterns[models.UserKeyCategoryCache],
+        escapeGlob(usernameCachePrefix+user.Username))
+    patterns[models.UserKeyCategoryLoginLockout] = []string{escapeGlob(loginAccountKey(user.Email)) + ":*"}
+    return patterns
+}
+
+// ListUserState returns the user's Redis keys with their category and time to
+// live, to debug users who are stuck rate limited or locked out.
+func (s *UserService) ListUserState(ctx context.Context, userID uuid.UUID) ([]*models.UserRedisKey, error) {
+    user, err := s.getUserByID(ctx, userID)
+    if err != nil {
+        return nil, err
+    }
+
+    keys := []*models.UserRedisKey{}
+    for category, patterns := range userStatePatterns(user) {
+        for _, pattern := range patterns {
+            found, err := s.redis.Scan(ctx, pattern)
+            if err != nil {
+                return nil, fmt.Errorf("scan %s: %w", pattern, err)
+            }
+            for _, key := range found {
+                ttl, err := s.redis.TTL(ctx, key)
+                if err != nil {
+                    return nil, fmt.Errorf("get ttl of %s: %w", key, err)
+                }
+                // Keys without an expiry report -1
+                ttlSeconds := int64(-1)
+                if ttl >= 0 {
+                    ttlSeconds = int64(ttl.Seconds())
+                }
+                keys = append(keys, &models.UserRedisKey{Key: key, Category: category, TTLSeconds: ttlSeconds})
+            }
+        }
+    }
+
+    sort.Slice(keys, func(i, j int) bool { return keys[i].Key < keys[j].Key })
+    return keys, nil
+}
+
+// PurgeUserState deletes the user's Redis keys, optionally only those in one
+// category, and returns how many were deleted.
+func (s *UserService) PurgeUserState(ctx context.Context, userID uuid.UUID, category string) (int, error) {
+    user, err := s.getUserByID(ctx, userID)
+    if err != nil {
+        return 0, err
+    }
+
+    deleted := 0
+    for cat, patterns := range userStatePatterns(user) {
+        if category != "" && cat != category {
+            continue
+        }
+        for _, pattern := range patterns {
+            n, err := s.purgeKeys(ctx, pattern)
+            deleted += n
+            if err != nil {
+                return deleted, err
+            }
+        }
+    }
+
+    return deleted, nil
+}
+
+// purgeKeys deletes the keys matching pattern in batches.
+func (s *UserService) purgeKeys(ctx context.Context, pattern string) (int, error) {
+    keys, err := s.redis.Scan(ctx, pattern)
+    if err != nil {
+        return 0, fmt.Errorf("scan %s: %w", pattern, err)
+    }
+
+    deleted := 0
+    for start := 0; start < len(keys); start += purgeBatchSize {
+        end := start + purgeBatchSize
+        if end > len(keys) {
+            end = len(keys)
+        }
+        if err := s.redis.Delete(ctx, keys[start:end]...); err != nil {
+            return deleted, fmt.Errorf("delete user keys: %w", err)
+        }
+        deleted += end - start
+    }
+    return deleted, nil
+}
+
+// escapeGlob escapes SCAN pattern metacharacters so user-controlled parts of
+// a key, such as emails, match literally.
+func escapeGlob(s string) string {
+    var b strings.Builder
+    for _, r := range s {
+        switch r {
+        case '*', '?', '[', ']', '\\':
+            b.WriteByte('\\')
+        }
+        b.WriteRune(r)
+    }
+    return b.String()
+}
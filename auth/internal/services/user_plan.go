@@ -0,0 +1,76 @@
+package services
+
+import (
+    "context"
+    "fmt"
+
+    "auth-service/internal/events"
+    "auth-service/internal/models"
+
+    "github.com/google/uuid"
+    "github.com/jackc/pgx/v5"
+)
+
+// SetPlan moves the user to plan, queues a user:plan_changed event in the
+// same transaction and returns the previous plan. Setting the current plan
+// again changes nothing.
+func (s *UserService) SetPlan(ctx context.Context, userID uuid.UUID, plan string) (string, error) {
+    tx, err := s.db.Pool().Begin(ctx)
+    if err != nil {
+        return "", fmt.Errorf("begin transaction: %w", err)
+    }
+    defer tx.Rollback(ctx)
+
+    var previous, username string
+    err = tx.QueryRow(ctx,
+        "SELECT plan, username FROM users WHERE id = $1 FOR UPDATE",
+        userID,
+    ).Scan(&previous, &username)
+    if err != nil {
+        if err == pgx.ErrNoRows {
+            return "", ErrUserNotFound
+        }
+        return "", fmt.Errorf("get plan: %w", err)
+    }
+    if previous == plan {
+        return previous, nil
+    }
+
+    var seq int64
+    err = tx.QueryRow(ctx,
+        `UPDATE users SET plan = $1, plan_changed_at = NOW(), change_seq = change_seq + 1, updated_at = NOW()
+         WHERE id = $2
+         RETURNING change_seq`,
+        plan, userID,
+    ).Scan(&seq)
+    if err != nil {
+        return "", fmt.Errorf("set plan: %w", err)
+    }
+
+    event := events.NewUserEvent(events.UserPlanChanged, userID.String(), username)
+    event.Data["plan"] = plan
+    event.Data["previous_plan"] = previous
+    event.Data["entitlements"] = models.EntitlementsFor(plan)
+    event.Data["seq"] = seq
+    if err := enqueueEvent(ctx, tx, event); err != nil {
+        return "", err
+    }
+
+    if err := tx.Commit(ctx); err != nil {
+        return "", fmt.Errorf("commit transaction: %w", err)
+    }
+    return previous, nil
+}
+
+// Entitlements returns the entitlements of the user's current plan.
+func (s *UserService) Entitlements(ctx context.Context, userID uuid.UUID) (models.Entitlements, error) {
+    var plan string
+    err := s.db.Pool().QueryRow(ctx, "SELECT plan FROM users WHERE id = $1", userID).Scan(&plan)
+    if err != nil {
+        if err == pgx.ErrNoRows {
+            return models.Entitlements{}, ErrUserNotFound
+        }
+        return models.Entitlements{}, fmt.Errorf("get plan: %w", err)
+    }
+    return models.EntitlementsFor(plan), nil
+}
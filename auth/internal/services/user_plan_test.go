@@ -0,0 +1,57 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"auth-service/internal/events"
+	"auth-service/internal/models"
+	"auth-service/test"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserService_SetPlan(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	ctx := context.Background()
+	userService := NewUserService(suite.DB.DB, suite.Redis.Client, suite.Logger)
+	relay := NewBufferedPublisher(suite.Events, suite.DB.DB, 10, suite.Logger)
+
+	user := suite.CreateTestUser(t, test.TestData.ValidEmail, test.TestData.ValidUsername, test.TestData.ValidPassword)
+
+	entitlements, err := userService.Entitlements(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.PlanFree, entitlements.Plan)
+
+	previous, err := userService.SetPlan(ctx, user.ID, models.PlanPlus)
+	require.NoError(t, err)
+	assert.Equal(t, models.PlanFree, previous)
+	// Setting the same plan again is not a change
+	previous, err = userService.SetPlan(ctx, user.ID, models.PlanPlus)
+	require.NoError(t, err)
+	assert.Equal(t, models.PlanPlus, previous)
+
+	entitlements, err = userService.Entitlements(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.EntitlementsFor(models.PlanPlus), entitlements)
+
+	_, err = userService.SetPlan(ctx, uuid.New(), models.PlanPlus)
+	assert.ErrorIs(t, err, ErrUserNotFound)
+
+	_, err = relay.RelayOutbox(ctx, 10)
+	require.NoError(t, err)
+
+	var changes []*events.UserEvent
+	for _, event := range suite.Events.Events {
+		if event.Type == events.UserPlanChanged {
+			changes = append(changes, event)
+		}
+	}
+	require.Len(t, changes, 1)
+	assert.Equal(t, models.PlanPlus, changes[0].Data["plan"])
+	assert.Equal(t, models.PlanFree, changes[0].Data["previous_plan"])
+}
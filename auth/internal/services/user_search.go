@@ -0,0 +1,218 @@
+package services
+
+import (
+    "context"
+    "fmt"
+    "strings"
+    "time"
+
+    "auth-service/internal/apperr"
+    "auth-service/internal/models"
+    "auth-service/internal/redis"
+
+    "github.com/google/uuid"
+    "github.com/jackc/pgx/v5"
+)
+
+const (
+    defaultUserSearchLimit = 10
+    // Searches per user per minute, on top of any per-IP rate limit rule
+    userSearchPerMinute = 30
+    // Public profile card lookups per IP per minute, on top of any per-IP
+    // rate limit rule
+    publicCardPerMinute = 20
+)
+
+var (
+    ErrSearchRateLimited     = apperr.New(apperr.RateLimited, "Too many searches", nil).WithRetryAfter(time.Minute)
+    ErrCannotBlockSelf       = apperr.New(apperr.Invalid, "You can't block yourself", nil)
+    ErrPublicCardRateLimited = apperr.New(apperr.RateLimited, "Rate limit exceeded", nil).WithRetryAfter(time.Minute)
+    ErrPublicCardNotFound    = apperr.New(apperr.NotFound, "Profile not found", nil)
+)
+
+// SearchUsers finds users whose handle or display name starts with query, for
+// @mention autocompletion. Users who opted out of discoverability, the
+// searcher, and anyone on either side of a block with the searcher are left
+// out. Searches are limited per user.
+func (s *UserService) SearchUsers(ctx context.Context, searcherID uuid.UUID, query string, limit int) ([]*models.PublicUser, error) {
+    if err := s.checkSearchRate(ctx, searcherID); err != nil {
+        return nil, err
+    }
+    if limit <= 0 {
+        limit = defaultUserSearchLimit
+    }
+
+    rows, err := s.db.Pool().Query(ctx,
+        `SELECT u.username, u.display_name, u.avatar_url FROM users u
+         WHERE u.discoverable AND u.id <> $1
+           AND (lower(u.username) LIKE $2 ESCAPE '\' OR lower(u.display_name) LIKE $2 ESCAPE '\')
+           AND NOT EXISTS (
+               SELECT 1 FROM user_blocks b
+               WHERE (b.blocker_id = u.id AND b.blocked_id = $1)
+                  OR (b.blocker_id = $1 AND b.blocked_id = u.id))
+         ORDER BY length(u.username), u.username
+         LIMIT $3`,
+        searcherID, escapeLike(strings.ToLower(query))+"%", limit,
+    )
+    if err != nil {
+        return nil, fmt.Errorf("search users: %w", err)
+    }
+    defer rows.Close()
+
+    users := []*models.PublicUser{}
+    for rows.Next() {
+        user := &models.PublicUser{}
+        if err := rows.Scan(&user.Handle, &user.DisplayName, &user.AvatarURL); err != nil {
+            return nil, fmt.Errorf("scan user: %w", err)
+        }
+        users = append(users, user)
+    }
+    return users, rows.Err()
+}
+
+// checkSearchRate counts searches in fixed one-minute windows. Redis errors
+// are logged and let the search through.
+func (s *UserService) checkSearchRate(ctx context.Context, userID uuid.UUID) error {
+    key := fmt.Sprintf("ratelimit:user:%s:search:%d", userID, time.Now().Unix()/60)
+    if !s.countPerMinute(ctx, key, userSearchPerMinute) {
+        return ErrSearchRateLimited
+    }
+    return nil
+}
+
+// PublicCard returns the public profile card of the user with handle.
+// Guests, accounts being deleted and users who haven't turned the card on
+// are all reported as ErrPublicCardNotFound, so the endpoint doesn't reveal
+// which handles exist. Lookups are limited per IP.
+func (s *UserService) PublicCard(ctx context.Context, handle, ip string) (*models.PublicProfileCard, error) {
+    key := fmt.Sprintf("ratelimit:ip:%s:public_card:%d", ip, time.Now().Unix()/60)
+    if !s.countPerMinute(ctx, key, publicCardPerMinute) {
+        return nil, ErrPublicCardRateLimited
+    }
+
+    var userID uuid.UUID
+    card := &models.PublicProfileCard{}
+    err := s.db.Pool().QueryRow(ctx,
+        `SELECT id, username, display_name, avatar_url FROM users
+         WHERE username = $1 AND public_card AND NOT is_guest AND deletion_requested_at IS NULL`,
+        handle,
+    ).Scan(&userID, &card.Handle, &card.DisplayName, &card.AvatarURL)
+    if err != nil {
+        if err == pgx.ErrNoRows {
+            return nil, ErrPublicCardNotFound
+        }
+        return nil, fmt.Errorf("get public card: %w", err)
+    }
+
+    card.Badges, err = loadBadges(ctx, s.db.Pool(), userID)
+    if err != nil {
+        if err == ErrUserNotFound {
+            return nil, ErrPublicCardNotFound
+        }
+        return nil, err
+    }
+    return card, nil
+}
+
+// countPerMinute counts a request against key, a counter for the current
+// one-minute window, and reports whether it is within limit. While Redis is
+// unavailable the request is counted in memory against half the limit; other
+// Redis errors are logged and let the request through.
+func (s *UserService) countPerMinute(ctx context.Context, key string, limit int64) bool {
+    count, err := s.redis.Incr(ctx, key)
+    if err == redis.ErrUnavailable {
+        return s.local.Incr(key, time.Minute) <= int64(degradedLimit(int(limit)))
+    }
+    if err != nil {
+        s.logger.Errorf("Failed to count request: %v", err)
+        return true
+    }
+    if count == 1 {
+        if err := s.redis.Expire(ctx, key, time.Minute); err != nil {
+            s.logger.Errorf("Failed to expire request counter: %v", err)
+        }
+    }
+    return count <= limit
+}
+
+// escapeLike escapes LIKE wildcards so user input matches literally.
+func escapeLike(s string) string {
+    return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// UpdatePublicProfile changes the fields set in req and queues a
+// user:snapshot event in the same transaction.
+func (s *UserService) UpdatePublicProfile(ctx context.Context, userID uuid.UUID, req *models.PublicProfileRequest) error {
+    outcome := models.ModerationAllow
+    if req.DisplayName != nil {
+        var err error
+        outcome, err = s.moderation.Check(ctx, &userID, models.ModerationFieldDisplayName, *req.DisplayName)
+        if err != nil {
+            return err
+        }
+    }
+
+    tx, err := s.db.Pool().Begin(ctx)
+    if err != nil {
+        return fmt.Errorf("begin transaction: %w", err)
+    }
+    defer tx.Rollback(ctx)
+
+    _, err = tx.Exec(ctx,
+        `UPDATE users SET
+             display_name = CASE WHEN $2 THEN NULLIF($3::text, '') ELSE display_name END,
+             avatar_url = CASE WHEN $4 THEN NULLIF($5::text, '') ELSE avatar_url END,
+             discoverable = COALESCE($6, discoverable),
+             public_card = COALESCE($7, public_card),
+             date_of_birth = CASE WHEN $8 THEN NULLIF($9::text, '')::date ELSE date_of_birth END,
+             change_seq = change_seq + 1, updated_at = NOW()
+         WHERE id = $1`,
+        userID, req.DisplayName != nil, stringValue(req.DisplayName),
+        req.AvatarURL != nil, stringValue(req.AvatarURL), req.Discoverable, req.PublicCard,
+        req.DateOfBirth != nil, stringValue(req.DateOfBirth),
+    )
+    if err != nil {
+        return fmt.Errorf("update public profile: %w", err)
+    }
+    if err := enqueueSnapshot(ctx, tx, userID); err != nil {
+        return err
+    }
+    if err := tx.Commit(ctx); err != nil {
+        return fmt.Errorf("commit transaction: %w", err)
+    }
+
+    if req.DisplayName != nil {
+        s.moderation.Track(ctx, userID, models.ModerationFieldDisplayName, *req.DisplayName, outcome)
+    }
+    return nil
+}
+
+// BlockUser hides the two users from each other's searches. Blocking twice
+// is a no-op.
+func (s *UserService) BlockUser(ctx context.Context, blockerID, blockedID uuid.UUID) error {
+    if blockerID == blockedID {
+        return ErrCannotBlockSelf
+    }
+
+    _, err := s.db.Pool().Exec(ctx,
+        `INSERT INTO user_blocks (blocker_id, blocked_id)
+         SELECT $1, id FROM users WHERE id = $2
+         ON CONFLICT DO NOTHING`,
+        blockerID, blockedID,
+    )
+    if err != nil {
+        return fmt.Errorf("block user: %w", err)
+    }
+    return nil
+}
+
+func (s *UserService) UnblockUser(ctx context.Context, blockerID, blockedID uuid.UUID) error {
+    _, err := s.db.Pool().Exec(ctx,
+        "DELETE FROM user_blocks WHERE blocker_id = $1 AND blocked_id = $2",
+        blockerID, blockedID,
+    )
+    if err != nil {
+        return fmt.Errorf("unblock user: %w", err)
+    }
+    return nil
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"auth-service/internal/models"
+	"auth-service/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEscapeLike(t *testing.T) {
+	assert.Equal(t, `al\%i\_ce\\`, escapeLike(`al%i_ce\`))
+	assert.Equal(t, "alice", escapeLike("alice"))
+}
+
+func TestUserService_SearchUsers(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	userService := NewUserService(suite.DB.DB, suite.Redis.Client, suite.Logger)
+	ctx := context.Background()
+
+	searcher := suite.CreateTestUser(t, "searcher@example.com", "searcher", test.TestData.ValidPassword)
+	suite.CreateTestUser(t, "sam@example.com", "sam", test.TestData.ValidPassword)
+	hidden := suite.CreateTestUser(t, "sasha@example.com", "sasha", test.TestData.ValidPassword)
+	blocker := suite.CreateTestUser(t, "sally@example.com", "sally", test.TestData.ValidPassword)
+
+	displayName := "Sandy Beach"
+	require.NoError(t, userService.UpdatePublicProfile(ctx, hidden.ID, &models.PublicProfileRequest{DisplayName: &displayName}))
+
+	handles := func() []string {
+		users, err := userService.SearchUsers(ctx, searcher.ID, "SA", 0)
+		require.NoError(t, err)
+		var out []string
+		for _, u := range users {
+			out = append(out, u.Handle)
+		}
+		return out
+	}
+
+	// The searcher never finds themselves
+	assert.Equal(t, []string{"sam", "sally", "sasha"}, handles())
+
+	discoverable := false
+	require.NoError(t, userService.UpdatePublicProfile(ctx, hidden.ID, &models.PublicProfileRequest{Discoverable: &discoverable}))
+	require.NoError(t, userService.BlockUser(ctx, blocker.ID, searcher.ID))
+	assert.Equal(t, []string{"sam"}, handles())
+
+	// Wildcards match literally
+	users, err := userService.SearchUsers(ctx, searcher.ID, "s%", 0)
+	require.NoError(t, err)
+	assert.Empty(t, users)
+
+	assert.Equal(t, ErrCannotBlockSelf, userService.BlockUser(ctx, searcher.ID, searcher.ID))
+}
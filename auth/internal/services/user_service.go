@@ -3,49 +3,206 @@ package services
 import (
     "context"
     "fmt"
+    "sort"
+    "strings"
 
+    "auth-service/internal/apperr"
     "auth-service/internal/database"
+    "auth-service/internal/events"
     "auth-service/internal/models"
+    "auth-service/internal/redis"
 
     "github.com/google/uuid"
+    "github.com/jackc/pgx/v5"
     "go.uber.org/zap"
-    "golang.org/x/crypto/bcrypt"
+    "golang.org/x/sync/singleflight"
 )
 
+var ErrUserNotFound = apperr.New(apperr.NotFound, "User not found", nil)
+
 type UserService struct {
     db     *database.DB
+    redis  *redis.Client
     logger *zap.SugaredLogger
+
+    // Profile fields users are asked to fill in after signing up
+    requiredFields []string
+
+    // Screens usernames and display names; nil when moderation is off
+    moderation *ModerationService
+
+    // Per-user limits while Redis is unavailable
+    local *localLimiter
+
+    // lookups collapses concurrent reads of the same user (or the same batch
+    // of users) into a single query, e.g. when a popular room loads
+    lookups singleflight.Group
 }
 
-func NewUserService(db *database.DB, logger *zap.SugaredLogger) *UserService {
+func NewUserService(db *database.DB, redis *redis.Client, logger *zap.SugaredLogger) *UserService {
     return &UserService{
         db:     db,
+        redis:  redis,
+        local:  newLocalLimiter(),
         logger: logger,
     }
 }
 
-func (s *UserService) GetUserByID(ctx context.Context, userID uuid.UUID) (*models.User, error) {
+// SetModeration screens username and display name changes.
+func (s *UserService) SetModeration(moderation *ModerationService) {
+    s.moderation = moderation
+}
+
+const userColumns = `id, email, username, email_verified, email_verified_at, email_bounced_at, is_guest, role,
+    plan, data_region, created_at, updated_at, last_login`
+
+func scanUser(row pgx.Row) (*models.User, error) {
     user := &models.User{}
-    err := s.db.Pool().QueryRow(ctx,
-        `SELECT id, email, username, email_verified, created_at, updated_at, last_login
-         FROM users WHERE id = $1`,
+    err := row.Scan(&user.ID, &user.Email, &user.Username, &user.EmailVerified, &user.EmailVerifiedAt,
+        &user.EmailBouncedAt, &user.IsGuest, &user.Role, &user.Plan, &user.DataRegion,
+        &user.CreatedAt, &user.UpdatedAt, &user.LastLogin)
+    return user, err
+}
+
+// GetUserByID loads a user. Concurrent calls for the same ID share one query;
+// each caller gets its own copy of the result.
+func (s *UserService) GetUserByID(ctx context.Context, userID uuid.UUID) (*models.User, error) {
+    v, err, _ := s.lookups.Do("user:"+userID.String(), func() (interface{}, error) {
+        // Detach from the first caller's cancellation so it can't fail the
+        // callers that joined its flight
+        return s.getUserByID(context.WithoutCancel(ctx), userID)
+    })
+    if err != nil {
+        return nil, err
+    }
+
+    user := *v.(*models.User)
+    return &user, nil
+}
+
+func (s *UserService) getUserByID(ctx context.Context, userID uuid.UUID) (*models.User, error) {
+    user, err := scanUser(s.db.Pool().QueryRow(ctx,
+        `SELECT `+userColumns+` FROM users WHERE id = $1`,
         userID,
-    ).Scan(&user.ID, &user.Email, &user.Username, &user.EmailVerified, 
-           &user.CreatedAt, &user.UpdatedAt, &user.LastLogin)
-    
+    ))
     if err != nil {
+        if err == pgx.ErrNoRows {
+            return nil, ErrUserNotFound
+        }
         return nil, fmt.Errorf("get user: %w", err)
     }
 
     return user, nil
 }
 
+// GetUsersByIDs loads the users that exist among userIDs, in no particular
+// order. Concurrent calls for the same set of IDs share one query.
+func (s *UserService) GetUsersByIDs(ctx context.Context, userIDs []uuid.UUID) ([]*models.User, error) {
+    keys := make([]string, 0, len(userIDs))
+    seen := make(map[uuid.UUID]bool, len(userIDs))
+    ids := make([]uuid.UUID, 0, len(userIDs))
+    for _, id := range userIDs {
+        if seen[id] {
+            continue
+        }
+        seen[id] = true
+        ids = append(ids, id)
+        keys = append(keys, id.String())
+    }
+    if len(ids) == 0 {
+        return []*models.User{}, nil
+    }
+    sort.Strings(keys)
+
+    v, err, _ := s.lookups.Do("users:"+strings.Join(keys, ","), func() (interface{}, error) {
+        return s.getUsersByIDs(context.WithoutCancel(ctx), ids)
+    })
+    if err != nil {
+        return nil, err
+    }
+
+    shared := v.([]*models.User)
+    users := make([]*models.User, len(shared))
+    for i, u := range shared {
+        user := *u
+        users[i] = &user
+    }
+    return users, nil
+}
+
+func (s *UserService) getUsersByIDs(ctx context.Context, userIDs []uuid.UUID) ([]*models.User, error) {
+    rows, err := s.db.Pool().Query(ctx,
+        `SELECT `+userColumns+` FROM users WHERE id = ANY($1)`,
+        userIDs,
+    )
+    if err != nil {
+        return nil, fmt.Errorf("get users: %w", err)
+    }
+    defer rows.Close()
+
+    users := []*models.User{}
+    for rows.Next() {
+        user, err := scanUser(rows)
+        if err != nil {
+            return nil, fmt.Errorf("scan user: %w", err)
+        }
+        users = append(users, user)
+    }
+    return users, rows.Err()
+}
+
+// UpdateProfile changes the user's username and queues user:username_changed
+// and user:snapshot events in the same transaction. Setting the current
+// username again changes nothing. Usernames denied by moderation are
+// rejected with a *ContentDeniedError.
 func (s *UserService) UpdateProfile(ctx context.Context, userID uuid.UUID, username string) error {
-    _, err := s.db.Pool().Exec(ctx,
-        "UPDATE users SET username = $1, updated_at = NOW() WHERE id = $2",
+    outcome, err := s.moderation.Check(ctx, &userID, models.ModerationFieldUsername, username)
+    if err != nil {
+        return err
+    }
+
+    tx, err := s.db.Pool().Begin(ctx)
+    if err != nil {
+        return fmt.Errorf("begin transaction: %w", err)
+    }
+    defer tx.Rollback(ctx)
+
+    var oldUsername string
+    var seq int64
+    err = tx.QueryRow(ctx,
+        `UPDATE users u SET username = $1, change_seq = u.change_seq + 1, updated_at = NOW()
+         FROM (SELECT username FROM users WHERE id = $2 FOR UPDATE) old
+         WHERE u.id = $2 AND old.username <> $1
+         RETURNING old.username, u.change_seq`,
         username, userID,
-    )
-    return err
+    ).Scan(&oldUsername, &seq)
+    if err != nil {
+        if err == pgx.ErrNoRows {
+            return nil
+        }
+        return fmt.Errorf("update profile: %w", err)
+    }
+
+    event := events.NewUserEvent(events.UserUsernameChanged, userID.String(), username)
+    event.Data["old_username"] = oldUsername
+    event.Data["new_username"] = username
+    event.Data["seq"] = seq
+    if err := enqueueEvent(ctx, tx, event); err != nil {
+        return err
+    }
+    if err := enqueueSnapshot(ctx, tx, userID); err != nil {
+        return err
+    }
+
+    if err := tx.Commit(ctx); err != nil {
+        return fmt.Errorf("commit transaction: %w", err)
+    }
+
+    if err := forgetUsernames(ctx, s.redis, oldUsername, username); err != nil {
+        s.logger.Errorf("Failed to invalidate username cache: %v", err)
+    }
+    s.moderation.Track(ctx, userID, models.ModerationFieldUsername, username, outcome)
+    return nil
 }
 
 func (s *UserService) ChangePassword(ctx context.Context, userID uuid.UUID, oldPassword, newPassword string) error {
@@ -60,29 +217,74 @@ func (s *UserService) ChangePassword(ctx context.Context, userID uuid.UUID, oldP
     }
 
     // Verify old password
-    if err := bcrypt.CompareHashAndPassword([]byte(currentHash), []byte(oldPassword)); err != nil {
-        return ErrInvalidCredentials
+    if err := passwords.Compare(ctx, currentHash, oldPassword); err != nil {
+        return err
     }
 
     // Hash new password
-    hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+    hashedPassword, err := passwords.Hash(ctx, newPassword)
     if err != nil {
-        return fmt.Errorf("hash password: %w", err)
+        return err
     }
 
     // Update password
     _, err = s.db.Pool().Exec(ctx,
-        "UPDATE users SET password_hash = $1, updated_at = NOW() WHERE id = $2",
-        string(hashedPassword), userID,
+        "UPDATE users SET password_hash = $1, password_changed_at = NOW(), updated_at = NOW() WHERE id = $2",
+        hashedPassword, userID,
     )
-    
-    return err
+    if err != nil {
+        return err
+    }
+
+    if err := forgetSecurityScore(ctx, s.redis, userID); err != nil {
+        s.logger.Errorf("Failed to invalidate security score: %v", err)
+    }
+    return nil
 }
 
 func (s *UserService) DeleteUser(ctx context.Context, userID uuid.UUID) error {
-    _, err := s.db.Pool().Exec(ctx,
-        "DELETE FROM users WHERE id = $1",
+    var username string
+    err := s.db.Pool().QueryRow(ctx,
+        "DELETE FROM users WHERE id = $1 RETURNING username",
         userID,
-    )
-    return err
-}
\ No newline at end of file
+    ).Scan(&username)
+    if err != nil {
+        if err == pgx.ErrNoRows {
+            return nil
+        }
+        return err
+    }
+
+    if err := forgetUsernames(ctx, s.redis, username); err != nil {
+        s.logger.Errorf("Failed to invalidate username cache: %v", err)
+    }
+
+    // The account is already gone, so a failed sweep is logged rather than
+    // surfaced; leftover keys expire or are caught by the next purge.
+    if _, err := s.PurgeUserData(ctx, userID); err != nil {
+        s.logger.Errorf("Failed to purge Redis data for user %s: %v", userID, err)
+    }
+
+    return nil
+}
+
+// RecordEmailBounce marks the account using email as due for re-verification.
+// It reports whether an account matched.
+func (s *UserService) RecordEmailBounce(ctx context.Context, email string) (bool, error) {
+    var userID uuid.UUID
+    err := s.db.Pool().QueryRow(ctx,
+        "UPDATE users SET email_bounced_at = NOW() WHERE email = $1 RETURNING id",
+        email,
+    ).Scan(&userID)
+    if err != nil {
+        if err == pgx.ErrNoRows {
+            return false, nil
+        }
+        return false, fmt.Errorf("record email bounce: %w", err)
+    }
+
+    if err := forgetSecurityScore(ctx, s.redis, userID); err != nil {
+        s.logger.Errorf("Failed to invalidate security score: %v", err)
+    }
+    return true, nil
+}
@@ -2,8 +2,12 @@ package services
 
 import (
 	"context"
+	"fmt"
+	"sync"
 	"testing"
+	"time"
 
+	"auth-service/internal/models"
 	"auth-service/test"
 
 	"github.com/google/uuid"
@@ -16,7 +20,7 @@ func TestUserService_GetUserByID(t *testing.T) {
 	suite := test.NewTestSuite(t)
 	defer suite.Cleanup(t)
 
-	userService := NewUserService(suite.DB.DB, suite.Logger)
+	userService := NewUserService(suite.DB.DB, suite.Redis.Client, suite.Logger)
 
 	// Create test user
 	testUser := suite.CreateTestUser(t, test.TestData.ValidEmail, test.TestData.ValidUsername, test.TestData.ValidPassword)
@@ -59,11 +63,48 @@ func TestUserService_GetUserByID(t *testing.T) {
 	}
 }
 
+func TestUserService_GetUsersByIDs(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	userService := NewUserService(suite.DB.DB, suite.Redis.Client, suite.Logger)
+	ctx := context.Background()
+
+	first := suite.CreateTestUser(t, "first@example.com", "firstuser", test.TestData.ValidPassword)
+	second := suite.CreateTestUser(t, "second@example.com", "seconduser", test.TestData.ValidPassword)
+
+	users, err := userService.GetUsersByIDs(ctx, []uuid.UUID{first.ID, second.ID, first.ID, uuid.New()})
+	require.NoError(t, err)
+	require.Len(t, users, 2)
+	assert.ElementsMatch(t, []uuid.UUID{first.ID, second.ID}, []uuid.UUID{users[0].ID, users[1].ID})
+
+	// Concurrent callers get equal but independent copies
+	var wg sync.WaitGroup
+	results := make([]*models.User, 20)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			user, err := userService.GetUserByID(ctx, first.ID)
+			assert.NoError(t, err)
+			results[i] = user
+		}(i)
+	}
+	wg.Wait()
+
+	for _, user := range results {
+		require.NotNil(t, user)
+		assert.Equal(t, first.Email, user.Email)
+	}
+	results[0].Username = "changed"
+	assert.Equal(t, first.Username, results[1].Username)
+}
+
 func TestUserService_UpdateProfile(t *testing.T) {
 	suite := test.NewTestSuite(t)
 	defer suite.Cleanup(t)
 
-	userService := NewUserService(suite.DB.DB, suite.Logger)
+	userService := NewUserService(suite.DB.DB, suite.Redis.Client, suite.Logger)
 
 	// Create test user
 	testUser := suite.CreateTestUser(t, test.TestData.ValidEmail, test.TestData.ValidUsername, test.TestData.ValidPassword)
@@ -112,7 +153,7 @@ func TestUserService_ChangePassword(t *testing.T) {
 	suite := test.NewTestSuite(t)
 	defer suite.Cleanup(t)
 
-	userService := NewUserService(suite.DB.DB, suite.Logger)
+	userService := NewUserService(suite.DB.DB, suite.Redis.Client, suite.Logger)
 
 	// Create test user
 	testUser := suite.CreateTestUser(t, test.TestData.ValidEmail, test.TestData.ValidUsername, test.TestData.ValidPassword)
@@ -185,7 +226,7 @@ func TestUserService_DeleteUser(t *testing.T) {
 	suite := test.NewTestSuite(t)
 	defer suite.Cleanup(t)
 
-	userService := NewUserService(suite.DB.DB, suite.Logger)
+	userService := NewUserService(suite.DB.DB, suite.Redis.Client, suite.Logger)
 
 	// Create test user
 	testUser := suite.CreateTestUser(t, test.TestData.ValidEmail, test.TestData.ValidUsername, test.TestData.ValidPassword)
@@ -211,13 +252,127 @@ func TestUserService_DeleteUser(t *testing.T) {
 	assert.Equal(t, 0, count)
 }
 
+func TestUserService_DeleteUserPurgesRedis(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	userService := NewUserService(suite.DB.DB, suite.Redis.Client, suite.Logger)
+	ctx := context.Background()
+
+	testUser := suite.CreateTestUser(t, test.TestData.ValidEmail, test.TestData.ValidUsername, test.TestData.ValidPassword)
+	otherID := uuid.New()
+
+	userKeys := []string{
+		fmt.Sprintf("user:%s", testUser.ID),
+		fmt.Sprintf("user:%s:settings", testUser.ID),
+		fmt.Sprintf("ratelimit:user:%s:login", testUser.ID),
+		fmt.Sprintf("presence:%s", testUser.ID),
+		fmt.Sprintf("otp:%s:login", testUser.ID),
+		fmt.Sprintf("device_trust:%s:laptop", testUser.ID),
+	}
+	for _, key := range userKeys {
+		require.NoError(t, suite.Redis.Set(ctx, key, "1", time.Hour))
+	}
+	otherKey := fmt.Sprintf("user:%s", otherID)
+	require.NoError(t, suite.Redis.Set(ctx, otherKey, "1", time.Hour))
+
+	require.NoError(t, userService.DeleteUser(ctx, testUser.ID))
+
+	for _, key := range userKeys {
+		exists, err := suite.Redis.Exists(ctx, key)
+		require.NoError(t, err)
+		assert.False(t, exists, key)
+	}
+
+	exists, err := suite.Redis.Exists(ctx, otherKey)
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func TestUserService_UserState(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	userService := NewUserService(suite.DB.DB, suite.Redis.Client, suite.Logger)
+	ctx := context.Background()
+
+	testUser := suite.CreateTestUser(t, test.TestData.ValidEmail, test.TestData.ValidUsername, test.TestData.ValidPassword)
+
+	lockout := loginAccountKey(testUser.Email) + ":locked"
+	rateLimit := fmt.Sprintf("ratelimit:user:%s:search:1", testUser.ID)
+	profile := fmt.Sprintf("user:%s", testUser.ID)
+	require.NoError(t, suite.Redis.Set(ctx, lockout, "1", time.Hour))
+	require.NoError(t, suite.Redis.Set(ctx, rateLimit, "1", time.Hour))
+	require.NoError(t, suite.Redis.Set(ctx, profile, "1", 0))
+	require.NoError(t, suite.Redis.Set(ctx, loginAccountKey("other@example.com")+":locked", "1", time.Hour))
+
+	keys, err := userService.ListUserState(ctx, testUser.ID)
+	require.NoError(t, err)
+	byKey := make(map[string]*models.UserRedisKey)
+	for _, key := range keys {
+		byKey[key.Key] = key
+	}
+	require.Len(t, byKey, 3)
+	assert.Equal(t, models.UserKeyCategoryLoginLockout, byKey[lockout].Category)
+	assert.Greater(t, byKey[lockout].TTLSeconds, int64(0))
+	assert.Equal(t, models.UserKeyCategoryRateLimit, byKey[rateLimit].Category)
+	assert.Equal(t, int64(-1), byKey[profile].TTLSeconds)
+
+	// Purging one category leaves the others
+	deleted, err := userService.PurgeUserState(ctx, testUser.ID, models.UserKeyCategoryLoginLockout)
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+
+	exists, err := suite.Redis.Exists(ctx, rateLimit)
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	deleted, err = userService.PurgeUserState(ctx, testUser.ID, "")
+	require.NoError(t, err)
+	assert.Equal(t, 2, deleted)
+
+	exists, err = suite.Redis.Exists(ctx, loginAccountKey("other@example.com")+":locked")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	_, err = userService.ListUserState(ctx, uuid.New())
+	assert.Equal(t, ErrUserNotFound, err)
+}
+
 func TestUserService_DeleteNonExistingUser(t *testing.T) {
 	suite := test.NewTestSuite(t)
 	defer suite.Cleanup(t)
 
-	userService := NewUserService(suite.DB.DB, suite.Logger)
+	userService := NewUserService(suite.DB.DB, suite.Redis.Client, suite.Logger)
 
 	// Try to delete non-existing user
 	err := userService.DeleteUser(context.Background(), uuid.New())
 	require.NoError(t, err) // DELETE with no rows affected doesn't error
-}
\ No newline at end of file
+}
+func TestUserService_ResolveUsernames(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	userService := NewUserService(suite.DB.DB, suite.Redis.Client, suite.Logger)
+	ctx := context.Background()
+
+	alice := suite.CreateTestUser(t, "alice@example.com", "alice", test.TestData.ValidPassword)
+
+	resolved, err := userService.ResolveUsernames(ctx, []string{"alice", "nobody", "alice"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]uuid.UUID{"alice": alice.ID}, resolved)
+
+	// Both the hit and the miss are cached
+	cached, err := suite.Redis.Get(ctx, usernameCachePrefix+"alice")
+	require.NoError(t, err)
+	assert.Equal(t, alice.ID.String(), cached)
+	cached, err = suite.Redis.Get(ctx, usernameCachePrefix+"nobody")
+	require.NoError(t, err)
+	assert.Equal(t, usernameMiss, cached)
+
+	// Renaming invalidates both the old name and the cached miss for the new one
+	require.NoError(t, userService.UpdateProfile(ctx, alice.ID, "nobody"))
+	resolved, err = userService.ResolveUsernames(ctx, []string{"alice", "nobody"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]uuid.UUID{"nobody": alice.ID}, resolved)
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+    "context"
+    "fmt"
+
+    "auth-service/internal/events"
+    "auth-service/internal/models"
+
+    "github.com/google/uuid"
+    "github.com/jackc/pgx/v5"
+)
+
+const snapshotColumns = `id, username, display_name, avatar_url, discoverable, public_card, is_guest,
+    deletion_requested_at IS NOT NULL, change_seq, data_region`
+
+// queryExecer is a transaction or the pool.
+type queryExecer interface {
+    execer
+    QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+func scanSnapshot(row pgx.Row) (*events.UserEvent, error) {
+    var userID uuid.UUID
+    var username string
+    var displayName, avatarURL *string
+    var discoverable, publicCard, guest, deleted bool
+    var seq int64
+    var dataRegion string
+    err := row.Scan(&userID, &username, &displayName, &avatarURL, &discoverable, &publicCard, &guest, &deleted, &seq,
+        &dataRegion)
+    if err != nil {
+        return nil, err
+    }
+
+    event := events.NewUserEvent(events.UserSnapshot, userID.String(), username)
+    event.DataRegion = dataRegion
+    event.Data["handle"] = username
+    event.Data["display_name"] = displayName
+    event.Data["avatar_url"] = avatarURL
+    event.Data["discoverable"] = discoverable
+    event.Data["public_card"] = publicCard
+    event.Data["guest"] = guest
+    event.Data["deleted"] = deleted
+    event.Data["seq"] = seq
+    return event, nil
+}
+
+// enqueueSnapshot queues a user:snapshot event with the user's public profile
+// as of tx. Call it in the transaction of every change to a snapshot field,
+// after the change and after bumping change_seq. A user that no longer exists
+// has nothing to publish.
+func enqueueSnapshot(ctx context.Context, tx queryExecer, userID uuid.UUID) error {
+    event, err := scanSnapshot(tx.QueryRow(ctx, "SELECT "+snapshotColumns+" FROM users WHERE id = $1", userID))
+    if err != nil {
+        if err == pgx.ErrNoRows {
+            return nil
+        }
+        return fmt.Errorf("get user snapshot: %w", err)
+    }
+    return enqueueEvent(ctx, tx, event)
+}
+
+// RepublishSnapshots queues a fresh user:snapshot event for each of the users
+// that exists, e.g. for another service rebuilding its read model. The events
+// carry the current seq, so consumers that are up to date can ignore them.
+func (s *UserService) RepublishSnapshots(ctx context.Context, userIDs []uuid.UUID) (*models.SnapshotRepublish, error) {
+    tx, err := s.db.Pool().Begin(ctx)
+    if err != nil {
+        return nil, fmt.Errorf("begin transaction: %w", err)
+    }
+    defer tx.Rollback(ctx)
+
+    rows, err := tx.Query(ctx, "SELECT "+snapshotColumns+" FROM users WHERE id = ANY($1)", userIDs)
+    if err != nil {
+        return nil, fmt.Errorf("get user snapshots: %w", err)
+    }
+    var snapshots []*events.UserEvent
+    for rows.Next() {
+        event, err := scanSnapshot(rows)
+        if err != nil {
+            rows.Close()
+            return nil, fmt.Errorf("scan user snapshot: %w", err)
+        }
+        snapshots = append(snapshots, event)
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil {
+        return nil, fmt.Errorf("get user snapshots: %w", err)
+    }
+
+    found := make(map[string]bool, len(snapshots))
+    for _, event := range snapshots {
+        if err := enqueueEvent(ctx, tx, event); err != nil {
+            return nil, err
+        }
+        found[event.UserID] = true
+    }
+
+    if err := tx.Commit(ctx); err != nil {
+        return nil, fmt.Errorf("commit transaction: %w", err)
+    }
+
+    result := &models.SnapshotRepublish{Published: len(snapshots), Missing: []uuid.UUID{}}
+    for _, userID := range userIDs {
+        if !found[userID.String()] {
+            result.Missing = append(result.Missing, userID)
+            found[userID.String()] = true
+        }
+    }
+    return result, nil
+}
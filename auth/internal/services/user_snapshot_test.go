@@ -0,0 +1,68 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"auth-service/internal/events"
+	"auth-service/internal/models"
+	"auth-service/test"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func outboxSnapshots(t *testing.T, suite *test.TestSuite, userID uuid.UUID) []*events.UserEvent {
+	rows, err := suite.DB.DB.Pool().Query(context.Background(),
+		"SELECT event FROM event_outbox WHERE event->>'type' = $1 AND event->>'user_id' = $2 ORDER BY id",
+		string(events.UserSnapshot), userID.String(),
+	)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var snapshots []*events.UserEvent
+	for rows.Next() {
+		var raw []byte
+		require.NoError(t, rows.Scan(&raw))
+		event := &events.UserEvent{}
+		require.NoError(t, json.Unmarshal(raw, event))
+		snapshots = append(snapshots, event)
+	}
+	require.NoError(t, rows.Err())
+	return snapshots
+}
+
+func TestUserService_Snapshots(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	userService := NewUserService(suite.DB.DB, suite.Redis.Client, suite.Logger)
+	ctx := context.Background()
+	user := suite.CreateTestUser(t, "test@example.com", "testuser", test.TestData.ValidPassword)
+
+	displayName := "Test User"
+	public := true
+	require.NoError(t, userService.UpdatePublicProfile(ctx, user.ID, &models.PublicProfileRequest{DisplayName: &displayName, PublicCard: &public}))
+	require.NoError(t, userService.UpdateProfile(ctx, user.ID, "renamed"))
+
+	snapshots := outboxSnapshots(t, suite, user.ID)
+	require.Len(t, snapshots, 2)
+	assert.Equal(t, "Test User", snapshots[0].Data["display_name"])
+	assert.Equal(t, true, snapshots[0].Data["public_card"])
+	assert.Equal(t, "renamed", snapshots[1].Data["handle"])
+	assert.Equal(t, false, snapshots[1].Data["deleted"])
+	assert.Greater(t, snapshots[1].Data["seq"], snapshots[0].Data["seq"])
+
+	// A re-publish repeats the latest snapshot and reports unknown users
+	unknown := uuid.New()
+	result, err := userService.RepublishSnapshots(ctx, []uuid.UUID{user.ID, unknown})
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Published)
+	assert.Equal(t, []uuid.UUID{unknown}, result.Missing)
+
+	snapshots = outboxSnapshots(t, suite, user.ID)
+	require.Len(t, snapshots, 3)
+	assert.Equal(t, snapshots[1].Data, snapshots[2].Data)
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+    "context"
+    "fmt"
+    "time"
+
+    "auth-service/internal/redis"
+
+    "github.com/google/uuid"
+)
+
+const (
+    usernameCachePrefix = "username:"
+    usernameCacheTTL    = 10 * time.Minute
+    // Unknown names are cached briefly so repeated bad @mentions don't hit
+    // the database, without hiding newly registered names for long
+    usernameMissTTL = time.Minute
+    // Cached value for a username that doesn't exist
+    usernameMiss = "-"
+)
+
+// ResolveUsernames maps usernames to user IDs, for resolving @mentions.
+// Unknown usernames are left out of the result. Lookups are cached in Redis,
+// including misses; if Redis is unavailable they go straight to the database.
+func (s *UserService) ResolveUsernames(ctx context.Context, usernames []string) (map[string]uuid.UUID, error) {
+    resolved := make(map[string]uuid.UUID, len(usernames))
+    var uncached []string
+    seen := make(map[string]bool, len(usernames))
+    for _, username := range usernames {
+        if seen[username] {
+            continue
+        }
+        seen[username] = true
+
+        cached, err := s.redis.Get(ctx, usernameCachePrefix+username)
+        if err != nil {
+            if err != redis.Nil {
+                s.logger.Errorf("Failed to read username cache: %v", err)
+            }
+            uncached = append(uncached, username)
+            continue
+        }
+        if cached == usernameMiss {
+            continue
+        }
+        if id, err := uuid.Parse(cached); err == nil {
+            resolved[username] = id
+        } else {
+            uncached = append(uncached, username)
+        }
+    }
+    if len(uncached) == 0 {
+        return resolved, nil
+    }
+
+    rows, err := s.db.Pool().Query(ctx,
+        "SELECT username, id FROM users WHERE username = ANY($1)",
+        uncached,
+    )
+    if err != nil {
+        return nil, fmt.Errorf("resolve usernames: %w", err)
+    }
+    defer rows.Close()
+
+    for rows.Next() {
+        var username string
+        var id uuid.UUID
+        if err := rows.Scan(&username, &id); err != nil {
+            return nil, fmt.Errorf("scan username: %w", err)
+        }
+        resolved[username] = id
+    }
+    if err := rows.Err(); err != nil {
+        return nil, fmt.Errorf("resolve usernames: %w", err)
+    }
+
+    for _, username := range uncached {
+        value, ttl := usernameMiss, usernameMissTTL
+        if id, ok := resolved[username]; ok {
+            value, ttl = id.String(), usernameCacheTTL
+        }
+        if err := s.redis.Set(ctx, usernameCachePrefix+username, value, ttl); err != nil {
+            s.logger.Errorf("Failed to write username cache: %v", err)
+            break
+        }
+    }
+
+    return resolved, nil
+}
+
+// forgetUsernames drops cached resolutions, e.g. after a rename, a new
+// registration or a deletion.
+func forgetUsernames(ctx context.Context, client *redis.Client, usernames ...string) error {
+    keys := make([]string, len(usernames))
+    for i, username := range usernames {
+        keys[i] = usernameCachePrefix + username
+    }
+    return client.Delete(ctx, keys...)
+}
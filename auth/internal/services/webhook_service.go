@@ -0,0 +1,281 @@
+package services
+
+import (
+    "bytes"
+    "context"
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net"
+    "net/http"
+    "strconv"
+    "syscall"
+    "time"
+
+    "auth-service/internal/apperr"
+    "auth-service/internal/database"
+    "auth-service/internal/events"
+    "auth-service/internal/models"
+
+    "github.com/google/uuid"
+    "go.uber.org/zap"
+)
+
+const (
+    maxWebhooksPerUser     = 5
+    webhookDeliveryTimeout = 5 * time.Second
+)
+
+var (
+    ErrWebhookNotFound = apperr.New(apperr.NotFound, "Webhook not found", nil)
+    ErrWebhookLimit    = apperr.New(apperr.Conflict, "Webhook limit reached", nil)
+    errWebhookBlocked  = errors.New("webhook address not allowed")
+)
+
+// WebhookService lets users subscribe their own URL to their account's events.
+// Every delivery is signed with the webhook's secret; see signWebhook.
+type WebhookService struct {
+    db     *database.DB
+    client *http.Client
+    logger *zap.SugaredLogger
+}
+
+func NewWebhookService(db *database.DB, logger *zap.SugaredLogger) *WebhookService {
+    dialer := &net.Dialer{
+        Timeout: webhookDeliveryTimeout,
+        Control: rejectInternalAddress,
+    }
+
+    return &WebhookService{
+        db: db,
+        client: &http.Client{
+            Timeout:   webhookDeliveryTimeout,
+            Transport: &http.Transport{DialContext: dialer.DialContext},
+            // Redirects could point the delivery at an internal address
+            CheckRedirect: func(*http.Request, []*http.Request) error {
+                return http.ErrUseLastResponse
+            },
+        },
+        logger: logger,
+    }
+}
+
+// Create registers a webhook and returns it with its signing secret.
+func (s *WebhookService) Create(ctx context.Context, userID uuid.UUID, req *models.CreateWebhookRequest) (*models.UserWebhook, string, error) {
+    var count int
+    err := s.db.Pool().QueryRow(ctx,
+        "SELECT COUNT(*) FROM user_webhooks WHERE user_id = $1",
+        userID,
+    ).Scan(&count)
+    if err != nil {
+        return nil, "", fmt.Errorf("count webhooks: %w", err)
+    }
+    if count >= maxWebhooksPerUser {
+        return nil, "", ErrWebhookLimit
+    }
+
+    secret := generateToken()
+    hook := &models.UserWebhook{}
+    err = s.db.Pool().QueryRow(ctx,
+        `INSERT INTO user_webhooks (user_id, url, secret, events)
+         VALUES ($1, $2, $3, $4)
+         RETURNING id, user_id, url, events, created_at, last_delivery_at, last_status_code`,
+        userID, req.URL, secret, req.Events,
+    ).Scan(&hook.ID, &hook.UserID, &hook.URL, &hook.Events, &hook.CreatedAt, &hook.LastDeliveryAt, &hook.LastStatusCode)
+    if err != nil {
+        return nil, "", fmt.Errorf("create webhook: %w", err)
+    }
+
+    return hook, secret, nil
+}
+
+func (s *WebhookService) List(ctx context.Context, userID uuid.UUID) ([]*models.UserWebhook, error) {
+    rows, err := s.db.Pool().Query(ctx,
+        `SELECT id, user_id, url, events, created_at, last_delivery_at, last_status_code
+         FROM user_webhooks WHERE user_id = $1 ORDER BY created_at`,
+        userID,
+    )
+    if err != nil {
+        return nil, fmt.Errorf("list webhooks: %w", err)
+    }
+    defer rows.Close()
+
+    hooks := []*models.UserWebhook{}
+    for rows.Next() {
+        hook := &models.UserWebhook{}
+        if err := rows.Scan(&hook.ID, &hook.UserID, &hook.URL, &hook.Events, &hook.CreatedAt,
+            &hook.LastDeliveryAt, &hook.LastStatusCode); err != nil {
+            return nil, fmt.Errorf("scan webhook: %w", err)
+        }
+        hooks = append(hooks, hook)
+    }
+
+    return hooks, rows.Err()
+}
+
+func (s *WebhookService) Delete(ctx context.Context, userID, webhookID uuid.UUID) error {
+    result, err := s.db.Pool().Exec(ctx,
+        "DELETE FROM user_webhooks WHERE id = $1 AND user_id = $2",
+        webhookID, userID,
+    )
+    if err != nil {
+        return fmt.Errorf("delete webhook: %w", err)
+    }
+    if result.RowsAffected() == 0 {
+        return ErrWebhookNotFound
+    }
+    return nil
+}
+
+// RotateSecret replaces a webhook's signing secret and returns the new one.
+func (s *WebhookService) RotateSecret(ctx context.Context, userID, webhookID uuid.UUID) (string, error) {
+    secret := generateToken()
+    result, err := s.db.Pool().Exec(ctx,
+        "UPDATE user_webhooks SET secret = $1 WHERE id = $2 AND user_id = $3",
+        secret, webhookID, userID,
+    )
+    if err != nil {
+        return "", fmt.Errorf("rotate webhook secret: %w", err)
+    }
+    if result.RowsAffected() == 0 {
+        return "", ErrWebhookNotFound
+    }
+    return secret, nil
+}
+
+// Dispatch delivers an event to every webhook of its user subscribed to it.
+// Delivery happens in the background and is best effort: failures are logged
+// and recorded on the webhook, never retried.
+func (s *WebhookService) Dispatch(event *events.UserEvent) {
+    userID, err := uuid.Parse(event.UserID)
+    if err != nil {
+        return
+    }
+
+    go func() {
+        ctx, cancel := context.WithTimeout(context.Background(), 2*webhookDeliveryTimeout)
+        defer cancel()
+
+        hooks, err := s.subscribed(ctx, userID, string(event.Type))
+        if err != nil {
+            s.logger.Errorf("Failed to load webhooks for user %s: %v", userID, err)
+            return
+        }
+
+        for _, hook := range hooks {
+            s.deliver(ctx, hook, event)
+        }
+    }()
+}
+
+func (s *WebhookService) subscribed(ctx context.Context, userID uuid.UUID, eventType string) ([]*models.UserWebhook, error) {
+    rows, err := s.db.Pool().Query(ctx,
+        `SELECT id, user_id, url, secret FROM user_webhooks
+         WHERE user_id = $1 AND $2 = ANY(events)`,
+        userID, eventType,
+    )
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    hooks := []*models.UserWebhook{}
+    for rows.Next() {
+        hook := &models.UserWebhook{}
+        if err := rows.Scan(&hook.ID, &hook.UserID, &hook.URL, &hook.Secret); err != nil {
+            return nil, err
+        }
+        hooks = append(hooks, hook)
+    }
+    return hooks, rows.Err()
+}
+
+func (s *WebhookService) deliver(ctx context.Context, hook *models.UserWebhook, event *events.UserEvent) {
+    body, err := json.Marshal(models.WebhookDelivery{
+        ID:        uuid.New(),
+        Event:     string(event.Type),
+        UserID:    event.UserID,
+        Timestamp: event.Timestamp,
+        Data:      event.Data,
+    })
+    if err != nil {
+        s.logger.Errorf("Failed to encode webhook delivery: %v", err)
+        return
+    }
+
+    req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+    if err != nil {
+        s.logger.Errorf("Failed to build webhook request for %s: %v", hook.ID, err)
+        return
+    }
+    timestamp := time.Now().Unix()
+    req.Header.Set("Content-Type", "application/json")
+    req.Header.Set("X-Webhook-Event", string(event.Type))
+    req.Header.Set("X-Webhook-Signature", signWebhook(hook.Secret, timestamp, body))
+
+    status := 0
+    resp, err := s.client.Do(req)
+    if err != nil {
+        s.logger.Warnf("Webhook %s delivery failed: %v", hook.ID, err)
+    } else {
+        status = resp.StatusCode
+        resp.Body.Close()
+    }
+
+    _, err = s.db.Pool().Exec(ctx,
+        "UPDATE user_webhooks SET last_delivery_at = NOW(), last_status_code = $1 WHERE id = $2",
+        status, hook.ID,
+    )
+    if err != nil {
+        s.logger.Errorf("Failed to record webhook %s delivery: %v", hook.ID, err)
+    }
+}
+
+// signWebhook returns the X-Webhook-Signature value "t=<unix>,v1=<hex>",
+// where v1 is the HMAC-SHA256 of "<unix>.<body>" keyed with the webhook
+// secret. Receivers should recompute it and reject stale timestamps.
+func signWebhook(secret string, timestamp int64, body []byte) string {
+    ts := strconv.FormatInt(timestamp, 10)
+    mac := hmac.New(sha256.New, []byte(secret))
+    mac.Write([]byte(ts))
+    mac.Write([]byte("."))
+    mac.Write(body)
+    return "t=" + ts + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// rejectInternalAddress stops webhook deliveries from reaching loopback,
+// private or link-local addresses, whatever the URL's host resolved to.
+func rejectInternalAddress(network, address string, _ syscall.RawConn) error {
+    host, _, err := net.SplitHostPort(address)
+    if err != nil {
+        return err
+    }
+    ip := net.ParseIP(host)
+    if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+        ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() {
+        return errWebhookBlocked
+    }
+    return nil
+}
+
+// WebhookPublisher forwards user events to the wrapped publisher and also
+// delivers them to the user's own webhooks.
+type WebhookPublisher struct {
+    next     EventPublisher
+    webhooks *WebhookService
+}
+
+func NewWebhookPublisher(next EventPublisher, webhooks *WebhookService) *WebhookPublisher {
+    return &WebhookPublisher{
+        next:     next,
+        webhooks: webhooks,
+    }
+}
+
+func (p *WebhookPublisher) PublishUserEvent(event *events.UserEvent) error {
+    p.webhooks.Dispatch(event)
+    return p.next.PublishUserEvent(event)
+}
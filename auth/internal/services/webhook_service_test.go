@@ -0,0 +1,70 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"auth-service/internal/models"
+	"auth-service/test"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignWebhook(t *testing.T) {
+	body := []byte(`{"event":"user:login"}`)
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("1700000000." + string(body)))
+	want := "t=1700000000,v1=" + hex.EncodeToString(mac.Sum(nil))
+
+	assert.Equal(t, want, signWebhook("secret", 1700000000, body))
+	assert.NotEqual(t, want, signWebhook("other", 1700000000, body))
+}
+
+func TestRejectInternalAddress(t *testing.T) {
+	for _, addr := range []string{"127.0.0.1:80", "10.1.2.3:443", "192.168.0.1:80", "169.254.169.254:80", "[::1]:443", "0.0.0.0:80"} {
+		assert.Equal(t, errWebhookBlocked, rejectInternalAddress("tcp", addr, nil), addr)
+	}
+	assert.NoError(t, rejectInternalAddress("tcp", "93.184.216.34:443", nil))
+}
+
+func TestWebhookService_CreateListDelete(t *testing.T) {
+	suite := test.NewTestSuite(t)
+	defer suite.Cleanup(t)
+
+	webhookService := NewWebhookService(suite.DB.DB, suite.Logger)
+	ctx := context.Background()
+
+	user := suite.CreateTestUser(t, test.TestData.ValidEmail, test.TestData.ValidUsername, test.TestData.ValidPassword)
+	req := &models.CreateWebhookRequest{URL: "https://example.com/hook", Events: []string{"user:login"}}
+
+	hook, secret, err := webhookService.Create(ctx, user.ID, req)
+	require.NoError(t, err)
+	assert.NotEmpty(t, secret)
+	assert.Equal(t, []string{"user:login"}, hook.Events)
+
+	for i := 1; i < maxWebhooksPerUser; i++ {
+		_, _, err := webhookService.Create(ctx, user.ID, req)
+		require.NoError(t, err)
+	}
+	_, _, err = webhookService.Create(ctx, user.ID, req)
+	assert.Equal(t, ErrWebhookLimit, err)
+
+	hooks, err := webhookService.List(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Len(t, hooks, maxWebhooksPerUser)
+
+	rotated, err := webhookService.RotateSecret(ctx, user.ID, hook.ID)
+	require.NoError(t, err)
+	assert.NotEqual(t, secret, rotated)
+
+	// Other users can't touch the webhook
+	assert.Equal(t, ErrWebhookNotFound, webhookService.Delete(ctx, uuid.New(), hook.ID))
+	require.NoError(t, webhookService.Delete(ctx, user.ID, hook.ID))
+	assert.Equal(t, ErrWebhookNotFound, webhookService.Delete(ctx, user.ID, hook.ID))
+}
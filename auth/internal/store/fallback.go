@@ -0,0 +1,80 @@
+package store
+
+import (
+    "context"
+    "errors"
+    "time"
+
+    "go.uber.org/zap"
+)
+
+// FallbackStore keeps tokens in primary, normally Redis, and falls back to
+// Postgres while primary fails. Entries written during an outage are moved
+// back to primary by Recover, so a token blacklisted while Redis was down
+// stays blacklisted once it is back.
+type FallbackStore struct {
+    primary  TokenStore
+    fallback PostgresStore
+    logger   *zap.SugaredLogger
+}
+
+func NewFallback(primary TokenStore, fallback PostgresStore, logger *zap.SugaredLogger) *FallbackStore {
+    return &FallbackStore{
+        primary:  primary,
+        fallback: fallback,
+        logger:   logger,
+    }
+}
+
+func (s *FallbackStore) Put(ctx context.Context, key, value string, ttl time.Duration) error {
+    err := s.primary.Put(ctx, key, value, ttl)
+    if err == nil {
+        return nil
+    }
+    s.logger.Warnf("Token store unavailable, writing %s to Postgres: %v", key, err)
+    return s.fallback.Put(ctx, key, value, ttl)
+}
+
+// Has checks primary, and fallback only if primary fails. Entries still
+// waiting in fallback for Recover are missed until it runs.
+func (s *FallbackStore) Has(ctx context.Context, key string) (bool, error) {
+    ok, err := s.primary.Has(ctx, key)
+    if err == nil {
+        return ok, nil
+    }
+    return s.fallback.Has(ctx, key)
+}
+
+// Take checks fallback too when primary has no entry, since a single-use
+// token issued during an outage may not have been moved back yet.
+func (s *FallbackStore) Take(ctx context.Context, key string) (string, error) {
+    value, err := s.primary.Take(ctx, key)
+    if err == nil {
+        return value, nil
+    }
+    if !errors.Is(err, ErrNotFound) {
+        s.logger.Warnf("Token store unavailable, taking %s from Postgres: %v", key, err)
+    }
+    return s.fallback.Take(ctx, key)
+}
+
+func (s *FallbackStore) Delete(ctx context.Context, key string) error {
+    primaryErr := s.primary.Delete(ctx, key)
+    if err := s.fallback.Delete(ctx, key); err != nil {
+        return err
+    }
+    return primaryErr
+}
+
+// Recover moves the entries written to fallback during an outage back to
+// primary. Call it once primary is reachable again.
+func (s *FallbackStore) Recover(ctx context.Context) {
+    moved, err := s.fallback.MoveTo(ctx, s.primary)
+    if err != nil {
+        s.logger.Errorf("Failed to move tokens back from Postgres: %v", err)
+        return
+    }
+    if moved > 0 {
+        s.logger.Infof("Moved %d tokens written during the Redis outage back to Redis", moved)
+    }
+}
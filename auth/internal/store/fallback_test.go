@@ -0,0 +1,108 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// flakyStore fails every call while down is set.
+type flakyStore struct {
+	*MemoryStore
+	down bool
+}
+
+var errDown = errors.New("store down")
+
+func (s *flakyStore) Put(ctx context.Context, key, value string, ttl time.Duration) error {
+	if s.down {
+		return errDown
+	}
+	return s.MemoryStore.Put(ctx, key, value, ttl)
+}
+
+func (s *flakyStore) Has(ctx context.Context, key string) (bool, error) {
+	if s.down {
+		return false, errDown
+	}
+	return s.MemoryStore.Has(ctx, key)
+}
+
+func (s *flakyStore) Take(ctx context.Context, key string) (string, error) {
+	if s.down {
+		return "", errDown
+	}
+	return s.MemoryStore.Take(ctx, key)
+}
+
+// movableStore stands in for the Postgres store.
+type movableStore struct {
+	*MemoryStore
+}
+
+func (s *movableStore) RunCleanup(ctx context.Context, interval time.Duration) {}
+
+func (s *movableStore) MoveTo(ctx context.Context, dst TokenStore) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	moved := 0
+	for key, entry := range s.entries {
+		if err := dst.Put(ctx, key, entry.value, time.Until(entry.expiresAt)); err != nil {
+			return moved, err
+		}
+		delete(s.entries, key)
+		moved++
+	}
+	return moved, nil
+}
+
+func TestFallbackStore(t *testing.T) {
+	ctx := context.Background()
+	primary := &flakyStore{MemoryStore: NewMemory()}
+	fallback := &movableStore{MemoryStore: NewMemory()}
+	s := NewFallback(primary, fallback, zap.NewNop().Sugar())
+
+	require.NoError(t, s.Put(ctx, "blacklist:a", "1", time.Minute))
+	ok, err := primary.Has(ctx, "blacklist:a")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// During an outage writes and reads go to the fallback
+	primary.down = true
+	require.NoError(t, s.Put(ctx, "blacklist:b", "1", time.Minute))
+	require.NoError(t, s.Put(ctx, "nonce:1", "google", time.Minute))
+
+	ok, err = s.Has(ctx, "blacklist:b")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	value, err := s.Take(ctx, "nonce:1")
+	require.NoError(t, err)
+	assert.Equal(t, "google", value)
+
+	// Entries written during the outage move back on recovery
+	primary.down = false
+	s.Recover(ctx)
+
+	ok, err = primary.Has(ctx, "blacklist:b")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = fallback.Has(ctx, "blacklist:b")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// Single-use tokens not yet moved back are still found
+	require.NoError(t, fallback.Put(ctx, "nonce:2", "apple", time.Minute))
+	value, err = s.Take(ctx, "nonce:2")
+	require.NoError(t, err)
+	assert.Equal(t, "apple", value)
+
+	_, err = s.Take(ctx, "nonce:3")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
@@ -0,0 +1,92 @@
+package store
+
+import (
+    "context"
+    "sync"
+    "time"
+)
+
+type memoryEntry struct {
+    value     string
+    expiresAt time.Time
+}
+
+// MemoryStore keeps tokens in process memory. State is lost on restart and
+// not shared between instances, so it suits single-node deployments and
+// tests.
+type MemoryStore struct {
+    mu      sync.Mutex
+    entries map[string]memoryEntry
+    now     func() time.Time
+    // Expired entries are swept on every sweepEvery-th Put
+    puts int
+}
+
+const sweepEvery = 1000
+
+func NewMemory() *MemoryStore {
+    return &MemoryStore{
+        entries: make(map[string]memoryEntry),
+        now:     time.Now,
+    }
+}
+
+func (s *MemoryStore) Put(ctx context.Context, key, value string, ttl time.Duration) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    now := s.now()
+    s.entries[key] = memoryEntry{value: value, expiresAt: now.Add(ttl)}
+
+    s.puts++
+    if s.puts%sweepEvery == 0 {
+        for k, entry := range s.entries {
+            if !now.Before(entry.expiresAt) {
+                delete(s.entries, k)
+            }
+        }
+    }
+    return nil
+}
+
+func (s *MemoryStore) Has(ctx context.Context, key string) (bool, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    _, ok := s.get(key)
+    return ok, nil
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string) (string, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    entry, ok := s.get(key)
+    if !ok {
+        return "", ErrNotFound
+    }
+    delete(s.entries, key)
+    return entry.value, nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, key string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    delete(s.entries, key)
+    return nil
+}
+
+// get returns the unexpired entry for key, dropping it if it has expired.
+// Callers must hold mu.
+func (s *MemoryStore) get(key string) (memoryEntry, bool) {
+    entry, ok := s.entries[key]
+    if !ok {
+        return memoryEntry{}, false
+    }
+    if !s.now().Before(entry.expiresAt) {
+        delete(s.entries, key)
+        return memoryEntry{}, false
+    }
+    return entry, true
+}
@@ -0,0 +1,59 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemory()
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	require.NoError(t, s.Put(ctx, "blacklist:a", "1", time.Minute))
+
+	ok, err := s.Has(ctx, "blacklist:a")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = s.Has(ctx, "blacklist:b")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	t.Run("take consumes the entry", func(t *testing.T) {
+		require.NoError(t, s.Put(ctx, "nonce:1", "google", time.Minute))
+
+		value, err := s.Take(ctx, "nonce:1")
+		require.NoError(t, err)
+		assert.Equal(t, "google", value)
+
+		_, err = s.Take(ctx, "nonce:1")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("delete removes the entry", func(t *testing.T) {
+		require.NoError(t, s.Put(ctx, "otp:1", "123456", time.Minute))
+		require.NoError(t, s.Delete(ctx, "otp:1"))
+
+		ok, err := s.Has(ctx, "otp:1")
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("entries expire", func(t *testing.T) {
+		require.NoError(t, s.Put(ctx, "nonce:2", "github", time.Minute))
+		now = now.Add(time.Minute)
+
+		ok, err := s.Has(ctx, "blacklist:a")
+		require.NoError(t, err)
+		assert.False(t, ok)
+
+		_, err = s.Take(ctx, "nonce:2")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
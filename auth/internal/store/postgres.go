@@ -0,0 +1,147 @@
+package store
+
+import (
+    "context"
+    "fmt"
+    "time"
+
+    "auth-service/internal/database"
+
+    "github.com/jackc/pgx/v5"
+    "go.uber.org/zap"
+)
+
+type postgresStore struct {
+    db     *database.DB
+    logger *zap.SugaredLogger
+}
+
+// PostgresStore keeps tokens in the token_store table. Expired rows are
+// ignored on read and removed by RunCleanup.
+type PostgresStore interface {
+    TokenStore
+    RunCleanup(ctx context.Context, interval time.Duration)
+    // MoveTo copies every unexpired entry to dst with its remaining TTL and
+    // removes it here, returning how many were moved.
+    MoveTo(ctx context.Context, dst TokenStore) (int, error)
+}
+
+func NewPostgres(db *database.DB, logger *zap.SugaredLogger) PostgresStore {
+    return &postgresStore{db: db, logger: logger}
+}
+
+func (s *postgresStore) Put(ctx context.Context, key, value string, ttl time.Duration) error {
+    _, err := s.db.Pool().Exec(ctx,
+        `INSERT INTO token_store (key, value, expires_at) VALUES ($1, $2, $3)
+         ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, expires_at = EXCLUDED.expires_at`,
+        key, value, time.Now().Add(ttl),
+    )
+    if err != nil {
+        return fmt.Errorf("put token: %w", err)
+    }
+    return nil
+}
+
+func (s *postgresStore) Has(ctx context.Context, key string) (bool, error) {
+    var exists bool
+    err := s.db.Pool().QueryRow(ctx,
+        "SELECT EXISTS(SELECT 1 FROM token_store WHERE key = $1 AND expires_at > NOW())",
+        key,
+    ).Scan(&exists)
+    if err != nil {
+        return false, fmt.Errorf("check token: %w", err)
+    }
+    return exists, nil
+}
+
+func (s *postgresStore) Take(ctx context.Context, key string) (string, error) {
+    var value string
+    err := s.db.Pool().QueryRow(ctx,
+        "DELETE FROM token_store WHERE key = $1 AND expires_at > NOW() RETURNING value",
+        key,
+    ).Scan(&value)
+    if err != nil {
+        if err == pgx.ErrNoRows {
+            return "", ErrNotFound
+        }
+        return "", fmt.Errorf("take token: %w", err)
+    }
+    return value, nil
+}
+
+func (s *postgresStore) Delete(ctx context.Context, key string) error {
+    if _, err := s.db.Pool().Exec(ctx, "DELETE FROM token_store WHERE key = $1", key); err != nil {
+        return fmt.Errorf("delete token: %w", err)
+    }
+    return nil
+}
+
+func (s *postgresStore) MoveTo(ctx context.Context, dst TokenStore) (int, error) {
+    tx, err := s.db.Pool().Begin(ctx)
+    if err != nil {
+        return 0, fmt.Errorf("begin transaction: %w", err)
+    }
+    defer tx.Rollback(ctx)
+
+    // Rows are only deleted if every copy succeeded; concurrent movers skip
+    // each other's rows
+    rows, err := tx.Query(ctx,
+        `DELETE FROM token_store WHERE key IN (
+             SELECT key FROM token_store WHERE expires_at > NOW() FOR UPDATE SKIP LOCKED
+         )
+         RETURNING key, value, expires_at`,
+    )
+    if err != nil {
+        return 0, fmt.Errorf("claim tokens: %w", err)
+    }
+
+    type entry struct {
+        key, value string
+        expiresAt  time.Time
+    }
+    var entries []entry
+    for rows.Next() {
+        var e entry
+        if err := rows.Scan(&e.key, &e.value, &e.expiresAt); err != nil {
+            rows.Close()
+            return 0, fmt.Errorf("scan token: %w", err)
+        }
+        entries = append(entries, e)
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil {
+        return 0, fmt.Errorf("claim tokens: %w", err)
+    }
+
+    for _, e := range entries {
+        ttl := time.Until(e.expiresAt)
+        if ttl <= 0 {
+            continue
+        }
+        if err := dst.Put(ctx, e.key, e.value, ttl); err != nil {
+            return 0, fmt.Errorf("move token: %w", err)
+        }
+    }
+
+    if err := tx.Commit(ctx); err != nil {
+        return 0, fmt.Errorf("commit transaction: %w", err)
+    }
+    return len(entries), nil
+}
+
+// RunCleanup deletes expired rows every interval until ctx is cancelled.
+func (s *postgresStore) RunCleanup(ctx context.Context, interval time.Duration) {
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+            if _, err := s.db.Pool().Exec(ctx, "DELETE FROM token_store WHERE expires_at <= NOW()"); err != nil {
+                s.logger.Errorf("Failed to clean up expired tokens: %v", err)
+            }
+        }
+    }
+}
@@ -0,0 +1,37 @@
+package store
+
+import (
+    "context"
+    "time"
+
+    "auth-service/internal/redis"
+)
+
+type redisStore struct {
+    client *redis.Client
+}
+
+// NewRedis stores tokens as Redis keys with native expiry.
+func NewRedis(client *redis.Client) TokenStore {
+    return &redisStore{client: client}
+}
+
+func (s *redisStore) Put(ctx context.Context, key, value string, ttl time.Duration) error {
+    return s.client.Set(ctx, key, value, ttl)
+}
+
+func (s *redisStore) Has(ctx context.Context, key string) (bool, error) {
+    return s.client.Exists(ctx, key)
+}
+
+func (s *redisStore) Take(ctx context.Context, key string) (string, error) {
+    value, err := s.client.GetDel(ctx, key)
+    if err == redis.Nil {
+        return "", ErrNotFound
+    }
+    return value, err
+}
+
+func (s *redisStore) Delete(ctx context.Context, key string) error {
+    return s.client.Delete(ctx, key)
+}
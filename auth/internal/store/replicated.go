@@ -0,0 +1,36 @@
+package store
+
+import (
+    "context"
+    "time"
+)
+
+type replicatedStore struct {
+    primary TokenStore
+    replica TokenStore
+}
+
+// NewReplicated writes to primary and answers Has from replica, e.g. a
+// region-local Redis replica of the primary, so validating a token never
+// leaves the region. Blacklist entries become visible in other regions once
+// replication catches up. Take and Delete go to primary so single-use tokens
+// stay single use.
+func NewReplicated(primary, replica TokenStore) TokenStore {
+    return &replicatedStore{primary: primary, replica: replica}
+}
+
+func (s *replicatedStore) Put(ctx context.Context, key, value string, ttl time.Duration) error {
+    return s.primary.Put(ctx, key, value, ttl)
+}
+
+func (s *replicatedStore) Has(ctx context.Context, key string) (bool, error) {
+    return s.replica.Has(ctx, key)
+}
+
+func (s *replicatedStore) Take(ctx context.Context, key string) (string, error) {
+    return s.primary.Take(ctx, key)
+}
+
+func (s *replicatedStore) Delete(ctx context.Context, key string) error {
+    return s.primary.Delete(ctx, key)
+}
@@ -0,0 +1,37 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplicatedStore(t *testing.T) {
+	ctx := context.Background()
+	primary, replica := NewMemory(), NewMemory()
+	s := NewReplicated(primary, replica)
+
+	require.NoError(t, s.Put(ctx, "blacklist:a", "1", time.Minute))
+
+	// Not visible until replicated
+	ok, err := s.Has(ctx, "blacklist:a")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, replica.Put(ctx, "blacklist:a", "1", time.Minute))
+	ok, err = s.Has(ctx, "blacklist:a")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// Single-use tokens are consumed on the primary
+	require.NoError(t, s.Put(ctx, "nonce:1", "google", time.Minute))
+	value, err := s.Take(ctx, "nonce:1")
+	require.NoError(t, err)
+	assert.Equal(t, "google", value)
+
+	_, err = primary.Take(ctx, "nonce:1")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
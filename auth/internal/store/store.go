@@ -0,0 +1,27 @@
+// Package store keeps short-lived token state such as blacklisted access
+// tokens, one-time codes and single-use nonces. Redis is the default backend;
+// the Postgres and in-memory drivers let single-node deployments and tests
+// run without it.
+package store
+
+import (
+    "context"
+    "errors"
+    "time"
+)
+
+// ErrNotFound is returned by Take when the key is missing or expired.
+var ErrNotFound = errors.New("token not found")
+
+// TokenStore is a key-value store whose entries expire.
+type TokenStore interface {
+    // Put stores value under key for ttl, replacing any existing entry.
+    Put(ctx context.Context, key, value string, ttl time.Duration) error
+    // Has reports whether an unexpired entry exists for key.
+    Has(ctx context.Context, key string) (bool, error)
+    // Take returns the value of key and deletes it in one step, so only one
+    // caller can consume a single-use token.
+    Take(ctx context.Context, key string) (string, error)
+    // Delete removes key if present.
+    Delete(ctx context.Context, key string) error
+}
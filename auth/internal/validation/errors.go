@@ -0,0 +1,101 @@
+package validation
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "reflect"
+    "strings"
+
+    "github.com/go-playground/validator/v10"
+)
+
+// FieldError describes one invalid field. Field is the JSON name and Code the
+// failing rule; both are stable and safe for clients to switch on, while
+// Message is localized and meant for display.
+type FieldError struct {
+    Field   string `json:"field"`
+    Code    string `json:"code"`
+    Message string `json:"message"`
+}
+
+// Translate converts an error returned by ShouldBind* into a localized
+// top-level message and per-field errors. fields is empty when the body could
+// not be decoded at all.
+func Translate(err error, acceptLanguage string) (string, []FieldError) {
+    lang := languageFor(acceptLanguage)
+
+    var validationErrs validator.ValidationErrors
+    if errors.As(err, &validationErrs) {
+        fields := make([]FieldError, 0, len(validationErrs))
+        for _, fe := range validationErrs {
+            fields = append(fields, FieldError{
+                Field:   fieldPath(fe),
+                Code:    fe.ActualTag(),
+                Message: fieldMessage(lang, fe),
+            })
+        }
+        return lookup(lang, "failed"), fields
+    }
+
+    var typeErr *json.UnmarshalTypeError
+    if errors.As(err, &typeErr) {
+        return lookup(lang, "failed"), []FieldError{{
+            Field:   typeErr.Field,
+            Code:    "type",
+            Message: lookup(lang, "type"),
+        }}
+    }
+
+    var schemaErr *SchemaError
+    if errors.As(err, &schemaErr) {
+        fields := make([]FieldError, 0, len(schemaErr.Problems))
+        for _, problem := range schemaErr.Problems {
+            fields = append(fields, FieldError{
+                Field:   problem.Field,
+                Code:    problem.Code,
+                Message: lookup(lang, problem.Code),
+            })
+        }
+        return lookup(lang, "failed"), fields
+    }
+
+    var syntaxErr *json.SyntaxError
+    if errors.As(err, &syntaxErr) || errors.Is(err, io.ErrUnexpectedEOF) {
+        return lookup(lang, "invalid_json"), []FieldError{}
+    }
+
+    return lookup(lang, "invalid_body"), []FieldError{}
+}
+
+// fieldPath strips the top-level struct name from the namespace, turning
+// "RegisterRequest.email" into "email" while keeping nested paths intact.
+func fieldPath(fe validator.FieldError) string {
+    ns := fe.Namespace()
+    if i := strings.Index(ns, "."); i >= 0 {
+        return ns[i+1:]
+    }
+    return fe.Field()
+}
+
+func fieldMessage(lang string, fe validator.FieldError) string {
+    // Aliases such as username_length are reported as the rule that failed
+    key := fe.ActualTag()
+    if (key == "min" || key == "max" || key == "len") && fe.Kind() == reflect.String {
+        key += "_string"
+    }
+
+    template, ok := messages[lang][key]
+    if !ok {
+        template, ok = messages["en"][key]
+    }
+    if !ok {
+        return lookup(lang, "invalid")
+    }
+
+    if strings.Contains(template, "%s") {
+        return fmt.Sprintf(template, fe.Param())
+    }
+    return template
+}
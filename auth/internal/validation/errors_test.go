@@ -0,0 +1,63 @@
+package validation
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type signupRequest struct {
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required,min=8"`
+}
+
+func bind(t *testing.T, body string) error {
+	require.NoError(t, Setup(DefaultLimits))
+
+	req, err := http.NewRequest("POST", "/", bytes.NewBufferString(body))
+	require.NoError(t, err)
+
+	var target signupRequest
+	return binding.JSON.Bind(req, &target)
+}
+
+func TestTranslate_FieldErrors(t *testing.T) {
+	err := bind(t, `{"email":"nope","password":"short"}`)
+
+	message, fields := Translate(err, "")
+	assert.Equal(t, "Validation failed", message)
+	assert.Equal(t, []FieldError{
+		{Field: "email", Code: "email", Message: "must be a valid email address"},
+		{Field: "password", Code: "min", Message: "must be at least 8 characters long"},
+	}, fields)
+}
+
+func TestTranslate_AcceptLanguage(t *testing.T) {
+	err := bind(t, `{"password":"longenough"}`)
+
+	message, fields := Translate(err, "fr-CA,fr;q=0.9,en;q=0.5")
+	assert.Equal(t, "La validation a échoué", message)
+	require.Len(t, fields, 1)
+	assert.Equal(t, "email", fields[0].Field)
+	assert.Equal(t, "required", fields[0].Code)
+	assert.Equal(t, "est obligatoire", fields[0].Message)
+
+	message, _ = Translate(err, "de-DE")
+	assert.Equal(t, "Validation failed", message)
+}
+
+func TestTranslate_MalformedBody(t *testing.T) {
+	message, fields := Translate(bind(t, `{"email":`), "es")
+	assert.Equal(t, "El cuerpo de la solicitud no es JSON válido", message)
+	assert.Empty(t, fields)
+
+	message, fields = Translate(bind(t, `{"email":42,"password":"longenough"}`), "")
+	assert.Equal(t, "Validation failed", message)
+	require.Len(t, fields, 1)
+	assert.Equal(t, "email", fields[0].Field)
+	assert.Equal(t, "type", fields[0].Code)
+}
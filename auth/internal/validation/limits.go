@@ -0,0 +1,66 @@
+package validation
+
+import (
+    "fmt"
+    "strconv"
+)
+
+// Limits are the length bounds of user-chosen values. Deployments can
+// tighten or relax them per market; request models refer to them through the
+// username_length, password_length and display_name_length aliases, which
+// report failures as plain min and max.
+type Limits struct {
+    UsernameMin    int `json:"username_min"`
+    UsernameMax    int `json:"username_max"`
+    PasswordMin    int `json:"password_min"`
+    DisplayNameMax int `json:"display_name_max"`
+}
+
+// Bounds limits can't be configured past: the username and display name
+// columns, and bcrypt only hashing the first 72 bytes of a password.
+const (
+    maxUsernameLength    = 50
+    maxDisplayNameLength = 100
+    minPasswordLength    = 6
+    maxPasswordLength    = 72
+)
+
+var DefaultLimits = Limits{
+    UsernameMin:    3,
+    UsernameMax:    50,
+    PasswordMin:    8,
+    DisplayNameMax: 100,
+}
+
+var activeLimits = DefaultLimits
+
+// ActiveLimits returns the limits passed to Setup.
+func ActiveLimits() Limits {
+    return activeLimits
+}
+
+// Check rejects limits that contradict each other or that the database or
+// password hashing couldn't honour.
+func (l Limits) Check() error {
+    if l.UsernameMin < 1 || l.UsernameMin > l.UsernameMax || l.UsernameMax > maxUsernameLength {
+        return fmt.Errorf("username length limits must satisfy 1 <= min <= max <= %d, got %d and %d",
+            maxUsernameLength, l.UsernameMin, l.UsernameMax)
+    }
+    if l.PasswordMin < minPasswordLength || l.PasswordMin > maxPasswordLength {
+        return fmt.Errorf("password minimum length must be between %d and %d, got %d",
+            minPasswordLength, maxPasswordLength, l.PasswordMin)
+    }
+    if l.DisplayNameMax < 1 || l.DisplayNameMax > maxDisplayNameLength {
+        return fmt.Errorf("display name maximum length must be between 1 and %d, got %d",
+            maxDisplayNameLength, l.DisplayNameMax)
+    }
+    return nil
+}
+
+func (l Limits) aliases() map[string]string {
+    return map[string]string{
+        "username_length":     "min=" + strconv.Itoa(l.UsernameMin) + ",max=" + strconv.Itoa(l.UsernameMax),
+        "password_length":     "min=" + strconv.Itoa(l.PasswordMin),
+        "display_name_length": "max=" + strconv.Itoa(l.DisplayNameMax),
+    }
+}
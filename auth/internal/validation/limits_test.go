@@ -0,0 +1,49 @@
+package validation
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type limitedRequest struct {
+	Username string `json:"username" binding:"required,username_length"`
+	Password string `json:"password" binding:"required,password_length"`
+}
+
+func TestSetup_Limits(t *testing.T) {
+	limits := Limits{UsernameMin: 5, UsernameMax: 20, PasswordMin: 12, DisplayNameMax: 60}
+	require.NoError(t, Setup(limits))
+	defer Setup(DefaultLimits)
+	assert.Equal(t, limits, ActiveLimits())
+
+	req, err := http.NewRequest("POST", "/", bytes.NewBufferString(`{"username":"abcd","password":"elevenchars"}`))
+	require.NoError(t, err)
+	var target limitedRequest
+	err = binding.JSON.Bind(req, &target)
+
+	// Aliases are reported as the rule that failed
+	_, fields := Translate(err, "")
+	assert.Equal(t, []FieldError{
+		{Field: "username", Code: "min", Message: "must be at least 5 characters long"},
+		{Field: "password", Code: "min", Message: "must be at least 12 characters long"},
+	}, fields)
+}
+
+func TestLimits_Check(t *testing.T) {
+	assert.NoError(t, DefaultLimits.Check())
+
+	for name, limits := range map[string]Limits{
+		"username min above max":   {UsernameMin: 10, UsernameMax: 5, PasswordMin: 8, DisplayNameMax: 100},
+		"username past column":     {UsernameMin: 3, UsernameMax: 51, PasswordMin: 8, DisplayNameMax: 100},
+		"password too short":       {UsernameMin: 3, UsernameMax: 50, PasswordMin: 4, DisplayNameMax: 100},
+		"password past bcrypt":     {UsernameMin: 3, UsernameMax: 50, PasswordMin: 73, DisplayNameMax: 100},
+		"display name past column": {UsernameMin: 3, UsernameMax: 50, PasswordMin: 8, DisplayNameMax: 101},
+	} {
+		assert.Error(t, limits.Check(), name)
+	}
+}
@@ -0,0 +1,106 @@
+package validation
+
+import (
+    "golang.org/x/text/language"
+)
+
+// Supported languages, in order of preference when nothing matches.
+var supportedLanguages = []language.Tag{
+    language.English,
+    language.Spanish,
+    language.French,
+}
+
+var matcher = language.NewMatcher(supportedLanguages)
+
+// Message templates keyed by language and validator tag. Templates receive
+// the validator parameter (e.g. the 8 in min=8) as their only argument.
+var messages = map[string]map[string]string{
+    "en": {
+        "required":         "is required",
+        "required_if":      "is required",
+        "email":            "must be a valid email address",
+        "min":              "must be at least %s",
+        "min_string":       "must be at least %s characters long",
+        "max":              "must be at most %s",
+        "max_string":       "must be at most %s characters long",
+        "len":              "must be exactly %s",
+        "len_string":       "must be exactly %s characters long",
+        "oneof":            "must be one of: %s",
+        "uuid":             "must be a valid UUID",
+        "url":              "must be a valid URL",
+        "type":             "has the wrong type",
+        "unknown_field":    "is not a known field",
+        "invalid":          "is invalid",
+        "invalid_body":     "Invalid request body",
+        "invalid_json":     "Request body is not valid JSON",
+        "failed":           "Validation failed",
+        "strong_password":  "must contain letters and digits and not be a common password",
+        "username_charset": "may only contain letters, digits, '_', '.' and '-'",
+        "e164_phone":       "must be a phone number in international format, e.g. +14155552671",
+        "safe_url":         "must be an http or https URL",
+    },
+    "es": {
+        "required":         "es obligatorio",
+        "required_if":      "es obligatorio",
+        "email":            "debe ser una dirección de correo válida",
+        "min":              "debe ser al menos %s",
+        "min_string":       "debe tener al menos %s caracteres",
+        "max":              "debe ser como máximo %s",
+        "max_string":       "debe tener como máximo %s caracteres",
+        "len":              "debe ser exactamente %s",
+        "len_string":       "debe tener exactamente %s caracteres",
+        "oneof":            "debe ser uno de: %s",
+        "uuid":             "debe ser un UUID válido",
+        "url":              "debe ser una URL válida",
+        "type":             "tiene un tipo incorrecto",
+        "unknown_field":    "no es un campo conocido",
+        "invalid":          "no es válido",
+        "invalid_body":     "Cuerpo de la solicitud no válido",
+        "invalid_json":     "El cuerpo de la solicitud no es JSON válido",
+        "failed":           "La validación falló",
+        "strong_password":  "debe contener letras y números y no ser una contraseña común",
+        "username_charset": "solo puede contener letras, números, '_', '.' y '-'",
+        "e164_phone":       "debe ser un número de teléfono en formato internacional, p. ej. +14155552671",
+        "safe_url":         "debe ser una URL http o https",
+    },
+    "fr": {
+        "required":         "est obligatoire",
+        "required_if":      "est obligatoire",
+        "email":            "doit être une adresse e-mail valide",
+        "min":              "doit être au moins %s",
+        "min_string":       "doit contenir au moins %s caractères",
+        "max":              "doit être au plus %s",
+        "max_string":       "doit contenir au plus %s caractères",
+        "len":              "doit être exactement %s",
+        "len_string":       "doit contenir exactement %s caractères",
+        "oneof":            "doit être l'une des valeurs : %s",
+        "uuid":             "doit être un UUID valide",
+        "url":              "doit être une URL valide",
+        "type":             "a un type incorrect",
+        "unknown_field":    "n'est pas un champ connu",
+        "invalid":          "n'est pas valide",
+        "invalid_body":     "Corps de la requête invalide",
+        "invalid_json":     "Le corps de la requête n'est pas un JSON valide",
+        "failed":           "La validation a échoué",
+        "strong_password":  "doit contenir des lettres et des chiffres et ne pas être un mot de passe courant",
+        "username_charset": "ne peut contenir que des lettres, des chiffres, '_', '.' et '-'",
+        "e164_phone":       "doit être un numéro de téléphone au format international, ex. +14155552671",
+        "safe_url":         "doit être une URL http ou https",
+    },
+}
+
+// languageFor picks the best supported language for an Accept-Language value.
+func languageFor(acceptLanguage string) string {
+    tags, _, _ := language.ParseAcceptLanguage(acceptLanguage)
+    _, index, _ := matcher.Match(tags...)
+    base, _ := supportedLanguages[index].Base()
+    return base.String()
+}
+
+func lookup(lang, key string) string {
+    if msg, ok := messages[lang][key]; ok {
+        return msg
+    }
+    return messages["en"][key]
+}
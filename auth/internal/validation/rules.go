@@ -0,0 +1,110 @@
+package validation
+
+import (
+    "net/url"
+    "regexp"
+    "strings"
+    "unicode"
+
+    "github.com/go-playground/validator/v10"
+)
+
+var (
+    usernamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+    e164Pattern     = regexp.MustCompile(`^\+[1-9][0-9]{1,14}$`)
+)
+
+// Passwords rejected outright regardless of composition.
+var commonPasswords = map[string]bool{
+    "password1": true,
+    "passw0rd":  true,
+    "qwerty123": true,
+    "abc12345":  true,
+    "letmein1":  true,
+    "welcome1":  true,
+    "iloveyou1": true,
+    "admin1234": true,
+    "12345678a": true,
+    "a12345678": true,
+}
+
+var customValidators = map[string]validator.Func{
+    "strong_password":  validateStrongPassword,
+    "username_charset": validateUsernameCharset,
+    "e164_phone":       validateE164Phone,
+    "safe_url":         validateSafeURL,
+}
+
+func registerCustomValidators(v *validator.Validate) error {
+    for tag, fn := range customValidators {
+        if err := v.RegisterValidation(tag, fn); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// IsStrongPassword requires at least one letter and one digit, no whitespace,
+// more than two distinct characters and not being a well-known password.
+// Length is enforced separately with min=.
+func IsStrongPassword(password string) bool {
+    if commonPasswords[strings.ToLower(password)] {
+        return false
+    }
+
+    var hasLetter, hasDigit bool
+    distinct := make(map[rune]bool)
+    for _, r := range password {
+        switch {
+        case unicode.IsSpace(r):
+            return false
+        case unicode.IsLetter(r):
+            hasLetter = true
+        case unicode.IsDigit(r):
+            hasDigit = true
+        }
+        distinct[r] = true
+    }
+
+    return hasLetter && hasDigit && len(distinct) > 2
+}
+
+// IsUsernameCharset allows ASCII letters, digits, '_', '.' and '-', starting
+// with a letter or digit.
+func IsUsernameCharset(username string) bool {
+    return usernamePattern.MatchString(username)
+}
+
+// IsE164Phone accepts phone numbers in E.164 format, e.g. +14155552671.
+func IsE164Phone(phone string) bool {
+    return e164Pattern.MatchString(phone)
+}
+
+// IsSafeURL accepts absolute http(s) URLs with a host and no embedded
+// credentials, ruling out javascript:, data: and similar schemes.
+func IsSafeURL(raw string) bool {
+    u, err := url.Parse(raw)
+    if err != nil {
+        return false
+    }
+    if u.Scheme != "http" && u.Scheme != "https" {
+        return false
+    }
+    return u.Host != "" && u.User == nil
+}
+
+func validateStrongPassword(fl validator.FieldLevel) bool {
+    return IsStrongPassword(fl.Field().String())
+}
+
+func validateUsernameCharset(fl validator.FieldLevel) bool {
+    return IsUsernameCharset(fl.Field().String())
+}
+
+func validateE164Phone(fl validator.FieldLevel) bool {
+    return IsE164Phone(fl.Field().String())
+}
+
+func validateSafeURL(fl validator.FieldLevel) bool {
+    return IsSafeURL(fl.Field().String())
+}
@@ -0,0 +1,207 @@
+package validation
+
+import (
+    "bytes"
+    "encoding"
+    "encoding/json"
+    "fmt"
+    "reflect"
+    "sort"
+    "strings"
+)
+
+var (
+    jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+    textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// SchemaProblem is one place where a document doesn't match its schema. Code
+// is "unknown_field" or "type".
+type SchemaProblem struct {
+    Field string
+    Code  string
+}
+
+// SchemaError lists every problem found in a document, sorted by field.
+type SchemaError struct {
+    Problems []SchemaProblem
+}
+
+func (e *SchemaError) Error() string {
+    parts := make([]string, 0, len(e.Problems))
+    for _, problem := range e.Problems {
+        parts = append(parts, problem.Field+": "+problem.Code)
+    }
+    return "schema mismatch: " + strings.Join(parts, ", ")
+}
+
+// CheckSchema checks a JSON document against the schema of model, i.e. the
+// shape encoding/json would decode into model's type: objects may only have
+// the type's fields and every value must have the field's JSON type. It
+// returns a *SchemaError for mismatches and the decoding error for invalid
+// JSON. Constraints such as required or max are left to the binding tags.
+func CheckSchema(body []byte, model interface{}) error {
+    decoder := json.NewDecoder(bytes.NewReader(body))
+    decoder.UseNumber()
+
+    var doc interface{}
+    if err := decoder.Decode(&doc); err != nil {
+        return err
+    }
+
+    checker := &schemaChecker{}
+    checker.check("", doc, reflect.TypeOf(model))
+    if len(checker.problems) > 0 {
+        return &SchemaError{Problems: checker.problems}
+    }
+    return nil
+}
+
+type schemaChecker struct {
+    problems []SchemaProblem
+}
+
+func (s *schemaChecker) fail(path, code string) {
+    s.problems = append(s.problems, SchemaProblem{Field: path, Code: code})
+}
+
+func (s *schemaChecker) check(path string, value interface{}, t reflect.Type) {
+    if value == nil {
+        switch t.Kind() {
+        case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+        default:
+            s.fail(path, "type")
+        }
+        return
+    }
+    for t.Kind() == reflect.Ptr {
+        t = t.Elem()
+    }
+
+    // Types with their own decoding, like time.Time and uuid.UUID, are
+    // checked by decoding the value
+    if reflect.PointerTo(t).Implements(jsonUnmarshalerType) || reflect.PointerTo(t).Implements(textUnmarshalerType) {
+        raw, err := json.Marshal(value)
+        if err == nil {
+            err = json.Unmarshal(raw, reflect.New(t).Interface())
+        }
+        if err != nil {
+            s.fail(path, "type")
+        }
+        return
+    }
+
+    switch t.Kind() {
+    case reflect.Interface:
+    case reflect.String:
+        if _, ok := value.(string); !ok {
+            s.fail(path, "type")
+        }
+    case reflect.Bool:
+        if _, ok := value.(bool); !ok {
+            s.fail(path, "type")
+        }
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+        reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+        reflect.Float32, reflect.Float64:
+        number, ok := value.(json.Number)
+        if !ok || json.Unmarshal([]byte(number), reflect.New(t).Interface()) != nil {
+            s.fail(path, "type")
+        }
+    case reflect.Slice, reflect.Array:
+        if t.Elem().Kind() == reflect.Uint8 {
+            // Byte slices are base64 strings
+            if _, ok := value.(string); !ok {
+                s.fail(path, "type")
+            }
+            return
+        }
+        items, ok := value.([]interface{})
+        if !ok {
+            s.fail(path, "type")
+            return
+        }
+        for i, item := range items {
+            s.check(fmt.Sprintf("%s[%d]", path, i), item, t.Elem())
+        }
+    case reflect.Map:
+        object, ok := value.(map[string]interface{})
+        if !ok {
+            s.fail(path, "type")
+            return
+        }
+        for _, key := range sortedKeys(object) {
+            s.check(joinPath(path, key), object[key], t.Elem())
+        }
+    case reflect.Struct:
+        object, ok := value.(map[string]interface{})
+        if !ok {
+            s.fail(path, "type")
+            return
+        }
+        fields := jsonFields(t)
+        for _, key := range sortedKeys(object) {
+            field, ok := fields[key]
+            if !ok {
+                s.fail(joinPath(path, key), "unknown_field")
+                continue
+            }
+            s.check(joinPath(path, key), object[key], field)
+        }
+    default:
+        s.fail(path, "type")
+    }
+}
+
+// jsonFields maps the JSON names of a struct's fields, including those
+// promoted from embedded structs, to their types.
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+    fields := make(map[string]reflect.Type)
+    for i := 0; i < t.NumField(); i++ {
+        field := t.Field(i)
+        tag := field.Tag.Get("json")
+        if tag == "-" {
+            continue
+        }
+        name, _, _ := strings.Cut(tag, ",")
+
+        if field.Anonymous && name == "" {
+            embedded := field.Type
+            if embedded.Kind() == reflect.Ptr {
+                embedded = embedded.Elem()
+            }
+            if embedded.Kind() == reflect.Struct {
+                for key, value := range jsonFields(embedded) {
+                    if _, ok := fields[key]; !ok {
+                        fields[key] = value
+                    }
+                }
+                continue
+            }
+        }
+        if !field.IsExported() {
+            continue
+        }
+        if name == "" {
+            name = field.Name
+        }
+        fields[name] = field.Type
+    }
+    return fields
+}
+
+func sortedKeys(object map[string]interface{}) []string {
+    keys := make([]string, 0, len(object))
+    for key := range object {
+        keys = append(keys, key)
+    }
+    sort.Strings(keys)
+    return keys
+}
+
+func joinPath(path, key string) string {
+    if path == "" {
+        return key
+    }
+    return path + "." + key
+}
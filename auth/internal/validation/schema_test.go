@@ -0,0 +1,65 @@
+package validation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type schemaDevice struct {
+	UserAgent string `json:"user_agent"`
+}
+
+type schemaBase struct {
+	ID uuid.UUID `json:"id"`
+}
+
+type schemaModel struct {
+	schemaBase
+	Name     string            `json:"name"`
+	Count    int               `json:"count,omitempty"`
+	Label    *string           `json:"label"`
+	At       time.Time         `json:"at"`
+	Devices  []schemaDevice    `json:"devices"`
+	Tags     map[string]string `json:"tags"`
+	Internal string            `json:"-"`
+}
+
+func TestCheckSchema_Valid(t *testing.T) {
+	body := `{"id":"` + uuid.NewString() + `","name":"a","count":2,"label":null,
+		"at":"2026-01-02T03:04:05Z","devices":[{"user_agent":"ua"}],"tags":{"k":"v"}}`
+	assert.NoError(t, CheckSchema([]byte(body), schemaModel{}))
+}
+
+func TestCheckSchema_Problems(t *testing.T) {
+	body := `{"id":"nope","name":1,"count":1.5,"at":"yesterday","devices":[{"user_agent":"ua","os":"x"}],
+		"tags":{"k":2},"Internal":"x"}`
+
+	err := CheckSchema([]byte(body), schemaModel{})
+	var schemaErr *SchemaError
+	require.ErrorAs(t, err, &schemaErr)
+	assert.Equal(t, []SchemaProblem{
+		{Field: "Internal", Code: "unknown_field"},
+		{Field: "at", Code: "type"},
+		{Field: "count", Code: "type"},
+		{Field: "devices[0].os", Code: "unknown_field"},
+		{Field: "id", Code: "type"},
+		{Field: "name", Code: "type"},
+		{Field: "tags.k", Code: "type"},
+	}, schemaErr.Problems)
+
+	message, fields := Translate(err, "fr")
+	assert.Equal(t, "La validation a échoué", message)
+	assert.Equal(t, FieldError{Field: "Internal", Code: "unknown_field", Message: "n'est pas un champ connu"}, fields[0])
+}
+
+func TestCheckSchema_InvalidJSON(t *testing.T) {
+	err := CheckSchema([]byte(`{"name":`), schemaModel{})
+	require.Error(t, err)
+	message, fields := Translate(err, "")
+	assert.Equal(t, "Request body is not valid JSON", message)
+	assert.Empty(t, fields)
+}
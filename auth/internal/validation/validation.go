@@ -0,0 +1,40 @@
+package validation
+
+import (
+    "reflect"
+    "strings"
+
+    "github.com/gin-gonic/gin/binding"
+    "github.com/go-playground/validator/v10"
+)
+
+// Setup configures gin's binding engine with limits. It must be called once
+// at startup, before any request is bound.
+func Setup(limits Limits) error {
+    if err := limits.Check(); err != nil {
+        return err
+    }
+    activeLimits = limits
+
+    v, ok := binding.Validator.Engine().(*validator.Validate)
+    if !ok {
+        return nil
+    }
+    for alias, tags := range limits.aliases() {
+        v.RegisterAlias(alias, tags)
+    }
+
+    // Report fields by their JSON name so error keys match the request body
+    v.RegisterTagNameFunc(func(field reflect.StructField) string {
+        name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+        if name == "-" {
+            return ""
+        }
+        if name == "" {
+            return field.Name
+        }
+        return name
+    })
+
+    return registerCustomValidators(v)
+}
@@ -2,39 +2,130 @@ package main
 
 import (
     "context"
+    "crypto/tls"
     "fmt"
     "net/http"
+    "net/http/pprof"
     "os"
     "os/signal"
     "syscall"
     "time"
 
+    "auth-service/internal/chaos"
+    "auth-service/internal/clock"
     "auth-service/internal/config"
     "auth-service/internal/database"
+    "auth-service/internal/events"
     "auth-service/internal/handlers"
+    "auth-service/internal/logging"
+    "auth-service/internal/metrics"
     "auth-service/internal/middleware"
+    "auth-service/internal/models"
     "auth-service/internal/rabbitmq"
     "auth-service/internal/redis"
+    "auth-service/internal/response"
+    "auth-service/internal/secrets"
     "auth-service/internal/services"
+    "auth-service/internal/store"
+    "auth-service/internal/validation"
 
     "github.com/gin-gonic/gin"
     "go.uber.org/zap"
 )
 
+// How often each instance reloads the shared IP ban list from Redis
+const ipBanRefreshInterval = 10 * time.Second
+
+// How often each instance reloads the rate limit policy from Redis
+const rateLimitPolicyRefreshInterval = 10 * time.Second
+
+// How often expired rows are purged from the Postgres token store
+const tokenStoreCleanupInterval = 10 * time.Minute
+
+// How often Redis is pinged to enter or leave degraded mode
+const redisHealthCheckInterval = 5 * time.Second
+
+// How often a lost RabbitMQ connection is retried, and the chat event
+// consumer restarted
+const rabbitMQReconnectInterval = 5 * time.Second
+
+// How often Postgres and Redis latencies are probed for load shedding
+const latencyProbeInterval = 5 * time.Second
+
+// How often each region deletes its own expired sessions
+const sessionCleanupInterval = time.Hour
+
+// How often buffered logins are written to last_login and login_count
+const loginStatsFlushInterval = 10 * time.Second
+
+// How often, and how many at a time, queued emails are sent
+const (
+    emailOutboxInterval  = 10 * time.Second
+    emailOutboxBatchSize = 50
+)
+
+// How often, and how many at a time, events diverted to the outbox are relayed
+const (
+    eventOutboxRelayInterval  = 5 * time.Second
+    eventOutboxRelayBatchSize = 100
+)
+
+// How often journaled events past their retention are deleted
+const eventJournalCleanupInterval = time.Hour
+
+// How often, and how many at a time, flagged or unchecked usernames and
+// display names are moderated again
+const (
+    moderationRecheckInterval  = time.Minute
+    moderationRecheckBatchSize = 50
+)
+
+// Chat events consumed to track onboarding
+const (
+    chatEventsExchange   = "chat_events"
+    onboardingEventQueue = "auth_onboarding"
+)
+
 func main() {
-    // Initialize logger
-    logger, _ := zap.NewProduction()
-    defer logger.Sync()
-    sugar := logger.Sugar()
+    // Maintenance subcommands run instead of the server
+    if len(os.Args) > 1 {
+        if err := runCommand(os.Args[1:]); err != nil {
+            fmt.Fprintf(os.Stderr, "%v\n", err)
+            os.Exit(1)
+        }
+        return
+    }
 
     // Load configuration
     cfg, err := config.Load()
     if err != nil {
-        sugar.Fatalf("Failed to load config: %v", err)
+        fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+        os.Exit(1)
+    }
+
+    // Initialize logger for the environment's profile
+    logger, err := logging.New(cfg.Profile)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Failed to create logger: %v\n", err)
+        os.Exit(1)
+    }
+    defer logger.Sync()
+    sugar := logger.Sugar()
+    gin.SetMode(cfg.Profile.GinMode)
+
+    // Register validation rules on the binding engine
+    if err := validation.Setup(cfg.ValidationLimits); err != nil {
+        sugar.Fatalf("Failed to set up validation: %v", err)
     }
 
-    // Initialize database
-    db, err := database.New(cfg.DatabaseURL)
+    // Initialize database. With CHAOS_RULES, Redis and Postgres calls fail
+    // or slow down as the rule for the request's route says.
+    var dbFault func(context.Context) bool
+    if len(cfg.ChaosRules) > 0 {
+        sugar.Warnf("Fault injection is on with %d rules", len(cfg.ChaosRules))
+        dbFault = chaos.DB
+    }
+    db, err := database.NewWithFaults(cfg.DatabaseURL, dbFault)
     if err != nil {
         sugar.Fatalf("Failed to connect to database: %v", err)
     }
@@ -45,43 +136,354 @@ func main() {
         sugar.Fatalf("Failed to run migrations: %v", err)
     }
 
-    // Initialize Redis
+    // Regions with databases of their own; their schema follows the primary's
+    regionDBs, err := database.NewRegions(db, cfg.DataResidency.Databases)
+    if err != nil {
+        sugar.Fatalf("Failed to connect to regional databases: %v", err)
+    }
+    defer regionDBs.Close()
+    if err := regionDBs.Migrate(); err != nil {
+        sugar.Fatalf("Failed to run regional migrations: %v", err)
+    }
+
+    // Initialize Redis. An unreachable Redis puts the service in degraded
+    // mode rather than stopping it.
     redisClient := redis.New(cfg.RedisURL)
     defer redisClient.Close()
+    if len(cfg.ChaosRules) > 0 {
+        redisClient.InjectFaults(chaos.Redis)
+    }
+    if !redisClient.Available() {
+        sugar.Warn("Redis is unavailable, starting in degraded mode")
+    }
+
+    // Blacklisted tokens and single-use nonces live in the configured store
+    var tokenStore store.TokenStore
+    var pgTokenStore store.PostgresStore
+    var fallbackTokenStore *store.FallbackStore
+    var replicaClient *redis.Client
+    switch cfg.TokenStore {
+    case "postgres":
+        pgTokenStore = store.NewPostgres(db, sugar)
+        tokenStore = pgTokenStore
+    case "memory":
+        tokenStore = store.NewMemory()
+    default:
+        tokenStore = store.NewRedis(redisClient)
+        // In multi-region deployments, check the blacklist against the
+        // region's read replica instead of the primary
+        if cfg.RedisReplicaURL != "" {
+            replicaClient = redis.New(cfg.RedisReplicaURL)
+            defer replicaClient.Close()
+            tokenStore = store.NewReplicated(tokenStore, store.NewRedis(replicaClient))
+        }
+        // Writes go to Postgres while Redis is down and are moved back once
+        // it recovers
+        pgTokenStore = store.NewPostgres(db, sugar)
+        fallbackTokenStore = store.NewFallback(tokenStore, pgTokenStore, sugar)
+        tokenStore = fallbackTokenStore
+    }
+
+    // RabbitMQ connects lazily: until it answers, events wait in the outbox.
+    // Without a broker they are only journaled.
+    var rabbitMQ *rabbitmq.Client
+    var broker services.EventPublisher = services.NoopPublisher{}
+    if cfg.EventBroker == "rabbitmq" {
+        rabbitMQ = rabbitmq.New(cfg.RabbitMQURL)
+        defer rabbitMQ.Close()
+        broker = rabbitMQ
+    } else {
+        sugar.Warn("No event broker configured, user events are only journaled")
+    }
+
+    // Cap concurrent bcrypt work so login bursts can't starve other requests
+    services.SetBcryptPool(services.NewBcryptPool(cfg.BcryptConcurrency, cfg.BcryptQueueTimeout))
+
+    // Initialize services. User events also go out to users' own webhooks.
+    webhookService := services.NewWebhookService(db, sugar)
+    personalTokens := services.NewPersonalTokenService(db, sugar)
+    // Events are buffered, and diverted to the outbox when RabbitMQ is slow,
+    // so publishing never blocks a request
+    eventPublisher := services.NewBufferedPublisher(broker, db, cfg.EventBufferSize, sugar)
+    eventJournal := services.NewEventJournal(eventPublisher, db, sugar)
+    publisher := services.NewWebhookPublisher(eventJournal, webhookService)
+    authService := services.NewAuthService(db, redisClient, cfg, sugar, publisher)
+    authService.SetCaptchaVerifier(services.NewCaptchaVerifier(cfg.CaptchaVerifyURL, cfg.CaptchaSecret))
+    userService := services.NewUserService(db, redisClient, sugar)
+    userService.SetRequiredProfileFields(cfg.ProfileRequiredFields)
+    signingKeys, err := loadSigningKeys(cfg)
+    if err != nil {
+        sugar.Fatalf("Failed to load JWT signing key: %v", err)
+    }
+    if !signingKeys.Locked() {
+        sugar.Warn("JWT signing key memory could not be locked and may be swapped to disk")
+    }
+    if cfg.JWTSecretFile == "" && cfg.Profile.Name == "production" {
+        sugar.Warn("JWT secret is set inline; use JWT_SECRET_FILE so it can be rotated without a restart")
+    }
+    tokenService := services.NewTokenService(signingKeys, cfg.JWTExpiry, tokenStore, sugar)
+    handleService := services.NewHandleService(db, sugar)
+    apiKeyService := services.NewAPIKeyService(db, redisClient, sugar)
+    requestVerifier := services.NewRequestVerifier(cfg.SigningKeys, redisClient)
+    recoveryService := services.NewRecoveryService(db, redisClient, cfg, sugar)
+    adminAuditService := services.NewAdminAuditService(db, sugar)
+    accountNotes := services.NewAccountNoteService(db, cfg.RestrictedNotesAdmins, sugar)
+    funnelService := services.NewFunnelService(db, sugar)
+    loginFailureService := services.NewLoginFailureService(db, sugar)
+    authService.SetLoginFailures(loginFailureService)
+    loginStats := services.NewLoginStatsService(db, redisClient, sugar)
+    authService.SetLoginStats(loginStats)
+    ipBanService := services.NewIPBanService(redisClient, sugar)
+    rateLimitPolicy := services.NewRateLimitPolicyService(redisClient, sugar)
+    refreshGuard := services.NewRefreshGuard(redisClient, ipBanService, sugar)
+    loginGuard := services.NewLoginGuard(redisClient, sugar)
+
+    var geoIP *services.GeoIPDatabase
+    if cfg.GeoIPDatabase != "" {
+        geoIP, err = services.LoadGeoIPDatabase(cfg.GeoIPDatabase)
+        if err != nil {
+            sugar.Fatalf("Failed to load GeoIP database: %v", err)
+        }
+    }
+    geoBlockService := services.NewGeoBlockService(db, cfg.GeoBlockedCountries, cfg.GeoIPHeader, geoIP, sugar)
+
+    var certificate *secrets.Certificate
+    if cfg.TLSCertFile != "" {
+        certificate, err = secrets.LoadCertificate(cfg.TLSCertFile, cfg.TLSKeyFile)
+        if err != nil {
+            sugar.Fatalf("Failed to load TLS certificate: %v", err)
+        }
+    }
+
+    // Everything loaded from a file can be rotated without a restart, on
+    // SIGHUP or through the admin API
+    reloader := services.NewReloader(sugar)
+    if cfg.JWTSecretFile != "" {
+        reloader.Register(services.ReloadSigningKey, func() (bool, string, error) {
+            rotated, err := signingKeys.Reload()
+            return rotated, signingKeys.Fingerprint(), err
+        })
+    }
+    if certificate != nil {
+        reloader.Register(services.ReloadTLS, func() (bool, string, error) {
+            changed, err := certificate.Reload()
+            return changed, certificate.Fingerprint(), err
+        })
+    }
+    if cfg.GeoIPDatabase != "" {
+        reloader.Register(services.ReloadGeoIP, func() (bool, string, error) {
+            changed, err := geoBlockService.ReloadGeoIP(cfg.GeoIPDatabase)
+            return changed, geoBlockService.GeoIPFingerprint(), err
+        })
+    }
+    tokenLineageService := services.NewTokenLineageService(db, sugar)
+    accountDeletionService := services.NewAccountDeletionService(db, userService, webhookService, publisher, sugar)
+
+    // Revoked sessions are announced on Redis so the gateway and chat
+    // service can disconnect their clients
+    forcedLogouts := services.NewForcedLogoutService(redisClient, sugar)
+    authService.SetForcedLogouts(forcedLogouts)
+    recoveryService.SetForcedLogouts(forcedLogouts)
+    accountDeletionService.SetForcedLogouts(forcedLogouts)
 
-    // Initialize RabbitMQ
-    rabbitMQ, err := rabbitmq.New(cfg.RabbitMQURL)
+    onboardingService := services.NewOnboardingService(db, publisher, sugar)
+    moderationService := services.NewModerationService(db, services.NewContentModerator(cfg.Moderation), userService, handleService, sugar)
+    moderationService.SetImpersonationGuard(services.NewImpersonationGuard(db, cfg.Moderation.ProtectedHandles, sugar))
+    authService.SetModeration(moderationService)
+    userService.SetModeration(moderationService)
+
+    // High-severity security events go to the security team's Slack
+    opsAlerts, err := services.NewOpsAlertService(cfg.Alerting, redisClient, sugar)
     if err != nil {
-        sugar.Fatalf("Failed to connect to RabbitMQ: %v", err)
+        sugar.Fatalf("Failed to set up ops alerts: %v", err)
     }
-    defer rabbitMQ.Close()
+    moderationService.SetAlerts(opsAlerts)
+    loginFailureService.SetAlerts(opsAlerts)
+    authService.SetAlerts(opsAlerts)
 
-    // Initialize services
-    authService := services.NewAuthService(db, redisClient, cfg, sugar, rabbitMQ)
-    userService := services.NewUserService(db, sugar)
-    tokenService := services.NewTokenService(cfg.JWTSecret, cfg.JWTExpiry, redisClient, sugar)
+    // Emails go to the SMTP relay, or into sent_emails in sandbox mode
+    var sandboxMailer *services.SandboxMailer
+    mailer := services.NewSMTPMailer(cfg)
+    if cfg.EmailSandbox {
+        sandboxMailer = services.NewSandboxMailer(db)
+        mailer = sandboxMailer
+        sugar.Warn("Email sandbox is on; emails are stored in sent_emails instead of being sent")
+    }
+    authService.SetMailer(mailer)
+    recoveryService.SetMailer(mailer)
+    onboardingService.SetMailer(mailer)
+
+    // In test mode tokens, sessions and reset links expire on a clock
+    // end-to-end tests can move forward
+    var testClock *clock.Travel
+    if cfg.TestMode {
+        testClock = clock.NewTravel()
+        tokenService.SetClock(testClock.Now)
+        authService.SetClock(testClock.Now)
+        sugar.Warn("Test mode is on; the clock can be moved forward through /admin/test/clock")
+    }
+
+    // Roll funnel events up into daily stats in the background
+    jobsCtx, stopJobs := context.WithCancel(context.Background())
+    defer stopJobs()
+    go funnelService.RunAggregation(jobsCtx, cfg.FunnelAggregation)
+    go ipBanService.RunRefresh(jobsCtx, ipBanRefreshInterval)
+    go rateLimitPolicy.RunRefresh(jobsCtx, rateLimitPolicyRefreshInterval)
+    go authService.RunSessionCleanup(jobsCtx, sessionCleanupInterval)
+    go loginStats.RunFlush(jobsCtx, loginStatsFlushInterval)
+    go accountDeletionService.RunWorker(jobsCtx, cfg.DeletionInterval, cfg.DeletionBatchSize)
+    go onboardingService.RunMailer(jobsCtx, emailOutboxInterval, emailOutboxBatchSize)
+    integrityChecker := services.NewIntegrityChecker(db, cfg.IntegrityAutoRepair, sugar)
+    if cfg.IntegrityCheckInterval > 0 {
+        go integrityChecker.Run(jobsCtx, cfg.IntegrityCheckInterval)
+    }
+    publisherDone := make(chan struct{})
+    go func() {
+        eventPublisher.Run(jobsCtx)
+        close(publisherDone)
+    }()
+    go eventPublisher.RunRelay(jobsCtx, eventOutboxRelayInterval, eventOutboxRelayBatchSize)
+    go eventJournal.RunCleanup(jobsCtx, eventJournalCleanupInterval, cfg.EventJournalRetention)
+    go moderationService.RunRechecks(jobsCtx, moderationRecheckInterval, moderationRecheckBatchSize)
+    if rabbitMQ != nil {
+        go rabbitMQ.Monitor(jobsCtx, rabbitMQReconnectInterval, func(available bool) {
+            if available {
+                metrics.RabbitMQAvailable.Set(1)
+                sugar.Info("RabbitMQ is available")
+                return
+            }
+            metrics.RabbitMQAvailable.Set(0)
+            sugar.Warn("RabbitMQ is unavailable, holding user events in the outbox")
+        })
+        go func() {
+            for {
+                err := rabbitMQ.Subscribe(jobsCtx, chatEventsExchange, onboardingEventQueue, []string{string(events.ChatJoined)}, onboardingService.HandleChatEvent)
+                if err != nil && err != rabbitmq.ErrUnavailable {
+                    sugar.Errorf("Stopped consuming chat events: %v", err)
+                }
+                select {
+                case <-jobsCtx.Done():
+                    return
+                case <-time.After(rabbitMQReconnectInterval):
+                }
+            }
+        }()
+    }
+    if pgTokenStore != nil {
+        go pgTokenStore.RunCleanup(jobsCtx, tokenStoreCleanupInterval)
+    }
+    go redisClient.Monitor(jobsCtx, redisHealthCheckInterval, func(available bool) {
+        if available {
+            metrics.RedisAvailable.Set(1)
+            sugar.Info("Redis is available")
+            if fallbackTokenStore != nil {
+                fallbackTokenStore.Recover(jobsCtx)
+            }
+            return
+        }
+        metrics.RedisAvailable.Set(0)
+        sugar.Warn("Redis is unavailable, running in degraded mode")
+    })
+    if replicaClient != nil {
+        go replicaClient.Monitor(jobsCtx, redisHealthCheckInterval, nil)
+    }
+    loadShedder := services.NewLoadShedder(cfg.LoadShedding, sugar)
+    if cfg.LoadShedding.MaxLatency > 0 {
+        go loadShedder.RunProbes(jobsCtx, latencyProbeInterval, map[string]services.Probe{
+            "postgres": db.Pool().Ping,
+            "redis":    redisClient.Ping,
+        })
+    }
 
     // Initialize handlers
     authHandler := handlers.NewAuthHandler(authService, userService, tokenService, sugar)
-    userHandler := handlers.NewUserHandler(userService, sugar)
+    authHandler.SetHandles(handleService)
+    authHandler.SetFunnel(funnelService)
+    authHandler.SetOnboarding(onboardingService)
+    authHandler.SetLoginGuard(loginGuard)
+    authHandler.SetRefreshGuard(refreshGuard)
+    authHandler.SetGeoBlock(geoBlockService)
+    userHandler := handlers.NewUserHandler(userService, accountDeletionService, onboardingService, sugar)
+    userHandler.SetGoneUserResponse(cfg.GoneUserStatus, tokenService, publisher)
+    adminHandler := handlers.NewAdminHandler(apiKeyService, adminAuditService, funnelService, loginFailureService, loginStats, ipBanService, rateLimitPolicy, tokenLineageService, geoBlockService, userService, authService, sugar)
+    recoveryHandler := handlers.NewRecoveryHandler(recoveryService, adminAuditService, sugar)
+    webhookHandler := handlers.NewWebhookHandler(webhookService, sugar)
+    sessionHandler := handlers.NewSessionHandler(authService, sugar)
+    personalTokenHandler := handlers.NewPersonalTokenHandler(personalTokens, sugar)
+    eventHandler := handlers.NewEventHandler(eventJournal, sugar)
+    forcedLogoutHandler := handlers.NewForcedLogoutHandler(forcedLogouts, sugar)
+    moderationHandler := handlers.NewModerationHandler(moderationService, adminAuditService, sugar)
+    integrityHandler := handlers.NewIntegrityHandler(integrityChecker, sugar)
+    keyHandler := handlers.NewKeyHandler(signingKeys, reloader, adminAuditService, sugar)
+    accountNoteHandler := handlers.NewAccountNoteHandler(accountNotes, adminAuditService, sugar)
+    healthHandler := handlers.NewHealthHandler(db, redisClient)
+    healthHandler.SetRegions(regionDBs)
+    if rabbitMQ != nil {
+        healthHandler.SetBroker(rabbitMQ.Available)
+    }
+    var sentEmailHandler *handlers.SentEmailHandler
+    if sandboxMailer != nil {
+        sentEmailHandler = handlers.NewSentEmailHandler(sandboxMailer, adminAuditService, sugar)
+    }
+    var testClockHandler *handlers.TestClockHandler
+    if testClock != nil {
+        testClockHandler = handlers.NewTestClockHandler(testClock, adminAuditService, sugar)
+    }
 
-    // Setup router
-    router := setupRouter(cfg, authHandler, userHandler, tokenService, sugar)
+    // Setup routers
+    router := setupRouter(cfg, healthHandler, authHandler, userHandler, recoveryHandler, webhookHandler, sessionHandler, personalTokenHandler, tokenService, personalTokens, authService, userService, ipBanService, apiKeyService, requestVerifier, rateLimitPolicy, loadShedder, sugar)
+    adminRouter := setupAdminRouter(cfg, healthHandler, authHandler, userHandler, adminHandler, recoveryHandler, eventHandler, forcedLogoutHandler, moderationHandler, integrityHandler, keyHandler, accountNoteHandler, sentEmailHandler, testClockHandler, tokenService, authService, userService, apiKeyService, requestVerifier, loadShedder, sugar)
 
-    // Start server
+    // Start servers. The admin server listens on its own address so admin,
+    // internal and debug routes are never reachable through the public port.
     srv := &http.Server{
         Addr:    fmt.Sprintf(":%d", cfg.Port),
         Handler: router,
     }
+    if certificate != nil {
+        // Fetched per handshake, so a reloaded certificate applies to new
+        // connections while open ones carry on
+        srv.TLSConfig = &tls.Config{
+            MinVersion:     tls.VersionTLS12,
+            GetCertificate: certificate.GetCertificate,
+        }
+    }
+    adminSrv := &http.Server{
+        Addr:    fmt.Sprintf("%s:%d", cfg.AdminHost, cfg.AdminPort),
+        Handler: adminRouter,
+    }
+    // Forced logout streams never finish on their own
+    adminSrv.RegisterOnShutdown(forcedLogoutHandler.Close)
 
     // Graceful shutdown
     go func() {
-        if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+        var err error
+        if srv.TLSConfig != nil {
+            err = srv.ListenAndServeTLS("", "")
+        } else {
+            err = srv.ListenAndServe()
+        }
+        if err != nil && err != http.ErrServerClosed {
             sugar.Fatalf("Failed to start server: %v", err)
         }
     }()
+    go func() {
+        if err := adminSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+            sugar.Fatalf("Failed to start admin server: %v", err)
+        }
+    }()
 
-    sugar.Infof("Auth service started on port %d", cfg.Port)
+    sugar.Infof("Auth service started on port %d, admin listener on %s", cfg.Port, adminSrv.Addr)
+
+    // SIGHUP re-reads the signing key, TLS certificate and GeoIP database
+    // after a rotation
+    reload := make(chan os.Signal, 1)
+    signal.Notify(reload, syscall.SIGHUP)
+    go func() {
+        for range reload {
+            reloader.Reload()
+        }
+    }()
 
     // Wait for interrupt signal
     quit := make(chan os.Signal, 1)
@@ -96,59 +498,329 @@ func main() {
     if err := srv.Shutdown(ctx); err != nil {
         sugar.Fatalf("Server forced to shutdown: %v", err)
     }
+    if err := adminSrv.Shutdown(ctx); err != nil {
+        sugar.Fatalf("Admin server forced to shutdown: %v", err)
+    }
+
+    // Move events still in the buffer to the outbox before exiting
+    stopJobs()
+    <-publisherDone
 
     sugar.Info("Server exited")
 }
 
+// loadSigningKeys moves the JWT signing secret into a keyring, preferring the
+// secret file, and clears the inline secret from the config.
+func loadSigningKeys(cfg *config.Config) (*secrets.Keyring, error) {
+    secret := cfg.JWTSecret
+    cfg.JWTSecret = ""
+    if cfg.JWTSecretFile != "" {
+        return secrets.LoadKeyring(secrets.FileSource(cfg.JWTSecretFile))
+    }
+    return secrets.StaticKeyring(secret)
+}
+
 func setupRouter(
     cfg *config.Config,
+    healthHandler *handlers.HealthHandler,
     authHandler *handlers.AuthHandler,
     userHandler *handlers.UserHandler,
+    recoveryHandler *handlers.RecoveryHandler,
+    webhookHandler *handlers.WebhookHandler,
+    sessionHandler *handlers.SessionHandler,
+    personalTokenHandler *handlers.PersonalTokenHandler,
     tokenService *services.TokenService,
+    personalTokens *services.PersonalTokenService,
+    authService *services.AuthService,
+    userService *services.UserService,
+    ipBanService *services.IPBanService,
+    apiKeyService *services.APIKeyService,
+    requestVerifier *services.RequestVerifier,
+    rateLimitPolicy *services.RateLimitPolicyService,
+    loadShedder *services.LoadShedder,
     logger *zap.SugaredLogger,
 ) *gin.Engine {
-    if cfg.Environment == "production" {
-        gin.SetMode(gin.ReleaseMode)
-    }
-
     router := gin.New()
     router.Use(gin.Recovery())
-    router.Use(middleware.Logger(logger))
-    router.Use(middleware.CORS(cfg.AllowedOrigins))
-    router.Use(middleware.RateLimit(cfg.RateLimit))
+    router.Use(middleware.Logger(logger, cfg.Profile.RequestLog))
+    router.Use(middleware.InFlight(loadShedder))
+    if len(cfg.ChaosRules) > 0 {
+        router.Use(middleware.Chaos(cfg.ChaosRules))
+    }
+    router.Use(middleware.IPBan(ipBanService))
+    router.Use(middleware.CORS(cfg.CORSPolicies[config.CORSGroupPublic]))
+    // Services calling public routes identify themselves so the rate limit
+    // policy can tell them apart
+    router.Use(middleware.SignedRequest(requestVerifier, middleware.OptionalAPIKey(apiKeyService)))
+    router.Use(middleware.RateLimit(cfg.RateLimit, rateLimitPolicy))
+    if cfg.SchemaValidation {
+        router.Use(middleware.Schema(handlers.Schemas, cfg.SchemaValidateResponses, logger))
+    }
 
     // Health check
     router.GET("/health", func(c *gin.Context) {
         c.JSON(http.StatusOK, gin.H{"status": "healthy"})
     })
+    router.GET("/readyz", healthHandler.Ready)
+    router.GET("/version", healthHandler.Version)
 
-    // Public routes
+    // Public routes. v1 and v2 share the same handlers; v2 differs only in
+    // its enveloped response shape, so both stay in sync during migration.
     v1 := router.Group("/api/v1")
+    v1.Use(middleware.APIVersion(response.V1), middleware.Deprecated(response.V1, response.V2, cfg.V1Sunset))
+    limits := middleware.NewRateLimitRules(cfg.RateLimitRules, rateLimitPolicy, userService, logger)
+    freshEmail := middleware.RequireFreshEmail(userService, cfg.EmailReverifyMonths, authService.Now)
+    sudo := middleware.RequireSudo(authService)
+    shed := middleware.Shed(loadShedder)
+    registerAPIRoutes(v1, authHandler, userHandler, recoveryHandler, webhookHandler, sessionHandler, personalTokenHandler, tokenService, personalTokens, limits, freshEmail, sudo, shed)
+
+    v2 := router.Group("/api/v2")
+    v2.Use(middleware.APIVersion(response.V2))
+    registerAPIRoutes(v2, authHandler, userHandler, recoveryHandler, webhookHandler, sessionHandler, personalTokenHandler, tokenService, personalTokens, limits, freshEmail, sudo, shed)
+
+    return router
+}
+
+// setupAdminRouter serves everything that must stay on the cluster network:
+// metrics, pprof, the admin API and service-to-service routes.
+func setupAdminRouter(
+    cfg *config.Config,
+    healthHandler *handlers.HealthHandler,
+    authHandler *handlers.AuthHandler,
+    userHandler *handlers.UserHandler,
+    adminHandler *handlers.AdminHandler,
+    recoveryHandler *handlers.RecoveryHandler,
+    eventHandler *handlers.EventHandler,
+    forcedLogoutHandler *handlers.ForcedLogoutHandler,
+    moderationHandler *handlers.ModerationHandler,
+    integrityHandler *handlers.IntegrityHandler,
+    keyHandler *handlers.KeyHandler,
+    accountNoteHandler *handlers.AccountNoteHandler,
+    sentEmailHandler *handlers.SentEmailHandler,
+    testClockHandler *handlers.TestClockHandler,
+    tokenService *services.TokenService,
+    authService *services.AuthService,
+    userService *services.UserService,
+    apiKeyService *services.APIKeyService,
+    requestVerifier *services.RequestVerifier,
+    loadShedder *services.LoadShedder,
+    logger *zap.SugaredLogger,
+) *gin.Engine {
+    router := gin.New()
+    router.Use(gin.Recovery())
+    router.Use(middleware.Logger(logger, cfg.Profile.RequestLog))
+    router.Use(middleware.InFlight(loadShedder))
+    if len(cfg.ChaosRules) > 0 {
+        router.Use(middleware.Chaos(cfg.ChaosRules))
+    }
+    router.Use(middleware.CORSRoutes([]middleware.CORSRoute{
+        {Prefix: "/api/v1/admin", Policy: cfg.CORSPolicies[config.CORSGroupAdmin]},
+        {Prefix: "/api/v2/admin", Policy: cfg.CORSPolicies[config.CORSGroupAdmin]},
+        {Prefix: "/internal/", Policy: cfg.CORSPolicies[config.CORSGroupInternal]},
+    }))
+    if cfg.SchemaValidation {
+        router.Use(middleware.Schema(handlers.Schemas, cfg.SchemaValidateResponses, logger))
+    }
+
+    router.GET("/health", func(c *gin.Context) {
+        c.JSON(http.StatusOK, gin.H{"status": "healthy"})
+    })
+    router.GET("/readyz", healthHandler.Ready)
+    router.GET("/version", healthHandler.Version)
+
+    // Prometheus / OpenMetrics scrape endpoint
+    router.GET("/metrics", gin.WrapH(metrics.Handler()))
+
+    // Go runtime profiling
+    debug := router.Group("/debug/pprof")
     {
-        auth := v1.Group("/auth")
-        {
-            auth.GET("/health", func(c *gin.Context) {
-                c.JSON(http.StatusOK, gin.H{"status": "healthy"})
-            })
-            auth.POST("/register", authHandler.Register)
-            auth.POST("/login", authHandler.Login)
-            auth.POST("/refresh", authHandler.RefreshToken)
-            auth.POST("/logout", middleware.Auth(tokenService), authHandler.Logout)
-            auth.POST("/verify-email", authHandler.VerifyEmail)
-            auth.POST("/forgot-password", authHandler.ForgotPassword)
-            auth.POST("/reset-password", authHandler.ResetPassword)
-        }
+        debug.GET("/", gin.WrapF(pprof.Index))
+        debug.GET("/cmdline", gin.WrapF(pprof.Cmdline))
+        debug.GET("/profile", gin.WrapF(pprof.Profile))
+        debug.GET("/symbol", gin.WrapF(pprof.Symbol))
+        debug.POST("/symbol", gin.WrapF(pprof.Symbol))
+        debug.GET("/trace", gin.WrapF(pprof.Trace))
+        debug.GET("/:profile", gin.WrapF(pprof.Index))
+    }
 
-        // Protected routes
-        users := v1.Group("/users")
-        users.Use(middleware.Auth(tokenService))
-        {
-            users.GET("/me", userHandler.GetCurrentUser)
-            users.PUT("/me", userHandler.UpdateProfile)
-            users.PUT("/me/password", userHandler.ChangePassword)
-            users.DELETE("/me", userHandler.DeleteAccount)
-        }
+    v1 := router.Group("/api/v1")
+    v1.Use(middleware.APIVersion(response.V1))
+    sudo := middleware.RequireSudo(authService)
+    registerAdminRoutes(v1, adminHandler, recoveryHandler, moderationHandler, integrityHandler, keyHandler, accountNoteHandler, sentEmailHandler, testClockHandler, tokenService, userService, sudo)
+
+    v2 := router.Group("/api/v2")
+    v2.Use(middleware.APIVersion(response.V2))
+    registerAdminRoutes(v2, adminHandler, recoveryHandler, moderationHandler, integrityHandler, keyHandler, accountNoteHandler, sentEmailHandler, testClockHandler, tokenService, userService, sudo)
+
+    // Internal service-to-service routes, authenticated by request signature
+    // or API key. User lookups are shed under load like profile reads.
+    internal := router.Group("/internal")
+    internal.Use(middleware.SignedRequest(requestVerifier, middleware.APIKey(apiKeyService)))
+    shed := middleware.Shed(loadShedder)
+    {
+        internal.GET("/users/resolve", shed, userHandler.ResolveUsernames)
+        internal.GET("/users/:id", shed, userHandler.GetUser)
+        internal.POST("/users/batch", shed, userHandler.GetUsers)
+        internal.POST("/users/snapshots", shed, userHandler.RepublishSnapshots)
+        internal.POST("/tokens/scheduled", authHandler.IssueScheduledToken)
+        internal.POST("/email-bounces", userHandler.ReportEmailBounce)
+        internal.GET("/events", eventHandler.ListEvents)
+        internal.GET("/forced-logouts", forcedLogoutHandler.Stream)
     }
 
     return router
-}
\ No newline at end of file
+}
+
+func registerAPIRoutes(
+    api *gin.RouterGroup,
+    authHandler *handlers.AuthHandler,
+    userHandler *handlers.UserHandler,
+    recoveryHandler *handlers.RecoveryHandler,
+    webhookHandler *handlers.WebhookHandler,
+    sessionHandler *handlers.SessionHandler,
+    personalTokenHandler *handlers.PersonalTokenHandler,
+    tokenService *services.TokenService,
+    personalTokens *services.PersonalTokenService,
+    limits *middleware.RateLimitRules,
+    freshEmail gin.HandlerFunc,
+    sudo gin.HandlerFunc,
+    shed gin.HandlerFunc,
+) {
+    auth := api.Group("/auth")
+    {
+        auth.GET("/health", func(c *gin.Context) {
+            response.JSON(c, http.StatusOK, gin.H{"status": "healthy"})
+        })
+        auth.POST("/register", limits.For("register"), authHandler.Register)
+        auth.POST("/start-registration", limits.For("register"), authHandler.StartRegistration)
+        auth.POST("/login", limits.For("login"), authHandler.Login)
+        auth.POST("/login/confirm", limits.For("login"), authHandler.ConfirmLogin)
+        auth.POST("/challenge/:type", limits.For("login"), authHandler.AnswerChallenge)
+        auth.POST("/guest", limits.For("guest"), authHandler.GuestLogin)
+        auth.POST("/refresh", limits.For("refresh"), authHandler.RefreshToken)
+        auth.POST("/view-only", limits.For("refresh"), authHandler.ViewOnlyToken)
+        auth.POST("/logout", middleware.Auth(tokenService, nil), authHandler.Logout)
+        auth.POST("/sudo", middleware.Auth(tokenService, nil), limits.For("login"), authHandler.Sudo)
+        auth.POST("/verify-email", authHandler.VerifyEmail)
+        auth.POST("/resend-verification", limits.For("resend_verification"), authHandler.ResendVerification)
+        auth.POST("/forgot-password", limits.For("forgot_password"), authHandler.ForgotPassword)
+        auth.POST("/reset-password", authHandler.ResetPassword)
+        auth.POST("/recovery/start", limits.For("recovery"), recoveryHandler.StartRecovery)
+        auth.POST("/recovery/complete", recoveryHandler.CompleteRecovery)
+        auth.GET("/validation-rules", authHandler.ValidationRules)
+    }
+
+    // Public profile cards for share links, served without authentication
+    api.GET("/public/profiles/:handle", shed, limits.For("public_profile"), userHandler.PublicProfileCard)
+
+    // Protected routes. Sensitive operations also require an email that
+    // isn't due for re-verification. Profile reads are low priority and
+    // shed under load.
+    users := api.Group("/users")
+    users.Use(middleware.Auth(tokenService, nil))
+    {
+        users.PUT("/me/password", freshEmail, userHandler.ChangePassword)
+        users.DELETE("/me", sudo, userHandler.DeleteAccount)
+        users.GET("/me/deletion-status", userHandler.DeletionStatus)
+        users.GET("/me/onboarding", shed, userHandler.Onboarding)
+        users.PUT("/me/recovery-email", freshEmail, sudo, recoveryHandler.SetRecoveryEmail)
+        users.POST("/me/recovery-codes", freshEmail, recoveryHandler.GenerateRecoveryCodes)
+        users.GET("/me/webhooks", webhookHandler.ListWebhooks)
+        users.POST("/me/webhooks", freshEmail, webhookHandler.CreateWebhook)
+        users.DELETE("/me/webhooks/:id", webhookHandler.DeleteWebhook)
+        users.POST("/me/webhooks/:id/rotate-secret", freshEmail, webhookHandler.RotateWebhookSecret)
+        users.GET("/me/sessions", sessionHandler.ListSessions)
+        users.GET("/me/sessions/current", sessionHandler.CurrentSession)
+        users.PATCH("/me/sessions/:id", freshEmail, sessionHandler.UpdateSession)
+        users.PUT("/me/travel-mode", freshEmail, userHandler.EnableTravelMode)
+        users.DELETE("/me/travel-mode", userHandler.DisableTravelMode)
+        users.POST("/me/mfa/totp", freshEmail, authHandler.EnrollTOTP)
+        users.POST("/me/mfa/totp/confirm", freshEmail, authHandler.ConfirmTOTP)
+        users.DELETE("/me/mfa/totp", freshEmail, authHandler.DisableTOTP)
+        users.GET("/me/security", shed, userHandler.SecurityScore)
+        users.GET("/me/tokens", personalTokenHandler.ListTokens)
+        users.POST("/me/tokens", freshEmail, sudo, personalTokenHandler.CreateToken)
+        users.DELETE("/me/tokens/:id", personalTokenHandler.RevokeToken)
+    }
+
+    // Routes integrations can also call with a personal access token. Each
+    // one names the scope the token needs; access tokens pass regardless.
+    integrations := api.Group("/users")
+    integrations.Use(middleware.Auth(tokenService, personalTokens))
+    {
+        integrations.GET("/me", middleware.RequireTokenScope(models.TokenScopeProfileRead), shed, userHandler.GetCurrentUser)
+        integrations.GET("/me/profile/completion", middleware.RequireTokenScope(models.TokenScopeProfileRead), shed, userHandler.ProfileCompletion)
+        integrations.GET("/search", middleware.RequireTokenScope(models.TokenScopeUsersSearch), shed, limits.For("user_search"), userHandler.SearchUsers)
+        integrations.PUT("/me", middleware.RequireTokenScope(models.TokenScopeProfileWrite), userHandler.UpdateProfile)
+        integrations.PUT("/me/public-profile", middleware.RequireTokenScope(models.TokenScopeProfileWrite), userHandler.UpdatePublicProfile)
+        integrations.PUT("/me/blocks/:id", middleware.RequireTokenScope(models.TokenScopeBlocksWrite), userHandler.BlockUser)
+        integrations.DELETE("/me/blocks/:id", middleware.RequireTokenScope(models.TokenScopeBlocksWrite), userHandler.UnblockUser)
+    }
+}
+
+func registerAdminRoutes(
+    api *gin.RouterGroup,
+    adminHandler *handlers.AdminHandler,
+    recoveryHandler *handlers.RecoveryHandler,
+    moderationHandler *handlers.ModerationHandler,
+    integrityHandler *handlers.IntegrityHandler,
+    keyHandler *handlers.KeyHandler,
+    accountNoteHandler *handlers.AccountNoteHandler,
+    sentEmailHandler *handlers.SentEmailHandler,
+    testClockHandler *handlers.TestClockHandler,
+    tokenService *services.TokenService,
+    userService *services.UserService,
+    sudo gin.HandlerFunc,
+) {
+    admin := api.Group("/admin")
+    admin.Use(middleware.Auth(tokenService, nil), middleware.RequireAdmin(userService))
+    {
+        admin.GET("/api-keys", adminHandler.ListAPIKeys)
+        admin.POST("/api-keys", sudo, adminHandler.CreateAPIKey)
+        admin.GET("/api-keys/:id/usage", adminHandler.GetAPIKeyUsage)
+        admin.DELETE("/api-keys/:id", adminHandler.RevokeAPIKey)
+        admin.GET("/recovery-requests", recoveryHandler.ListRequests)
+        admin.POST("/recovery-requests/:id/approve", recoveryHandler.ApproveRequest)
+        admin.POST("/recovery-requests/:id/reject", recoveryHandler.RejectRequest)
+        admin.GET("/audit", adminHandler.ListAuditLog)
+        admin.GET("/stats/funnel", adminHandler.GetFunnelStats)
+        admin.GET("/stats/login-failures", adminHandler.GetLoginFailureStats)
+        admin.GET("/integrity", integrityHandler.GetIntegrityReport)
+        admin.POST("/signing-key/reload", sudo, keyHandler.ReloadSigningKey)
+        admin.POST("/reload", sudo, keyHandler.Reload)
+        admin.GET("/ip-bans", adminHandler.ListIPBans)
+        admin.POST("/ip-bans", adminHandler.CreateIPBan)
+        admin.DELETE("/ip-bans", adminHandler.DeleteIPBan)
+        admin.GET("/rate-limit-policy", adminHandler.GetRateLimitPolicy)
+        admin.PUT("/rate-limit-policy", adminHandler.SetRateLimitPolicy)
+        admin.POST("/sessions/revoke", sudo, adminHandler.RevokeSessions)
+        admin.GET("/moderation/denials", moderationHandler.ListDenials)
+        admin.GET("/moderation/impersonation", moderationHandler.ListImpersonationReviews)
+        admin.POST("/moderation/impersonation/:id/dismiss", moderationHandler.DismissImpersonation)
+        admin.POST("/moderation/impersonation/:id/revert", moderationHandler.RevertImpersonation)
+        admin.GET("/users/:id/token-families", adminHandler.ListTokenFamilies)
+        admin.GET("/token-families/:id/export", adminHandler.ExportTokenFamily)
+        admin.PUT("/users/:id/geo-block-exempt", adminHandler.SetGeoBlockExempt)
+        admin.PUT("/users/:id/plan", adminHandler.SetPlan)
+        admin.GET("/users/:id", adminHandler.GetUser)
+        admin.GET("/users/:id/badges", adminHandler.GetBadges)
+        admin.PUT("/users/:id/badges/:badge", adminHandler.GrantBadge)
+        admin.DELETE("/users/:id/badges/:badge", adminHandler.RevokeBadge)
+        admin.GET("/users/:id/notes", accountNoteHandler.ListNotes)
+        admin.POST("/users/:id/notes", accountNoteHandler.CreateNote)
+        admin.DELETE("/users/:id/notes/:note_id", accountNoteHandler.DeleteNote)
+        admin.GET("/users/:id/redis-keys", adminHandler.ListUserRedisKeys)
+        admin.DELETE("/users/:id/redis-keys", adminHandler.PurgeUserRedisKeys)
+        // Only while EMAIL_SANDBOX is on
+        if sentEmailHandler != nil {
+            admin.GET("/sent-emails", sentEmailHandler.ListSentEmails)
+            admin.DELETE("/sent-emails", sentEmailHandler.ClearSentEmails)
+        }
+        // Only while TEST_MODE is on
+        if testClockHandler != nil {
+            admin.GET("/test/clock", testClockHandler.GetClock)
+            admin.POST("/test/clock", testClockHandler.AdvanceClock)
+            admin.DELETE("/test/clock", testClockHandler.ResetClock)
+        }
+    }
+}
@@ -0,0 +1,253 @@
+package authclient
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http"
+    "net/url"
+
+    "auth-service/internal/models"
+)
+
+// Client calls the auth service's public API. It is safe for concurrent use.
+type Client struct {
+    t *transport
+}
+
+// New returns a client for the public listener at baseURL, e.g.
+// "https://auth.tapin.app".
+func New(baseURL string, opts ...Option) *Client {
+    return &Client{t: newTransport(baseURL, true, opts)}
+}
+
+func (c *Client) api(method, path string) *call {
+    return newCall(method, apiPrefix+path)
+}
+
+// Register creates an account. New accounts sign in with Login.
+func (c *Client) Register(ctx context.Context, req RegisterRequest) (*User, error) {
+    var user User
+    if _, err := c.t.do(ctx, c.api(http.MethodPost, "/auth/register").withBody(req), &user); err != nil {
+        return nil, err
+    }
+    return &user, nil
+}
+
+// StartRegistration emails the code Register needs when registration email
+// codes are enabled.
+func (c *Client) StartRegistration(ctx context.Context, email string) error {
+    _, err := c.t.do(ctx, c.api(http.MethodPost, "/auth/start-registration").withBody(models.StartRegistrationRequest{Email: email}), nil)
+    return err
+}
+
+// Login signs in with an email and password.
+func (c *Client) Login(ctx context.Context, req LoginRequest) (*LoginResult, error) {
+    return c.loginStep(ctx, c.api(http.MethodPost, "/auth/login").withBody(req))
+}
+
+// ConfirmLogin completes a login held back by travel mode, with the token
+// from the emailed link.
+func (c *Client) ConfirmLogin(ctx context.Context, token string) (*LoginResult, error) {
+    return c.loginStep(ctx, c.api(http.MethodPost, "/auth/login/confirm").withBody(models.ConfirmLoginRequest{Token: token}))
+}
+
+// AnswerChallenge answers the current challenge of a challenged login, the
+// first of challenge.Challenges.
+func (c *Client) AnswerChallenge(ctx context.Context, challenge *LoginChallenge, answer string) (*LoginResult, error) {
+    if len(challenge.Challenges) == 0 {
+        return nil, errors.New("challenge has nothing left to answer")
+    }
+    path := "/auth/challenge/" + url.PathEscape(challenge.Challenges[0])
+    return c.loginStep(ctx, c.api(http.MethodPost, path).withBody(models.ChallengeAnswer{
+        ChallengeToken: challenge.Token,
+        Response:       answer,
+    }))
+}
+
+// loginStep sends a login request, which answers with tokens or, with 202,
+// a challenge or a pending confirmation.
+func (c *Client) loginStep(ctx context.Context, req *call) (*LoginResult, error) {
+    req.accept = []int{http.StatusAccepted}
+
+    var raw json.RawMessage
+    status, err := c.t.do(ctx, req, &raw)
+    if err != nil {
+        return nil, err
+    }
+
+    if status != http.StatusAccepted {
+        var tokens TokenResponse
+        if err := json.Unmarshal(raw, &tokens); err != nil {
+            return nil, fmt.Errorf("decode response: %w", err)
+        }
+        return &LoginResult{Tokens: &tokens}, nil
+    }
+
+    var step loginStep
+    if err := json.Unmarshal(raw, &step); err != nil {
+        return nil, fmt.Errorf("decode response: %w", err)
+    }
+    if step.ConfirmationRequired {
+        return &LoginResult{ConfirmationRequired: true}, nil
+    }
+    return &LoginResult{Challenge: &LoginChallenge{
+        Token:      step.ChallengeToken,
+        Challenges: step.Challenges,
+        ExpiresAt:  step.ExpiresAt,
+    }}, nil
+}
+
+// Guest creates a guest account and signs it in.
+func (c *Client) Guest(ctx context.Context) (*TokenResponse, error) {
+    var tokens TokenResponse
+    if _, err := c.t.do(ctx, c.api(http.MethodPost, "/auth/guest"), &tokens); err != nil {
+        return nil, err
+    }
+    return &tokens, nil
+}
+
+// Refresh trades a refresh token for new tokens. The old refresh token is
+// revoked, and using it again revokes the whole session, so the result must
+// replace it. Session does this for you.
+func (c *Client) Refresh(ctx context.Context, refreshToken string) (*TokenResponse, error) {
+    var tokens TokenResponse
+    if _, err := c.t.do(ctx, c.api(http.MethodPost, "/auth/refresh").withBody(models.RefreshRequest{RefreshToken: refreshToken}), &tokens); err != nil {
+        return nil, err
+    }
+    return &tokens, nil
+}
+
+// ViewOnly trades a recently expired refresh token for a read-only access
+// token.
+func (c *Client) ViewOnly(ctx context.Context, refreshToken string) (*ViewOnlyTokenResponse, error) {
+    var token ViewOnlyTokenResponse
+    if _, err := c.t.do(ctx, c.api(http.MethodPost, "/auth/view-only").withBody(models.RefreshRequest{RefreshToken: refreshToken}), &token); err != nil {
+        return nil, err
+    }
+    return &token, nil
+}
+
+// VerifyEmail verifies an email address with the token from the emailed
+// link.
+func (c *Client) VerifyEmail(ctx context.Context, token string) error {
+    req := c.api(http.MethodPost, "/auth/verify-email")
+    req.query = url.Values{"token": {token}}
+    _, err := c.t.do(ctx, req, nil)
+    return err
+}
+
+// ResendVerification sends a new verification link if the address needs
+// one. It succeeds whether or not the address has an account.
+func (c *Client) ResendVerification(ctx context.Context, email string) error {
+    _, err := c.t.do(ctx, c.api(http.MethodPost, "/auth/resend-verification").withBody(models.EmailRequest{Email: email}), nil)
+    return err
+}
+
+// ForgotPassword sends a password reset link if the address has an
+// account. It succeeds either way.
+func (c *Client) ForgotPassword(ctx context.Context, email string) error {
+    _, err := c.t.do(ctx, c.api(http.MethodPost, "/auth/forgot-password").withBody(models.EmailRequest{Email: email}), nil)
+    return err
+}
+
+// ResetPassword sets a new password with the token from the reset link.
+func (c *Client) ResetPassword(ctx context.Context, req ResetPasswordRequest) error {
+    _, err := c.t.do(ctx, c.api(http.MethodPost, "/auth/reset-password").withBody(req), nil)
+    return err
+}
+
+// StartRecovery starts recovering an account that lost access to its
+// email. The recovery token is only returned for the recovery_code method;
+// the other methods send instructions out of band.
+func (c *Client) StartRecovery(ctx context.Context, req StartRecoveryRequest) (string, error) {
+    var body struct {
+        RecoveryToken string `json:"recovery_token"`
+    }
+    if _, err := c.t.do(ctx, c.api(http.MethodPost, "/auth/recovery/start").withBody(req), &body); err != nil {
+        return "", err
+    }
+    return body.RecoveryToken, nil
+}
+
+// CompleteRecovery sets a new email and password with a recovery token.
+func (c *Client) CompleteRecovery(ctx context.Context, req CompleteRecoveryRequest) error {
+    _, err := c.t.do(ctx, c.api(http.MethodPost, "/auth/recovery/complete").withBody(req), nil)
+    return err
+}
+
+// PublicProfile returns the public card of handle, if its user shares one.
+func (c *Client) PublicProfile(ctx context.Context, handle string) (*PublicProfileCard, error) {
+    var card PublicProfileCard
+    if _, err := c.t.do(ctx, c.api(http.MethodGet, "/public/profiles/"+url.PathEscape(handle)), &card); err != nil {
+        return nil, err
+    }
+    return &card, nil
+}
+
+// ValidationRules returns the length limits the service currently enforces
+// on usernames, passwords and display names.
+func (c *Client) ValidationRules(ctx context.Context) (*ValidationLimits, error) {
+    var limits ValidationLimits
+    if _, err := c.t.do(ctx, c.api(http.MethodGet, "/auth/validation-rules"), &limits); err != nil {
+        return nil, err
+    }
+    return &limits, nil
+}
+
+// Health reports whether the service answers at all.
+func (c *Client) Health(ctx context.Context) error {
+    return health(ctx, c.t)
+}
+
+// Ready returns the service's readiness. An unavailable service is not an
+// error; its Status says so.
+func (c *Client) Ready(ctx context.Context) (*Readiness, error) {
+    return ready(ctx, c.t)
+}
+
+// Version describes the running build.
+func (c *Client) Version(ctx context.Context) (*Version, error) {
+    return version(ctx, c.t)
+}
+
+// The health routes live outside the versioned API and are never
+// enveloped, so they use an unenveloped copy of the transport.
+func health(ctx context.Context, t *transport) error {
+    _, err := t.unenveloped().do(ctx, newCall(http.MethodGet, "/health"), nil)
+    return err
+}
+
+func ready(ctx context.Context, t *transport) (*Readiness, error) {
+    req := newCall(http.MethodGet, "/readyz")
+    req.accept = []int{http.StatusServiceUnavailable}
+
+    var readiness Readiness
+    if _, err := t.unenveloped().do(ctx, req, &readiness); err != nil {
+        return nil, err
+    }
+    return &readiness, nil
+}
+
+func version(ctx context.Context, t *transport) (*Version, error) {
+    var v Version
+    if _, err := t.unenveloped().do(ctx, newCall(http.MethodGet, "/version"), &v); err != nil {
+        return nil, err
+    }
+    return &v, nil
+}
+
+func (t *transport) unenveloped() *transport {
+    if !t.enveloped {
+        return t
+    }
+    copied := *t
+    copied.enveloped = false
+    return &copied
+}
+
+func (c *call) withBody(body interface{}) *call {
+    c.body = body
+    return c
+}
@@ -0,0 +1,372 @@
+// Package authclient is a typed Go client for the auth service, so other
+// TapIn services don't have to hand-roll HTTP calls against it.
+//
+// Client talks to the public API (/api/v2) and hands out a Session per
+// signed-in user, which refreshes its access token as needed. Internal
+// talks to the service-to-service routes on the admin listener and
+// authenticates with a request signature or an API key:
+//
+//    auth := authclient.New("https://auth.tapin.internal")
+//    tokens, err := auth.Login(ctx, authclient.LoginRequest{Email: email, Password: password})
+//
+//    internal := authclient.NewInternal("http://auth-admin:9090",
+//        authclient.WithSigningKey("chat", secret))
+//    users, err := internal.GetUsers(ctx, ids)
+//
+// Failed requests return an *APIError carrying the status, message and
+// details the service answered with.
+package authclient
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "net/url"
+    "strconv"
+    "strings"
+    "time"
+
+    "auth-service/pkg/signing"
+)
+
+const (
+    defaultTimeout    = 10 * time.Second
+    defaultRetries    = 2
+    defaultMaxBackoff = 5 * time.Second
+    initialBackoff    = 100 * time.Millisecond
+    defaultUserAgent  = "tapin-authclient"
+
+    // apiPrefix is the enveloped API version the client speaks
+    apiPrefix = "/api/v2"
+)
+
+// Option configures a Client or Internal.
+type Option func(*transport)
+
+// WithHTTPClient sends requests through client instead of a default client
+// with a 10 second timeout. WithSigningKey wraps its transport.
+func WithHTTPClient(client *http.Client) Option {
+    return func(t *transport) {
+        t.http = client
+    }
+}
+
+// WithAPIKey authenticates requests with an X-API-Key header.
+func WithAPIKey(key string) Option {
+    return func(t *transport) {
+        t.apiKey = key
+    }
+}
+
+// WithSigningKey signs every request with the given key ID and secret, see
+// package signing. Services calling public routes are told apart by it too.
+func WithSigningKey(keyID string, secret []byte) Option {
+    return func(t *transport) {
+        t.signingKeyID = keyID
+        t.signingSecret = secret
+    }
+}
+
+// WithRetries sets how often an idempotent request is retried after a
+// network error, 429, 502, 503 or 504, and the longest the client waits
+// between attempts. A longer Retry-After from the service ends the retries.
+func WithRetries(retries int, maxBackoff time.Duration) Option {
+    return func(t *transport) {
+        t.retries = retries
+        t.maxBackoff = maxBackoff
+    }
+}
+
+// WithUserAgent sets the User-Agent header, which the service records on
+// sessions.
+func WithUserAgent(userAgent string) Option {
+    return func(t *transport) {
+        t.userAgent = userAgent
+    }
+}
+
+// WithClientType sets the X-Client-Type header used by the service's
+// metrics, e.g. "ios" or "web".
+func WithClientType(clientType string) Option {
+    return func(t *transport) {
+        t.clientType = clientType
+    }
+}
+
+// APIError is a response with a non-2xx status.
+type APIError struct {
+    Status  int
+    Message string
+    Details map[string]interface{}
+    // RetryAfter is the service's Retry-After header, if it sent one
+    RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+    if code := e.Code(); code != "" {
+        return fmt.Sprintf("auth service: %d %s (%s)", e.Status, e.Message, code)
+    }
+    return fmt.Sprintf("auth service: %d %s", e.Status, e.Message)
+}
+
+// Code returns the machine-readable error code in the details, e.g.
+// CodeUserGone, or "" if there is none.
+func (e *APIError) Code() string {
+    code, _ := e.Details["code"].(string)
+    return code
+}
+
+// CodeUserGone is the code of a token whose user no longer exists.
+const CodeUserGone = "AUTH_USER_GONE"
+
+// StatusOf returns the status of an *APIError, or 0 for any other error.
+func StatusOf(err error) int {
+    var apiErr *APIError
+    if errors.As(err, &apiErr) {
+        return apiErr.Status
+    }
+    return 0
+}
+
+// transport sends JSON requests and decodes their responses. Client and
+// Internal share it.
+type transport struct {
+    baseURL       string
+    enveloped     bool
+    http          *http.Client
+    apiKey        string
+    signingKeyID  string
+    signingSecret []byte
+    retries       int
+    maxBackoff    time.Duration
+    userAgent     string
+    clientType    string
+
+    // sleep waits between retries; replaced in tests
+    sleep func(ctx context.Context, d time.Duration) error
+}
+
+func newTransport(baseURL string, enveloped bool, opts []Option) *transport {
+    t := &transport{
+        baseURL:    strings.TrimRight(baseURL, "/"),
+        enveloped:  enveloped,
+        retries:    defaultRetries,
+        maxBackoff: defaultMaxBackoff,
+        userAgent:  defaultUserAgent,
+        sleep:      sleepContext,
+    }
+    for _, opt := range opts {
+        opt(t)
+    }
+
+    if t.http == nil {
+        t.http = &http.Client{Timeout: defaultTimeout}
+    }
+    if t.signingKeyID != "" {
+        // Copy the client so one passed in by the caller isn't changed
+        signed := *t.http
+        signed.Transport = &signing.Transport{KeyID: t.signingKeyID, Secret: t.signingSecret, Base: t.http.Transport}
+        t.http = &signed
+    }
+    return t
+}
+
+// call describes one API request.
+type call struct {
+    method string
+    path   string
+    query  url.Values
+    body   interface{}
+    // accessToken is sent as a bearer token when set
+    accessToken string
+    // idempotent requests are retried; defaults to true for GET, PUT and
+    // DELETE
+    idempotent bool
+    // accept lists non-2xx statuses whose body is decoded into out instead
+    // of being returned as an *APIError
+    accept []int
+}
+
+func newCall(method, path string) *call {
+    return &call{
+        method:     method,
+        path:       path,
+        idempotent: method == http.MethodGet || method == http.MethodPut || method == http.MethodDelete,
+    }
+}
+
+// do sends the call, retrying it if allowed, and decodes the response data
+// into out, which may be nil. It returns the response status.
+func (t *transport) do(ctx context.Context, c *call, out interface{}) (int, error) {
+    var body []byte
+    if c.body != nil {
+        var err error
+        body, err = json.Marshal(c.body)
+        if err != nil {
+            return 0, fmt.Errorf("encode request: %w", err)
+        }
+    }
+
+    backoff := initialBackoff
+    for attempt := 0; ; attempt++ {
+        status, wait, err := t.send(ctx, c, body, out)
+        if wait < 0 || !c.idempotent || attempt >= t.retries {
+            return status, err
+        }
+
+        if wait == 0 {
+            wait = backoff
+            backoff *= 2
+            if wait > t.maxBackoff {
+                wait = t.maxBackoff
+            }
+        } else if wait > t.maxBackoff {
+            // Not worth blocking the caller for
+            return status, err
+        }
+        if sleepErr := t.sleep(ctx, wait); sleepErr != nil {
+            return status, err
+        }
+    }
+}
+
+// send makes one attempt. wait is negative if the attempt must not be
+// retried, or else the Retry-After the service asked for, if any.
+func (t *transport) send(ctx context.Context, c *call, body []byte, out interface{}) (status int, wait time.Duration, err error) {
+    target := t.baseURL + c.path
+    if len(c.query) > 0 {
+        target += "?" + c.query.Encode()
+    }
+
+    var reader io.Reader
+    if body != nil {
+        reader = bytes.NewReader(body)
+    }
+    req, err := http.NewRequestWithContext(ctx, c.method, target, reader)
+    if err != nil {
+        return 0, -1, fmt.Errorf("create request: %w", err)
+    }
+    if body != nil {
+        req.Header.Set("Content-Type", "application/json")
+    }
+    req.Header.Set("Accept", "application/json")
+    req.Header.Set("User-Agent", t.userAgent)
+    if t.clientType != "" {
+        req.Header.Set("X-Client-Type", t.clientType)
+    }
+    if t.apiKey != "" {
+        req.Header.Set("X-API-Key", t.apiKey)
+    }
+    if c.accessToken != "" {
+        req.Header.Set("Authorization", "Bearer "+c.accessToken)
+    }
+
+    resp, err := t.http.Do(req)
+    if err != nil {
+        if ctx.Err() != nil {
+            return 0, -1, ctx.Err()
+        }
+        return 0, 0, fmt.Errorf("%s %s: %w", c.method, c.path, err)
+    }
+    defer resp.Body.Close()
+
+    raw, err := io.ReadAll(resp.Body)
+    if err != nil {
+        return resp.StatusCode, 0, fmt.Errorf("read response: %w", err)
+    }
+
+    if resp.StatusCode < 300 || containsStatus(c.accept, resp.StatusCode) {
+        return resp.StatusCode, -1, t.decode(raw, out)
+    }
+
+    apiErr := t.decodeError(resp, raw)
+    switch resp.StatusCode {
+    case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+        return resp.StatusCode, apiErr.RetryAfter, apiErr
+    default:
+        return resp.StatusCode, -1, apiErr
+    }
+}
+
+// decode unwraps the v2 envelope, if the transport speaks it, and decodes
+// the data into out.
+func (t *transport) decode(raw []byte, out interface{}) error {
+    if out == nil || len(raw) == 0 {
+        return nil
+    }
+    if t.enveloped {
+        var envelope struct {
+            Data json.RawMessage `json:"data"`
+        }
+        if err := json.Unmarshal(raw, &envelope); err != nil {
+            return fmt.Errorf("decode response: %w", err)
+        }
+        raw = envelope.Data
+    }
+    if err := json.Unmarshal(raw, out); err != nil {
+        return fmt.Errorf("decode response: %w", err)
+    }
+    return nil
+}
+
+// decodeError reads an error response. v2 nests the message and details in
+// an error object; unenveloped routes put the details next to "error".
+func (t *transport) decodeError(resp *http.Response, raw []byte) *APIError {
+    apiErr := &APIError{Status: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+    if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+        apiErr.RetryAfter = time.Duration(seconds) * time.Second
+    }
+
+    if t.enveloped {
+        var envelope struct {
+            Error *struct {
+                Message string                 `json:"message"`
+                Details map[string]interface{} `json:"details"`
+            } `json:"error"`
+        }
+        if err := json.Unmarshal(raw, &envelope); err == nil && envelope.Error != nil {
+            apiErr.Message = envelope.Error.Message
+            apiErr.Details = envelope.Error.Details
+        }
+        return apiErr
+    }
+
+    var body map[string]interface{}
+    if err := json.Unmarshal(raw, &body); err != nil {
+        return apiErr
+    }
+    if message, ok := body["error"].(string); ok {
+        apiErr.Message = message
+        delete(body, "error")
+    }
+    if len(body) > 0 {
+        apiErr.Details = body
+    }
+    return apiErr
+}
+
+func containsStatus(statuses []int, status int) bool {
+    for _, s := range statuses {
+        if s == status {
+            return true
+        }
+    }
+    return false
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+    timer := time.NewTimer(d)
+    defer timer.Stop()
+
+    select {
+    case <-ctx.Done():
+        return ctx.Err()
+    case <-timer.C:
+        return nil
+    }
+}
+
@@ -0,0 +1,277 @@
+package authclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"auth-service/pkg/signing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeData(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"data": data, "meta": map[string]string{"api_version": "v2"}})
+}
+
+func writeError(w http.ResponseWriter, status int, message string, details map[string]interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]interface{}{"message": message, "details": details},
+		"meta":  map[string]string{"api_version": "v2"},
+	})
+}
+
+func noSleep(t *transport) {
+	t.sleep = func(ctx context.Context, d time.Duration) error { return nil }
+}
+
+func TestClient_LoginChallengeAndTokens(t *testing.T) {
+	expiresAt := time.Now().Add(15 * time.Minute).UTC().Truncate(time.Second)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v2/auth/login":
+			writeData(w, http.StatusAccepted, map[string]interface{}{
+				"challenge_required": true,
+				"challenge_token":    "ct",
+				"challenges":         []string{"totp"},
+				"expires_at":         expiresAt,
+			})
+		case "/api/v2/auth/challenge/totp":
+			var answer map[string]string
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&answer))
+			assert.Equal(t, "ct", answer["challenge_token"])
+			assert.Equal(t, "123456", answer["response"])
+			writeData(w, http.StatusOK, TokenResponse{AccessToken: "access", RefreshToken: "refresh", ExpiresAt: expiresAt})
+		default:
+			t.Fatalf("unexpected request to %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	client := New(server.URL)
+
+	result, err := client.Login(context.Background(), LoginRequest{Email: "a@example.com", Password: "secret"})
+	require.NoError(t, err)
+	require.NotNil(t, result.Challenge)
+	assert.Nil(t, result.Tokens)
+	assert.Equal(t, []string{"totp"}, result.Challenge.Challenges)
+	assert.True(t, expiresAt.Equal(result.Challenge.ExpiresAt))
+
+	result, err = client.AnswerChallenge(context.Background(), result.Challenge, "123456")
+	require.NoError(t, err)
+	require.NotNil(t, result.Tokens)
+	assert.Equal(t, "access", result.Tokens.AccessToken)
+	assert.True(t, expiresAt.Equal(result.Tokens.ExpiresAt))
+}
+
+func TestClient_ErrorDetails(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusUnauthorized, "User no longer exists", map[string]interface{}{"code": CodeUserGone})
+	}))
+	defer server.Close()
+
+	session := New(server.URL).Session(&TokenResponse{AccessToken: "access", ExpiresAt: time.Now().Add(time.Hour)})
+	_, err := session.Me(context.Background())
+
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusUnauthorized, apiErr.Status)
+	assert.Equal(t, "User no longer exists", apiErr.Message)
+	assert.Equal(t, CodeUserGone, apiErr.Code())
+	assert.Equal(t, http.StatusUnauthorized, StatusOf(err))
+}
+
+func TestClient_RetriesIdempotentRequests(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.Header().Set("Retry-After", "1")
+			writeError(w, http.StatusServiceUnavailable, "Service is overloaded", nil)
+			return
+		}
+		writeData(w, http.StatusOK, PublicProfileCard{Handle: "alice"})
+	}))
+	defer server.Close()
+
+	client := New(server.URL)
+	noSleep(client.t)
+
+	card, err := client.PublicProfile(context.Background(), "alice")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", card.Handle)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+
+	// Logins aren't retried
+	atomic.StoreInt32(&calls, 0)
+	_, err = client.Login(context.Background(), LoginRequest{Email: "a@example.com", Password: "secret"})
+	assert.Equal(t, http.StatusServiceUnavailable, StatusOf(err))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestClient_NoRetryPastMaxBackoff(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Retry-After", "120")
+		writeError(w, http.StatusTooManyRequests, "Too many requests", nil)
+	}))
+	defer server.Close()
+
+	client := New(server.URL)
+	noSleep(client.t)
+
+	_, err := client.PublicProfile(context.Background(), "alice")
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, 120*time.Second, apiErr.RetryAfter)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestSession_RefreshesExpiringToken(t *testing.T) {
+	var refreshes int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v2/auth/refresh":
+			atomic.AddInt32(&refreshes, 1)
+			writeData(w, http.StatusOK, TokenResponse{AccessToken: "fresh", RefreshToken: "refresh-2", ExpiresAt: time.Now().Add(15 * time.Minute)})
+		case "/api/v2/users/me":
+			assert.Equal(t, "Bearer fresh", r.Header.Get("Authorization"))
+			writeData(w, http.StatusOK, User{Username: "alice"})
+		}
+	}))
+	defer server.Close()
+
+	session := New(server.URL).Session(&TokenResponse{AccessToken: "stale", RefreshToken: "refresh-1", ExpiresAt: time.Now().Add(10 * time.Second)})
+	var persisted string
+	session.OnRefresh(func(tokens *TokenResponse) { persisted = tokens.RefreshToken })
+
+	user, err := session.Me(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "alice", user.Username)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&refreshes))
+	assert.Equal(t, "refresh-2", persisted)
+	assert.Equal(t, "refresh-2", session.Tokens().RefreshToken)
+}
+
+func TestSession_RefreshesRejectedTokenOnce(t *testing.T) {
+	var refreshes int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v2/auth/refresh":
+			atomic.AddInt32(&refreshes, 1)
+			writeData(w, http.StatusOK, TokenResponse{AccessToken: "fresh", RefreshToken: "refresh-2", ExpiresAt: time.Now().Add(15 * time.Minute)})
+		case "/api/v2/users/me":
+			if r.Header.Get("Authorization") != "Bearer fresh" {
+				writeError(w, http.StatusUnauthorized, invalidTokenMessage, nil)
+				return
+			}
+			writeData(w, http.StatusOK, User{Username: "alice"})
+		case "/api/v2/auth/sudo":
+			writeError(w, http.StatusUnauthorized, "Invalid password", nil)
+		}
+	}))
+	defer server.Close()
+
+	session := New(server.URL).Session(&TokenResponse{AccessToken: "revoked", RefreshToken: "refresh-1", ExpiresAt: time.Now().Add(time.Hour)})
+
+	user, err := session.Me(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "alice", user.Username)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&refreshes))
+
+	// A wrong password is not fixed by refreshing
+	_, err = session.Sudo(context.Background(), "wrong", "")
+	assert.Equal(t, http.StatusUnauthorized, StatusOf(err))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&refreshes))
+}
+
+func TestSession_NoRefreshToken(t *testing.T) {
+	session := New("http://127.0.0.1:0").Session(&TokenResponse{AccessToken: "view-only", ExpiresAt: time.Now()})
+
+	_, err := session.Me(context.Background())
+	assert.ErrorIs(t, err, ErrNoRefreshToken)
+}
+
+func TestPersonalTokenSession(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tp_bot" {
+			writeError(w, http.StatusUnauthorized, invalidTokenMessage, nil)
+			return
+		}
+		writeData(w, http.StatusOK, User{Username: "alice"})
+	}))
+	defer server.Close()
+
+	user, err := New(server.URL).PersonalTokenSession("tp_bot").Me(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "alice", user.Username)
+
+	// A rejected token is never refreshed
+	_, err = New(server.URL).PersonalTokenSession("tp_revoked").Me(context.Background())
+	assert.ErrorIs(t, err, ErrNoRefreshToken)
+}
+
+func TestInternal_SignsRequestsAndReadsUnenvelopedErrors(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	id := uuid.New()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "chat", r.Header.Get(signing.HeaderKeyID))
+		assert.NotEmpty(t, r.Header.Get(signing.HeaderSignature))
+
+		switch r.URL.Path {
+		case "/internal/users/resolve":
+			assert.Equal(t, []string{"alice", "bob"}, r.URL.Query()["username"])
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"users":   map[string]uuid.UUID{"alice": id},
+				"unknown": []string{"bob"},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]interface{}{"error": "User not found", "hint": "deleted"})
+		}
+	}))
+	defer server.Close()
+
+	internal := NewInternal(server.URL, WithSigningKey("chat", secret))
+
+	resolved, err := internal.ResolveUsernames(context.Background(), "alice", "bob")
+	require.NoError(t, err)
+	assert.Equal(t, id, resolved.Users["alice"])
+	assert.Equal(t, []string{"bob"}, resolved.Unknown)
+
+	_, err = internal.GetUser(context.Background(), uuid.New())
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusNotFound, apiErr.Status)
+	assert.Equal(t, "User not found", apiErr.Message)
+	assert.Equal(t, "deleted", apiErr.Details["hint"])
+}
+
+func TestClient_ReadyAcceptsUnavailable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/readyz", r.URL.Path)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": "unavailable", "unavailable": []string{"postgres"}})
+	}))
+	defer server.Close()
+
+	client := New(server.URL)
+	noSleep(client.t)
+
+	readiness, err := client.Ready(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "unavailable", readiness.Status)
+	assert.Equal(t, []string{"postgres"}, readiness.Unavailable)
+}
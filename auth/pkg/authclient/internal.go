@@ -0,0 +1,127 @@
+package authclient
+
+import (
+    "context"
+    "net/http"
+    "net/url"
+    "strconv"
+
+    "auth-service/internal/models"
+
+    "github.com/google/uuid"
+)
+
+// Internal calls the service-to-service routes under /internal on the
+// admin listener. Give it WithSigningKey or WithAPIKey. It is safe for
+// concurrent use.
+type Internal struct {
+    t *transport
+}
+
+// NewInternal returns a client for the admin listener at baseURL, e.g.
+// "http://auth-admin:9090".
+func NewInternal(baseURL string, opts ...Option) *Internal {
+    return &Internal{t: newTransport(baseURL, false, opts)}
+}
+
+// ResolveUsernames maps usernames to user IDs, e.g. to resolve @mentions.
+func (i *Internal) ResolveUsernames(ctx context.Context, usernames ...string) (*ResolveResult, error) {
+    req := newCall(http.MethodGet, "/internal/users/resolve")
+    req.query = url.Values{"username": usernames}
+
+    var result ResolveResult
+    if _, err := i.t.do(ctx, req, &result); err != nil {
+        return nil, err
+    }
+    return &result, nil
+}
+
+// GetUser looks up a user by ID. An unknown ID is an *APIError with status
+// 404.
+func (i *Internal) GetUser(ctx context.Context, id uuid.UUID) (*User, error) {
+    var user User
+    if _, err := i.t.do(ctx, newCall(http.MethodGet, "/internal/users/"+id.String()), &user); err != nil {
+        return nil, err
+    }
+    return &user, nil
+}
+
+// GetUsers looks up to 100 users at once. IDs that match no user are left
+// out.
+func (i *Internal) GetUsers(ctx context.Context, ids []uuid.UUID) ([]*User, error) {
+    req := newCall(http.MethodPost, "/internal/users/batch").withBody(models.BatchUserRequest{IDs: ids})
+    // A lookup, despite the POST
+    req.idempotent = true
+
+    var body struct {
+        Users []*User `json:"users"`
+    }
+    if _, err := i.t.do(ctx, req, &body); err != nil {
+        return nil, err
+    }
+    return body.Users, nil
+}
+
+// RepublishSnapshots queues a fresh user:snapshot event for each user.
+func (i *Internal) RepublishSnapshots(ctx context.Context, ids []uuid.UUID) (*SnapshotRepublish, error) {
+    req := newCall(http.MethodPost, "/internal/users/snapshots").withBody(models.BatchUserRequest{IDs: ids})
+    // Snapshots carry the current state, so publishing one twice is harmless
+    req.idempotent = true
+
+    var result SnapshotRepublish
+    if _, err := i.t.do(ctx, req, &result); err != nil {
+        return nil, err
+    }
+    return &result, nil
+}
+
+// IssueScheduledToken pre-issues an access token that becomes valid at
+// req.NotBefore.
+func (i *Internal) IssueScheduledToken(ctx context.Context, req ScheduledTokenRequest) (*ScheduledTokenResponse, error) {
+    var token ScheduledTokenResponse
+    if _, err := i.t.do(ctx, newCall(http.MethodPost, "/internal/tokens/scheduled").withBody(req), &token); err != nil {
+        return nil, err
+    }
+    return &token, nil
+}
+
+// ReportEmailBounce flags an address that bounced, so its account has to
+// verify it again.
+func (i *Internal) ReportEmailBounce(ctx context.Context, email string) error {
+    req := newCall(http.MethodPost, "/internal/email-bounces").withBody(models.EmailRequest{Email: email})
+    req.idempotent = true
+
+    _, err := i.t.do(ctx, req, nil)
+    return err
+}
+
+// Events returns a page of the user event journal. Pass the page's
+// NextAfter as query.After to read on.
+func (i *Internal) Events(ctx context.Context, query JournalQuery) (*JournalPage, error) {
+    req := newCall(http.MethodGet, "/internal/events")
+    req.query = url.Values{"after": {strconv.FormatInt(query.After, 10)}}
+    if query.Limit > 0 {
+        req.query.Set("limit", strconv.Itoa(query.Limit))
+    }
+    if len(query.Types) > 0 {
+        req.query["type"] = query.Types
+    }
+
+    var page JournalPage
+    if _, err := i.t.do(ctx, req, &page); err != nil {
+        return nil, err
+    }
+    return &page, nil
+}
+
+func (i *Internal) Health(ctx context.Context) error {
+    return health(ctx, i.t)
+}
+
+func (i *Internal) Ready(ctx context.Context) (*Readiness, error) {
+    return ready(ctx, i.t)
+}
+
+func (i *Internal) Version(ctx context.Context) (*Version, error) {
+    return version(ctx, i.t)
+}
@@ -0,0 +1,162 @@
+package authclient
+
+import (
+    "context"
+    "errors"
+    "net/http"
+    "net/url"
+    "sync"
+    "time"
+
+    "auth-service/internal/models"
+)
+
+// refreshSkew is how long before its expiry an access token is refreshed,
+// so it doesn't expire in flight.
+const refreshSkew = 30 * time.Second
+
+// invalidTokenMessage is how the auth middleware rejects an expired or
+// revoked access token. Other 401s, e.g. a wrong password for sudo, are not
+// fixed by refreshing.
+const invalidTokenMessage = "Invalid token"
+
+// ErrNoRefreshToken is returned when a session's access token has expired
+// and it has no refresh token to get a new one, e.g. a view-only session.
+var ErrNoRefreshToken = errors.New("access token expired and there is no refresh token")
+
+// Session calls the API as one signed-in user. It refreshes the access
+// token shortly before it expires, and once more if the service rejects it
+// anyway. Refreshes are serialized, as reusing a rotated refresh token
+// revokes the whole session. It is safe for concurrent use.
+type Session struct {
+    client *Client
+
+    mu        sync.Mutex
+    tokens    TokenResponse
+    onRefresh func(tokens *TokenResponse)
+    now       func() time.Time
+}
+
+// Session returns a session for tokens, as returned by Login, Guest or
+// Refresh.
+func (c *Client) Session(tokens *TokenResponse) *Session {
+    return &Session{client: c, tokens: *tokens, now: time.Now}
+}
+
+// PersonalTokenSession returns a session that calls the API with a personal
+// access token. It is only accepted on routes its scopes open, and is never
+// refreshed.
+func (c *Client) PersonalTokenSession(token string) *Session {
+    return c.Session(&TokenResponse{AccessToken: token, ExpiresAt: personalTokenExpiry})
+}
+
+// Personal access tokens don't carry their expiry, so the session treats
+// them as never expiring and lets the service reject them
+var personalTokenExpiry = time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// OnRefresh registers fn to be called with the new tokens after every
+// refresh, e.g. to persist the rotated refresh token. It is called with the
+// session's lock held and must not call back into the session.
+func (s *Session) OnRefresh(fn func(tokens *TokenResponse)) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.onRefresh = fn
+}
+
+// Tokens returns the session's current tokens.
+func (s *Session) Tokens() TokenResponse {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return s.tokens
+}
+
+// accessToken returns an access token that isn't about to expire.
+func (s *Session) accessToken(ctx context.Context) (string, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    if s.tokens.ExpiresAt.After(s.now().Add(refreshSkew)) {
+        return s.tokens.AccessToken, nil
+    }
+    if err := s.refreshLocked(ctx); err != nil {
+        return "", err
+    }
+    return s.tokens.AccessToken, nil
+}
+
+// refresh replaces rejected with a new access token, unless another caller
+// already did.
+func (s *Session) refresh(ctx context.Context, rejected string) (string, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    if s.tokens.AccessToken != rejected {
+        return s.tokens.AccessToken, nil
+    }
+    if err := s.refreshLocked(ctx); err != nil {
+        return "", err
+    }
+    return s.tokens.AccessToken, nil
+}
+
+func (s *Session) refreshLocked(ctx context.Context) error {
+    if s.tokens.RefreshToken == "" {
+        return ErrNoRefreshToken
+    }
+
+    tokens, err := s.client.Refresh(ctx, s.tokens.RefreshToken)
+    if err != nil {
+        return err
+    }
+    s.tokens = *tokens
+    if s.onRefresh != nil {
+        s.onRefresh(tokens)
+    }
+    return nil
+}
+
+// do sends an authenticated call, refreshing and retrying once if the
+// access token is rejected.
+func (s *Session) do(ctx context.Context, c *call, out interface{}) (int, error) {
+    token, err := s.accessToken(ctx)
+    if err != nil {
+        return 0, err
+    }
+
+    c.accessToken = token
+    status, err := s.client.t.do(ctx, c, out)
+    var apiErr *APIError
+    if !errors.As(err, &apiErr) || apiErr.Status != http.StatusUnauthorized || apiErr.Message != invalidTokenMessage {
+        return status, err
+    }
+
+    if c.accessToken, err = s.refresh(ctx, token); err != nil {
+        return 0, err
+    }
+    return s.client.t.do(ctx, c, out)
+}
+
+// Logout revokes the access token and, with all, every session of the
+// user.
+func (s *Session) Logout(ctx context.Context, all bool) error {
+    req := s.client.api(http.MethodPost, "/auth/logout")
+    if all {
+        req.query = url.Values{"all": {"true"}}
+    }
+    _, err := s.do(ctx, req, nil)
+    return err
+}
+
+// Sudo re-confirms the password, and the TOTP code if TOTP is enabled, to
+// unlock sensitive operations with the current access token until the
+// returned time. A refresh ends sudo mode.
+func (s *Session) Sudo(ctx context.Context, password, code string) (time.Time, error) {
+    var body struct {
+        SudoUntil time.Time `json:"sudo_until"`
+    }
+    req := s.client.api(http.MethodPost, "/auth/sudo").withBody(models.SudoRequest{Password: password, Code: code})
+    if _, err := s.do(ctx, req, &body); err != nil {
+        return time.Time{}, err
+    }
+    return body.SudoUntil, nil
+}
@@ -0,0 +1,100 @@
+package authclient
+
+import (
+    "time"
+
+    "auth-service/internal/models"
+    "auth-service/internal/validation"
+
+    "github.com/google/uuid"
+)
+
+// The request and response types are the service's own, so the client
+// can't drift from what the handlers bind and return.
+type (
+    User                   = models.PrivateUser
+    PublicUser             = models.PublicUser
+    PublicProfileCard      = models.PublicProfileCard
+    ValidationLimits       = validation.Limits
+    TokenResponse          = models.TokenResponse
+    ViewOnlyTokenResponse  = models.ViewOnlyTokenResponse
+    ScheduledTokenRequest  = models.ScheduledTokenRequest
+    ScheduledTokenResponse = models.ScheduledTokenResponse
+    RegisterRequest        = models.RegisterRequest
+    LoginRequest           = models.LoginRequest
+    LoginChallenge         = models.LoginChallenge
+    ResetPasswordRequest   = models.ResetPasswordRequest
+    UpdateProfileRequest   = models.UpdateProfileRequest
+    ChangePasswordRequest  = models.ChangePasswordRequest
+    PublicProfileRequest   = models.PublicProfileRequest
+    UpdateSessionRequest   = models.UpdateSessionRequest
+    SessionInfo            = models.SessionInfo
+    AccountDeletion        = models.AccountDeletion
+    AccountExport          = models.AccountExport
+    StartRecoveryRequest   = models.StartRecoveryRequest
+    CompleteRecoveryRequest = models.CompleteRecoveryRequest
+    UserWebhook            = models.UserWebhook
+    CreateWebhookRequest   = models.CreateWebhookRequest
+    CreateWebhookResponse  = models.CreateWebhookResponse
+    PersonalAccessToken    = models.PersonalAccessToken
+    CreatePersonalAccessTokenRequest  = models.CreatePersonalAccessTokenRequest
+    CreatePersonalAccessTokenResponse = models.CreatePersonalAccessTokenResponse
+    TOTPEnrollment         = models.TOTPEnrollment
+    OnboardingProgress     = models.OnboardingProgress
+    ProfileCompletion      = models.ProfileCompletion
+    SecurityScore          = models.SecurityScore
+    SnapshotRepublish      = models.SnapshotRepublish
+    JournalQuery           = models.JournalQuery
+    JournalPage            = models.JournalPage
+    JournalEvent           = models.JournalEvent
+)
+
+// LoginResult is the outcome of a login step. Exactly one of Tokens,
+// Challenge and ConfirmationRequired is set: the login succeeded, the next
+// challenge has to be answered with AnswerChallenge, or travel mode holds
+// the login until it is confirmed from the emailed link.
+type LoginResult struct {
+    Tokens               *TokenResponse
+    Challenge            *LoginChallenge
+    ConfirmationRequired bool
+}
+
+// loginStep is the body of a 202 login response.
+type loginStep struct {
+    ChallengeRequired    bool      `json:"challenge_required"`
+    ChallengeToken       string    `json:"challenge_token"`
+    Challenges           []string  `json:"challenges"`
+    ExpiresAt            time.Time `json:"expires_at"`
+    ConfirmationRequired bool      `json:"confirmation_required"`
+}
+
+// DeletionResult is the answer to an account deletion request. Export is
+// only set for the export mode.
+type DeletionResult struct {
+    Deletion *AccountDeletion `json:"deletion"`
+    Export   *AccountExport   `json:"export,omitempty"`
+}
+
+// ResolveResult maps usernames to user IDs. Unknown lists the names that
+// matched no user.
+type ResolveResult struct {
+    Users   map[string]uuid.UUID `json:"users"`
+    Unknown []string             `json:"unknown"`
+}
+
+// Version describes the running build, as served at /version.
+type Version struct {
+    Service     string   `json:"service"`
+    Revision    string   `json:"revision,omitempty"`
+    BuiltAt     string   `json:"built_at,omitempty"`
+    GoVersion   string   `json:"go_version"`
+    APIVersions []string `json:"api_versions"`
+}
+
+// Readiness is the service's /readyz answer. Status is "ready", "degraded"
+// or "unavailable".
+type Readiness struct {
+    Status      string   `json:"status"`
+    Degraded    []string `json:"degraded,omitempty"`
+    Unavailable []string `json:"unavailable,omitempty"`
+}
@@ -0,0 +1,273 @@
+package authclient
+
+import (
+    "context"
+    "net/http"
+    "net/url"
+    "strconv"
+    "time"
+
+    "auth-service/internal/models"
+
+    "github.com/google/uuid"
+)
+
+// Me returns the signed-in user. A user deleted since the token was issued
+// is an *APIError with the code CodeUserGone.
+func (s *Session) Me(ctx context.Context) (*User, error) {
+    var user User
+    if _, err := s.do(ctx, s.client.api(http.MethodGet, "/users/me"), &user); err != nil {
+        return nil, err
+    }
+    return &user, nil
+}
+
+// UpdateProfile changes the username.
+func (s *Session) UpdateProfile(ctx context.Context, req UpdateProfileRequest) error {
+    _, err := s.do(ctx, s.client.api(http.MethodPut, "/users/me").withBody(req), nil)
+    return err
+}
+
+// ChangePassword changes the password, given the current one.
+func (s *Session) ChangePassword(ctx context.Context, req ChangePasswordRequest) error {
+    _, err := s.do(ctx, s.client.api(http.MethodPut, "/users/me/password").withBody(req), nil)
+    return err
+}
+
+// DeleteAccount schedules the account's deletion in mode, one of
+// models.DeletionModeDelete, Anonymize and Export; "" deletes. It needs sudo.
+func (s *Session) DeleteAccount(ctx context.Context, mode string) (*DeletionResult, error) {
+    req := s.client.api(http.MethodDelete, "/users/me")
+    if mode != "" {
+        req.query = url.Values{"mode": {mode}}
+    }
+    // Deleting twice conflicts, so a retry could hide the first success
+    req.idempotent = false
+
+    var result DeletionResult
+    if _, err := s.do(ctx, req, &result); err != nil {
+        return nil, err
+    }
+    return &result, nil
+}
+
+// DeletionStatus returns the progress of the account's deletion.
+func (s *Session) DeletionStatus(ctx context.Context) (*AccountDeletion, error) {
+    var deletion AccountDeletion
+    if _, err := s.do(ctx, s.client.api(http.MethodGet, "/users/me/deletion-status"), &deletion); err != nil {
+        return nil, err
+    }
+    return &deletion, nil
+}
+
+// Onboarding returns the user's onboarding milestones.
+func (s *Session) Onboarding(ctx context.Context) (*OnboardingProgress, error) {
+    var progress OnboardingProgress
+    if _, err := s.do(ctx, s.client.api(http.MethodGet, "/users/me/onboarding"), &progress); err != nil {
+        return nil, err
+    }
+    return &progress, nil
+}
+
+// ProfileCompletion returns the profile fields the user has yet to fill in.
+func (s *Session) ProfileCompletion(ctx context.Context) (*ProfileCompletion, error) {
+    var completion ProfileCompletion
+    if _, err := s.do(ctx, s.client.api(http.MethodGet, "/users/me/profile/completion"), &completion); err != nil {
+        return nil, err
+    }
+    return &completion, nil
+}
+
+// SecurityScore returns the account's security checklist.
+func (s *Session) SecurityScore(ctx context.Context) (*SecurityScore, error) {
+    var score SecurityScore
+    if _, err := s.do(ctx, s.client.api(http.MethodGet, "/users/me/security"), &score); err != nil {
+        return nil, err
+    }
+    return &score, nil
+}
+
+// UpdatePublicProfile changes the public profile fields that are set.
+func (s *Session) UpdatePublicProfile(ctx context.Context, req PublicProfileRequest) error {
+    _, err := s.do(ctx, s.client.api(http.MethodPut, "/users/me/public-profile").withBody(req), nil)
+    return err
+}
+
+// SearchUsers finds discoverable users by handle or display name. limit
+// may be 0 for the service's default.
+func (s *Session) SearchUsers(ctx context.Context, query string, limit int) ([]*PublicUser, error) {
+    req := s.client.api(http.MethodGet, "/users/search")
+    req.query = url.Values{"q": {query}}
+    if limit > 0 {
+        req.query.Set("limit", strconv.Itoa(limit))
+    }
+
+    var body struct {
+        Users []*PublicUser `json:"users"`
+    }
+    if _, err := s.do(ctx, req, &body); err != nil {
+        return nil, err
+    }
+    return body.Users, nil
+}
+
+// Block blocks userID for the signed-in user; Unblock lifts it.
+func (s *Session) Block(ctx context.Context, userID uuid.UUID) error {
+    _, err := s.do(ctx, s.client.api(http.MethodPut, "/users/me/blocks/"+userID.String()), nil)
+    return err
+}
+
+func (s *Session) Unblock(ctx context.Context, userID uuid.UUID) error {
+    _, err := s.do(ctx, s.client.api(http.MethodDelete, "/users/me/blocks/"+userID.String()), nil)
+    return err
+}
+
+// SetRecoveryEmail sets the address account recovery can use. It needs
+// sudo.
+func (s *Session) SetRecoveryEmail(ctx context.Context, email string) error {
+    _, err := s.do(ctx, s.client.api(http.MethodPut, "/users/me/recovery-email").withBody(models.RecoveryEmailRequest{RecoveryEmail: email}), nil)
+    return err
+}
+
+// GenerateRecoveryCodes replaces the user's recovery codes with new ones.
+func (s *Session) GenerateRecoveryCodes(ctx context.Context) ([]string, error) {
+    var body models.RecoveryCodesResponse
+    if _, err := s.do(ctx, s.client.api(http.MethodPost, "/users/me/recovery-codes"), &body); err != nil {
+        return nil, err
+    }
+    return body.Codes, nil
+}
+
+func (s *Session) Webhooks(ctx context.Context) ([]*UserWebhook, error) {
+    var body struct {
+        Webhooks []*UserWebhook `json:"webhooks"`
+    }
+    if _, err := s.do(ctx, s.client.api(http.MethodGet, "/users/me/webhooks"), &body); err != nil {
+        return nil, err
+    }
+    return body.Webhooks, nil
+}
+
+// CreateWebhook registers a webhook. The response holds its signing
+// secret, which is not returned again.
+func (s *Session) CreateWebhook(ctx context.Context, req CreateWebhookRequest) (*CreateWebhookResponse, error) {
+    var created CreateWebhookResponse
+    if _, err := s.do(ctx, s.client.api(http.MethodPost, "/users/me/webhooks").withBody(req), &created); err != nil {
+        return nil, err
+    }
+    return &created, nil
+}
+
+func (s *Session) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+    _, err := s.do(ctx, s.client.api(http.MethodDelete, "/users/me/webhooks/"+id.String()), nil)
+    return err
+}
+
+// RotateWebhookSecret replaces the webhook's signing secret and returns the
+// new one.
+func (s *Session) RotateWebhookSecret(ctx context.Context, id uuid.UUID) (string, error) {
+    var body struct {
+        Secret string `json:"secret"`
+    }
+    if _, err := s.do(ctx, s.client.api(http.MethodPost, "/users/me/webhooks/"+id.String()+"/rotate-secret"), &body); err != nil {
+        return "", err
+    }
+    return body.Secret, nil
+}
+
+// PersonalTokens lists the user's personal access tokens, including revoked
+// and expired ones.
+func (s *Session) PersonalTokens(ctx context.Context) ([]*PersonalAccessToken, error) {
+    var body struct {
+        Tokens []*PersonalAccessToken `json:"personal_access_tokens"`
+    }
+    if _, err := s.do(ctx, s.client.api(http.MethodGet, "/users/me/tokens"), &body); err != nil {
+        return nil, err
+    }
+    return body.Tokens, nil
+}
+
+// CreatePersonalToken issues a personal access token; it needs sudo. The
+// response holds the token, which is not returned again.
+func (s *Session) CreatePersonalToken(ctx context.Context, req CreatePersonalAccessTokenRequest) (*CreatePersonalAccessTokenResponse, error) {
+    var created CreatePersonalAccessTokenResponse
+    if _, err := s.do(ctx, s.client.api(http.MethodPost, "/users/me/tokens").withBody(req), &created); err != nil {
+        return nil, err
+    }
+    return &created, nil
+}
+
+func (s *Session) RevokePersonalToken(ctx context.Context, id uuid.UUID) error {
+    _, err := s.do(ctx, s.client.api(http.MethodDelete, "/users/me/tokens/"+id.String()), nil)
+    return err
+}
+
+// Sessions lists the user's active sessions.
+func (s *Session) Sessions(ctx context.Context) ([]*SessionInfo, error) {
+    var body struct {
+        Sessions []*SessionInfo `json:"sessions"`
+    }
+    if _, err := s.do(ctx, s.client.api(http.MethodGet, "/users/me/sessions"), &body); err != nil {
+        return nil, err
+    }
+    return body.Sessions, nil
+}
+
+// CurrentSession returns the session this session's tokens belong to.
+func (s *Session) CurrentSession(ctx context.Context) (*SessionInfo, error) {
+    var info SessionInfo
+    if _, err := s.do(ctx, s.client.api(http.MethodGet, "/users/me/sessions/current"), &info); err != nil {
+        return nil, err
+    }
+    return &info, nil
+}
+
+// UpdateSession labels a session or marks it trusted.
+func (s *Session) UpdateSession(ctx context.Context, id uuid.UUID, req UpdateSessionRequest) (*SessionInfo, error) {
+    call := s.client.api(http.MethodPatch, "/users/me/sessions/"+id.String()).withBody(req)
+    // Only the fields that are set change, so repeating it is harmless
+    call.idempotent = true
+
+    var info SessionInfo
+    if _, err := s.do(ctx, call, &info); err != nil {
+        return nil, err
+    }
+    return &info, nil
+}
+
+// EnableTravelMode holds logins from new devices for email confirmation
+// for days days, and returns when travel mode ends.
+func (s *Session) EnableTravelMode(ctx context.Context, days int) (time.Time, error) {
+    var body struct {
+        TravelModeUntil time.Time `json:"travel_mode_until"`
+    }
+    if _, err := s.do(ctx, s.client.api(http.MethodPut, "/users/me/travel-mode").withBody(models.TravelModeRequest{Days: days}), &body); err != nil {
+        return time.Time{}, err
+    }
+    return body.TravelModeUntil, nil
+}
+
+func (s *Session) DisableTravelMode(ctx context.Context) error {
+    _, err := s.do(ctx, s.client.api(http.MethodDelete, "/users/me/travel-mode"), nil)
+    return err
+}
+
+// EnrollTOTP starts TOTP enrollment. TOTP is on once ConfirmTOTP accepts a
+// code from the authenticator app.
+func (s *Session) EnrollTOTP(ctx context.Context) (*TOTPEnrollment, error) {
+    var enrollment TOTPEnrollment
+    if _, err := s.do(ctx, s.client.api(http.MethodPost, "/users/me/mfa/totp"), &enrollment); err != nil {
+        return nil, err
+    }
+    return &enrollment, nil
+}
+
+func (s *Session) ConfirmTOTP(ctx context.Context, code string) error {
+    _, err := s.do(ctx, s.client.api(http.MethodPost, "/users/me/mfa/totp/confirm").withBody(models.TOTPCodeRequest{Code: code}), nil)
+    return err
+}
+
+func (s *Session) DisableTOTP(ctx context.Context, code string) error {
+    _, err := s.do(ctx, s.client.api(http.MethodDelete, "/users/me/mfa/totp").withBody(models.TOTPCodeRequest{Code: code}), nil)
+    return err
+}
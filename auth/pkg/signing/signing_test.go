@@ -0,0 +1,67 @@
+package signing
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSign(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	now := time.Unix(1700000000, 0)
+
+	req := httptest.NewRequest(http.MethodPost, "/internal/users/batch?fields=id", strings.NewReader(`{"ids":[]}`))
+	require.NoError(t, Sign(req, "chat", secret, now))
+
+	assert.Equal(t, "chat", req.Header.Get(HeaderKeyID))
+	assert.Equal(t, "1700000000", req.Header.Get(HeaderTimestamp))
+	assert.Len(t, req.Header.Get(HeaderNonce), 32)
+	assert.Equal(t, Digest([]byte(`{"ids":[]}`)), req.Header.Get(HeaderDigest))
+
+	expected := Signature(secret, StringToSign("POST", "/internal/users/batch?fields=id",
+		"1700000000", req.Header.Get(HeaderNonce), req.Header.Get(HeaderDigest)))
+	assert.Equal(t, expected, req.Header.Get(HeaderSignature))
+
+	// The body can still be sent
+	body, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Equal(t, `{"ids":[]}`, string(body))
+
+	// Every request gets its own nonce
+	other := httptest.NewRequest(http.MethodGet, "/internal/users/resolve", nil)
+	require.NoError(t, Sign(other, "chat", secret, now))
+	assert.NotEqual(t, req.Header.Get(HeaderNonce), other.Header.Get(HeaderNonce))
+	assert.Equal(t, Digest(nil), other.Header.Get(HeaderDigest))
+}
+
+func TestTransport(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+
+	var received *http.Request
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &Transport{KeyID: "user", Secret: secret}}
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/internal/email-bounces", strings.NewReader(`{"email":"a@b.c"}`))
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Empty(t, req.Header.Get(HeaderSignature), "caller's request must not be modified")
+	require.NotNil(t, received)
+	assert.Equal(t, `{"email":"a@b.c"}`, string(body))
+	assert.Equal(t, Signature(secret, StringToSign("POST", "/internal/email-bounces",
+		received.Header.Get(HeaderTimestamp), received.Header.Get(HeaderNonce), Digest(body))),
+		received.Header.Get(HeaderSignature))
+}
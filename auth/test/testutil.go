@@ -2,14 +2,19 @@ package test
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"log"
+	"sync"
 	"testing"
 	"time"
 
 	"auth-service/internal/config"
 	"auth-service/internal/database"
+	"auth-service/internal/events"
 	"auth-service/internal/models"
 	"auth-service/internal/redis"
+	"auth-service/internal/secrets"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
@@ -39,6 +44,7 @@ type TestSuite struct {
 	Redis  *TestRedis
 	Config *config.Config
 	Logger *zap.SugaredLogger
+	Events *EventRecorder
 	ctx    context.Context
 }
 
@@ -110,7 +116,13 @@ func NewTestSuite(t *testing.T) *TestSuite {
 		JWTExpiry:      15 * time.Minute,
 		RefreshExpiry:  24 * time.Hour,
 		AllowedOrigins: []string{"*"},
+		CORSPolicies:   config.DefaultCORSPolicies([]string{"*"}),
 		RateLimit:      100,
+
+		RecoveryTokenExpiry:     30 * time.Minute,
+		SudoTTL:                 10 * time.Minute,
+		EmailVerificationExpiry: 24 * time.Hour,
+		TrustedRefreshExpiry:    30 * 24 * time.Hour,
 	}
 
 	return &TestSuite{
@@ -118,6 +130,7 @@ func NewTestSuite(t *testing.T) *TestSuite {
 		Redis:  testRedis,
 		Config: cfg,
 		Logger: sugar,
+		Events: &EventRecorder{},
 		ctx:    ctx,
 	}
 }
@@ -141,7 +154,7 @@ func (ts *TestSuite) Cleanup(t *testing.T) {
 
 // CleanDatabase truncates all tables
 func (ts *TestSuite) CleanDatabase(t *testing.T) {
-	_, err := ts.DB.Pool().Exec(ts.ctx, "TRUNCATE TABLE sessions, users RESTART IDENTITY CASCADE")
+	_, err := ts.DB.Pool().Exec(ts.ctx, "TRUNCATE TABLE sessions, users, account_deletions RESTART IDENTITY CASCADE")
 	require.NoError(t, err)
 }
 
@@ -162,6 +175,19 @@ func (ts *TestSuite) CreateTestUser(t *testing.T, email, username, password stri
 	return user
 }
 
+// CreateEmailVerificationToken stores a verification token for the user's
+// current email, hashed the same way the service stores it
+func (ts *TestSuite) CreateEmailVerificationToken(t *testing.T, userID uuid.UUID, email, token string, expiresAt time.Time) {
+	sum := sha256.Sum256([]byte(token))
+
+	_, err := ts.DB.Pool().Exec(ts.ctx,
+		`INSERT INTO email_verification_tokens (user_id, email, token_hash, expires_at)
+		 VALUES ($1, $2, $3, $4)`,
+		userID, email, hex.EncodeToString(sum[:]), expiresAt,
+	)
+	require.NoError(t, err)
+}
+
 // CreateTestSession creates a test session in the database
 func (ts *TestSuite) CreateTestSession(t *testing.T, userID uuid.UUID) *models.Session {
 	session := &models.Session{
@@ -184,6 +210,13 @@ func (ts *TestSuite) CreateTestSession(t *testing.T, userID uuid.UUID) *models.S
 	return session
 }
 
+// Keyring returns a fixed keyring holding secret for a token service under test
+func Keyring(t *testing.T, secret string) *secrets.Keyring {
+	keys, err := secrets.StaticKeyring(secret)
+	require.NoError(t, err)
+	return keys
+}
+
 // MockTestSuite provides mocked dependencies for unit tests
 type MockTestSuite struct {
 	Config *config.Config
@@ -204,7 +237,13 @@ func NewMockTestSuite() *MockTestSuite {
 		JWTExpiry:      15 * time.Minute,
 		RefreshExpiry:  24 * time.Hour,
 		AllowedOrigins: []string{"*"},
+		CORSPolicies:   config.DefaultCORSPolicies([]string{"*"}),
 		RateLimit:      100,
+
+		RecoveryTokenExpiry:     30 * time.Minute,
+		SudoTTL:                 10 * time.Minute,
+		EmailVerificationExpiry: 24 * time.Hour,
+		TrustedRefreshExpiry:    30 * 24 * time.Hour,
 	}
 
 	return &MockTestSuite{
@@ -213,6 +252,20 @@ func NewMockTestSuite() *MockTestSuite {
 	}
 }
 
+// EventRecorder captures published user events in memory
+type EventRecorder struct {
+	mu     sync.Mutex
+	Events []*events.UserEvent
+}
+
+// PublishUserEvent records the event instead of sending it to a broker
+func (r *EventRecorder) PublishUserEvent(event *events.UserEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Events = append(r.Events, event)
+	return nil
+}
+
 // AssertErrorContains checks if error contains expected message
 func AssertErrorContains(t *testing.T, err error, expected string) {
 	require.Error(t, err)
@@ -243,4 +296,4 @@ var TestData = struct {
 	ValidPassword: "password123",
 	InvalidEmail:  "invalid-email",
 	ShortPassword: "123",
-}
\ No newline at end of file
+}